@@ -0,0 +1,230 @@
+// Package admission is a Kubernetes validating admission webhook that holds
+// automated agents to their Agent-Safe capability tokens.
+//
+// An agent attaches its token, JSON or compact, to the objects it writes in
+// the agent-safe.io/token annotation. The webhook verifies it against the
+// AdmissionReview, which the policy sees as
+//
+//	{"verb": "create", "resource": "deployments", "group": "apps",
+//	 "version": "v1", "subresource": "", "kind": "Deployment",
+//	 "namespace": "agents", "name": "web",
+//	 "user": "system:serviceaccount:agents:deployer", "groups": [...],
+//	 "dry_run": false, "object": {...}, "old_object": {...}}
+//
+// so a policy can say, for example,
+//
+//	(and (member (get req "verb") (tuple "create" "update"))
+//	     (= (get req "namespace") "agents")
+//	     (<= (get (get (get req "object") "spec") "replicas") 5))
+//
+// Mount Handler on an HTTPS server and register it in a
+// ValidatingWebhookConfiguration. The AdmissionReview types here cover the
+// fields the webhook uses, so the package needs no Kubernetes modules.
+package admission
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Annotations read from the object under review.
+const (
+	TokenAnnotation        = "agent-safe.io/token"
+	PresentationAnnotation = "agent-safe.io/presentation"
+)
+
+// maxReviewBody bounds an AdmissionReview request body; the API server
+// caps objects at 3 MiB.
+const maxReviewBody = 8 << 20
+
+// Options configures Handler.
+type Options struct {
+	// Verify configures token verification: trust anchors, vars, counters
+	// and crypto callbacks. PresentationSignature is taken from each
+	// object's PresentationAnnotation instead.
+	Verify spl.VerifyTokenOptions
+	// AllowUnannotated admits objects that carry no token, so that only
+	// agents that present one are constrained. Without it such requests
+	// are denied; a namespaceSelector or objectSelector on the webhook
+	// configuration is the other way to scope it.
+	AllowUnannotated bool
+	// Request, when set, adjusts the request map built from a review
+	// before the policy sees it.
+	Request func(r *Request, req map[string]any)
+	// OnDecision, when set, is called with every verification result, for
+	// logging and audit.
+	OnDecision func(r *Request, res spl.VerifyTokenResult)
+}
+
+// AdmissionReview is the admission.k8s.io/v1 request and response body.
+type AdmissionReview struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Request    *Request  `json:"request,omitempty"`
+	Response   *Response `json:"response,omitempty"`
+}
+
+// Request is the part of an AdmissionRequest the webhook reads.
+type Request struct {
+	UID         string               `json:"uid"`
+	Kind        GroupVersionKind     `json:"kind"`
+	Resource    GroupVersionResource `json:"resource"`
+	SubResource string               `json:"subResource,omitempty"`
+	Name        string               `json:"name,omitempty"`
+	Namespace   string               `json:"namespace,omitempty"`
+	Operation   string               `json:"operation"`
+	UserInfo    UserInfo             `json:"userInfo"`
+	Object      json.RawMessage      `json:"object,omitempty"`
+	OldObject   json.RawMessage      `json:"oldObject,omitempty"`
+	DryRun      *bool                `json:"dryRun,omitempty"`
+}
+
+// GroupVersionKind identifies the kind of the object under review.
+type GroupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// GroupVersionResource identifies the resource being acted on.
+type GroupVersionResource struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+// UserInfo identifies the user making the request.
+type UserInfo struct {
+	Username string   `json:"username"`
+	UID      string   `json:"uid,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// Response is an AdmissionResponse.
+type Response struct {
+	UID     string  `json:"uid"`
+	Allowed bool    `json:"allowed"`
+	Status  *Status `json:"status,omitempty"`
+}
+
+// Status explains a denial to the API client.
+type Status struct {
+	Code    int32  `json:"code"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message"`
+}
+
+// Handler serves AdmissionReview requests. Malformed reviews get 400;
+// every well-formed one gets a 200 AdmissionReview whose response allows or
+// denies the request, a denial carrying a 403 status and the reason.
+func Handler(opts Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var review AdmissionReview
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReviewBody)).Decode(&review); err != nil || review.Request == nil {
+			http.Error(w, "invalid AdmissionReview", http.StatusBadRequest)
+			return
+		}
+		resp := Review(review.Request, opts)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AdmissionReview{
+			APIVersion: "admission.k8s.io/v1",
+			Kind:       "AdmissionReview",
+			Response:   resp,
+		})
+	})
+}
+
+// Review decides one admission request.
+func Review(ar *Request, opts Options) *Response {
+	deny := func(reason string) *Response {
+		return &Response{UID: ar.UID, Status: &Status{Code: http.StatusForbidden, Reason: "Forbidden", Message: "agent-safe: " + reason}}
+	}
+	annotations := objectAnnotations(ar)
+	raw := strings.TrimSpace(annotations[TokenAnnotation])
+	if raw == "" {
+		if opts.AllowUnannotated {
+			return &Response{UID: ar.UID, Allowed: true}
+		}
+		return deny("no " + TokenAnnotation + " annotation")
+	}
+	tok, err := spl.ParseToken(raw)
+	if err != nil {
+		return deny(err.Error())
+	}
+	req, err := RequestMap(ar)
+	if err != nil {
+		return deny(err.Error())
+	}
+	if opts.Request != nil {
+		opts.Request(ar, req)
+	}
+	vopts := opts.Verify
+	vopts.PresentationSignature = annotations[PresentationAnnotation]
+	res := spl.VerifyTokenObj(tok, req, vopts)
+	if opts.OnDecision != nil {
+		opts.OnDecision(ar, res)
+	}
+	if !res.Allow {
+		reason := res.ErrorMessage()
+		if reason == "" {
+			reason = "policy denied the request"
+		}
+		return deny(reason)
+	}
+	return &Response{UID: ar.UID, Allowed: true}
+}
+
+// objectAnnotations returns the annotations of the object under review, or
+// of the old object for a delete.
+func objectAnnotations(ar *Request) map[string]string {
+	obj := ar.Object
+	if len(obj) == 0 || string(obj) == "null" {
+		obj = ar.OldObject
+	}
+	var meta struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	json.Unmarshal(obj, &meta)
+	return meta.Metadata.Annotations
+}
+
+// RequestMap describes an admission request as an SPL request.
+func RequestMap(ar *Request) (map[string]any, error) {
+	groups := make([]any, len(ar.UserInfo.Groups))
+	for i, g := range ar.UserInfo.Groups {
+		groups[i] = g
+	}
+	req := map[string]any{
+		"verb":        strings.ToLower(ar.Operation),
+		"resource":    ar.Resource.Resource,
+		"group":       ar.Resource.Group,
+		"version":     ar.Resource.Version,
+		"subresource": ar.SubResource,
+		"kind":        ar.Kind.Kind,
+		"namespace":   ar.Namespace,
+		"name":        ar.Name,
+		"user":        ar.UserInfo.Username,
+		"groups":      groups,
+		"dry_run":     ar.DryRun != nil && *ar.DryRun,
+	}
+	for key, raw := range map[string]json.RawMessage{"object": ar.Object, "old_object": ar.OldObject} {
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		req[key] = v
+	}
+	return req, nil
+}
@@ -0,0 +1,110 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+const testPolicy = `(and (member (get req "verb") (tuple "create" "update"))
+  (= (get req "namespace") "agents")
+  (= (get req "user") "system:serviceaccount:agents:deployer")
+  (<= (get (get (get req "object") "spec") "replicas") 5))`
+
+func review(t *testing.T, h http.Handler, op, namespace, token string, replicas int) *Response {
+	t.Helper()
+	obj := map[string]any{
+		"metadata": map[string]any{"name": "web", "annotations": map[string]string{}},
+		"spec":     map[string]any{"replicas": replicas},
+	}
+	if token != "" {
+		obj["metadata"].(map[string]any)["annotations"] = map[string]string{TokenAnnotation: token}
+	}
+	raw, _ := json.Marshal(obj)
+	ar := AdmissionReview{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview", Request: &Request{
+		UID:       "uid-1",
+		Kind:      GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		Resource:  GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		Name:      "web",
+		Namespace: namespace,
+		Operation: op,
+		UserInfo:  UserInfo{Username: "system:serviceaccount:agents:deployer"},
+		Object:    raw,
+	}}
+	if op == "DELETE" {
+		ar.Request.Object, ar.Request.OldObject = nil, raw
+	}
+	body, _ := json.Marshal(ar)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/validate", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body)
+	}
+	var out AdmissionReview
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.Response == nil || out.Kind != "AdmissionReview" {
+		t.Fatalf("bad response %s", w.Body)
+	}
+	if out.Response.UID != "uid-1" {
+		t.Fatalf("expected the request UID to be echoed, got %q", out.Response.UID)
+	}
+	return out.Response
+}
+
+func TestHandler(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	tok, _ := spl.Mint(testPolicy, priv, spl.MintOptions{})
+	compact, _ := tok.Compact()
+	h := Handler(Options{})
+
+	if r := review(t, h, "CREATE", "agents", compact, 3); !r.Allowed {
+		t.Fatalf("expected allow, got %+v", r.Status)
+	}
+	for name, r := range map[string]*Response{
+		"too many replicas": review(t, h, "CREATE", "agents", compact, 10),
+		"other namespace":   review(t, h, "UPDATE", "kube-system", compact, 3),
+		"delete":            review(t, h, "DELETE", "agents", compact, 3),
+		"no token":          review(t, h, "CREATE", "agents", "", 3),
+		"malformed token":   review(t, h, "CREATE", "agents", "!!", 3),
+	} {
+		if r.Allowed || r.Status == nil || r.Status.Code != http.StatusForbidden || !strings.HasPrefix(r.Status.Message, "agent-safe: ") {
+			t.Errorf("%s: expected a 403 denial, got %+v", name, r)
+		}
+	}
+
+	if r := review(t, Handler(Options{AllowUnannotated: true}), "CREATE", "kube-system", "", 50); !r.Allowed {
+		t.Fatal("expected AllowUnannotated to admit an object without a token")
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/validate", strings.NewReader(`{"kind": "AdmissionReview"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a review without a request, got %d", w.Code)
+	}
+}
+
+func TestRequestMap(t *testing.T) {
+	dry := true
+	req, err := RequestMap(&Request{
+		Operation:   "UPDATE",
+		Resource:    GroupVersionResource{Resource: "pods"},
+		SubResource: "exec",
+		UserInfo:    UserInfo{Username: "alice", Groups: []string{"system:authenticated"}},
+		DryRun:      &dry,
+		Object:      json.RawMessage(`{"kind": "PodExecOptions"}`),
+		OldObject:   json.RawMessage(`null`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req["verb"] != "update" || req["subresource"] != "exec" || req["dry_run"] != true || len(req["groups"].([]any)) != 1 {
+		t.Fatalf("unexpected request map %v", req)
+	}
+	if _, ok := req["old_object"]; ok {
+		t.Fatal("expected a null old object to be left out")
+	}
+}
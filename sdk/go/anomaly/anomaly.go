@@ -0,0 +1,259 @@
+// Package anomaly watches a stream of decisions for the patterns of an agent
+// that has been compromised or jailbroken: bursts of denied requests,
+// amounts walking up to a limit until the limit is found, and sudden
+// payments to many recipients never seen before. It is a first line of
+// defence, raising alerts for a person or a revocation hook to act on; it
+// does not change decisions.
+//
+// A Detector is an events.Events, so it watches the decisions events.Verify
+// and events.Notify report:
+//
+//	d := anomaly.New(anomaly.Options{OnAlert: func(a anomaly.Alert) { page(a) }})
+//	res := events.Verify(d, tok, req, opts)
+package anomaly
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/events"
+)
+
+// Alert kinds.
+const (
+	KindDenyBurst      = "deny_burst"
+	KindLimitProbe     = "limit_probe"
+	KindRecipientBurst = "recipient_burst"
+)
+
+// Alert reports a suspicious pattern in one token's decisions.
+type Alert struct {
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"`
+	// Token is the signature of the token whose decisions matched.
+	Token  string `json:"token"`
+	Detail string `json:"detail"`
+}
+
+// Options configures a Detector. Zero fields take the defaults given.
+type Options struct {
+	// Window is how far back the detector looks; 1 minute.
+	Window time.Duration
+	// DenyBurst is how many denials within Window raise KindDenyBurst; 5.
+	DenyBurst int
+	// AmountField is the request field holding an amount, as a dotted path
+	// such as "arguments.amount"; "amount".
+	AmountField string
+	// ProbeAmounts is how many distinct amounts within Window, some
+	// allowed and some denied, it takes to raise KindLimitProbe; 4.
+	ProbeAmounts int
+	// ProbeMargin is how close, as a fraction of the lowest denied amount,
+	// the highest allowed amount must come to it for KindLimitProbe; 0.1.
+	ProbeMargin float64
+	// RecipientField is the request field naming a recipient; "recipient".
+	RecipientField string
+	// RecipientBurst is how many recipients first seen within Window raise
+	// KindRecipientBurst; 3.
+	RecipientBurst int
+	// OnAlert receives alerts. Each kind is raised at most once per token
+	// per Window. It is called with no locks held but must not block.
+	OnAlert func(Alert)
+}
+
+// Detector raises alerts on the decisions it is given. It is safe for
+// concurrent use.
+type Detector struct {
+	opts Options
+
+	mu     sync.Mutex
+	tokens map[string]*history
+	seen   int // events since the last sweep of idle tokens
+}
+
+// history is what the detector remembers of one token.
+type history struct {
+	denials    []time.Time
+	amounts    []amount
+	recipients map[string]bool
+	newcomers  []time.Time // when recipients were first seen
+	alerted    map[string]time.Time
+	last       time.Time
+}
+
+// amount is one request's amount and its outcome.
+type amount struct {
+	at      time.Time
+	value   float64
+	allowed bool
+}
+
+// sweepEvery is how many events pass between sweeps for idle tokens.
+const sweepEvery = 1024
+
+// New returns a detector with opts.
+func New(opts Options) *Detector {
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.DenyBurst <= 0 {
+		opts.DenyBurst = 5
+	}
+	if opts.AmountField == "" {
+		opts.AmountField = "amount"
+	}
+	if opts.ProbeAmounts <= 0 {
+		opts.ProbeAmounts = 4
+	}
+	if opts.ProbeMargin <= 0 {
+		opts.ProbeMargin = 0.1
+	}
+	if opts.RecipientField == "" {
+		opts.RecipientField = "recipient"
+	}
+	if opts.RecipientBurst <= 0 {
+		opts.RecipientBurst = 3
+	}
+	return &Detector{opts: opts, tokens: map[string]*history{}}
+}
+
+func (d *Detector) OnAllow(e events.Event) { d.Observe(e) }
+func (d *Detector) OnDeny(e events.Event)  { d.Observe(e) }
+
+// OnError ignores the event: a token that fails verification never
+// reaches its policy, so its request says nothing of the agent's intent.
+func (d *Detector) OnError(events.Event) {}
+
+// Observe adds an allow or deny event to its token's history and raises
+// the alerts it completes.
+func (d *Detector) Observe(e events.Event) {
+	if e.Kind != events.KindAllow && e.Kind != events.KindDeny {
+		return
+	}
+	now := e.Time
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+	d.mu.Lock()
+	h := d.history(e.Token, now)
+	alerts := d.observe(h, e, now)
+	d.mu.Unlock()
+	if d.opts.OnAlert == nil {
+		return
+	}
+	for _, a := range alerts {
+		d.opts.OnAlert(a)
+	}
+}
+
+// history returns token's history, sweeping out idle tokens now and then.
+func (d *Detector) history(token string, now time.Time) *history {
+	if d.seen++; d.seen >= sweepEvery {
+		d.seen = 0
+		for k, h := range d.tokens {
+			if now.Sub(h.last) > d.opts.Window {
+				delete(d.tokens, k)
+			}
+		}
+	}
+	h := d.tokens[token]
+	if h == nil {
+		h = &history{recipients: map[string]bool{}, alerted: map[string]time.Time{}}
+		d.tokens[token] = h
+	}
+	h.last = now
+	return h
+}
+
+func (d *Detector) observe(h *history, e events.Event, now time.Time) []Alert {
+	o := &d.opts
+	since := now.Add(-o.Window)
+	allowed := e.Kind == events.KindAllow
+	var alerts []Alert
+	raise := func(kind, format string, args ...any) {
+		if at, ok := h.alerted[kind]; ok && at.After(since) {
+			return
+		}
+		h.alerted[kind] = now
+		alerts = append(alerts, Alert{Kind: kind, Time: now, Token: e.Token, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if !allowed {
+		h.denials = append(trim(h.denials, since), now)
+		if len(h.denials) >= o.DenyBurst {
+			raise(KindDenyBurst, "%d denials within %s", len(h.denials), o.Window)
+		}
+	}
+
+	if v, ok := lookup(e.Request, o.AmountField).(float64); ok {
+		h.amounts = append(trimAmounts(h.amounts, since), amount{now, v, allowed})
+		if lo, hi, n, ok := probe(h.amounts); ok && n >= o.ProbeAmounts && hi-lo <= o.ProbeMargin*hi {
+			raise(KindLimitProbe, "%d amounts narrowed the limit to between %g allowed and %g denied", n, lo, hi)
+		}
+	}
+
+	if r, ok := lookup(e.Request, o.RecipientField).(string); ok && r != "" && !h.recipients[r] {
+		h.recipients[r] = true
+		h.newcomers = append(trim(h.newcomers, since), now)
+		if len(h.newcomers) >= o.RecipientBurst {
+			raise(KindRecipientBurst, "%d new recipients within %s", len(h.newcomers), o.Window)
+		}
+	}
+	return alerts
+}
+
+// probe returns the highest allowed and lowest denied amount and how many
+// distinct amounts were tried, or false unless some were allowed and some
+// denied, all of the allowed below all of the denied.
+func probe(amounts []amount) (allowed, denied float64, distinct int, ok bool) {
+	var haveAllowed, haveDenied bool
+	values := map[float64]bool{}
+	for _, a := range amounts {
+		values[a.value] = true
+		switch {
+		case a.allowed && (!haveAllowed || a.value > allowed):
+			allowed, haveAllowed = a.value, true
+		case !a.allowed && (!haveDenied || a.value < denied):
+			denied, haveDenied = a.value, true
+		}
+	}
+	return allowed, denied, len(values), haveAllowed && haveDenied && allowed < denied
+}
+
+// trim drops the times before since.
+func trim(ts []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(ts) && ts[i].Before(since) {
+		i++
+	}
+	return append(ts[:0], ts[i:]...)
+}
+
+func trimAmounts(as []amount, since time.Time) []amount {
+	i := 0
+	for i < len(as) && as[i].at.Before(since) {
+		i++
+	}
+	return append(as[:0], as[i:]...)
+}
+
+// lookup returns the value at a dotted path in req, with numbers as
+// float64, or nil.
+func lookup(req map[string]any, path string) any {
+	var v any = req
+	for _, k := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return v
+}
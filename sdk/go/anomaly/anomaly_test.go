@@ -0,0 +1,60 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/events"
+)
+
+func TestDetector(t *testing.T) {
+	var alerts []Alert
+	d := New(Options{OnAlert: func(a Alert) { alerts = append(alerts, a) }})
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(i int) time.Time { return start.Add(time.Duration(i) * time.Second) }
+	event := func(i int, kind, token string, req map[string]any) {
+		d.OnAllow(events.Event{Kind: kind, Time: at(i), Token: token, Request: req})
+	}
+
+	// Walking an amount up to a limit of 100, then closing in on it.
+	for i, amt := range []float64{20, 60, 120, 90, 105, 99} {
+		kind := events.KindAllow
+		if amt > 100 {
+			kind = events.KindDeny
+		}
+		event(i, kind, "prober", map[string]any{"amount": amt, "recipient": "bob"})
+	}
+	if len(alerts) != 1 || alerts[0].Kind != KindLimitProbe || alerts[0].Token != "prober" {
+		t.Fatalf("expected a limit probe, got %+v", alerts)
+	}
+
+	alerts = nil
+	for i := 0; i < 5; i++ {
+		event(i, events.KindDeny, "denied", map[string]any{"amount": 500.0})
+	}
+	if len(alerts) != 1 || alerts[0].Kind != KindDenyBurst {
+		t.Fatalf("expected one deny burst, got %+v", alerts)
+	}
+
+	alerts = nil
+	for i, r := range []string{"a", "b", "a"} {
+		event(i, events.KindAllow, "payer", map[string]any{"recipient": r})
+	}
+	if len(alerts) != 0 {
+		t.Fatalf("expected no alert for 2 recipients, got %+v", alerts)
+	}
+	event(3, events.KindAllow, "payer", map[string]any{"recipient": "c"})
+	if len(alerts) != 1 || alerts[0].Kind != KindRecipientBurst {
+		t.Fatalf("expected a recipient burst, got %+v", alerts)
+	}
+
+	// Spread out past the window, the same denials are not a burst.
+	alerts = nil
+	for i := 0; i < 5; i++ {
+		event(i*30, events.KindDeny, "slow", nil)
+	}
+	d.OnError(events.Event{Kind: events.KindError, Time: at(150), Token: "slow"})
+	if len(alerts) != 0 {
+		t.Fatalf("expected no alerts for spread out denials, got %+v", alerts)
+	}
+}
@@ -0,0 +1,266 @@
+// Package approval adds a third outcome to verification, pending: a request
+// the policy would allow once a human approves it.
+//
+// A policy asks for approval with approval_ok?:
+//
+//	(and (= (get req "action") "payments.create")
+//	     (or (<= (get req "amount") 50) (approval_ok? "parent")))
+//
+// A Manager verifies tokens like spl.VerifyTokenObj. When the policy denies
+// only for want of approval, the Manager parks the request, notifies the
+// approvers and returns it as pending. An approver signs the request's ID
+// with their key (or mints a discharge token for it) and Approve records
+// it; the same request, presented again before it expires, is allowed.
+package approval
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Request is a parked request awaiting approval.
+type Request struct {
+	// ID identifies the request: a digest of the token signature and the
+	// request, so that the same request presented again maps to it.
+	ID      string         `json:"id"`
+	Token   string         `json:"token"`
+	Request map[string]any `json:"request"`
+	// Approvers are those whose approval the policy asked for.
+	Approvers []string `json:"approvers"`
+	// Approved maps each approver who has approved to when.
+	Approved map[string]time.Time `json:"approved,omitempty"`
+	Created  time.Time            `json:"created"`
+	// Expires ends the request: approvals are accepted, and count, until
+	// then.
+	Expires time.Time `json:"expires"`
+}
+
+// Result is the outcome of Manager.Verify. Pending is set, and Allow is
+// false, when the request awaits approval.
+type Result struct {
+	spl.VerifyTokenResult
+	Pending *Request
+}
+
+// RequestID returns the ID of req presented with tok.
+func RequestID(tok *spl.Token, req map[string]any) (string, error) {
+	// Maps marshal with sorted keys, so equal requests have equal JSON.
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("approval: request: %w", err)
+	}
+	h := sha256.New()
+	h.Write([]byte(tok.Signature))
+	h.Write([]byte{0})
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Manager parks requests that need approval and records approvals.
+type Manager struct {
+	Store Store
+	// Approvers maps each approver name used in approval_ok? to their hex
+	// Ed25519 public key.
+	Approvers map[string]string
+	// TTL is how long a parked request waits for approval and an approved
+	// request stays allowed. Default 1 hour.
+	TTL time.Duration
+	// Notify, when set, is called once when a request is parked, to ask its
+	// approvers. It must not block.
+	Notify func(Request)
+
+	now func() time.Time
+}
+
+func (m *Manager) time() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
+
+// Verify verifies tok and evaluates its policy against req, with
+// approval_ok? backed by the approvals recorded for this request. A deny
+// that approval would turn into an allow is returned as pending.
+func (m *Manager) Verify(tok *spl.Token, req map[string]any, opts spl.VerifyTokenOptions) Result {
+	id, err := RequestID(tok, req)
+	if err != nil {
+		return Result{VerifyTokenResult: spl.VerifyTokenResult{Sealed: tok.Sealed, Err: err, Code: spl.CodeOf(err)}}
+	}
+	now := m.time()
+	parked, found, err := m.Store.Get(id)
+	if err != nil {
+		err = spl.ErrStore.Wrap(fmt.Errorf("approval store: %w", err))
+		return Result{VerifyTokenResult: spl.VerifyTokenResult{Sealed: tok.Sealed, Err: err, Code: spl.CodeStore}}
+	}
+	if found && !now.Before(parked.Expires) {
+		found = false
+	}
+	opts.Crypto.ApprovalOk = func(approver string) bool {
+		_, ok := parked.Approved[approver]
+		return found && ok
+	}
+	res := spl.VerifyTokenObj(tok, req, opts)
+	if res.Allow || res.Err != nil {
+		return Result{VerifyTokenResult: res}
+	}
+
+	// Would approval allow it? Evaluate again with every approval granted.
+	asked := map[string]bool{}
+	// The pass must not take counter, rate or spend units: the request has
+	// not run, and is charged when it is approved and presented again.
+	whatIf := opts
+	whatIf.Tracer = nil
+	whatIf.Replay = nil
+	if opts.Counters != nil {
+		whatIf.Counters = readOnlyCounters{opts.Counters}
+	}
+	if opts.Rates != nil {
+		whatIf.Rates = readOnlyRates{opts.Rates}
+	}
+	if opts.Ledger != nil {
+		whatIf.Ledger = readOnlyLedger{opts.Ledger}
+	}
+	whatIf.Crypto.ApprovalOk = func(approver string) bool {
+		asked[approver] = true
+		return true
+	}
+	if !spl.VerifyTokenObj(tok, req, whatIf).Allow {
+		return Result{VerifyTokenResult: res}
+	}
+	r := Request{ID: id, Token: tok.Signature, Request: req, Created: now, Expires: now.Add(m.ttl())}
+	for a := range asked {
+		r.Approvers = append(r.Approvers, a)
+	}
+	sort.Strings(r.Approvers)
+	if found {
+		return Result{VerifyTokenResult: res, Pending: &parked}
+	}
+	if err := m.Store.Put(r); err != nil {
+		err = spl.ErrStore.Wrap(fmt.Errorf("approval store: %w", err))
+		return Result{VerifyTokenResult: spl.VerifyTokenResult{Sealed: tok.Sealed, Err: err, Code: spl.CodeStore}}
+	}
+	if m.Notify != nil {
+		m.Notify(r)
+	}
+	return Result{VerifyTokenResult: res, Pending: &r}
+}
+
+// readOnlyCounters, readOnlyRates and readOnlyLedger answer a take or
+// spend with whether it would succeed, recording nothing.
+type readOnlyCounters struct{ spl.CounterStore }
+
+func (c readOnlyCounters) CheckAndIncrement(action, window string, limit int) (bool, error) {
+	n, err := c.Count(action, window)
+	return n < limit, err
+}
+
+type readOnlyRates struct{ spl.RateStore }
+
+func (r readOnlyRates) WindowTake(action string, window time.Duration, limit int, now time.Time) (bool, error) {
+	n, err := r.WindowCount(action, window, now)
+	return n < limit, err
+}
+
+func (r readOnlyRates) BucketTake(action string, b spl.Bucket, now time.Time) (bool, error) {
+	tokens, err := r.BucketTokens(action, b, now)
+	return tokens >= 1, err
+}
+
+type readOnlyLedger struct{ spl.LedgerStore }
+
+func (l readOnlyLedger) Spend(k spl.LedgerKey, pending spl.Money, limit spl.SpendLimit) (bool, error) {
+	total, err := l.Spent(k, pending, limit.Currency)
+	return limit.Allows(total), err
+}
+
+func (m *Manager) ttl() time.Duration {
+	if m.TTL > 0 {
+		return m.TTL
+	}
+	return time.Hour
+}
+
+// ApprovalMessage is what an approver signs to approve request id.
+func ApprovalMessage(id string) []byte {
+	return []byte("agent-safe approval v1\n" + id)
+}
+
+// SignApproval signs an approval of request id with the approver's hex
+// Ed25519 private key.
+func SignApproval(id, approverPrivateKeyHex string) (string, error) {
+	seed, err := hex.DecodeString(approverPrivateKeyHex)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return "", errors.New("approval: approver private key must be a hex Ed25519 seed")
+	}
+	return hex.EncodeToString(ed25519.Sign(ed25519.NewKeyFromSeed(seed), ApprovalMessage(id))), nil
+}
+
+// Approve records approver's approval of request id, given as the hex
+// signature SignApproval returns.
+func (m *Manager) Approve(id, approver, signatureHex string) error {
+	pub, ok := m.Approvers[approver]
+	if !ok {
+		return fmt.Errorf("approval: unknown approver %q", approver)
+	}
+	if !spl.VerifyEd25519(ApprovalMessage(id), signatureHex, pub) {
+		return errors.New("approval: invalid approval signature")
+	}
+	return m.record(id, approver)
+}
+
+// ApproveWithDischarge records approver's approval of request id, given as
+// a discharge token: a token issued by the approver's key whose policy
+// allows {"approval": id, "approver": approver}, such as
+//
+//	(= (get req "approval") "3f2a...")
+//
+// This lets an approval service that mints tokens, rather than a person
+// holding a key, stand in for the approver.
+func (m *Manager) ApproveWithDischarge(id, approver string, discharge *spl.Token) error {
+	pub, ok := m.Approvers[approver]
+	if !ok {
+		return fmt.Errorf("approval: unknown approver %q", approver)
+	}
+	ring, _ := spl.NewKeyRing()
+	if err := ring.Add(spl.KeyRingEntry{ID: approver, PublicKey: pub}); err != nil {
+		return fmt.Errorf("approval: approver key: %w", err)
+	}
+	res := spl.VerifyTokenObj(discharge, map[string]any{"approval": id, "approver": approver},
+		spl.VerifyTokenOptions{KeyResolver: ring})
+	if !res.Allow {
+		reason := res.ErrorMessage()
+		if reason == "" {
+			reason = "discharge policy does not allow this approval"
+		}
+		return errors.New("approval: " + reason)
+	}
+	return m.record(id, approver)
+}
+
+func (m *Manager) record(id, approver string) error {
+	now := m.time()
+	r, found, err := m.Store.Get(id)
+	if err != nil {
+		return err
+	}
+	if !found || !now.Before(r.Expires) {
+		return fmt.Errorf("approval: no pending request %s", id)
+	}
+	asked := false
+	for _, a := range r.Approvers {
+		asked = asked || a == approver
+	}
+	if !asked {
+		return fmt.Errorf("approval: request %s does not ask %s", id, approver)
+	}
+	return m.Store.Approve(id, approver, now)
+}
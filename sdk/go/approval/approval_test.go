@@ -0,0 +1,144 @@
+package approval
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+const policy = `(and (= (get req "action") "payments.create")
+     (or (<= (get req "amount") 50) (approval_ok? "parent")))`
+
+func setup(t *testing.T) (*Manager, *spl.Token, string, *[]Request) {
+	t.Helper()
+	_, issuer := spl.GenerateKeypair()
+	tok, err := spl.Mint(policy, issuer, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentPub, parentPriv := spl.GenerateKeypair()
+	var notified []Request
+	now := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	m := &Manager{
+		Store:     NewMemoryStore(),
+		Approvers: map[string]string{"parent": parentPub},
+		TTL:       time.Hour,
+		Notify:    func(r Request) { notified = append(notified, r) },
+		now:       func() time.Time { return now },
+	}
+	return m, tok, parentPriv, &notified
+}
+
+func TestPendingThenApproved(t *testing.T) {
+	m, tok, parentPriv, notified := setup(t)
+	small := map[string]any{"action": "payments.create", "amount": 20.0}
+	big := map[string]any{"action": "payments.create", "amount": 200.0}
+
+	if res := m.Verify(tok, small, spl.VerifyTokenOptions{}); !res.Allow || res.Pending != nil {
+		t.Fatalf("expected a small payment allowed outright, got %+v", res)
+	}
+	if res := m.Verify(tok, map[string]any{"action": "refund"}, spl.VerifyTokenOptions{}); res.Allow || res.Pending != nil {
+		t.Fatalf("expected a deny approval cannot fix, got %+v", res)
+	}
+
+	res := m.Verify(tok, big, spl.VerifyTokenOptions{})
+	if res.Allow || res.Pending == nil || strings.Join(res.Pending.Approvers, ",") != "parent" {
+		t.Fatalf("expected a big payment pending the parent, got %+v", res)
+	}
+	id := res.Pending.ID
+	if again := m.Verify(tok, big, spl.VerifyTokenOptions{}); again.Pending == nil || again.Pending.ID != id {
+		t.Fatalf("expected the same pending request, got %+v", again)
+	}
+	if len(*notified) != 1 {
+		t.Fatalf("expected one notification, got %d", len(*notified))
+	}
+
+	_, stranger := spl.GenerateKeypair()
+	forged, _ := SignApproval(id, stranger)
+	if err := m.Approve(id, "parent", forged); err == nil {
+		t.Fatal("expected an approval by the wrong key to fail")
+	}
+	if err := m.Approve(id, "grandparent", forged); err == nil {
+		t.Fatal("expected an unknown approver to fail")
+	}
+	sig, err := SignApproval(id, parentPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Approve(id, "parent", sig); err != nil {
+		t.Fatal(err)
+	}
+	if res := m.Verify(tok, big, spl.VerifyTokenOptions{}); !res.Allow {
+		t.Fatalf("expected the approved payment allowed, got %+v", res)
+	}
+	other := map[string]any{"action": "payments.create", "amount": 300.0}
+	if res := m.Verify(tok, other, spl.VerifyTokenOptions{}); res.Allow {
+		t.Fatal("expected the approval to cover only the approved request")
+	}
+
+	later := time.Date(2026, 1, 31, 13, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return later }
+	res = m.Verify(tok, big, spl.VerifyTokenOptions{})
+	if res.Allow || res.Pending == nil || len(res.Pending.Approved) != 0 {
+		t.Fatalf("expected the approval expired and the request parked again, got %+v", res)
+	}
+	if err := m.Approve("nonexistent", "parent", sig); err == nil {
+		t.Fatal("expected approving an unknown request to fail")
+	}
+}
+
+func TestPendingTakesNoCounters(t *testing.T) {
+	m, _, parentPriv, _ := setup(t)
+	_, issuer := spl.GenerateKeypair()
+	tok, err := spl.Mint(`(and (<= (per-day-count "pay" (get req "day")) 5) (approval_ok? "parent"))`, issuer, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counters := &spl.MemoryCounters{}
+	opts := spl.VerifyTokenOptions{Counters: counters}
+	req := map[string]any{"action": "pay", "day": "2026-01-31"}
+	var res Result
+	for i := 0; i < 3; i++ {
+		if res = m.Verify(tok, req, opts); res.Pending == nil {
+			t.Fatalf("expected the request pending, got %+v", res)
+		}
+	}
+	if n, _ := counters.Count("pay", "2026-01-31"); n != 0 {
+		t.Fatalf("expected pending checks to count nothing, got %d", n)
+	}
+	sig, _ := SignApproval(res.Pending.ID, parentPriv)
+	if err := m.Approve(res.Pending.ID, "parent", sig); err != nil {
+		t.Fatal(err)
+	}
+	if res := m.Verify(tok, req, opts); !res.Allow {
+		t.Fatalf("expected the approved request allowed, got %+v", res)
+	}
+	if n, _ := counters.Count("pay", "2026-01-31"); n != 1 {
+		t.Fatalf("expected the approved request counted once, got %d", n)
+	}
+}
+
+func TestApproveWithDischarge(t *testing.T) {
+	m, tok, parentPriv, _ := setup(t)
+	big := map[string]any{"action": "payments.create", "amount": 200.0}
+	id := m.Verify(tok, big, spl.VerifyTokenOptions{}).Pending.ID
+
+	wrong, _ := spl.Mint(`(= (get req "approval") "something-else")`, parentPriv, spl.MintOptions{})
+	if err := m.ApproveWithDischarge(id, "parent", wrong); err == nil {
+		t.Fatal("expected a discharge for another request to fail")
+	}
+	_, stranger := spl.GenerateKeypair()
+	forged, _ := spl.Mint(`(= (get req "approval") "`+id+`")`, stranger, spl.MintOptions{})
+	if err := m.ApproveWithDischarge(id, "parent", forged); err == nil {
+		t.Fatal("expected a discharge from the wrong issuer to fail")
+	}
+	discharge, _ := spl.Mint(`(= (get req "approval") "`+id+`")`, parentPriv, spl.MintOptions{})
+	if err := m.ApproveWithDischarge(id, "parent", discharge); err != nil {
+		t.Fatal(err)
+	}
+	if res := m.Verify(tok, big, spl.VerifyTokenOptions{}); !res.Allow {
+		t.Fatalf("expected the discharged payment allowed, got %+v", res)
+	}
+}
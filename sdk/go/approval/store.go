@@ -0,0 +1,65 @@
+package approval
+
+import (
+	"sync"
+	"time"
+)
+
+// Store keeps parked requests. Implementations must be safe for concurrent
+// use.
+type Store interface {
+	// Get returns the request with id, if one is stored.
+	Get(id string) (Request, bool, error)
+	// Put stores r, replacing any request with its ID.
+	Put(r Request) error
+	// Approve records approver's approval of request id at.
+	Approve(id, approver string, at time.Time) error
+}
+
+// MemoryStore is a Store in memory, for a single verifier. Expired requests
+// are removed as new ones are stored.
+type MemoryStore struct {
+	mu       sync.Mutex
+	requests map[string]Request
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{requests: map[string]Request{}}
+}
+
+func (s *MemoryStore) Get(id string) (Request, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.requests[id]
+	return r, ok, nil
+}
+
+func (s *MemoryStore) Put(r Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, old := range s.requests {
+		if !r.Created.Before(old.Expires) {
+			delete(s.requests, id)
+		}
+	}
+	s.requests[r.ID] = r
+	return nil
+}
+
+func (s *MemoryStore) Approve(id, approver string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.requests[id]
+	if !ok {
+		return nil
+	}
+	approved := make(map[string]time.Time, len(r.Approved)+1)
+	for k, v := range r.Approved {
+		approved[k] = v
+	}
+	approved[approver] = at
+	r.Approved = approved
+	s.requests[id] = r
+	return nil
+}
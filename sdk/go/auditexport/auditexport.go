@@ -0,0 +1,229 @@
+// Package auditexport streams every decision to a log pipeline, such as a
+// SIEM, in batches and in the background.
+//
+// An Exporter is an events.Events and an spl.AuditLog, so it takes
+// decisions from events.Verify, a middleware's OnDecision through
+// events.Notify, or anything that appends audit records. It queues them,
+// groups them into batches and hands each batch to a Sink:
+//
+//	sink, err := auditexport.DialSyslog("tcp", "siem.internal:601", auditexport.SyslogOptions{})
+//	x := auditexport.New(sink, auditexport.Options{Block: true})
+//	defer x.Close()
+//	res := events.Verify(x, tok, req, opts)
+//
+// JSONL files and syslog are built in; Kafka is reached through
+// KafkaWriter, which a few lines adapt to any client library.
+package auditexport
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/events"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Sink delivers batches of decisions. The Exporter calls Write from one
+// goroutine at a time.
+type Sink interface {
+	// Write delivers batch, oldest first. On error the Exporter retries the
+	// whole batch, so a sink that may have delivered part of it can send
+	// duplicates.
+	Write(batch []events.Event) error
+	// Close flushes and releases the sink.
+	Close() error
+}
+
+// Options configures an Exporter.
+type Options struct {
+	// BatchSize is the most events handed to the sink at once. Default 100.
+	BatchSize int
+	// FlushInterval bounds how long an event waits for its batch to fill.
+	// Default 1 second.
+	FlushInterval time.Duration
+	// QueueSize bounds events waiting for the sink. Default 10000.
+	QueueSize int
+	// Block makes a full queue hold up callers until there is room, so a
+	// slow sink slows verification instead of losing decisions. Without it
+	// events that do not fit are dropped.
+	Block bool
+	// MaxAttempts bounds writes of one batch. Default 5.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubling for each retry
+	// after. Default 1 second.
+	Backoff time.Duration
+	// OnDrop, when set, is called with events that are dropped or could not
+	// be written, and why.
+	OnDrop func([]events.Event, error)
+}
+
+// ErrQueueFull is returned by Append when the queue is full and Block is
+// not set.
+var ErrQueueFull = errors.New("auditexport: queue full")
+
+var (
+	_ events.Events = (*Exporter)(nil)
+	_ spl.AuditLog  = (*Exporter)(nil)
+)
+
+// Exporter batches decisions for a Sink. It is safe for concurrent use.
+type Exporter struct {
+	sink    Sink
+	opts    Options
+	queue   chan events.Event
+	flushes chan chan error
+	done    chan struct{}
+	close   sync.Once
+	sleep   func(time.Duration)
+}
+
+// New starts an Exporter writing to sink. Close it to flush the queue and
+// close the sink.
+func New(sink Sink, opts Options) *Exporter {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10000
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	x := &Exporter{
+		sink:    sink,
+		opts:    opts,
+		queue:   make(chan events.Event, opts.QueueSize),
+		flushes: make(chan chan error),
+		done:    make(chan struct{}),
+		sleep:   time.Sleep,
+	}
+	go x.run()
+	return x
+}
+
+func (x *Exporter) OnAllow(e events.Event) { x.enqueue(e) }
+func (x *Exporter) OnDeny(e events.Event)  { x.enqueue(e) }
+func (x *Exporter) OnError(e events.Event) { x.enqueue(e) }
+
+// Append queues an audit record, for code that writes to an spl.AuditLog.
+func (x *Exporter) Append(r spl.AuditRecord) error {
+	e := events.Event{Time: r.Time, Token: r.Token, Request: r.Request, Error: r.Error}
+	switch {
+	case r.Error != "":
+		e.Kind = events.KindError
+	case r.Allow:
+		e.Kind = events.KindAllow
+	default:
+		e.Kind = events.KindDeny
+	}
+	if !x.enqueue(e) {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+func (x *Exporter) enqueue(e events.Event) bool {
+	if x.opts.Block {
+		x.queue <- e
+		return true
+	}
+	select {
+	case x.queue <- e:
+		return true
+	default:
+		x.drop([]events.Event{e}, ErrQueueFull)
+		return false
+	}
+}
+
+func (x *Exporter) drop(batch []events.Event, err error) {
+	if x.opts.OnDrop != nil {
+		x.opts.OnDrop(batch, err)
+	}
+}
+
+// Flush writes the events queued so far and returns the sink's error, if
+// it gave up on any of them.
+func (x *Exporter) Flush() error {
+	ack := make(chan error, 1)
+	select {
+	case x.flushes <- ack:
+		return <-ack
+	case <-x.done:
+		return nil
+	}
+}
+
+// Close stops accepting events, writes those queued and closes the sink.
+// Events sent after Close panic.
+func (x *Exporter) Close() error {
+	x.close.Do(func() { close(x.queue) })
+	<-x.done
+	return x.sink.Close()
+}
+
+func (x *Exporter) run() {
+	defer close(x.done)
+	tick := time.NewTicker(x.opts.FlushInterval)
+	defer tick.Stop()
+	var batch []events.Event
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := x.write(batch)
+		batch = nil
+		return err
+	}
+	for {
+		select {
+		case e, ok := <-x.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= x.opts.BatchSize {
+				flush()
+			}
+		case <-tick.C:
+			flush()
+		case ack := <-x.flushes:
+			// Take what is already queued, so Flush covers every event sent
+			// before it was called.
+			var err error
+			for n := len(x.queue); n > 0; n-- {
+				batch = append(batch, <-x.queue)
+				if len(batch) >= x.opts.BatchSize {
+					err = errors.Join(err, flush())
+				}
+			}
+			ack <- errors.Join(err, flush())
+		}
+	}
+}
+
+func (x *Exporter) write(batch []events.Event) error {
+	backoff := x.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		err := x.sink.Write(batch)
+		if err == nil {
+			return nil
+		}
+		if attempt == x.opts.MaxAttempts {
+			err = fmt.Errorf("auditexport: write failed after %d attempts: %w", attempt, err)
+			x.drop(batch, err)
+			return err
+		}
+		x.sleep(backoff)
+		backoff *= 2
+	}
+}
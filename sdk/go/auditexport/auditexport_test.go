@@ -0,0 +1,174 @@
+package auditexport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/events"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// memSink records batches, failing the first fail writes.
+type memSink struct {
+	mu      sync.Mutex
+	batches [][]events.Event
+	fail    int
+	gate    chan struct{}
+	closed  bool
+}
+
+func (s *memSink) Write(batch []events.Event) error {
+	if s.gate != nil {
+		<-s.gate
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("unavailable")
+	}
+	s.batches = append(s.batches, append([]events.Event(nil), batch...))
+	return nil
+}
+
+func (s *memSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestExporterBatches(t *testing.T) {
+	sink := &memSink{fail: 1}
+	x := New(sink, Options{BatchSize: 2, FlushInterval: time.Hour})
+	var waits []time.Duration
+	x.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	x.OnAllow(events.Event{Kind: events.KindAllow, Token: "a"})
+	x.OnDeny(events.Event{Kind: events.KindDeny, Token: "b"})
+	x.Append(spl.AuditRecord{Token: "c", Error: "token expired"})
+	if err := x.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.batches) != 2 || len(sink.batches[0]) != 2 || len(sink.batches[1]) != 1 {
+		t.Fatalf("expected batches of 2 and 1, got %+v", sink.batches)
+	}
+	if e := sink.batches[1][0]; e.Kind != events.KindError || e.Token != "c" {
+		t.Fatalf("unexpected appended record %+v", e)
+	}
+	if len(waits) != 1 || waits[0] != time.Second {
+		t.Fatalf("expected one retry after 1s, got %v", waits)
+	}
+
+	x.OnAllow(events.Event{Kind: events.KindAllow, Token: "d"})
+	x.Close()
+	if len(sink.batches) != 3 || !sink.closed {
+		t.Fatalf("expected Close to flush and close the sink, got %+v", sink.batches)
+	}
+}
+
+func TestExporterGivesUp(t *testing.T) {
+	var dropped []events.Event
+	x := New(&memSink{fail: 3}, Options{MaxAttempts: 3, OnDrop: func(b []events.Event, _ error) { dropped = append(dropped, b...) }})
+	x.sleep = func(time.Duration) {}
+	x.OnDeny(events.Event{Kind: events.KindDeny})
+	if err := x.Flush(); err == nil || len(dropped) != 1 {
+		t.Fatalf("expected the batch to be dropped after 3 attempts, got %v %v", err, dropped)
+	}
+	x.Close()
+}
+
+func TestExporterBackpressure(t *testing.T) {
+	sink := &memSink{gate: make(chan struct{})}
+	var dropped int
+	x := New(sink, Options{BatchSize: 1, QueueSize: 1, OnDrop: func(b []events.Event, _ error) { dropped += len(b) }})
+	x.OnAllow(events.Event{})
+	// The first event is held by the sink; the second fills the queue.
+	for len(x.queue) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	x.OnAllow(events.Event{})
+	if err := x.Append(spl.AuditRecord{Allow: true}); err != ErrQueueFull || dropped != 1 {
+		t.Fatalf("expected a full queue to drop, got %v with %d dropped", err, dropped)
+	}
+	close(sink.gate)
+	x.Close()
+
+	sink = &memSink{gate: make(chan struct{})}
+	x = New(sink, Options{BatchSize: 1, QueueSize: 1, Block: true})
+	x.OnAllow(events.Event{})
+	x.OnAllow(events.Event{})
+	sent := make(chan struct{})
+	go func() {
+		x.OnAllow(events.Event{})
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("expected a full queue to block the caller")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(sink.gate)
+	<-sent
+	x.Close()
+	if len(sink.batches) != 3 {
+		t.Fatalf("expected every event written, got %d batches", len(sink.batches))
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+	sink, err := OpenJSONL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := New(sink, Options{})
+	x.OnAllow(events.Event{Kind: events.KindAllow, Token: "a"})
+	x.OnDeny(events.Event{Kind: events.KindDeny, Token: "b"})
+	x.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []events.Event
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var e events.Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+	}
+	if len(got) != 2 || got[0].Token != "a" || got[1].Kind != events.KindDeny {
+		t.Fatalf("unexpected lines %+v", got)
+	}
+}
+
+type kafkaStub struct{ msgs []KafkaMessage }
+
+func (k *kafkaStub) WriteMessages(ctx context.Context, msgs ...KafkaMessage) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("no deadline")
+	}
+	k.msgs = append(k.msgs, msgs...)
+	return nil
+}
+
+func TestKafka(t *testing.T) {
+	k := &kafkaStub{}
+	at := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	if err := NewKafka(k, 0).Write([]events.Event{{Kind: events.KindDeny, Token: "sig", Time: at}, {Kind: events.KindError}}); err != nil {
+		t.Fatal(err)
+	}
+	var e events.Event
+	if len(k.msgs) != 2 || string(k.msgs[0].Key) != "sig" || !k.msgs[0].Time.Equal(at) || k.msgs[1].Key != nil ||
+		json.Unmarshal(k.msgs[0].Value, &e) != nil || e.Kind != events.KindDeny {
+		t.Fatalf("unexpected messages %+v", k.msgs)
+	}
+}
@@ -0,0 +1,59 @@
+package auditexport
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/jmcentire/agent-safe/sdk/go/events"
+)
+
+// JSONL writes each event as a line of JSON, the shape most log shippers
+// tail.
+type JSONL struct {
+	w    io.Writer
+	file *os.File
+}
+
+// NewJSONL returns a sink writing to w. Closing it does not close w.
+func NewJSONL(w io.Writer) *JSONL {
+	return &JSONL{w: w}
+}
+
+// OpenJSONL returns a sink appending to the file at path, creating it if
+// needed. Each batch is synced to disk before Write returns.
+func OpenJSONL(path string) (*JSONL, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONL{w: f, file: f}, nil
+}
+
+// Write writes batch with a single write, so a batch is never interleaved
+// with another writer's lines.
+func (s *JSONL) Write(batch []events.Event) error {
+	var buf []byte
+	for _, e := range batch {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	if _, err := s.w.Write(buf); err != nil {
+		return err
+	}
+	if s.file != nil {
+		return s.file.Sync()
+	}
+	return nil
+}
+
+// Close closes the file OpenJSONL opened.
+func (s *JSONL) Close() error {
+	if s.file != nil {
+		return s.file.Close()
+	}
+	return nil
+}
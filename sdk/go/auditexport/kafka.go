@@ -0,0 +1,68 @@
+package auditexport
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/events"
+)
+
+// KafkaMessage is one record for a Kafka topic.
+type KafkaMessage struct {
+	Key, Value []byte
+	Time       time.Time
+}
+
+// KafkaWriter produces messages to a topic. It is the one method of a Kafka
+// client this package needs, so that it does not depend on any; with
+// segmentio/kafka-go, for example:
+//
+//	type kafkaWriter struct{ *kafka.Writer }
+//
+//	func (w kafkaWriter) WriteMessages(ctx context.Context, msgs ...auditexport.KafkaMessage) error {
+//		out := make([]kafka.Message, len(msgs))
+//		for i, m := range msgs {
+//			out[i] = kafka.Message{Key: m.Key, Value: m.Value, Time: m.Time}
+//		}
+//		return w.Writer.WriteMessages(ctx, out...)
+//	}
+type KafkaWriter interface {
+	// WriteMessages writes msgs, returning once they are acknowledged.
+	WriteMessages(ctx context.Context, msgs ...KafkaMessage) error
+}
+
+// Kafka writes each event as a JSON message keyed by its token's signature,
+// so that one token's decisions stay in order on one partition.
+type Kafka struct {
+	w       KafkaWriter
+	timeout time.Duration
+}
+
+// NewKafka returns a sink writing to w, allowing each batch timeout to be
+// acknowledged; zero means 30 seconds. Closing the sink does not close w.
+func NewKafka(w KafkaWriter, timeout time.Duration) *Kafka {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &Kafka{w: w, timeout: timeout}
+}
+
+func (s *Kafka) Write(batch []events.Event) error {
+	msgs := make([]KafkaMessage, len(batch))
+	for i, e := range batch {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		msgs[i] = KafkaMessage{Value: b, Time: e.Time}
+		if e.Token != "" {
+			msgs[i].Key = []byte(e.Token)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	return s.w.WriteMessages(ctx, msgs...)
+}
+
+func (s *Kafka) Close() error { return nil }
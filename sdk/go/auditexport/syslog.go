@@ -0,0 +1,156 @@
+package auditexport
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/events"
+)
+
+// SyslogOptions configures a syslog sink.
+type SyslogOptions struct {
+	// Facility is the syslog facility code. Default 10, authpriv.
+	Facility int
+	// Hostname and AppName fill the message header. Defaults: the
+	// machine's hostname and "agent-safe".
+	Hostname, AppName string
+	// TLS, when set, secures stream connections (RFC 5425).
+	TLS *tls.Config
+	// Timeout bounds dialing and each batch's writes. Default 10 seconds.
+	Timeout time.Duration
+}
+
+// Syslog sends each event as an RFC 5424 message whose MSGID is the event's
+// kind and whose body is the event as JSON. Allows are logged at severity
+// info, denies at notice and errors at warning. Over a stream ("tcp",
+// "unix") messages are framed by octet counting (RFC 6587); over a datagram
+// transport ("udp", "unixgram") each is its own datagram.
+type Syslog struct {
+	network, addr string
+	opts          SyslogOptions
+	conn          net.Conn
+}
+
+// DialSyslog connects a syslog sink to the collector at addr. After a
+// failed write the next Write dials again.
+func DialSyslog(network, addr string, opts SyslogOptions) (*Syslog, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix", "udp", "udp4", "udp6", "unixgram":
+	default:
+		return nil, fmt.Errorf("auditexport: unsupported syslog network %q", network)
+	}
+	if opts.Facility == 0 {
+		opts.Facility = 10
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.AppName == "" {
+		opts.AppName = "agent-safe"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	s := &Syslog{network: network, addr: addr, opts: opts}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Syslog) dial() error {
+	d := &net.Dialer{Timeout: s.opts.Timeout}
+	var err error
+	if s.opts.TLS != nil && s.stream() {
+		s.conn, err = tls.DialWithDialer(d, s.network, s.addr, s.opts.TLS)
+	} else {
+		s.conn, err = d.Dial(s.network, s.addr)
+	}
+	return err
+}
+
+func (s *Syslog) stream() bool {
+	switch s.network {
+	case "udp", "udp4", "udp6", "unixgram":
+		return false
+	}
+	return true
+}
+
+func (s *Syslog) Write(batch []events.Event) error {
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.opts.Timeout))
+	for _, e := range batch {
+		msg, err := s.format(e)
+		if err != nil {
+			return err
+		}
+		if s.stream() {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Syslog) format(e events.Event) ([]byte, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	severity := 6
+	switch e.Kind {
+	case events.KindDeny:
+		severity = 5
+	case events.KindError:
+		severity = 4
+	}
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	kind := e.Kind
+	if kind == "" {
+		kind = "-"
+	}
+	head := fmt.Sprintf("<%d>1 %s %s %s %d %s - ", s.opts.Facility*8+severity,
+		t.UTC().Format("2006-01-02T15:04:05.000000Z"), header(s.opts.Hostname), header(s.opts.AppName), os.Getpid(), kind)
+	return append([]byte(head), body...), nil
+}
+
+// header makes s a valid header field: printable ASCII without spaces, or
+// "-" when empty.
+func header(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c <= ' ' || c > '~' {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+func (s *Syslog) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
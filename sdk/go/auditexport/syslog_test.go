@@ -0,0 +1,88 @@
+package auditexport
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/events"
+)
+
+func TestSyslogStream(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 4)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			n, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(n))
+			msg := make([]byte, size)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			got <- string(msg)
+		}
+	}()
+
+	s, err := DialSyslog("tcp", ln.Addr().String(), SyslogOptions{Hostname: "verifier 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	at := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	if err := s.Write([]events.Event{{Kind: events.KindAllow, Time: at}, {Kind: events.KindDeny, Time: at, Token: "sig"}}); err != nil {
+		t.Fatal(err)
+	}
+	allow, deny := <-got, <-got
+	if !strings.HasPrefix(allow, "<86>1 2026-01-31T12:00:00.000000Z verifier_1 agent-safe ") || !strings.Contains(allow, ` allow - {"kind":"allow"`) {
+		t.Fatalf("unexpected allow message %q", allow)
+	}
+	if !strings.HasPrefix(deny, "<85>1 ") || !strings.HasSuffix(deny, `"token":"sig","sealed":false,"gas_used":0}`) {
+		t.Fatalf("unexpected deny message %q", deny)
+	}
+}
+
+func TestSyslogDatagram(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	s, err := DialSyslog("udp", pc.LocalAddr().String(), SyslogOptions{Facility: 16, AppName: "pay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Write([]events.Event{{Kind: events.KindError, Error: "token expired"}}); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<132>1 ") || !strings.Contains(msg, " pay ") || !strings.Contains(msg, `"error":"token expired"`) {
+		t.Fatalf("unexpected datagram %q", msg)
+	}
+
+	if _, err := DialSyslog("sctp", "x", SyslogOptions{}); err == nil {
+		t.Fatal("expected an unsupported network to be refused")
+	}
+}
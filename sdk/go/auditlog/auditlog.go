@@ -0,0 +1,350 @@
+// Package auditlog keeps a tamper-evident record of decisions. Each entry
+// carries the hash of the entry before it, so that changing, removing or
+// reordering an entry breaks every hash after it, and the log is signed at
+// checkpoints with an Ed25519 key, so that a rewritten chain is detected
+// too. VerifyLog checks a log against the key's public half.
+//
+//	log, err := auditlog.Open("/var/log/agent-safe/audit.log", auditlog.Options{PrivateKey: keyHex})
+//	x := auditexport.New(log, auditexport.Options{})
+//	res := events.Verify(x, tok, req, opts)
+//
+// A Log is an spl.AuditLog, for writing records directly, and an
+// auditexport.Sink, for logging every decision an Exporter receives.
+//
+// Entries after the last checkpoint are chained but not yet signed: cutting
+// them off the end of the log cannot be detected. Report.Unsigned counts
+// them, and Close writes a final checkpoint.
+package auditlog
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/auditexport"
+	"github.com/jmcentire/agent-safe/sdk/go/events"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Genesis is the Prev of a log's first entry.
+var Genesis = strings.Repeat("0", 64)
+
+// Entry is one decision in a log.
+type Entry struct {
+	Seq uint64 `json:"seq"`
+	// Prev is the Hash of the entry before, or Genesis.
+	Prev string `json:"prev"`
+	// Hash is EntryHash(Seq, Prev, Event).
+	Hash  string          `json:"hash"`
+	Event json.RawMessage `json:"event"`
+}
+
+// Checkpoint signs the log up to and including entry Seq, whose hash is
+// Head.
+type Checkpoint struct {
+	Seq       uint64    `json:"seq"`
+	Head      string    `json:"head"`
+	Time      time.Time `json:"time"`
+	Signature string    `json:"signature"`
+}
+
+// A log is JSON lines, each an Entry or a Checkpoint.
+type line struct {
+	*Entry
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// EntryHash returns the hex SHA-256 of an entry: its sequence number, the
+// previous entry's hash and the event's JSON exactly as logged, each on
+// its own line.
+func EntryHash(seq uint64, prev string, event []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s\n", seq, prev)
+	h.Write(event)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CheckpointPayload returns the bytes a checkpoint's signature covers.
+func CheckpointPayload(seq uint64, head string, at time.Time) []byte {
+	return []byte("agent-safe-audit-checkpoint\n" + strconv.FormatUint(seq, 10) + "\n" + head + "\n" + at.UTC().Format(time.RFC3339Nano))
+}
+
+// Options configures a Log.
+type Options struct {
+	// PrivateKey is the hex Ed25519 seed that signs checkpoints, as
+	// spl.GenerateKeypair returns it. Required.
+	PrivateKey string
+	// CheckpointEvery is the number of entries between checkpoints.
+	// Default 1000.
+	CheckpointEvery int
+	// CheckpointInterval, when positive, also checkpoints the first
+	// append this long after the last checkpoint.
+	CheckpointInterval time.Duration
+	// Now returns the current time. Default time.Now.
+	Now func() time.Time
+}
+
+var (
+	_ spl.AuditLog     = (*Log)(nil)
+	_ auditexport.Sink = (*Log)(nil)
+)
+
+// Log appends entries and checkpoints to a writer. It is safe for
+// concurrent use.
+type Log struct {
+	opts       Options
+	key        ed25519.PrivateKey
+	mu         sync.Mutex
+	w          io.Writer
+	seq        uint64
+	head       string
+	signedSeq  uint64
+	signedTime time.Time
+}
+
+// New returns a Log that starts a new chain on w. Closing the Log closes w
+// if it is an io.Closer.
+func New(w io.Writer, opts Options) (*Log, error) {
+	return newLog(w, opts, &Report{Head: Genesis})
+}
+
+var errKey = errors.New("auditlog: PrivateKey must be a hex Ed25519 seed")
+
+// newLog returns a Log continuing the verified log rep describes.
+func newLog(w io.Writer, opts Options, rep *Report) (*Log, error) {
+	seed, err := hex.DecodeString(opts.PrivateKey)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errKey
+	}
+	if opts.CheckpointEvery <= 0 {
+		opts.CheckpointEvery = 1000
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Log{
+		opts:       opts,
+		key:        ed25519.NewKeyFromSeed(seed),
+		w:          w,
+		seq:        rep.Entries,
+		head:       rep.Head,
+		signedSeq:  rep.Entries - rep.Unsigned,
+		signedTime: opts.Now(),
+	}, nil
+}
+
+// Open opens the log at path, creating it if needed, and continues its
+// chain. An existing log must verify under the key; Open refuses to extend
+// a log that does not.
+func Open(path string, opts Options) (*Log, error) {
+	pub := publicKey(opts.PrivateKey)
+	if pub == "" {
+		return nil, errKey
+	}
+	rep := &Report{Head: Genesis}
+	if f, err := os.Open(path); err == nil {
+		rep, err = verify(f, pub)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("auditlog: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("auditlog: %w", err)
+	}
+	l, err := newLog(f, opts, rep)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func publicKey(seedHex string) string {
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return ""
+	}
+	return hex.EncodeToString(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+}
+
+// Append logs a record, for code that writes to an spl.AuditLog.
+func (l *Log) Append(r spl.AuditRecord) error {
+	e := events.Event{Time: r.Time, Token: r.Token, Request: r.Request, Error: r.Error}
+	switch {
+	case r.Error != "":
+		e.Kind = events.KindError
+	case r.Allow:
+		e.Kind = events.KindAllow
+	default:
+		e.Kind = events.KindDeny
+	}
+	return l.Write([]events.Event{e})
+}
+
+// Write logs a batch of events, checkpointing when one is due.
+func (l *Log) Write(batch []events.Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var buf []byte
+	seq, head := l.seq, l.head
+	for _, e := range batch {
+		ev, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("auditlog: %w", err)
+		}
+		seq++
+		entry := Entry{Seq: seq, Prev: head, Hash: EntryHash(seq, head, ev), Event: ev}
+		b, _ := json.Marshal(line{Entry: &entry})
+		buf = append(append(buf, b...), '\n')
+		head = entry.Hash
+	}
+	if err := l.write(buf); err != nil {
+		return err
+	}
+	l.seq, l.head = seq, head
+	if l.seq-l.signedSeq >= uint64(l.opts.CheckpointEvery) ||
+		(l.opts.CheckpointInterval > 0 && l.opts.Now().Sub(l.signedTime) >= l.opts.CheckpointInterval) {
+		return l.checkpoint()
+	}
+	return nil
+}
+
+// Checkpoint signs the entries logged so far, if any are unsigned.
+func (l *Log) Checkpoint() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.checkpoint()
+}
+
+func (l *Log) checkpoint() error {
+	if l.seq == l.signedSeq {
+		return nil
+	}
+	at := l.opts.Now().UTC()
+	cp := Checkpoint{Seq: l.seq, Head: l.head, Time: at}
+	cp.Signature = hex.EncodeToString(ed25519.Sign(l.key, CheckpointPayload(cp.Seq, cp.Head, at)))
+	b, _ := json.Marshal(line{Checkpoint: &cp})
+	if err := l.write(append(b, '\n')); err != nil {
+		return err
+	}
+	l.signedSeq, l.signedTime = l.seq, at
+	return nil
+}
+
+func (l *Log) write(b []byte) error {
+	if _, err := l.w.Write(b); err != nil {
+		return fmt.Errorf("auditlog: %w", err)
+	}
+	if f, ok := l.w.(*os.File); ok {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("auditlog: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close writes a final checkpoint and closes the writer.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.checkpoint()
+	if c, ok := l.w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Report summarises a verified log.
+type Report struct {
+	Entries     uint64 `json:"entries"`
+	Checkpoints int    `json:"checkpoints"`
+	// Head is the last entry's hash, or Genesis for an empty log.
+	Head string `json:"head"`
+	// Unsigned counts the entries after the last checkpoint.
+	Unsigned uint64 `json:"unsigned"`
+}
+
+// VerifyError locates the first problem VerifyLog found.
+type VerifyError struct {
+	Line   int
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("auditlog: line %d: %s", e.Line, e.Reason)
+}
+
+// VerifyLog reads a log and checks that its entries are numbered from 1
+// without gaps, that each hash is correct and chains to the entry before,
+// and that each checkpoint names the entry before it and is signed by
+// publicKeyHex. It returns a *VerifyError for the first problem found.
+func VerifyLog(r io.Reader, publicKeyHex string) (*Report, error) {
+	return verify(r, publicKeyHex)
+}
+
+func verify(r io.Reader, publicKeyHex string) (*Report, error) {
+	rep := &Report{Head: Genesis}
+	var signed uint64
+	var lastTime time.Time
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16<<20)
+	for n := 1; sc.Scan(); n++ {
+		fail := func(format string, args ...any) (*Report, error) {
+			return nil, &VerifyError{Line: n, Reason: fmt.Sprintf(format, args...)}
+		}
+		var l line
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+			return fail("malformed line: %v", err)
+		}
+		switch {
+		case l.Checkpoint != nil && l.Entry == nil:
+			cp := l.Checkpoint
+			if cp.Seq != rep.Entries || cp.Head != rep.Head {
+				return fail("checkpoint names entry %d %s, but the log is at entry %d %s", cp.Seq, cp.Head, rep.Entries, rep.Head)
+			}
+			if cp.Time.Before(lastTime) {
+				return fail("checkpoint time %s is before the previous checkpoint's", cp.Time.Format(time.RFC3339))
+			}
+			if !spl.VerifyEd25519(CheckpointPayload(cp.Seq, cp.Head, cp.Time), cp.Signature, publicKeyHex) {
+				return fail("checkpoint signature invalid")
+			}
+			rep.Checkpoints++
+			signed, lastTime = cp.Seq, cp.Time
+		case l.Entry != nil && l.Checkpoint == nil:
+			e := l.Entry
+			if e.Seq != rep.Entries+1 {
+				return fail("entry %d follows entry %d", e.Seq, rep.Entries)
+			}
+			if e.Prev != rep.Head {
+				return fail("entry %d does not chain to entry %d", e.Seq, rep.Entries)
+			}
+			if e.Hash != EntryHash(e.Seq, e.Prev, e.Event) {
+				return fail("entry %d hash mismatch", e.Seq)
+			}
+			rep.Entries, rep.Head = e.Seq, e.Hash
+		default:
+			return fail("neither an entry nor a checkpoint")
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("auditlog: %w", err)
+	}
+	rep.Unsigned = rep.Entries - signed
+	return rep, nil
+}
@@ -0,0 +1,131 @@
+package auditlog
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/events"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func testLog(t *testing.T, every int) (*bytes.Buffer, *Log, string) {
+	t.Helper()
+	pub, priv := spl.GenerateKeypair()
+	var buf bytes.Buffer
+	at := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	l, err := New(&buf, Options{PrivateKey: priv, CheckpointEvery: every, Now: func() time.Time { return at }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &buf, l, pub
+}
+
+func TestLogVerifies(t *testing.T) {
+	buf, l, pub := testLog(t, 2)
+	for i, r := range []spl.AuditRecord{
+		{Token: "a", Allow: true},
+		{Token: "b", Request: map[string]any{"amount": 5}},
+		{Token: "c", Error: "token expired"},
+	} {
+		if err := l.Append(r); err != nil {
+			t.Fatalf("append %d: %v", i, err)
+		}
+	}
+	rep, err := VerifyLog(bytes.NewReader(buf.Bytes()), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Entries != 3 || rep.Checkpoints != 1 || rep.Unsigned != 1 {
+		t.Fatalf("expected 3 entries with the last unsigned, got %+v", rep)
+	}
+	l.Close()
+	rep, err = VerifyLog(bytes.NewReader(buf.Bytes()), pub)
+	if err != nil || rep.Checkpoints != 2 || rep.Unsigned != 0 {
+		t.Fatalf("expected Close to sign the tail, got %+v %v", rep, err)
+	}
+
+	other, _ := spl.GenerateKeypair()
+	if _, err := VerifyLog(bytes.NewReader(buf.Bytes()), other); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("expected another key to be refused, got %v", err)
+	}
+}
+
+func TestLogTampering(t *testing.T) {
+	buf, l, pub := testLog(t, 100)
+	l.Write([]events.Event{{Kind: events.KindAllow, Token: "a"}, {Kind: events.KindDeny, Token: "b"}, {Kind: events.KindAllow, Token: "c"}})
+	l.Close()
+	lines := strings.SplitAfter(buf.String(), "\n")
+
+	cases := []struct {
+		name   string
+		edit   func([]string) []string
+		line   int
+		reason string
+	}{
+		{"edited event", func(ls []string) []string {
+			ls[1] = strings.Replace(ls[1], `"kind":"deny"`, `"kind":"allow"`, 1)
+			return ls
+		}, 2, "hash mismatch"},
+		{"removed entry", func(ls []string) []string { return append(ls[:1], ls[2:]...) }, 2, "follows entry 1"},
+		{"reordered entries", func(ls []string) []string { ls[0], ls[1] = ls[1], ls[0]; return ls }, 1, "follows entry 0"},
+		{"truncated before checkpoint", func(ls []string) []string { return append(ls[:2], ls[3]) }, 3, "checkpoint names entry 3"},
+		{"garbage", func(ls []string) []string { return append(ls, "{}\n") }, 5, "neither"},
+	}
+	for _, c := range cases {
+		edited := strings.Join(c.edit(append([]string(nil), lines...)), "")
+		_, err := VerifyLog(strings.NewReader(edited), pub)
+		var ve *VerifyError
+		if !errors.As(err, &ve) || ve.Line != c.line || !strings.Contains(ve.Reason, c.reason) {
+			t.Fatalf("%s: expected line %d %q, got %v", c.name, c.line, c.reason, err)
+		}
+	}
+}
+
+func TestOpenContinuesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	pub, priv := spl.GenerateKeypair()
+	for i := 0; i < 2; i++ {
+		l, err := Open(path, Options{PrivateKey: priv})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Append(spl.AuditRecord{Allow: true})
+		l.Append(spl.AuditRecord{})
+		l.Close()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rep, err := VerifyLog(f, pub)
+	if err != nil || rep.Entries != 4 || rep.Checkpoints != 2 {
+		t.Fatalf("expected one chain of 4 entries, got %+v %v", rep, err)
+	}
+
+	os.WriteFile(path, []byte("{}\n"), 0o600)
+	if _, err := Open(path, Options{PrivateKey: priv}); err == nil {
+		t.Fatal("expected a corrupt log to be refused")
+	}
+	if _, err := Open(path, Options{PrivateKey: "zz"}); err == nil {
+		t.Fatal("expected an invalid key to be refused")
+	}
+}
+
+func TestCheckpointInterval(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	var buf bytes.Buffer
+	at := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	l, _ := New(&buf, Options{PrivateKey: priv, CheckpointInterval: time.Minute, Now: func() time.Time { return at }})
+	l.Append(spl.AuditRecord{})
+	at = at.Add(time.Minute)
+	l.Append(spl.AuditRecord{})
+	if n := strings.Count(buf.String(), `"checkpoint"`); n != 1 {
+		t.Fatalf("expected a checkpoint after the interval, got %d", n)
+	}
+}
@@ -0,0 +1,223 @@
+// Package bls adds BLS12-381 token signatures (IETF BLS basic scheme, keys
+// in G1, signatures in G2). Signatures over many tokens aggregate into one
+// 96-byte signature that a verifier checks with a single multi-pairing, so
+// an issuer minting thousands of per-session tokens can publish one
+// signature for the whole batch.
+//
+// It is a separate module so the core SDK stays dependency-free.
+package bls
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/cloudflare/circl/sign/bls"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Alg is the token alg value for BLS12-381 signatures.
+const Alg = "BLS12-381"
+
+// GenerateKeypair creates a BLS12-381 keypair: a 48-byte compressed G1
+// public key and a 32-byte secret scalar, both hex-encoded.
+func GenerateKeypair() (publicKeyHex, privateKeyHex string, err error) {
+	ikm := make([]byte, 32)
+	if _, err := rand.Read(ikm); err != nil {
+		return "", "", err
+	}
+	k, err := bls.KeyGen[bls.G1](ikm, nil, nil)
+	if err != nil {
+		return "", "", err
+	}
+	priv, err := k.MarshalBinary()
+	if err != nil {
+		return "", "", err
+	}
+	pub, err := k.PublicKey().MarshalBinary()
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(pub), hex.EncodeToString(priv), nil
+}
+
+func privateKey(privateKeyHex string) (*bls.PrivateKey[bls.G1], error) {
+	raw, err := hex.DecodeString(privateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key hex: %w", err)
+	}
+	k := new(bls.PrivateKey[bls.G1])
+	if err := k.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("invalid BLS private key: %w", err)
+	}
+	return k, nil
+}
+
+func publicKey(publicKeyHex string) (*bls.PublicKey[bls.G1], error) {
+	raw, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	k := new(bls.PublicKey[bls.G1])
+	if err := k.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Mint creates a token signed with a BLS12-381 key.
+func Mint(policy, privateKeyHex string, opts spl.MintOptions) (*spl.Token, error) {
+	toks, _, err := MintBatch([]string{policy}, privateKeyHex, opts)
+	if err != nil {
+		return nil, err
+	}
+	return toks[0], nil
+}
+
+// MintBatch signs one token per policy, all sharing opts, and returns the
+// tokens together with their aggregate signature. Each token also keeps its
+// own signature so it can be presented on its own.
+func MintBatch(policies []string, privateKeyHex string, opts spl.MintOptions) ([]*spl.Token, string, error) {
+	if opts.Alg != "" && opts.Alg != Alg {
+		return nil, "", fmt.Errorf("unsupported algorithm: %s", opts.Alg)
+	}
+	if len(opts.CertChain) > 0 || opts.PQPrivateKey != "" {
+		return nil, "", errors.New("certificate chains and PQ signatures are not supported with BLS")
+	}
+	if len(policies) == 0 {
+		return nil, "", errors.New("no policies to sign")
+	}
+	k, err := privateKey(privateKeyHex)
+	if err != nil {
+		return nil, "", err
+	}
+	pub, err := k.PublicKey().MarshalBinary()
+	if err != nil {
+		return nil, "", err
+	}
+	toks := make([]*spl.Token, len(policies))
+	sigs := make([]bls.Signature, len(policies))
+	for i, policy := range policies {
+		payload := spl.SigningPayload(policy, opts.MerkleRoot, opts.HashChainCommitment, opts.Sealed, opts.Expires)
+		sigs[i] = bls.Sign(k, payload)
+		toks[i] = &spl.Token{
+			Version:             spl.TokenVersionAlg,
+			Policy:              policy,
+			MerkleRoot:          opts.MerkleRoot,
+			HashChainCommitment: opts.HashChainCommitment,
+			Sealed:              opts.Sealed,
+			Expires:             opts.Expires,
+			PublicKey:           hex.EncodeToString(pub),
+			Signature:           hex.EncodeToString(sigs[i]),
+			PoPKey:              opts.PoPKey,
+			Alg:                 Alg,
+		}
+	}
+	if _, err := payloads(toks); err != nil {
+		return nil, "", err
+	}
+	agg, err := bls.Aggregate(bls.G1{}, sigs)
+	if err != nil {
+		return nil, "", err
+	}
+	return toks, hex.EncodeToString(agg), nil
+}
+
+// Aggregate combines the individual signatures of BLS tokens, which may come
+// from different issuers, into one signature.
+func Aggregate(tokens []*spl.Token) (string, error) {
+	if _, err := payloads(tokens); err != nil {
+		return "", err
+	}
+	sigs := make([]bls.Signature, len(tokens))
+	for i, t := range tokens {
+		sig, err := hex.DecodeString(t.Signature)
+		if err != nil {
+			return "", fmt.Errorf("token %d: invalid signature hex: %w", i, err)
+		}
+		sigs[i] = sig
+	}
+	agg, err := bls.Aggregate(bls.G1{}, sigs)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(agg), nil
+}
+
+// payloads returns the signing payloads of BLS tokens. The basic scheme is
+// only secure for distinct messages, so duplicate payloads are rejected.
+func payloads(tokens []*spl.Token) ([][]byte, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("no tokens")
+	}
+	seen := make(map[string]bool, len(tokens))
+	msgs := make([][]byte, len(tokens))
+	for i, t := range tokens {
+		if t.Alg != Alg {
+			return nil, fmt.Errorf("token %d: alg %q is not %s", i, t.Alg, Alg)
+		}
+		msgs[i] = t.Payload()
+		if seen[string(msgs[i])] {
+			return nil, fmt.Errorf("token %d: duplicate signing payload", i)
+		}
+		seen[string(msgs[i])] = true
+	}
+	return msgs, nil
+}
+
+// VerifyAggregate checks an aggregate signature over tokens in one
+// multi-pairing. Individual token signatures are ignored.
+func VerifyAggregate(tokens []*spl.Token, aggregateHex string) error {
+	msgs, err := payloads(tokens)
+	if err != nil {
+		return err
+	}
+	agg, err := hex.DecodeString(aggregateHex)
+	if err != nil {
+		return fmt.Errorf("invalid aggregate signature hex: %w", err)
+	}
+	pubs := make([]*bls.PublicKey[bls.G1], len(tokens))
+	for i, t := range tokens {
+		if pubs[i], err = publicKey(t.PublicKey); err != nil {
+			return fmt.Errorf("token %d: invalid public key: %w", i, err)
+		}
+	}
+	if !bls.VerifyAggregate(pubs, msgs, agg) {
+		return errors.New("invalid aggregate signature")
+	}
+	return nil
+}
+
+// Verify is the spl.SignatureVerifier for individually signed BLS tokens:
+//
+//	spl.VerifyTokenOptions{Algorithms: map[string]spl.SignatureVerifier{bls.Alg: bls.Verify}}
+func Verify(message []byte, signatureHex, publicKeyHex string) bool {
+	sig, err := hex.DecodeString(signatureHex)
+	if err != nil {
+		return false
+	}
+	pub, err := publicKey(publicKeyHex)
+	if err != nil {
+		return false
+	}
+	return bls.Verify(pub, message, sig)
+}
+
+// Preverified checks an aggregate once and returns a spl.SignatureVerifier
+// that accepts exactly the (payload, public key) pairs it covers. Policies
+// in the batch can then be evaluated with VerifyTokenObj at the cost of a
+// map lookup per signature check; token signature fields are not consulted.
+func Preverified(tokens []*spl.Token, aggregateHex string) (spl.SignatureVerifier, error) {
+	if err := VerifyAggregate(tokens, aggregateHex); err != nil {
+		return nil, err
+	}
+	covered := make(map[string]bool, len(tokens))
+	for _, t := range tokens {
+		payload := t.Payload()
+		covered[t.PublicKey+"\x00"+string(payload)] = true
+	}
+	return func(message []byte, _ string, publicKeyHex string) bool {
+		return covered[publicKeyHex+"\x00"+string(message)]
+	}, nil
+}
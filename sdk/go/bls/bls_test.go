@@ -0,0 +1,109 @@
+package bls
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func batchPolicies(n int) []string {
+	ps := make([]string, n)
+	for i := range ps {
+		ps[i] = fmt.Sprintf(`(and (= (get req "session") "s-%d") (<= (get req "amount") 100))`, i)
+	}
+	return ps
+}
+
+func sessionReq(i int) map[string]any {
+	return map[string]any{"session": fmt.Sprintf("s-%d", i), "amount": 50.0}
+}
+
+func TestIndividualToken(t *testing.T) {
+	_, priv, err := GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := Mint(batchPolicies(1)[0], priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := spl.VerifyTokenOptions{Algorithms: map[string]spl.SignatureVerifier{Alg: Verify}}
+	if r := spl.VerifyTokenObj(tok, sessionReq(0), opts); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+	if r := spl.VerifyTokenObj(tok, sessionReq(0), spl.VerifyTokenOptions{}); r.Allow || r.ErrorMessage() != "unsupported algorithm: "+Alg {
+		t.Fatalf("expected unsupported algorithm without the extension, got %+v", r)
+	}
+	tampered := *tok
+	tampered.Policy = batchPolicies(2)[1]
+	if r := spl.VerifyTokenObj(&tampered, sessionReq(1), opts); r.Allow {
+		t.Fatal("expected tampered policy to be rejected")
+	}
+}
+
+func TestBatchAggregate(t *testing.T) {
+	_, priv, _ := GenerateKeypair()
+	toks, agg, err := MintBatch(batchPolicies(64), priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyAggregate(toks, agg); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Aggregate(toks); again != agg {
+		t.Fatal("expected Aggregate to reproduce the batch signature")
+	}
+
+	// Batch tokens can be evaluated without their individual signatures.
+	v, err := Preverified(toks, agg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := spl.VerifyTokenOptions{Algorithms: map[string]spl.SignatureVerifier{Alg: v}}
+	for i, tok := range toks {
+		stripped := *tok
+		stripped.Signature = ""
+		if r := spl.VerifyTokenObj(&stripped, sessionReq(i), opts); !r.Allow {
+			t.Fatalf("token %d: expected allow, got %q", i, r.ErrorMessage())
+		}
+	}
+
+	// A token outside the batch is not covered.
+	other, _ := Mint(`(= (get req "session") "other")`, priv, spl.MintOptions{})
+	if r := spl.VerifyTokenObj(other, map[string]any{"session": "other"}, opts); r.Allow {
+		t.Fatal("expected token outside the batch to be rejected")
+	}
+}
+
+func TestAggregateRejectsTampering(t *testing.T) {
+	_, priv, _ := GenerateKeypair()
+	toks, agg, _ := MintBatch(batchPolicies(4), priv, spl.MintOptions{})
+	toks[2].Policy = `(= 1 1)`
+	if err := VerifyAggregate(toks, agg); err == nil {
+		t.Fatal("expected tampered batch to fail")
+	}
+}
+
+func TestAggregateAcrossIssuers(t *testing.T) {
+	_, privA, _ := GenerateKeypair()
+	_, privB, _ := GenerateKeypair()
+	a, _, _ := MintBatch(batchPolicies(2), privA, spl.MintOptions{})
+	b, _, _ := MintBatch(batchPolicies(3)[2:], privB, spl.MintOptions{})
+	toks := append(a, b...)
+	agg, err := Aggregate(toks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyAggregate(toks, agg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDuplicatePayloadsRejected(t *testing.T) {
+	_, priv, _ := GenerateKeypair()
+	p := batchPolicies(1)[0]
+	if _, _, err := MintBatch([]string{p, p}, priv, spl.MintOptions{}); err == nil {
+		t.Fatal("expected duplicate payloads to be rejected")
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/jmcentire/agent-safe/sdk/go/bls
+
+go 1.22.0
+
+require (
+	github.com/cloudflare/circl v1.6.1
+	github.com/jmcentire/agent-safe/sdk/go v0.0.0
+)
+
+require (
+	golang.org/x/crypto v0.11.1-0.20230711161743-2e82bdd1719d // indirect
+	golang.org/x/sys v0.10.0 // indirect
+)
+
+replace github.com/jmcentire/agent-safe/sdk/go => ../
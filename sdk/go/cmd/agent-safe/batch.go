@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// batchRequest is one request of a corpus, named by file or file:line.
+type batchRequest struct {
+	name string
+	req  map[string]any
+	err  error // the request could not be read or decoded
+}
+
+// batchResult is the outcome for one request in a batch run.
+type batchResult struct {
+	Request    string `json:"request"`
+	Decision   string `json:"decision"` // allow, deny, or error
+	Reason     string `json:"reason,omitempty"`
+	GasUsed    int    `json:"gas_used"`
+	DurationUS int64  `json:"duration_us"`
+}
+
+// batchSummary totals a batch run.
+type batchSummary struct {
+	Requests   int   `json:"requests"`
+	Allow      int   `json:"allow"`
+	Deny       int   `json:"deny"`
+	Errors     int   `json:"errors"`
+	MaxGas     int   `json:"max_gas"`
+	DurationUS int64 `json:"duration_us"`
+}
+
+// loadRequests reads every *.json file in a directory, sorted by name, or
+// every non-blank line of a JSONL file or, for "-", standard input. Undecodable requests are returned
+// with err set so they show up in the results instead of aborting the run.
+func (c *cli) loadRequests(path string) ([]batchRequest, error) {
+	if info, err := os.Stat(path); path != "-" && err != nil {
+		return nil, err
+	} else if path != "-" && info.IsDir() {
+		names, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(names)
+		reqs := make([]batchRequest, 0, len(names))
+		for _, name := range names {
+			r := batchRequest{name: filepath.Base(name)}
+			r.err = c.readJSON(name, &r.req)
+			reqs = append(reqs, r)
+		}
+		return reqs, nil
+	}
+	data, err := c.readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(path)
+	if path == "-" {
+		base = "stdin"
+	}
+	var reqs []batchRequest
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for line := 1; sc.Scan(); line++ {
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		r := batchRequest{name: fmt.Sprintf("%s:%d", base, line)}
+		r.err = json.Unmarshal(text, &r.req)
+		reqs = append(reqs, r)
+	}
+	return reqs, sc.Err()
+}
+
+// verifyBatch evaluates every request under path with up to parallel
+// workers and prints per-request results followed by a summary. It returns
+// errReported if any request could not be read, else errDenied if any
+// request was denied.
+func (c *cli) verifyBatch(path string, parallel int, eval func(map[string]any) decision) error {
+	reqs, err := c.loadRequests(path)
+	if err != nil {
+		return err
+	}
+	results := make([]batchResult, len(reqs))
+	start := time.Now()
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel && w < len(reqs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = evalBatch(reqs[i], eval)
+			}
+		}()
+	}
+	for i := range reqs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	sum := batchSummary{Requests: len(results), DurationUS: time.Since(start).Microseconds()}
+	for _, r := range results {
+		switch r.Decision {
+		case "allow":
+			sum.Allow++
+		case "deny":
+			sum.Deny++
+		default:
+			sum.Errors++
+		}
+		sum.MaxGas = max(sum.MaxGas, r.GasUsed)
+	}
+
+	if c.json {
+		err = writeJSON(c, struct {
+			Results []batchResult `json:"results"`
+			Summary batchSummary  `json:"summary"`
+		}{results, sum})
+	} else {
+		err = writeBatchTable(c, results, sum)
+	}
+	switch {
+	case err != nil:
+		return err
+	case sum.Errors > 0:
+		fmt.Fprintf(c.stderr, "agent-safe verify: %d of %d requests could not be read\n", sum.Errors, sum.Requests)
+		return errReported
+	case sum.Deny > 0:
+		return errDenied
+	}
+	return nil
+}
+
+func evalBatch(r batchRequest, eval func(map[string]any) decision) batchResult {
+	if r.err != nil {
+		return batchResult{Request: r.name, Decision: "error", Reason: r.err.Error()}
+	}
+	d := eval(r.req)
+	res := batchResult{
+		Request:    r.name,
+		Decision:   "allow",
+		Reason:     d.Reason,
+		GasUsed:    d.GasUsed,
+		DurationUS: d.Duration.Microseconds(),
+	}
+	if !d.Allow {
+		res.Decision = "deny"
+	}
+	return res
+}
+
+func writeBatchTable(c *cli, results []batchResult, sum batchSummary) error {
+	tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REQUEST\tDECISION\tGAS\tTIME\tREASON")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", r.Request, strings.ToUpper(r.Decision), r.GasUsed,
+			time.Duration(r.DurationUS)*time.Microsecond, r.Reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(c.stdout, "\n%d requests: %d allow, %d deny, %d errors; max gas %d; %s\n",
+		sum.Requests, sum.Allow, sum.Deny, sum.Errors, sum.MaxGas,
+		time.Duration(sum.DurationUS)*time.Microsecond)
+	return err
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyBatch(t *testing.T) {
+	dir := t.TempDir()
+	policy := write(t, dir, "policy.spl", `(<= (get req "amount") 100)`)
+	reqs := filepath.Join(dir, "reqs")
+	if err := os.Mkdir(reqs, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	write(t, reqs, "a.json", `{"amount": 50}`)
+	write(t, reqs, "b.json", `{"amount": 500}`)
+	write(t, reqs, "c.json", `{"amount": 100}`)
+	write(t, reqs, "notes.txt", `ignored`)
+
+	code, out, errOut := agentSafe(t, "verify", "--policy", policy, "--requests", reqs, "--parallel", "2")
+	if code != exitDeny {
+		t.Fatalf("expected exit 1 with a denied request, got %d: %s", code, errOut)
+	}
+	lines := strings.Split(out, "\n")
+	if !strings.HasPrefix(lines[1], "a.json") || !strings.Contains(lines[2], "DENY") || !strings.HasPrefix(lines[3], "c.json") {
+		t.Fatalf("expected results in file order:\n%s", out)
+	}
+	if !strings.Contains(out, "3 requests: 2 allow, 1 deny, 0 errors") {
+		t.Fatalf("missing summary:\n%s", out)
+	}
+
+	jsonl := write(t, dir, "reqs.jsonl", "{\"amount\": 1}\n\n{\"amount\": 2}\nnot json\n")
+	code, out, _ = agentSafe(t, "verify", "--output", "json", "--requests", jsonl, policy)
+	if code != exitError {
+		t.Fatalf("expected exit 2 with an unreadable request, got %d", code)
+	}
+	var r struct {
+		Results []batchResult `json:"results"`
+		Summary batchSummary  `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(out), &r); err != nil {
+		t.Fatalf("%v in %q", err, out)
+	}
+	if r.Summary.Allow != 2 || r.Summary.Errors != 1 || r.Results[1].Request != "reqs.jsonl:3" || r.Results[2].Decision != "error" {
+		t.Fatalf("unexpected batch result %+v", r)
+	}
+
+	if code, _, _ := agentSafe(t, "verify", "--requests", reqs, policy, "extra.json"); code != exitError {
+		t.Fatalf("expected a usage error for a request argument in batch mode, got %d", code)
+	}
+}
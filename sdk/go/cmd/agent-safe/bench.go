@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// benchStats summarizes the latencies of one benchmarked stage.
+type benchStats struct {
+	Stage     string  `json:"stage"`
+	Ops       int     `json:"ops"`
+	OpsPerSec float64 `json:"ops_per_sec"`
+	P50       int64   `json:"p50_ns"`
+	P90       int64   `json:"p90_ns"`
+	P99       int64   `json:"p99_ns"`
+	Max       int64   `json:"max_ns"`
+}
+
+// benchStage runs op repeatedly for d and times every call.
+func benchStage(name string, d time.Duration, op func() error) (benchStats, error) {
+	var lat []time.Duration
+	start := time.Now()
+	deadline := start.Add(d)
+	for len(lat) == 0 || time.Now().Before(deadline) {
+		t := time.Now()
+		if err := op(); err != nil {
+			return benchStats{}, fmt.Errorf("%s: %w", name, err)
+		}
+		lat = append(lat, time.Since(t))
+	}
+	elapsed := time.Since(start)
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	pct := func(p float64) int64 { return int64(lat[int(p*float64(len(lat)-1))]) }
+	return benchStats{
+		Stage:     name,
+		Ops:       len(lat),
+		OpsPerSec: float64(len(lat)) / elapsed.Seconds(),
+		P50:       pct(0.50),
+		P90:       pct(0.90),
+		P99:       pct(0.99),
+		Max:       int64(lat[len(lat)-1]),
+	}, nil
+}
+
+func cmdBench(c *cli, args []string) error {
+	fs := c.flags("bench")
+	policyPath := fs.String("policy", "", "SPL policy file, or - for stdin")
+	reqPath := fs.String("request", "", "request JSON file, or - for stdin")
+	tokenPath := fs.String("token", "", "token to verify (default: a token for the policy minted with a throwaway key)")
+	duration := fs.Duration("duration", 10*time.Second, "total run time, split evenly between stages")
+	f := addEvalFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *policyPath == "" || *reqPath == "" || fs.NArg() != 0 || *duration <= 0 {
+		return errUsage
+	}
+	src, err := c.readInput(*policyPath)
+	if err != nil {
+		return err
+	}
+	policy := string(src)
+	var req map[string]any
+	if err := c.readJSON(*reqPath, &req); err != nil {
+		return err
+	}
+	env, err := f.load()
+	if err != nil {
+		return err
+	}
+	vars := map[string]any{}
+	for k, v := range env.vars {
+		vars[k] = v
+	}
+	if env.now != "" {
+		vars["now"] = env.now
+	}
+	ast, err := spl.Parse(policy)
+	if err != nil {
+		return fmt.Errorf("policy: %w", err)
+	}
+	var tok *spl.Token
+	if *tokenPath != "" {
+		if tok, err = c.loadToken(*tokenPath); err != nil {
+			return err
+		}
+	} else {
+		_, priv := spl.GenerateKeypair()
+		if tok, err = spl.Mint(policy, priv, spl.MintOptions{}); err != nil {
+			return err
+		}
+	}
+	spEnv := spl.Env{Req: req, Vars: vars, PerDayCount: env.perDayCount, Crypto: env.crypto}
+	allow, gas, evalErr := spl.VerifyWithGas(ast, spEnv)
+
+	// SPL has no compile step: policies are evaluated straight from the
+	// parsed AST, so parse is the whole per-policy preparation cost.
+	stages := []struct {
+		name string
+		op   func() error
+	}{
+		{"parse", func() error { _, err := spl.Parse(policy); return err }},
+		{"eval", func() error { spl.VerifyWithGas(ast, spEnv); return nil }},
+		{"signature", func() error {
+			payload := tok.Payload()
+			spl.VerifySignature(tok.Alg, payload, tok.Signature, tok.PublicKey)
+			return nil
+		}},
+		{"verify-token", func() error { env.verifyToken(tok, req, ""); return nil }},
+	}
+	if tok.Alg == spl.AlgHS256 {
+		// HMAC tokens need the shared secret, which bench does not take.
+		stages = stages[:2]
+	}
+	per := *duration / time.Duration(len(stages))
+	results := []benchStats{}
+	for _, s := range stages {
+		r, err := benchStage(s.name, per, s.op)
+		if err != nil {
+			return err
+		}
+		results = append(results, r)
+	}
+
+	if c.json {
+		return writeJSON(c, results)
+	}
+	verdict := "DENY"
+	if allow {
+		verdict = "ALLOW"
+	}
+	if evalErr != nil {
+		verdict += ": " + evalErr.Error()
+	}
+	fmt.Fprintf(c.stdout, "decision %s, gas %d; signature %s\n\n", verdict, gas, signatureStatus(tok))
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tOPS\tOPS/S\tP50\tP90\tP99\tMAX")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%v\t%v\t%v\t%v\n", r.Stage, r.Ops, r.OpsPerSec,
+			time.Duration(r.P50), time.Duration(r.P90), time.Duration(r.P99), time.Duration(r.Max))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBench(t *testing.T) {
+	dir := t.TempDir()
+	policy := write(t, dir, "limit.spl", `(<= (get req "amount") 100)`)
+	req := write(t, dir, "req.json", `{"amount": 5}`)
+	out := mustRun(t, "bench", "--policy", policy, "--request", req, "--duration", "40ms")
+	if !strings.HasPrefix(out, "decision ALLOW, gas ") || !strings.Contains(out, "signature valid") {
+		t.Fatalf("unexpected header %q", out)
+	}
+	for _, stage := range []string{"parse", "eval", "signature", "verify-token"} {
+		if !strings.Contains(out, "\n"+stage+" ") {
+			t.Errorf("missing stage %s in %q", stage, out)
+		}
+	}
+
+	var stats []benchStats
+	if err := json.Unmarshal([]byte(mustRun(t, "bench", "--output", "json", "--policy", policy, "--request", req, "--duration", "20ms")), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 4 {
+		t.Fatalf("expected 4 stages, got %+v", stats)
+	}
+	for _, s := range stats {
+		if s.Ops < 1 || s.P50 > s.P99 || s.P99 > s.Max {
+			t.Errorf("inconsistent stats %+v", s)
+		}
+	}
+
+	if code, _, _ := agentSafe(t, "bench", "--policy", policy); code != exitError {
+		t.Fatalf("expected a usage error without --request, got %d", code)
+	}
+}
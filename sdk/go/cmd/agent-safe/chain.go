@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func cmdDelegate(c *cli, args []string) error {
+	fs := c.flags("delegate")
+	parentPath := fs.String("parent", "", "token to delegate from, or - for stdin")
+	policyPath := fs.String("policy", "", "SPL policy for the child token, or - for stdin")
+	keyPath := fs.String("key", "", "issuer private key file (not needed for HMAC tokens)")
+	out := fs.String("out", "", "write the child token here instead of stdout")
+	varsPath := fs.String("vars", "", "YAML or JSON config whose vars are substituted for the subsumption check")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *parentPath == "" || *policyPath == "" || fs.NArg() != 0 {
+		return errUsage
+	}
+	parent, err := c.loadToken(*parentPath)
+	if err != nil {
+		return err
+	}
+	src, err := c.readInput(*policyPath)
+	if err != nil {
+		return err
+	}
+	policy := strings.TrimSpace(string(src))
+	narrow, err := spl.Parse(policy)
+	if err != nil {
+		return fmt.Errorf("policy: %w", err)
+	}
+	broad, err := spl.Parse(parent.Policy)
+	if err != nil {
+		return fmt.Errorf("parent policy: %w", err)
+	}
+	vars, err := loadVars(*varsPath)
+	if err != nil {
+		return err
+	}
+	// The child is always limited by the parent, but a policy that is not
+	// provably narrower would not mean what it says; use attenuate to add
+	// a constraint regardless.
+	if !spl.Subsumes(broad, narrow, vars) {
+		return errors.New("policy is not provably narrower than the parent's (see agent-safe diff --prove-narrower)")
+	}
+	var priv string
+	if parent.Alg != spl.AlgHS256 {
+		if *keyPath == "" {
+			return errors.New("--key is required to sign the child token")
+		}
+		if _, priv, err = loadPrivateKey(*keyPath); err != nil {
+			return err
+		}
+	}
+	child, err := spl.Attenuate(parent, policy, priv)
+	if err != nil {
+		return err
+	}
+	if err := spl.VerifyDelegation(parent, child, vars); err != nil {
+		return err
+	}
+	if *out == "" {
+		return writeJSON(c, child)
+	}
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, child); err != nil {
+		return err
+	}
+	return os.WriteFile(*out, buf.Bytes(), 0o644)
+}
+
+// loadVars returns the vars of a config file, or nil for an empty path.
+func loadVars(path string) (map[string]any, error) {
+	if path == "" {
+		return nil, nil
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Vars, nil
+}
+
+// chainLink is the verify-chain result for one token.
+type chainLink struct {
+	Token     string `json:"token"`
+	Signature string `json:"signature"`
+	Error     string `json:"error,omitempty"`
+}
+
+func cmdVerifyChain(c *cli, args []string) error {
+	fs := c.flags("verify-chain")
+	reqPath := fs.String("request", "", "request JSON file to evaluate against the last token, or - for stdin")
+	f := addEvalFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+	env, err := f.load()
+	if err != nil {
+		return err
+	}
+	var toks []*spl.Token
+	for _, path := range fs.Args() {
+		tok, err := c.loadToken(path)
+		if err != nil {
+			return err
+		}
+		toks = append(toks, tok)
+	}
+
+	// Without --vars, symbols stay opaque to the subsumption check.
+	var vars map[string]any
+	if *f.vars != "" {
+		vars = env.vars
+	}
+	links := make([]chainLink, len(toks))
+	valid := true
+	for i, tok := range toks {
+		l := &links[i]
+		l.Token, l.Signature = inputName(fs.Arg(i)), signatureStatus(tok)
+		switch {
+		case l.Signature == "invalid":
+			l.Error = "invalid signature"
+		case i > 0:
+			if err := spl.VerifyDelegation(toks[i-1], tok, vars); err != nil {
+				l.Error = err.Error()
+			}
+		}
+		valid = valid && l.Error == ""
+	}
+
+	var d *decision
+	if valid && *reqPath != "" {
+		var req map[string]any
+		if err := c.readJSON(*reqPath, &req); err != nil {
+			return err
+		}
+		v := env.verifyToken(toks[len(toks)-1], req, "")
+		d = &v
+	}
+	if c.json {
+		r := struct {
+			Links    []chainLink     `json:"links"`
+			Valid    bool            `json:"valid"`
+			Decision *decisionReport `json:"decision,omitempty"`
+		}{Links: links, Valid: valid}
+		if d != nil {
+			rep := d.report()
+			r.Decision = &rep
+		}
+		if err := writeJSON(c, r); err != nil {
+			return err
+		}
+	} else {
+		for i, l := range links {
+			status := "ok"
+			if l.Error != "" {
+				status = l.Error
+			}
+			fmt.Fprintf(c.stdout, "%d %s: signature %s; %s\n", i+1, l.Token, l.Signature, status)
+		}
+		if !valid {
+			fmt.Fprintln(c.stdout, "CHAIN INVALID")
+		} else if d == nil {
+			fmt.Fprintln(c.stdout, "CHAIN OK")
+		}
+	}
+	switch {
+	case !valid || d != nil && c.json && !d.Allow:
+		return errDenied
+	case d != nil && !c.json:
+		return c.decide(*d)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDelegateAndVerifyChain(t *testing.T) {
+	dir := t.TempDir()
+	key := write(t, dir, "key.json", mustRun(t, "keygen"))
+	root := write(t, dir, "root.json", mustRun(t, "mint", "--key", key, "--expires", "24h",
+		"--policy", write(t, dir, "root.spl", `(and (= (get req "action") "pay") (<= (get req "amount") 100))`)))
+	narrow := write(t, dir, "narrow.spl", `(and (= (get req "action") "pay") (<= (get req "amount") 25))`)
+
+	child := filepath.Join(dir, "child.json")
+	mustRun(t, "delegate", "--parent", root, "--policy", narrow, "--key", key, "--out", child)
+	grandchild := write(t, dir, "grandchild.json", mustRun(t, "delegate", "--parent", child, "--key", key,
+		"--policy", write(t, dir, "narrower.spl", `(and (= (get req "action") "pay") (<= (get req "amount") 10))`)))
+
+	if out := mustRun(t, "verify-chain", root, child, grandchild); !strings.HasSuffix(out, "CHAIN OK\n") {
+		t.Fatalf("verify-chain: %s", out)
+	}
+	allow := write(t, dir, "allow.json", `{"action": "pay", "amount": 5}`)
+	deny := write(t, dir, "deny.json", `{"action": "pay", "amount": 20}`)
+	if out := mustRun(t, "verify-chain", "--request", allow, root, child, grandchild); !strings.HasSuffix(out, "ALLOW\n") {
+		t.Fatalf("chain allow: %s", out)
+	}
+	if code, out, _ := agentSafe(t, "verify-chain", "--request", deny, root, child, grandchild); code != exitDeny || !strings.HasSuffix(out, "DENY\n") {
+		t.Fatalf("chain deny: exit %d: %s", code, out)
+	}
+
+	// Out of order, the second link widens the first.
+	code, out, _ := agentSafe(t, "verify-chain", child, root)
+	if code != exitDeny || !strings.Contains(out, "not provably narrower") || !strings.Contains(out, "CHAIN INVALID") {
+		t.Fatalf("reversed chain: exit %d: %s", code, out)
+	}
+
+	wider := write(t, dir, "wider.spl", `(<= (get req "amount") 500)`)
+	code, _, errOut := agentSafe(t, "delegate", "--parent", root, "--policy", wider, "--key", key)
+	if code != exitError || !strings.Contains(errOut, "not provably narrower") {
+		t.Fatalf("wider delegation: exit %d: %s", code, errOut)
+	}
+}
@@ -0,0 +1,743 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+	"github.com/jmcentire/agent-safe/sdk/go/splhttp"
+)
+
+// keyFile is the default keygen output and the simplest key file format.
+type keyFile struct {
+	Alg        string `json:"alg"`
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key,omitempty"`
+}
+
+func cmdKeygen(c *cli, args []string) error {
+	fs := c.flags("keygen")
+	alg := fs.String("alg", spl.AlgEd25519, "key algorithm: Ed25519, ES256, or X25519 (for receiving encrypted tokens)")
+	format := fs.String("format", "hex", "output format: hex, jwk, or pem")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	var pub, priv string
+	var err error
+	if *alg == "X25519" {
+		if *format != "hex" {
+			return errors.New("X25519 keys are only available in hex format")
+		}
+		pub, priv, err = spl.GenerateX25519Keypair()
+	} else {
+		pub, priv, err = spl.GenerateKeypairAlg(*alg)
+	}
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "hex":
+		return writeJSON(c, keyFile{Alg: *alg, PublicKey: pub, PrivateKey: priv})
+	case "jwk":
+		j, err := spl.KeyToJWK(*alg, pub, priv)
+		if err != nil {
+			return err
+		}
+		return writeJSON(c, j)
+	case "pem":
+		privPEM, err := spl.PrivateKeyToPEM(*alg, priv)
+		if err != nil {
+			return err
+		}
+		pubPEM, err := spl.PublicKeyToPEM(*alg, pub)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(c.stdout, privPEM+pubPEM)
+		return err
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func cmdMint(c *cli, args []string) error {
+	fs := c.flags("mint")
+	policyPath := fs.String("policy", "", "SPL policy file, or - for stdin")
+	keyPath := fs.String("key", "", "issuer private key file (keygen output, JWK, PEM, or Ed25519 hex)")
+	expires := fs.String("expires", "", "expiry: RFC 3339 time, or a duration from now such as 90m, 24h or 7d")
+	sealed := fs.Bool("sealed", false, "seal the token against attenuation")
+	fs.BoolVar(sealed, "seal", false, "alias for --sealed")
+	popKey := fs.String("pop-key", "", "bind the token to an agent's Ed25519 public key (hex or DID) or SPIFFE ID")
+	merkleRoot := fs.String("merkle-root", "", "Merkle root for merkle_ok? and merkle-member?; see merkle-allowlist")
+	hashChain := fs.String("hash-chain-commitment", "", "hash-chain commitment for receipts")
+	format := fs.String("format", "json", "token encoding: json, or compact (one base64url line)")
+	actionPaths := map[string]string{}
+	fs.Func("action", "NAME=FILE: the policy for requests whose action is NAME, in place of --policy (repeatable)", func(v string) error {
+		name, path, ok := strings.Cut(v, "=")
+		if !ok || name == "" || path == "" {
+			return fmt.Errorf("want NAME=FILE, got %q", v)
+		}
+		if _, dup := actionPaths[name]; dup {
+			return fmt.Errorf("action %q given twice", name)
+		}
+		actionPaths[name] = path
+		return nil
+	})
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *policyPath == "" || *keyPath == "" || fs.NArg() != 0 {
+		return errUsage
+	}
+	if *format != "json" && *format != "compact" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	policy, err := c.readInput(*policyPath)
+	if err != nil {
+		return err
+	}
+	var actions map[string]string
+	for name, path := range actionPaths {
+		src, err := c.readInput(path)
+		if err != nil {
+			return err
+		}
+		if actions == nil {
+			actions = map[string]string{}
+		}
+		actions[name] = strings.TrimSpace(string(src))
+	}
+	// Lint before signing: a token cannot be fixed once issued. Warnings are
+	// shown; errors stop the mint.
+	lintErrors := 0
+	lint := func(path, src string) {
+		for _, d := range spl.Lint(src) {
+			if d.Severity < spl.SeverityWarning {
+				continue
+			}
+			fmt.Fprintf(c.stderr, "%s:%s\n", inputName(path), d)
+			if d.Severity >= spl.SeverityError {
+				lintErrors++
+			}
+		}
+	}
+	lint(*policyPath, string(policy))
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lint(actionPaths[name], actions[name])
+	}
+	if lintErrors > 0 {
+		return fmt.Errorf("policy: %d lint error(s); not minting", lintErrors)
+	}
+	exp, err := parseExpiry(*expires, time.Now())
+	if err != nil {
+		return fmt.Errorf("--expires: %w", err)
+	}
+	alg, priv, err := loadPrivateKey(*keyPath)
+	if err != nil {
+		return err
+	}
+	tok, err := spl.Mint(strings.TrimSpace(string(policy)), priv, spl.MintOptions{
+		MerkleRoot:          *merkleRoot,
+		HashChainCommitment: *hashChain,
+		Sealed:              *sealed,
+		Expires:             exp,
+		PoPKey:              *popKey,
+		Alg:                 alg,
+		Actions:             actions,
+	})
+	if err != nil {
+		return err
+	}
+	if *format == "compact" {
+		s, err := tok.Compact()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(c.stdout, s)
+		return err
+	}
+	return writeJSON(c, tok)
+}
+
+// parseExpiry turns an --expires value into RFC 3339: absolute times pass
+// through, and durations (Go syntax, plus a d suffix for days) are added to
+// now. An empty value means no expiry.
+func parseExpiry(s string, now time.Time) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	if _, err := time.Parse(time.RFC3339, s); err == nil {
+		return s, nil
+	}
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return "", fmt.Errorf("invalid time or duration %q", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return "", fmt.Errorf("invalid time or duration %q", s)
+		}
+	}
+	if d <= 0 {
+		return "", fmt.Errorf("duration %q must be positive", s)
+	}
+	return now.Add(d).UTC().Format(time.RFC3339), nil
+}
+
+func cmdVerify(c *cli, args []string) error {
+	fs := c.flags("verify")
+	policyPath := fs.String("policy", "", "policy file (or the first argument)")
+	requests := fs.String("requests", "", "directory of JSON requests or a JSONL file (- for stdin), for batch verification")
+	parallel := fs.Int("parallel", runtime.GOMAXPROCS(0), "concurrent evaluations in batch mode")
+	explain := fs.Bool("explain", false, "print the evaluation tree, marking the clause that denied")
+	watch := fs.Bool("watch", false, "re-run whenever the policy, request or --vars file changes")
+	f := addEvalFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	rest := fs.Args()
+	if *policyPath == "" && len(rest) > 0 {
+		*policyPath, rest = rest[0], rest[1:]
+	}
+	wantArgs := 1
+	if *requests != "" {
+		wantArgs = 0
+	}
+	if *policyPath == "" || len(rest) != wantArgs || *parallel < 1 || *explain && *requests != "" {
+		return errUsage
+	}
+	if *watch {
+		if *requests != "" || *policyPath == "-" || rest[0] == "-" {
+			return errUsage
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return c.watchVerify(ctx, *policyPath, rest[0], f, *explain)
+	}
+	eval, err := c.policyEvaluator(*policyPath, f, *explain)
+	if err != nil {
+		return err
+	}
+	if *requests != "" {
+		return c.verifyBatch(*requests, *parallel, eval)
+	}
+	var req map[string]any
+	if err := c.readJSON(rest[0], &req); err != nil {
+		return err
+	}
+	return c.decide(eval(req))
+}
+
+// policyEvaluator loads a policy and the verifier config and returns a
+// function that evaluates requests against them.
+func (c *cli) policyEvaluator(policyPath string, f *evalFlags, explain bool) (func(map[string]any) decision, error) {
+	policy, err := c.readInput(policyPath)
+	if err != nil {
+		return nil, err
+	}
+	env, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	if env.now != "" {
+		env.vars["now"] = env.now
+	}
+	ast, err := spl.Parse(string(policy))
+	if err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	return func(req map[string]any) decision {
+		start := time.Now()
+		spEnv := spl.Env{
+			Req:          req,
+			Vars:         env.vars,
+			RequiredVars: env.required,
+			PerDayCount:  env.perDayCount,
+			Crypto:       env.crypto,
+		}
+		var d decision
+		var err error
+		if explain {
+			var ex *spl.Explanation
+			ex, err = spl.Explain(ast, spEnv)
+			d = decision{Allow: ex.Allow, GasUsed: ex.GasUsed, Trace: ex.Root}
+		} else {
+			d.Allow, d.GasUsed, err = spl.VerifyWithGas(ast, spEnv)
+		}
+		d.Duration = time.Since(start)
+		if err != nil {
+			// Evaluation errors fail closed, as in token verification.
+			d.Reason = err.Error()
+		}
+		return d
+	}, nil
+}
+
+func cmdVerifyToken(c *cli, args []string) error {
+	fs := c.flags("verify-token")
+	tokenPath := fs.String("token", "", "token file, or - for stdin")
+	reqPath := fs.String("request", "", "request JSON file, or - for stdin")
+	httpPath := fs.String("http-request", "", "raw HTTP/1.1 request file, or - for stdin, described to the policy as the HTTP middleware does")
+	popSig := fs.String("presentation-signature", "", "agent's PoP presentation signature (hex)")
+	explain := fs.Bool("explain", false, "print the policy's evaluation tree, marking the clause that denied")
+	witnessPath := fs.String("witnesses", "", "merkle-allowlist output, or a part of it, holding the witnesses the agent presents for merkle-member?")
+	f := addEvalFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *tokenPath == "" || (*reqPath == "") == (*httpPath == "") {
+		return errUsage
+	}
+	var witnesses spl.MerkleAllowlist
+	if *witnessPath != "" {
+		if err := c.readJSON(*witnessPath, &witnesses); err != nil {
+			return err
+		}
+	}
+	tok, err := c.loadToken(*tokenPath)
+	if err != nil {
+		return err
+	}
+	var req map[string]any
+	if *httpPath != "" {
+		req, err = c.readHTTPRequest(*httpPath)
+	} else {
+		err = c.readJSON(*reqPath, &req)
+	}
+	if err != nil {
+		return err
+	}
+	env, err := f.load()
+	if err != nil {
+		return err
+	}
+	env.explain = *explain
+	env.witnesses = witnesses.Witnesses
+	return c.decide(env.verifyToken(tok, req, *popSig))
+}
+
+func cmdAttenuate(c *cli, args []string) error {
+	fs := c.flags("attenuate")
+	tokenPath := fs.String("token", "", "token file, or - for stdin")
+	keyPath := fs.String("key", "", "issuer private key file (not needed for HMAC tokens)")
+	constraint := fs.String("constraint", "", "SPL expression the narrowed token must also satisfy")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *tokenPath == "" || *constraint == "" {
+		return errUsage
+	}
+	tok, err := c.loadToken(*tokenPath)
+	if err != nil {
+		return err
+	}
+	var priv string
+	if tok.Alg != spl.AlgHS256 {
+		if *keyPath == "" {
+			return errors.New("--key is required to re-sign the token")
+		}
+		if _, priv, err = loadPrivateKey(*keyPath); err != nil {
+			return err
+		}
+	}
+	out, err := spl.Attenuate(tok, *constraint, priv)
+	if err != nil {
+		return err
+	}
+	return writeJSON(c, out)
+}
+
+func cmdSeal(c *cli, args []string) error {
+	fs := c.flags("seal")
+	tokenPath := fs.String("token", "", "token file, or - for stdin")
+	keyPath := fs.String("key", "", "issuer private key file")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *tokenPath == "" || *keyPath == "" {
+		return errUsage
+	}
+	tok, err := c.loadToken(*tokenPath)
+	if err != nil {
+		return err
+	}
+	_, priv, err := loadPrivateKey(*keyPath)
+	if err != nil {
+		return err
+	}
+	out, err := spl.Seal(tok, priv)
+	if err != nil {
+		return err
+	}
+	return writeJSON(c, out)
+}
+
+func cmdInspect(c *cli, args []string) error {
+	fs := c.flags("inspect")
+	tokenPath := fs.String("token", "", "token file, or - for stdin")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *tokenPath == "" && fs.NArg() == 1 {
+		*tokenPath = fs.Arg(0)
+	}
+	if *tokenPath == "" {
+		return errUsage
+	}
+	data, err := c.readInput(*tokenPath)
+	if err != nil {
+		return err
+	}
+	tok, err := spl.ParseToken(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", inputName(*tokenPath), err)
+	}
+	// ParseToken drops fields it does not know; check the token as sent.
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' {
+		data, _ = base64.RawURLEncoding.DecodeString(string(data))
+	}
+	problems := spl.ValidateTokenJSON(data)
+	if c.json {
+		return writeJSON(c, struct {
+			Token     *spl.Token          `json:"token"`
+			Signature string              `json:"signature"`
+			Schema    []spl.SchemaProblem `json:"schema,omitempty"`
+		}{tok, signatureStatus(tok), problems})
+	}
+	alg := tok.Alg
+	if alg == "" {
+		alg = spl.AlgEd25519
+	}
+	w := c.stdout
+	fmt.Fprintf(w, "version:    %s\n", tok.Version)
+	fmt.Fprintf(w, "alg:        %s\n", alg)
+	if tok.KeyID != "" {
+		fmt.Fprintf(w, "kid:        %s\n", tok.KeyID)
+	}
+	fmt.Fprintf(w, "issuer:     %s\n", orNone(tok.PublicKey))
+	fmt.Fprintf(w, "sealed:     %v\n", tok.Sealed)
+	fmt.Fprintf(w, "expires:    %s\n", orNone(tok.Expires))
+	fmt.Fprintf(w, "pop key:    %s\n", orNone(tok.PoPKey))
+	if tok.MerkleRoot != "" {
+		fmt.Fprintf(w, "merkle:     %s\n", tok.MerkleRoot)
+	}
+	if tok.HashChainCommitment != "" {
+		fmt.Fprintf(w, "hash chain: %s\n", tok.HashChainCommitment)
+	}
+	if tok.PQSignature != "" {
+		fmt.Fprintln(w, "pq:         ML-DSA-65")
+	}
+	if len(tok.Caveats) > 0 {
+		fmt.Fprintf(w, "caveats:    %d\n", len(tok.Caveats))
+	}
+	fmt.Fprintf(w, "signature:  %s\n", signatureStatus(tok))
+	if len(problems) == 0 {
+		fmt.Fprintln(w, "schema:     ok")
+	} else {
+		fmt.Fprintln(w, "schema:     invalid")
+		for _, p := range problems {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+	fmt.Fprintf(w, "policy:\n%s\n", indent(tok.Policy, "  "))
+	names := make([]string, 0, len(tok.Actions))
+	for name := range tok.Actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "action %s:\n%s\n", name, indent(tok.Actions[name], "  "))
+	}
+	return nil
+}
+
+// signatureStatus checks the classical signature without evaluating the
+// policy. HMAC tokens cannot be checked without the shared secret.
+func signatureStatus(t *spl.Token) string {
+	if t.Alg == spl.AlgHS256 {
+		return "unchecked (HMAC)"
+	}
+	payload := t.Payload()
+	key := t.PublicKey
+	if strings.HasPrefix(key, "did:key:") {
+		if _, k, err := spl.DIDKeyToPublicKey(key); err == nil {
+			key = k
+		}
+	}
+	if spl.VerifySignature(t.Alg, payload, t.Signature, key) {
+		return "valid"
+	}
+	return "invalid"
+}
+
+// decision is the outcome of a verify command.
+type decision struct {
+	Allow    bool
+	Reason   string
+	Sealed   bool
+	GasUsed  int
+	Duration time.Duration
+	Trace    *spl.Trace // with --explain
+}
+
+// decisionReport is the JSON form of a decision, shared by --output json
+// and serve.
+type decisionReport struct {
+	Decision   string     `json:"decision"` // allow or deny
+	Reason     string     `json:"reason,omitempty"`
+	Sealed     bool       `json:"sealed,omitempty"`
+	GasUsed    int        `json:"gas_used"`
+	DurationUS int64      `json:"duration_us"`
+	Trace      *spl.Trace `json:"trace,omitempty"`
+}
+
+func (d decision) report() decisionReport {
+	r := decisionReport{"allow", d.Reason, d.Sealed, d.GasUsed, d.Duration.Microseconds(), d.Trace}
+	if !d.Allow {
+		r.Decision = "deny"
+	}
+	return r
+}
+
+// decide prints d and returns errDenied for a deny so the process exits 1.
+func (c *cli) decide(d decision) error {
+	r := d.report()
+	if c.json {
+		if err := writeJSON(c, r); err != nil {
+			return err
+		}
+	} else if d.Reason != "" {
+		fmt.Fprintf(c.stdout, "%s: %s\n", strings.ToUpper(r.Decision), d.Reason)
+	} else {
+		fmt.Fprintln(c.stdout, strings.ToUpper(r.Decision))
+	}
+	if d.Trace != nil && !c.json {
+		printTrace(c.stdout, d.Trace, 0)
+	}
+	if !d.Allow {
+		return errDenied
+	}
+	return nil
+}
+
+// printTrace prints an evaluation tree one expression per line, with a >
+// in the margin along the path to the clause that denied. Long
+// expressions are shortened; --output json has them in full.
+func printTrace(w io.Writer, t *spl.Trace, depth int) {
+	mark, note := " ", ""
+	if t.Failing {
+		mark = ">"
+		if len(t.Children) == 0 || !t.Children[len(t.Children)-1].Failing {
+			note = "   <- denied here"
+		}
+	}
+	result := "error: " + t.Error
+	if t.Error == "" {
+		v, _ := json.Marshal(t.Value)
+		result = string(v)
+	}
+	expr := t.Expr
+	if r := []rune(expr); len(r) > 60 {
+		expr = string(r[:57]) + "..."
+	}
+	fmt.Fprintf(w, "%s %s%s => %s%s\n", mark, strings.Repeat("  ", depth), expr, result, note)
+	for _, child := range t.Children {
+		printTrace(w, child, depth+1)
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, l := range lines {
+		lines[i] = prefix + l
+	}
+	return strings.Join(lines, "\n")
+}
+
+func readFile(path string) ([]byte, error) {
+	return os.ReadFile(filepath.Clean(path))
+}
+
+// readInput reads a policy, request or token argument, where "-" means
+// standard input. Only one argument of a command can be "-".
+func (c *cli) readInput(path string) ([]byte, error) {
+	if path != "-" {
+		return readFile(path)
+	}
+	if c.stdinUsed {
+		return nil, errors.New("only one argument can read standard input (-)")
+	}
+	c.stdinUsed = true
+	return io.ReadAll(c.stdin)
+}
+
+// inputName names an argument in messages.
+func inputName(path string) string {
+	if path == "-" {
+		return "stdin"
+	}
+	return path
+}
+
+func (c *cli) readJSON(path string, v any) error {
+	data, err := c.readInput(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", inputName(path), err)
+	}
+	return nil
+}
+
+// readHTTPRequest reads a raw HTTP/1.1 request and describes it as
+// splhttp.RequestToMap does for the middleware.
+func (c *cli) readHTTPRequest(path string) (map[string]any, error) {
+	data, err := c.readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", inputName(path), err)
+	}
+	req, err := splhttp.RequestToMap(r, splhttp.MapOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", inputName(path), err)
+	}
+	return req, nil
+}
+
+func writeJSON(c *cli, v any) error {
+	return encodeJSON(c.stdout, v)
+}
+
+func encodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// loadToken reads a token as JSON or in the compact form mint --format
+// compact writes.
+func (c *cli) loadToken(path string) (*spl.Token, error) {
+	data, err := c.readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := spl.ParseToken(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", inputName(path), err)
+	}
+	return t, nil
+}
+
+// loadPrivateKey reads an issuer key from keygen output, a JWK, a PEM
+// private key, or a bare Ed25519 seed in hex.
+func loadPrivateKey(path string) (alg, privateKeyHex string, err error) {
+	alg, _, privateKeyHex, err = loadKey(path)
+	if err != nil {
+		return "", "", err
+	}
+	if privateKeyHex == "" {
+		return "", "", fmt.Errorf("%s: no private key", path)
+	}
+	return alg, privateKeyHex, nil
+}
+
+// loadKey reads a public or private key in any format loadPrivateKey
+// accepts, deriving the public key from a private one when needed. A path
+// of keystore:NAME reads the key store entry NAME instead (see openKeyStore).
+func loadKey(path string) (alg, publicKeyHex, privateKeyHex string, err error) {
+	if name, ok := strings.CutPrefix(path, "keystore:"); ok {
+		ks, err := openKeyStore("", "")
+		if err != nil {
+			return "", "", "", err
+		}
+		k, ok := ks.Get(name)
+		if !ok || k.Kind != spl.KeyKindKey {
+			return "", "", "", fmt.Errorf("key store: no key named %q", name)
+		}
+		return k.Alg, k.PublicKey, k.PrivateKey, nil
+	}
+	data, err := readFile(path)
+	if err != nil {
+		return "", "", "", err
+	}
+	return parseKey(data, path)
+}
+
+// parseKey decodes the key formats loadKey accepts; name labels errors.
+func parseKey(data []byte, name string) (alg, publicKeyHex, privateKeyHex string, err error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("-----BEGIN")):
+		alg, publicKeyHex, privateKeyHex, err = spl.KeyFromPEM(data)
+	case bytes.HasPrefix(data, []byte("{")):
+		var probe struct {
+			Kty string `json:"kty"`
+		}
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return "", "", "", fmt.Errorf("%s: %w", name, err)
+		}
+		if probe.Kty != "" {
+			var j spl.JWK
+			if err := json.Unmarshal(data, &j); err != nil {
+				return "", "", "", err
+			}
+			alg, publicKeyHex, privateKeyHex, err = spl.KeyFromJWK(&j)
+			break
+		}
+		var k keyFile
+		if err := json.Unmarshal(data, &k); err != nil {
+			return "", "", "", err
+		}
+		alg, publicKeyHex, privateKeyHex = k.Alg, k.PublicKey, k.PrivateKey
+	default:
+		alg, privateKeyHex = spl.AlgEd25519, string(data)
+		var seed []byte
+		if seed, err = hex.DecodeString(privateKeyHex); err == nil && len(seed) == ed25519.SeedSize {
+			publicKeyHex = hex.EncodeToString(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+		}
+	}
+	if err != nil {
+		return "", "", "", fmt.Errorf("%s: %w", name, err)
+	}
+	return alg, publicKeyHex, privateKeyHex, nil
+}
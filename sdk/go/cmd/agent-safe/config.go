@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/internal/yaml"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+	"github.com/jmcentire/agent-safe/sdk/go/spltest"
+)
+
+// evalFlags are shared by the commands that evaluate policies.
+type evalFlags struct {
+	vars   *string
+	now    *string
+	assume *string
+}
+
+func addEvalFlags(fs *flag.FlagSet) *evalFlags {
+	return &evalFlags{
+		vars:   fs.String("vars", "", "YAML or JSON verifier config: vars, now, counters, crypto"),
+		now:    fs.String("now", "", "evaluation time, RFC 3339 (overrides the config; default: current time)"),
+		assume: fs.String("assume", "", "comma-separated crypto predicates to treat as satisfied: dpop,merkle,vrf,thresh,attested,approval"),
+	}
+}
+
+// evalEnv is everything a policy evaluation needs besides the request.
+type evalEnv struct {
+	vars        map[string]any
+	required    []string // variables a policy may not use unbound
+	now         string
+	perDayCount func(action, day string) int
+	crypto      spl.CryptoCallbacks
+	trust       spl.KeyResolver // nil: any self-signed token is accepted
+	revocations *revocationList
+	explain     bool                             // record the evaluation tree (--explain)
+	witnesses   map[string][]spl.MerkleProofStep // for merkle-member? (--witnesses)
+	// verifier, set by serve, verifies under verifyOptions and keeps the
+	// statistics GET /v1/stats reports.
+	verifier *spl.Verifier
+}
+
+func (f *evalFlags) load() (*evalEnv, error) {
+	cfg := &config{Vars: map[string]any{}, Crypto: map[string]bool{}}
+	if *f.vars != "" {
+		var err error
+		if cfg, err = loadConfig(*f.vars); err != nil {
+			return nil, err
+		}
+	}
+	if *f.now != "" {
+		cfg.Now = *f.now
+	}
+	if cfg.Now != "" {
+		if _, err := time.Parse(time.RFC3339, cfg.Now); err != nil {
+			return nil, fmt.Errorf("now: %w", err)
+		}
+	}
+	if *f.assume != "" {
+		for _, name := range strings.Split(*f.assume, ",") {
+			cfg.Crypto[strings.TrimSpace(name)] = true
+		}
+	}
+	crypto, err := spltest.StubCrypto(cfg.Crypto)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(*f.vars)
+	counts, err := cfg.Counters.perDayCount(dir)
+	if err != nil {
+		return nil, err
+	}
+	trust, err := cfg.Trust.resolver(dir)
+	if err != nil {
+		return nil, err
+	}
+	revocations, err := cfg.Revocation.load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &evalEnv{
+		vars:        cfg.Vars,
+		required:    cfg.RequiredVars,
+		now:         cfg.Now,
+		perDayCount: counts,
+		crypto:      crypto,
+		trust:       trust,
+		revocations: revocations,
+	}, nil
+}
+
+// verifyToken checks a token against the environment, including its trust
+// anchors and revocation list.
+func (env *evalEnv) verifyToken(tok *spl.Token, req map[string]any, presentationSig string) decision {
+	start := time.Now()
+	if env.revocations.revoked(tok) {
+		return decision{Reason: "token revoked", Sealed: tok.Sealed, Duration: time.Since(start)}
+	}
+	var r spl.VerifyTokenResult
+	if env.verifier != nil {
+		r = env.verifier.VerifyPresented(context.Background(), tok, req, presentationSig)
+	} else {
+		opts := env.verifyOptions()
+		opts.PresentationSignature = presentationSig
+		r = spl.VerifyTokenObj(tok, req, opts)
+	}
+	return decision{
+		Allow:    r.Allow,
+		Reason:   r.ErrorMessage(),
+		Sealed:   r.Sealed,
+		GasUsed:  r.GasUsed,
+		Duration: time.Since(start),
+		Trace:    r.Trace,
+	}
+}
+
+// verifyOptions returns the token verification options the environment
+// describes, apart from its revocation list.
+func (env *evalEnv) verifyOptions() spl.VerifyTokenOptions {
+	opts := spl.VerifyTokenOptions{
+		Vars:            env.vars,
+		RequiredVars:    env.required,
+		Now:             env.now,
+		PerDayCount:     env.perDayCount,
+		Crypto:          env.crypto,
+		Explain:         env.explain,
+		MerkleWitnesses: env.witnesses,
+	}
+	if env.trust != nil {
+		opts.KeyResolver = env.trust
+	}
+	return opts
+}
+
+// config is the verifier configuration file:
+//
+//	now: 2025-10-01T00:00:00Z      # optional fixed evaluation time
+//	vars:                          # policy variables
+//	  allowed_recipients: [niece@example.com, mom@example.com]
+//	required_vars:                 # vars an error to use unbound
+//	  - allowed_recipients
+//	counters:                      # backs per-day-count
+//	  backend: static              # static (inline counts) or file
+//	  counts:
+//	    payments.create: {"2025-09-29": 1}
+//	crypto:                        # predicates stubbed to true for testing
+//	  dpop: true
+//	trust: ...                     # issuer keys; see trustConfig
+//	revocation: ...                # revoked tokens and keys; see revocationConfig
+//
+// Top-level keys other than these are treated as variables, so a plain
+// variables file is also a valid config.
+type config struct {
+	Now          string
+	Vars         map[string]any
+	RequiredVars []string
+	Counters     *counterConfig
+	Crypto       map[string]bool
+
+	Trust      *trustConfig
+	Revocation *revocationConfig
+}
+
+type counterConfig struct {
+	Backend string `json:"backend"`
+	// Counts maps action → day → count for the static backend.
+	Counts map[string]map[string]int `json:"counts"`
+	// Path names a YAML or JSON file of counts in the same shape, for the
+	// file backend; relative paths resolve against the config file.
+	Path string `json:"path"`
+}
+
+func loadConfig(path string) (*config, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg := &config{Vars: map[string]any{}, Crypto: map[string]bool{}}
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "now":
+			s, ok := v.(string)
+			if !ok {
+				err = fmt.Errorf("must be an RFC 3339 string")
+			}
+			cfg.Now = s
+		case "vars":
+			err = remarshal(v, &cfg.Vars)
+		case "required_vars":
+			err = remarshal(v, &cfg.RequiredVars)
+		case "counters":
+			err = remarshal(v, &cfg.Counters)
+		case "crypto":
+			err = remarshal(v, &cfg.Crypto)
+		case "trust":
+			err = remarshal(v, &cfg.Trust)
+		case "revocation":
+			err = remarshal(v, &cfg.Revocation)
+		default:
+			cfg.Vars[k] = v
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, k, err)
+		}
+	}
+	if cfg.Vars == nil {
+		cfg.Vars = map[string]any{}
+	}
+	if cfg.Crypto == nil {
+		cfg.Crypto = map[string]bool{}
+	}
+	return cfg, nil
+}
+
+// remarshal converts a decoded YAML value into a typed destination.
+func remarshal(v any, dst any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(b, dst)
+}
+
+func (c *counterConfig) perDayCount(dir string) (func(action, day string) int, error) {
+	if c == nil {
+		return func(_, _ string) int { return 0 }, nil
+	}
+	switch c.Backend {
+	case "", "static":
+		counts := c.Counts
+		return func(action, day string) int { return counts[action][day] }, nil
+	case "file":
+		if c.Path == "" {
+			return nil, fmt.Errorf("counters: file backend needs a path")
+		}
+		// The file is re-read whenever it changes, so a long-running serve
+		// sees counts maintained by another process.
+		var mu sync.RWMutex
+		var counts map[string]map[string]int
+		w := &watchedFile{path: resolvePath(dir, c.Path), parse: func(data []byte) error {
+			var next map[string]map[string]int
+			if err := yaml.Unmarshal(data, &next); err != nil {
+				return err
+			}
+			mu.Lock()
+			counts = next
+			mu.Unlock()
+			return nil
+		}}
+		if err := w.refresh(); err != nil {
+			return nil, fmt.Errorf("counters: %w", err)
+		}
+		return func(action, day string) int {
+			w.refresh() // on error, keep the last good counts
+			mu.RLock()
+			defer mu.RUnlock()
+			return counts[action][day]
+		}, nil
+	}
+	return nil, fmt.Errorf("counters: unknown backend %q", c.Backend)
+}
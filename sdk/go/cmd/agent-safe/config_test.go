@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const counterPolicy = `(and
+  (member (get req "recipient") allowed_recipients)
+  (<= (per-day-count "pay" (get req "day")) 1)
+  (before now "2026-01-01T00:00:00Z")
+  (dpop_ok?))`
+
+func TestVerifyTokenConfig(t *testing.T) {
+	dir := t.TempDir()
+	key := write(t, dir, "key.json", mustRun(t, "keygen"))
+	policy := write(t, dir, "policy.spl", counterPolicy)
+	tok := write(t, dir, "token.json", mustRun(t, "mint", "--policy", policy, "--key", key))
+	write(t, dir, "counts.yaml", "pay:\n  \"2025-09-30\": 2\n")
+	cfg := write(t, dir, "vars.yaml", `now: 2025-10-01T00:00:00Z
+vars:
+  allowed_recipients: [niece@example.com]
+counters:
+  backend: file
+  path: counts.yaml
+crypto:
+  dpop: true
+`)
+
+	for day, want := range map[string]string{"2025-09-29": "ALLOW\n", "2025-09-30": "DENY\n"} {
+		req := write(t, dir, "req.json", `{"recipient": "niece@example.com", "day": "`+day+`"}`)
+		if out := verdict(t, "verify-token", "--token", tok, "--request", req, "--vars", cfg); out != want {
+			t.Fatalf("%s: expected %q, got %q", day, want, out)
+		}
+	}
+
+	// --now overrides the config's fixed time.
+	req := write(t, dir, "req.json", `{"recipient": "niece@example.com", "day": "2025-09-29"}`)
+	if out := verdict(t, "verify-token", "--token", tok, "--request", req, "--vars", cfg, "--now", "2026-06-01T00:00:00Z"); out != "DENY\n" {
+		t.Fatalf("expected DENY after --now override, got %q", out)
+	}
+}
+
+func TestAssumeWithoutConfig(t *testing.T) {
+	dir := t.TempDir()
+	policy := write(t, dir, "policy.spl", `(and (<= (get req "amount") 100) (dpop_ok?))`)
+	req := write(t, dir, "req.json", `{"amount": 50}`)
+	if out := verdict(t, "verify", "--assume", "dpop", policy, req); out != "ALLOW\n" {
+		t.Fatalf("expected --assume alone to satisfy dpop_ok?, got %q", out)
+	}
+	if out := verdict(t, "verify", policy, req); out != "DENY\n" {
+		t.Fatalf("expected DENY without --assume, got %q", out)
+	}
+}
+
+func TestConfigPlainVarsAndErrors(t *testing.T) {
+	dir := t.TempDir()
+	policy := write(t, dir, "policy.spl", `(member (get req "to") allowed)`)
+	req := write(t, dir, "req.json", `{"to": "a"}`)
+	plain := write(t, dir, "plain.json", `{"allowed": ["a", "b"]}`)
+	if out := verdict(t, "verify", "--vars", plain, policy, req); out != "ALLOW\n" {
+		t.Fatalf("expected plain vars file to work, got %q", out)
+	}
+
+	for name, content := range map[string]string{
+		"crypto.yaml":  "crypto:\n  telepathy: true\n",
+		"backend.yaml": "counters:\n  backend: carrier-pigeon\n",
+		"now.yaml":     "now: yesterday\n",
+	} {
+		cfg := write(t, dir, name, content)
+		code, _, errOut := agentSafe(t, "verify", "--vars", cfg, policy, req)
+		if code != exitError || !strings.Contains(errOut, "agent-safe verify:") {
+			t.Fatalf("%s: expected config error, got %d %q", name, code, errOut)
+		}
+	}
+}
+
+func TestConfigRequiredVars(t *testing.T) {
+	dir := t.TempDir()
+	policy := write(t, dir, "policy.spl", `(and (not (member (get req "to") blocked)) (= (get req "tier") tier))`)
+	req := write(t, dir, "req.json", `{"to": "a", "tier": "tier"}`)
+	lenient := write(t, dir, "lenient.yaml", "vars: {}\n")
+	if out := verdict(t, "verify", "--vars", lenient, policy, req); out != "ALLOW\n" {
+		t.Fatalf("expected unbound vars to evaluate leniently, got %q", out)
+	}
+	required := write(t, dir, "required.yaml", "required_vars: [blocked]\n")
+	code, out, _ := agentSafe(t, "verify", "--vars", required, policy, req)
+	if code != exitDeny || !strings.Contains(out, "unresolved symbol: blocked") {
+		t.Fatalf("expected the missing blocklist to deny, got %d %q", code, out)
+	}
+}
+
+func TestVerifyTokenTrustAnchors(t *testing.T) {
+	dir := t.TempDir()
+	issuer := write(t, dir, "issuer.json", mustRun(t, "keygen"))
+	other := write(t, dir, "other.json", mustRun(t, "keygen"))
+	policy := write(t, dir, "policy.spl", `(= 1 1)`)
+	req := write(t, dir, "req.json", `{}`)
+	cfg := write(t, dir, "verifier.yaml", "trust:\n  keys:\n    - file: issuer.json\n")
+
+	tok := write(t, dir, "token.json", mustRun(t, "mint", "--policy", policy, "--key", issuer))
+	if out := verdict(t, "verify-token", "--token", tok, "--request", req, "--vars", cfg); out != "ALLOW\n" {
+		t.Fatalf("expected the trusted issuer to be allowed, got %q", out)
+	}
+	forged := write(t, dir, "forged.json", mustRun(t, "mint", "--policy", policy, "--key", other))
+	if out := verdict(t, "verify-token", "--token", forged, "--request", req, "--vars", cfg); !strings.HasPrefix(out, "DENY: untrusted issuer key") {
+		t.Fatalf("expected an untrusted issuer to be denied, got %q", out)
+	}
+}
+
+func TestVerifyTokenTrustJWKS(t *testing.T) {
+	dir := t.TempDir()
+	published := write(t, dir, "published.jwk", mustRun(t, "keygen", "--format", "jwk"))
+	listed := write(t, dir, "listed.json", mustRun(t, "keygen"))
+	other := write(t, dir, "other.json", mustRun(t, "keygen"))
+	policy := write(t, dir, "policy.spl", `(= 1 1)`)
+	req := write(t, dir, "req.json", `{}`)
+
+	var jwk map[string]any
+	data, _ := os.ReadFile(published)
+	json.Unmarshal(data, &jwk)
+	delete(jwk, "d")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []any{jwk}})
+	}))
+	defer srv.Close()
+	cfg := write(t, dir, "verifier.yaml", "trust:\n  keys:\n    - file: listed.json\n  jwks: "+srv.URL+"\n")
+
+	for key, want := range map[string]string{published: "ALLOW", listed: "ALLOW", other: "DENY"} {
+		tok := write(t, dir, "token.json", mustRun(t, "mint", "--policy", policy, "--key", key))
+		if out := verdict(t, "verify-token", "--token", tok, "--request", req, "--vars", cfg); !strings.HasPrefix(out, want) {
+			t.Errorf("%s: expected %s, got %q", key, want, out)
+		}
+	}
+}
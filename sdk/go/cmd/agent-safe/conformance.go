@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jmcentire/agent-safe/sdk/go/conformance"
+)
+
+// cmdConformance checks a directory of shared test vectors against this
+// SDK. With --output json it prints a conformance.Report, the format other
+// SDKs' runners emit too, so reports can be compared file digest by file
+// digest.
+func cmdConformance(c *cli, args []string) error {
+	fs := c.flags("conformance")
+	verbose := fs.Bool("v", false, "list every case, not just failures")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	r, err := conformance.NewReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if c.json {
+		if err := writeJSON(c, r); err != nil {
+			return err
+		}
+	} else {
+		for _, res := range r.Results {
+			if !res.Pass {
+				fmt.Fprintf(c.stdout, "    FAIL %s\n", res)
+			} else if *verbose {
+				fmt.Fprintf(c.stdout, "    ok   %s\n", res)
+			}
+		}
+		for _, f := range r.Files {
+			status := "ok  "
+			if f.Failed > 0 {
+				status = "FAIL"
+			}
+			fmt.Fprintf(c.stdout, "%s %s\t%d cases\tsha256:%s\n", status, f.File, f.Passed+f.Failed, f.SHA256[:12])
+		}
+	}
+	if !r.OK() {
+		return errDenied
+	}
+	return nil
+}
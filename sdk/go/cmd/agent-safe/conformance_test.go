@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	dir := t.TempDir()
+	if err := conformance.GenerateVectors(dir); err != nil {
+		t.Fatal(err)
+	}
+	out := mustRun(t, "conformance", dir)
+	if !strings.Contains(out, "ok   "+conformance.EvalFile) || strings.Contains(out, "FAIL") {
+		t.Fatalf("unexpected output %q", out)
+	}
+	var r conformance.Report
+	if err := json.Unmarshal([]byte(mustRun(t, "conformance", "--output", "json", dir)), &r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.OK() || r.Passed == 0 || r.SDK != "go" {
+		t.Fatalf("unexpected report %+v", r)
+	}
+
+	path := filepath.Join(dir, conformance.EvalFile)
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(data), `"expected": true`, `"expected": false`, 1)), 0o644)
+	if code, out, _ := agentSafe(t, "conformance", dir); code != exitDeny || !strings.Contains(out, "FAIL "+conformance.EvalFile) {
+		t.Fatalf("expected a failing case to exit %d, got %d: %s", exitDeny, code, out)
+	}
+	if code, _, _ := agentSafe(t, "conformance"); code != exitError {
+		t.Fatalf("expected a usage error without a directory, got %d", code)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// The key store location and passphrase default to these environment
+// variables; the store otherwise lives in the user config directory.
+const (
+	envKeyStore   = "AGENT_SAFE_KEYSTORE"
+	envPassphrase = "AGENT_SAFE_PASSPHRASE"
+)
+
+// openKeyStore opens the key store at path, or the default location when
+// path is empty, with the passphrase from passphraseFile or, when that is
+// empty, $AGENT_SAFE_PASSPHRASE.
+func openKeyStore(path, passphraseFile string) (*spl.KeyStore, error) {
+	if path == "" {
+		path = os.Getenv(envKeyStore)
+	}
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("key store: %w; set %s", err, envKeyStore)
+		}
+		path = filepath.Join(dir, "agent-safe", "keystore.json")
+	}
+	var pass []byte
+	if passphraseFile != "" {
+		data, err := readFile(passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		pass = bytes.TrimRight(data, "\r\n")
+	} else {
+		pass = []byte(os.Getenv(envPassphrase))
+	}
+	if len(pass) == 0 {
+		return nil, fmt.Errorf("key store: no passphrase; set %s or pass --passphrase-file", envPassphrase)
+	}
+	return spl.OpenKeyStore(path, pass)
+}
+
+// keyStoreFlags are the flags every key subcommand shares.
+type keyStoreFlags struct {
+	path, passphraseFile *string
+}
+
+func addKeyStoreFlags(fs *flag.FlagSet) *keyStoreFlags {
+	return &keyStoreFlags{
+		path:           fs.String("keystore", "", "key store file (default $"+envKeyStore+" or the user config directory)"),
+		passphraseFile: fs.String("passphrase-file", "", "file holding the key store passphrase (default $"+envPassphrase+")"),
+	}
+}
+
+func (f *keyStoreFlags) open() (*spl.KeyStore, error) {
+	return openKeyStore(*f.path, *f.passphraseFile)
+}
+
+// keyInfo is the public view of a key store entry.
+type keyInfo struct {
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Alg       string    `json:"alg,omitempty"`
+	PublicKey string    `json:"public_key,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Created   time.Time `json:"created"`
+}
+
+func publicInfo(k spl.StoredKey) keyInfo {
+	return keyInfo{k.Name, k.Kind, k.Alg, k.PublicKey, k.Path, k.Created}
+}
+
+func cmdKey(c *cli, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	sub := map[string]func(*cli, []string) error{
+		"new":    cmdKeyNew,
+		"list":   cmdKeyList,
+		"export": cmdKeyExport,
+		"import": cmdKeyImport,
+		"derive": cmdKeyDerive,
+	}[args[0]]
+	if sub == nil {
+		return errUsage
+	}
+	return sub(c, args[1:])
+}
+
+func cmdKeyNew(c *cli, args []string) error {
+	fs := c.flags("key new")
+	alg := fs.String("alg", spl.AlgEd25519, "key algorithm: Ed25519 or ES256")
+	seed := fs.Bool("seed", false, "generate an HD master seed for key derive instead of a key")
+	ksf := addKeyStoreFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	ks, err := ksf.open()
+	if err != nil {
+		return err
+	}
+	k := spl.StoredKey{Name: fs.Arg(0), Kind: spl.KeyKindKey, Alg: *alg}
+	if *seed {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		k = spl.StoredKey{Name: fs.Arg(0), Kind: spl.KeyKindSeed, PrivateKey: hex.EncodeToString(b)}
+	} else if k.PublicKey, k.PrivateKey, err = spl.GenerateKeypairAlg(*alg); err != nil {
+		return err
+	}
+	return c.addKey(ks, k)
+}
+
+// addKey stores k, saves the store and prints the public view of k.
+func (c *cli) addKey(ks *spl.KeyStore, k spl.StoredKey) error {
+	if err := ks.Add(k); err != nil {
+		return err
+	}
+	if err := ks.Save(); err != nil {
+		return err
+	}
+	k, _ = ks.Get(k.Name)
+	return writeJSON(c, publicInfo(k))
+}
+
+func cmdKeyList(c *cli, args []string) error {
+	fs := c.flags("key list")
+	ksf := addKeyStoreFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	ks, err := ksf.open()
+	if err != nil {
+		return err
+	}
+	infos := []keyInfo{}
+	for _, k := range ks.Keys() {
+		infos = append(infos, publicInfo(k))
+	}
+	if c.json {
+		return writeJSON(c, infos)
+	}
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tKIND\tALG\tPUBLIC KEY\tCREATED")
+	for _, k := range infos {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", k.Name, k.Kind, orDash(k.Alg), orDash(k.PublicKey), k.Created.Format(time.DateOnly))
+	}
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func cmdKeyExport(c *cli, args []string) error {
+	fs := c.flags("key export")
+	private := fs.Bool("private", false, "include the private key or seed")
+	format := fs.String("format", "hex", "output format: hex, jwk, or pem")
+	ksf := addKeyStoreFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	ks, err := ksf.open()
+	if err != nil {
+		return err
+	}
+	k, ok := ks.Get(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("key store: no entry named %q", fs.Arg(0))
+	}
+	if k.Kind == spl.KeyKindSeed {
+		if !*private || *format != "hex" {
+			return errors.New("a seed has no public key; export it with --private in hex format")
+		}
+		_, err := fmt.Fprintln(c.stdout, k.PrivateKey)
+		return err
+	}
+	priv := ""
+	if *private {
+		priv = k.PrivateKey
+	}
+	switch *format {
+	case "hex":
+		return writeJSON(c, keyFile{Alg: k.Alg, PublicKey: k.PublicKey, PrivateKey: priv})
+	case "jwk":
+		j, err := spl.KeyToJWK(k.Alg, k.PublicKey, priv)
+		if err != nil {
+			return err
+		}
+		return writeJSON(c, j)
+	case "pem":
+		out, err := spl.PublicKeyToPEM(k.Alg, k.PublicKey)
+		if err != nil {
+			return err
+		}
+		if priv != "" {
+			privPEM, err := spl.PrivateKeyToPEM(k.Alg, priv)
+			if err != nil {
+				return err
+			}
+			out = privPEM + out
+		}
+		_, err = fmt.Fprint(c.stdout, out)
+		return err
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func cmdKeyImport(c *cli, args []string) error {
+	fs := c.flags("key import")
+	seed := fs.Bool("seed", false, "import an HD master seed (hex, or a BIP-39 mnemonic) instead of a key")
+	ksf := addKeyStoreFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errUsage
+	}
+	name, path := fs.Arg(0), fs.Arg(1)
+	data, err := c.readInput(path)
+	if err != nil {
+		return err
+	}
+	k := spl.StoredKey{Name: name, Kind: spl.KeyKindKey}
+	if *seed {
+		k.Kind = spl.KeyKindSeed
+		k.PrivateKey = strings.TrimSpace(string(data))
+		if strings.Contains(k.PrivateKey, " ") {
+			b, err := spl.MnemonicToSeed(k.PrivateKey, "")
+			if err != nil {
+				return err
+			}
+			k.PrivateKey = hex.EncodeToString(b)
+		}
+	} else {
+		if k.Alg, k.PublicKey, k.PrivateKey, err = parseKey(data, inputName(path)); err != nil {
+			return err
+		}
+		if k.PrivateKey == "" {
+			return fmt.Errorf("%s: no private key", inputName(path))
+		}
+	}
+	ks, err := ksf.open()
+	if err != nil {
+		return err
+	}
+	return c.addKey(ks, k)
+}
+
+func cmdKeyDerive(c *cli, args []string) error {
+	fs := c.flags("key derive")
+	service := fs.String("service", "", "service domain the key is for, e.g. payments.example.com")
+	epoch := fs.Int("epoch", 0, "rotation epoch")
+	name := fs.String("name", "", "name for the derived key (default SERVICE-epoch-N)")
+	ksf := addKeyStoreFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *service == "" {
+		return errUsage
+	}
+	if *name == "" {
+		*name = fmt.Sprintf("%s-epoch-%d", *service, *epoch)
+	}
+	ks, err := ksf.open()
+	if err != nil {
+		return err
+	}
+	k, err := ks.Derive(fs.Arg(0), *name, *service, *epoch)
+	if err != nil {
+		return err
+	}
+	if err := ks.Save(); err != nil {
+		return err
+	}
+	return writeJSON(c, publicInfo(k))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyStoreCommands(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(envKeyStore, filepath.Join(dir, "keystore.json"))
+	t.Setenv(envPassphrase, "s3cret")
+
+	var issuer keyInfo
+	if err := json.Unmarshal([]byte(mustRun(t, "key", "new", "issuer")), &issuer); err != nil || issuer.PublicKey == "" {
+		t.Fatalf("key new: %+v, %v", issuer, err)
+	}
+	mustRun(t, "key", "new", "--seed", "master")
+	var derived keyInfo
+	json.Unmarshal([]byte(mustRun(t, "key", "derive", "--service", "payments.example.com", "--epoch", "1", "master")), &derived)
+	if derived.Name != "payments.example.com-epoch-1" || derived.Path == "" {
+		t.Fatalf("key derive: %+v", derived)
+	}
+	mustRun(t, "key", "import", "imported", write(t, dir, "es.json", mustRun(t, "keygen", "--alg", "ES256")))
+
+	list := mustRun(t, "key", "list")
+	for _, name := range []string{"issuer", "master", "payments.example.com-epoch-1", "imported"} {
+		if !strings.Contains(list, name) {
+			t.Errorf("key list missing %s:\n%s", name, list)
+		}
+	}
+
+	var pub keyFile
+	json.Unmarshal([]byte(mustRun(t, "key", "export", "issuer")), &pub)
+	if pub.PublicKey != issuer.PublicKey || pub.PrivateKey != "" {
+		t.Fatalf("key export without --private: %+v", pub)
+	}
+	if out := mustRun(t, "key", "export", "--private", "issuer"); !strings.Contains(out, "private_key") {
+		t.Fatalf("key export --private: %s", out)
+	}
+
+	// Signing commands take keystore:NAME in place of a key file.
+	policy := write(t, dir, "policy.spl", `(<= (get req "amount") 100)`)
+	tok := write(t, dir, "token.json", mustRun(t, "mint", "--policy", policy, "--key", "keystore:issuer"))
+	if !strings.Contains(mustRun(t, "inspect", tok), issuer.PublicKey) {
+		t.Fatal("token not signed by the stored key")
+	}
+
+	for _, args := range [][]string{
+		{"key", "new", "issuer"},
+		{"key", "export", "master"},
+		{"key", "derive", "--service", "x.example.com", "issuer"},
+		{"mint", "--policy", policy, "--key", "keystore:missing"},
+	} {
+		if code, _, _ := agentSafe(t, args...); code != exitError {
+			t.Errorf("%v: exit %d, want %d", args, code, exitError)
+		}
+	}
+	t.Setenv(envPassphrase, "wrong")
+	if code, _, errOut := agentSafe(t, "key", "list"); code != exitError || !strings.Contains(errOut, "passphrase") {
+		t.Fatalf("wrong passphrase: exit %d: %s", code, errOut)
+	}
+}
@@ -0,0 +1,154 @@
+// Command agent-safe mints, inspects, attenuates and verifies Agent-Safe
+// capability tokens and SPL policies.
+//
+//	agent-safe <command> [flags]
+//
+// Run "agent-safe help" for the list of commands. Every command accepts
+// --output json for machine-readable results. Exit status is 0 for success
+// or allow, 1 for deny, and 2 for errors, including usage errors. A policy,
+// request or token argument of "-" is read from standard input.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// cli carries the process streams so commands can be exercised in tests.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	json   bool // --output json
+
+	stdinUsed bool // an argument of "-" has consumed stdin
+}
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"keygen", "keygen [--alg Ed25519|ES256|X25519] [--format hex|jwk|pem]", "generate a keypair", cmdKeygen},
+	{"key", "key new [--alg Ed25519|ES256] [--seed] NAME\n       agent-safe key list\n       agent-safe key export [--private] [--format hex|jwk|pem] NAME\n       agent-safe key import [--seed] NAME FILE\n       agent-safe key derive --service DOMAIN [--epoch N] [--name NAME] SEED\n       (each accepts --keystore FILE and --passphrase-file FILE)", "manage keys in the encrypted key store", cmdKey},
+	{"mint", "mint --policy FILE --key FILE [--expires RFC3339|DURATION] [--seal] [--pop-key HEX|SPIFFE-ID] [--merkle-root HEX] [--action NAME=FILE]... [--format json|compact]", "mint a signed token", cmdMint},
+	{"verify", "verify [--explain] [--watch] [--vars FILE] [--now RFC3339] [--assume PREDICATES] POLICY REQUEST\n       agent-safe verify --policy FILE --requests DIR|JSONL [--parallel N] [--vars FILE] ...", "evaluate a policy against a request or a request corpus", cmdVerify},
+	{"verify-token", "verify-token --token FILE --request FILE|--http-request FILE [--explain] [--witnesses FILE] [--vars FILE] [--now RFC3339] [--assume PREDICATES]", "verify a token and evaluate its policy", cmdVerifyToken},
+	{"bench", "bench --policy FILE --request FILE [--token FILE] [--duration 10s] [--vars FILE] [--now RFC3339] [--assume PREDICATES]", "measure parse, eval and signature-verify latency", cmdBench},
+	{"serve", "serve --vars FILE [--addr HOST:PORT] [--upstream URL [--forward-token]] [--allow-any-issuer] [--now RFC3339] [--assume PREDICATES]", "run an HTTP verification service (POST /v1/verify, OPA Data API) or, with --upstream, an enforcing reverse proxy", cmdServe},
+	{"mcp-guard", "mcp-guard [--token FILE] [--caller NAME] [--vars FILE] [--now RFC3339] [--assume PREDICATES] -- SERVER [ARG...]", "run an MCP stdio tool server, gating tool calls on a token", cmdMCPGuard},
+	{"merkle-allowlist", "merkle-allowlist VALUES-FILE", "commit to a private allowlist, printing its Merkle root and witnesses", cmdMerkleAllowlist},
+	{"openapi", "openapi [--scope FILE] SPEC [OPERATION...]", "generate a policy allowing operations of an OpenAPI document", cmdOpenAPI},
+	{"attenuate", "attenuate --token FILE --key FILE --constraint EXPR", "narrow a token's policy", cmdAttenuate},
+	{"delegate", "delegate --parent FILE --policy FILE [--key FILE] [--out FILE] [--vars FILE]", "derive a child token whose policy is provably narrower", cmdDelegate},
+	{"verify-chain", "verify-chain [--request FILE] [--vars FILE] [--now RFC3339] [--assume PREDICATES] TOKEN...", "check a delegation chain, root first", cmdVerifyChain},
+	{"lint", "lint [--fail-on info|warning|error] POLICY...", "check policies for mistakes", cmdLint},
+	{"fmt", "fmt [-w | -check] [POLICY...]", "format policies canonically", cmdFmt},
+	{"convert", "convert --from spl|cedar|rego --to spl|cedar|rego [POLICY]", "translate a policy to or from Cedar or Rego", cmdConvert},
+	{"diff", "diff [--vars FILE] [--prove-narrower] OLD NEW", "show semantic changes between two policies", cmdDiff},
+	{"simulate", "simulate [--fail-on-flip] [--vars FILE] [--now RFC3339] [--assume PREDICATES] OLD NEW REQUESTS.jsonl", "replay recorded requests against two policies and list the decisions that flip", cmdSimulate},
+	{"test", "test [-v] [PATH...]", "run policy test suites (*_test.yaml)", cmdTest},
+	{"mutate", "mutate [-v] [--min-score F] [PATH...]", "find policy clauses test suites do not cover, by mutating the policy", cmdMutate},
+	{"conformance", "conformance [-v] VECTORS-DIR", "check the shared cross-SDK test vectors against this SDK", cmdConformance},
+	{"gen-requests", "gen-requests --policy FILE [--count N] [--seed N] [--vars FILE] [--out DIR]", "generate boundary-value requests from a policy's constraints", cmdGenRequests},
+	{"inspect", "inspect --token FILE", "describe a token and check its signature", cmdInspect},
+	{"seal", "seal --token FILE --key FILE", "seal a token against further attenuation", cmdSeal},
+}
+
+// Exit statuses.
+const (
+	exitOK    = 0 // success, or the request is allowed
+	exitDeny  = 1 // the request is denied, or a check such as lint failed
+	exitError = 2
+)
+
+var (
+	// errUsage reports a command-line mistake; the command's usage is printed.
+	errUsage = errors.New("usage")
+	// errDenied reports a deny decision, or a failed check, that has
+	// already been printed.
+	errDenied = errors.New("denied")
+	// errReported reports a failure whose details have already been printed.
+	errReported = errors.New("failed")
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		c.usage()
+		return exitError
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		c.usage()
+		return exitOK
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(c, args[1:])
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.Is(err, errDenied):
+			return exitDeny
+		case errors.Is(err, errReported):
+		case errors.Is(err, errUsage):
+			fmt.Fprintf(stderr, "usage: agent-safe %s\n", cmd.usage)
+		case c.json:
+			writeJSON(c, map[string]string{"error": err.Error()})
+		default:
+			fmt.Fprintf(stderr, "agent-safe %s: %v\n", cmd.name, err)
+		}
+		return exitError
+	}
+	fmt.Fprintf(stderr, "agent-safe: unknown command %q\n", args[0])
+	c.usage()
+	return exitError
+}
+
+func (c *cli) usage() {
+	fmt.Fprintln(c.stderr, "usage: agent-safe <command> [flags]\n\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stderr, "  %-13s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(c.stderr, "\nevery command accepts --output json; exit status is 0 allow, 1 deny, 2 error")
+	fmt.Fprintln(c.stderr, "a policy, request or token argument of - reads standard input")
+}
+
+// flags returns a flag set for a command that reports errors on stderr,
+// with the --output flag every command shares.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Func("output", "output format: text or json (default text)", func(v string) error {
+		switch v {
+		case "text", "json":
+			c.json = v == "json"
+			return nil
+		}
+		return fmt.Errorf("unknown output format %q", v)
+	})
+	return fs
+}
+
+// parse parses args, mapping flag syntax errors to errUsage.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// agentSafe runs the CLI in-process and returns its exit code and output.
+func agentSafe(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	return agentSafeStdin(t, "", args...)
+}
+
+// agentSafeStdin is agentSafe with stdin holding the given input.
+func agentSafeStdin(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// write creates a file in dir and returns its path.
+func write(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func mustRun(t *testing.T, args ...string) string {
+	t.Helper()
+	code, out, errOut := agentSafe(t, args...)
+	if code != 0 {
+		t.Fatalf("agent-safe %s: exit %d: %s", strings.Join(args, " "), code, errOut)
+	}
+	return out
+}
+
+// verdict runs a verify command and checks its exit code agrees with the
+// printed decision: 0 for ALLOW, 1 for DENY.
+func verdict(t *testing.T, args ...string) string {
+	t.Helper()
+	code, out, errOut := agentSafe(t, args...)
+	want := exitOK
+	if strings.HasPrefix(out, "DENY") {
+		want = exitDeny
+	}
+	if code != want {
+		t.Fatalf("agent-safe %s: exit %d with output %q: %s", strings.Join(args, " "), code, out, errOut)
+	}
+	return out
+}
+
+func TestTokenLifecycle(t *testing.T) {
+	for _, alg := range []string{"Ed25519", "ES256"} {
+		t.Run(alg, func(t *testing.T) {
+			dir := t.TempDir()
+			key := write(t, dir, "key.json", mustRun(t, "keygen", "--alg", alg))
+			policy := write(t, dir, "policy.spl", `(<= (get req "amount") 100)`)
+			req := write(t, dir, "req.json", `{"amount": 50}`)
+
+			tok := write(t, dir, "token.json", mustRun(t, "mint", "--policy", policy, "--key", key, "--expires", "2099-01-01T00:00:00Z"))
+			if out := verdict(t, "verify-token", "--token", tok, "--request", req); out != "ALLOW\n" {
+				t.Fatalf("expected ALLOW, got %q", out)
+			}
+
+			narrow := write(t, dir, "narrow.json", mustRun(t, "attenuate", "--token", tok, "--key", key, "--constraint", `(<= (get req "amount") 25)`))
+			if out := verdict(t, "verify-token", "--token", narrow, "--request", req); out != "DENY\n" {
+				t.Fatalf("expected DENY from attenuated token, got %q", out)
+			}
+
+			sealed := write(t, dir, "sealed.json", mustRun(t, "seal", "--token", tok, "--key", key))
+			if code, _, _ := agentSafe(t, "attenuate", "--token", sealed, "--key", key, "--constraint", "(= 1 1)"); code != exitError {
+				t.Fatalf("expected sealed token to refuse attenuation, exit %d", code)
+			}
+
+			out := mustRun(t, "inspect", sealed)
+			if !strings.Contains(out, "sealed:     true") || !strings.Contains(out, "signature:  valid") {
+				t.Fatalf("unexpected inspect output:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestVerifyTokenReportsTampering(t *testing.T) {
+	dir := t.TempDir()
+	key := write(t, dir, "key.json", mustRun(t, "keygen"))
+	policy := write(t, dir, "policy.spl", `(<= (get req "amount") 100)`)
+	tok := mustRun(t, "mint", "--policy", policy, "--key", key)
+	tampered := write(t, dir, "token.json", strings.Replace(tok, "100", "1000", 1))
+	req := write(t, dir, "req.json", `{"amount": 500}`)
+
+	if out := verdict(t, "verify-token", "--token", tampered, "--request", req); out != "DENY: invalid signature\n" {
+		t.Fatalf("got %q", out)
+	}
+	if out := mustRun(t, "inspect", "--token", tampered); !strings.Contains(out, "signature:  invalid") {
+		t.Fatalf("expected inspect to flag the signature:\n%s", out)
+	}
+	odd := write(t, dir, "odd.json", strings.Replace(tok, `"sealed"`, `"audience": "svc", "sealed"`, 1))
+	if out := mustRun(t, "inspect", odd); !strings.Contains(out, "schema:     invalid\n  /audience: unknown field \"audience\"") {
+		t.Fatalf("expected inspect to report the unknown field:\n%s", out)
+	}
+}
+
+func TestVerifyExamplePolicy(t *testing.T) {
+	examples := "../../../../examples/"
+	policy := examples + "policies/family_gifts.spl"
+	req := examples + "requests/gift_50_niece.json"
+	vars := examples + "vars/family_gifts.yaml"
+	if _, err := os.Stat(policy); err != nil {
+		t.Skipf("examples not available: %v", err)
+	}
+	if out := verdict(t, "verify", "--vars", vars, policy, req); out != "ALLOW\n" {
+		t.Fatalf("expected ALLOW, got %q", out)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	if code, _, _ := agentSafe(t); code != 2 {
+		t.Fatalf("expected exit 2 without a command, got %d", code)
+	}
+	if code, _, _ := agentSafe(t, "frobnicate"); code != 2 {
+		t.Fatalf("expected exit 2 for an unknown command, got %d", code)
+	}
+	if code, _, errOut := agentSafe(t, "mint"); code != 2 || !strings.Contains(errOut, "usage: agent-safe mint") {
+		t.Fatalf("expected mint usage, got %d %q", code, errOut)
+	}
+	if code, _, _ := agentSafe(t, "verify", "--assume", "bogus", "a", "b"); code != 2 {
+		t.Fatalf("expected exit 2, got %d", code)
+	}
+	if code, _, errOut := agentSafe(t, "inspect", "--output", "yaml", "t.json"); code != 2 || !strings.Contains(errOut, "unknown output format") {
+		t.Fatalf("expected --output to be validated, got %d %q", code, errOut)
+	}
+}
+
+func TestStdinArguments(t *testing.T) {
+	dir := t.TempDir()
+	key := write(t, dir, "key.json", mustRun(t, "keygen"))
+	policy := write(t, dir, "policy.spl", `(<= (get req "amount") 100)`)
+	req := write(t, dir, "req.json", `{"amount": 50}`)
+
+	code, tok, errOut := agentSafeStdin(t, `(<= (get req "amount") 100)`, "mint", "--policy", "-", "--key", key)
+	if code != exitOK {
+		t.Fatalf("mint --policy -: exit %d: %s", code, errOut)
+	}
+	tokPath := write(t, dir, "token.json", tok)
+
+	for _, tc := range []struct {
+		name, stdin string
+		args        []string
+		want        int
+	}{
+		{"request", `{"amount": 50}`, []string{"verify-token", "--token", tokPath, "--request", "-"}, exitOK},
+		{"request deny", `{"amount": 500}`, []string{"verify-token", "--token", tokPath, "--request", "-"}, exitDeny},
+		{"token", tok, []string{"verify-token", "--token", "-", "--request", req}, exitOK},
+		{"inspect", tok, []string{"inspect", "-"}, exitOK},
+		{"verify policy", `(<= (get req "amount") 10)`, []string{"verify", "-", req}, exitDeny},
+		{"verify request", `{"amount": 5}`, []string{"verify", policy, "-"}, exitOK},
+		{"batch", "{\"amount\": 5}\n{\"amount\": 500}\n", []string{"verify", "--policy", policy, "--requests", "-"}, exitDeny},
+		{"lint", `(and)`, []string{"lint", "-"}, exitOK},
+		{"fmt check", `(and  #t)`, []string{"fmt", "-check", "-"}, exitDeny},
+		{"diff", `(<= (get req "amount") 10)`, []string{"diff", "--prove-narrower", policy, "-"}, exitOK},
+		{"two stdin arguments", tok, []string{"verify-token", "--token", "-", "--request", "-"}, exitError},
+		{"fmt -w", `(and #t)`, []string{"fmt", "-w", "-"}, exitError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, out, errOut := agentSafeStdin(t, tc.stdin, tc.args...)
+			if code != tc.want {
+				t.Fatalf("exit %d, want %d\nstdout: %s\nstderr: %s", code, tc.want, out, errOut)
+			}
+		})
+	}
+
+	code, out, _ := agentSafeStdin(t, "{\"amount\": 5}\n", "verify", "--output", "json", "--policy", policy, "--requests", "-")
+	if code != exitOK || !strings.Contains(out, `"stdin:1"`) {
+		t.Fatalf("batch from stdin: exit %d: %s", code, out)
+	}
+}
+
+func TestMintOptions(t *testing.T) {
+	dir := t.TempDir()
+	key := write(t, dir, "key.json", mustRun(t, "keygen"))
+	req := write(t, dir, "req.json", `{"amount": 50}`)
+
+	var tok spl.Token
+	out := mustRun(t, "mint", "--policy", write(t, dir, "p.spl", `(<= (get req "amount") 100)`), "--key", key, "--expires", "24h", "--seal")
+	if err := json.Unmarshal([]byte(out), &tok); err != nil {
+		t.Fatal(err)
+	}
+	exp, err := time.Parse(time.RFC3339, tok.Expires)
+	if err != nil || time.Until(exp) < 23*time.Hour || time.Until(exp) > 25*time.Hour {
+		t.Fatalf("--expires 24h gave %q", tok.Expires)
+	}
+	if !tok.Sealed {
+		t.Fatal("--seal did not seal the token")
+	}
+
+	compact := mustRun(t, "mint", "--policy", write(t, dir, "p2.spl", `(<= (get req "amount") 100)`), "--key", key, "--format", "compact")
+	if strings.Count(compact, "\n") != 1 || strings.ContainsAny(compact, "{ ") {
+		t.Fatalf("compact output is not one base64url line: %q", compact)
+	}
+	if out := verdict(t, "verify-token", "--token", write(t, dir, "compact.tok", compact), "--request", req); out != "ALLOW\n" {
+		t.Fatalf("compact token: got %q", out)
+	}
+
+	code, _, errOut := agentSafe(t, "mint", "--policy", write(t, dir, "bad.spl", `(and (frobnicate 1) #t)`), "--key", key)
+	if code != exitError || !strings.Contains(errOut, "unknown-op") {
+		t.Fatalf("lint error: exit %d: %s", code, errOut)
+	}
+
+	multi := write(t, dir, "multi.json", mustRun(t, "mint", "--policy", write(t, dir, "read.spl", `#t`), "--key", key,
+		"--action", "pay="+write(t, dir, "pay.spl", `(<= (get req "amount") 10)`)))
+	for body, want := range map[string]string{
+		`{"action": "read", "amount": 50}`: "ALLOW\n",
+		`{"action": "pay", "amount": 5}`:   "ALLOW\n",
+		`{"action": "pay", "amount": 50}`:  "DENY\n",
+	} {
+		if out := verdict(t, "verify-token", "--token", multi, "--request", write(t, dir, "req.json", body)); out != want {
+			t.Fatalf("%s: expected %q, got %q", body, want, out)
+		}
+	}
+	if out := mustRun(t, "inspect", multi); !strings.Contains(out, "signature:  valid") || !strings.Contains(out, "action pay:\n  (<= (get req \"amount\") 10)") {
+		t.Fatalf("inspect of a token with actions:\n%s", out)
+	}
+	code, _, errOut = agentSafe(t, "mint", "--policy", write(t, dir, "p3.spl", `#t`), "--key", key, "--action", "pay")
+	if code != exitError || !strings.Contains(errOut, "NAME=FILE") {
+		t.Fatalf("--action without a file: exit %d: %s", code, errOut)
+	}
+}
+
+func TestMerkleAllowlist(t *testing.T) {
+	dir := t.TempDir()
+	key := write(t, dir, "key.json", mustRun(t, "keygen"))
+	var list spl.MerkleAllowlist
+	if err := json.Unmarshal([]byte(mustRun(t, "merkle-allowlist", write(t, dir, "allowed.txt", "alice\nbob\n\ncarol\n"))), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Witnesses) != 3 {
+		t.Fatalf("expected 3 witnesses, got %v", list.Witnesses)
+	}
+	tok := write(t, dir, "token.json", mustRun(t, "mint", "--policy", write(t, dir, "p.spl", `(merkle-member? (get req "to"))`), "--key", key, "--merkle-root", list.Root))
+
+	// The agent presents only the witness for the value it uses.
+	b, _ := json.Marshal(spl.MerkleAllowlist{Witnesses: map[string][]spl.MerkleProofStep{"bob": list.Witnesses["bob"]}})
+	witness := write(t, dir, "bob.json", string(b))
+	for to, want := range map[string]string{"bob": "ALLOW\n", "carol": "DENY\n"} {
+		req := write(t, dir, "req.json", `{"to": "`+to+`"}`)
+		if out := verdict(t, "verify-token", "--token", tok, "--request", req, "--witnesses", witness); out != want {
+			t.Fatalf("%s: expected %q, got %q", to, want, out)
+		}
+	}
+	if out := verdict(t, "verify-token", "--token", tok, "--request", write(t, dir, "req.json", `{"to": "bob"}`)); out != "DENY\n" {
+		t.Fatalf("expected no witness to deny, got %q", out)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	dir := t.TempDir()
+	spec := write(t, dir, "openapi.yaml", `paths:
+  /v1/payments:
+    post:
+      operationId: createPayment
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [amount]
+              properties:
+                amount: {type: number, minimum: 1}
+  /v1/payments/{id}:
+    delete:
+      operationId: deletePayment
+`)
+	scope := write(t, dir, "scope.yaml", "- operation: createPayment\n  bounds:\n    body.amount: {max: 50}\n")
+	src := mustRun(t, "openapi", "--scope", scope, spec)
+	if !strings.Contains(src, `(<= (get (get req "body") "amount") 50)`) {
+		t.Fatalf("expected the bound in the policy, got\n%s", src)
+	}
+	key := write(t, dir, "key.json", mustRun(t, "keygen"))
+	tok := write(t, dir, "token.json", mustRun(t, "mint", "--policy", write(t, dir, "p.spl", src), "--key", key))
+	for body, want := range map[string]string{`{"amount": 20}`: "ALLOW\n", `{"amount": 80}`: "DENY\n"} {
+		req := write(t, dir, "req.json", `{"method": "POST", "route": "/v1/payments", "body": `+body+`}`)
+		if out := verdict(t, "verify-token", "--token", tok, "--request", req); out != want {
+			t.Fatalf("%s: expected %q, got %q", body, want, out)
+		}
+	}
+	if code, _, errOut := agentSafe(t, "openapi", spec, "refundPayment"); code != exitError || !strings.Contains(errOut, "refundPayment") {
+		t.Fatalf("unknown operation: exit %d: %s", code, errOut)
+	}
+	if code, _, _ := agentSafe(t, "openapi", spec); code != exitError {
+		t.Fatalf("expected a usage error without operations, got exit %d", code)
+	}
+}
+
+func TestVerifyTokenHTTPRequest(t *testing.T) {
+	dir := t.TempDir()
+	key := write(t, dir, "key.json", mustRun(t, "keygen"))
+	policy := `(and (= (get req "method") "POST") (= (get req "host") "api.example.com") (<= (get (get req "body") "amount") 50))`
+	tok := write(t, dir, "token.json", mustRun(t, "mint", "--policy", write(t, dir, "p.spl", policy), "--key", key))
+	for body, want := range map[string]string{`{"amount": 20}`: "ALLOW\n", `{"amount": 80}`: "DENY\n"} {
+		raw := "POST /pay HTTP/1.1\r\nHost: API.example.com:80\r\nContent-Type: application/json\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+		if out := verdict(t, "verify-token", "--token", tok, "--http-request", write(t, dir, "req.http", raw)); out != want {
+			t.Fatalf("%s: expected %q, got %q", body, want, out)
+		}
+	}
+	if code, _, _ := agentSafe(t, "verify-token", "--token", tok, "--http-request", write(t, dir, "bad.http", "not http"), "--request", "x.json"); code != exitError {
+		t.Fatalf("expected --request and --http-request together to be a usage error, got exit %d", code)
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for in, want := range map[string]string{
+		"":                     "",
+		"2027-01-01T00:00:00Z": "2027-01-01T00:00:00Z",
+		"90m":                  "2026-01-01T13:30:00Z",
+		"7d":                   "2026-01-08T12:00:00Z",
+	} {
+		if got, err := parseExpiry(in, now); err != nil || got != want {
+			t.Errorf("parseExpiry(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"-1h", "soon", "0d"} {
+		if _, err := parseExpiry(in, now); err == nil {
+			t.Errorf("parseExpiry(%q) succeeded", in)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	dir := t.TempDir()
+	key := write(t, dir, "key.json", mustRun(t, "keygen"))
+	policy := write(t, dir, "policy.spl", `(and (= (get req "action") "pay") (<= (get req "amount") 100))`)
+	req := write(t, dir, "req.json", `{"action": "pay", "amount": 500}`)
+	tok := write(t, dir, "token.json", mustRun(t, "mint", "--policy", policy, "--key", key))
+
+	for _, args := range [][]string{
+		{"verify", "--explain", policy, req},
+		{"verify-token", "--explain", "--token", tok, "--request", req},
+	} {
+		out := verdict(t, args...)
+		if !strings.Contains(out, `>   (<= (get req "amount") 100) => false   <- denied here`) ||
+			!strings.Contains(out, `(get req "amount") => 500`) {
+			t.Errorf("%s: missing failing clause:\n%s", args[0], out)
+		}
+	}
+
+	code, out, _ := agentSafe(t, "verify", "--explain", "--output", "json", policy, req)
+	var r struct {
+		Trace *spl.Trace `json:"trace"`
+	}
+	if err := json.Unmarshal([]byte(out), &r); code != exitDeny || err != nil || r.Trace == nil || !r.Trace.Failing || len(r.Trace.Children) != 2 {
+		t.Fatalf("JSON trace: exit %d: %s", code, out)
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	dir := t.TempDir()
+	key := write(t, dir, "key.json", mustRun(t, "keygen"))
+	policy := write(t, dir, "policy.spl", `(<= (get req "amount") 100)`)
+	tok := write(t, dir, "token.json", mustRun(t, "mint", "--policy", policy, "--key", key))
+
+	type result struct {
+		Decision   string `json:"decision"`
+		Reason     string `json:"reason"`
+		GasUsed    int    `json:"gas_used"`
+		DurationUS *int64 `json:"duration_us"`
+	}
+	for _, tc := range []struct {
+		cmd      []string
+		amount   string
+		code     int
+		decision string
+	}{
+		{[]string{"verify", policy}, "50", exitOK, "allow"},
+		{[]string{"verify", policy}, "500", exitDeny, "deny"},
+		{[]string{"verify-token", "--token", tok, "--request"}, "50", exitOK, "allow"},
+		{[]string{"verify-token", "--token", tok, "--request"}, "500", exitDeny, "deny"},
+	} {
+		req := write(t, dir, "req.json", `{"amount": `+tc.amount+`}`)
+		args := append([]string{tc.cmd[0], "--output", "json"}, append(tc.cmd[1:], req)...)
+		code, out, errOut := agentSafe(t, args...)
+		if code != tc.code {
+			t.Fatalf("%v: exit %d, want %d: %s", args, code, tc.code, errOut)
+		}
+		var r result
+		if err := json.Unmarshal([]byte(out), &r); err != nil {
+			t.Fatalf("%v: %v in %q", args, err, out)
+		}
+		if r.Decision != tc.decision || r.GasUsed == 0 || r.DurationUS == nil {
+			t.Fatalf("%v: unexpected result %s", args, out)
+		}
+	}
+
+	// Errors are reported as JSON on stdout with exit 2.
+	code, out, _ := agentSafe(t, "verify", "--output", "json", policy, filepath.Join(dir, "missing.json"))
+	var e struct{ Error string }
+	if code != exitError || json.Unmarshal([]byte(out), &e) != nil || e.Error == "" {
+		t.Fatalf("expected a JSON error with exit 2, got %d %q", code, out)
+	}
+
+	var insp struct {
+		Token     map[string]any `json:"token"`
+		Signature string         `json:"signature"`
+	}
+	if err := json.Unmarshal([]byte(mustRun(t, "inspect", "--output", "json", tok)), &insp); err != nil || insp.Signature != "valid" || insp.Token["policy"] == nil {
+		t.Fatalf("unexpected inspect JSON: %+v %v", insp, err)
+	}
+}
+
+func TestKeygenFormats(t *testing.T) {
+	dir := t.TempDir()
+	policy := write(t, dir, "policy.spl", `(= 1 1)`)
+	for _, format := range []string{"jwk", "pem"} {
+		key := write(t, dir, "key."+format, mustRun(t, "keygen", "--alg", "ES256", "--format", format))
+		out := mustRun(t, "mint", "--policy", policy, "--key", key)
+		if !strings.Contains(out, `"alg": "ES256"`) {
+			t.Fatalf("%s: expected ES256 token, got %s", format, out)
+		}
+	}
+	if out := mustRun(t, "keygen", "--alg", "X25519"); !strings.Contains(out, `"alg": "X25519"`) {
+		t.Fatalf("unexpected X25519 keygen output %s", out)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+
+	"github.com/jmcentire/agent-safe/sdk/go/mcpguard"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// cmdMCPGuard runs an MCP stdio tool server behind mcpguard: the client
+// talks to agent-safe on stdin and stdout, and every tools/call is checked
+// against the token before it reaches the server. Decisions are logged to
+// stderr, which MCP clients keep out of the protocol stream.
+func cmdMCPGuard(c *cli, args []string) error {
+	fs := c.flags("mcp-guard")
+	tokenPath := fs.String("token", "", "the agent's token file")
+	caller := fs.String("caller", "", "caller identity for policies (default the client's clientInfo name)")
+	f := addEvalFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+	env, err := f.load()
+	if err != nil {
+		return err
+	}
+	// The server's stderr is copied from another goroutine.
+	stderr := &lockedWriter{w: c.stderr}
+	g := &mcpguard.Guard{
+		Verify:  env.verifyOptions(),
+		Revoked: env.revocations.revoked,
+		Caller:  *caller,
+		OnDecision: func(call mcpguard.Call, res spl.VerifyTokenResult) {
+			if res.Allow {
+				fmt.Fprintf(stderr, "mcp-guard: ALLOW %s\n", call.Tool)
+			} else {
+				fmt.Fprintf(stderr, "mcp-guard: DENY %s: %s\n", call.Tool, orDash(res.ErrorMessage()))
+			}
+		},
+	}
+	if *tokenPath != "" {
+		if g.Token, err = c.loadToken(*tokenPath); err != nil {
+			return err
+		}
+	}
+
+	server := exec.Command(fs.Arg(0), fs.Args()[1:]...)
+	server.Stderr = stderr
+	serverIn, err := server.StdinPipe()
+	if err != nil {
+		return err
+	}
+	serverOut, err := server.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := server.Start(); err != nil {
+		return err
+	}
+	proxyErr := g.Proxy(c.stdin, c.stdout, serverIn, serverOut)
+	if err := server.Wait(); err != nil && proxyErr == nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	return proxyErr
+}
+
+// lockedWriter serializes writes to w.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMCPGuard(t *testing.T) {
+	dir := t.TempDir()
+	issuer := write(t, dir, "issuer.json", mustRun(t, "keygen"))
+	policy := write(t, dir, "policy.spl", `(and (= (get req "tool") "read_file") (= (get req "caller") "editor"))`)
+	tok := write(t, dir, "token.json", mustRun(t, "mint", "--policy", policy, "--key", issuer))
+
+	// cat stands in for the tool server, echoing what reaches it.
+	in := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"clientInfo":{"name":"editor"}}}
+{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"read_file","arguments":{"path":"a.txt"}}}
+{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"delete_file","arguments":{"path":"a.txt"}}}
+`
+	code, out, errOut := agentSafeStdin(t, in, "mcp-guard", "--token", tok, "--", "cat")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, errOut)
+	}
+	if !strings.Contains(out, `"name":"read_file"`) || strings.Contains(out, `"name":"delete_file"`) {
+		t.Fatalf("expected only the allowed call to reach the server:\n%s", out)
+	}
+	if !strings.Contains(out, `"isError":true`) {
+		t.Fatalf("expected a tool error for the denied call:\n%s", out)
+	}
+	if !strings.Contains(errOut, "ALLOW read_file") || !strings.Contains(errOut, "DENY delete_file") {
+		t.Fatalf("expected decisions on stderr, got %q", errOut)
+	}
+
+	if code, _, _ := agentSafe(t, "mcp-guard", "--token", tok); code != exitError {
+		t.Fatalf("expected a usage error without a server command, got %d", code)
+	}
+}
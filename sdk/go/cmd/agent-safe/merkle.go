@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// cmdMerkleAllowlist commits to the values in a file, one per line, and
+// prints the root for mint --merkle-root with every value's witness. The
+// agent keeps the witnesses and presents the one it needs with
+// verify-token --witnesses.
+func cmdMerkleAllowlist(c *cli, args []string) error {
+	fs := c.flags("merkle-allowlist")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	data, err := c.readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var values []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if v := strings.TrimSpace(line); v != "" {
+			values = append(values, v)
+		}
+	}
+	a, err := spl.NewMerkleAllowlist(values)
+	if err != nil {
+		return err
+	}
+	return writeJSON(c, a)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jmcentire/agent-safe/sdk/go/internal/yaml"
+	"github.com/jmcentire/agent-safe/sdk/go/openapi"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// cmdOpenAPI prints the policy that allows the named operations of an
+// OpenAPI document. --scope adds operations with bounds on their fields,
+// from a JSON or YAML list of {"operation": ..., "bounds": {...}}.
+func cmdOpenAPI(c *cli, args []string) error {
+	fs := c.flags("openapi")
+	scopePath := fs.String("scope", "", "JSON or YAML file listing operations and bounds on their fields")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || (fs.NArg() == 1 && *scopePath == "") {
+		return errUsage
+	}
+	data, err := c.readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	doc, err := openapi.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", inputName(fs.Arg(0)), err)
+	}
+	var scope []openapi.Scope
+	if *scopePath != "" {
+		b, err := c.readInput(*scopePath)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(b, &scope); err != nil {
+			return fmt.Errorf("%s: %w", inputName(*scopePath), err)
+		}
+	}
+	for _, op := range fs.Args()[1:] {
+		scope = append(scope, openapi.Scope{Operation: op})
+	}
+	p, err := doc.Policy(scope...)
+	if err != nil {
+		return err
+	}
+	src, err := p.Source()
+	if err != nil {
+		return err
+	}
+	if src, err = spl.Format(src); err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(c, map[string]string{"policy": src})
+	}
+	_, err = io.WriteString(c.stdout, src)
+	return err
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+	"github.com/jmcentire/agent-safe/sdk/go/spltest"
+)
+
+// fileDiagnostic is a lint finding in a named policy file.
+type fileDiagnostic struct {
+	File string `json:"file"`
+	spl.Diagnostic
+}
+
+func cmdLint(c *cli, args []string) error {
+	fs := c.flags("lint")
+	failOn := fs.String("fail-on", "error", "lowest severity that fails the run: info, warning, or error")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	threshold, err := spl.ParseSeverity(*failOn)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+	diags := []fileDiagnostic{}
+	for _, path := range fs.Args() {
+		src, err := c.readInput(path)
+		if err != nil {
+			return err
+		}
+		for _, d := range spl.Lint(string(src)) {
+			diags = append(diags, fileDiagnostic{inputName(path), d})
+		}
+	}
+	failed := false
+	for _, d := range diags {
+		failed = failed || d.Severity >= threshold
+	}
+	if c.json {
+		if err := writeJSON(c, diags); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range diags {
+			fmt.Fprintf(c.stdout, "%s:%s\n", d.File, d.Diagnostic)
+			if d.Expr != "" {
+				fmt.Fprintf(c.stdout, "    %s\n", d.Expr)
+			}
+		}
+	}
+	if failed {
+		// Findings at or above --fail-on fail the check like a deny.
+		return errDenied
+	}
+	return nil
+}
+
+func cmdFmt(c *cli, args []string) error {
+	fs := c.flags("fmt")
+	write := fs.Bool("w", false, "write the result back to each file instead of stdout")
+	check := fs.Bool("check", false, "list files that are not formatted and exit 1 if there are any")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *write && *check {
+		return errUsage
+	}
+	if fs.NArg() == 0 {
+		if *write || *check {
+			return errUsage
+		}
+		src, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return err
+		}
+		out, err := spl.Format(string(src))
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(c.stdout, out)
+		return err
+	}
+	unformatted := []string{}
+	for _, path := range fs.Args() {
+		if path == "-" && *write {
+			return fmt.Errorf("-w cannot rewrite standard input")
+		}
+		src, err := c.readInput(path)
+		if err != nil {
+			return err
+		}
+		out, err := spl.Format(string(src))
+		if err != nil {
+			return fmt.Errorf("%s:%w", inputName(path), err)
+		}
+		switch {
+		case *check:
+			if out != string(src) {
+				unformatted = append(unformatted, inputName(path))
+			}
+		case *write:
+			if out != string(src) {
+				if err := os.WriteFile(path, []byte(out), 0o644); err != nil {
+					return err
+				}
+			}
+		default:
+			if _, err := io.WriteString(c.stdout, out); err != nil {
+				return err
+			}
+		}
+	}
+	if !*check {
+		return nil
+	}
+	if c.json {
+		if err := writeJSON(c, map[string][]string{"unformatted": unformatted}); err != nil {
+			return err
+		}
+	} else {
+		for _, path := range unformatted {
+			fmt.Fprintln(c.stdout, path)
+		}
+	}
+	if len(unformatted) > 0 {
+		return errDenied
+	}
+	return nil
+}
+
+func cmdConvert(c *cli, args []string) error {
+	fs := c.flags("convert")
+	from := fs.String("from", "spl", "language of the input: spl, cedar, or rego")
+	to := fs.String("to", "", "language of the output: spl, cedar, or rego")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || *to == "" {
+		return errUsage
+	}
+	path := "-"
+	if fs.NArg() == 1 {
+		path = fs.Arg(0)
+	}
+	src, err := c.readInput(path)
+	if err != nil {
+		return err
+	}
+	// Go through SPL: read the input into it, then write it out.
+	res := &spl.Translation{Policy: string(src)}
+	switch *from {
+	case "spl":
+	case "cedar":
+		res, err = spl.FromCedar(string(src))
+	case "rego":
+		res, err = spl.FromRego(string(src))
+	default:
+		return fmt.Errorf("unknown language %q", *from)
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%w", inputName(path), err)
+	}
+	var out *spl.Translation
+	switch *to {
+	case "spl":
+		out = &spl.Translation{}
+		out.Policy, err = spl.Format(res.Policy)
+	case "cedar":
+		out, err = spl.ToCedar(res.Policy)
+	case "rego":
+		out, err = spl.ToRego(res.Policy)
+	default:
+		return fmt.Errorf("unknown language %q", *to)
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%w", inputName(path), err)
+	}
+	out.Warnings = append(res.Warnings, out.Warnings...)
+	if c.json {
+		return writeJSON(c, out)
+	}
+	for _, w := range out.Warnings {
+		fmt.Fprintf(c.stderr, "%s: %s\n", inputName(path), w)
+	}
+	_, err = io.WriteString(c.stdout, out.Policy)
+	return err
+}
+
+func cmdTest(c *cli, args []string) error {
+	fs := c.flags("test")
+	verbose := fs.Bool("v", false, "list every case, not just failures")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := spltest.Find(paths...)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no test suites (*_test.yaml) found")
+	}
+	all := []spltest.Result{}
+	failed := 0
+	for _, path := range files {
+		s, err := spltest.Load(path)
+		if err != nil {
+			return err
+		}
+		results, err := s.Run()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		all = append(all, results...)
+		fails := 0
+		for _, r := range results {
+			if !r.Pass {
+				fails++
+			}
+			if c.json || !(*verbose || !r.Pass) {
+				continue
+			}
+			if r.Pass {
+				fmt.Fprintf(c.stdout, "    ok   %s\n", r.Case)
+			} else {
+				fmt.Fprintf(c.stdout, "    FAIL %s\n", r)
+			}
+		}
+		failed += fails
+		if !c.json {
+			status := "ok  "
+			if fails > 0 {
+				status = "FAIL"
+			}
+			fmt.Fprintf(c.stdout, "%s %s\t%d cases\n", status, path, len(results))
+		}
+	}
+	if c.json {
+		if err := writeJSON(c, all); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return errDenied
+	}
+	return nil
+}
+
+// cmdSimulate replays recorded requests against an old and a new policy and
+// lists the decisions that flip.
+func cmdSimulate(c *cli, args []string) error {
+	fs := c.flags("simulate")
+	failOnFlip := fs.Bool("fail-on-flip", false, "exit 1 if any decision flips")
+	f := addEvalFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 3 {
+		return errUsage
+	}
+	oldSrc, err := c.readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	newSrc, err := c.readInput(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	archive, err := c.readInput(fs.Arg(2))
+	if err != nil {
+		return err
+	}
+	env, err := f.load()
+	if err != nil {
+		return err
+	}
+	if env.now != "" {
+		env.vars["now"] = env.now
+	}
+	r, err := spl.Simulate(string(oldSrc), string(newSrc), bytes.NewReader(archive), spl.Env{
+		Vars:         env.vars,
+		RequiredVars: env.required,
+		PerDayCount:  env.perDayCount,
+		Crypto:       env.crypto,
+	})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		if err := writeJSON(c, r); err != nil {
+			return err
+		}
+	} else {
+		base := filepath.Base(fs.Arg(2))
+		if fs.Arg(2) == "-" {
+			base = "stdin"
+		}
+		for _, fl := range r.Flips {
+			sign, change, reason := "-", "allow -> deny", fl.NewError
+			if fl.NewAllow {
+				sign, change, reason = "+", "deny -> allow", fl.OldError
+			}
+			req, _ := json.Marshal(fl.Request)
+			fmt.Fprintf(c.stdout, "%s %s:%d\t%s\t%s", sign, base, fl.Line, change, req)
+			if reason != "" {
+				fmt.Fprintf(c.stdout, "\t(%s)", reason)
+			}
+			fmt.Fprintln(c.stdout)
+		}
+		fmt.Fprintf(c.stdout, "%d requests: %d allowed before, %d after; %d newly allowed, %d newly denied",
+			r.Requests, r.OldAllow, r.NewAllow, r.NewlyAllowed, r.NewlyDenied)
+		if r.Invalid > 0 {
+			fmt.Fprintf(c.stdout, "; %d invalid lines skipped", r.Invalid)
+		}
+		fmt.Fprintln(c.stdout)
+	}
+	if *failOnFlip && len(r.Flips) > 0 {
+		return errDenied
+	}
+	return nil
+}
+
+// cmdMutate runs each suite against mutants of its policy and lists the
+// mutants no case catches, the clauses the suite leaves untested.
+func cmdMutate(c *cli, args []string) error {
+	fs := c.flags("mutate")
+	verbose := fs.Bool("v", false, "list every mutant, not just survivors")
+	minScore := fs.Float64("min-score", 0, "exit 1 if a suite kills less than this fraction of its mutants")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := spltest.Find(paths...)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no test suites (*_test.yaml) found")
+	}
+	reports := []*spltest.MutationReport{}
+	low := false
+	for _, path := range files {
+		s, err := spltest.Load(path)
+		if err != nil {
+			return err
+		}
+		r, err := s.Mutate()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		reports = append(reports, r)
+		low = low || r.Score() < *minScore
+		if c.json {
+			continue
+		}
+		for _, m := range r.Mutants {
+			if !m.Killed() {
+				fmt.Fprintf(c.stdout, "    survived %s\n", m)
+			} else if *verbose {
+				fmt.Fprintf(c.stdout, "    killed   %s (by %s)\n", m, m.KilledBy)
+			}
+		}
+		fmt.Fprintf(c.stdout, "%s\t%d/%d mutants killed (%.0f%%)\n", path, r.Killed, len(r.Mutants), 100*r.Score())
+	}
+	if c.json {
+		if err := writeJSON(c, reports); err != nil {
+			return err
+		}
+	}
+	if low {
+		return errDenied
+	}
+	return nil
+}
+
+func cmdDiff(c *cli, args []string) error {
+	fs := c.flags("diff")
+	varsPath := fs.String("vars", "", "YAML or JSON config whose vars are substituted into both policies")
+	prove := fs.Bool("prove-narrower", false, "exit 1 unless the new policy provably allows nothing the old one denies")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errUsage
+	}
+	oldSrc, err := c.readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	newSrc, err := c.readInput(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	vars, err := loadVars(*varsPath)
+	if err != nil {
+		return err
+	}
+	d, err := spl.DiffPolicies(string(oldSrc), string(newSrc), vars)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		if err := writeJSON(c, d); err != nil {
+			return err
+		}
+	} else {
+		for _, ch := range d.Changes {
+			switch ch.Kind {
+			case "added":
+				fmt.Fprintf(c.stdout, "+ added      %s\n", ch.New)
+			case "removed":
+				fmt.Fprintf(c.stdout, "- removed    %s\n", ch.Old)
+			default:
+				fmt.Fprintf(c.stdout, "~ %-10s %s\n             => %s\n", ch.Kind, ch.Old, ch.New)
+			}
+			if ch.Detail != "" {
+				fmt.Fprintf(c.stdout, "             %s\n", ch.Detail)
+			}
+		}
+		if d.Narrower {
+			fmt.Fprintln(c.stdout, "new policy is narrower: proved")
+		} else {
+			fmt.Fprintln(c.stdout, "new policy is narrower: not proved; the new policy does not imply:")
+			for _, u := range d.Unproven {
+				fmt.Fprintf(c.stdout, "    %s\n", u)
+			}
+		}
+	}
+	if *prove && !d.Narrower {
+		return errDenied
+	}
+	return nil
+}
+
+func cmdGenRequests(c *cli, args []string) error {
+	fs := c.flags("gen-requests")
+	policyPath := fs.String("policy", "", "SPL policy file, or - for stdin")
+	count := fs.Int("count", 100, "maximum number of requests to generate")
+	seed := fs.Int64("seed", 1, "seed for the random combinations beyond the boundary cases")
+	varsPath := fs.String("vars", "", "YAML or JSON config whose vars resolve limits and lists")
+	outDir := fs.String("out", "", "write one JSON file per request to this directory instead of JSONL to stdout")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *policyPath == "" || fs.NArg() != 0 || *count < 1 {
+		return errUsage
+	}
+	src, err := c.readInput(*policyPath)
+	if err != nil {
+		return err
+	}
+	ast, err := spl.Parse(string(src))
+	if err != nil {
+		return fmt.Errorf("policy: %w", err)
+	}
+	vars, err := loadVars(*varsPath)
+	if err != nil {
+		return err
+	}
+	reqs := spltest.GenerateRequests(ast, vars, *count, *seed)
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			return err
+		}
+		for i, r := range reqs {
+			var buf bytes.Buffer
+			if err := encodeJSON(&buf, r.Request); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(*outDir, fmt.Sprintf("%03d.json", i+1)), buf.Bytes(), 0o644); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(c.stderr, "wrote %d requests to %s\n", len(reqs), *outDir)
+		return nil
+	}
+	if c.json {
+		return writeJSON(c, reqs)
+	}
+	// One compact request per line, as verify --requests reads JSONL.
+	enc := json.NewEncoder(c.stdout)
+	enc.SetEscapeHTML(false)
+	for _, r := range reqs {
+		if err := enc.Encode(r.Request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
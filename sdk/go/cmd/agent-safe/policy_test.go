@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	clean := write(t, dir, "clean.spl", `(<= (get req "amount") 100)`)
+	warn := write(t, dir, "warn.spl", "(and\n  (<= (get req \"amount\") 100)\n  (<= (get req \"amount\") 100))")
+	broken := write(t, dir, "broken.spl", `(or (frobnicate) (get req "ok"))`)
+
+	if out := mustRun(t, "lint", clean); out != "" {
+		t.Fatalf("expected no output for a clean policy, got %q", out)
+	}
+	out := mustRun(t, "lint", warn)
+	if !strings.Contains(out, "warn.spl:3:3: warning: duplicate and operand [duplicate]") {
+		t.Fatalf("unexpected lint output:\n%s", out)
+	}
+	if code, _, _ := agentSafe(t, "lint", "--fail-on", "warning", clean, warn); code != exitDeny {
+		t.Fatalf("expected --fail-on warning to fail, exit %d", code)
+	}
+	code, out, _ := agentSafe(t, "lint", "--output", "json", clean, broken)
+	var diags []fileDiagnostic
+	if code != exitDeny || json.Unmarshal([]byte(out), &diags) != nil || len(diags) != 1 || diags[0].Code != "unknown-op" {
+		t.Fatalf("expected one unknown-op error, got %d %s", code, out)
+	}
+	if code, _, _ := agentSafe(t, "lint", "--fail-on", "fatal", clean); code != exitError {
+		t.Fatalf("expected a bad --fail-on to be an error, exit %d", code)
+	}
+}
+
+func TestFmt(t *testing.T) {
+	dir := t.TempDir()
+	messy := write(t, dir, "messy.spl", "(and  (get req \"ok\")\n   (not (get req \"blocked\")))")
+	tidy := write(t, dir, "tidy.spl", "(get req \"ok\")\n")
+
+	if out := mustRun(t, "fmt", messy); out != "(and (get req \"ok\") (not (get req \"blocked\")))\n" {
+		t.Fatalf("unexpected fmt output %q", out)
+	}
+	code, out, _ := agentSafe(t, "fmt", "-check", messy, tidy)
+	if code != exitDeny || out != messy+"\n" {
+		t.Fatalf("expected -check to list only %s and exit 1, got %d %q", messy, code, out)
+	}
+	mustRun(t, "fmt", "-w", messy, tidy)
+	if out := mustRun(t, "fmt", "-check", messy, tidy); out != "" {
+		t.Fatalf("expected files to be formatted after -w, got %q", out)
+	}
+	bad := write(t, dir, "bad.spl", "(and x")
+	if code, _, errOut := agentSafe(t, "fmt", bad); code != exitError || !strings.Contains(errOut, "bad.spl:1:1: unterminated (") {
+		t.Fatalf("expected a positioned syntax error, got %d %q", code, errOut)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	policy := write(t, dir, "policy.spl", `(and (= (get req "action") "read") (before now "2026-12-31T00:00:00Z"))`)
+
+	code, cedar, errOut := agentSafe(t, "convert", "--to", "cedar", policy)
+	if code != 0 || !strings.Contains(cedar, `context.action == "read"`) || !strings.Contains(errOut, "context.vars") {
+		t.Fatalf("unexpected Cedar translation %d:\n%s%s", code, cedar, errOut)
+	}
+	code, rego, _ := agentSafeStdin(t, cedar, "convert", "--from", "cedar", "--to", "rego")
+	if code != 0 || !strings.Contains(rego, "default allow := false") {
+		t.Fatalf("unexpected Rego translation %d:\n%s", code, rego)
+	}
+	code, back, _ := agentSafeStdin(t, rego, "convert", "--from", "rego", "--to", "spl", "-")
+	if code != 0 || back != "(and (= (get req \"action\") \"read\") (before now \"2026-12-31T00:00:00Z\"))\n" {
+		t.Fatalf("unexpected round trip %d:\n%s", code, back)
+	}
+	if code, _, _ := agentSafe(t, "convert", policy); code != exitError {
+		t.Fatalf("expected --to to be required, exit %d", code)
+	}
+	frac := write(t, dir, "frac.spl", `(<= (get req "amount") 0.5)`)
+	if code, _, errOut := agentSafe(t, "convert", "--to", "cedar", frac); code != exitError || !strings.Contains(errOut, "frac.spl:1:24:") {
+		t.Fatalf("expected a positioned error, got %d %q", code, errOut)
+	}
+}
+
+func TestPolicyTests(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "limit.spl", `(<= (get req "amount") 100)`)
+	write(t, dir, "limit_test.yaml", `policy: limit.spl
+cases:
+  - {name: small, request: {amount: 5}, expect: allow}
+  - {name: large, request: {amount: 500}, expect: deny}
+`)
+	write(t, dir, "notes.yaml", "not: a suite\n")
+	if out := mustRun(t, "test", dir); !strings.HasPrefix(out, "ok   ") || !strings.Contains(out, "2 cases") {
+		t.Fatalf("unexpected test output %q", out)
+	}
+
+	write(t, dir, "broken_test.yaml", `policy: limit.spl
+cases:
+  - {name: wrong, request: {amount: 500}, expect: allow}
+`)
+	code, out, _ := agentSafe(t, "test", dir)
+	if code != exitDeny || !strings.Contains(out, "FAIL wrong: expected allow, got deny") {
+		t.Fatalf("expected a failing case, got %d %q", code, out)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	dir := t.TempDir()
+	old := write(t, dir, "old.spl", `(<= (get req "amount") limit)`)
+	tight := write(t, dir, "new.spl", `(<= (get req "amount") 50)`)
+	vars := write(t, dir, "vars.yaml", "vars: {limit: 100}\n")
+	traffic := write(t, dir, "traffic.jsonl", "{\"amount\": 20}\n{\"amount\": 75}\n{\"amount\": 500}\n")
+
+	out := mustRun(t, "simulate", "--vars", vars, old, tight, traffic)
+	if !strings.Contains(out, "- traffic.jsonl:2\tallow -> deny\t{\"amount\":75}") ||
+		!strings.Contains(out, "3 requests: 2 allowed before, 1 after; 0 newly allowed, 1 newly denied") {
+		t.Fatalf("unexpected simulate output %q", out)
+	}
+	if code, _, _ := agentSafe(t, "simulate", "--fail-on-flip", "--vars", vars, old, tight, traffic); code != exitDeny {
+		t.Fatalf("expected a flip to exit %d, got %d", exitDeny, code)
+	}
+	if code, _, _ := agentSafe(t, "simulate", "--fail-on-flip", "--vars", vars, old, old, traffic); code != 0 {
+		t.Fatalf("expected no flips to exit 0, got %d", code)
+	}
+}
+
+func TestMutate(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "limit.spl", `(and (= (get req "action") "pay") (<= (get req "amount") 100))`)
+	write(t, dir, "limit_test.yaml", `policy: limit.spl
+cases:
+  - {name: at limit, request: {action: pay, amount: 100}, expect: allow}
+  - {name: over limit, request: {action: pay, amount: 101}, expect: deny}
+  - {name: refund, request: {action: refund, amount: 5}, expect: deny}
+`)
+	out := mustRun(t, "mutate", dir)
+	if !strings.Contains(out, "5/5 mutants killed (100%)") || strings.Contains(out, "survived") {
+		t.Fatalf("unexpected mutate output %q", out)
+	}
+
+	write(t, dir, "limit_test.yaml", `policy: limit.spl
+cases:
+  - {name: at limit, request: {action: pay, amount: 100}, expect: allow}
+`)
+	out = mustRun(t, "mutate", dir)
+	if !strings.Contains(out, "survived drop_clause: dropped (<= (get req \"amount\") 100)") || !strings.Contains(out, "1/5 mutants killed") {
+		t.Fatalf("expected surviving mutants, got %q", out)
+	}
+	if code, _, _ := agentSafe(t, "mutate", "--min-score", "0.8", dir); code != exitDeny {
+		t.Fatalf("expected a low score to exit %d, got %d", exitDeny, code)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	old := write(t, dir, "old.spl", `(and (<= (get req "amount") 50) (member (get req "to") family))`)
+	narrow := write(t, dir, "narrow.spl", `(and (<= (get req "amount") 25) (member (get req "to") (tuple "mom")) (dpop_ok?))`)
+	vars := write(t, dir, "vars.yaml", "family: [mom, niece]\n")
+
+	out := mustRun(t, "diff", "--vars", vars, "--prove-narrower", old, narrow)
+	for _, want := range []string{"~ tightened  (<= (get req \"amount\") 50)", "-niece", "+ added      (dpop_ok?)", "narrower: proved"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in diff output:\n%s", want, out)
+		}
+	}
+	code, out, _ := agentSafe(t, "diff", "--vars", vars, "--prove-narrower", narrow, old)
+	if code != exitDeny || !strings.Contains(out, "~ loosened") || !strings.Contains(out, "    (dpop_ok?)") {
+		t.Fatalf("expected widening to fail --prove-narrower, got %d:\n%s", code, out)
+	}
+}
+
+func TestGenRequests(t *testing.T) {
+	dir := t.TempDir()
+	policy := write(t, dir, "limit.spl", `(and (<= (get req "amount") 100) (member (get req "to") (tuple "a" "b")))`)
+	out := mustRun(t, "gen-requests", "--policy", policy, "--count", "5")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 5 || lines[0] != `{"amount":100,"to":"a"}` {
+		t.Fatalf("unexpected requests %q", out)
+	}
+	jsonl := write(t, dir, "reqs.jsonl", out)
+	if code, out, _ := agentSafe(t, "verify", "--policy", policy, "--requests", jsonl); code != exitDeny || !strings.Contains(out, "5 requests: 4 allow, 1 deny") {
+		t.Fatalf("expected the requests to feed verify, got %d %q", code, out)
+	}
+
+	var reqs []struct {
+		Name    string         `json:"name"`
+		Request map[string]any `json:"request"`
+	}
+	if err := json.Unmarshal([]byte(mustRun(t, "gen-requests", "--output", "json", "--policy", policy, "--count", "3")), &reqs); err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 3 || reqs[0].Name != "baseline" {
+		t.Fatalf("unexpected JSON output %+v", reqs)
+	}
+
+	mustRun(t, "gen-requests", "--policy", policy, "--out", filepath.Join(dir, "corpus"))
+	if _, err := os.Stat(filepath.Join(dir, "corpus", "001.json")); err != nil {
+		t.Fatal(err)
+	}
+}
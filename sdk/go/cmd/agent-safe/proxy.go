@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+	"github.com/jmcentire/agent-safe/sdk/go/splhttp"
+)
+
+// Headers the proxy sets on requests it forwards, after removing any the
+// client sent with the same names.
+const (
+	// verifiedTokenHeader carries the signature of the token that allowed
+	// the request, for upstream logs.
+	verifiedTokenHeader = "Agent-Safe-Verified-Token"
+	// verifiedIssuerHeader carries the allowing token's kid, or its public
+	// key when it has none.
+	verifiedIssuerHeader = "Agent-Safe-Verified-Issuer"
+)
+
+// newProxyHandler serves serve --upstream: every request is described to
+// the token's policy as splhttp.Middleware describes it and, if allowed,
+// forwarded to upstream without its Agent-Safe headers. Refusals follow
+// the middleware too: 401 without a token, 400 for a malformed one, 413
+// for an oversized body and 403 for a deny, each with a splhttp.Denial
+// body. With keepToken the token headers are forwarded as well, for
+// upstreams that verify or attenuate it themselves.
+func newProxyHandler(env *evalEnv, upstream string, keepToken bool, logf func(string, ...any)) (http.Handler, error) {
+	u, err := url.Parse(upstream)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("--upstream: want an http or https URL, got %q", upstream)
+	}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(u)
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logf("upstream %s %s: %v", r.Method, r.URL.Path, err)
+			proxyDeny(w, http.StatusBadGateway, "bad_gateway", "upstream unavailable")
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw := splhttp.TokenFromRequest(r)
+		if raw == "" {
+			w.Header().Set("WWW-Authenticate", "AgentSafe")
+			proxyDeny(w, http.StatusUnauthorized, "unauthorized", "no Agent-Safe token")
+			return
+		}
+		tok, err := spl.ParseToken(raw)
+		if err != nil {
+			proxyDeny(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		req, err := splhttp.RequestToMap(r, splhttp.MapOptions{})
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				proxyDeny(w, http.StatusRequestEntityTooLarge, "bad_request", "request body too large")
+			} else if errors.Is(err, splhttp.ErrUnsupportedBody) {
+				proxyDeny(w, http.StatusUnsupportedMediaType, "unsupported_media_type", err.Error())
+			} else {
+				proxyDeny(w, http.StatusBadRequest, "bad_request", err.Error())
+			}
+			return
+		}
+		d := env.verifyToken(tok, req, r.Header.Get(splhttp.PresentationHeader))
+		if !d.Allow {
+			reason := d.Reason
+			if reason == "" {
+				reason = "policy denied the request"
+			}
+			proxyDeny(w, http.StatusForbidden, "forbidden", reason)
+			return
+		}
+		out := r.Clone(r.Context())
+		stripAgentSafeHeaders(out.Header, keepToken)
+		out.Header.Set(verifiedTokenHeader, tok.Signature)
+		issuer := tok.KeyID
+		if issuer == "" {
+			issuer = tok.PublicKey
+		}
+		out.Header.Set(verifiedIssuerHeader, issuer)
+		proxy.ServeHTTP(w, out)
+	}), nil
+}
+
+// stripAgentSafeHeaders removes the Agent-Safe-* headers, so a client
+// cannot pass off its own verified headers, and the Authorization header
+// when it carries the token.
+func stripAgentSafeHeaders(h http.Header, keepToken bool) {
+	for name := range h {
+		if !strings.HasPrefix(name, "Agent-Safe-") {
+			continue
+		}
+		if keepToken && (name == splhttp.TokenHeader || name == splhttp.PresentationHeader) {
+			continue
+		}
+		h.Del(name)
+	}
+	if scheme, _, _ := strings.Cut(h.Get("Authorization"), " "); !keepToken &&
+		(strings.EqualFold(scheme, "AgentSafe") || strings.EqualFold(scheme, "Bearer")) {
+		h.Del("Authorization")
+	}
+}
+
+func proxyDeny(w http.ResponseWriter, status int, code, reason string) {
+	httpJSON(w, status, splhttp.Denial{Error: code, Reason: reason})
+}
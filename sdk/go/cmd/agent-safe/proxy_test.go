@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/splhttp"
+)
+
+func TestServeProxy(t *testing.T) {
+	dir := t.TempDir()
+	issuer := write(t, dir, "issuer.json", mustRun(t, "keygen"))
+	policy := write(t, dir, "policy.spl", `(and (= (get req "method") "POST") (<= (get (get req "body") "amount") 100))`)
+	tok := strings.TrimSpace(mustRun(t, "mint", "--policy", policy, "--key", issuer, "--format", "compact"))
+	cfg := write(t, dir, "verifier.yaml", "trust:\n  keys:\n    - file: issuer.json\n")
+
+	var seen *http.Request
+	var seenBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		seen, seenBody = r, string(b)
+		w.Write([]byte("paid"))
+	}))
+	defer upstream.Close()
+
+	proxy := func(keepToken bool) *httptest.Server {
+		h, err := newProxyHandler(loadEnv(t, "--vars", cfg), upstream.URL+"/api", keepToken, t.Logf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return httptest.NewServer(h)
+	}
+	srv := proxy(false)
+	defer srv.Close()
+
+	send := func(srv *httptest.Server, method, body string, header map[string]string) (int, string) {
+		t.Helper()
+		seen = nil
+		req, _ := http.NewRequest(method, srv.URL+"/payments?id=7", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	code, body := send(srv, "POST", `{"amount": 50}`, map[string]string{
+		"Authorization":              "AgentSafe " + tok,
+		"Agent-Safe-Verified-Issuer": "forged",
+		"X-Request-Id":               "r1",
+	})
+	if code != http.StatusOK || body != "paid" || seen == nil {
+		t.Fatalf("expected the allowed request upstream, got %d %q", code, body)
+	}
+	if seen.URL.Path != "/api/payments" || seen.URL.RawQuery != "id=7" || seenBody != `{"amount": 50}` ||
+		seen.Header.Get("X-Request-Id") != "r1" || seen.Header.Get("X-Forwarded-For") == "" {
+		t.Fatalf("request not forwarded intact: %s %s %q %v", seen.Method, seen.URL, seenBody, seen.Header)
+	}
+	if seen.Header.Get("Authorization") != "" || seen.Header.Get(verifiedTokenHeader) == "" ||
+		seen.Header.Get(verifiedIssuerHeader) == "forged" || seen.Header.Get(verifiedIssuerHeader) == "" {
+		t.Fatalf("expected token headers stripped and verified headers set, got %v", seen.Header)
+	}
+
+	for _, tc := range []struct {
+		name, method, body string
+		header             map[string]string
+		code               int
+		reason             string
+	}{
+		{"deny", "POST", `{"amount": 500}`, map[string]string{splhttp.TokenHeader: tok}, http.StatusForbidden, "policy denied the request"},
+		{"no token", "POST", `{"amount": 5}`, nil, http.StatusUnauthorized, "no Agent-Safe token"},
+		{"malformed token", "POST", `{"amount": 5}`, map[string]string{splhttp.TokenHeader: "{"}, http.StatusBadRequest, ""},
+		{"body not JSON", "POST", `{"amount": 500}`, map[string]string{splhttp.TokenHeader: tok, "Content-Type": "text/plain"}, http.StatusUnsupportedMediaType, ""},
+	} {
+		code, body := send(srv, tc.method, tc.body, tc.header)
+		var d splhttp.Denial
+		if code != tc.code || json.Unmarshal([]byte(body), &d) != nil || (tc.reason != "" && d.Reason != tc.reason) || seen != nil {
+			t.Errorf("%s: got %d %s", tc.name, code, body)
+		}
+	}
+
+	kept := proxy(true)
+	defer kept.Close()
+	if code, _ := send(kept, "POST", `{"amount": 5}`, map[string]string{splhttp.TokenHeader: tok}); code != http.StatusOK || seen.Header.Get(splhttp.TokenHeader) != tok {
+		t.Fatalf("expected --forward-token to keep the token header, got %d %v", code, seen)
+	}
+
+	upstream.Close()
+	if code, body := send(srv, "POST", `{"amount": 5}`, map[string]string{splhttp.TokenHeader: tok}); code != http.StatusBadGateway {
+		t.Fatalf("expected 502 with the upstream down, got %d %s", code, body)
+	}
+	if _, err := newProxyHandler(loadEnv(t, "--vars", cfg), "localhost:8080", false, t.Logf); err == nil {
+		t.Fatal("expected a URL without a scheme to be refused")
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// maxVerifyBody bounds a /v1/verify request body.
+const maxVerifyBody = 1 << 20
+
+func cmdServe(c *cli, args []string) error {
+	fs := c.flags("serve")
+	addr := fs.String("addr", "127.0.0.1:8080", "listen address")
+	anyIssuer := fs.Bool("allow-any-issuer", false, "accept self-signed tokens from any issuer when the config has no trust section")
+	upstream := fs.String("upstream", "", "run as a reverse proxy, forwarding allowed requests to this URL")
+	keepToken := fs.Bool("forward-token", false, "with --upstream, forward the token headers too")
+	f := addEvalFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	env, err := f.load()
+	if err != nil {
+		return err
+	}
+	if env.trust == nil && !*anyIssuer {
+		return errors.New("no trust anchors: add a trust section to the --vars config, or pass --allow-any-issuer")
+	}
+	logf := func(format string, args ...any) {
+		fmt.Fprintf(c.stderr, "agent-safe serve: "+format+"\n", args...)
+	}
+	handler := newVerifyHandler(env)
+	if *upstream != "" {
+		if handler, err = newProxyHandler(env, *upstream, *keepToken, logf); err != nil {
+			return err
+		}
+	} else if *keepToken {
+		return errors.New("--forward-token requires --upstream")
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	if *upstream != "" {
+		logf("listening on %s, proxying to %s", ln.Addr(), *upstream)
+	} else {
+		logf("listening on %s", ln.Addr())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go env.revocations.poll(ctx, logf)
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// verifyRequest is the body of POST /v1/verify.
+type verifyRequest struct {
+	Token                 *spl.Token     `json:"token"`
+	Request               map[string]any `json:"request"`
+	PresentationSignature string         `json:"presentation_signature,omitempty"`
+}
+
+// opaInput is the input document of an OPA Data API query: the fields of a
+// verifyRequest, with the token as an object or in compact form.
+type opaInput struct {
+	Token                 json.RawMessage `json:"token"`
+	Request               map[string]any  `json:"request"`
+	PresentationSignature string          `json:"presentation_signature,omitempty"`
+}
+
+// opaDocument is the result of a query for data.agentsafe.
+type opaDocument struct {
+	Allow bool `json:"allow"`
+	decisionReport
+}
+
+// statsReport is the body of GET /v1/stats.
+type statsReport struct {
+	Requests    uint64 `json:"requests"`
+	Allowed     uint64 `json:"allowed"`
+	Denied      uint64 `json:"denied"`
+	Errors      uint64 `json:"errors"`
+	PolicyCache struct {
+		Hits      uint64 `json:"hits"`
+		Misses    uint64 `json:"misses"`
+		Evictions uint64 `json:"evictions"`
+	} `json:"policy_cache"`
+	Policies []spl.PolicyStats `json:"policies"`
+}
+
+// newVerifyHandler serves POST /v1/verify, which answers a verifyRequest
+// with a decisionReport (200 for both allow and deny), the OPA Data API
+// queries POST /v1/data/agentsafe and /v1/data/agentsafe/allow, GET
+// /v1/stats, which reports decision counts and the gas and latency of each
+// policy evaluated, and GET /healthz. From then on env verifies through
+// one spl.Verifier, which keeps those statistics.
+func newVerifyHandler(env *evalEnv) http.Handler {
+	if env.verifier == nil {
+		env.verifier = spl.NewVerifier(spl.VerifierConfig{VerifyTokenOptions: env.verifyOptions()})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/verify", func(w http.ResponseWriter, r *http.Request) {
+		var body verifyRequest
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxVerifyBody))
+		if err := dec.Decode(&body); err != nil {
+			httpError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		if body.Token == nil || body.Request == nil {
+			httpError(w, http.StatusBadRequest, "token and request are required")
+			return
+		}
+		httpJSON(w, http.StatusOK, env.verifyToken(body.Token, body.Request, body.PresentationSignature).report())
+	})
+	// The OPA Data API, so that clients written for an OPA sidecar can
+	// query agent-safe instead: {"input": {...}} in, {"result": ...} out.
+	opa := func(allowOnly bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Input *opaInput `json:"input"`
+			}
+			dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxVerifyBody))
+			if err := dec.Decode(&body); err != nil {
+				httpJSON(w, http.StatusBadRequest, map[string]string{"code": "invalid_parameter", "message": "invalid JSON body: " + err.Error()})
+				return
+			}
+			d := env.verifyOPAInput(body.Input)
+			if allowOnly {
+				httpJSON(w, http.StatusOK, map[string]any{"result": d.Allow})
+				return
+			}
+			httpJSON(w, http.StatusOK, map[string]any{"result": opaDocument{d.Allow, d.report()}})
+		}
+	}
+	mux.HandleFunc("POST /v1/data/agentsafe", opa(false))
+	mux.HandleFunc("POST /v1/data/agentsafe/allow", opa(true))
+	mux.HandleFunc("GET /v1/stats", func(w http.ResponseWriter, r *http.Request) {
+		st := env.verifier.Stats()
+		report := statsReport{Requests: st.Requests, Allowed: st.Allowed, Denied: st.Denied, Errors: st.Errors, Policies: st.Policies}
+		report.PolicyCache.Hits, report.PolicyCache.Misses, report.PolicyCache.Evictions = st.PolicyCache.Hits, st.PolicyCache.Misses, st.PolicyCache.Evictions
+		if report.Policies == nil {
+			report.Policies = []spl.PolicyStats{}
+		}
+		httpJSON(w, http.StatusOK, report)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		httpJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return mux
+}
+
+// verifyOPAInput verifies the token in an OPA input document. As with an
+// OPA policy whose allow defaults to false, missing or malformed input is a
+// deny rather than an error.
+func (env *evalEnv) verifyOPAInput(in *opaInput) decision {
+	if in == nil || len(in.Token) == 0 || in.Request == nil {
+		return decision{Reason: "input.token and input.request are required"}
+	}
+	raw := string(in.Token)
+	var compact string
+	if json.Unmarshal(in.Token, &compact) == nil {
+		raw = compact
+	}
+	tok, err := spl.ParseToken(raw)
+	if err != nil {
+		return decision{Reason: err.Error()}
+	}
+	return env.verifyToken(tok, in.Request, in.PresentationSignature)
+}
+
+func httpJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func httpError(w http.ResponseWriter, status int, msg string) {
+	httpJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// loadEnv loads a verifier config the way the commands do.
+func loadEnv(t *testing.T, args ...string) *evalEnv {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addEvalFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	env, err := f.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+func TestServeVerify(t *testing.T) {
+	dir := t.TempDir()
+	issuer := write(t, dir, "issuer.json", mustRun(t, "keygen"))
+	other := write(t, dir, "other.json", mustRun(t, "keygen", "--alg", "ES256"))
+	policy := write(t, dir, "policy.spl", `(<= (get req "amount") 100)`)
+	tok := mustRun(t, "mint", "--policy", policy, "--key", issuer)
+	untrusted := mustRun(t, "mint", "--policy", policy, "--key", other)
+	revoked := write(t, dir, "revoked.txt", "# revoked tokens\n")
+	cfg := write(t, dir, "verifier.yaml", `trust:
+  keys:
+    - file: issuer.json
+revocation:
+  file: revoked.txt
+`)
+	srv := httptest.NewServer(newVerifyHandler(loadEnv(t, "--vars", cfg)))
+	defer srv.Close()
+
+	verify := func(token string, amount int) (int, decisionReport) {
+		t.Helper()
+		body := `{"token": ` + token + `, "request": {"amount": ` + strconv.Itoa(amount) + `}}`
+		resp, err := http.Post(srv.URL+"/v1/verify", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var r decisionReport
+		json.NewDecoder(resp.Body).Decode(&r)
+		return resp.StatusCode, r
+	}
+
+	if code, r := verify(tok, 5); code != http.StatusOK || r.Decision != "allow" || r.GasUsed == 0 {
+		t.Fatalf("expected allow, got %d %+v", code, r)
+	}
+	if _, r := verify(tok, 500); r.Decision != "deny" {
+		t.Fatalf("expected deny over the limit, got %+v", r)
+	}
+	if _, r := verify(untrusted, 5); r.Decision != "deny" || !strings.Contains(r.Reason, "untrusted issuer") {
+		t.Fatalf("expected an untrusted issuer to be denied, got %+v", r)
+	}
+
+	var parsed struct{ Signature string }
+	json.Unmarshal([]byte(tok), &parsed)
+	if err := os.WriteFile(revoked, []byte(parsed.Signature+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, r := verify(tok, 5); r.Decision != "deny" || r.Reason != "token revoked" {
+		t.Fatalf("expected the revoked token to be denied, got %+v", r)
+	}
+
+	resp, err := http.Post(srv.URL+"/v1/verify", "application/json", bytes.NewReader([]byte(`{"request": {}}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(b), "token and request are required") {
+		t.Fatalf("expected 400, got %d %s", resp.StatusCode, b)
+	}
+	if resp, err := http.Get(srv.URL + "/v1/verify"); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for GET, got %v %v", resp, err)
+	}
+
+	resp, err = http.Get(srv.URL + "/v1/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats statsReport
+	json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	// The revoked token is denied before reaching the verifier.
+	if stats.Requests != 3 || stats.Allowed != 1 || stats.Denied != 1 || stats.Errors != 1 {
+		t.Fatalf("unexpected decision counts %+v", stats)
+	}
+	if len(stats.Policies) != 1 || stats.Policies[0].Evaluations != 2 || stats.Policies[0].GasMax == 0 || stats.Policies[0].LatencyMax == 0 {
+		t.Fatalf("expected the evaluations of the one policy, got %+v", stats.Policies)
+	}
+}
+
+func TestServeRequiresTrust(t *testing.T) {
+	code, _, errOut := agentSafe(t, "serve", "--addr", "127.0.0.1:0")
+	if code != exitError || !strings.Contains(errOut, "no trust anchors") {
+		t.Fatalf("expected serve to refuse to start without trust anchors, got %d %q", code, errOut)
+	}
+}
+
+func TestServeOPADataAPI(t *testing.T) {
+	dir := t.TempDir()
+	issuer := write(t, dir, "issuer.json", mustRun(t, "keygen"))
+	policy := write(t, dir, "policy.spl", `(<= (get req "amount") 100)`)
+	tok := mustRun(t, "mint", "--policy", policy, "--key", issuer)
+	compact := strings.TrimSpace(mustRun(t, "mint", "--policy", policy, "--key", issuer, "--format", "compact"))
+	cfg := write(t, dir, "verifier.yaml", "trust:\n  keys:\n    - file: issuer.json\n")
+	srv := httptest.NewServer(newVerifyHandler(loadEnv(t, "--vars", cfg)))
+	defer srv.Close()
+
+	query := func(path, body string) (int, map[string]any) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var r map[string]any
+		json.NewDecoder(resp.Body).Decode(&r)
+		return resp.StatusCode, r
+	}
+	input := func(token string, amount int) string {
+		return `{"input": {"token": ` + token + `, "request": {"amount": ` + strconv.Itoa(amount) + `}}}`
+	}
+
+	if code, r := query("/v1/data/agentsafe/allow", input(tok, 5)); code != http.StatusOK || r["result"] != true {
+		t.Fatalf("expected result true, got %d %v", code, r)
+	}
+	if _, r := query("/v1/data/agentsafe/allow", input(strconv.Quote(compact), 500)); r["result"] != false {
+		t.Fatalf("expected result false over the limit, got %v", r)
+	}
+	_, r := query("/v1/data/agentsafe", input(strconv.Quote(compact), 5))
+	doc, _ := r["result"].(map[string]any)
+	if doc["allow"] != true || doc["decision"] != "allow" {
+		t.Fatalf("expected the full decision document, got %v", r)
+	}
+	_, r = query("/v1/data/agentsafe", `{}`)
+	if doc, _ := r["result"].(map[string]any); doc["allow"] != false || doc["reason"] != "input.token and input.request are required" {
+		t.Fatalf("expected missing input to deny, got %v", r)
+	}
+	if code, r := query("/v1/data/agentsafe/allow", `{"input": `); code != http.StatusBadRequest || r["code"] != "invalid_parameter" {
+		t.Fatalf("expected an OPA-style 400, got %d %v", code, r)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/jwks"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// trustConfig lists the issuer keys tokens must be signed by:
+//
+//	trust:
+//	  keys:
+//	    - file: issuer.json          # keygen output, JWK, or PEM
+//	    - alg: ES256
+//	      public_key: 04ab...
+//	      not_after: 2026-01-01T00:00:00Z
+//	  jwks: https://issuer.example/.well-known/jwks.json
+//	  issuer: https://issuer.example  # OIDC discovery, instead of jwks
+//
+// Keys listed under keys are tried before the published key set.
+type trustConfig struct {
+	Keys   []trustKey `json:"keys"`
+	JWKS   string     `json:"jwks"`
+	Issuer string     `json:"issuer"`
+}
+
+type trustKey struct {
+	ID        string `json:"kid"`
+	Alg       string `json:"alg"`
+	PublicKey string `json:"public_key"`
+	File      string `json:"file"`
+	NotBefore string `json:"not_before"`
+	NotAfter  string `json:"not_after"`
+}
+
+// resolver builds the issuer key resolver the config describes: a
+// verify-only key ring of the listed keys, a published key set, or both.
+// A nil config yields a nil resolver, meaning any correctly self-signed
+// token is accepted.
+func (tc *trustConfig) resolver(dir string) (spl.KeyResolver, error) {
+	if tc == nil {
+		return nil, nil
+	}
+	if len(tc.Keys) == 0 && tc.JWKS == "" && tc.Issuer == "" {
+		return nil, fmt.Errorf("trust: no keys, jwks or issuer")
+	}
+	var rs anyResolver
+	if len(tc.Keys) > 0 {
+		kr, err := tc.keyRing(dir)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, kr)
+	}
+	if tc.JWKS != "" || tc.Issuer != "" {
+		r, err := jwks.New(jwks.Options{URL: tc.JWKS, Issuer: tc.Issuer})
+		if err != nil {
+			return nil, fmt.Errorf("trust: %w", err)
+		}
+		rs = append(rs, r)
+	}
+	if len(rs) == 1 {
+		return rs[0], nil
+	}
+	return rs, nil
+}
+
+// anyResolver trusts a key any of its resolvers trusts.
+type anyResolver []spl.KeyResolver
+
+func (rs anyResolver) ResolveKey(t *spl.Token, at time.Time) (string, error) {
+	var errs []error
+	for _, r := range rs {
+		key, err := r.ResolveKey(t, at)
+		if err == nil {
+			return key, nil
+		}
+		errs = append(errs, err)
+	}
+	return "", errors.Join(errs...)
+}
+
+// keyRing builds a verify-only key ring from the listed keys; relative
+// key files resolve against dir.
+func (tc *trustConfig) keyRing(dir string) (*spl.KeyRing, error) {
+	kr, _ := spl.NewKeyRing()
+	for i, k := range tc.Keys {
+		e := spl.KeyRingEntry{ID: k.ID, Alg: k.Alg, PublicKey: k.PublicKey}
+		if k.File != "" {
+			var err error
+			if e.Alg, e.PublicKey, _, err = loadKey(resolvePath(dir, k.File)); err != nil {
+				return nil, fmt.Errorf("trust: key %d: %w", i+1, err)
+			}
+		}
+		for _, t := range []struct {
+			s   string
+			dst *time.Time
+		}{{k.NotBefore, &e.NotBefore}, {k.NotAfter, &e.NotAfter}} {
+			if t.s == "" {
+				continue
+			}
+			v, err := time.Parse(time.RFC3339, t.s)
+			if err != nil {
+				return nil, fmt.Errorf("trust: key %d: %w", i+1, err)
+			}
+			*t.dst = v
+		}
+		if err := kr.Add(e); err != nil {
+			return nil, fmt.Errorf("trust: key %d: %w", i+1, err)
+		}
+	}
+	return kr, nil
+}
+
+func resolvePath(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// revocationConfig names where revoked tokens and keys are listed:
+//
+//	revocation:
+//	  file: revoked.txt              # re-read whenever it changes
+//	  url: https://issuer.example/revoked.txt
+//	  refresh: 1m                    # how often serve re-fetches url
+//
+// Both sources hold one entry per line (# starts a comment): a token
+// signature, an issuer public key, or a kid.
+type revocationConfig struct {
+	File    string `json:"file"`
+	URL     string `json:"url"`
+	Refresh string `json:"refresh"`
+}
+
+// revocationList is the union of the configured revocation sources.
+type revocationList struct {
+	file    *watchedFile
+	url     string
+	refresh time.Duration
+
+	mu       sync.RWMutex
+	fromFile map[string]bool
+	fromURL  map[string]bool
+}
+
+func (rc *revocationConfig) load(dir string) (*revocationList, error) {
+	if rc == nil {
+		return nil, nil
+	}
+	if rc.File == "" && rc.URL == "" {
+		return nil, fmt.Errorf("revocation: file or url required")
+	}
+	rl := &revocationList{url: rc.URL, refresh: time.Minute}
+	if rc.Refresh != "" {
+		d, err := time.ParseDuration(rc.Refresh)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("revocation: invalid refresh %q", rc.Refresh)
+		}
+		rl.refresh = d
+	}
+	if rc.File != "" {
+		rl.file = &watchedFile{path: resolvePath(dir, rc.File), parse: func(data []byte) error {
+			set := parseRevocations(bytes.NewReader(data))
+			rl.mu.Lock()
+			rl.fromFile = set
+			rl.mu.Unlock()
+			return nil
+		}}
+		if err := rl.file.refresh(); err != nil {
+			return nil, fmt.Errorf("revocation: %w", err)
+		}
+	}
+	if rc.URL != "" {
+		if err := rl.fetch(context.Background()); err != nil {
+			return nil, fmt.Errorf("revocation: %w", err)
+		}
+	}
+	return rl, nil
+}
+
+func parseRevocations(r io.Reader) map[string]bool {
+	set := map[string]bool{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			set[strings.ToLower(line)] = true
+		}
+	}
+	return set
+}
+
+// fetch replaces the URL entries with the list currently served.
+func (rl *revocationList) fetch(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rl.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", rl.url, resp.Status)
+	}
+	set := parseRevocations(io.LimitReader(resp.Body, 16<<20))
+	rl.mu.Lock()
+	rl.fromURL = set
+	rl.mu.Unlock()
+	return nil
+}
+
+// poll re-fetches the URL source every refresh interval until ctx is done,
+// keeping the last good list when a fetch fails.
+func (rl *revocationList) poll(ctx context.Context, logf func(format string, args ...any)) {
+	if rl == nil || rl.url == "" {
+		return
+	}
+	t := time.NewTicker(rl.refresh)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := rl.fetch(ctx); err != nil {
+				logf("revocation: %v", err)
+			}
+		}
+	}
+}
+
+// revoked reports whether the token, its issuer key or its kid is listed.
+func (rl *revocationList) revoked(t *spl.Token) bool {
+	if rl == nil {
+		return false
+	}
+	if rl.file != nil {
+		rl.file.refresh() // on error, keep the last good list
+	}
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	for _, id := range []string{t.Signature, t.PublicKey, t.KeyID} {
+		id = strings.ToLower(id)
+		if id != "" && (rl.fromFile[id] || rl.fromURL[id]) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchedFile re-parses a file whenever its size or modification time
+// changes.
+type watchedFile struct {
+	path  string
+	parse func([]byte) error
+
+	mu   sync.Mutex
+	mod  time.Time
+	size int64
+}
+
+func (w *watchedFile) refresh() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	if !w.mod.IsZero() && info.ModTime().Equal(w.mod) && info.Size() == w.size {
+		return nil
+	}
+	data, err := readFile(w.path)
+	if err != nil {
+		return err
+	}
+	if err := w.parse(data); err != nil {
+		return fmt.Errorf("%s: %w", w.path, err)
+	}
+	w.mod, w.size = info.ModTime(), info.Size()
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// watchInterval is how often watch mode polls the files it watches.
+var watchInterval = 300 * time.Millisecond
+
+// ANSI colors for watch output on a terminal.
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+)
+
+// color reports whether stdout is a terminal that wants color.
+func (c *cli) color() bool {
+	f, ok := c.stdout.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (c *cli) paint(color, s string) string {
+	if !c.color() {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// watchVerify evaluates the request against the policy now and after every
+// change to either file or the --vars config, until ctx is done. Each run
+// prints one timestamped line, noting when the decision flipped.
+func (c *cli) watchVerify(ctx context.Context, policyPath, reqPath string, f *evalFlags, explain bool) error {
+	paths := []string{policyPath, reqPath}
+	if *f.vars != "" {
+		paths = append(paths, *f.vars)
+	}
+	last := ""
+	run := func() {
+		stamp := time.Now().Format(time.TimeOnly)
+		d, err := c.verifyFiles(policyPath, reqPath, f, explain)
+		if err != nil {
+			fmt.Fprintf(c.stdout, "[%s] %s\n", stamp, c.paint(colorYellow, "ERROR: "+err.Error()))
+			last = ""
+			return
+		}
+		verdict, color := "DENY", colorRed
+		if d.Allow {
+			verdict, color = "ALLOW", colorGreen
+		}
+		line := c.paint(color, verdict)
+		if d.Reason != "" {
+			line += ": " + d.Reason
+		}
+		if last != "" && last != verdict {
+			line += fmt.Sprintf("  (was %s)", last)
+		}
+		fmt.Fprintf(c.stdout, "[%s] %s\n", stamp, line)
+		if d.Trace != nil {
+			printTrace(c.stdout, d.Trace, 0)
+		}
+		last = verdict
+	}
+	return watchFiles(ctx, paths, watchInterval, run)
+}
+
+// verifyFiles evaluates the request in reqPath against the policy in
+// policyPath.
+func (c *cli) verifyFiles(policyPath, reqPath string, f *evalFlags, explain bool) (decision, error) {
+	eval, err := c.policyEvaluator(policyPath, f, explain)
+	if err != nil {
+		return decision{}, err
+	}
+	var req map[string]any
+	if err := c.readJSON(reqPath, &req); err != nil {
+		return decision{}, err
+	}
+	return eval(req), nil
+}
+
+// watchFiles calls fn once, then again whenever any of paths changes,
+// polling every interval until ctx is done. A file that briefly disappears,
+// as when an editor replaces it on save, is picked up when it returns.
+func watchFiles(ctx context.Context, paths []string, interval time.Duration, fn func()) error {
+	changed := false
+	files := make([]*watchedFile, len(paths))
+	for i, p := range paths {
+		files[i] = &watchedFile{path: p, parse: func([]byte) error {
+			changed = true
+			return nil
+		}}
+		files[i].refresh()
+	}
+	fn()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+		changed = false
+		for _, w := range files {
+			w.refresh()
+		}
+		if changed {
+			fn()
+		}
+	}
+}
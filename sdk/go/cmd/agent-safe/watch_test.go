@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe to read while watch mode writes to it.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestWatchVerify(t *testing.T) {
+	defer func(d time.Duration) { watchInterval = d }(watchInterval)
+	watchInterval = 5 * time.Millisecond
+	dir := t.TempDir()
+	policy := write(t, dir, "policy.spl", `(<= (get req "amount") 100)`)
+	req := write(t, dir, "req.json", `{"amount": 50}`)
+
+	var out syncBuffer
+	c := &cli{stdin: strings.NewReader(""), stdout: &out, stderr: io.Discard}
+	f := addEvalFlags(flag.NewFlagSet("verify", flag.ContinueOnError))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.watchVerify(ctx, policy, req, f, false) }()
+
+	waitFor := func(s string, n int) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if strings.Count(out.String(), s) >= n {
+				return
+			}
+		}
+		t.Fatalf("watch output never contained %q %d times:\n%s", s, n, out.String())
+	}
+	waitFor("] ALLOW\n", 1)
+	write(t, dir, "req.json", `{"amount": 500}`)
+	waitFor("] DENY  (was ALLOW)\n", 1)
+	write(t, dir, "policy.spl", `(<= (get req "amount"`)
+	waitFor("] ERROR: policy:", 1)
+	write(t, dir, "policy.spl", `(<= (get req "amount") 1000)`)
+	waitFor("] ALLOW\n", 2)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Fatal("colored output written to a non-terminal")
+	}
+}
@@ -0,0 +1,125 @@
+// Package conformance generates and checks the test vectors every Agent-Safe
+// SDK must agree on: Ed25519 signatures, Merkle proofs, hash chains, token
+// signing payloads, proof-of-possession presentations and SPL evaluation.
+//
+// The vectors are JSON files, committed under examples/crypto, that the
+// Python, JavaScript and Rust SDKs load in their own tests. GenerateVectors
+// rewrites them from this package, the reference; RunVectors checks a
+// directory of them against the Go SDK, Run does so from a Go test, and
+// NewReport summarizes the outcome for comparison with other SDKs (as
+// "agent-safe conformance" prints it). Regenerate with
+//
+//	go generate ./conformance
+package conformance
+
+//go:generate go run gen.go ../../../examples/crypto
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Vector files, as written by GenerateVectors.
+const (
+	Ed25519File   = "ed25519_vectors.json"
+	MerkleFile    = "merkle_vectors.json"
+	HashChainFile = "hashchain_vectors.json"
+	TokenFile     = "token_vectors.json"
+	PoPFile       = "pop_vectors.json"
+	EvalFile      = "eval_vectors.json"
+)
+
+// Result is the outcome of one vector case.
+type Result struct {
+	File  string `json:"file"`
+	Case  string `json:"case"`
+	Pass  bool   `json:"pass"`
+	Error string `json:"error,omitempty"` // why the case failed
+}
+
+func (r Result) String() string {
+	if r.Pass {
+		return fmt.Sprintf("%s: %s: ok", r.File, r.Case)
+	}
+	return fmt.Sprintf("%s: %s: %s", r.File, r.Case, r.Error)
+}
+
+// vectorFile pairs a vector file with its generator and checker.
+type vectorFile struct {
+	name     string
+	generate func() (any, error)
+	run      func(data []byte) ([]Result, error)
+}
+
+var files = []vectorFile{
+	{Ed25519File, ed25519Vectors, runEd25519},
+	{MerkleFile, merkleVectors, runMerkle},
+	{HashChainFile, hashChainVectors, runHashChain},
+	{TokenFile, tokenVectors, runTokenCases},
+	{PoPFile, popVectors, runTokenCases},
+	{EvalFile, evalVectors, runEval},
+}
+
+// GenerateVectors writes every vector file into dir. The output is
+// deterministic, so regenerating unchanged vectors leaves the files
+// byte-for-byte the same.
+func GenerateVectors(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, f := range files {
+		v, err := f.generate()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, f.name), b, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunVectors checks every vector file in dir against this SDK and returns a
+// result per case. A missing or malformed file is an error: an SDK proves
+// parity only by passing all of them.
+func RunVectors(dir string) ([]Result, error) {
+	var results []Result
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(dir, f.name))
+		if err != nil {
+			return nil, err
+		}
+		rs, err := f.run(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+		for i := range rs {
+			rs[i].File = f.name
+		}
+		results = append(results, rs...)
+	}
+	return results, nil
+}
+
+// check records a case that passes when err is nil.
+func check(results *[]Result, name string, err error) {
+	r := Result{Case: name, Pass: err == nil}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	*results = append(*results, r)
+}
+
+// expect compares a boolean outcome with the vector's expectation.
+func expect(got, want bool) error {
+	if got != want {
+		return fmt.Errorf("expected %v, got %v", want, got)
+	}
+	return nil
+}
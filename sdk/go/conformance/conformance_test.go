@@ -0,0 +1,124 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const vectorsDir = "../../../examples/crypto"
+
+func TestRunVectors(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateVectors(dir); err != nil {
+		t.Fatal(err)
+	}
+	results, err := RunVectors(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) < 40 {
+		t.Fatalf("expected every vector file to contribute cases, got %d", len(results))
+	}
+	for _, r := range results {
+		if !r.Pass {
+			t.Error(r)
+		}
+	}
+
+	os.Remove(filepath.Join(dir, EvalFile))
+	if _, err := RunVectors(dir); err == nil {
+		t.Fatal("expected a missing vector file to be an error")
+	}
+}
+
+func TestRunVectorsReportsFailures(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateVectors(dir); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, EvalFile)
+	data, _ := os.ReadFile(path)
+	var v evalVectorFile
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range v.Cases {
+		if c.Name == "le_at_limit" {
+			v.Cases[i].Policy = `(<= (get req "amount") 99)`
+		}
+	}
+	data, _ = json.Marshal(v)
+	os.WriteFile(path, data, 0o644)
+	results, err := RunVectors(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := 0
+	for _, r := range results {
+		if !r.Pass {
+			failed++
+			if r.Case != "le_at_limit" || r.Error != "expected true, got false" {
+				t.Errorf("unexpected failure %s", r)
+			}
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("expected one failure, got %d", failed)
+	}
+}
+
+// The committed vectors must match what this package generates; run
+// "go generate ./conformance" after changing it.
+func TestCommittedVectorsUpToDate(t *testing.T) {
+	if _, err := os.Stat(vectorsDir); err != nil {
+		t.Skipf("examples not available: %v", err)
+	}
+	dir := t.TempDir()
+	if err := GenerateVectors(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		want, _ := os.ReadFile(filepath.Join(dir, f.name))
+		got, err := os.ReadFile(filepath.Join(vectorsDir, f.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is stale; run go generate ./conformance", f.name)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	if _, err := os.Stat(vectorsDir); err != nil {
+		t.Skipf("examples not available: %v", err)
+	}
+	Run(t, vectorsDir)
+}
+
+func TestNewReport(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateVectors(dir); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.OK() || r.SDK != "go" || r.Passed != len(r.Results) || len(r.Files) != len(files) {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	sum := 0
+	for _, f := range r.Files {
+		if len(f.SHA256) != 64 || f.Passed == 0 {
+			t.Fatalf("unexpected file report %+v", f)
+		}
+		sum += f.Passed
+	}
+	if sum != r.Passed {
+		t.Fatalf("expected file counts to add up to %d, got %d", r.Passed, sum)
+	}
+}
@@ -1,8 +1,4 @@
-// +build ignore
-
-// Generates shared crypto test vectors for all SDKs.
-// Run: go run generate_vectors.go
-package main
+package conformance
 
 import (
 	"crypto/ed25519"
@@ -10,17 +6,11 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"os"
-)
 
-func main() {
-	generateEd25519Vectors()
-	generateMerkleVectors()
-	generateHashChainVectors()
-	fmt.Println("All vectors generated.")
-}
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
 
-func generateEd25519Vectors() {
+func ed25519Vectors() (any, error) {
 	// Deterministic seed for reproducibility
 	seed := sha256.Sum256([]byte("agent-safe-test-vector-seed-ed25519"))
 	privKey := ed25519.NewKeyFromSeed(seed[:])
@@ -34,12 +24,12 @@ func generateEd25519Vectors() {
 	copy(tampered, message)
 	tampered[5] = 'o' // change '=' to 'o'
 
-	vectors := map[string]any{
-		"description": "Ed25519 test vectors for SPL policy signing",
-		"private_key_hex": hex.EncodeToString(privKey.Seed()),
-		"public_key_hex":  hex.EncodeToString([]byte(pubKey)),
-		"message":         string(message),
-		"signature_hex":   hex.EncodeToString(signature),
+	return map[string]any{
+		"description":      "Ed25519 test vectors for SPL policy signing",
+		"private_key_hex":  hex.EncodeToString(privKey.Seed()),
+		"public_key_hex":   hex.EncodeToString([]byte(pubKey)),
+		"message":          string(message),
+		"signature_hex":    hex.EncodeToString(signature),
 		"tampered_message": string(tampered),
 		"cases": []map[string]any{
 			{
@@ -53,12 +43,31 @@ func generateEd25519Vectors() {
 				"expected": false,
 			},
 		},
-	}
+	}, nil
+}
 
-	writeJSON("ed25519_vectors.json", vectors)
+func runEd25519(data []byte) ([]Result, error) {
+	var v struct {
+		PublicKeyHex string `json:"public_key_hex"`
+		SignatureHex string `json:"signature_hex"`
+		Cases        []struct {
+			Name     string `json:"name"`
+			Message  string `json:"message"`
+			Expected bool   `json:"expected"`
+		} `json:"cases"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, c := range v.Cases {
+		got := spl.VerifyEd25519([]byte(c.Message), v.SignatureHex, v.PublicKeyHex)
+		check(&results, c.Name, expect(got, c.Expected))
+	}
+	return results, nil
 }
 
-func generateMerkleVectors() {
+func merkleVectors() (any, error) {
 	// 4-leaf Merkle tree
 	leaves := []string{
 		"alice@example.com",
@@ -93,7 +102,7 @@ func generateMerkleVectors() {
 		{"hash": hex.EncodeToString(n01), "position": "left"},
 	}
 
-	vectors := map[string]any{
+	return map[string]any{
 		"description": "SHA-256 Merkle tree test vectors (4 leaves)",
 		"leaves":      leaves,
 		"leaf_hashes": leafHashHexes,
@@ -121,12 +130,37 @@ func generateMerkleVectors() {
 				"expected":  false,
 			},
 		},
-	}
+	}, nil
+}
 
-	writeJSON("merkle_vectors.json", vectors)
+func runMerkle(data []byte) ([]Result, error) {
+	var v struct {
+		Root  string `json:"root"`
+		Cases []struct {
+			Name     string                `json:"name"`
+			Leaf     string                `json:"leaf"`
+			LeafHash string                `json:"leaf_hash"`
+			Proof    []spl.MerkleProofStep `json:"proof"`
+			Expected bool                  `json:"expected"`
+		} `json:"cases"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, c := range v.Cases {
+		var err error
+		if h := hex.EncodeToString(sha256Bytes([]byte(c.Leaf))); h != c.LeafHash {
+			err = fmt.Errorf("leaf hash %s, want %s", h, c.LeafHash)
+		} else {
+			err = expect(spl.VerifyMerkleProof(c.Leaf, c.Proof, v.Root), c.Expected)
+		}
+		check(&results, c.Name, err)
+	}
+	return results, nil
 }
 
-func generateHashChainVectors() {
+func hashChainVectors() (any, error) {
 	// Seed -> hash 5 times to produce chain
 	// chain[0] = seed, chain[i] = SHA256(chain[i-1])
 	// Commitment = chain[5] (the end)
@@ -145,67 +179,74 @@ func generateHashChainVectors() {
 	// Commitment is chain[5]
 	commitment := chain[5]
 
-	vectors := map[string]any{
-		"description": "SHA-256 hash chain test vectors (5-step chain)",
-		"seed_hex":    chain[0],
-		"chain":       chain,
-		"commitment":  commitment,
+	return map[string]any{
+		"description":  "SHA-256 hash chain test vectors (5-step chain)",
+		"seed_hex":     chain[0],
+		"chain":        chain,
+		"commitment":   commitment,
 		"chain_length": 5,
 		"cases": []map[string]any{
 			{
-				"name":       "valid_receipt_step_3",
-				"preimage":   chain[3],
-				"index":      3,
-				"expected":   true,
-				"note":       "Hash preimage (5-3)=2 times to reach commitment",
+				"name":     "valid_receipt_step_3",
+				"preimage": chain[3],
+				"index":    3,
+				"expected": true,
+				"note":     "Hash preimage (5-3)=2 times to reach commitment",
 			},
 			{
-				"name":       "valid_receipt_step_0",
-				"preimage":   chain[0],
-				"index":      0,
-				"expected":   true,
-				"note":       "Hash seed 5 times to reach commitment",
+				"name":     "valid_receipt_step_0",
+				"preimage": chain[0],
+				"index":    0,
+				"expected": true,
+				"note":     "Hash seed 5 times to reach commitment",
 			},
 			{
-				"name":       "valid_receipt_step_5",
-				"preimage":   chain[5],
-				"index":      5,
-				"expected":   true,
-				"note":       "Preimage IS the commitment (0 hashes)",
+				"name":     "valid_receipt_step_5",
+				"preimage": chain[5],
+				"index":    5,
+				"expected": true,
+				"note":     "Preimage IS the commitment (0 hashes)",
 			},
 			{
-				"name":       "invalid_receipt_wrong_preimage",
-				"preimage":   hex.EncodeToString(sha256Bytes([]byte("wrong"))),
-				"index":      3,
-				"expected":   false,
+				"name":     "invalid_receipt_wrong_preimage",
+				"preimage": hex.EncodeToString(sha256Bytes([]byte("wrong"))),
+				"index":    3,
+				"expected": false,
 			},
 		},
-	}
+	}, nil
+}
 
-	writeJSON("hashchain_vectors.json", vectors)
+func runHashChain(data []byte) ([]Result, error) {
+	var v struct {
+		Commitment  string `json:"commitment"`
+		ChainLength int    `json:"chain_length"`
+		Cases       []struct {
+			Name     string `json:"name"`
+			Preimage string `json:"preimage"`
+			Index    int    `json:"index"`
+			Expected bool   `json:"expected"`
+		} `json:"cases"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, c := range v.Cases {
+		got := spl.VerifyHashChain(v.Commitment, c.Preimage, c.Index, v.ChainLength)
+		check(&results, c.Name, expect(got, c.Expected))
+	}
+	return results, nil
 }
 
 func hashPair(a, b []byte) []byte {
 	h := sha256.New()
 	h.Write(a)
 	h.Write(b)
-	sum := h.Sum(nil)
-	return sum
+	return h.Sum(nil)
 }
 
 func sha256Bytes(data []byte) []byte {
 	h := sha256.Sum256(data)
 	return h[:]
 }
-
-func writeJSON(filename string, data any) {
-	b, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		panic(err)
-	}
-	err = os.WriteFile(filename, b, 0644)
-	if err != nil {
-		panic(err)
-	}
-	fmt.Printf("Wrote %s\n", filename)
-}
@@ -0,0 +1,145 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// evalCase is an SPL evaluation vector: the decision policy must reach for
+// request under vars and env. Evaluation errors deny; Error names the kind
+// of error, as a spl.ErrorCode, that evaluation must fail with, if any.
+type evalCase struct {
+	Name     string         `json:"name"`
+	Policy   string         `json:"policy"`
+	Request  map[string]any `json:"request"`
+	Vars     map[string]any `json:"vars,omitempty"`
+	Env      *evalEnv       `json:"env,omitempty"`
+	Expected bool           `json:"expected"`
+	Error    string         `json:"error,omitempty"`
+	Note     string         `json:"note,omitempty"`
+}
+
+// evalEnv is the evaluation environment of a case; zero fields take the
+// defaults of SPEC.md. Each node evaluated costs one unit of gas and nests
+// one level deeper than its parent.
+type evalEnv struct {
+	Strict   bool `json:"strict,omitempty"`
+	MaxGas   int  `json:"max_gas,omitempty"`
+	MaxDepth int  `json:"max_depth,omitempty"`
+}
+
+type evalVectorFile struct {
+	Description string     `json:"description"`
+	Cases       []evalCase `json:"cases"`
+}
+
+var evalCases = []evalCase{
+	{Name: "eq_string", Policy: `(= (get req "action") "read")`, Request: map[string]any{"action": "read"}, Expected: true},
+	{Name: "eq_string_mismatch", Policy: `(= (get req "action") "read")`, Request: map[string]any{"action": "write"}, Expected: false},
+	{Name: "le_at_limit", Policy: `(<= (get req "amount") 100)`, Request: map[string]any{"amount": 100.0}, Expected: true},
+	{Name: "le_over_limit", Policy: `(<= (get req "amount") 100)`, Request: map[string]any{"amount": 100.01}, Expected: false},
+	{Name: "lt_at_limit", Policy: `(< (get req "amount") 100)`, Request: map[string]any{"amount": 100.0}, Expected: false},
+	{Name: "ge_and_gt", Policy: `(and (>= (get req "n") 1) (> (get req "n") 0))`, Request: map[string]any{"n": 1.0}, Expected: true},
+	{Name: "and_short_circuit", Policy: `(and (= 1 2) (unknown-op))`, Request: map[string]any{}, Expected: false,
+		Note: "and stops at the first false argument, so the unknown operator is never reached"},
+	{Name: "or_short_circuit", Policy: `(or (= 1 1) (unknown-op))`, Request: map[string]any{}, Expected: true},
+	{Name: "not", Policy: `(not (= (get req "action") "delete"))`, Request: map[string]any{"action": "read"}, Expected: true},
+	{Name: "member_tuple", Policy: `(member (get req "to") (tuple "alice" "bob"))`, Request: map[string]any{"to": "bob"}, Expected: true},
+	{Name: "member_var", Policy: `(member (get req "to") allowed)`, Request: map[string]any{"to": "carol"},
+		Vars: map[string]any{"allowed": []any{"alice", "bob"}}, Expected: false},
+	{Name: "in_alias", Policy: `(in (get req "to") allowed)`, Request: map[string]any{"to": "alice"},
+		Vars: map[string]any{"allowed": []any{"alice", "bob"}}, Expected: true},
+	{Name: "not_member", Policy: `(not-member (get req "to") blocked)`, Request: map[string]any{"to": "carol"},
+		Vars: map[string]any{"blocked": []any{"mallory"}}, Expected: true},
+	{Name: "not_member_blocked", Policy: `(not-member (get req "to") blocked)`, Request: map[string]any{"to": "mallory"},
+		Vars: map[string]any{"blocked": []any{"mallory"}}, Expected: false},
+	{Name: "not_member_missing_list", Policy: `(not-member (get req "to") blocked)`, Request: map[string]any{"to": "mallory"},
+		Expected: false, Error: "unresolved_symbol", Note: "a missing blocklist denies, unlike (not (member ...))"},
+	{Name: "subset", Policy: `(subset? (get req "scopes") (tuple "read" "list"))`, Request: map[string]any{"scopes": []any{"list"}}, Expected: true},
+	{Name: "subset_extra_item", Policy: `(subset? (get req "scopes") (tuple "read" "list"))`, Request: map[string]any{"scopes": []any{"list", "write"}}, Expected: false},
+	{Name: "nested_get", Policy: `(= (get (get req "user") "role") "admin")`, Request: map[string]any{"user": map[string]any{"role": "admin"}}, Expected: true},
+	{Name: "var_limit", Policy: `(<= (get req "amount") limit)`, Request: map[string]any{"amount": 75.0},
+		Vars: map[string]any{"limit": 50.0}, Expected: false},
+	{Name: "before_now", Policy: `(before now (get req "deadline"))`, Request: map[string]any{"deadline": "2026-06-01T00:00:00Z"},
+		Vars: map[string]any{"now": "2026-01-01T00:00:00Z"}, Expected: true},
+	{Name: "booleans", Policy: `(and #t (not #f))`, Request: map[string]any{}, Expected: true},
+	{Name: "truthy_field", Policy: `(get req "attested")`, Request: map[string]any{"attested": false}, Expected: false},
+	{Name: "crypto_fails_closed", Policy: `(dpop_ok?)`, Request: map[string]any{}, Expected: false,
+		Note: "crypto predicates deny when no verifier is configured"},
+	{Name: "unknown_operator", Policy: `(frobnicate (get req "x"))`, Request: map[string]any{"x": 1.0}, Expected: false,
+		Error: "unknown_op", Note: "evaluation errors deny"},
+
+	// Strict mode.
+	{Name: "unbound_symbol_lenient", Policy: `(= (get req "role") admin_role)`, Request: map[string]any{"role": "admin_role"}, Expected: true,
+		Note: "outside strict mode an unbound symbol evaluates to its name, the bypass strict mode exists to prevent"},
+	{Name: "strict_unbound_symbol", Policy: `(<= 50 limit)`, Request: map[string]any{},
+		Env: &evalEnv{Strict: true}, Expected: false, Error: "unresolved_symbol"},
+	{Name: "strict_bound_symbol", Policy: `(<= 50 limit)`, Request: map[string]any{},
+		Vars: map[string]any{"limit": 100.0}, Env: &evalEnv{Strict: true}, Expected: true},
+	{Name: "strict_unbound_now", Policy: `(before now deadline)`, Request: map[string]any{},
+		Vars: map[string]any{"deadline": "2026-06-01T00:00:00Z"}, Env: &evalEnv{Strict: true}, Expected: false, Error: "unresolved_symbol"},
+	{Name: "strict_short_circuit", Policy: `(or #t unbound)`, Request: map[string]any{}, Env: &evalEnv{Strict: true}, Expected: true,
+		Note: "an unbound symbol or never evaluates is no error"},
+
+	// Gas and depth.
+	{Name: "gas_at_budget", Policy: `(and #t #t #t #t #t #t #t #t #t #t)`, Request: map[string]any{},
+		Env: &evalEnv{MaxGas: 11}, Expected: true, Note: "the and and its ten arguments cost 11"},
+	{Name: "gas_exhausted", Policy: `(and #t #t #t #t #t #t #t #t #t #t)`, Request: map[string]any{},
+		Env: &evalEnv{MaxGas: 10}, Expected: false, Error: "gas_exceeded"},
+	{Name: "gas_short_circuit", Policy: `(or #t #t #t #t #t #t #t #t #t #t)`, Request: map[string]any{},
+		Env: &evalEnv{MaxGas: 2}, Expected: true, Note: "arguments never evaluated cost nothing"},
+	{Name: "depth_at_limit", Policy: `(not (not (not (not #f))))`, Request: map[string]any{}, Env: &evalEnv{MaxDepth: 5}, Expected: false},
+	{Name: "depth_exceeded", Policy: `(not (not (not (not #t))))`, Request: map[string]any{},
+		Env: &evalEnv{MaxDepth: 4}, Expected: false, Error: "depth_exceeded",
+		Note: "four nots and the #t they wrap nest five deep"},
+
+	// Type mismatches.
+	{Name: "eq_number_string", Policy: `(= (get req "amount") 100)`, Request: map[string]any{"amount": "100"}, Expected: false,
+		Note: "equality across types is false"},
+	{Name: "eq_bool_number", Policy: `(= (get req "attested") 1)`, Request: map[string]any{"attested": true}, Expected: false},
+	{Name: "eq_lists", Policy: `(= (get req "tags") (tuple "a" "b"))`, Request: map[string]any{"tags": []any{"a", "b"}}, Expected: true},
+	{Name: "eq_missing_field", Policy: `(= (get req "missing") 1)`, Request: map[string]any{}, Expected: false},
+	{Name: "member_not_list", Policy: `(member (get req "to") "alice")`, Request: map[string]any{"to": "alice"}, Expected: false,
+		Note: "membership in anything but a list is false"},
+	{Name: "subset_not_list", Policy: `(subset? (get req "scopes") (tuple "read"))`, Request: map[string]any{"scopes": "read"}, Expected: false},
+	{Name: "non_boolean_result", Policy: `(get req "amount")`, Request: map[string]any{"amount": 5.0}, Expected: false, Error: "eval",
+		Note: "a policy must evaluate to a boolean"},
+}
+
+func evalVectors() (any, error) {
+	return evalVectorFile{
+		Description: "SPL evaluation vectors: policy + request + vars + env -> decision; errors deny, and error names the kind of error expected (gas_exceeded, depth_exceeded, unresolved_symbol, unknown_op or eval)",
+		Cases:       evalCases,
+	}, nil
+}
+
+func runEval(data []byte) ([]Result, error) {
+	var v evalVectorFile
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, c := range v.Cases {
+		ast, err := spl.Parse(c.Policy)
+		if err != nil {
+			check(&results, c.Name, err)
+			continue
+		}
+		env := spl.Env{Req: c.Request, Vars: c.Vars}
+		if c.Env != nil {
+			env.Strict, env.MaxGas, env.MaxDepth = c.Env.Strict, c.Env.MaxGas, c.Env.MaxDepth
+		}
+		allow, err := spl.Verify(ast, env)
+		if code := spl.CodeOf(err); err != nil && code.String() != c.Error {
+			err = fmt.Errorf("expected error %q, got %s: %v", c.Error, code, err)
+		} else if err == nil && c.Error != "" {
+			err = fmt.Errorf("expected error %q, got none", c.Error)
+		} else {
+			err = expect(allow, c.Expected)
+		}
+		check(&results, c.Name, err)
+	}
+	return results, nil
+}
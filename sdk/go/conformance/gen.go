@@ -0,0 +1,24 @@
+//go:build ignore
+
+// Writes the shared test vectors for all SDKs.
+// Run: go generate ./conformance
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jmcentire/agent-safe/sdk/go/conformance"
+)
+
+func main() {
+	dir := "."
+	if len(os.Args) > 1 {
+		dir = os.Args[1]
+	}
+	if err := conformance.GenerateVectors(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("All vectors generated.")
+}
@@ -0,0 +1,82 @@
+package conformance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Report is the machine-readable outcome of running the vectors, in a form
+// every SDK can emit: two SDKs whose reports name the same file digests
+// and both pass have been checked against the same bytes.
+type Report struct {
+	// SDK names the implementation that ran the vectors, "go" here.
+	SDK     string       `json:"sdk"`
+	Passed  int          `json:"passed"`
+	Failed  int          `json:"failed"`
+	Files   []FileReport `json:"files"`
+	Results []Result     `json:"results"`
+}
+
+// FileReport summarizes one vector file.
+type FileReport struct {
+	File string `json:"file"`
+	// SHA256 is the hex digest of the file as read.
+	SHA256 string `json:"sha256"`
+	Passed int    `json:"passed"`
+	Failed int    `json:"failed"`
+}
+
+// OK reports whether every case passed.
+func (r *Report) OK() bool { return r.Failed == 0 }
+
+// NewReport runs the vectors in dir, as RunVectors does, and reports the
+// outcome with the digest of each file.
+func NewReport(dir string) (*Report, error) {
+	results, err := RunVectors(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{SDK: "go", Results: results}
+	index := map[string]int{}
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(dir, f.name))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		index[f.name] = len(r.Files)
+		r.Files = append(r.Files, FileReport{File: f.name, SHA256: hex.EncodeToString(sum[:])})
+	}
+	for _, res := range results {
+		f := &r.Files[index[res.File]]
+		if res.Pass {
+			f.Passed++
+			r.Passed++
+		} else {
+			f.Failed++
+			r.Failed++
+		}
+	}
+	return r, nil
+}
+
+// Run checks the vectors in dir as subtests of t, one per case, named
+// file/case.
+func Run(t *testing.T, dir string) {
+	t.Helper()
+	results, err := RunVectors(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		r := r
+		t.Run(r.File+"/"+r.Case, func(t *testing.T) {
+			if !r.Pass {
+				t.Error(r)
+			}
+		})
+	}
+}
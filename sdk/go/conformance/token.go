@@ -0,0 +1,178 @@
+package conformance
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// tokenVectorFile is the layout of the token and PoP vector files. Each
+// case gives a token, the hex signing payload an SDK must rebuild from its
+// fields, and whether verifying it for the request at now must allow.
+type tokenVectorFile struct {
+	Description        string      `json:"description"`
+	PrivateKeyHex      string      `json:"private_key_hex"`
+	PublicKeyHex       string      `json:"public_key_hex"`
+	AgentPrivateKeyHex string      `json:"agent_private_key_hex,omitempty"`
+	AgentPublicKeyHex  string      `json:"agent_public_key_hex,omitempty"`
+	Now                string      `json:"now"`
+	Cases              []tokenCase `json:"cases"`
+}
+
+type tokenCase struct {
+	Name                  string         `json:"name"`
+	Token                 *spl.Token     `json:"token"`
+	PayloadHex            string         `json:"payload_hex"`
+	Request               map[string]any `json:"request"`
+	PresentationSignature string         `json:"presentation_signature,omitempty"`
+	Expected              bool           `json:"expected"`
+	Note                  string         `json:"note,omitempty"`
+}
+
+const (
+	vectorPolicy = `(and (= (get req "action") "read") (<= (get req "amount") 100))`
+	vectorNow    = "2026-01-01T00:00:00Z"
+)
+
+// vectorKey derives a deterministic Ed25519 keypair from label.
+func vectorKey(label string) (pub, priv string) {
+	seed := sha256.Sum256([]byte(label))
+	key := ed25519.NewKeyFromSeed(seed[:])
+	return hex.EncodeToString(key.Public().(ed25519.PublicKey)), hex.EncodeToString(seed[:])
+}
+
+func payloadHex(t *spl.Token) string {
+	return hex.EncodeToString(spl.SigningPayload(t.Policy, t.MerkleRoot, t.HashChainCommitment, t.Sealed, t.Expires))
+}
+
+func readReq(amount float64) map[string]any {
+	return map[string]any{"action": "read", "amount": amount}
+}
+
+func tokenVectors() (any, error) {
+	pub, priv := vectorKey("agent-safe-test-vector-seed-token")
+	minimal, err := spl.Mint(vectorPolicy, priv, spl.MintOptions{})
+	if err != nil {
+		return nil, err
+	}
+	full, err := spl.Mint(vectorPolicy, priv, spl.MintOptions{
+		MerkleRoot:          hex.EncodeToString(sha256Bytes([]byte("agent-safe-merkle-root"))),
+		HashChainCommitment: hex.EncodeToString(sha256Bytes([]byte("agent-safe-hash-chain"))),
+		Sealed:              true,
+		Expires:             "2027-01-01T00:00:00Z",
+	})
+	if err != nil {
+		return nil, err
+	}
+	expired, err := spl.Mint(vectorPolicy, priv, spl.MintOptions{Expires: "2025-01-01T00:00:00Z"})
+	if err != nil {
+		return nil, err
+	}
+	tamper := func(t *spl.Token, f func(*spl.Token)) *spl.Token {
+		c := *t
+		f(&c)
+		return &c
+	}
+	cases := []tokenCase{
+		{Name: "valid_minimal", Token: minimal, Request: readReq(50), Expected: true},
+		{Name: "valid_full_envelope", Token: full, Request: readReq(100), Expected: true,
+			Note: "merkle_root, hash_chain_commitment, sealed and expires are all signed"},
+		{Name: "policy_denies", Token: minimal, Request: readReq(500), Expected: false},
+		{Name: "tampered_policy", Token: tamper(minimal, func(t *spl.Token) {
+			t.Policy = `(and (= (get req "action") "read") (<= (get req "amount") 1000))`
+		}), Request: readReq(500), Expected: false},
+		{Name: "tampered_sealed", Token: tamper(full, func(t *spl.Token) { t.Sealed = false }),
+			Request: readReq(50), Expected: false},
+		{Name: "tampered_expires", Token: tamper(full, func(t *spl.Token) { t.Expires = "2099-01-01T00:00:00Z" }),
+			Request: readReq(50), Expected: false},
+		{Name: "tampered_merkle_root", Token: tamper(full, func(t *spl.Token) { t.MerkleRoot = "" }),
+			Request: readReq(50), Expected: false},
+		{Name: "expired", Token: expired, Request: readReq(50), Expected: false,
+			Note: "expires is before now"},
+	}
+	for i := range cases {
+		cases[i].PayloadHex = payloadHex(cases[i].Token)
+	}
+	return tokenVectorFile{
+		Description:   "Token signing payload vectors: policy \\0 merkle_root \\0 hash_chain_commitment \\0 sealed (0|1) \\0 expires, signed with Ed25519",
+		PrivateKeyHex: priv,
+		PublicKeyHex:  pub,
+		Now:           vectorNow,
+		Cases:         cases,
+	}, nil
+}
+
+func popVectors() (any, error) {
+	pub, priv := vectorKey("agent-safe-test-vector-seed-token")
+	agentPub, agentPriv := vectorKey("agent-safe-test-vector-seed-pop")
+	bound, err := spl.Mint(vectorPolicy, priv, spl.MintOptions{PoPKey: agentPub})
+	if err != nil {
+		return nil, err
+	}
+	other, err := spl.Mint(vectorPolicy, priv, spl.MintOptions{PoPKey: agentPub, Expires: "2027-01-01T00:00:00Z"})
+	if err != nil {
+		return nil, err
+	}
+	valid, err := spl.CreatePresentationSignature(bound, agentPriv)
+	if err != nil {
+		return nil, err
+	}
+	byIssuer, err := spl.CreatePresentationSignature(bound, priv)
+	if err != nil {
+		return nil, err
+	}
+	forOther, err := spl.CreatePresentationSignature(other, agentPriv)
+	if err != nil {
+		return nil, err
+	}
+	cases := []tokenCase{
+		{Name: "valid_presentation", Token: bound, Request: readReq(50), PresentationSignature: valid, Expected: true,
+			Note: "Ed25519 signature by pop_key over SHA-256(signing payload)"},
+		{Name: "missing_presentation", Token: bound, Request: readReq(50), Expected: false},
+		{Name: "wrong_agent_key", Token: bound, Request: readReq(50), PresentationSignature: byIssuer, Expected: false},
+		{Name: "presentation_for_other_token", Token: bound, Request: readReq(50), PresentationSignature: forOther, Expected: false},
+	}
+	for i := range cases {
+		cases[i].PayloadHex = payloadHex(cases[i].Token)
+	}
+	return tokenVectorFile{
+		Description:        "Proof-of-possession presentation vectors",
+		PrivateKeyHex:      priv,
+		PublicKeyHex:       pub,
+		AgentPrivateKeyHex: agentPriv,
+		AgentPublicKeyHex:  agentPub,
+		Now:                vectorNow,
+		Cases:              cases,
+	}, nil
+}
+
+func runTokenCases(data []byte) ([]Result, error) {
+	var v tokenVectorFile
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, c := range v.Cases {
+		if c.Token == nil {
+			return nil, fmt.Errorf("%s: no token", c.Name)
+		}
+		var err error
+		if got := payloadHex(c.Token); got != c.PayloadHex {
+			err = fmt.Errorf("signing payload %s, want %s", got, c.PayloadHex)
+		} else {
+			r := spl.VerifyTokenObj(c.Token, c.Request, spl.VerifyTokenOptions{
+				Now:                   v.Now,
+				PresentationSignature: c.PresentationSignature,
+			})
+			if err = expect(r.Allow, c.Expected); err != nil && r.Err != nil {
+				err = fmt.Errorf("%w (%v)", err, r.Err)
+			}
+		}
+		check(&results, c.Name, err)
+	}
+	return results, nil
+}
@@ -0,0 +1,212 @@
+// Package crdt counts usage on devices that may be offline, in counters
+// that merge without losing or double-counting a use.
+//
+// Each device keeps its own Store, named by a device ID, and verifies
+// against it while disconnected. When it reconnects it sends State to the
+// verifier, which merges it into its own Store:
+//
+//	phone := crdt.NewStore("phone-7f3a")
+//	res := spl.VerifyTokenObj(tok, req, spl.VerifyTokenOptions{Counters: phone})
+//	// later, online:
+//	verifier.Merge(phone.State())
+//
+// Counts are PN-counters: each device only adds to its own entries, and
+// merging keeps the larger of each device's entries, so merging the same
+// state twice, or states in any order, gives the same counts. Limits hold
+// within each device while it is offline; devices spending offline at the
+// same time can together pass a limit, and later requests are then denied
+// until the window ends.
+package crdt
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// GCounter is a grow-only counter: a count per device, whose value is
+// their sum.
+type GCounter map[string]uint64
+
+// Value returns the sum of the devices' counts.
+func (g GCounter) Value() uint64 {
+	var n uint64
+	for _, c := range g {
+		n += c
+	}
+	return n
+}
+
+// Merge raises each of g's counts to other's where other's is larger.
+func (g GCounter) Merge(other GCounter) {
+	for d, c := range other {
+		if c > g[d] {
+			g[d] = c
+		}
+	}
+}
+
+// PNCounter is a counter that can also be decremented, as a pair of
+// GCounters of increments and decrements.
+type PNCounter struct {
+	P GCounter `json:"p"`
+	N GCounter `json:"n,omitempty"`
+}
+
+// Value returns the increments less the decrements.
+func (c *PNCounter) Value() int64 {
+	return int64(c.P.Value()) - int64(c.N.Value())
+}
+
+// Add adds n, which may be negative, to device's entries.
+func (c *PNCounter) Add(device string, n int64) {
+	switch {
+	case n > 0:
+		if c.P == nil {
+			c.P = GCounter{}
+		}
+		c.P[device] += uint64(n)
+	case n < 0:
+		if c.N == nil {
+			c.N = GCounter{}
+		}
+		c.N[device] += uint64(-n)
+	}
+}
+
+// Merge merges other into c.
+func (c *PNCounter) Merge(other PNCounter) {
+	if len(other.P) > 0 && c.P == nil {
+		c.P = GCounter{}
+	}
+	if len(other.N) > 0 && c.N == nil {
+		c.N = GCounter{}
+	}
+	c.P.Merge(other.P)
+	c.N.Merge(other.N)
+}
+
+func (c *PNCounter) clone() PNCounter {
+	out := PNCounter{}
+	out.Merge(*c)
+	return out
+}
+
+var _ spl.CounterStore = (*Store)(nil)
+
+// Store is an spl.CounterStore of PN-counters, one per action and window,
+// that counts as one device and merges other devices' counts. It is safe
+// for concurrent use.
+type Store struct {
+	device   string
+	mu       sync.Mutex
+	counters map[key]*PNCounter
+}
+
+type key struct{ action, window string }
+
+// NewStore returns an empty store counting as device. Every device that
+// counts must have an ID of its own.
+func NewStore(device string) *Store {
+	return &Store{device: device, counters: map[key]*PNCounter{}}
+}
+
+func (s *Store) counter(action, window string) *PNCounter {
+	k := key{action, window}
+	c := s.counters[k]
+	if c == nil {
+		c = &PNCounter{}
+		s.counters[k] = c
+	}
+	return c
+}
+
+// Count returns the count of action in window across every device merged.
+func (s *Store) Count(action, window string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int(s.counter(action, window).Value()), nil
+}
+
+// CheckAndIncrement counts a use by this device if the count across every
+// device merged is below limit, and reports whether it did.
+func (s *Store) CheckAndIncrement(action, window string, limit int) (bool, error) {
+	if s.device == "" {
+		return false, errors.New("crdt: store has no device ID")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.counter(action, window)
+	if c.Value() >= int64(limit) {
+		return false, nil
+	}
+	c.Add(s.device, 1)
+	return true, nil
+}
+
+// Decrement takes back n uses of action in window for this device, as for
+// a refunded purchase.
+func (s *Store) Decrement(action, window string, n int) error {
+	if s.device == "" {
+		return errors.New("crdt: store has no device ID")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counter(action, window).Add(s.device, -int64(n))
+	return nil
+}
+
+// State is a snapshot of a Store's counters, as JSON for syncing.
+type State struct {
+	Counters []CounterState `json:"counters"`
+}
+
+// CounterState is one counter in a State.
+type CounterState struct {
+	Action string `json:"action"`
+	Window string `json:"window"`
+	PNCounter
+}
+
+// State returns a snapshot of every counter, sorted by window and action.
+func (s *Store) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := State{Counters: make([]CounterState, 0, len(s.counters))}
+	for k, c := range s.counters {
+		st.Counters = append(st.Counters, CounterState{Action: k.action, Window: k.window, PNCounter: c.clone()})
+	}
+	sort.Slice(st.Counters, func(i, j int) bool {
+		a, b := st.Counters[i], st.Counters[j]
+		if a.Window != b.Window {
+			return a.Window < b.Window
+		}
+		return a.Action < b.Action
+	})
+	return st
+}
+
+// Merge merges another store's state into s. Merging is idempotent,
+// commutative and associative, so states can be synced repeatedly, late
+// or through intermediaries.
+func (s *Store) Merge(st State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range st.Counters {
+		s.counter(c.Action, c.Window).Merge(c.PNCounter)
+	}
+}
+
+// Prune drops the counters of windows for which keep returns false, such
+// as days that have passed.
+func (s *Store) Prune(keep func(window string) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.counters {
+		if !keep(k.window) {
+			delete(s.counters, k)
+		}
+	}
+}
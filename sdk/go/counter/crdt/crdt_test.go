@@ -0,0 +1,85 @@
+package crdt
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func TestOfflineSync(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	tok, err := spl.Mint(`(< (per-day-count "purchase" "2026-01-31") 3)`, priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewStore("verifier")
+	phone := NewStore("phone")
+	verify := func(s *Store) bool {
+		return spl.VerifyTokenObj(tok, map[string]any{}, spl.VerifyTokenOptions{Counters: s}).Allow
+	}
+
+	if !verify(verifier) {
+		t.Fatal("expected an online purchase to be allowed")
+	}
+	phone.Merge(verifier.State())
+	// Offline, the phone spends the rest of the allowance on its own.
+	if !verify(phone) || !verify(phone) || verify(phone) {
+		t.Fatal("expected the phone to allow 2 more purchases offline, then deny")
+	}
+
+	// Syncing through JSON, twice, counts each purchase once.
+	data, err := json.Marshal(phone.State())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		var st State
+		if err := json.Unmarshal(data, &st); err != nil {
+			t.Fatal(err)
+		}
+		verifier.Merge(st)
+	}
+	if n, _ := verifier.Count("purchase", "2026-01-31"); n != 3 {
+		t.Fatalf("expected 3 purchases after syncing, got %d", n)
+	}
+	if verify(verifier) {
+		t.Fatal("expected the synced allowance to be spent")
+	}
+
+	verifier.Decrement("purchase", "2026-01-31", 1)
+	phone.Merge(verifier.State())
+	if n, _ := phone.Count("purchase", "2026-01-31"); n != 2 {
+		t.Fatalf("expected a refund to sync back, got %d", n)
+	}
+}
+
+func TestMergeConverges(t *testing.T) {
+	a, b, c := NewStore("a"), NewStore("b"), NewStore("c")
+	for i := 0; i < 3; i++ {
+		a.CheckAndIncrement("search", "d", 100)
+	}
+	b.CheckAndIncrement("search", "d", 100)
+	c.CheckAndIncrement("search", "d", 100)
+	c.Decrement("search", "d", 1)
+
+	// Merging in different orders, and through an intermediary, gives the
+	// same count everywhere.
+	b.Merge(c.State())
+	a.Merge(b.State())
+	c.Merge(a.State())
+	b.Merge(a.State())
+	for name, s := range map[string]*Store{"a": a, "b": b, "c": c} {
+		if n, _ := s.Count("search", "d"); n != 4 {
+			t.Errorf("%s: expected 4, got %d", name, n)
+		}
+	}
+
+	a.Prune(func(window string) bool { return window != "d" })
+	if st := a.State(); len(st.Counters) != 0 {
+		t.Fatalf("expected the window pruned, got %+v", st)
+	}
+	if _, err := NewStore("").CheckAndIncrement("search", "d", 1); err == nil {
+		t.Fatal("expected a store without a device ID to refuse to count")
+	}
+}
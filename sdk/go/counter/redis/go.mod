@@ -0,0 +1,17 @@
+module github.com/jmcentire/agent-safe/sdk/go/counter/redis
+
+go 1.24.0
+
+require (
+	github.com/alicebob/miniredis/v2 v2.39.0
+	github.com/jmcentire/agent-safe/sdk/go v0.0.0
+	github.com/redis/go-redis/v9 v9.14.1
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+)
+
+replace github.com/jmcentire/agent-safe/sdk/go => ../../
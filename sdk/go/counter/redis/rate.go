@@ -0,0 +1,117 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// windowTake adds member ARGV[4] with score ARGV[1] (now, in milliseconds)
+// to the sorted set KEYS[1] if fewer than ARGV[3] members score in the
+// window of ARGV[2] milliseconds ending at now, dropping members older
+// than ARGV[5] milliseconds, and returns 1 if it did.
+var windowTake = goredis.NewScript(`
+local now = tonumber(ARGV[1])
+local n = redis.call('ZCOUNT', KEYS[1], '(' .. (now - tonumber(ARGV[2])), now)
+if n >= tonumber(ARGV[3]) then
+  return 0
+end
+redis.call('ZADD', KEYS[1], now, ARGV[4])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', '(' .. (now - tonumber(ARGV[5])))
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+// bucket refills the token bucket in the hash KEYS[1], of capacity ARGV[1]
+// gaining a token every ARGV[2] milliseconds, up to now, ARGV[3]. With
+// ARGV[4] = 1 it takes a token, returning "1" if there was one or "0" if
+// not; otherwise it returns the tokens held. A missing bucket is full, so
+// the hash expires once the bucket would be full again.
+var bucket = goredis.NewScript(`
+local cap, refill, now = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local s = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(s[1]) or cap
+local at = tonumber(s[2]) or now
+if now > at then
+  tokens = math.min(cap, tokens + (now - at) / refill)
+  at = now
+end
+if ARGV[4] ~= '1' then
+  return tostring(tokens)
+end
+if tokens < 1 then
+  return '0'
+end
+tokens = tokens - 1
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', at)
+redis.call('PEXPIRE', KEYS[1], math.ceil((cap - tokens) * refill) + 1)
+return '1'
+`)
+
+func (s *Store) windowKey(action string) string {
+	return s.opts.Prefix + "window:" + action
+}
+
+func (s *Store) bucketKey(action string, b spl.Bucket) string {
+	return s.opts.Prefix + "bucket:" + strconv.Itoa(b.Capacity) + ":" + strconv.FormatInt(b.Refill.Milliseconds(), 10) + ":" + action
+}
+
+// WindowCount returns the uses of action in the window ending at now.
+func (s *Store) WindowCount(action string, window time.Duration, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	ms := now.UnixMilli()
+	n, err := s.client.ZCount(ctx, s.windowKey(action),
+		"("+strconv.FormatInt(ms-window.Milliseconds(), 10), strconv.FormatInt(ms, 10)).Result()
+	return int(n), err
+}
+
+// WindowTake records a use of action at now if fewer than limit uses are
+// in the window ending at now, and reports whether it did.
+func (s *Store) WindowTake(action string, window time.Duration, limit int, now time.Time) (bool, error) {
+	if window > s.opts.RateHistory {
+		window = s.opts.RateHistory
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	ms := now.UnixMilli()
+	n, err := windowTake.Run(ctx, s.client, []string{s.windowKey(action)},
+		ms, window.Milliseconds(), limit, strconv.FormatInt(ms, 10)+":"+hex.EncodeToString(id), s.opts.RateHistory.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// BucketTokens returns the tokens in action's bucket at now.
+func (s *Store) BucketTokens(action string, b spl.Bucket, now time.Time) (float64, error) {
+	res, err := s.runBucket(action, b, now, false)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(res, 64)
+}
+
+// BucketTake takes a token from action's bucket if it holds one at now, and
+// reports whether it did.
+func (s *Store) BucketTake(action string, b spl.Bucket, now time.Time) (bool, error) {
+	res, err := s.runBucket(action, b, now, true)
+	return res == "1", err
+}
+
+func (s *Store) runBucket(action string, b spl.Bucket, now time.Time, take bool) (string, error) {
+	refill := math.Max(float64(b.Refill.Milliseconds()), 1)
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	flag := "0"
+	if take {
+		flag = "1"
+	}
+	return bucket.Run(ctx, s.client, []string{s.bucketKey(action, b)}, b.Capacity, refill, now.UnixMilli(), flag).Text()
+}
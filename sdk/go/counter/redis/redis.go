@@ -0,0 +1,117 @@
+// Package redis keeps per-day counts, rolling-window histories, token
+// buckets and replay identifiers in Redis, so that horizontally scaled
+// verifiers share one view of an agent's usage:
+//
+//	s := redis.New(goredis.NewClient(&goredis.Options{Addr: "localhost:6379"}), redis.Options{})
+//	res := spl.VerifyTokenObj(tok, req, spl.VerifyTokenOptions{Counters: s, Rates: s})
+//
+// Store implements spl.CounterStore, spl.RateStore and spl.ReplayStore.
+// Every check-and-take runs as a Lua script, so it is atomic on the server,
+// and every key expires on its own. It lives in its own module so that the
+// SDK itself stays free of the Redis dependency.
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+var (
+	_ spl.CounterStore = (*Store)(nil)
+	_ spl.RateStore    = (*Store)(nil)
+	_ spl.ReplayStore  = (*Store)(nil)
+)
+
+// Options configures a Store.
+type Options struct {
+	// Prefix begins every key the store writes. Default "agent-safe:".
+	Prefix string
+	// CounterTTL is how long a window's count is kept after it is first
+	// incremented. It must outlast the window; the default, 48 hours,
+	// covers a calendar day in any time zone.
+	CounterTTL time.Duration
+	// RateHistory is how long uses are kept for window-count. It must
+	// outlast the longest window policies ask for. Default 7 days.
+	RateHistory time.Duration
+	// Timeout bounds each Redis round trip. Default 2 seconds.
+	Timeout time.Duration
+}
+
+// Store is a Redis-backed spl.CounterStore, spl.RateStore and
+// spl.ReplayStore. It is safe for concurrent use.
+type Store struct {
+	client goredis.UniversalClient
+	opts   Options
+}
+
+// New returns a Store using client, which may be a single node, a Sentinel
+// failover client or a cluster client.
+func New(client goredis.UniversalClient, opts Options) *Store {
+	if opts.Prefix == "" {
+		opts.Prefix = "agent-safe:"
+	}
+	if opts.CounterTTL <= 0 {
+		opts.CounterTTL = 48 * time.Hour
+	}
+	if opts.RateHistory <= 0 {
+		opts.RateHistory = 7 * 24 * time.Hour
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Second
+	}
+	return &Store{client: client, opts: opts}
+}
+
+// checkAndIncrement increments KEYS[1] if it is below ARGV[1], setting its
+// expiry to ARGV[2] milliseconds when it has none, and returns 1 if it did.
+var checkAndIncrement = goredis.NewScript(`
+local n = tonumber(redis.call('GET', KEYS[1]) or '0')
+if n >= tonumber(ARGV[1]) then
+  return 0
+end
+redis.call('INCR', KEYS[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+func (s *Store) counterKey(action, window string) string {
+	return s.opts.Prefix + "count:" + window + ":" + action
+}
+
+// Count returns the number of times action was counted in window.
+func (s *Store) Count(action, window string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	n, err := s.client.Get(ctx, s.counterKey(action, window)).Int()
+	if errors.Is(err, goredis.Nil) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// CheckAndIncrement counts action in window if its count is below limit and
+// reports whether it did.
+func (s *Store) CheckAndIncrement(action, window string, limit int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	n, err := checkAndIncrement.Run(ctx, s.client, []string{s.counterKey(action, window)},
+		limit, s.opts.CounterTTL.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// Claim records id for ttl and reports true if it was not already recorded.
+func (s *Store) Claim(id string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, errors.New("redis: replay TTL must be positive")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	return s.client.SetNX(ctx, s.opts.Prefix+"seen:"+id, 1, ttl).Result()
+}
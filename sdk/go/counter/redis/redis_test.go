@@ -0,0 +1,173 @@
+package redis
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func newStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return New(client, Options{}), mr
+}
+
+func TestCheckAndIncrement(t *testing.T) {
+	s, mr := newStore(t)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := s.CheckAndIncrement("payments.create", "2026-01-31", 3)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 3 {
+		t.Fatalf("expected 3 of 20 concurrent requests allowed, got %d", allowed)
+	}
+	if n, err := s.Count("payments.create", "2026-01-31"); err != nil || n != 3 {
+		t.Fatalf("expected count 3, got %d, %v", n, err)
+	}
+	if n, err := s.Count("payments.create", "2026-02-01"); err != nil || n != 0 {
+		t.Fatalf("expected a new window to start at 0, got %d, %v", n, err)
+	}
+
+	if ttl := mr.TTL("agent-safe:count:2026-01-31:payments.create"); ttl != 48*time.Hour {
+		t.Fatalf("expected the count to expire in 48h, got %v", ttl)
+	}
+	mr.FastForward(49 * time.Hour)
+	if n, _ := s.Count("payments.create", "2026-01-31"); n != 0 {
+		t.Fatalf("expected the count to have expired, got %d", n)
+	}
+}
+
+func TestPerDayCount(t *testing.T) {
+	s, _ := newStore(t)
+	_, priv := spl.GenerateKeypair()
+	tok, err := spl.Mint(`(< (per-day-count "search" "2026-01-31") 2)`, priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := spl.VerifyTokenOptions{PerDayCount: spl.PerDayCountFrom(s)}
+	for i, want := range []bool{true, true, false} {
+		if got := spl.VerifyTokenObj(tok, map[string]any{}, opts).Allow; got != want {
+			t.Fatalf("request %d: expected allow=%v", i, want)
+		}
+		s.CheckAndIncrement("search", "2026-01-31", 10)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	s, mr := newStore(t)
+	if ok, err := s.Claim("sig-1", time.Minute); err != nil || !ok {
+		t.Fatalf("expected the first claim to succeed, got %v, %v", ok, err)
+	}
+	if ok, _ := s.Claim("sig-1", time.Minute); ok {
+		t.Fatal("expected a replay to be refused")
+	}
+	if ok, _ := s.Claim("sig-2", time.Minute); !ok {
+		t.Fatal("expected another id to be claimable")
+	}
+	mr.FastForward(2 * time.Minute)
+	if ok, _ := s.Claim("sig-1", time.Minute); !ok {
+		t.Fatal("expected the claim to expire")
+	}
+	if _, err := s.Claim("sig-3", 0); err == nil {
+		t.Fatal("expected a zero TTL to be refused")
+	}
+
+	mr.Close()
+	if ok, err := s.Claim("sig-4", time.Minute); ok || err == nil {
+		t.Fatal("expected an unreachable server to fail the claim")
+	}
+}
+
+func TestRates(t *testing.T) {
+	s, mr := newStore(t)
+	_, priv := spl.GenerateKeypair()
+	tok, err := spl.Mint(`(and (< (window-count "purchase" "24h") 2) (bucket-ok? "purchase" 5 "1h"))`, priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(when string) bool {
+		return spl.VerifyTokenObj(tok, map[string]any{}, spl.VerifyTokenOptions{Rates: s, Now: when}).Allow
+	}
+	for i, tc := range []struct {
+		when  string
+		allow bool
+	}{
+		{"2026-01-31T20:00:00Z", true},
+		{"2026-01-31T23:00:00Z", true},
+		{"2026-02-01T19:59:59Z", false},
+		{"2026-02-01T20:00:01Z", true},
+	} {
+		if got := at(tc.when); got != tc.allow {
+			t.Fatalf("request %d at %s: expected allow=%v", i, tc.when, tc.allow)
+		}
+	}
+	now := time.Date(2026, 2, 1, 20, 0, 1, 0, time.UTC)
+	if n, err := s.WindowCount("purchase", 24*time.Hour, now); err != nil || n != 2 {
+		t.Fatalf("expected 2 purchases in the window, got %d, %v", n, err)
+	}
+	if n, err := s.BucketTokens("purchase", spl.Bucket{Capacity: 5, Refill: time.Hour}, now); err != nil || n != 4 {
+		t.Fatalf("expected the bucket refilled to 4 of 5, got %v, %v", n, err)
+	}
+	if ttl := mr.TTL("agent-safe:window:purchase"); ttl != 7*24*time.Hour {
+		t.Fatalf("expected the history to expire in 7 days, got %v", ttl)
+	}
+
+	b := spl.Bucket{Capacity: 2, Refill: time.Minute}
+	for i, want := range []bool{true, true, false} {
+		if ok, err := s.BucketTake("search", b, now); err != nil || ok != want {
+			t.Fatalf("take %d: expected %v, got %v, %v", i, want, ok, err)
+		}
+	}
+	if ok, _ := s.BucketTake("search", b, now.Add(time.Minute)); !ok {
+		t.Fatal("expected a token to refill after a minute")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	src, _ := newStore(t)
+	dst, mr := newStore(t)
+	src.CheckAndIncrement("pay:eu", "2026-01-31", 5)
+	src.CheckAndIncrement("pay:eu", "2026-01-31", 5)
+	src.Claim("sig", time.Hour)
+	dst.CheckAndIncrement("pay:eu", "2026-01-31", 5)
+	for i := 0; i < 2; i++ {
+		if err := spl.CopyState(dst, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := dst.Count("pay:eu", "2026-01-31"); n != 2 {
+		t.Fatalf("expected count 2, got %d", n)
+	}
+	if ttl := mr.TTL("agent-safe:count:2026-01-31:pay:eu"); ttl <= 47*time.Hour {
+		t.Fatalf("expected the count to keep its expiry, got %v", ttl)
+	}
+	if ok, _ := dst.Claim("sig", time.Hour); ok {
+		t.Fatal("expected the imported claim to be refused")
+	}
+	snap, err := dst.Export()
+	if err != nil || len(snap.Counters) != 1 || len(snap.Nonces) != 1 || snap.Counters[0].Action != "pay:eu" {
+		t.Fatalf("unexpected snapshot %+v %v", snap, err)
+	}
+}
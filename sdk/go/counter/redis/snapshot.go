@@ -0,0 +1,164 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+var _ spl.StateStore = (*Store)(nil)
+
+// Export returns the per-day counts and replay identifiers under the
+// store's prefix. Keys are scanned one batch at a time, so each value is
+// exact but the snapshot is not of a single moment; export while
+// verifiers are stopped for a consistent backup. Window histories and
+// token buckets are not included.
+func (s *Store) Export() (*spl.Snapshot, error) {
+	ctx := context.Background()
+	now := time.Now()
+	snap := &spl.Snapshot{Taken: now.UTC()}
+	scan := func(ctx context.Context, c goredis.UniversalClient) error {
+		for _, kind := range []string{"count:", "seen:"} {
+			prefix := s.opts.Prefix + kind
+			iter := c.Scan(ctx, 0, prefix+"*", 500).Iterator()
+			var keys []string
+			flush := func() error {
+				if len(keys) == 0 {
+					return nil
+				}
+				if err := s.exportKeys(ctx, c, prefix, keys, now, snap); err != nil {
+					return err
+				}
+				keys = keys[:0]
+				return nil
+			}
+			for iter.Next(ctx) {
+				if keys = append(keys, iter.Val()); len(keys) == 500 {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			}
+			if err := iter.Err(); err != nil {
+				return err
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if cluster, ok := s.client.(*goredis.ClusterClient); ok {
+		// ForEachMaster runs concurrently; scan one master at a time.
+		var mu sync.Mutex
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *goredis.Client) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return scan(ctx, node)
+		})
+	} else {
+		err = scan(ctx, s.client)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// exportKeys reads keys, all beginning with prefix, into snap.
+func (s *Store) exportKeys(ctx context.Context, c goredis.UniversalClient, prefix string, keys []string, now time.Time, snap *spl.Snapshot) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	pipe := c.Pipeline()
+	gets := make([]*goredis.StringCmd, len(keys))
+	ttls := make([]*goredis.DurationCmd, len(keys))
+	for i, k := range keys {
+		gets[i] = pipe.Get(ctx, k)
+		ttls[i] = pipe.PTTL(ctx, k)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, goredis.Nil) {
+		return err
+	}
+	for i, k := range keys {
+		if gets[i].Err() != nil {
+			continue // expired since the scan
+		}
+		var exp time.Time
+		if ttl := ttls[i].Val(); ttl > 0 {
+			exp = now.Add(ttl).UTC()
+		}
+		name := strings.TrimPrefix(k, prefix)
+		if prefix == s.opts.Prefix+"seen:" {
+			snap.Nonces = append(snap.Nonces, spl.NonceState{ID: name, Expires: exp})
+			continue
+		}
+		// Windows are dates, without colons; actions may have them.
+		window, action, ok := strings.Cut(name, ":")
+		n, err := gets[i].Int()
+		if !ok || err != nil {
+			continue
+		}
+		snap.Counters = append(snap.Counters, spl.CounterState{Action: action, Window: window, Count: n, Expires: exp})
+	}
+	return nil
+}
+
+// raiseCount sets KEYS[1] to ARGV[1] with an expiry of ARGV[2]
+// milliseconds if it holds less.
+var raiseCount = goredis.NewScript(`
+local n = tonumber(redis.call('GET', KEYS[1]) or '0')
+if n < tonumber(ARGV[1]) then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+end
+return 0
+`)
+
+// extendClaim sets KEYS[1] with an expiry of ARGV[1] milliseconds unless it
+// already expires later.
+var extendClaim = goredis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 or (ttl >= 0 and ttl < tonumber(ARGV[1])) then
+  redis.call('SET', KEYS[1], 1, 'PX', ARGV[1])
+end
+return 0
+`)
+
+// Import merges a snapshot. Counts without an expiry are kept for
+// CounterTTL. Revocations are ignored.
+func (s *Store) Import(snap *spl.Snapshot) error {
+	now := time.Now()
+	run := func(script *goredis.Script, key string, args ...any) error {
+		ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+		defer cancel()
+		return script.Run(ctx, s.client, []string{key}, args...).Err()
+	}
+	for _, c := range snap.Counters {
+		ttl := s.opts.CounterTTL
+		if !c.Expires.IsZero() {
+			ttl = c.Expires.Sub(now)
+		}
+		if ttl.Milliseconds() <= 0 {
+			continue
+		}
+		if err := run(raiseCount, s.counterKey(c.Action, c.Window), c.Count, ttl.Milliseconds()); err != nil {
+			return err
+		}
+	}
+	for _, n := range snap.Nonces {
+		ttl := n.Expires.Sub(now)
+		if ttl.Milliseconds() <= 0 {
+			continue
+		}
+		if err := run(extendClaim, s.opts.Prefix+"seen:"+n.ID, ttl.Milliseconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
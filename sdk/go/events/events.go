@@ -0,0 +1,170 @@
+// Package events notifies other systems of policy decisions as they are
+// made, so that a household or an ops team hears at once when an agent is
+// denied or is using up its budget.
+//
+// Verify wraps spl.VerifyTokenObj and reports the decision to an Events:
+//
+//	hook, err := events.NewWebhook(events.WebhookOptions{URL: url, Secret: secret})
+//	defer hook.Close()
+//	res := events.Verify(hook, tok, req, opts)
+//
+// An integration that already has the result, such as a middleware's
+// OnDecision, calls Notify instead. Verify leaves out the request fields
+// opts.Classifier marks as sensitive; wrap ev with Redacting to do the same
+// for Notify.
+//
+// A Bus publishes the rest of a token's lifecycle as well: minting,
+// attenuation, presentation, nearing expiry and revocation.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Events receives decisions. Implementations must be safe for concurrent
+// use and should not block the verification that called them.
+type Events interface {
+	// OnAllow is called when a policy allows a request.
+	OnAllow(Event)
+	// OnDeny is called when a policy denies a request.
+	OnDeny(Event)
+	// OnError is called when verification fails before or during policy
+	// evaluation: a bad signature, an expired token, an evaluation error.
+	OnError(Event)
+}
+
+// Event kinds.
+const (
+	KindAllow = "allow"
+	KindDeny  = "deny"
+	KindError = "error"
+)
+
+// Event describes one decision.
+type Event struct {
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"`
+	// Token is the signature of the token verified.
+	Token   string         `json:"token,omitempty"`
+	Request map[string]any `json:"request,omitempty"`
+	// Error is the verification error for KindError.
+	Error   string `json:"error,omitempty"`
+	Sealed  bool   `json:"sealed"`
+	GasUsed int    `json:"gas_used"`
+	// Counts are the per-day-count values the policy read, for watching an
+	// agent's usage against its limits. Set by Verify only.
+	Counts []Count `json:"counts,omitempty"`
+}
+
+// Count is one per-day-count lookup.
+type Count struct {
+	Action string `json:"action"`
+	Day    string `json:"day"`
+	Count  int    `json:"count"`
+}
+
+// Notify reports a verification result to ev.
+func Notify(ev Events, tok *spl.Token, req map[string]any, res spl.VerifyTokenResult) {
+	notify(ev, tok, req, res, nil)
+}
+
+func notify(ev Events, tok *spl.Token, req map[string]any, res spl.VerifyTokenResult, counts []Count) {
+	e := Event{Time: time.Now().UTC(), Request: req, Error: res.ErrorMessage(), Sealed: res.Sealed, GasUsed: res.GasUsed, Counts: counts}
+	if tok != nil {
+		e.Token = tok.Signature
+	}
+	switch {
+	case res.Err != nil:
+		e.Kind = KindError
+		ev.OnError(e)
+	case res.Allow:
+		e.Kind = KindAllow
+		ev.OnAllow(e)
+	default:
+		e.Kind = KindDeny
+		ev.OnDeny(e)
+	}
+}
+
+// Verify verifies tok with spl.VerifyTokenObj and reports the result, with
+// the per-day counts the policy read from PerDayCount or Counters, to ev.
+func Verify(ev Events, tok *spl.Token, req map[string]any, opts spl.VerifyTokenOptions) spl.VerifyTokenResult {
+	var mu sync.Mutex
+	var counts []Count
+	record := func(action, day string, n int) {
+		mu.Lock()
+		counts = append(counts, Count{Action: action, Day: day, Count: n})
+		mu.Unlock()
+	}
+	if count := opts.PerDayCount; count != nil {
+		opts.PerDayCount = func(action, day string) int {
+			n := count(action, day)
+			record(action, day, n)
+			return n
+		}
+	}
+	if opts.Counters != nil {
+		opts.Counters = recordingCounters{opts.Counters, record}
+	}
+	res := spl.VerifyTokenObj(tok, req, opts)
+	notify(ev, tok, opts.Classifier.Redact(req), res, counts)
+	return res
+}
+
+// recordingCounters reports the counts read from a CounterStore.
+type recordingCounters struct {
+	spl.CounterStore
+	record func(action, day string, n int)
+}
+
+func (r recordingCounters) Count(action, window string) (int, error) {
+	n, err := r.CounterStore.Count(action, window)
+	if err == nil {
+		r.record(action, window, n)
+	}
+	return n, err
+}
+
+// Redacting returns ev with the request fields c marks as sensitive
+// redacted from every event's Request.
+func Redacting(ev Events, c *spl.FieldClassifier) Events {
+	if c == nil {
+		return ev
+	}
+	return redacting{ev, c}
+}
+
+type redacting struct {
+	ev Events
+	c  *spl.FieldClassifier
+}
+
+func (r redacting) OnAllow(e Event) { e.Request = r.c.Redact(e.Request); r.ev.OnAllow(e) }
+func (r redacting) OnDeny(e Event)  { e.Request = r.c.Redact(e.Request); r.ev.OnDeny(e) }
+func (r redacting) OnError(e Event) { e.Request = r.c.Redact(e.Request); r.ev.OnError(e) }
+
+// Funcs adapts functions to Events; nil fields ignore their events.
+type Funcs struct {
+	Allow, Deny, Error func(Event)
+}
+
+func (f Funcs) OnAllow(e Event) {
+	if f.Allow != nil {
+		f.Allow(e)
+	}
+}
+
+func (f Funcs) OnDeny(e Event) {
+	if f.Deny != nil {
+		f.Deny(e)
+	}
+}
+
+func (f Funcs) OnError(e Event) {
+	if f.Error != nil {
+		f.Error(e)
+	}
+}
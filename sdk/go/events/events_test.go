@@ -0,0 +1,69 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func TestVerify(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	tok, err := spl.Mint(`(and (= (get req "action") "pay") (< (per-day-count "pay" "2026-01-31") 3))`, priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Event
+	record := func(e Event) { got = append(got, e) }
+	ev := Funcs{Allow: record, Deny: record, Error: record}
+	opts := spl.VerifyTokenOptions{PerDayCount: func(string, string) int { return 2 }}
+
+	Verify(ev, tok, map[string]any{"action": "pay"}, opts)
+	Verify(ev, tok, map[string]any{"action": "refund"}, opts)
+	forged := *tok
+	forged.Policy = "#t"
+	Verify(ev, &forged, map[string]any{"action": "pay"}, opts)
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 events, got %+v", got)
+	}
+	if e := got[0]; e.Kind != KindAllow || e.Token != tok.Signature || len(e.Counts) != 1 || e.Counts[0] != (Count{"pay", "2026-01-31", 2}) {
+		t.Fatalf("unexpected allow event %+v", e)
+	}
+	if e := got[1]; e.Kind != KindDeny || e.Error != "" || e.Counts != nil {
+		t.Fatalf("unexpected deny event %+v", e)
+	}
+	if e := got[2]; e.Kind != KindError || e.Error == "" {
+		t.Fatalf("unexpected error event %+v", e)
+	}
+
+	got = nil
+	Verify(ev, tok, map[string]any{"action": "pay"}, spl.VerifyTokenOptions{Counters: &spl.MemoryCounters{}})
+	if len(got) != 1 || got[0].Kind != KindAllow || len(got[0].Counts) != 1 || got[0].Counts[0].Count != 0 {
+		t.Fatalf("expected the count read from Counters, got %+v", got)
+	}
+}
+
+func TestVerifyRedacts(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	tok, err := spl.Mint(`(= (get req "action") "pay")`, priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Event
+	record := func(e Event) { got = append(got, e) }
+	ev := Funcs{Allow: record, Deny: record, Error: record}
+	c := &spl.FieldClassifier{Fields: map[string]spl.Sensitivity{"card.number": spl.Redact}}
+	req := map[string]any{"action": "pay", "card": map[string]any{"number": "4111", "brand": "visa"}}
+
+	Verify(ev, tok, req, spl.VerifyTokenOptions{Classifier: c})
+	Notify(Redacting(ev, c), tok, req, spl.VerifyTokenResult{Allow: true})
+	for _, e := range got {
+		card := e.Request["card"].(map[string]any)
+		if card["number"] != spl.Redacted || card["brand"] != "visa" || e.Request["action"] != "pay" {
+			t.Fatalf("expected card.number redacted, got %+v", e.Request)
+		}
+	}
+	if len(got) != 2 || req["card"].(map[string]any)["number"] != "4111" {
+		t.Fatalf("expected 2 events and req unchanged, got %d, %+v", len(got), req)
+	}
+}
@@ -0,0 +1,225 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Lifecycle event kinds, besides KindAllow, KindDeny and KindError for
+// verifications.
+const (
+	KindMint       = "mint"
+	KindAttenuate  = "attenuate" // includes sealing
+	KindPresent    = "present"
+	KindExpireSoon = "expire_soon"
+	KindRevoke     = "revoke"
+)
+
+// DefaultExpireSoon is Bus.ExpireSoon when it is zero.
+const DefaultExpireSoon = 24 * time.Hour
+
+// LifecycleEvent is one step in the life of a token: its minting, an
+// attenuation, a presentation, a verification, its nearing expiry or its
+// revocation.
+type LifecycleEvent struct {
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"`
+	// TokenID identifies the token; see spl.TokenID.
+	TokenID string `json:"token_id"`
+	// Token is the token's signature, the identifier revocation lists use.
+	Token string `json:"token,omitempty"`
+	// ParentID is the TokenID of the token an attenuated token narrows.
+	ParentID     string `json:"parent_id,omitempty"`
+	PolicyDigest string `json:"policy_digest,omitempty"`
+	Expires      string `json:"expires,omitempty"`
+	// Decision is the record of a verification, for KindAllow, KindDeny
+	// and KindError.
+	Decision *spl.Decision `json:"decision,omitempty"`
+}
+
+// NewLifecycleEvent returns an event of kind about t, for publishing steps
+// Bus has no wrapper for, such as minting with spl.MintHMAC.
+func NewLifecycleEvent(kind string, t *spl.Token) LifecycleEvent {
+	return LifecycleEvent{
+		Kind:         kind,
+		Time:         time.Now().UTC(),
+		TokenID:      spl.TokenID(t),
+		Token:        t.Signature,
+		PolicyDigest: spl.PolicyDigest(t.Policy),
+		Expires:      t.Expires,
+	}
+}
+
+// Subscriber receives lifecycle events. Implementations must be safe for
+// concurrent use and should not block the operation that published them.
+type Subscriber interface {
+	OnLifecycle(LifecycleEvent)
+}
+
+// SubscriberFunc adapts a function to Subscriber.
+type SubscriberFunc func(LifecycleEvent)
+
+func (f SubscriberFunc) OnLifecycle(e LifecycleEvent) { f(e) }
+
+// Bus publishes the lifecycle of tokens to its subscribers, so dashboards
+// and anomaly detection can watch every capability from minting to
+// revocation through one hook. Its methods wrap the spl operations and
+// publish each one that succeeds. The zero Bus is ready to use and is safe
+// for concurrent use.
+type Bus struct {
+	// ExpireSoon is how near its expiry a verified token must be for the
+	// bus to publish KindExpireSoon, once per token; 0 means
+	// DefaultExpireSoon.
+	ExpireSoon time.Duration
+
+	mu     sync.Mutex
+	subs   []*subscription
+	warned map[string]time.Time // expire_soon published, by TokenID, until expiry
+}
+
+type subscription struct{ s Subscriber }
+
+// Subscribe adds s to the bus and returns a function that removes it.
+func (b *Bus) Subscribe(s Subscriber) (unsubscribe func()) {
+	sub := &subscription{s}
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, x := range b.subs {
+			if x == sub {
+				b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish sends e to every subscriber.
+func (b *Bus) Publish(e LifecycleEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.mu.Lock()
+	subs := b.subs
+	b.mu.Unlock()
+	for _, sub := range subs {
+		sub.s.OnLifecycle(e)
+	}
+}
+
+// Mint is spl.Mint, publishing KindMint.
+func (b *Bus) Mint(policy, privateKeyHex string, opts spl.MintOptions) (*spl.Token, error) {
+	t, err := spl.Mint(policy, privateKeyHex, opts)
+	if err != nil {
+		return nil, err
+	}
+	b.Publish(NewLifecycleEvent(KindMint, t))
+	return t, nil
+}
+
+// Attenuate is spl.Attenuate, publishing KindAttenuate.
+func (b *Bus) Attenuate(t *spl.Token, constraint, privateKeyHex string) (*spl.Token, error) {
+	return b.narrowed(t)(spl.Attenuate(t, constraint, privateKeyHex))
+}
+
+// Seal is spl.Seal, publishing KindAttenuate.
+func (b *Bus) Seal(t *spl.Token, privateKeyHex string) (*spl.Token, error) {
+	return b.narrowed(t)(spl.Seal(t, privateKeyHex))
+}
+
+// narrowed returns a function publishing KindAttenuate for a child of
+// parent, if one was made.
+func (b *Bus) narrowed(parent *spl.Token) func(*spl.Token, error) (*spl.Token, error) {
+	return func(child *spl.Token, err error) (*spl.Token, error) {
+		if err != nil {
+			return nil, err
+		}
+		e := NewLifecycleEvent(KindAttenuate, child)
+		e.ParentID = spl.TokenID(parent)
+		b.Publish(e)
+		return child, nil
+	}
+}
+
+// Present is spl.CreatePresentationSignature, publishing KindPresent.
+func (b *Bus) Present(t *spl.Token, agentPrivateKeyHex string) (string, error) {
+	sig, err := spl.CreatePresentationSignature(t, agentPrivateKeyHex)
+	if err != nil {
+		return "", err
+	}
+	b.Publish(NewLifecycleEvent(KindPresent, t))
+	return sig, nil
+}
+
+// Revoke revokes t's signature in s, publishing KindRevoke.
+func (b *Bus) Revoke(s spl.RevocationStore, t *spl.Token) error {
+	if err := s.Revoke(t.Signature); err != nil {
+		return err
+	}
+	b.Publish(NewLifecycleEvent(KindRevoke, t))
+	return nil
+}
+
+// Verify verifies t with v.DecidePresented and publishes the decision; see
+// Decided.
+func (b *Bus) Verify(ctx context.Context, v *spl.Verifier, t *spl.Token, req map[string]any, presentationSignature string) spl.Decision {
+	d := v.DecidePresented(ctx, t, req, presentationSignature)
+	b.Decided(t, d)
+	return d
+}
+
+// Decided publishes d, the decision of verifying t, as KindAllow,
+// KindDeny or KindError. A token that verified and expires within
+// ExpireSoon is also published as KindExpireSoon, the first time only.
+func (b *Bus) Decided(t *spl.Token, d spl.Decision) {
+	e := NewLifecycleEvent(KindError, t)
+	e.Time, e.Decision = d.EvaluatedAt.UTC(), &d
+	switch d.Outcome {
+	case spl.OutcomeAllow:
+		e.Kind = KindAllow
+	case spl.OutcomeDeny:
+		e.Kind = KindDeny
+	}
+	b.Publish(e)
+	if e.Kind != KindError && b.expiringSoon(e.TokenID, t.Expires, d.EvaluatedAt) {
+		e.Kind, e.Decision = KindExpireSoon, nil
+		b.Publish(e)
+	}
+}
+
+// expiringSoon reports whether a token expiring at expires is within
+// ExpireSoon of now and has not been reported before.
+func (b *Bus) expiringSoon(id, expires string, now time.Time) bool {
+	exp, err := time.Parse(time.RFC3339, expires)
+	if err != nil {
+		return false
+	}
+	window := b.ExpireSoon
+	if window == 0 {
+		window = DefaultExpireSoon
+	}
+	if exp.Sub(now) > window {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.warned[id]; ok {
+		return false
+	}
+	if b.warned == nil {
+		b.warned = map[string]time.Time{}
+	}
+	for k, until := range b.warned {
+		if now.After(until) {
+			delete(b.warned, k)
+		}
+	}
+	b.warned[id] = exp
+	return true
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+type revocations struct{ ids []string }
+
+func (r *revocations) Revoke(id string) error { r.ids = append(r.ids, id); return nil }
+func (r *revocations) IsRevoked(id string) (bool, error) {
+	for _, x := range r.ids {
+		if x == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestBusLifecycle(t *testing.T) {
+	var mu sync.Mutex
+	var got []LifecycleEvent
+	var bus Bus
+	unsubscribe := bus.Subscribe(SubscriberFunc(func(e LifecycleEvent) {
+		mu.Lock()
+		got = append(got, e)
+		mu.Unlock()
+	}))
+
+	_, issuer := spl.GenerateKeypair()
+	agentPub, agentPriv := spl.GenerateKeypair()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	parent, err := bus.Mint(`(<= (get req "amount") 100)`, issuer, spl.MintOptions{PoPKey: agentPub, Expires: "2026-01-01T12:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := bus.Attenuate(parent, `(= (get req "to") "bob")`, issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := bus.Present(child, agentPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := spl.NewVerifier(spl.VerifierConfig{VerifyTokenOptions: spl.VerifyTokenOptions{Now: now.Format(time.RFC3339)}})
+	ctx := context.Background()
+	if d := bus.Verify(ctx, v, child, map[string]any{"amount": 5.0, "to": "bob"}, sig); d.Outcome != spl.OutcomeAllow {
+		t.Fatalf("expected an allow, got %+v", d)
+	}
+	bus.Verify(ctx, v, child, map[string]any{"amount": 500.0, "to": "bob"}, sig)
+	bus.Verify(ctx, v, child, map[string]any{"amount": 5.0, "to": "bob"}, "")
+	if err := bus.Revoke(&revocations{}, child); err != nil {
+		t.Fatal(err)
+	}
+	unsubscribe()
+	bus.Verify(ctx, v, child, nil, sig)
+
+	var kinds []string
+	for _, e := range got {
+		kinds = append(kinds, e.Kind)
+	}
+	want := []string{KindMint, KindAttenuate, KindPresent, KindAllow, KindExpireSoon, KindDeny, KindError, KindRevoke}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("expected %v, got %v", want, kinds)
+	}
+	if got[1].ParentID != spl.TokenID(parent) || got[1].TokenID != spl.TokenID(child) || got[1].PolicyDigest != spl.PolicyDigest(child.Policy) {
+		t.Fatalf("unexpected attenuate event %+v", got[1])
+	}
+	if got[3].Decision == nil || got[3].Decision.TokenID != got[3].TokenID || !got[3].Time.Equal(now) {
+		t.Fatalf("expected the allow to carry its decision, got %+v", got[3])
+	}
+	if got[4].Decision != nil || got[4].Expires != "2026-01-01T12:00:00Z" {
+		t.Fatalf("unexpected expire_soon event %+v", got[4])
+	}
+	if got[7].Token != child.Signature {
+		t.Fatalf("expected the revoked signature, got %+v", got[7])
+	}
+}
@@ -0,0 +1,233 @@
+package events
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Webhook headers.
+const (
+	// SignatureHeader carries t=UNIX-SECONDS,v1=HEX, where HEX is the
+	// HMAC-SHA256 under the shared secret of "UNIX-SECONDS." followed by the
+	// body.
+	SignatureHeader = "Agent-Safe-Signature"
+	// DeliveryHeader carries an ID that is the same on every attempt to
+	// deliver one event, for receivers to drop duplicates.
+	DeliveryHeader = "Agent-Safe-Delivery"
+	// EventHeader carries the event's kind.
+	EventHeader = "Agent-Safe-Event"
+)
+
+// WebhookOptions configures a Webhook.
+type WebhookOptions struct {
+	// URL receives each event as a JSON POST.
+	URL string
+	// Secret signs each delivery; see SignatureHeader. Required.
+	Secret []byte
+	// Kinds, when set, selects the event kinds delivered, e.g. only
+	// KindDeny and KindError.
+	Kinds []string
+	// Client makes the requests; the default has a 10 second timeout.
+	Client *http.Client
+	// MaxAttempts bounds deliveries of one event. Default 5.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubling for each retry
+	// after. Default 1 second.
+	Backoff time.Duration
+	// QueueSize bounds events waiting for delivery; when the queue is full,
+	// new events are dropped. Default 1024.
+	QueueSize int
+	// OnDrop, when set, is called with each event that is dropped or could
+	// not be delivered, and why.
+	OnDrop func(Event, error)
+}
+
+// Webhook delivers events to an HTTP endpoint in the background, retrying
+// failed deliveries with exponential backoff. A 2xx response is success; a
+// network error, 408, 429 or 5xx is retried; any other status is final.
+type Webhook struct {
+	opts  WebhookOptions
+	kinds map[string]bool
+	queue chan Event
+	done  chan struct{}
+	close sync.Once
+	sleep func(time.Duration)
+}
+
+// NewWebhook starts a Webhook. Close it to flush the queue.
+func NewWebhook(opts WebhookOptions) (*Webhook, error) {
+	if opts.URL == "" {
+		return nil, errors.New("events: webhook URL required")
+	}
+	if len(opts.Secret) == 0 {
+		return nil, errors.New("events: webhook secret required")
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	w := &Webhook{
+		opts:  opts,
+		queue: make(chan Event, opts.QueueSize),
+		done:  make(chan struct{}),
+		sleep: time.Sleep,
+	}
+	if len(opts.Kinds) > 0 {
+		w.kinds = map[string]bool{}
+		for _, k := range opts.Kinds {
+			w.kinds[k] = true
+		}
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *Webhook) OnAllow(e Event) { w.enqueue(e) }
+func (w *Webhook) OnDeny(e Event)  { w.enqueue(e) }
+func (w *Webhook) OnError(e Event) { w.enqueue(e) }
+
+func (w *Webhook) enqueue(e Event) {
+	if w.kinds != nil && !w.kinds[e.Kind] {
+		return
+	}
+	select {
+	case w.queue <- e:
+	default:
+		w.drop(e, errors.New("events: webhook queue full"))
+	}
+}
+
+func (w *Webhook) drop(e Event, err error) {
+	if w.opts.OnDrop != nil {
+		w.opts.OnDrop(e, err)
+	}
+}
+
+// Close stops accepting events and returns once those queued have been
+// delivered or given up on. Events sent after Close panic.
+func (w *Webhook) Close() error {
+	w.close.Do(func() { close(w.queue) })
+	<-w.done
+	return nil
+}
+
+func (w *Webhook) run() {
+	defer close(w.done)
+	for e := range w.queue {
+		if err := w.deliver(e); err != nil {
+			w.drop(e, err)
+		}
+	}
+}
+
+func (w *Webhook) deliver(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	delivery := hex.EncodeToString(id)
+	backoff := w.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(body, e.Kind, delivery)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == w.opts.MaxAttempts {
+			return fmt.Errorf("events: webhook delivery failed after %d attempts: %w", attempt, err)
+		}
+		w.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (w *Webhook) post(body []byte, kind, delivery string) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, w.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, kind)
+	req.Header.Set(DeliveryHeader, delivery)
+	req.Header.Set(SignatureHeader, Sign(w.opts.Secret, time.Now(), body))
+	resp, err := w.opts.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	switch s := resp.StatusCode; {
+	case s >= 200 && s < 300:
+		return false, nil
+	case s == http.StatusRequestTimeout || s == http.StatusTooManyRequests || s >= 500:
+		return true, errors.New(resp.Status)
+	default:
+		return false, errors.New(resp.Status)
+	}
+}
+
+// Sign returns the SignatureHeader value for body sent at t.
+func Sign(secret []byte, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, body))
+}
+
+func mac(secret []byte, ts string, body []byte) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(ts + "."))
+	m.Write(body)
+	return m.Sum(nil)
+}
+
+// VerifySignature checks a SignatureHeader value for body, refusing
+// signatures more than tolerance away from now, for webhook receivers.
+func VerifySignature(secret []byte, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			if b, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, b)
+			}
+		}
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return errors.New("events: malformed signature header")
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+		return errors.New("events: signature timestamp outside tolerance")
+	}
+	want := mac(secret, ts, body)
+	for _, s := range sigs {
+		if hmac.Equal(s, want) {
+			return nil
+		}
+	}
+	return errors.New("events: invalid signature")
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	secret := []byte("s3cret")
+	var mu sync.Mutex
+	var received []Event
+	deliveries := map[string]int{}
+	failures := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := VerifySignature(secret, r.Header.Get(SignatureHeader), body, time.Minute, time.Now()); err != nil {
+			t.Errorf("signature: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries[r.Header.Get(DeliveryHeader)]++
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var e Event
+		json.Unmarshal(body, &e)
+		if r.Header.Get(EventHeader) != e.Kind {
+			t.Errorf("event header %q for a %s event", r.Header.Get(EventHeader), e.Kind)
+		}
+		received = append(received, e)
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhook(WebhookOptions{URL: srv.URL, Secret: secret, Kinds: []string{KindDeny, KindError}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var waits []time.Duration
+	w.sleep = func(d time.Duration) { waits = append(waits, d) }
+	w.OnAllow(Event{Kind: KindAllow})
+	w.OnDeny(Event{Kind: KindDeny, Token: "sig"})
+	w.OnError(Event{Kind: KindError, Error: "token expired"})
+	w.Close()
+
+	if len(received) != 2 || received[0].Token != "sig" || received[1].Error != "token expired" {
+		t.Fatalf("expected the deny and error events, got %+v", received)
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("expected one delivery ID per event, got %v", deliveries)
+	}
+	if len(waits) != 2 || waits[0] != time.Second || waits[1] != 2*time.Second {
+		t.Fatalf("expected backoff 1s then 2s, got %v", waits)
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if r.Header.Get(EventHeader) == KindDeny {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	var dropped []error
+	w, _ := NewWebhook(WebhookOptions{URL: srv.URL, Secret: []byte("k"), MaxAttempts: 3,
+		OnDrop: func(_ Event, err error) { dropped = append(dropped, err) }})
+	w.sleep = func(time.Duration) {}
+	w.OnDeny(Event{Kind: KindDeny})
+	w.OnError(Event{Kind: KindError})
+	w.Close()
+	if attempts != 4 || len(dropped) != 2 {
+		t.Fatalf("expected 1 attempt for the 400 and 3 for the 500, got %d attempts, %v", attempts, dropped)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	secret, body := []byte("k"), []byte(`{"kind":"deny"}`)
+	at := time.Unix(1767225600, 0)
+	h := Sign(secret, at, body)
+	for name, tc := range map[string]struct {
+		secret []byte
+		header string
+		body   []byte
+		now    time.Time
+		ok     bool
+	}{
+		"valid":          {secret, h, body, at.Add(time.Minute), true},
+		"wrong secret":   {[]byte("x"), h, body, at, false},
+		"altered body":   {secret, h, []byte(`{"kind":"allow"}`), at, false},
+		"too old":        {secret, h, body, at.Add(10 * time.Minute), false},
+		"malformed":      {secret, "v1=00", body, at, false},
+		"rotated secret": {secret, h + ",v1=" + Sign([]byte("old"), at, body)[len("t=1767225600,v1="):], body, at, true},
+	} {
+		if err := VerifySignature(tc.secret, tc.header, tc.body, 5*time.Minute, tc.now); (err == nil) != tc.ok {
+			t.Errorf("%s: expected ok=%v, got %v", name, tc.ok, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package extauthz implements Envoy's external authorization service
+// (envoy.service.auth.v3.Authorization) with Agent-Safe capability tokens,
+// so that any service behind Envoy or Istio can be gated without changes
+// to its code:
+//
+//	srv := grpc.NewServer()
+//	authv3.RegisterAuthorizationServer(srv, extauthz.New(extauthz.Options{
+//		Verify: spl.VerifyTokenOptions{KeyResolver: issuers},
+//	}))
+//
+// The token is read from the same headers as splhttp uses, and the policy
+// sees req as
+//
+//	{"method": "POST", "path": "/v1/payments", "query": {...},
+//	 "host": "payments.internal", "principal": "spiffe://...",
+//	 "body": {...}}
+//
+// where principal is the downstream peer's identity from mutual TLS and
+// body is present when the filter is configured with with_request_body and
+// the body is JSON. It lives in its own module so that the SDK itself stays
+// free of the Envoy dependencies.
+package extauthz
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/url"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+	"github.com/jmcentire/agent-safe/sdk/go/splhttp"
+)
+
+// Options configures the authorization server.
+type Options struct {
+	// Verify configures token verification: trust anchors, vars, counters
+	// and crypto callbacks. PresentationSignature is taken from each
+	// request's Agent-Safe-Presentation header instead.
+	Verify spl.VerifyTokenOptions
+	// Request, when set, adjusts the request map built from a check
+	// before the policy sees it.
+	Request func(check *authv3.CheckRequest, req map[string]any)
+	// OnDecision, when set, is called with every verification result, for
+	// logging and metrics.
+	OnDecision func(ctx context.Context, check *authv3.CheckRequest, res spl.VerifyTokenResult)
+}
+
+// Server answers Envoy's Check calls.
+type Server struct {
+	authv3.UnimplementedAuthorizationServer
+	opts Options
+}
+
+// New returns an authorization server; register it with
+// authv3.RegisterAuthorizationServer.
+func New(opts Options) *Server {
+	return &Server{opts: opts}
+}
+
+// Check allows the request when its token verifies and the token's policy
+// allows the request. Denials carry a 401 (no token), 400 (malformed token
+// or body) or 403 HTTP response with an splhttp.Denial body; Envoy sends it
+// to the client.
+func (s *Server) Check(ctx context.Context, check *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	h := check.GetAttributes().GetRequest().GetHttp()
+	headers := h.GetHeaders()
+	raw := tokenFromHeaders(headers)
+	if raw == "" {
+		return denied(typev3.StatusCode_Unauthorized, codes.Unauthenticated, "unauthorized", "no Agent-Safe token"), nil
+	}
+	tok, err := spl.ParseToken(raw)
+	if err != nil {
+		return denied(typev3.StatusCode_BadRequest, codes.Unauthenticated, "bad_request", err.Error()), nil
+	}
+	req, err := RequestMap(check)
+	if err != nil {
+		return denied(typev3.StatusCode_BadRequest, codes.InvalidArgument, "bad_request", err.Error()), nil
+	}
+	if s.opts.Request != nil {
+		s.opts.Request(check, req)
+	}
+	vopts := s.opts.Verify
+	vopts.PresentationSignature = headers[strings.ToLower(splhttp.PresentationHeader)]
+	res := spl.VerifyTokenObj(tok, req, vopts)
+	if s.opts.OnDecision != nil {
+		s.opts.OnDecision(ctx, check, res)
+	}
+	if !res.Allow {
+		reason := res.ErrorMessage()
+		if reason == "" {
+			reason = "policy denied the request"
+		}
+		return denied(typev3.StatusCode_Forbidden, codes.PermissionDenied, "forbidden", reason), nil
+	}
+	return &authv3.CheckResponse{
+		Status:       &status.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{OkResponse: &authv3.OkHttpResponse{}},
+	}, nil
+}
+
+// tokenFromHeaders finds the token in Envoy's lower-cased request headers,
+// as splhttp.TokenFromRequest does in an *http.Request.
+func tokenFromHeaders(headers map[string]string) string {
+	if auth := headers["authorization"]; auth != "" {
+		scheme, tok, ok := strings.Cut(auth, " ")
+		if ok && (strings.EqualFold(scheme, "AgentSafe") || strings.EqualFold(scheme, "Bearer")) {
+			return strings.TrimSpace(tok)
+		}
+	}
+	return strings.TrimSpace(headers[strings.ToLower(splhttp.TokenHeader)])
+}
+
+// RequestMap describes the HTTP request in a check as an SPL request.
+func RequestMap(check *authv3.CheckRequest) (map[string]any, error) {
+	attrs := check.GetAttributes()
+	h := attrs.GetRequest().GetHttp()
+	// Envoy sends the request target, path and query string together.
+	u, err := url.ParseRequestURI(h.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	query := map[string]any{}
+	for k, vs := range u.Query() {
+		if len(vs) == 1 {
+			query[k] = vs[0]
+			continue
+		}
+		list := make([]any, len(vs))
+		for i, v := range vs {
+			list[i] = v
+		}
+		query[k] = list
+	}
+	req := map[string]any{
+		"method":    h.GetMethod(),
+		"path":      u.Path,
+		"query":     query,
+		"host":      h.GetHost(),
+		"principal": attrs.GetSource().GetPrincipal(),
+	}
+	body := h.GetRawBody()
+	if body == nil {
+		body = []byte(h.GetBody())
+	}
+	if len(strings.TrimSpace(string(body))) > 0 && isJSON(h.GetHeaders()["content-type"]) {
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			return nil, err
+		}
+		req["body"] = v
+	}
+	return req, nil
+}
+
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+func denied(httpStatus typev3.StatusCode, code codes.Code, errCode, reason string) *authv3.CheckResponse {
+	body, _ := json.Marshal(splhttp.Denial{Error: errCode, Reason: reason})
+	return &authv3.CheckResponse{
+		Status: &status.Status{Code: int32(code), Message: reason},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{DeniedResponse: &authv3.DeniedHttpResponse{
+			Status: &typev3.HttpStatus{Code: httpStatus},
+			Headers: []*corev3.HeaderValueOption{{
+				Header: &corev3.HeaderValue{Key: "content-type", Value: "application/json"},
+			}},
+			Body: string(body),
+		}},
+	}
+}
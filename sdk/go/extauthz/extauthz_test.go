@@ -0,0 +1,86 @@
+package extauthz
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/grpc/codes"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+	"github.com/jmcentire/agent-safe/sdk/go/splhttp"
+)
+
+const testPolicy = `(and (= (get req "method") "POST")
+  (= (get req "path") "/pay")
+  (= (get req "principal") "spiffe://cluster.local/ns/agents/sa/shopper")
+  (<= (get (get req "body") "amount") 50))`
+
+func checkRequest(path, body string, headers map[string]string) *authv3.CheckRequest {
+	return &authv3.CheckRequest{Attributes: &authv3.AttributeContext{
+		Source: &authv3.AttributeContext_Peer{Principal: "spiffe://cluster.local/ns/agents/sa/shopper"},
+		Request: &authv3.AttributeContext_Request{Http: &authv3.AttributeContext_HttpRequest{
+			Method:  "POST",
+			Path:    path,
+			Host:    "payments.internal",
+			Headers: headers,
+			Body:    body,
+		}},
+	}}
+}
+
+func TestCheck(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	tok, _ := spl.Mint(testPolicy, priv, spl.MintOptions{})
+	compact, _ := tok.Compact()
+	auth := map[string]string{"authorization": "AgentSafe " + compact, "content-type": "application/json"}
+	s := New(Options{})
+	ctx := context.Background()
+
+	resp, err := s.Check(ctx, checkRequest("/pay?dry_run=1", `{"amount": 20}`, auth))
+	if err != nil || resp.Status.Code != int32(codes.OK) || resp.GetOkResponse() == nil {
+		t.Fatalf("expected allow, got %v, %v", resp, err)
+	}
+
+	resp, _ = s.Check(ctx, checkRequest("/pay", `{"amount": 80}`, auth))
+	denied := resp.GetDeniedResponse()
+	var d splhttp.Denial
+	if resp.Status.Code != int32(codes.PermissionDenied) || denied.GetStatus().GetCode() != typev3.StatusCode_Forbidden {
+		t.Fatalf("expected 403, got %v", resp)
+	}
+	if err := json.Unmarshal([]byte(denied.Body), &d); err != nil || d.Error != "forbidden" {
+		t.Fatalf("expected a denial body, got %q", denied.Body)
+	}
+
+	resp, _ = s.Check(ctx, checkRequest("/pay", `{"amount": 20}`, map[string]string{"content-type": "application/json"}))
+	if resp.GetDeniedResponse().GetStatus().GetCode() != typev3.StatusCode_Unauthorized {
+		t.Fatalf("expected 401 without a token, got %v", resp)
+	}
+	resp, _ = s.Check(ctx, checkRequest("/pay", `{"amount":`, auth))
+	if resp.GetDeniedResponse().GetStatus().GetCode() != typev3.StatusCode_BadRequest {
+		t.Fatalf("expected 400 for a malformed body, got %v", resp)
+	}
+
+	// The token may also come in Agent-Safe-Token, as JSON.
+	data, _ := json.Marshal(tok)
+	headers := map[string]string{"agent-safe-token": string(data)}
+	if resp, _ := s.Check(ctx, checkRequest("/pay", `{"amount": 50}`, headers)); resp.Status.Code != int32(codes.OK) {
+		t.Fatalf("expected allow via agent-safe-token, got %v", resp)
+	}
+}
+
+func TestRequestMap(t *testing.T) {
+	req, err := RequestMap(checkRequest("/items/7?tag=a&tag=b&dry_run=1", "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := req["query"].(map[string]any)
+	if req["path"] != "/items/7" || req["host"] != "payments.internal" || q["dry_run"] != "1" || len(q["tag"].([]any)) != 2 {
+		t.Fatalf("unexpected request map %v", req)
+	}
+	if _, ok := req["body"]; ok {
+		t.Fatal("expected no body")
+	}
+}
@@ -0,0 +1,430 @@
+// Package yaml decodes the subset of YAML used by agent-safe configuration
+// and test files: block mappings and sequences, flow collections, plain and
+// quoted scalars, literal (|) and folded (>) block scalars, and comments.
+// Anchors, tags, multi-document streams and complex keys are not supported.
+//
+// Values decode to the same types as encoding/json: map[string]any, []any,
+// string, float64, bool and nil. JSON input is accepted as is.
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Unmarshal decodes YAML into v using encoding/json rules, so struct fields
+// are matched by their json tags.
+func Unmarshal(data []byte, v any) error {
+	doc, err := Decode(data)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Decode parses a YAML document into generic values.
+func Decode(data []byte) (any, error) {
+	if t := bytes.TrimSpace(data); len(t) > 0 && (t[0] == '{' || t[0] == '[') {
+		var v any
+		if err := json.Unmarshal(t, &v); err == nil {
+			return v, nil
+		}
+	}
+	p := &parser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		p.lines = append(p.lines, line{num: i + 1, raw: raw})
+	}
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	indent, err := p.indent()
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.block(indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected content")
+	}
+	return v, nil
+}
+
+type line struct {
+	num int
+	raw string
+}
+
+type parser struct {
+	lines []line
+	pos   int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	n := len(p.lines)
+	if p.pos < len(p.lines) {
+		n = p.lines[p.pos].num
+	}
+	return fmt.Errorf("yaml: line %d: %s", n, fmt.Sprintf(format, args...))
+}
+
+// content returns the current line without indentation or comment.
+func (p *parser) content() string {
+	return strings.TrimSpace(stripComment(p.lines[p.pos].raw))
+}
+
+func (p *parser) skipBlank() {
+	for p.pos < len(p.lines) {
+		c := p.content()
+		if c != "" && c != "---" {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) indent() (int, error) {
+	raw := p.lines[p.pos].raw
+	n := len(raw) - len(strings.TrimLeft(raw, " \t"))
+	if strings.ContainsRune(raw[:n], '\t') {
+		return 0, p.errorf("tabs are not allowed for indentation")
+	}
+	return n, nil
+}
+
+// block parses the mapping or sequence starting at the current line, whose
+// entries all sit at indent.
+func (p *parser) block(indent int) (any, error) {
+	if c := p.content(); c == "-" || strings.HasPrefix(c, "- ") {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *parser) sequence(indent int) (any, error) {
+	out := []any{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return out, nil
+		}
+		ind, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		c := p.content()
+		if ind < indent || !(c == "-" || strings.HasPrefix(c, "- ")) {
+			if ind > indent {
+				return nil, p.errorf("bad indentation")
+			}
+			return out, nil
+		}
+		if ind > indent {
+			return nil, p.errorf("bad indentation")
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(c, "-"))
+		if rest == "" {
+			p.pos++
+			v, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			continue
+		}
+		if isMappingEntry(rest) {
+			// "- key: value" opens a mapping indented past the dash.
+			after := p.lines[p.pos].raw[ind+1:]
+			child := ind + 1 + len(after) - len(strings.TrimLeft(after, " "))
+			p.lines[p.pos].raw = strings.Repeat(" ", child) + strings.TrimLeft(after, " ")
+			v, err := p.mapping(child)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			continue
+		}
+		v, err := p.inline(rest, indent)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+}
+
+func (p *parser) mapping(indent int) (any, error) {
+	out := map[string]any{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return out, nil
+		}
+		ind, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		if ind < indent {
+			return out, nil
+		}
+		if ind > indent {
+			return nil, p.errorf("bad indentation")
+		}
+		c := p.content()
+		if c == "-" || strings.HasPrefix(c, "- ") {
+			return out, nil
+		}
+		key, rest, ok := splitEntry(c)
+		if !ok {
+			return nil, p.errorf("expected key: value")
+		}
+		if _, dup := out[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		if rest == "" {
+			p.pos++
+			// A sequence may sit at the same indent as its key.
+			p.skipBlank()
+			if p.pos < len(p.lines) {
+				if ind, _ := p.indent(); ind == indent {
+					if c := p.content(); c == "-" || strings.HasPrefix(c, "- ") {
+						v, err := p.sequence(indent)
+						if err != nil {
+							return nil, err
+						}
+						out[key] = v
+						continue
+					}
+				}
+			}
+			v, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+			continue
+		}
+		v, err := p.inline(rest, indent)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = v
+	}
+}
+
+// nested parses the block under a "key:" or "-" line, or null if there is
+// none.
+func (p *parser) nested(parent int) (any, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	ind, err := p.indent()
+	if err != nil {
+		return nil, err
+	}
+	if ind <= parent {
+		return nil, nil
+	}
+	return p.block(ind)
+}
+
+// inline parses a value on the current line and advances past it (and past
+// any block scalar body).
+func (p *parser) inline(s string, parent int) (any, error) {
+	if s == "|" || s == ">" || s == "|-" || s == ">-" {
+		p.pos++
+		return p.blockScalar(s, parent), nil
+	}
+	v, err := scalar(s)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.pos++
+	return v, nil
+}
+
+func (p *parser) blockScalar(style string, parent int) string {
+	var lines []string
+	indent := -1
+	for p.pos < len(p.lines) {
+		raw := p.lines[p.pos].raw
+		if strings.TrimSpace(raw) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		ind := len(raw) - len(strings.TrimLeft(raw, " "))
+		if ind <= parent {
+			break
+		}
+		if indent < 0 {
+			indent = ind
+		}
+		if ind < indent {
+			break
+		}
+		lines = append(lines, raw[indent:])
+		p.pos++
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var s string
+	if style[0] == '|' {
+		s = strings.Join(lines, "\n")
+	} else {
+		s = strings.Join(lines, " ")
+	}
+	if !strings.HasSuffix(style, "-") && s != "" {
+		s += "\n"
+	}
+	return s
+}
+
+// splitEntry splits "key: value" at the first unquoted ": " (or trailing
+// ":"), unquoting the key.
+func splitEntry(s string) (key, rest string, ok bool) {
+	i := indexOutsideQuotes(s, ':', func(j int) bool { return j+1 == len(s) || s[j+1] == ' ' })
+	if i <= 0 {
+		return "", "", false
+	}
+	key = strings.TrimSpace(s[:i])
+	if k, err := scalar(key); err == nil {
+		if ks, isStr := k.(string); isStr && (key[0] == '"' || key[0] == '\'') {
+			key = ks
+		}
+	}
+	return key, strings.TrimSpace(s[i+1:]), true
+}
+
+func isMappingEntry(s string) bool {
+	if s[0] == '[' || s[0] == '{' {
+		return false
+	}
+	_, _, ok := splitEntry(s)
+	return ok
+}
+
+// indexOutsideQuotes returns the first index of c outside quotes and flow
+// brackets for which accept returns true.
+func indexOutsideQuotes(s string, c byte, accept func(int) bool) int {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[' || ch == '{':
+			depth++
+		case ch == ']' || ch == '}':
+			depth--
+		case ch == c && depth == 0 && accept(i):
+			return i
+		}
+	}
+	return -1
+}
+
+// stripComment removes a trailing "# comment" that is not inside quotes.
+func stripComment(s string) string {
+	i := indexOutsideQuotes(s, '#', func(j int) bool { return j == 0 || s[j-1] == ' ' || s[j-1] == '\t' })
+	if i < 0 {
+		return s
+	}
+	return s[:i]
+}
+
+func scalar(s string) (any, error) {
+	switch {
+	case s == "":
+		return nil, nil
+	case s[0] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double-quoted string %s", s)
+		}
+		return v, nil
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("unterminated single-quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s[0] == '[':
+		return flow(s, '[', ']')
+	case s[0] == '{':
+		return flow(s, '{', '}')
+	}
+	switch s {
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if strings.ContainsRune("0123456789+-.", rune(s[0])) && !strings.ContainsAny(s, "xXpP_nN") {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+	}
+	return s, nil
+}
+
+// flow parses a flow sequence [a, b] or flow mapping {k: v}.
+func flow(s string, open, close byte) (any, error) {
+	if s[len(s)-1] != close {
+		return nil, fmt.Errorf("unterminated flow collection %s", s)
+	}
+	body := strings.TrimSpace(s[1 : len(s)-1])
+	var items []string
+	for body != "" {
+		i := indexOutsideQuotes(body, ',', func(int) bool { return true })
+		if i < 0 {
+			items = append(items, strings.TrimSpace(body))
+			break
+		}
+		items = append(items, strings.TrimSpace(body[:i]))
+		body = strings.TrimSpace(body[i+1:])
+	}
+	if open == '[' {
+		out := make([]any, 0, len(items))
+		for _, it := range items {
+			v, err := scalar(it)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+	out := make(map[string]any, len(items))
+	for _, it := range items {
+		k, rest, ok := splitEntry(it)
+		if !ok {
+			return nil, fmt.Errorf("invalid flow mapping entry %q", it)
+		}
+		v, err := scalar(rest)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, nil
+}
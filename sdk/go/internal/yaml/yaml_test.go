@@ -0,0 +1,125 @@
+package yaml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	src := `# verifier config
+now: 2025-10-01T00:00:00Z
+vars:
+  allowed_recipients: [niece@example.com, "mom@example.com"]
+  limit: 50
+  note: 'it''s # not a comment'
+crypto:
+  dpop: true    # enabled
+  vrf: false
+counters:
+  backend: static
+  counts:
+    payments.create:
+      "2025-09-29": 1
+cases:
+- name: small
+  request: {amount: 20, recipient: niece@example.com}
+  expect: allow
+-
+  name: nested
+  tags:
+    - a
+    - b
+policy: |
+  (and
+    (= 1 1))
+empty:
+`
+	got, err := Decode([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"now": "2025-10-01T00:00:00Z",
+		"vars": map[string]any{
+			"allowed_recipients": []any{"niece@example.com", "mom@example.com"},
+			"limit":              50.0,
+			"note":               "it's # not a comment",
+		},
+		"crypto": map[string]any{"dpop": true, "vrf": false},
+		"counters": map[string]any{
+			"backend": "static",
+			"counts": map[string]any{
+				"payments.create": map[string]any{"2025-09-29": 1.0},
+			},
+		},
+		"cases": []any{
+			map[string]any{
+				"name":    "small",
+				"request": map[string]any{"amount": 20.0, "recipient": "niece@example.com"},
+				"expect":  "allow",
+			},
+			map[string]any{"name": "nested", "tags": []any{"a", "b"}},
+		},
+		"policy": "(and\n  (= 1 1))\n",
+		"empty":  nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	got, err := Decode([]byte(`{"a": [1, "x"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, map[string]any{"a": []any{1.0, "x"}}) {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestDecodeScalars(t *testing.T) {
+	for src, want := range map[string]any{
+		"v: inf":     "inf",
+		"v: -1.5":    -1.5,
+		"v: 0x10":    "0x10",
+		"v: ~":       nil,
+		"v: \"\\t\"": "\t",
+		"v: a: b":    "a: b",
+	} {
+		got, err := Decode([]byte(src))
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if v := got.(map[string]any)["v"]; !reflect.DeepEqual(v, want) {
+			t.Fatalf("%s: got %#v, want %#v", src, v, want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, src := range []string{
+		"a: 1\na: 2",
+		"a:\n\tb: 1",
+		"a: [1, 2",
+		"a: 1\n   b: 2",
+		"just text",
+	} {
+		if _, err := Decode([]byte(src)); err == nil {
+			t.Fatalf("expected error for %q", src)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var cfg struct {
+		Now  string         `json:"now"`
+		Vars map[string]any `json:"vars"`
+	}
+	if err := Unmarshal([]byte("now: x\nvars:\n  a: 1\n"), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Now != "x" || cfg.Vars["a"] != 1.0 {
+		t.Fatalf("got %+v", cfg)
+	}
+}
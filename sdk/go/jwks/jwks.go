@@ -0,0 +1,284 @@
+// Package jwks trusts the issuer keys an organization publishes as a JSON
+// Web Key Set, the way OIDC providers publish their signing keys.
+//
+// A Resolver is an spl.KeyResolver: set it as VerifyTokenOptions.KeyResolver
+// and tokens must be signed by a key currently in the set.
+//
+//	r, err := jwks.New(jwks.Options{Issuer: "https://issuer.example.com"})
+//	res := spl.VerifyTokenObj(tok, req, spl.VerifyTokenOptions{KeyResolver: r})
+//
+// The set is fetched on first use and again once it is older than its TTL.
+// A token whose kid is not in the set triggers an early refetch, rate
+// limited by MinRefresh, so keys added by a rotation are picked up at once;
+// keys removed from the set stop verifying at the next refetch. If a
+// refetch fails, the last good set is used until it is MaxStale old.
+//
+// Lookups read the current set and never wait on a refetch that set can
+// answer: an expired set is refetched in the background, one fetch at a
+// time, while it keeps serving. Only a token the set cannot answer, because
+// there is no usable set or its kid is missing, waits for the fetch.
+package jwks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Options configures a Resolver. One of URL and Issuer is required.
+type Options struct {
+	// URL is the JWKS URL.
+	URL string
+	// Issuer is an OIDC issuer whose discovery document,
+	// Issuer/.well-known/openid-configuration, gives the JWKS URL in its
+	// jwks_uri. Used when URL is empty.
+	Issuer string
+	// Client makes the requests; the default has a 10 second timeout.
+	Client *http.Client
+	// TTL is how long a fetched set is used before it is refetched, unless
+	// the response's Cache-Control max-age is shorter. Default 15 minutes.
+	TTL time.Duration
+	// MinRefresh is the least time between fetches, however often unknown
+	// kids arrive. Default 1 minute.
+	MinRefresh time.Duration
+	// MaxStale is how long past its TTL the last good set is still used
+	// while refetches fail. Default 24 hours.
+	MaxStale time.Duration
+}
+
+// Resolver resolves token issuer keys from a JWKS. It is safe for
+// concurrent use.
+type Resolver struct {
+	opts Options
+	now  func() time.Time
+
+	mu        sync.RWMutex
+	jwksURL   string
+	keys      []key
+	fetched   time.Time // last successful fetch
+	expires   time.Time // when the fetched set should be refetched
+	attempted time.Time // last fetch attempt
+	lastErr   error
+	inflight  chan struct{} // closed when the running refetch ends
+}
+
+// key is a usable signing key from the set.
+type key struct {
+	kid, publicKey string
+}
+
+// New returns a Resolver; nothing is fetched until the first ResolveKey or
+// Refresh.
+func New(opts Options) (*Resolver, error) {
+	if opts.URL == "" && opts.Issuer == "" {
+		return nil, errors.New("jwks: URL or Issuer required")
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 15 * time.Minute
+	}
+	if opts.MinRefresh <= 0 {
+		opts.MinRefresh = time.Minute
+	}
+	if opts.MaxStale <= 0 {
+		opts.MaxStale = 24 * time.Hour
+	}
+	return &Resolver{opts: opts, now: time.Now, jwksURL: opts.URL}, nil
+}
+
+// ResolveKey returns the key in the set with the token's kid or, for a
+// token without one, the key equal to its public key. The at argument is
+// not used: a key is trusted for as long as it is published.
+func (r *Resolver) ResolveKey(t *spl.Token, _ time.Time) (string, error) {
+	now := r.now()
+	r.mu.RLock()
+	k, ok := r.lookup(t)
+	usable := !r.fetched.IsZero() && !now.After(r.expires.Add(r.opts.MaxStale))
+	due := now.Sub(r.attempted) >= r.opts.MinRefresh
+	// Refetch an expired set, or one that lacks the kid: perhaps the
+	// issuer rotated since the last fetch.
+	refetch := due && (now.After(r.expires) || !ok && r.keys != nil)
+	var done <-chan struct{} = r.inflight
+	r.mu.RUnlock()
+	if refetch {
+		done = r.refetch(now)
+	}
+	if done != nil && (!ok || !usable) {
+		<-done
+		r.mu.RLock()
+		k, ok = r.lookup(t)
+		usable = !r.fetched.IsZero() && !now.After(r.expires.Add(r.opts.MaxStale))
+		r.mu.RUnlock()
+	}
+	switch {
+	case !usable:
+		r.mu.RLock()
+		err := r.lastErr
+		r.mu.RUnlock()
+		if err != nil {
+			return "", fmt.Errorf("jwks: no current key set: %w", err)
+		}
+		return "", errors.New("jwks: no current key set")
+	case !ok:
+		if t.KeyID != "" {
+			return "", fmt.Errorf("jwks: no key with kid %q", t.KeyID)
+		}
+		return "", errors.New("jwks: token key is not in the key set")
+	}
+	return k.publicKey, nil
+}
+
+// lookup finds t's key in the current set; r.mu must be held.
+func (r *Resolver) lookup(t *spl.Token) (key, bool) {
+	for _, k := range r.keys {
+		if t.KeyID != "" && k.kid == t.KeyID || t.KeyID == "" && k.publicKey == t.PublicKey {
+			return k, true
+		}
+	}
+	return key{}, false
+}
+
+// refetch starts a background refetch at now unless one is running, and
+// returns a channel closed when the running one ends.
+func (r *Resolver) refetch(now time.Time) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.inflight == nil {
+		done := make(chan struct{})
+		r.inflight = done
+		go func() {
+			r.refresh(context.Background(), now)
+			r.mu.Lock()
+			r.inflight = nil
+			r.mu.Unlock()
+			close(done)
+		}()
+	}
+	return r.inflight
+}
+
+// Refresh fetches the key set now.
+func (r *Resolver) Refresh(ctx context.Context) error {
+	return r.refresh(ctx, r.now())
+}
+
+// refresh fetches the set without holding r.mu, so lookups continue
+// meanwhile, and installs it as fetched at at.
+func (r *Resolver) refresh(ctx context.Context, at time.Time) error {
+	r.mu.Lock()
+	r.attempted = at
+	url := r.jwksURL
+	r.mu.Unlock()
+
+	keys, url, maxAge, err := r.fetch(ctx, url)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastErr = err
+	if err != nil {
+		return err
+	}
+	ttl := r.opts.TTL
+	if maxAge >= 0 && maxAge < ttl {
+		ttl = max(maxAge, r.opts.MinRefresh)
+	}
+	r.jwksURL = url
+	r.keys, r.fetched, r.expires = keys, at, at.Add(ttl)
+	return nil
+}
+
+// fetch fetches the set from jwksURL, discovering the URL first when it is
+// empty, and returns the keys, the URL and the set's max-age.
+func (r *Resolver) fetch(ctx context.Context, jwksURL string) ([]key, string, time.Duration, error) {
+	if jwksURL == "" {
+		var doc struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		issuer := strings.TrimSuffix(r.opts.Issuer, "/")
+		if _, err := r.get(ctx, issuer+"/.well-known/openid-configuration", &doc); err != nil {
+			return nil, "", 0, err
+		}
+		if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+			return nil, "", 0, fmt.Errorf("jwks: discovery document is for issuer %q", doc.Issuer)
+		}
+		if doc.JWKSURI == "" {
+			return nil, "", 0, errors.New("jwks: discovery document has no jwks_uri")
+		}
+		jwksURL = doc.JWKSURI
+	}
+	var set struct {
+		Keys []struct {
+			spl.JWK
+			Use string `json:"use"`
+		} `json:"keys"`
+	}
+	maxAge, err := r.get(ctx, jwksURL, &set)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	keys := []key{}
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" || j.D != "" {
+			continue
+		}
+		// Keys of other types, such as RSA, cannot sign tokens.
+		_, pub, _, err := spl.KeyFromJWK(&j.JWK)
+		if err != nil {
+			continue
+		}
+		kid := j.Kid
+		if kid == "" {
+			kid = j.Thumbprint()
+		}
+		keys = append(keys, key{kid: kid, publicKey: pub})
+	}
+	return keys, jwksURL, maxAge, nil
+}
+
+// get decodes the JSON document at url into v and returns its
+// Cache-Control max-age, or -1 when it has none.
+func (r *Resolver) get(ctx context.Context, url string, v any) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return -1, fmt.Errorf("jwks: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := r.opts.Client.Do(req)
+	if err != nil {
+		return -1, fmt.Errorf("jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return -1, fmt.Errorf("jwks: %s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return -1, fmt.Errorf("jwks: %s: %w", url, err)
+	}
+	return maxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+func maxAge(cacheControl string) time.Duration {
+	for _, d := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if strings.EqualFold(name, "no-store") || strings.EqualFold(name, "no-cache") {
+			return 0
+		}
+		if strings.EqualFold(name, "max-age") {
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				return time.Duration(n) * time.Second
+			}
+		}
+	}
+	return -1
+}
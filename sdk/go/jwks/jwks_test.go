@@ -0,0 +1,261 @@
+package jwks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// issuer serves an OIDC discovery document and a JWKS of its current keys.
+type issuer struct {
+	*httptest.Server
+	mu           sync.Mutex
+	keys         []*spl.JWK
+	fetches      int
+	cacheControl string
+	fail         bool
+	// stall, when set, holds each JWKS response until it is closed.
+	stall chan struct{}
+}
+
+func newIssuer(t *testing.T) *issuer {
+	iss := &issuer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + "/jwks.json"})
+	})
+	mux.HandleFunc("/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		iss.mu.Lock()
+		stall := iss.stall
+		iss.mu.Unlock()
+		if stall != nil {
+			<-stall
+		}
+		iss.mu.Lock()
+		defer iss.mu.Unlock()
+		iss.fetches++
+		if iss.fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if iss.cacheControl != "" {
+			w.Header().Set("Cache-Control", iss.cacheControl)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": append([]any{
+			map[string]string{"kty": "RSA", "kid": "rsa-1", "n": "AQAB", "e": "AQAB"},
+		}, anySlice(iss.keys)...)})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func anySlice(keys []*spl.JWK) []any {
+	out := make([]any, len(keys))
+	for i, k := range keys {
+		out[i] = k
+	}
+	return out
+}
+
+// rotate publishes a new signing key and returns a token minted with it.
+func (iss *issuer) rotate(t *testing.T, alg string) *spl.Token {
+	t.Helper()
+	pub, priv, err := spl.GenerateKeypairAlg(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, _ := spl.KeyToJWK(alg, pub, "")
+	kr, _ := spl.NewKeyRing(spl.KeyRingEntry{ID: j.Kid, Alg: alg, PublicKey: pub, PrivateKey: priv})
+	tok, err := kr.Mint(`(= 1 1)`, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss.mu.Lock()
+	iss.keys = append(iss.keys, j)
+	iss.mu.Unlock()
+	return tok
+}
+
+// clock is a settable time source for the resolver.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newResolver(t *testing.T, opts Options) (*Resolver, *clock) {
+	t.Helper()
+	r, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &clock{time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r.now = c.now
+	return r, c
+}
+
+// settle waits for a background refetch to finish.
+func (r *Resolver) settle() {
+	r.mu.RLock()
+	done := r.inflight
+	r.mu.RUnlock()
+	if done != nil {
+		<-done
+	}
+}
+
+func (iss *issuer) fetchCount() int {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	return iss.fetches
+}
+
+func verify(r *Resolver, tok *spl.Token) spl.VerifyTokenResult {
+	return spl.VerifyTokenObj(tok, map[string]any{}, spl.VerifyTokenOptions{KeyResolver: r})
+}
+
+func TestResolverDiscoveryAndRotation(t *testing.T) {
+	iss := newIssuer(t)
+	first := iss.rotate(t, spl.AlgEd25519)
+	r, c := newResolver(t, Options{Issuer: iss.URL})
+
+	if res := verify(r, first); !res.Allow {
+		t.Fatalf("expected allow, got %q", res.ErrorMessage())
+	}
+	if res := verify(r, first); !res.Allow || iss.fetches != 1 {
+		t.Fatalf("expected the cached set to be reused, got %d fetches", iss.fetches)
+	}
+
+	// A new key is picked up when a token using it arrives, once
+	// MinRefresh has passed.
+	second := iss.rotate(t, spl.AlgES256)
+	if res := verify(r, second); res.Allow {
+		t.Fatal("expected the refetch to wait for MinRefresh")
+	}
+	c.advance(2 * time.Minute)
+	if res := verify(r, second); !res.Allow {
+		t.Fatalf("expected the rotated key to verify, got %q", res.ErrorMessage())
+	}
+
+	// A key dropped from the set stops verifying once the set expires.
+	iss.mu.Lock()
+	iss.keys = iss.keys[1:]
+	iss.mu.Unlock()
+	c.advance(16 * time.Minute)
+	verify(r, first) // served from the expired set while it is refetched
+	r.settle()
+	if res := verify(r, first); res.Allow || !strings.Contains(res.ErrorMessage(), "no key with kid") {
+		t.Fatalf("expected the retired key to be refused, got %+v", res)
+	}
+
+	// A token signed by a key outside the set, claiming a published kid,
+	// fails the signature check.
+	_, otherPriv := spl.GenerateKeypair()
+	forged, _ := spl.Mint(`(= 1 1)`, otherPriv, spl.MintOptions{})
+	forged.KeyID = second.KeyID
+	if res := verify(r, forged); res.Allow {
+		t.Fatal("expected a forged token to be refused")
+	}
+}
+
+func TestResolverCacheControlAndOutages(t *testing.T) {
+	iss := newIssuer(t)
+	tok := iss.rotate(t, spl.AlgEd25519)
+	iss.cacheControl = "public, max-age=120"
+	r, c := newResolver(t, Options{URL: iss.URL + "/jwks.json", MaxStale: time.Hour})
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c.advance(3 * time.Minute)
+	verify(r, tok)
+	r.settle()
+	if iss.fetchCount() != 2 {
+		t.Fatalf("expected max-age to shorten the TTL, got %d fetches", iss.fetches)
+	}
+
+	iss.mu.Lock()
+	iss.fail = true
+	iss.mu.Unlock()
+	c.advance(10 * time.Minute)
+	if res := verify(r, tok); !res.Allow {
+		t.Fatalf("expected the last good set to ride out an outage, got %q", res.ErrorMessage())
+	}
+	r.settle()
+	c.advance(2 * time.Hour)
+	if res := verify(r, tok); res.Allow || !strings.Contains(res.ErrorMessage(), "503") {
+		t.Fatalf("expected a stale set to be refused, got %+v", res)
+	}
+}
+
+func TestResolverRefetchesInBackground(t *testing.T) {
+	iss := newIssuer(t)
+	tok := iss.rotate(t, spl.AlgEd25519)
+	r, c := newResolver(t, Options{URL: iss.URL + "/jwks.json"})
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stalled refetch of an expired set holds up no token the set can
+	// answer, and runs once however many arrive.
+	stall := make(chan struct{})
+	iss.mu.Lock()
+	iss.stall = stall
+	iss.mu.Unlock()
+	c.advance(16 * time.Minute)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 3; i++ {
+			if !verify(r, tok).Allow {
+				done <- false
+				return
+			}
+		}
+		done <- true
+	}()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("expected the expired set to keep verifying")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected lookups not to wait on the refetch")
+	}
+	close(stall)
+	r.settle()
+	if n := iss.fetchCount(); n != 2 {
+		t.Fatalf("expected one refetch, got %d fetches", n-1)
+	}
+
+	// Without a set, every lookup waits for the one fetch.
+	stall = make(chan struct{})
+	iss.mu.Lock()
+	iss.stall = stall
+	iss.mu.Unlock()
+	fresh, _ := newResolver(t, Options{URL: iss.URL + "/jwks.json"})
+	results := make(chan bool, 3)
+	for i := 0; i < 3; i++ {
+		go func() { results <- verify(fresh, tok).Allow }()
+	}
+	close(stall)
+	for i := 0; i < 3; i++ {
+		if !<-results {
+			t.Fatal("expected each first lookup to wait for the set")
+		}
+	}
+	if n := iss.fetchCount(); n != 3 {
+		t.Fatalf("expected one fetch for the first lookups, got %d", n-2)
+	}
+}
+
+func TestNewRequiresSource(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Fatal("expected an error without URL or Issuer")
+	}
+}
@@ -0,0 +1,127 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// AWSCredentials are the credentials used to sign KMS requests.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// EnvCredentials reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN.
+func EnvCredentials(context.Context) (AWSCredentials, error) {
+	c := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return c, errors.New("kms: AWS credentials not set in environment")
+	}
+	return c, nil
+}
+
+// AWSConfig locates an AWS KMS asymmetric signing key.
+type AWSConfig struct {
+	Region string
+	// KeyID is a key ID, key ARN, alias name or alias ARN. The key spec must
+	// be ECC_NIST_P256 or ECC_NIST_EDWARDS25519.
+	KeyID string
+	// Credentials is called for every request so rotating credentials
+	// (instance roles, STS) can be refreshed. Defaults to EnvCredentials.
+	Credentials func(ctx context.Context) (AWSCredentials, error)
+	Endpoint    string // default https://kms.<region>.amazonaws.com
+	Client      *http.Client
+}
+
+// AWSSigner signs with an AWS KMS key.
+type AWSSigner struct {
+	cfg AWSConfig
+	pub crypto.PublicKey
+}
+
+// NewAWSSigner fetches the key's public key.
+func NewAWSSigner(ctx context.Context, cfg AWSConfig) (*AWSSigner, error) {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://kms." + cfg.Region + ".amazonaws.com"
+	}
+	if cfg.Credentials == nil {
+		cfg.Credentials = EnvCredentials
+	}
+	s := &AWSSigner{cfg: cfg}
+	var out struct {
+		PublicKey []byte // base64 in JSON
+	}
+	if err := s.call(ctx, "GetPublicKey", map[string]string{"KeyId": cfg.KeyID}, &out); err != nil {
+		return nil, err
+	}
+	pub, err := parsePublicKey(out.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	s.pub = pub
+	return s, nil
+}
+
+func (s *AWSSigner) call(ctx context.Context, action string, in, out any) error {
+	creds, err := s.cfg.Credentials(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	signV4(req, body, creds, s.cfg.Region, "kms", time.Now())
+	return send(s.cfg.Client, req, out)
+}
+
+// Public returns the KMS key's public key.
+func (s *AWSSigner) Public() crypto.PublicKey { return s.pub }
+
+// Sign implements crypto.Signer.
+func (s *AWSSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.SignContext(context.Background(), digest, opts)
+}
+
+// SignContext is Sign with a caller-supplied context.
+func (s *AWSSigner) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if err := checkOpts(s.pub, digest, opts); err != nil {
+		return nil, err
+	}
+	in := map[string]any{"KeyId": s.cfg.KeyID, "Message": digest}
+	if _, ok := s.pub.(*ecdsa.PublicKey); ok {
+		in["MessageType"] = "DIGEST"
+		in["SigningAlgorithm"] = "ECDSA_SHA_256"
+	} else {
+		in["MessageType"] = "RAW"
+		in["SigningAlgorithm"] = "ED25519_SHA_512"
+	}
+	var out struct {
+		Signature string
+	}
+	if err := s.call(ctx, "Sign", in, &out); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Signature)
+}
@@ -0,0 +1,208 @@
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+const testPolicy = `(and (= (get req "action") "read") (<= (get req "amount") 100))`
+
+func mintAndVerify(t *testing.T, signer crypto.Signer) {
+	t.Helper()
+	tok, err := spl.MintWithSigner(testPolicy, signer, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := map[string]any{"action": "read", "amount": 50.0}
+	if r := spl.VerifyTokenObj(tok, req, spl.VerifyTokenOptions{}); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+}
+
+func spkiPEM(t *testing.T, pub crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// localSign signs the way the services do: DER ECDSA over a digest, plain
+// Ed25519 over a message.
+func localSign(t *testing.T, key crypto.Signer, input []byte) []byte {
+	opts := crypto.Hash(0)
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		opts = crypto.SHA256
+	}
+	sig, err := key.Sign(rand.Reader, input, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func testKeys(t *testing.T) map[string]crypto.Signer {
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed, _ := ed25519.GenerateKey(rand.Reader)
+	return map[string]crypto.Signer{"p256": ec, "ed25519": ed}
+}
+
+func TestVaultSigner(t *testing.T) {
+	for name, key := range testKeys(t) {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Vault-Token") != "s.test" {
+					http.Error(w, "permission denied", http.StatusForbidden)
+					return
+				}
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/v1/transit/keys/issuer":
+					typ, pub := "ecdsa-p256", spkiPEM(t, key.Public())
+					if edPub, ok := key.Public().(ed25519.PublicKey); ok {
+						typ, pub = "ed25519", base64.StdEncoding.EncodeToString(edPub)
+					}
+					json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+						"type": typ, "latest_version": 2,
+						"keys": map[string]any{"2": map[string]string{"public_key": pub}},
+					}})
+				case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1/transit/sign/issuer"):
+					var in struct{ Input string }
+					json.NewDecoder(r.Body).Decode(&in)
+					input, _ := base64.StdEncoding.DecodeString(in.Input)
+					sig := localSign(t, key, input)
+					json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{
+						"signature": "vault:v2:" + base64.StdEncoding.EncodeToString(sig),
+					}})
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			s, err := NewVaultSigner(context.Background(), VaultConfig{Address: srv.URL, Token: "s.test", Key: "issuer"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mintAndVerify(t, s)
+		})
+	}
+}
+
+func TestGCPSigner(t *testing.T) {
+	const keyVersion = "projects/p/locations/global/keyRings/r/cryptoKeys/issuer/cryptoKeyVersions/1"
+	for name, key := range testKeys(t) {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer ya29.test" {
+					http.Error(w, "unauthenticated", http.StatusUnauthorized)
+					return
+				}
+				switch r.URL.Path {
+				case "/v1/" + keyVersion + "/publicKey":
+					json.NewEncoder(w).Encode(map[string]string{"pem": spkiPEM(t, key.Public())})
+				case "/v1/" + keyVersion + ":asymmetricSign":
+					var in struct {
+						Data   []byte
+						Digest struct{ SHA256 []byte }
+					}
+					json.NewDecoder(r.Body).Decode(&in)
+					input := in.Data
+					if input == nil {
+						input = in.Digest.SHA256
+					}
+					json.NewEncoder(w).Encode(map[string][]byte{"signature": localSign(t, key, input)})
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			s, err := NewGCPSigner(context.Background(), GCPConfig{
+				KeyVersion: keyVersion,
+				Endpoint:   srv.URL,
+				Token:      func(context.Context) (string, error) { return "ya29.test", nil },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mintAndVerify(t, s)
+		})
+	}
+}
+
+func TestAWSSigner(t *testing.T) {
+	for name, key := range testKeys(t) {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDTEST/") {
+					http.Error(w, "missing signature", http.StatusForbidden)
+					return
+				}
+				switch r.Header.Get("X-Amz-Target") {
+				case "TrentService.GetPublicKey":
+					der, _ := x509.MarshalPKIXPublicKey(key.Public())
+					json.NewEncoder(w).Encode(map[string][]byte{"PublicKey": der})
+				case "TrentService.Sign":
+					var in struct{ Message []byte }
+					json.NewDecoder(r.Body).Decode(&in)
+					json.NewEncoder(w).Encode(map[string][]byte{"Signature": localSign(t, key, in.Message)})
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			s, err := NewAWSSigner(context.Background(), AWSConfig{
+				Region:   "us-east-1",
+				KeyID:    "alias/issuer",
+				Endpoint: srv.URL,
+				Credentials: func(context.Context) (AWSCredentials, error) {
+					return AWSCredentials{AccessKeyID: "AKIDTEST", SecretAccessKey: "secret"}, nil
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mintAndVerify(t, s)
+		})
+	}
+}
+
+// Example from the AWS General Reference, "Signature Version 4 signing process".
+func TestSignV4Vector(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signV4(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+}
+
+func TestSignerRejectsWrongHash(t *testing.T) {
+	ec, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err := checkOpts(&ec.PublicKey, make([]byte, 48), crypto.SHA384); err == nil {
+		t.Fatal("expected SHA-384 to be rejected for P-256 keys")
+	}
+}
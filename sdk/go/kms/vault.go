@@ -0,0 +1,119 @@
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// VaultConfig locates a HashiCorp Vault transit key.
+type VaultConfig struct {
+	Address   string // e.g. https://vault.example.com:8200
+	Token     string
+	Namespace string // Vault Enterprise namespace, optional
+	Mount     string // transit mount path, default "transit"
+	Key       string // ecdsa-p256 or ed25519 key name
+	Client    *http.Client
+}
+
+// VaultSigner signs with the latest version of a Vault transit key.
+type VaultSigner struct {
+	cfg VaultConfig
+	pub crypto.PublicKey
+}
+
+// NewVaultSigner reads the key's public half from Vault.
+func NewVaultSigner(ctx context.Context, cfg VaultConfig) (*VaultSigner, error) {
+	if cfg.Mount == "" {
+		cfg.Mount = "transit"
+	}
+	var out struct {
+		Data struct {
+			Type          string `json:"type"`
+			LatestVersion int    `json:"latest_version"`
+			Keys          map[string]struct {
+				PublicKey string `json:"public_key"`
+			} `json:"keys"`
+		} `json:"data"`
+	}
+	if err := doJSON(ctx, cfg.Client, http.MethodGet, cfg.url("keys"), cfg.header(), nil, &out); err != nil {
+		return nil, err
+	}
+	k, ok := out.Data.Keys[strconv.Itoa(out.Data.LatestVersion)]
+	if !ok {
+		return nil, errors.New("kms: vault key has no public key for its latest version")
+	}
+	s := &VaultSigner{cfg: cfg}
+	switch out.Data.Type {
+	case "ecdsa-p256":
+		pub, err := parsePublicKey([]byte(k.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+		s.pub = pub
+	case "ed25519":
+		raw, err := base64.StdEncoding.DecodeString(k.PublicKey)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, errors.New("kms: invalid vault ed25519 public key")
+		}
+		s.pub = ed25519.PublicKey(raw)
+	default:
+		return nil, fmt.Errorf("kms: unsupported vault key type %q", out.Data.Type)
+	}
+	return s, nil
+}
+
+func (c *VaultConfig) url(op string) string {
+	return strings.TrimRight(c.Address, "/") + "/v1/" + strings.Trim(c.Mount, "/") + "/" + op + "/" + c.Key
+}
+
+func (c *VaultConfig) header() http.Header {
+	h := http.Header{"X-Vault-Token": {c.Token}}
+	if c.Namespace != "" {
+		h.Set("X-Vault-Namespace", c.Namespace)
+	}
+	return h
+}
+
+// Public returns the transit key's public key.
+func (s *VaultSigner) Public() crypto.PublicKey { return s.pub }
+
+// Sign implements crypto.Signer.
+func (s *VaultSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.SignContext(context.Background(), digest, opts)
+}
+
+// SignContext is Sign with a caller-supplied context.
+func (s *VaultSigner) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if err := checkOpts(s.pub, digest, opts); err != nil {
+		return nil, err
+	}
+	in := map[string]any{"input": base64.StdEncoding.EncodeToString(digest)}
+	url := s.cfg.url("sign")
+	if opts.HashFunc() == crypto.SHA256 {
+		url += "/sha2-256"
+		in["prehashed"] = true
+		in["marshaling_algorithm"] = "asn1"
+	}
+	var out struct {
+		Data struct {
+			Signature string `json:"signature"`
+		} `json:"data"`
+	}
+	if err := doJSON(ctx, s.cfg.Client, http.MethodPost, url, s.cfg.header(), in, &out); err != nil {
+		return nil, err
+	}
+	// Signatures are formatted vault:v<version>:<base64>.
+	i := strings.LastIndexByte(out.Data.Signature, ':')
+	if !strings.HasPrefix(out.Data.Signature, "vault:") || i < 0 {
+		return nil, errors.New("kms: malformed vault signature")
+	}
+	return base64.StdEncoding.DecodeString(out.Data.Signature[i+1:])
+}
@@ -0,0 +1,257 @@
+// Package ledger tracks what each token has spent per action and period,
+// in typed currency amounts, behind the sum-amount op. A Ledger is an
+// spl.LedgerStore:
+//
+//	l := ledger.New(ledger.Options{Convert: ledger.Rates("USD", map[string]float64{"EUR": 0.92, "GBP": 0.79})})
+//	res := spl.VerifyTokenObj(tok, req, spl.VerifyTokenOptions{Ledger: l})
+//
+// so that a policy of (<= (sum-amount "pay" "month" "USD") 500) allows at
+// most $500 of payments a month, whichever currencies they are made in.
+// Spend is kept in the currency it was made in and converted when a total
+// is asked for, at the rates of that moment.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Amount is a sum of money in the minor units of an ISO 4217 currency:
+// Amount{1250, "USD"} is $12.50.
+type Amount struct {
+	Minor    int64  `json:"minor"`
+	Currency string `json:"currency"`
+}
+
+// minorDigits lists the currencies whose minor unit is not a hundredth.
+var minorDigits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// Exponent returns the number of decimal digits in currency's minor unit:
+// 2 for most currencies, 0 for JPY, 3 for KWD.
+func Exponent(currency string) int {
+	if d, ok := minorDigits[currency]; ok {
+		return d
+	}
+	return 2
+}
+
+func checkCurrency(c string) error {
+	if len(c) != 3 {
+		return fmt.Errorf("ledger: invalid currency %q", c)
+	}
+	for i := 0; i < 3; i++ {
+		if c[i] < 'A' || c[i] > 'Z' {
+			return fmt.Errorf("ledger: invalid currency %q", c)
+		}
+	}
+	return nil
+}
+
+// NewAmount returns value in currency, rounded to the nearest minor unit.
+func NewAmount(value float64, currency string) (Amount, error) {
+	if err := checkCurrency(currency); err != nil {
+		return Amount{}, err
+	}
+	minor := math.Round(value * math.Pow10(Exponent(currency)))
+	if math.IsNaN(minor) || math.Abs(minor) >= 1<<62 {
+		return Amount{}, fmt.Errorf("ledger: amount %v out of range", value)
+	}
+	return Amount{Minor: int64(minor), Currency: currency}, nil
+}
+
+// Value returns a in major units: 12.5 for $12.50.
+func (a Amount) Value() float64 {
+	return float64(a.Minor) / math.Pow10(Exponent(a.Currency))
+}
+
+func (a Amount) String() string {
+	return strconv.FormatFloat(a.Value(), 'f', Exponent(a.Currency), 64) + " " + a.Currency
+}
+
+// Converter converts a into currency to at the rates at time at. It is the
+// hook for exchange rates: a fixed table, as Rates returns, or a lookup in
+// a rates service.
+type Converter func(a Amount, to string, at time.Time) (Amount, error)
+
+// Rates returns a Converter from fixed rates: perBase[c] is the units of
+// currency c one unit of base buys.
+func Rates(base string, perBase map[string]float64) Converter {
+	rate := func(c string) (float64, bool) {
+		if c == base {
+			return 1, true
+		}
+		r, ok := perBase[c]
+		return r, ok && r > 0
+	}
+	return func(a Amount, to string, _ time.Time) (Amount, error) {
+		from, ok := rate(a.Currency)
+		if !ok {
+			return Amount{}, fmt.Errorf("ledger: no rate for %s", a.Currency)
+		}
+		into, ok := rate(to)
+		if !ok {
+			return Amount{}, fmt.Errorf("ledger: no rate for %s", to)
+		}
+		return NewAmount(a.Value()/from*into, to)
+	}
+}
+
+// Options configures a Ledger.
+type Options struct {
+	// Convert converts between currencies. Without it, a budget can only be
+	// spent in the currency it is kept in.
+	Convert Converter
+	// Now returns the current time, for conversions. Default time.Now.
+	Now func() time.Time
+}
+
+// Ledger is an in-memory spl.LedgerStore. It is safe for concurrent use.
+type Ledger struct {
+	opts   Options
+	mu     sync.Mutex
+	totals map[spl.LedgerKey]map[string]int64 // minor units by currency
+}
+
+var _ spl.LedgerStore = (*Ledger)(nil)
+
+// New returns an empty ledger.
+func New(opts Options) *Ledger {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Ledger{opts: opts, totals: map[spl.LedgerKey]map[string]int64{}}
+}
+
+func (l *Ledger) convert(a Amount, to string) (Amount, error) {
+	if a.Currency == to || a.Minor == 0 {
+		return Amount{Minor: a.Minor, Currency: to}, nil
+	}
+	if l.opts.Convert == nil {
+		return Amount{}, fmt.Errorf("ledger: cannot convert %s to %s without a converter", a.Currency, to)
+	}
+	out, err := l.opts.Convert(a, to, l.opts.Now())
+	if err != nil {
+		return Amount{}, err
+	}
+	if out.Currency != to {
+		return Amount{}, fmt.Errorf("ledger: converter returned %s, not %s", out.Currency, to)
+	}
+	return out, nil
+}
+
+// total returns k's spend plus pending in currency. l.mu must be held.
+func (l *Ledger) total(k spl.LedgerKey, pending Amount, currency string) (Amount, error) {
+	if err := checkCurrency(currency); err != nil {
+		return Amount{}, err
+	}
+	sum, err := l.convert(pending, currency)
+	if err != nil {
+		return Amount{}, err
+	}
+	for c, minor := range l.totals[k] {
+		a, err := l.convert(Amount{Minor: minor, Currency: c}, currency)
+		if err != nil {
+			return Amount{}, err
+		}
+		sum.Minor += a.Minor
+	}
+	return sum, nil
+}
+
+func pendingAmount(m spl.Money) (Amount, error) {
+	if m.Amount < 0 {
+		return Amount{}, errors.New("ledger: negative spend")
+	}
+	return NewAmount(m.Amount, m.Currency)
+}
+
+// Total returns what the budget k has spent, in currency.
+func (l *Ledger) Total(k spl.LedgerKey, currency string) (Amount, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total(k, Amount{Currency: currency}, currency)
+}
+
+func (l *Ledger) Spent(k spl.LedgerKey, pending spl.Money, currency string) (float64, error) {
+	p, err := pendingAmount(pending)
+	if err != nil {
+		return 0, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t, err := l.total(k, p, currency)
+	return t.Value(), err
+}
+
+func (l *Ledger) Spend(k spl.LedgerKey, pending spl.Money, limit spl.SpendLimit) (bool, error) {
+	p, err := pendingAmount(pending)
+	if err != nil {
+		return false, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t, err := l.total(k, p, limit.Currency)
+	if err != nil {
+		return false, err
+	}
+	if !limit.Allows(t.Value()) {
+		return false, nil
+	}
+	if l.totals[k] == nil {
+		l.totals[k] = map[string]int64{}
+	}
+	l.totals[k][p.Currency] += p.Minor
+	return true, nil
+}
+
+// Refund takes back spend recorded against k, such as a reversed payment.
+// A budget's spend in a currency cannot go below zero.
+func (l *Ledger) Refund(k spl.LedgerKey, a Amount) error {
+	if err := checkCurrency(a.Currency); err != nil {
+		return err
+	}
+	if a.Minor < 0 {
+		return errors.New("ledger: negative refund")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t := l.totals[k]
+	if t[a.Currency] < a.Minor {
+		return fmt.Errorf("ledger: refund of %s exceeds the %s spent", a, Amount{t[a.Currency], a.Currency})
+	}
+	if t == nil {
+		// Nothing was spent against k, so the refund is of zero.
+		return nil
+	}
+	t[a.Currency] -= a.Minor
+	return nil
+}
+
+// Prune removes the budgets of periods that have ended by now, which
+// sum-amount no longer reads.
+func (l *Ledger) Prune(now time.Time) {
+	current := map[string]bool{}
+	for _, p := range []string{"day", "week", "month", "year"} {
+		id, _ := spl.LedgerPeriod(p, now)
+		current[id] = true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k := range l.totals {
+		if !current[k.Period] {
+			delete(l.totals, k)
+		}
+	}
+}
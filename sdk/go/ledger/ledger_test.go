@@ -0,0 +1,126 @@
+package ledger
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func TestAmount(t *testing.T) {
+	for _, tc := range []struct {
+		value    float64
+		currency string
+		want     string
+	}{
+		{12.5, "USD", "12.50 USD"},
+		{0.125, "USD", "0.13 USD"},
+		{1999.6, "JPY", "2000 JPY"},
+		{1.2345, "KWD", "1.235 KWD"},
+	} {
+		a, err := NewAmount(tc.value, tc.currency)
+		if err != nil || a.String() != tc.want {
+			t.Fatalf("%v %s: expected %s, got %s %v", tc.value, tc.currency, tc.want, a, err)
+		}
+	}
+	if _, err := NewAmount(1, "usd"); err == nil {
+		t.Fatal("expected a lowercase currency to be refused")
+	}
+}
+
+func TestRates(t *testing.T) {
+	convert := Rates("USD", map[string]float64{"EUR": 0.8, "JPY": 150})
+	eur, _ := NewAmount(40, "EUR")
+	got, err := convert(eur, "JPY", time.Time{})
+	if err != nil || got != (Amount{7500, "JPY"}) {
+		t.Fatalf("expected 7500 JPY, got %v %v", got, err)
+	}
+	if _, err := convert(eur, "GBP", time.Time{}); err == nil {
+		t.Fatal("expected a currency without a rate to be refused")
+	}
+}
+
+func TestLedgerEnforcesBudget(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	tok, err := spl.Mint(`(and (= (get req "action") "pay") (<= (sum-amount "pay" "month" "USD") 500))`, priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(Options{Convert: Rates("USD", map[string]float64{"EUR": 0.8})})
+	opts := spl.VerifyTokenOptions{Ledger: l, Now: "2026-10-16T12:00:00Z"}
+	pay := func(amount float64, currency string) bool {
+		return spl.VerifyTokenObj(tok, map[string]any{"action": "pay", "amount": amount, "currency": currency}, opts).Allow
+	}
+	steps := []struct {
+		amount   float64
+		currency string
+		allow    bool
+	}{
+		{300, "USD", true},
+		{160, "EUR", true}, // $200
+		{0.01, "USD", false},
+		{0, "USD", true},
+	}
+	for i, s := range steps {
+		if got := pay(s.amount, s.currency); got != s.allow {
+			t.Fatalf("step %d: expected %v, got %v", i, s.allow, got)
+		}
+	}
+	k := spl.LedgerKey{Token: tok.Signature, Action: "pay", Period: "2026-10"}
+	if total, _ := l.Total(k, "USD"); total != (Amount{50000, "USD"}) {
+		t.Fatalf("expected $500 spent, got %v", total)
+	}
+	if err := l.Refund(k, Amount{5000, "EUR"}); err != nil {
+		t.Fatal(err)
+	}
+	if !pay(62.5, "USD") || pay(0.01, "USD") {
+		t.Fatal("expected a refund of €50 to allow $62.50 more")
+	}
+	// A budget with no spend refunds nothing, and no more than nothing.
+	if err := l.Refund(spl.LedgerKey{}, Amount{0, "USD"}); err != nil {
+		t.Fatalf("expected a zero refund of an unspent budget, got %v", err)
+	}
+	if err := l.Refund(spl.LedgerKey{}, Amount{1, "USD"}); err == nil {
+		t.Fatal("expected a refund of an unspent budget to fail")
+	}
+
+	opts.Now = "2026-11-01T00:00:00Z"
+	if !pay(500, "USD") {
+		t.Fatal("expected a new month to start a new budget")
+	}
+	l.Prune(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC))
+	if total, _ := l.Total(k, "USD"); total.Minor != 0 {
+		t.Fatalf("expected October pruned, got %v", total)
+	}
+
+	l = New(Options{})
+	opts.Ledger = l
+	if pay(5, "EUR") {
+		t.Fatal("expected spend in another currency to need a converter")
+	}
+}
+
+func TestLedgerConcurrent(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	tok, _ := spl.Mint(`(< (sum-amount "pay" "day" "USD") 100)`, priv, spl.MintOptions{})
+	l := New(Options{})
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if spl.VerifyTokenObj(tok, map[string]any{"amount": 10.0}, spl.VerifyTokenOptions{Ledger: l}).Allow {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 9 {
+		t.Fatalf("expected 9 payments of $10 to stay below $100, got %d", allowed)
+	}
+}
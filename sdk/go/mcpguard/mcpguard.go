@@ -0,0 +1,371 @@
+// Package mcpguard keeps an LLM agent inside its capability token when it
+// calls Model Context Protocol tools.
+//
+// Every tool invocation becomes an SPL request,
+//
+//	{"tool": "send_email", "arguments": {"to": "...", ...},
+//	 "caller": "claude-desktop"}
+//
+// that is checked against the agent's token before the tool runs. Guard.Check
+// does this for a single call; Guard.Proxy sits between an MCP client and a
+// stdio tool server, forwarding everything except denied tools/call
+// requests, which it answers itself with a tool error the model can read.
+// The caller is Guard.Caller or, failing that, the clientInfo name the
+// client sent in initialize.
+package mcpguard
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// MetaTokenKey is the params._meta key under which a client may send a
+// token, JSON or compact, with an individual call instead of relying on
+// Guard.Token.
+const MetaTokenKey = "agent-safe.io/token"
+
+// Call is one tool invocation.
+type Call struct {
+	Tool      string
+	Arguments map[string]any
+	Caller    string
+}
+
+// Guard checks tool calls against a capability token.
+type Guard struct {
+	// Token is the session's capability token, used for calls that do not
+	// carry their own under MetaTokenKey.
+	Token *spl.Token
+	// Verify configures token verification: trust anchors, vars, counters
+	// and crypto callbacks.
+	Verify spl.VerifyTokenOptions
+	// Revoked, when set, reports tokens to refuse whatever their
+	// signature, such as those on a revocation list.
+	Revoked func(tok *spl.Token) bool
+	// Caller identifies the client to policies; when empty, Proxy uses the
+	// clientInfo name from initialize.
+	Caller string
+	// Request, when set, adjusts the request map built for a call before
+	// the policy sees it.
+	Request func(call Call, req map[string]any)
+	// OnDecision, when set, is called with every verification result, for
+	// audit logs.
+	OnDecision func(call Call, res spl.VerifyTokenResult)
+}
+
+// DeniedError reports a tool call the token does not allow.
+type DeniedError struct {
+	Tool   string
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("agent-safe denied tool %q: %s", e.Tool, e.Reason)
+}
+
+// RequestMap describes a tool call as an SPL request.
+func RequestMap(call Call) map[string]any {
+	args := call.Arguments
+	if args == nil {
+		args = map[string]any{}
+	}
+	return map[string]any{"tool": call.Tool, "arguments": args, "caller": call.Caller}
+}
+
+// Check verifies the call against the guard's token and returns a
+// *DeniedError unless the token allows it.
+func (g *Guard) Check(call Call) error {
+	return g.check(call, g.Token)
+}
+
+func (g *Guard) check(call Call, tok *spl.Token) error {
+	if call.Caller == "" {
+		call.Caller = g.Caller
+	}
+	if tok == nil {
+		return &DeniedError{call.Tool, "no capability token"}
+	}
+	if g.Revoked != nil && g.Revoked(tok) {
+		return &DeniedError{call.Tool, "token revoked"}
+	}
+	req := RequestMap(call)
+	if g.Request != nil {
+		g.Request(call, req)
+	}
+	res := spl.VerifyTokenObj(tok, req, g.Verify)
+	if g.OnDecision != nil {
+		g.OnDecision(call, res)
+	}
+	if !res.Allow {
+		reason := res.ErrorMessage()
+		if reason == "" {
+			reason = "policy denied the call"
+		}
+		return &DeniedError{call.Tool, reason}
+	}
+	return nil
+}
+
+// message is the part of a JSON-RPC message the proxy reads.
+type message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type callParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+	Meta      map[string]any `json:"_meta"`
+}
+
+// Proxy relays newline-delimited JSON-RPC, the MCP stdio transport, between
+// a client (clientIn, clientOut) and a tool server (serverIn, serverOut). It
+// answers denied tools/call requests itself and forwards everything else.
+// It returns when the client's input ends, after closing serverIn if it is
+// an io.Closer, or when either side fails.
+func (g *Guard) Proxy(clientIn io.Reader, clientOut io.Writer, serverIn io.Writer, serverOut io.Reader) error {
+	var mu sync.Mutex // serializes writes to clientOut
+	writeClient := func(line []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := clientOut.Write(withNewline(line))
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		sc := newScanner(serverOut)
+		for sc.Scan() {
+			if err := writeClient(sc.Bytes()); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- sc.Err()
+	}()
+
+	caller := g.Caller
+	sc := newScanner(clientIn)
+	for sc.Scan() {
+		line := sc.Bytes()
+		reply, name := g.filter(line, caller)
+		if name != "" {
+			caller = name
+		}
+		var err error
+		if reply != nil {
+			err = writeClient(reply)
+		} else {
+			_, err = serverIn.Write(withNewline(line))
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case err := <-done:
+			return err
+		default:
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if c, ok := serverIn.(io.Closer); ok {
+		c.Close()
+	}
+	return <-done
+}
+
+// withNewline copies a scanned line and terminates it; appending in place
+// would write into the scanner's buffer.
+func withNewline(line []byte) []byte {
+	out := make([]byte, len(line)+1)
+	copy(out, line)
+	out[len(line)] = '\n'
+	return out
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	return sc
+}
+
+// filter inspects one client message. It returns the reply to send
+// instead of forwarding a denied tools/call, and the client's name when the
+// message is an initialize request.
+//
+// The guard and the server must read a message alike, so filter answers
+// anything it cannot read exactly itself: a line that is not a single JSON
+// object, such as a batch; an object with a key twice, even in different
+// case; or one spelling a field it reads in another case, such as
+// "METHOD".
+func (g *Guard) filter(line []byte, caller string) (reply []byte, clientName string) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil, ""
+	}
+	var m message
+	if err := decodeStrict(line, &m, "id", "method", "params"); err != nil {
+		json.Unmarshal(line, &m) // for the ID to answer, if there is one
+		return rpcError(m.ID, "agent-safe: "+err.Error()), ""
+	}
+	switch m.Method {
+	case "initialize":
+		var p struct {
+			ClientInfo struct {
+				Name string `json:"name"`
+			} `json:"clientInfo"`
+		}
+		json.Unmarshal(m.Params, &p)
+		if g.Caller == "" {
+			return nil, p.ClientInfo.Name
+		}
+	case "tools/call":
+		var p callParams
+		if err := decodeStrict(m.Params, &p, "name", "arguments", "_meta"); err != nil {
+			return toolError(m.ID, "agent-safe: malformed tools/call params: "+err.Error()), ""
+		}
+		tok := g.Token
+		if raw, ok := p.Meta[MetaTokenKey]; ok {
+			var err error
+			if tok, err = metaToken(raw); err != nil {
+				return toolError(m.ID, "agent-safe: "+err.Error()), ""
+			}
+		}
+		if err := g.check(Call{Tool: p.Name, Arguments: p.Arguments, Caller: caller}, tok); err != nil {
+			return toolError(m.ID, err.Error()), ""
+		}
+	}
+	return nil, ""
+}
+
+// decodeStrict decodes data, which must be a single JSON object, into v,
+// after checking that no object in it repeats a key, even in different
+// case, and that the keys fields names are spelled exactly so.
+func decodeStrict(data []byte, v any, fields ...string) error {
+	if err := checkKeys(data); err != nil {
+		return err
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return errors.New("message is not a JSON object")
+	}
+	for key := range top {
+		for _, f := range fields {
+			if key != f && strings.EqualFold(key, f) {
+				return fmt.Errorf("key %q must be spelled %q", key, f)
+			}
+		}
+	}
+	return json.Unmarshal(data, v)
+}
+
+// checkKeys walks the JSON value in data and rejects any object that
+// repeats a key, comparing keys case-insensitively as encoding/json does.
+func checkKeys(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	type frame struct {
+		object bool
+		keys   map[string]struct{} // folded with foldKey
+	}
+	var stack []*frame
+	valueNext := false // the last token was an object key
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.New("message is not valid JSON")
+		}
+		if d, ok := tok.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				stack = append(stack, &frame{object: d == '{'})
+			default:
+				stack = stack[:len(stack)-1]
+			}
+			valueNext = false
+			continue
+		}
+		key, isString := tok.(string)
+		if top := len(stack) - 1; top >= 0 && stack[top].object && !valueNext && isString {
+			f := stack[top]
+			if f.keys == nil {
+				f.keys = map[string]struct{}{}
+			}
+			folded := foldKey(key)
+			if _, ok := f.keys[folded]; ok {
+				return fmt.Errorf("duplicate key %q", key)
+			}
+			f.keys[folded] = struct{}{}
+			valueNext = true
+			continue
+		}
+		valueNext = false
+	}
+}
+
+// foldKey maps each rune of key to the least rune of its simple case
+// folding orbit, so two keys fold alike exactly when strings.EqualFold
+// reports them equal.
+func foldKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		least := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			least = min(least, f)
+		}
+		return least
+	}, key)
+}
+
+// metaToken parses a token sent in params._meta as a compact string or a
+// JSON object.
+func metaToken(raw any) (*spl.Token, error) {
+	if s, ok := raw.(string); ok {
+		return spl.ParseToken(s)
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	return spl.ParseToken(string(data))
+}
+
+// rpcError is a JSON-RPC invalid-request error, for messages the proxy
+// will not forward.
+func rpcError(id json.RawMessage, text string) []byte {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	out, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   map[string]any{"code": -32600, "message": text},
+	})
+	return out
+}
+
+// toolError is a tools/call result flagged isError, which MCP clients hand
+// to the model rather than treating as a protocol failure.
+func toolError(id json.RawMessage, text string) []byte {
+	out, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"result": map[string]any{
+			"content": []map[string]any{{"type": "text", "text": text}},
+			"isError": true,
+		},
+	})
+	return out
+}
@@ -0,0 +1,186 @@
+package mcpguard
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+const testPolicy = `(and (= (get req "tool") "send_email")
+  (member (get (get req "arguments") "to") (tuple "alice@example.com"))
+  (= (get req "caller") "test-client"))`
+
+func mint(t *testing.T, policy string) *spl.Token {
+	t.Helper()
+	_, priv := spl.GenerateKeypair()
+	tok, err := spl.Mint(policy, priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func TestCheck(t *testing.T) {
+	g := &Guard{Token: mint(t, testPolicy), Caller: "test-client"}
+	if err := g.Check(Call{Tool: "send_email", Arguments: map[string]any{"to": "alice@example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	err := g.Check(Call{Tool: "send_email", Arguments: map[string]any{"to": "mallory@example.com"}})
+	var denied *DeniedError
+	if !errors.As(err, &denied) || denied.Tool != "send_email" {
+		t.Fatalf("expected a DeniedError, got %v", err)
+	}
+	g.Revoked = func(*spl.Token) bool { return true }
+	if err := g.Check(Call{Tool: "send_email", Arguments: map[string]any{"to": "alice@example.com"}}); err == nil {
+		t.Fatal("expected a revoked token to be denied")
+	}
+	if err := (&Guard{}).Check(Call{Tool: "send_email"}); err == nil {
+		t.Fatal("expected a guard without a token to deny")
+	}
+}
+
+// echoServer answers every request it reads with its method.
+func echoServer(in io.Reader, out io.WriteCloser) {
+	defer out.Close()
+	sc := bufio.NewScanner(in)
+	for sc.Scan() {
+		var m message
+		json.Unmarshal(sc.Bytes(), &m)
+		reply, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": m.ID, "result": map[string]any{"served": m.Method}})
+		out.Write(append(reply, '\n'))
+	}
+}
+
+func TestProxy(t *testing.T) {
+	open, _ := mint(t, `(= 1 1)`).Compact()
+	lines := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"clientInfo":{"name":"test-client"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"send_email","arguments":{"to":"alice@example.com"}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"send_email","arguments":{"to":"mallory@example.com"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"delete_repo","arguments":{},"_meta":{"agent-safe.io/token":"` + open + `"}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/list"}`,
+	}
+	serverInR, serverInW := io.Pipe()
+	serverOutR, serverOutW := io.Pipe()
+	go echoServer(serverInR, serverOutW)
+
+	var out bytes.Buffer
+	var calls int
+	g := &Guard{Token: mint(t, testPolicy), OnDecision: func(Call, spl.VerifyTokenResult) { calls++ }}
+	if err := g.Proxy(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out, serverInW, serverOutR); err != nil {
+		t.Fatal(err)
+	}
+
+	replies := map[string]map[string]any{}
+	sc := bufio.NewScanner(&out)
+	for sc.Scan() {
+		var r struct {
+			ID     json.RawMessage `json:"id"`
+			Result map[string]any  `json:"result"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		replies[string(r.ID)] = r.Result
+	}
+	if len(replies) != 5 {
+		t.Fatalf("expected a reply per request, got %v", replies)
+	}
+	for _, id := range []string{"1", "2", "4", "5"} {
+		if replies[id]["served"] == nil {
+			t.Errorf("request %s: expected the server to answer, got %v", id, replies[id])
+		}
+	}
+	denied := replies["3"]
+	if denied["isError"] != true || !strings.Contains(denied["content"].([]any)[0].(map[string]any)["text"].(string), "denied tool \"send_email\"") {
+		t.Fatalf("expected a tool error for the denied call, got %v", denied)
+	}
+	if calls != 3 {
+		t.Fatalf("expected three tool calls to be checked, got %d", calls)
+	}
+}
+
+func TestProxyRefusesAmbiguousMessages(t *testing.T) {
+	lines := []string{
+		`[{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"delete_repo","arguments":{}}}]`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","METHOD":"x","params":{"name":"delete_repo","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"x","method":"tools/call","params":{"name":"delete_repo","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":4,"Method":"tools/call","params":{"name":"delete_repo","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"send_email","NAME":"delete_repo","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"Name":"delete_repo","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"delete_repo","arguments":{"to":"a","To":"b"}}}`,
+		`not json`,
+		`{"jsonrpc":"2.0","id":8,"method":"tools/list"}`,
+	}
+	serverInR, serverInW := io.Pipe()
+	serverOutR, serverOutW := io.Pipe()
+	go echoServer(serverInR, serverOutW)
+
+	var out bytes.Buffer
+	g := &Guard{} // no token: every tools/call the guard reads is denied
+	if err := g.Proxy(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out, serverInW, serverOutR); err != nil {
+		t.Fatal(err)
+	}
+	served := 0
+	refused := 0
+	sc := bufio.NewScanner(&out)
+	for sc.Scan() {
+		var r struct {
+			Result map[string]any `json:"result"`
+			Error  map[string]any `json:"error"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case r.Result["served"] != nil:
+			if r.Result["served"] != "tools/list" {
+				t.Errorf("expected only tools/list to reach the server, got %s", sc.Bytes())
+			}
+			served++
+		case r.Error != nil || r.Result["isError"] == true:
+			refused++
+		}
+	}
+	if served != 1 || refused != len(lines)-1 {
+		t.Fatalf("expected 1 message served and %d refused, got %d and %d", len(lines)-1, served, refused)
+	}
+}
+
+func TestCheckKeys(t *testing.T) {
+	for _, c := range []struct {
+		json string
+		ok   bool
+	}{
+		{`{"a":1,"b":{"a":2},"c":[{"a":3},{"a":4}]}`, true},
+		{`{"name":1,"NAME":2}`, false},
+		{`{"s":1,"\u017f":2}`, false}, // long s folds to s
+		{`{"k":1,"\u212a":2}`, false}, // Kelvin sign folds to k
+		{`{"a":{"x":1,"X":2}}`, false},
+	} {
+		if err := checkKeys([]byte(c.json)); (err == nil) != c.ok {
+			t.Errorf("%s: expected ok=%v, got %v", c.json, c.ok, err)
+		}
+	}
+
+	// Many keys are checked in linear time.
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i < 200000; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `"k%d":0`, i)
+	}
+	b.WriteString("}")
+	if err := checkKeys([]byte(b.String())); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,199 @@
+package nonce
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// File is a Memory that also appends each claim to a log file, so that a
+// restarted verifier still refuses identifiers claimed before the restart.
+// Opening the file replays its unexpired claims; the garbage collection
+// rewrites it once most of its lines have expired. Each claim is synced to
+// disk before Claim returns.
+type File struct {
+	*Memory
+	path  string
+	mu    sync.Mutex
+	f     *os.File
+	lines int
+}
+
+// OpenFile opens the log at path, creating it if needed, and returns a
+// store holding the claims in it that have not expired.
+func OpenFile(path string, opts Options) (*File, error) {
+	s := &File{Memory: newMemory(opts), path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("nonce: %w", err)
+	}
+	s.f = f
+	go s.run(func() { s.Collect() })
+	return s, nil
+}
+
+func (s *File) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("nonce: %w", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		exp, id, err := parseLine(sc.Text())
+		if err != nil {
+			return fmt.Errorf("nonce: %s:%d: %v", s.path, n, err)
+		}
+		s.lines++
+		s.Memory.claimUntil(id, exp)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("nonce: %w", err)
+	}
+	// Loading is neither claiming nor evicting.
+	s.Memory.Collect()
+	s.Memory.claims.Store(0)
+	s.Memory.replays.Store(0)
+	s.Memory.evicted.Store(0)
+	return nil
+}
+
+// A log line is the claim's expiry in Unix nanoseconds and its quoted
+// identifier.
+func formatLine(id string, expires time.Time) string {
+	return strconv.FormatInt(expires.UnixNano(), 10) + " " + strconv.Quote(id) + "\n"
+}
+
+func parseLine(line string) (time.Time, string, error) {
+	exp, quoted, ok := strings.Cut(line, " ")
+	if !ok {
+		return time.Time{}, "", fmt.Errorf("malformed line %q", line)
+	}
+	ns, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("malformed expiry %q", exp)
+	}
+	id, err := strconv.Unquote(quoted)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("malformed identifier %s", quoted)
+	}
+	return time.Unix(0, ns), id, nil
+}
+
+// Claim records id for ttl as Memory does and logs the claim. If the claim
+// cannot be logged it reports false with the error; the identifier stays
+// claimed in memory, so a retry is refused as a replay.
+func (s *File) Claim(id string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, errors.New("nonce: replay TTL must be positive")
+	}
+	exp := s.opts.Now().Add(ttl)
+	ok, err := s.Memory.claimUntil(id, exp)
+	if !ok || err != nil {
+		return ok, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.WriteString(formatLine(id, exp)); err != nil {
+		return false, fmt.Errorf("nonce: %w", err)
+	}
+	if err := s.f.Sync(); err != nil {
+		return false, fmt.Errorf("nonce: %w", err)
+	}
+	s.lines++
+	return true, nil
+}
+
+// Import claims the snapshot's identifiers as Memory does and logs the
+// claims it makes.
+func (s *File) Import(snap *spl.Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range snap.Nonces {
+		ok, err := s.Memory.importClaim(n.ID, n.Expires)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if _, err := s.f.WriteString(formatLine(n.ID, n.Expires)); err != nil {
+			return fmt.Errorf("nonce: %w", err)
+		}
+		s.lines++
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("nonce: %w", err)
+	}
+	return nil
+}
+
+// Collect removes expired claims and, once fewer than half the log's lines
+// are live, rewrites the log with only the live claims.
+func (s *File) Collect() error {
+	s.Memory.Collect()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if live := int(s.size.Load()); s.lines < 1024 || s.lines < 2*live {
+		return nil
+	}
+	return s.compact()
+}
+
+func (s *File) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("nonce: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	lines := 0
+	s.Memory.each(func(id string, exp time.Time) {
+		w.WriteString(formatLine(id, exp))
+		lines++
+	})
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("nonce: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("nonce: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("nonce: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("nonce: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("nonce: %w", err)
+	}
+	s.f.Close()
+	s.f, s.lines = f, lines
+	return nil
+}
+
+// Close stops the garbage collection and closes the log.
+func (s *File) Close() error {
+	s.Memory.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
@@ -0,0 +1,261 @@
+// Package nonce implements spl.ReplayStore for verifiers on a single node:
+// Memory keeps claims in memory, and File also logs them to disk so that
+// they survive a restart.
+//
+//	store := nonce.NewMemory(nonce.Options{})
+//	defer store.Close()
+//	if ok, err := store.Claim(presentationSignature, 5*time.Minute); !ok || err != nil {
+//		// replayed
+//	}
+//
+// Expired claims are removed in the background. Stats reports the store's
+// size and how many claims it has refused and evicted, for metrics.
+package nonce
+
+import (
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Options configures a store.
+type Options struct {
+	// Shards splits the store so that concurrent claims rarely contend.
+	// Default 32.
+	Shards int
+	// GCInterval is how often expired claims are removed. Default 1 minute.
+	GCInterval time.Duration
+	// MaxEntries, when positive, bounds the claims held. Claims beyond it
+	// fail with ErrFull rather than evict a claim that has not expired,
+	// which would let its identifier be replayed.
+	MaxEntries int
+	// Now returns the current time. Default time.Now.
+	Now func() time.Time
+}
+
+// ErrFull is returned by Claim when the store holds MaxEntries claims.
+var ErrFull = errors.New("nonce: store full")
+
+// Stats describes a store's state and activity.
+type Stats struct {
+	// Size is the number of claims held, including expired claims not yet
+	// removed.
+	Size int `json:"size"`
+	// Claims counts identifiers claimed, and Replays claims refused because
+	// the identifier was already claimed.
+	Claims  uint64 `json:"claims"`
+	Replays uint64 `json:"replays"`
+	// Evicted counts expired claims removed.
+	Evicted uint64 `json:"evicted"`
+}
+
+var (
+	_ spl.ReplayStore = (*Memory)(nil)
+	_ spl.ReplayStore = (*File)(nil)
+	_ spl.StateStore  = (*Memory)(nil)
+	_ spl.StateStore  = (*File)(nil)
+)
+
+// Memory is an in-memory spl.ReplayStore. It is safe for concurrent use.
+type Memory struct {
+	opts                     Options
+	shards                   []shard
+	size                     atomic.Int64
+	claims, replays, evicted atomic.Uint64
+	stop                     chan struct{}
+	done                     chan struct{}
+	close                    sync.Once
+}
+
+type shard struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+// NewMemory returns an empty store and starts its garbage collection.
+// Close it to stop the collection.
+func NewMemory(opts Options) *Memory {
+	m := newMemory(opts)
+	go m.run(m.Collect)
+	return m
+}
+
+func newMemory(opts Options) *Memory {
+	if opts.Shards <= 0 {
+		opts.Shards = 32
+	}
+	if opts.GCInterval <= 0 {
+		opts.GCInterval = time.Minute
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	m := &Memory{
+		opts:   opts,
+		shards: make([]shard, opts.Shards),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	for i := range m.shards {
+		m.shards[i].expires = map[string]time.Time{}
+	}
+	return m
+}
+
+func (m *Memory) shard(id string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return &m.shards[h.Sum32()%uint32(len(m.shards))]
+}
+
+// Claim records id for ttl and reports true if it was not already recorded
+// or its earlier record has expired.
+func (m *Memory) Claim(id string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, errors.New("nonce: replay TTL must be positive")
+	}
+	return m.claimUntil(id, m.opts.Now().Add(ttl))
+}
+
+func (m *Memory) claimUntil(id string, expires time.Time) (bool, error) {
+	now := m.opts.Now()
+	s := m.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if exp, ok := s.expires[id]; ok {
+		if exp.After(now) {
+			m.replays.Add(1)
+			return false, nil
+		}
+		m.evicted.Add(1)
+	} else {
+		if m.opts.MaxEntries > 0 && m.size.Load() >= int64(m.opts.MaxEntries) {
+			return false, ErrFull
+		}
+		m.size.Add(1)
+	}
+	s.expires[id] = expires
+	m.claims.Add(1)
+	return true, nil
+}
+
+// Collect removes expired claims now, as the background collection does.
+func (m *Memory) Collect() {
+	now := m.opts.Now()
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		for id, exp := range s.expires {
+			if !exp.After(now) {
+				delete(s.expires, id)
+				m.size.Add(-1)
+				m.evicted.Add(1)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// run calls collect every GCInterval until the store is closed.
+func (m *Memory) run(collect func()) {
+	defer close(m.done)
+	t := time.NewTicker(m.opts.GCInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			collect()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// each calls f with every claim that has not expired.
+func (m *Memory) each(f func(id string, expires time.Time)) {
+	now := m.opts.Now()
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		for id, exp := range s.expires {
+			if exp.After(now) {
+				f(id, exp)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Export returns every claim that has not expired, with every shard locked
+// so that the snapshot is of one moment.
+func (m *Memory) Export() (*spl.Snapshot, error) {
+	for i := range m.shards {
+		m.shards[i].mu.Lock()
+		defer m.shards[i].mu.Unlock()
+	}
+	now := m.opts.Now()
+	s := &spl.Snapshot{Taken: now.UTC()}
+	for i := range m.shards {
+		for id, exp := range m.shards[i].expires {
+			if exp.After(now) {
+				s.Nonces = append(s.Nonces, spl.NonceState{ID: id, Expires: exp})
+			}
+		}
+	}
+	return s, nil
+}
+
+// Import claims the snapshot's identifiers until they expire, extending
+// claims the store already holds that expire sooner. Counters and
+// revocations are ignored.
+func (m *Memory) Import(s *spl.Snapshot) error {
+	for _, n := range s.Nonces {
+		if _, err := m.importClaim(n.ID, n.Expires); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importClaim records id until expires unless it is already recorded until
+// then, and reports whether it did.
+func (m *Memory) importClaim(id string, expires time.Time) (bool, error) {
+	if !expires.After(m.opts.Now()) {
+		return false, nil
+	}
+	sh := m.shard(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	exp, ok := sh.expires[id]
+	switch {
+	case ok && !expires.After(exp):
+		return false, nil
+	case !ok && m.opts.MaxEntries > 0 && m.size.Load() >= int64(m.opts.MaxEntries):
+		return false, ErrFull
+	case !ok:
+		m.size.Add(1)
+	}
+	sh.expires[id] = expires
+	return true, nil
+}
+
+// Stats returns the store's size and counters.
+func (m *Memory) Stats() Stats {
+	return Stats{
+		Size:    int(m.size.Load()),
+		Claims:  m.claims.Load(),
+		Replays: m.replays.Load(),
+		Evicted: m.evicted.Load(),
+	}
+}
+
+// Close stops the background collection.
+func (m *Memory) Close() error {
+	m.close.Do(func() { close(m.stop) })
+	<-m.done
+	return nil
+}
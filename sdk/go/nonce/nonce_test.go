@@ -0,0 +1,204 @@
+package nonce
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestMemoryClaim(t *testing.T) {
+	c := &clock{time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)}
+	m := NewMemory(Options{Shards: 4, GCInterval: time.Hour, Now: c.now})
+	defer m.Close()
+	steps := []struct {
+		id      string
+		advance time.Duration
+		want    bool
+	}{
+		{"a", 0, true},
+		{"a", 0, false},
+		{"b", 0, true},
+		{"a", 59 * time.Second, false},
+		{"a", time.Second, true}, // a's first claim has expired
+		{"b", 0, true},
+	}
+	for i, s := range steps {
+		c.t = c.t.Add(s.advance)
+		if ok, err := m.Claim(s.id, time.Minute); err != nil || ok != s.want {
+			t.Fatalf("step %d: expected %v, got %v %v", i, s.want, ok, err)
+		}
+	}
+	if st := m.Stats(); st != (Stats{Size: 2, Claims: 4, Replays: 2, Evicted: 2}) {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	c.t = c.t.Add(time.Minute)
+	m.Collect()
+	if st := m.Stats(); st.Size != 0 || st.Evicted != 4 {
+		t.Fatalf("expected collection to evict both claims, got %+v", st)
+	}
+	if _, err := m.Claim("c", 0); err == nil {
+		t.Fatal("expected a zero TTL to be refused")
+	}
+}
+
+func TestMemoryFull(t *testing.T) {
+	c := &clock{time.Unix(0, 0)}
+	m := NewMemory(Options{MaxEntries: 1, Now: c.now})
+	defer m.Close()
+	m.Claim("a", time.Minute)
+	if ok, err := m.Claim("b", time.Minute); ok || err != ErrFull {
+		t.Fatalf("expected ErrFull, got %v %v", ok, err)
+	}
+	c.t = c.t.Add(time.Minute)
+	if ok, err := m.Claim("a", time.Minute); !ok || err != nil {
+		t.Fatalf("expected an expired claim to be replaced, got %v %v", ok, err)
+	}
+}
+
+func TestMemoryConcurrent(t *testing.T) {
+	m := NewMemory(Options{})
+	defer m.Close()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	won := map[string]int{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				id := fmt.Sprint(i)
+				if ok, _ := m.Claim(id, time.Hour); ok {
+					mu.Lock()
+					won[id]++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for id, n := range won {
+		if n != 1 {
+			t.Fatalf("expected %s claimed once, got %d", id, n)
+		}
+	}
+	if st := m.Stats(); len(won) != 200 || st.Size != 200 || st.Replays != 7*200 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestMemoryCollectsInBackground(t *testing.T) {
+	m := NewMemory(Options{GCInterval: time.Millisecond})
+	m.Claim("a", time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Stats().Size != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the expired claim to be collected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	m.Close()
+	m.Close()
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.log")
+	c := &clock{time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)}
+	s, err := OpenFile(path, Options{GCInterval: time.Hour, Now: c.now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Claim("short", time.Minute)
+	s.Claim("long\nline", time.Hour)
+	if ok, _ := s.Claim("short", time.Minute); ok {
+		t.Fatal("expected a replay to be refused")
+	}
+	s.Close()
+
+	c.t = c.t.Add(2 * time.Minute)
+	s, err = OpenFile(path, Options{GCInterval: time.Hour, Now: c.now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if st := s.Stats(); st != (Stats{Size: 1}) {
+		t.Fatalf("expected only the unexpired claim loaded, got %+v", st)
+	}
+	if ok, _ := s.Claim("long\nline", time.Hour); ok {
+		t.Fatal("expected a claim from before the restart to be refused")
+	}
+	if ok, _ := s.Claim("short", time.Minute); !ok {
+		t.Fatal("expected an expired claim to be claimable again")
+	}
+
+	// Once most lines are dead, collection rewrites the log.
+	for i := 0; i < 1100; i++ {
+		s.Claim(fmt.Sprint(i), time.Second)
+	}
+	c.t = c.t.Add(time.Minute)
+	if err := s.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(lines) != 1 || !strings.HasSuffix(lines[0], `"long\nline"`) {
+		t.Fatalf("expected the log compacted to one claim, got %d lines", len(lines))
+	}
+	s.Claim("after", time.Minute)
+	if b, _ := os.ReadFile(path); strings.Count(string(b), "\n") != 2 {
+		t.Fatalf("expected claims appended to the compacted log, got %q", b)
+	}
+}
+
+func TestFileMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.log")
+	os.WriteFile(path, []byte("1 \"a\"\nnot a claim\n"), 0o600)
+	if _, err := OpenFile(path, Options{}); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Fatalf("expected the malformed line reported, got %v", err)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	c := &clock{time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)}
+	src := NewMemory(Options{Now: c.now})
+	defer src.Close()
+	src.Claim("a", time.Minute)
+	src.Claim("b", time.Hour)
+	snap, err := src.Export()
+	if err != nil || len(snap.Nonces) != 2 {
+		t.Fatalf("expected 2 claims exported, got %+v %v", snap, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "nonces.log")
+	dst, err := OpenFile(path, Options{Now: c.now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst.Claim("b", time.Minute)
+	if err := dst.Import(snap); err != nil {
+		t.Fatal(err)
+	}
+	dst.Close()
+
+	c.t = c.t.Add(30 * time.Minute)
+	dst, err = OpenFile(path, Options{Now: c.now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if ok, _ := dst.Claim("b", time.Minute); ok {
+		t.Fatal("expected the imported claim on b to outlast the shorter one")
+	}
+	if ok, _ := dst.Claim("a", time.Minute); !ok {
+		t.Fatal("expected the imported claim on a to have expired")
+	}
+}
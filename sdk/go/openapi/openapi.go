@@ -0,0 +1,651 @@
+// Package openapi derives SPL policies from an OpenAPI document, so an API's
+// owner can mint tokens scoped to operations of its published contract
+// rather than writing each policy by hand:
+//
+//	doc, err := openapi.Parse(spec)
+//	if err != nil {
+//		return err
+//	}
+//	max := 50.0
+//	p, err := doc.Policy(
+//		openapi.Scope{Operation: "createPayment", Bounds: map[string]openapi.Bound{
+//			"body.amount": {Max: &max},
+//		}},
+//		openapi.Scope{Operation: "GET /v1/payments/{id}"},
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	src, err := p.Source()
+//
+// The policy holds a request to one of the selected operations: its method,
+// its path template, and its parameters and JSON body fields to the enums
+// and numeric ranges the document declares, narrowed by the scope's
+// bounds. A request says which operation it is for through Route, which
+// fits splhttp.Options.Request:
+//
+//	gate := splhttp.Middleware(splhttp.Options{Verify: verify, Request: doc.Route})
+//
+// Route adds the matched path template and the path and query parameters,
+// converted to the types the document declares, so the policy sees
+//
+//	{"method": "POST", "path": "/v1/accounts/42/payments",
+//	 "route": "/v1/accounts/{account}/payments", "params": {"account": 42},
+//	 "body": {"amount": 20, ...}}
+//
+// Documents may be JSON or YAML, OpenAPI 3.0 or 3.1, with local $refs.
+// Header and cookie parameters, string lengths and patterns are not
+// constrained; SPL has no operators for them.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmcentire/agent-safe/sdk/go/internal/yaml"
+	"github.com/jmcentire/agent-safe/sdk/go/policy"
+)
+
+// Document is a parsed OpenAPI document: its operations and the fields
+// each one takes.
+type Document struct {
+	// Base is a path prefix requests carry ahead of the document's paths,
+	// such as "/api" when the API is mounted there. Route strips it.
+	Base string
+
+	ops []*operation
+}
+
+type operation struct {
+	id     string
+	method string
+	path   string
+	fields []*field
+}
+
+// field is a parameter or a JSON body field, named as in Bound: "account"
+// for a parameter, "body.amount" for a body field.
+type field struct {
+	name     string
+	in       string   // "path", "query" or "body"
+	keys     []string // the request path policies read it at
+	typ      string
+	required bool
+	schema   map[string]any
+}
+
+// maxNesting bounds how deep Parse follows body schemas.
+const maxNesting = 16
+
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Parse reads an OpenAPI document in JSON or YAML.
+func Parse(data []byte) (*Document, error) {
+	v, err := yaml.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	root, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("openapi: document is not an object")
+	}
+	paths, ok := root["paths"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("openapi: document has no paths")
+	}
+	r := resolver{root: root}
+	d := &Document{}
+	for _, path := range sortedKeys(paths) {
+		item, err := r.object(paths[path])
+		if err != nil {
+			return nil, fmt.Errorf("openapi: %s: %w", path, err)
+		}
+		for _, m := range methods {
+			raw, ok := item[m]
+			if !ok {
+				continue
+			}
+			op, err := r.operation(path, strings.ToUpper(m), item, raw)
+			if err != nil {
+				return nil, fmt.Errorf("openapi: %s %s: %w", strings.ToUpper(m), path, err)
+			}
+			d.ops = append(d.ops, op)
+		}
+	}
+	return d, nil
+}
+
+// resolver follows local $refs within a document.
+type resolver struct {
+	root map[string]any
+}
+
+// object returns v as an object, following a $ref.
+func (r resolver) object(v any) (map[string]any, error) {
+	for range maxNesting {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an object, got %T", v)
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m, nil
+		}
+		if v, ok = r.lookup(ref); !ok {
+			return nil, fmt.Errorf("unresolved $ref %q", ref)
+		}
+	}
+	return nil, fmt.Errorf("$refs nest too deeply")
+}
+
+// lookup resolves a local JSON pointer such as
+// "#/components/schemas/Payment".
+func (r resolver) lookup(ref string) (any, bool) {
+	ptr, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil, false
+	}
+	var v any = r.root
+	for _, tok := range strings.Split(ptr, "/") {
+		tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[tok]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func (r resolver) operation(path, method string, item map[string]any, raw any) (*operation, error) {
+	o, err := r.object(raw)
+	if err != nil {
+		return nil, err
+	}
+	op := &operation{method: method, path: path}
+	op.id, _ = o["operationId"].(string)
+
+	// Operation parameters override path-item ones of the same name and
+	// location.
+	params := map[string]*field{}
+	for _, list := range []any{item["parameters"], o["parameters"]} {
+		items, _ := list.([]any)
+		for _, p := range items {
+			f, err := r.parameter(p)
+			if err != nil {
+				return nil, err
+			}
+			if f != nil {
+				params[f.in+" "+f.name] = f
+			}
+		}
+	}
+	for _, k := range sortedKeys(params) {
+		op.fields = append(op.fields, params[k])
+	}
+
+	if raw, ok := o["requestBody"]; ok {
+		body, err := r.object(raw)
+		if err != nil {
+			return nil, fmt.Errorf("requestBody: %w", err)
+		}
+		required, _ := body["required"].(bool)
+		content, _ := body["content"].(map[string]any)
+		for _, mt := range sortedKeys(content) {
+			if mt != "application/json" && !strings.HasSuffix(mt, "+json") {
+				continue
+			}
+			media, err := r.object(content[mt])
+			if err != nil {
+				return nil, fmt.Errorf("requestBody: %w", err)
+			}
+			if s, ok := media["schema"]; ok {
+				if err := r.body(op, s, []string{"body"}, required, 0); err != nil {
+					return nil, fmt.Errorf("requestBody: %w", err)
+				}
+			}
+			break
+		}
+	}
+	return op, nil
+}
+
+// parameter reads a path or query parameter; others yield nil.
+func (r resolver) parameter(raw any) (*field, error) {
+	p, err := r.object(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parameter: %w", err)
+	}
+	name, _ := p["name"].(string)
+	in, _ := p["in"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("parameter without a name")
+	}
+	if in != "path" && in != "query" {
+		return nil, nil
+	}
+	f := &field{name: name, in: in, keys: []string{"params", name}, schema: map[string]any{}}
+	f.required, _ = p["required"].(bool)
+	if s, ok := p["schema"]; ok {
+		if f.schema, err = r.object(s); err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+	}
+	f.typ = schemaType(f.schema)
+	return f, nil
+}
+
+// body adds the field at keys and, for an object, its properties.
+func (r resolver) body(op *operation, raw any, keys []string, required bool, depth int) error {
+	if depth > maxNesting {
+		return fmt.Errorf("schema nests too deeply")
+	}
+	s, err := r.object(raw)
+	if err != nil {
+		return err
+	}
+	props, _ := s["properties"].(map[string]any)
+	if len(keys) > 1 {
+		op.fields = append(op.fields, &field{
+			name:     strings.Join(keys, "."),
+			in:       "body",
+			keys:     keys,
+			typ:      schemaType(s),
+			required: required,
+			schema:   s,
+		})
+	}
+	if len(props) == 0 {
+		return nil
+	}
+	need := map[string]bool{}
+	if list, ok := s["required"].([]any); ok {
+		for _, k := range list {
+			if k, ok := k.(string); ok {
+				need[k] = true
+			}
+		}
+	}
+	for _, k := range sortedKeys(props) {
+		sub := append(keys[:len(keys):len(keys)], k)
+		if err := r.body(op, props[k], sub, required && need[k], depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaType returns a schema's type; of a 3.1 type list, the first that
+// is not "null".
+func schemaType(s map[string]any) string {
+	switch t := s["type"].(type) {
+	case string:
+		return t
+	case []any:
+		for _, t := range t {
+			if t, ok := t.(string); ok && t != "null" {
+				return t
+			}
+		}
+	}
+	return ""
+}
+
+// Bound narrows a field beyond what the document allows. The document's
+// own constraints on an optional field hold only when the request gives it
+// a value; a bound holds either way.
+type Bound struct {
+	// Min and Max bound a number, inclusively.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// Enum lists the values allowed: strings, numbers or booleans.
+	Enum []any `json:"enum,omitempty"`
+}
+
+// Scope selects an operation a token may call.
+type Scope struct {
+	// Operation is the operation's operationId, or its method and path
+	// template, as in "POST /v1/accounts/{account}/payments".
+	Operation string `json:"operation"`
+	// Bounds narrows the operation's fields, by name: a path or query
+	// parameter's name, or "body." and a body field's path, such as
+	// "body.amount" or "body.recipient.country".
+	Bounds map[string]Bound `json:"bounds,omitempty"`
+}
+
+// Policy returns the policy that allows exactly the scoped operations,
+// within the document's constraints and the scopes' bounds. It fails on an
+// operation the document lacks or a bound on a field the operation lacks.
+func (d *Document) Policy(scope ...Scope) (policy.Cond, error) {
+	if len(scope) == 0 {
+		return policy.Cond{}, fmt.Errorf("openapi: no operations in scope")
+	}
+	var alts []policy.Cond
+	for _, s := range scope {
+		op := d.lookup(s.Operation)
+		if op == nil {
+			return policy.Cond{}, fmt.Errorf("openapi: no operation %q", s.Operation)
+		}
+		c, err := op.policy(s.Bounds)
+		if err != nil {
+			return policy.Cond{}, fmt.Errorf("openapi: %s: %w", s.Operation, err)
+		}
+		alts = append(alts, c)
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return policy.Or(alts...), nil
+}
+
+// lookup finds an operation by operationId or "METHOD /path".
+func (d *Document) lookup(name string) *operation {
+	for _, op := range d.ops {
+		if op.id != "" && op.id == name {
+			return op
+		}
+	}
+	if method, path, ok := strings.Cut(name, " "); ok {
+		for _, op := range d.ops {
+			if op.method == strings.ToUpper(method) && op.path == strings.TrimSpace(path) {
+				return op
+			}
+		}
+	}
+	return nil
+}
+
+func (op *operation) policy(bounds map[string]Bound) (policy.Cond, error) {
+	conds := []policy.Cond{
+		policy.Eq(policy.Req("method"), op.method),
+		policy.Eq(policy.Req("route"), op.path),
+	}
+	byName := map[string]*field{}
+	for _, f := range op.fields {
+		byName[f.name] = f
+	}
+	for _, name := range sortedKeys(bounds) {
+		if byName[name] == nil {
+			return policy.Cond{}, fmt.Errorf("no field %q", name)
+		}
+	}
+	for _, f := range op.fields {
+		b, bounded := bounds[f.name]
+		cs, err := f.constraints(b)
+		if err != nil {
+			return policy.Cond{}, err
+		}
+		if len(cs) == 0 {
+			continue
+		}
+		c := cs[0]
+		if len(cs) > 1 {
+			c = policy.And(cs...)
+		}
+		// An absent field reads as nil. Unless the document requires the
+		// field or the scope bounds it, absence satisfies its constraints.
+		if !f.required && !bounded {
+			c = policy.Or(policy.Not(policy.Truthy(f.value())), c)
+		}
+		conds = append(conds, c)
+	}
+	return policy.And(conds...), nil
+}
+
+func (f *field) value() policy.Expr { return policy.Req(f.keys...) }
+
+// constraints returns the conditions the document's schema and b put on
+// f's value.
+func (f *field) constraints(b Bound) ([]policy.Cond, error) {
+	x := f.value()
+	var out []policy.Cond
+	enum := func(values []any) error {
+		list := make([]policy.Expr, len(values))
+		for i, v := range values {
+			e, err := literal(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", f.name, err)
+			}
+			list[i] = e
+		}
+		out = append(out, policy.Member(x, policy.Tuple(list...)))
+		return nil
+	}
+	s := f.schema
+	if c, ok := s["const"]; ok {
+		if err := enum([]any{c}); err != nil {
+			return nil, err
+		}
+	}
+	if e, ok := s["enum"].([]any); ok {
+		if err := enum(withoutNull(e)); err != nil {
+			return nil, err
+		}
+	}
+	// exclusiveMinimum is a number in 3.1 and a flag on minimum in 3.0.
+	if min, ok := s["minimum"].(float64); ok {
+		if excl, _ := s["exclusiveMinimum"].(bool); excl {
+			out = append(out, policy.Gt(x, min))
+		} else {
+			out = append(out, policy.Gte(x, min))
+		}
+	}
+	if min, ok := s["exclusiveMinimum"].(float64); ok {
+		out = append(out, policy.Gt(x, min))
+	}
+	if max, ok := s["maximum"].(float64); ok {
+		if excl, _ := s["exclusiveMaximum"].(bool); excl {
+			out = append(out, policy.Lt(x, max))
+		} else {
+			out = append(out, policy.Lte(x, max))
+		}
+	}
+	if max, ok := s["exclusiveMaximum"].(float64); ok {
+		out = append(out, policy.Lt(x, max))
+	}
+	if b.Enum != nil {
+		if err := enum(b.Enum); err != nil {
+			return nil, err
+		}
+	}
+	if b.Min != nil {
+		out = append(out, policy.Gte(x, *b.Min))
+	}
+	if b.Max != nil {
+		out = append(out, policy.Lte(x, *b.Max))
+	}
+	return out, nil
+}
+
+// withoutNull drops null, which a request field cannot be told apart from
+// absent by.
+func withoutNull(values []any) []any {
+	out := make([]any, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func literal(v any) (policy.Expr, error) {
+	switch v := v.(type) {
+	case string:
+		return policy.Lit(v), nil
+	case bool:
+		return policy.Lit(v), nil
+	case float64:
+		return policy.Lit(v), nil
+	case int:
+		return policy.Lit(v), nil
+	case int64:
+		return policy.Lit(v), nil
+	}
+	return policy.Expr{}, fmt.Errorf("enum value %v is not a string, number or boolean", v)
+}
+
+// Route describes r in the terms of the policies Policy makes: it sets
+// req["route"] to the path template of the operation r matches and
+// req["params"] to its path and query parameters, converted to their
+// declared types. A request that matches no operation, or whose parameters
+// or body fields do not have their declared types, gets no route, so no
+// such policy allows it. Where several templates match, the one with the
+// most literal segments wins.
+func (d *Document) Route(r *http.Request, req map[string]any) {
+	path, ok := strings.CutPrefix(r.URL.EscapedPath(), strings.TrimSuffix(d.Base, "/"))
+	if !ok {
+		return
+	}
+	segs := strings.Split(path, "/")
+	var best *operation
+	var bestParams map[string]string
+	bestLiterals := -1
+	for _, op := range d.ops {
+		if op.method != r.Method {
+			continue
+		}
+		params, literals, ok := match(op.path, segs)
+		if ok && literals > bestLiterals {
+			best, bestParams, bestLiterals = op, params, literals
+		}
+	}
+	if best == nil {
+		return
+	}
+	query := r.URL.Query()
+	values := map[string]any{}
+	for _, f := range best.fields {
+		switch f.in {
+		case "path":
+			v, ok := convert(bestParams[f.name], f.typ)
+			if !ok {
+				return
+			}
+			values[f.name] = v
+		case "query":
+			raw, present := query[f.name]
+			if !present {
+				continue
+			}
+			if f.typ == "array" {
+				items, _ := f.schema["items"].(map[string]any)
+				list := make([]any, len(raw))
+				for i, s := range raw {
+					if list[i], ok = convert(s, schemaType(items)); !ok {
+						return
+					}
+				}
+				values[f.name] = list
+				continue
+			}
+			v, ok := convert(raw[0], f.typ)
+			if !ok {
+				return
+			}
+			values[f.name] = v
+		case "body":
+			if v, present := lookupPath(req, f.keys); present && !hasType(v, f.typ) {
+				return
+			}
+		}
+	}
+	req["route"] = best.path
+	req["params"] = values
+}
+
+// match matches escaped path segments against a template, returning the
+// unescaped template parameters and how many segments matched literally.
+func match(template string, segs []string) (map[string]string, int, bool) {
+	tsegs := strings.Split(template, "/")
+	if len(tsegs) != len(segs) {
+		return nil, 0, false
+	}
+	params := map[string]string{}
+	literals := 0
+	for i, t := range tsegs {
+		s, err := url.PathUnescape(segs[i])
+		if err != nil {
+			return nil, 0, false
+		}
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if s == "" {
+				return nil, 0, false
+			}
+			params[t[1:len(t)-1]] = s
+			continue
+		}
+		if s != t {
+			return nil, 0, false
+		}
+		literals++
+	}
+	return params, literals, true
+}
+
+// convert reads a parameter's text as its declared type.
+func convert(s, typ string) (any, bool) {
+	switch typ {
+	case "integer":
+		n, err := strconv.ParseInt(s, 10, 64)
+		return float64(n), err == nil
+	case "number":
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	case "boolean":
+		b, err := strconv.ParseBool(s)
+		return b, err == nil
+	}
+	return s, true
+}
+
+// lookupPath reads the value at keys in req.
+func lookupPath(req map[string]any, keys []string) (any, bool) {
+	var v any = req
+	for _, k := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+// hasType reports whether a decoded JSON value has a declared scalar type.
+// Comparisons read a value of another type as zero, so a number sent as a
+// string would pass any maximum.
+func hasType(v any, typ string) bool {
+	switch typ {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	}
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,144 @@
+package openapi
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+	"github.com/jmcentire/agent-safe/sdk/go/splhttp"
+)
+
+const spec = `openapi: 3.1.0
+info: {title: Payments, version: "1"}
+paths:
+  /v1/accounts/{account}/payments:
+    parameters:
+    - {name: account, in: path, required: true, schema: {type: integer, minimum: 1}}
+    post:
+      operationId: createPayment
+      parameters:
+      - {name: dry_run, in: query, schema: {type: boolean}}
+      - {name: Idempotency-Key, in: header, schema: {type: string}}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/Payment"}
+    get:
+      operationId: listPayments
+      parameters:
+      - {name: limit, in: query, schema: {type: integer, maximum: 100}}
+  /v1/accounts/{account}/payments/latest:
+    get:
+      parameters:
+      - {name: account, in: path, required: true, schema: {type: integer}}
+components:
+  schemas:
+    Payment:
+      type: object
+      required: [amount, currency]
+      properties:
+        amount: {type: number, exclusiveMinimum: 0}
+        currency: {type: string, enum: [USD, EUR]}
+        memo: {type: string}
+        recipient:
+          type: object
+          properties:
+            country: {type: string}
+`
+
+func TestPolicy(t *testing.T) {
+	doc, err := Parse([]byte(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	max := 50.0
+	p, err := doc.Policy(Scope{Operation: "createPayment", Bounds: map[string]Bound{
+		"body.amount":            {Max: &max},
+		"body.recipient.country": {Enum: []any{"US", "CA"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := p.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `(and (= (get req "method") "POST") (= (get req "route") "/v1/accounts/{account}/payments")` +
+		` (>= (get (get req "params") "account") 1)` +
+		` (and (> (get (get req "body") "amount") 0) (<= (get (get req "body") "amount") 50))` +
+		` (member (get (get req "body") "currency") (tuple "USD" "EUR"))` +
+		` (member (get (get (get req "body") "recipient") "country") (tuple "US" "CA")))`
+	if src != want {
+		t.Fatalf("got\n%s\nwant\n%s", src, want)
+	}
+
+	for _, bad := range []Scope{
+		{Operation: "deletePayment"},
+		{Operation: "createPayment", Bounds: map[string]Bound{"body.amont": {Max: &max}}},
+	} {
+		if _, err := doc.Policy(bad); err == nil {
+			t.Errorf("%+v: expected an error", bad)
+		}
+	}
+	if _, err := doc.Policy(); err == nil {
+		t.Error("expected an empty scope to fail")
+	}
+}
+
+func TestRoute(t *testing.T) {
+	doc, err := Parse([]byte(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Base = "/api"
+	p, err := doc.Policy(Scope{Operation: "createPayment"}, Scope{Operation: "GET /v1/accounts/{account}/payments"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		method, target, body string
+		route                string
+		allow                bool
+	}{
+		{"POST", "/api/v1/accounts/42/payments?dry_run=true", `{"amount": 20, "currency": "USD"}`, "/v1/accounts/{account}/payments", true},
+		{"POST", "/api/v1/accounts/42/payments", `{"amount": 20, "currency": "GBP"}`, "/v1/accounts/{account}/payments", false},
+		{"POST", "/api/v1/accounts/0/payments", `{"amount": 20, "currency": "USD"}`, "/v1/accounts/{account}/payments", false},
+		// Mistyped values get no route rather than comparing as zero.
+		{"POST", "/api/v1/accounts/42/payments", `{"amount": "20", "currency": "USD"}`, "", false},
+		{"POST", "/api/v1/accounts/abc/payments", `{"amount": 20, "currency": "USD"}`, "", false},
+		{"GET", "/api/v1/accounts/42/payments?limit=100", "", "/v1/accounts/{account}/payments", true},
+		{"GET", "/api/v1/accounts/42/payments?limit=500", "", "/v1/accounts/{account}/payments", false},
+		{"GET", "/api/v1/accounts/42/payments", "", "/v1/accounts/{account}/payments", true},
+		// The literal segment wins, and that operation is out of scope.
+		{"GET", "/api/v1/accounts/42/payments/latest", "", "/v1/accounts/{account}/payments/latest", false},
+		{"GET", "/v1/accounts/42/payments", "", "", false},
+	} {
+		r := httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+		req, err := splhttp.RequestMap(r, splhttp.DefaultMaxBodyBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc.Route(r, req)
+		if got, _ := req["route"].(string); got != c.route {
+			t.Errorf("%s %s: route %q, want %q", c.method, c.target, got, c.route)
+		}
+		if allow, err := spl.Verify(p.Node(), spl.Env{Req: req}); allow != c.allow {
+			t.Errorf("%s %s %s: allow %v (%v), want %v", c.method, c.target, c.body, allow, err, c.allow)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, doc := range []string{
+		`[]`,
+		`{"openapi": "3.1.0"}`,
+		`{"paths": {"/x": {"get": {"parameters": [{"$ref": "#/components/parameters/missing"}]}}}}`,
+		`{"paths": {"/x": {"$ref": "#/paths/~1x"}}}`,
+	} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: expected an error", doc)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Package policy builds SPL policies in Go, so services that construct
+// policies from configuration or user input do so with checked types
+// rather than by concatenating strings:
+//
+//	p := policy.And(
+//		policy.Eq(policy.Req("action"), "payments.create"),
+//		policy.Lte(policy.Req("amount"), 100),
+//		policy.Member(policy.Req("recipient"), policy.Var("allowed_recipients")),
+//		policy.DPoPOk(),
+//	)
+//	src, err := p.Source()
+//	if err != nil {
+//		return err
+//	}
+//	tok, err := spl.Mint(src, priv, spl.MintOptions{})
+//
+// Conditions (Cond) and values (Expr) are distinct types, so a comparison
+// cannot be compared and a field cannot stand where a condition belongs
+// without Truthy. Go strings, numbers and booleans become SPL literals.
+// Source renders the canonical one-line source, which spl.Parse reads back
+// as Node, and refuses a literal SPL cannot hold, so no value can inject
+// syntax.
+package policy
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Expr is an SPL value: a request field, a variable, a literal, a tuple or
+// a counter.
+type Expr struct{ n spl.Node }
+
+// Cond is an SPL condition, which evaluates to a boolean. A policy is a
+// Cond.
+type Cond struct{ n spl.Node }
+
+// Node returns the expression's syntax tree.
+func (e Expr) Node() spl.Node { return e.n }
+
+// String returns the expression as SPL source.
+func (e Expr) String() string { return spl.Render(e.n) }
+
+// Node returns the condition's syntax tree.
+func (c Cond) Node() spl.Node { return c.n }
+
+// String returns the condition as SPL source, for display; see Source.
+func (c Cond) String() string { return spl.Render(c.n) }
+
+// Source returns the condition as canonical SPL source, ready for spl.Mint
+// or spl.Parse. It fails if a name or literal cannot be written in SPL: a
+// string holding a double quote, which SPL strings cannot escape, a number
+// that is not finite, or a variable name that is empty or holds a space, a
+// parenthesis, a quote or a semicolon.
+func (c Cond) Source() (string, error) {
+	if err := check(c.n); err != nil {
+		return "", err
+	}
+	return spl.Render(c.n), nil
+}
+
+// check reports the first name or literal in n that SPL cannot hold.
+func check(n spl.Node) error {
+	switch v := n.(type) {
+	case *spl.Symbol:
+		if v.Name == "" || strings.ContainsAny(v.Name, "()\";") || strings.IndexFunc(v.Name, unicode.IsSpace) >= 0 {
+			return fmt.Errorf("policy: %q is not a variable name", v.Name)
+		}
+	case spl.List:
+		for _, c := range v {
+			if err := check(c); err != nil {
+				return err
+			}
+		}
+	case spl.Str:
+		if strings.ContainsRune(string(v), '"') {
+			return fmt.Errorf("policy: string %q holds a double quote", string(v))
+		}
+	case spl.Num:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Errorf("policy: number %v is not finite", float64(v))
+		}
+	}
+	return nil
+}
+
+// Operand is what the comparisons and predicates accept: an Expr, or a Go
+// literal. Numbers must be finite; SPL has no NaN or infinity.
+type Operand interface {
+	Expr | string | bool | int | int64 | float64
+}
+
+// node converts an operand to its syntax tree.
+func node[T Operand](v T) spl.Node {
+	switch x := any(v).(type) {
+	case Expr:
+		return x.n
+	case string:
+		return spl.Str(x)
+	case bool:
+		return spl.Bool(x)
+	case int:
+		return spl.Num(x)
+	case int64:
+		return spl.Num(x)
+	case float64:
+		return spl.Num(x)
+	}
+	return nil
+}
+
+// apply builds (op args...).
+func apply(op string, args ...spl.Node) spl.List {
+	return append(spl.List{spl.Sym(op)}, args...)
+}
+
+// Lit returns a Go literal as an Expr, for the places that take one, such
+// as Truthy.
+func Lit[T Operand](v T) Expr { return Expr{node(v)} }
+
+// Req returns the request field at path, one key per level:
+// Req("user", "role") is (get (get req "user") "role").
+func Req(path ...string) Expr {
+	var n spl.Node = spl.Sym("req")
+	for _, k := range path {
+		n = apply("get", n, spl.Str(k))
+	}
+	return Expr{n}
+}
+
+// Var returns the variable called name, bound in the verifier's vars.
+func Var(name string) Expr { return Expr{spl.Sym(name)} }
+
+// Now returns the evaluation time.
+func Now() Expr { return Expr{spl.Sym("now")} }
+
+// Tuple returns a list of values.
+func Tuple[T Operand](values ...T) Expr {
+	l := apply("tuple")
+	for _, v := range values {
+		l = append(l, node(v))
+	}
+	return Expr{l}
+}
+
+// PerDayCount returns how often action has been used on day.
+func PerDayCount[D Operand](action string, day D) Expr {
+	return Expr{apply("per-day-count", spl.Str(action), node(day))}
+}
+
+// WindowCount returns how often action has been used in the rolling
+// window, such as "24h" or "7d", ending now.
+func WindowCount(action, window string) Expr {
+	return Expr{apply("window-count", spl.Str(action), spl.Str(window))}
+}
+
+// SumAmount returns what has been spent on action in the current period,
+// "day", "week", "month" or "year", including this request, in currency.
+func SumAmount(action, period, currency string) Expr {
+	return Expr{apply("sum-amount", spl.Str(action), spl.Str(period), spl.Str(currency))}
+}
+
+// And holds when every condition holds; with none, it always holds.
+func And(conds ...Cond) Cond { return Cond{connective("and", conds)} }
+
+// Or holds when any condition holds; with none, it never holds.
+func Or(conds ...Cond) Cond { return Cond{connective("or", conds)} }
+
+func connective(op string, conds []Cond) spl.Node {
+	l := apply(op)
+	for _, c := range conds {
+		l = append(l, c.n)
+	}
+	return l
+}
+
+// Not holds when c does not.
+func Not(c Cond) Cond { return Cond{apply("not", c.n)} }
+
+// Truthy holds when e is true, or any value but false and nil.
+func Truthy(e Expr) Cond { return Cond{e.n} }
+
+// Bool is the condition that always or never holds.
+func Bool(b bool) Cond { return Cond{spl.Bool(b)} }
+
+// Eq holds when a and b are equal; values of different types never are.
+func Eq[A, B Operand](a A, b B) Cond { return Cond{apply("=", node(a), node(b))} }
+
+// Lt holds when a < b.
+func Lt[A, B Operand](a A, b B) Cond { return Cond{apply("<", node(a), node(b))} }
+
+// Lte holds when a <= b.
+func Lte[A, B Operand](a A, b B) Cond { return Cond{apply("<=", node(a), node(b))} }
+
+// Gt holds when a > b.
+func Gt[A, B Operand](a A, b B) Cond { return Cond{apply(">", node(a), node(b))} }
+
+// Gte holds when a >= b.
+func Gte[A, B Operand](a A, b B) Cond { return Cond{apply(">=", node(a), node(b))} }
+
+// Member holds when x is an item of list, a Tuple or a variable bound to a
+// list.
+func Member[A Operand](x A, list Expr) Cond { return Cond{apply("member", node(x), list.n)} }
+
+// NotMember holds when x is not an item of blocklist. Unlike Not(Member),
+// it denies when blocklist is a variable that is not bound.
+func NotMember[A Operand](x A, blocklist Expr) Cond {
+	return Cond{apply("not-member", node(x), blocklist.n)}
+}
+
+// Subset holds when every item of a is an item of b.
+func Subset(a, b Expr) Cond { return Cond{apply("subset?", a.n, b.n)} }
+
+// BloomMember holds when the Bloom filter bound to the variable filter may
+// contain x.
+func BloomMember[A Operand](x A, filter string) Cond {
+	return Cond{apply("bloom-member?", node(x), spl.Sym(filter))}
+}
+
+// Before holds when the time a is before the time b; both are RFC 3339.
+func Before[A, B Operand](a A, b B) Cond { return Cond{apply("before", node(a), node(b))} }
+
+// DPoPOk holds when the presenter proved possession of its key.
+func DPoPOk() Cond { return Cond{apply("dpop_ok?")} }
+
+// MerkleOk holds when the request proves tuple is in the token's Merkle
+// set.
+func MerkleOk(tuple Expr) Cond { return Cond{apply("merkle_ok?", tuple.n)} }
+
+// MerkleMember holds when x is a leaf of the token's Merkle root, proved
+// by a witness the presenter supplies; see spl.MerkleAllowlist.
+func MerkleMember[A Operand](x A) Cond { return Cond{apply("merkle-member?", node(x))} }
+
+// MerkleMemberProof is MerkleMember with the proof read from proof, such
+// as a request field, rather than from the presentation.
+func MerkleMemberProof[A Operand](x A, proof Expr) Cond {
+	return Cond{apply("merkle-member?", node(x), proof.n)}
+}
+
+// VRFOk holds when the offline budget proof for day and amount verifies.
+func VRFOk[D, A Operand](day D, amount A) Cond {
+	return Cond{apply("vrf_ok?", node(day), node(amount))}
+}
+
+// ThreshOk holds when enough co-signatures verify.
+func ThreshOk() Cond { return Cond{apply("thresh_ok?")} }
+
+// AttestedOk holds when the request carries a valid WebAuthn assertion.
+func AttestedOk() Cond { return Cond{apply("attested_ok?")} }
+
+// RangeOk holds when proof shows the amount committed to is at most limit.
+func RangeOk[C, P, L Operand](commitment C, proof P, limit L) Cond {
+	return Cond{apply("range_ok?", node(commitment), node(proof), node(limit))}
+}
+
+// ApprovalOk holds when approver has signed off on the request.
+func ApprovalOk[A Operand](approver A) Cond { return Cond{apply("approval_ok?", node(approver))} }
+
+// BucketOk holds when action's token bucket, holding up to capacity tokens
+// and gaining one every refill, such as "1m", has a token.
+func BucketOk(action string, capacity int, refill string) Cond {
+	return Cond{apply("bucket-ok?", spl.Str(action), spl.Num(capacity), spl.Str(refill))}
+}
@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func TestBuildsExamplePolicy(t *testing.T) {
+	p := And(
+		Eq(Req("actor_pub"), "K_ai"),
+		Eq(Req("action"), "payments.create"),
+		Lte(Req("amount"), 50),
+		Member(Req("recipient"), Var("allowed_recipients")),
+		Eq(Req("purpose"), "giftcard"),
+		Lte(PerDayCount("payments.create", Req("day")), 1),
+		Truthy(Req("device_attested")),
+		DPoPOk(),
+		MerkleOk(Tuple(Req("actor_pub"), Req("action"), Req("recipient"), Lit(50), Lit("giftcard"), Req("day"))),
+		VRFOk(Req("day"), Req("amount")),
+	)
+	src, err := p.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile("../../../examples/policies/family_gifts.spl")
+	if err != nil {
+		t.Skipf("examples not available: %v", err)
+	}
+	want, err := spl.Parse(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src != spl.Render(want) {
+		t.Fatalf("built\n%s\nwant\n%s", src, spl.Render(want))
+	}
+	if back, err := spl.Parse(src); err != nil || spl.Render(back) != src {
+		t.Fatalf("source does not round-trip: %v", err)
+	}
+}
+
+func TestBuilders(t *testing.T) {
+	cases := []struct {
+		c    Cond
+		want string
+	}{
+		{Or(), "(or)"},
+		{Not(Bool(false)), "(not #f)"},
+		{Gt(Req("user", "age"), int64(17)), `(> (get (get req "user") "age") 17)`},
+		{Gte(0.5, Req("score")), `(>= 0.5 (get req "score"))`},
+		{Lt(SumAmount("pay", "month", "USD"), 500), `(< (sum-amount "pay" "month" "USD") 500)`},
+		{Lte(WindowCount("pay", "24h"), 3), `(<= (window-count "pay" "24h") 3)`},
+		{Member(Req("to"), Tuple("a", "b")), `(member (get req "to") (tuple "a" "b"))`},
+		{NotMember(Req("to"), Var("blocked")), `(not-member (get req "to") blocked)`},
+		{Subset(Req("scopes"), Tuple("read")), `(subset? (get req "scopes") (tuple "read"))`},
+		{BloomMember(Req("to"), "blocked"), `(bloom-member? (get req "to") blocked)`},
+		{Before(Now(), "2027-01-01T00:00:00Z"), `(before now "2027-01-01T00:00:00Z")`},
+		{Eq(Req("ok"), true), `(= (get req "ok") #t)`},
+		{MerkleMember(Req("to")), `(merkle-member? (get req "to"))`},
+		{MerkleMemberProof(Req("to"), Req("proof")), `(merkle-member? (get req "to") (get req "proof"))`},
+		{ApprovalOk("alice"), `(approval_ok? "alice")`},
+		{BucketOk("pay", 10, "1m"), `(bucket-ok? "pay" 10 "1m")`},
+		{RangeOk(Req("c"), Req("proof"), 100), `(range_ok? (get req "c") (get req "proof") 100)`},
+		{And(ThreshOk(), AttestedOk()), "(and (thresh_ok?) (attested_ok?))"},
+	}
+	for _, c := range cases {
+		got, err := c.c.Source()
+		if err != nil || got != c.want {
+			t.Errorf("got %s, %v; want %s", got, err, c.want)
+		}
+	}
+
+	allow, err := spl.Verify(And(Eq(Req("action"), "read"), Lte(Req("n"), 2)).Node(), spl.Env{Req: map[string]any{"action": "read", "n": 2.0}})
+	if err != nil || !allow {
+		t.Fatalf("expected the built policy to allow, got %v, %v", allow, err)
+	}
+}
+
+func TestSourceRejectsUnwritableLiterals(t *testing.T) {
+	for _, c := range []Cond{
+		Eq(Req("name"), `x") (or #t`),
+		Lte(Req("amount"), math.Inf(1)),
+		Eq(Var("a b"), 1),
+		Member(Req("to"), Var("")),
+	} {
+		if src, err := c.Source(); err == nil || !strings.HasPrefix(err.Error(), "policy: ") {
+			t.Errorf("expected %s to be refused, got %q, %v", c, src, err)
+		}
+	}
+}
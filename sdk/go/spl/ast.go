@@ -0,0 +1,170 @@
+package spl
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Node is a parsed policy expression: a *Symbol, Str, Num, Bool or List.
+type Node interface{ node() }
+
+// Symbol is a bare name: an operator, or a variable such as req. Sym
+// interns the operators, so evaluation dispatches on Op rather than on the
+// name.
+type Symbol struct {
+	Name string
+	Op   Op // OpNone unless Name is a built-in operator
+
+	// times holds the RFC 3339 literals of the before list this symbol
+	// heads, parsed with the policy; nil for any other symbol.
+	times *[2]timeLit
+}
+
+// Str is a quoted string literal. Evaluation resolves it as it does a
+// Symbol: a string that names a variable evaluates to the variable's value,
+// and under Env.Strict one that names nothing is an error.
+type Str string
+
+// Num is a number literal.
+type Num float64
+
+// Bool is #t or #f.
+type Bool bool
+
+// List is an operator application, (op arg ...).
+type List []Node
+
+func (*Symbol) node() {}
+func (Str) node()     {}
+func (Num) node()     {}
+func (Bool) node()    {}
+func (List) node()    {}
+
+func (s *Symbol) String() string { return s.Name }
+
+// MarshalJSON encodes a symbol as its name, so a parsed policy encodes as
+// nested JSON arrays of names and literals.
+func (s *Symbol) MarshalJSON() ([]byte, error) { return json.Marshal(s.Name) }
+
+// Op identifies a built-in operator.
+type Op uint8
+
+const (
+	OpNone Op = iota
+	OpAnd
+	OpOr
+	OpNot
+	OpEq
+	OpLt
+	OpLe
+	OpGt
+	OpGe
+	OpMember
+	OpIn
+	OpSubset
+	OpBloomMember
+	OpBefore
+	OpGet
+	OpTuple
+	OpPerDayCount
+	OpWindowCount
+	OpBucketOk
+	OpSumAmount
+	OpDPoPOk
+	OpMerkleOk
+	OpVRFOk
+	OpThreshOk
+	OpAttestedOk
+	OpRangeOk
+	OpApprovalOk
+	OpNotMember
+	OpMerkleMember
+	numOps
+)
+
+var opNames = [numOps]string{
+	OpAnd: "and", OpOr: "or", OpNot: "not",
+	OpEq: "=", OpLt: "<", OpLe: "<=", OpGt: ">", OpGe: ">=",
+	OpMember: "member", OpIn: "in", OpSubset: "subset?", OpBloomMember: "bloom-member?", OpBefore: "before",
+	OpGet: "get", OpTuple: "tuple", OpPerDayCount: "per-day-count",
+	OpWindowCount: "window-count", OpBucketOk: "bucket-ok?", OpSumAmount: "sum-amount",
+	OpDPoPOk: "dpop_ok?", OpMerkleOk: "merkle_ok?", OpVRFOk: "vrf_ok?",
+	OpThreshOk: "thresh_ok?", OpAttestedOk: "attested_ok?", OpRangeOk: "range_ok?",
+	OpApprovalOk: "approval_ok?", OpNotMember: "not-member",
+	OpMerkleMember: "merkle-member?",
+}
+
+func (o Op) String() string {
+	if o == OpNone || o >= numOps {
+		return "Op(" + strconv.Itoa(int(o)) + ")"
+	}
+	return opNames[o]
+}
+
+// interned holds the shared symbols: every operator, and the names
+// policies use most.
+var interned = func() map[string]*Symbol {
+	m := map[string]*Symbol{}
+	for op := OpAnd; op < numOps; op++ {
+		m[opNames[op]] = &Symbol{Name: opNames[op], Op: op}
+	}
+	for _, name := range []string{"req", "now", "day"} {
+		m[name] = &Symbol{Name: name}
+	}
+	return m
+}()
+
+// Sym returns the symbol called name, with its Op set if it names an
+// operator. Operators and common names are shared rather than allocated.
+func Sym(name string) *Symbol {
+	if s, ok := interned[name]; ok {
+		return s
+	}
+	return &Symbol{Name: name}
+}
+
+// Head returns the operator l applies and its name, or false if l is
+// empty or its head is not a name. The head is normally a symbol, but a
+// string naming an operator applies it too, since strings and symbols
+// evaluate alike.
+func (l List) Head() (Op, string, bool) {
+	if len(l) == 0 {
+		return OpNone, "", false
+	}
+	switch h := l[0].(type) {
+	case *Symbol:
+		return opOf(h), h.Name, true
+	case Str:
+		return opOf(&Symbol{Name: string(h)}), string(h), true
+	}
+	return OpNone, "", false
+}
+
+// opOf returns the operator a list head names, or OpNone. A Symbol built
+// without Sym is looked up by name.
+func opOf(s *Symbol) Op {
+	if s.Op != OpNone {
+		return s.Op
+	}
+	if i, ok := interned[s.Name]; ok {
+		return i.Op
+	}
+	return OpNone
+}
+
+// isSymbol reports whether n is the symbol called name.
+func isSymbol(n Node, name string) bool {
+	s, ok := n.(*Symbol)
+	return ok && s.Name == name
+}
+
+// text returns the name of a symbol or the value of a string literal.
+func text(n Node) (string, bool) {
+	switch v := n.(type) {
+	case *Symbol:
+		return v.Name, true
+	case Str:
+		return string(v), true
+	}
+	return "", false
+}
@@ -0,0 +1,65 @@
+package spl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSymbolsInterned(t *testing.T) {
+	if Sym("and") != Sym("and") || Sym("and").Op != OpAnd {
+		t.Fatalf("expected and to be interned as OpAnd, got %+v", Sym("and"))
+	}
+	if s := Sym("allowed"); s.Op != OpNone || s == Sym("allowed") {
+		t.Fatalf("expected a fresh non-operator symbol, got %+v", s)
+	}
+	for op := OpAnd; op < numOps; op++ {
+		if Sym(op.String()).Op != op {
+			t.Fatalf("%s: expected to round trip", op)
+		}
+		if _, ok := opArity[op.String()]; !ok {
+			t.Fatalf("%s: expected lint to know its arity", op)
+		}
+	}
+	if got := OpNone.String(); got != "Op(0)" {
+		t.Fatalf("expected Op(0), got %s", got)
+	}
+}
+
+func TestTypedNodesEvaluate(t *testing.T) {
+	env := Env{Req: map[string]any{"n": 3.0}, Vars: map[string]any{"limit": 5.0}}
+	cases := []struct {
+		ast  Node
+		want bool
+	}{
+		// A Symbol built without Sym still applies its operator.
+		{List{&Symbol{Name: "<"}, List{Sym("get"), Sym("req"), Str("n")}, Sym("limit")}, true},
+		// So does a string in operator position.
+		{List{Str("="), Num(3), List{Sym("get"), Sym("req"), Str("n")}}, true},
+		{List{Sym("not"), Bool(true)}, false},
+		// A string naming a variable resolves like the symbol.
+		{List{Sym("="), Str("limit"), Num(5)}, true},
+	}
+	for _, c := range cases {
+		got, err := Verify(c.ast, env)
+		if err != nil || got != c.want {
+			t.Fatalf("%s: expected %v, got %v, %v", render(c.ast), c.want, got, err)
+		}
+	}
+	if _, err := Verify(List{Num(1), Num(2)}, env); err == nil {
+		t.Fatal("expected a number in operator position to fail")
+	}
+}
+
+func TestNodeJSON(t *testing.T) {
+	ast, err := Parse(`(and (member (get req "to") allowed) (= 2.5 10) #t)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `["and",["member",["get","req","to"],"allowed"],["=",2.5,10],true]`; string(b) != want {
+		t.Fatalf("expected %s, got %s", want, b)
+	}
+}
@@ -0,0 +1,133 @@
+package spl
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Attenuate narrows a token: the returned token's policy is
+// (and <original> <constraint>), as is each of its action policies,
+// re-signed with the issuer's private key so it can never allow more than
+// the original. HMAC tokens are narrowed with
+// AddCaveat instead, which needs no key. Sealed tokens cannot be attenuated.
+func Attenuate(t *Token, constraint, privateKeyHex string) (*Token, error) {
+	if t.Alg == AlgHS256 {
+		return AddCaveat(t, constraint)
+	}
+	if t.Sealed {
+		return nil, errors.New("token is sealed and cannot be attenuated")
+	}
+	if _, err := Parse(constraint); err != nil {
+		return nil, fmt.Errorf("constraint parse error: %w", err)
+	}
+	var actions map[string]string
+	if len(t.Actions) > 0 {
+		actions = make(map[string]string, len(t.Actions))
+		for name, policy := range t.Actions {
+			actions[name] = "(and " + policy + " " + constraint + ")"
+		}
+	}
+	return remint(t, "(and "+t.Policy+" "+constraint+")", actions, false, privateKeyHex)
+}
+
+// Seal returns a sealed copy of t, re-signed with the issuer's private key,
+// so holders cannot attenuate it further.
+func Seal(t *Token, privateKeyHex string) (*Token, error) {
+	if t.Alg == AlgHS256 {
+		return nil, errors.New("HMAC tokens are sealed at minting")
+	}
+	return remint(t, t.Policy, t.Actions, true, privateKeyHex)
+}
+
+// remint re-signs t's envelope with new policies and sealed flag. The key
+// must be the token's issuer key; PQ signatures are dropped because the PQ
+// key is not available here.
+func remint(t *Token, policy string, actions map[string]string, sealed bool, privateKeyHex string) (*Token, error) {
+	out, err := Mint(policy, privateKeyHex, MintOptions{
+		Actions:             actions,
+		MerkleRoot:          t.MerkleRoot,
+		HashChainCommitment: t.HashChainCommitment,
+		Sealed:              sealed,
+		Expires:             t.Expires,
+		PoPKey:              t.PoPKey,
+		Alg:                 t.Alg,
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, issuer, err := resolveKeyRef(t.PublicKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if out.PublicKey != issuer {
+		return nil, errors.New("private key does not match the token's issuer key")
+	}
+	out.PublicKey = t.PublicKey
+	out.KeyID = t.KeyID
+	out.X5C = t.X5C
+	out.X5TS256 = t.X5TS256
+	return out, nil
+}
+
+// VerifyDelegation checks that child is a valid delegation of parent, as
+// Attenuate or AddCaveat produce: same issuer and bindings, parent not
+// sealed, no later expiry, and a policy that provably allows nothing the
+// parent's denies (see Subsumes; vars as there). Signatures are not
+// checked; verify each token with VerifyTokenObj.
+func VerifyDelegation(parent, child *Token, vars map[string]any) error {
+	if parent.Sealed {
+		return errors.New("parent token is sealed")
+	}
+	switch {
+	case child.PublicKey != parent.PublicKey || child.Alg != parent.Alg:
+		return errors.New("child token has a different issuer")
+	case child.PoPKey != parent.PoPKey:
+		return errors.New("child token changes the PoP binding")
+	case child.MerkleRoot != parent.MerkleRoot || child.HashChainCommitment != parent.HashChainCommitment:
+		return errors.New("child token changes the Merkle root or hash-chain commitment")
+	}
+	if parent.Expires != "" {
+		pe, err := time.Parse(time.RFC3339, parent.Expires)
+		if err != nil {
+			return fmt.Errorf("parent expiry: %w", err)
+		}
+		ce, err := time.Parse(time.RFC3339, child.Expires)
+		if err != nil {
+			return errors.New("child token must expire no later than its parent")
+		}
+		if ce.After(pe) {
+			return errors.New("child token expires after its parent")
+		}
+	}
+	// Compare the policies each token applies to every action either
+	// names, and to the rest.
+	actions := []string{""}
+	for name := range parent.Actions {
+		actions = append(actions, name)
+	}
+	for name := range child.Actions {
+		if _, ok := parent.Actions[name]; !ok {
+			actions = append(actions, name)
+		}
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		pp, err := tokenPolicy(parent, parent.actionPolicy(action), nil, Limits{})
+		if err != nil {
+			return fmt.Errorf("parent %w", err)
+		}
+		cp, err := tokenPolicy(child, child.actionPolicy(action), nil, Limits{})
+		if err != nil {
+			return fmt.Errorf("child %w", err)
+		}
+		if !Subsumes(pp, cp, vars) {
+			if action == "" {
+				return errors.New("child policy is not provably narrower than its parent's")
+			}
+			return fmt.Errorf("child policy for action %q is not provably narrower than its parent's", action)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package spl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttenuateNarrowsPolicy(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{Expires: "2099-01-01T00:00:00Z"})
+	narrow, err := Attenuate(tok, `(<= (get req "amount") 25)`, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if narrow.Expires != tok.Expires {
+		t.Fatal("expected attenuation to keep the envelope")
+	}
+	if r := VerifyTokenObj(narrow, tokenTestReq(20), VerifyTokenOptions{}); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+	if r := VerifyTokenObj(narrow, tokenTestReq(50), VerifyTokenOptions{}); r.Allow {
+		t.Fatal("expected attenuated token to deny $50")
+	}
+
+	_, other := GenerateKeypair()
+	if _, err := Attenuate(tok, `(= 1 1)`, other); err == nil {
+		t.Fatal("expected a foreign key to be rejected")
+	}
+	if _, err := Attenuate(tok, `(<= 1`, priv); err == nil {
+		t.Fatal("expected an unparsable constraint to be rejected")
+	}
+}
+
+func TestSealBlocksAttenuation(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{})
+	sealed, err := Seal(tok, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := VerifyTokenObj(sealed, tokenTestReq(50), VerifyTokenOptions{}); !r.Allow || !r.Sealed {
+		t.Fatalf("expected sealed allow, got %+v", r)
+	}
+	if _, err := Attenuate(sealed, `(= 1 1)`, priv); err == nil {
+		t.Fatal("expected sealed token to refuse attenuation")
+	}
+}
+
+func TestAttenuateHMACUsesCaveats(t *testing.T) {
+	tok, _ := MintHMAC(tokenTestPolicy, testHMACSecret, MintOptions{})
+	narrow, err := Attenuate(tok, `(<= (get req "amount") 25)`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(narrow.Caveats) != 1 {
+		t.Fatalf("expected one caveat, got %d", len(narrow.Caveats))
+	}
+}
+
+func TestVerifyDelegation(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{Expires: "2099-01-01T00:00:00Z"})
+	child, _ := Attenuate(tok, `(<= (get req "amount") 25)`, priv)
+	if err := VerifyDelegation(tok, child, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	wider, _ := Mint(`(<= (get req "amount") 1000)`, priv, MintOptions{Expires: "2099-01-01T00:00:00Z"})
+	later, _ := Mint(tokenTestPolicy, priv, MintOptions{Expires: "2100-01-01T00:00:00Z"})
+	_, other := GenerateKeypair()
+	foreign, _ := Mint(tokenTestPolicy, other, MintOptions{Expires: "2099-01-01T00:00:00Z"})
+	sealed, _ := Seal(tok, priv)
+	for name, tc := range map[string][2]*Token{
+		"wider policy":   {tok, wider},
+		"later expiry":   {tok, later},
+		"other issuer":   {tok, foreign},
+		"sealed parent":  {sealed, child},
+		"reversed chain": {child, tok},
+	} {
+		if err := VerifyDelegation(tc[0], tc[1], nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	hmacTok, _ := MintHMAC(tokenTestPolicy, testHMACSecret, MintOptions{})
+	caveated, _ := AddCaveat(hmacTok, `(<= (get req "amount") 25)`)
+	if err := VerifyDelegation(hmacTok, caveated, nil); err != nil {
+		t.Fatalf("caveat: %v", err)
+	}
+}
+
+func TestAttenuateActions(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, _ := Mint(`(= (get req "action") "read")`, priv, MintOptions{Actions: map[string]string{
+		"pay": `(<= (get req "amount") 100)`,
+	}})
+	narrow, err := Attenuate(tok, `(= (get req "region") "eu")`, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []map[string]any{{"action": "read"}, {"action": "pay", "amount": 5.0}} {
+		if r := VerifyTokenObj(narrow, req, VerifyTokenOptions{}); r.Allow {
+			t.Errorf("%v: expected the constraint to apply", req)
+		}
+		req["region"] = "eu"
+		if r := VerifyTokenObj(narrow, req, VerifyTokenOptions{}); !r.Allow {
+			t.Errorf("%v: expected allow, got %q", req, r.ErrorMessage())
+		}
+	}
+	if err := VerifyDelegation(tok, narrow, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A child that widens one action, or adds one the parent's default
+	// policy would not allow, is no delegation.
+	wider, _ := Mint(`(= (get req "action") "read")`, priv, MintOptions{Actions: map[string]string{"pay": `(<= (get req "amount") 1000)`}})
+	added, _ := Mint(`(= (get req "action") "read")`, priv, MintOptions{Actions: map[string]string{"pay": `(<= (get req "amount") 100)`, "delete": "#t"}})
+	for _, child := range []*Token{wider, added} {
+		if err := VerifyDelegation(tok, child, nil); err == nil || !strings.Contains(err.Error(), "action") {
+			t.Errorf("expected an action to be found wider, got %v", err)
+		}
+	}
+}
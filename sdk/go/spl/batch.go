@@ -0,0 +1,130 @@
+package spl
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// BatchItem is one presentation in a VerifyBatch call.
+type BatchItem struct {
+	// Token is the presented token, JSON or compact.
+	Token   string
+	Request map[string]any
+	// PresentationSignature proves possession of the token's pop_key for
+	// this request; see VerifyTokenOptions.PresentationSignature.
+	PresentationSignature string
+}
+
+// BatchOptions configures VerifyBatch. The embedded options apply to every
+// item, except PresentationSignature, which is taken from each item.
+type BatchOptions struct {
+	VerifyTokenOptions
+	// Workers bounds the number of items verified at once; 0 means
+	// GOMAXPROCS.
+	Workers int
+}
+
+// VerifyBatch verifies a burst of presentations across a bounded pool of
+// workers and returns their results in the order of items. The batch is
+// verified as of one instant, so work shared between items is done once:
+// each distinct token is decoded once and its signatures, key resolution
+// and certificate chain checked once, and every policy is parsed once
+// through the policy cache. Per-item state is reused as by a Verifier.
+// Counters, rates and other stores are consulted per item, as by
+// VerifyTokenObj. Items not started when ctx is done fail with its error.
+func VerifyBatch(ctx context.Context, items []BatchItem, opts BatchOptions) []VerifyTokenResult {
+	results := make([]VerifyTokenResult, len(items))
+	if len(items) == 0 {
+		return results
+	}
+	base := opts.VerifyTokenOptions
+	if base.Now == "" || base.Clock != nil {
+		base.Now, base.Clock = base.now().UTC().Format(time.RFC3339), nil
+	}
+	if base.PolicyCache == nil {
+		base.PolicyCache = DefaultPolicyCache
+	}
+	base.auth = &authMemo{entries: map[*Token]*authEntry{}}
+	base.scratch = &sync.Pool{New: func() any { return &verifyScratch{} }}
+
+	// Decode each distinct token once; items presenting it share the
+	// *Token, which keys the signature memo.
+	type decoded struct {
+		tok *Token
+		err error
+	}
+	tokens := make(map[string]decoded)
+	parsed := make([]decoded, len(items))
+	for i, it := range items {
+		d, ok := tokens[it.Token]
+		if !ok {
+			d.tok, d.err = ParseToken(it.Token)
+			tokens[it.Token] = d
+		}
+		parsed[i] = d
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(items))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := ctx.Err(); err != nil {
+					results[i] = failed(nil, err)
+					continue
+				}
+				d := parsed[i]
+				if d.err != nil {
+					results[i] = failed(nil, d.err)
+					continue
+				}
+				o := base
+				o.PresentationSignature = items[i].PresentationSignature
+				results[i] = VerifyTokenObj(d.tok, items[i].Request, o)
+			}
+		}()
+	}
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// authMemo remembers the outcome of verifyAuthenticity per token within a
+// batch, whose items share one verification time.
+type authMemo struct {
+	mu      sync.Mutex
+	entries map[*Token]*authEntry
+}
+
+type authEntry struct {
+	once sync.Once
+	err  error
+}
+
+// verify is verifyAuthenticity, run once per token when m is set.
+func (m *authMemo) verify(t *Token, payload []byte, opts *VerifyTokenOptions, now time.Time) error {
+	if m == nil {
+		return verifyAuthenticity(t, payload, opts, now)
+	}
+	m.mu.Lock()
+	e := m.entries[t]
+	if e == nil {
+		e = &authEntry{}
+		m.entries[t] = e
+	}
+	m.mu.Unlock()
+	e.once.Do(func() { e.err = verifyAuthenticity(t, payload, opts, now) })
+	return e.err
+}
@@ -0,0 +1,131 @@
+package spl
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingResolver trusts one key and counts its lookups.
+type countingResolver struct {
+	pub   string
+	calls atomic.Int32
+}
+
+func (r *countingResolver) ResolveKey(*Token, time.Time) (string, error) {
+	r.calls.Add(1)
+	return r.pub, nil
+}
+
+func TestVerifyBatch(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := mustJSON(t, tok)
+	compact, err := tok.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := *tok
+	forged.Policy = `(= (get req "action") "payments.create")`
+
+	items := []BatchItem{
+		{Token: good, Request: tokenTestReq(50)},
+		{Token: good, Request: tokenTestReq(500)},
+		{Token: compact, Request: tokenTestReq(100)},
+		{Token: mustJSON(t, &forged), Request: tokenTestReq(50)},
+		{Token: "{", Request: tokenTestReq(50)},
+	}
+	for i := 0; i < 50; i++ {
+		items = append(items, BatchItem{Token: good, Request: tokenTestReq(float64(i))})
+	}
+	r := &countingResolver{pub: pub}
+	res := VerifyBatch(context.Background(), items, BatchOptions{VerifyTokenOptions: VerifyTokenOptions{KeyResolver: r}, Workers: 4})
+	if len(res) != len(items) {
+		t.Fatalf("expected %d results, got %d", len(items), len(res))
+	}
+	want := []struct {
+		allow bool
+		err   string
+	}{{true, ""}, {false, ""}, {true, ""}, {false, "invalid signature"}, {false, "invalid token JSON"}}
+	for i, w := range want {
+		if res[i].Allow != w.allow || !strings.Contains(res[i].ErrorMessage(), w.err) || (w.err == "" && res[i].Err != nil) {
+			t.Fatalf("item %d: expected allow=%v error %q, got %+v", i, w.allow, w.err, res[i])
+		}
+	}
+	for i, r := range res[len(want):] {
+		if !r.Allow {
+			t.Fatalf("item %d: expected allow, got %q", i+len(want), r.ErrorMessage())
+		}
+	}
+	// One resolution per distinct token: the JSON, compact and forged forms.
+	if got := r.calls.Load(); got != 3 {
+		t.Fatalf("expected 3 key resolutions, got %d", got)
+	}
+}
+
+func TestVerifyBatchMatchesVerifyToken(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{Expires: "2026-01-01T00:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(tok)
+	for _, now := range []string{"2025-06-01T00:00:00Z", "2026-06-01T00:00:00Z"} {
+		opts := VerifyTokenOptions{Now: now}
+		want := VerifyToken(string(data), tokenTestReq(50), opts)
+		got := VerifyBatch(context.Background(), []BatchItem{{Token: string(data), Request: tokenTestReq(50)}}, BatchOptions{VerifyTokenOptions: opts})
+		if got[0] != want {
+			t.Fatalf("%s: expected %+v, got %+v", now, want, got[0])
+		}
+	}
+
+	// A clock between whole seconds gives both the same now.
+	deadline, _ := Mint(`(before now "2026-01-01T12:00:00Z")`, priv, MintOptions{})
+	data, _ = json.Marshal(deadline)
+	opts := VerifyTokenOptions{Clock: func() time.Time { return time.Date(2026, 1, 1, 12, 0, 0, 5e8, time.UTC) }}
+	want := VerifyToken(string(data), map[string]any{}, opts)
+	got := VerifyBatch(context.Background(), []BatchItem{{Token: string(data), Request: map[string]any{}}}, BatchOptions{VerifyTokenOptions: opts})
+	if got[0] != want || want.Allow {
+		t.Fatalf("expected both to deny at the deadline, got %+v and %+v", got[0], want)
+	}
+}
+
+func TestVerifyBatchCancelled(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res := VerifyBatch(ctx, []BatchItem{{Token: mustJSON(t, tok), Request: tokenTestReq(50)}}, BatchOptions{})
+	if res[0].Allow || res[0].ErrorMessage() != context.Canceled.Error() {
+		t.Fatalf("expected a cancelled item, got %+v", res[0])
+	}
+	if len(VerifyBatch(ctx, nil, BatchOptions{})) != 0 {
+		t.Fatal("expected no results for no items")
+	}
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, _ := json.Marshal(tok)
+	items := make([]BatchItem, 256)
+	for i := range items {
+		items[i] = BatchItem{Token: string(data), Request: tokenTestReq(50)}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		VerifyBatch(context.Background(), items, BatchOptions{})
+	}
+}
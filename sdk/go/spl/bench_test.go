@@ -33,12 +33,7 @@ func benchEnv() Env {
 			"now":                "2025-06-01T00:00:00Z",
 		},
 		PerDayCount: func(action, day string) int { return 0 },
-		Crypto: struct {
-			DPoPOk   func() bool
-			MerkleOk func(tuple []any) bool
-			VRFOk    func(day string, amount float64) bool
-			ThreshOk func() bool
-		}{
+		Crypto: CryptoCallbacks{
 			DPoPOk:   func() bool { return true },
 			MerkleOk: func(tuple []any) bool { return true },
 			VRFOk:    func(day string, amount float64) bool { return true },
@@ -48,6 +43,7 @@ func benchEnv() Env {
 }
 
 func BenchmarkParseAndEval(b *testing.B) {
+	b.ReportAllocs()
 	env := benchEnv()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -76,3 +72,28 @@ func BenchmarkEvalOnly(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkEvalMemoized(b *testing.B) {
+	ast, err := Parse(benchPolicy)
+	if err != nil {
+		b.Fatal(err)
+	}
+	env := benchEnv()
+	env.Memoize = true
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Verify(ast, env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(benchPolicy); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package spl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"math"
+	"strconv"
+)
+
+// Bloom is a Bloom filter over strings, for checking membership of lists
+// too large to ship whole, such as blocklists of millions of entries. It
+// never misses a member, but reports a non-member as one at about the
+// false-positive rate it was sized for. Bind one in Vars and test it with
+// (bloom-member? x filter). Contains is safe for concurrent use; Add is not.
+type Bloom struct {
+	k    uint32   // hash functions
+	m    uint64   // bits
+	bits []uint64 // m bits, little end first
+}
+
+// NewBloom returns an empty filter sized for n entries at false-positive
+// rate fpRate, which must be between 0 and 1.
+func NewBloom(n int, fpRate float64) (*Bloom, error) {
+	if !(fpRate > 0 && fpRate < 1) {
+		return nil, fmt.Errorf("bloom: false-positive rate %v is not between 0 and 1", fpRate)
+	}
+	n = max(n, 1)
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Round(float64(m) / float64(n) * math.Ln2))
+	return newBloom(max(m, 64), max(k, 1)), nil
+}
+
+func newBloom(m uint64, k uint32) *Bloom {
+	return &Bloom{k: k, m: m, bits: make([]uint64, (m+63)/64)}
+}
+
+// Add adds s to the filter.
+func (b *Bloom) Add(s string) {
+	h1, h2 := bloomHash(s)
+	for i := uint64(0); i < uint64(b.k); i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Contains reports whether s may have been added: false means it was not.
+func (b *Bloom) Contains(s string) bool {
+	h1, h2 := bloomHash(s)
+	for i := uint64(0); i < uint64(b.k); i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHash derives the two hashes the k bit positions are built from
+// (Kirsch and Mitzenmacher): the halves of s's 128-bit FNV-1a hash.
+func bloomHash(s string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(s))
+	var sum [16]byte
+	h.Sum(sum[:0])
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+// BloomVersion is the version of the binary filter format that
+// MarshalBinary writes.
+const BloomVersion = 1
+
+var bloomMagic = []byte("ASBLOOM")
+
+// MarshalBinary encodes b: the magic "ASBLOOM", the format version, the
+// number of hash functions and of bits as varints, the bits as big-endian
+// 64-bit words, and a CRC-32 of everything before it.
+func (b *Bloom) MarshalBinary() ([]byte, error) {
+	out := make([]byte, 0, len(bloomMagic)+3*binary.MaxVarintLen64+8*len(b.bits)+4)
+	out = append(out, bloomMagic...)
+	out = binary.AppendUvarint(out, BloomVersion)
+	out = binary.AppendUvarint(out, uint64(b.k))
+	out = binary.AppendUvarint(out, b.m)
+	for _, w := range b.bits {
+		out = binary.BigEndian.AppendUint64(out, w)
+	}
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out)), nil
+}
+
+// UnmarshalBinary decodes a filter MarshalBinary encoded, refusing
+// versions it does not know and data that fails its checksum.
+func (b *Bloom) UnmarshalBinary(data []byte) error {
+	if len(data) < len(bloomMagic)+4 || !bytes.HasPrefix(data, bloomMagic) {
+		return errors.New("bloom: not a Bloom filter")
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return errors.New("bloom: checksum mismatch")
+	}
+	r := &snapshotReader{b: body[len(bloomMagic):]}
+	if v := r.uvarint(); r.err == nil && v != BloomVersion {
+		return fmt.Errorf("bloom: unsupported version %d", v)
+	}
+	k, m := r.uvarint(), r.uvarint()
+	if r.err != nil {
+		return fmt.Errorf("bloom: %w", r.err)
+	}
+	if k == 0 || k > math.MaxUint32 || m == 0 || m > uint64(len(r.b))*8 || uint64(len(r.b)) != (m+63)/64*8 {
+		return errors.New("bloom: malformed filter")
+	}
+	f := newBloom(m, uint32(k))
+	for i := range f.bits {
+		f.bits[i] = binary.BigEndian.Uint64(r.b[8*i:])
+	}
+	*b = *f
+	return nil
+}
+
+// bloomKey is the string a bloom-member? operand is looked up as: a string
+// itself, and a number in its shortest decimal form.
+func bloomKey(x any) (string, bool) {
+	if s, ok := x.(string); ok {
+		return s, true
+	}
+	if f, ok := number(x); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,109 @@
+package spl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBloomFalsePositiveRate(t *testing.T) {
+	const n = 20000
+	b, err := NewBloom(n, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		b.Add(fmt.Sprintf("blocked-%d", i))
+	}
+	for i := 0; i < n; i++ {
+		if !b.Contains(fmt.Sprintf("blocked-%d", i)) {
+			t.Fatalf("expected blocked-%d to be a member", i)
+		}
+	}
+	fp := 0
+	for i := 0; i < n; i++ {
+		if b.Contains(fmt.Sprintf("allowed-%d", i)) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / n; rate > 0.02 {
+		t.Fatalf("expected a false-positive rate near 1%%, got %.2f%%", rate*100)
+	}
+	for _, p := range []float64{0, 1, -0.5} {
+		if _, err := NewBloom(10, p); err == nil {
+			t.Fatalf("expected rate %v to be rejected", p)
+		}
+	}
+}
+
+func TestBloomBinaryRoundTrip(t *testing.T) {
+	b, _ := NewBloom(1000, 0.001)
+	b.Add("mallory@example.com")
+	data, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Bloom
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Contains("mallory@example.com") || got.Contains("alice@example.com") {
+		t.Fatal("expected the decoded filter to match the original")
+	}
+
+	flipped := append([]byte{}, data...)
+	flipped[len(bloomMagic)+5] ^= 1
+	badVersion, _ := newBloom(64, 1).MarshalBinary()
+	badVersion[len(bloomMagic)] = 2
+	for name, c := range map[string]struct {
+		data []byte
+		err  string
+	}{
+		"magic":    {[]byte("ASSNAP\x01\x00\x00\x00\x00"), "not a Bloom filter"},
+		"checksum": {flipped, "checksum mismatch"},
+		"version":  {resum(badVersion), "unsupported version 2"},
+		"size":     {resum(append(append([]byte{}, data[:len(data)-4]...), 0)), "malformed"},
+	} {
+		if err := got.UnmarshalBinary(c.data); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected %q, got %v", name, c.err, err)
+		}
+	}
+}
+
+func TestBloomMemberOp(t *testing.T) {
+	blocked, _ := NewBloom(100, 0.001)
+	blocked.Add("mallory@example.com")
+	blocked.Add("42")
+	env := Env{
+		Req:  map[string]any{"to": "mallory@example.com", "id": 42.0, "ok": "alice@example.com"},
+		Vars: map[string]any{"blocklist": blocked},
+	}
+	cases := []struct {
+		src  string
+		want bool
+		err  string
+	}{
+		{`(bloom-member? (get req "to") blocklist)`, true, ""},
+		{`(bloom-member? (get req "id") blocklist)`, true, ""},
+		{`(not (bloom-member? (get req "ok") blocklist))`, true, ""},
+		{`(bloom-member? #t blocklist)`, false, ""},
+		{`(bloom-member? (get req "to") (tuple "a"))`, false, "must be a Bloom filter"},
+		{`(bloom-member? (get req "to"))`, false, "requires 2 arguments"},
+	}
+	for _, c := range cases {
+		ast, err := Parse(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Verify(ast, env)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: expected error %q, got %v", c.src, c.err, err)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("%s: expected %v, got %v, %v", c.src, c.want, got, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package spl
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// DefaultPolicyCacheSize is the capacity of DefaultPolicyCache.
+const DefaultPolicyCacheSize = 1024
+
+// DefaultPolicyCache holds the parsed policies of tokens verified without
+// VerifyTokenOptions.PolicyCache.
+var DefaultPolicyCache = NewPolicyCache(DefaultPolicyCacheSize)
+
+// PolicyCache keeps the parsed form of recently seen policies, keyed by the
+// SHA-256 of their source, so a policy presented again is not tokenized and
+// parsed again. Evaluation never modifies a parsed policy, so one AST is
+// safely shared by concurrent verifications. Only policies that parse are
+// cached. It is safe for concurrent use.
+type PolicyCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is most recently used
+	entries map[[32]byte]*list.Element
+	stats   PolicyCacheStats
+}
+
+// PolicyCacheStats counts a PolicyCache's lookups.
+type PolicyCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+type policyCacheEntry struct {
+	digest [32]byte
+	ast    Node
+	shape  policyShape
+}
+
+// NewPolicyCache returns a cache of at most size policies, evicting the
+// least recently used. A size of 0 or less caches nothing.
+func NewPolicyCache(size int) *PolicyCache {
+	return &PolicyCache{size: size, order: list.New(), entries: map[[32]byte]*list.Element{}}
+}
+
+// Parse returns the parsed form of src, from the cache if it has been
+// parsed before. A nil cache parses every time.
+func (c *PolicyCache) Parse(src string) (Node, error) {
+	return c.ParseLimits(src, DefaultLimits)
+}
+
+// ParseLimits is Parse within l. A cached policy is checked against l as
+// well, so a policy cached under looser limits is still refused.
+func (c *PolicyCache) ParseLimits(src string, l Limits) (Node, error) {
+	l = l.withDefaults()
+	if c == nil || c.size <= 0 {
+		ast, _, err := parseLimits(src, l)
+		return ast, err
+	}
+	digest := sha256.Sum256([]byte(src))
+	c.mu.Lock()
+	if e, ok := c.entries[digest]; ok {
+		c.order.MoveToFront(e)
+		c.stats.Hits++
+		c.mu.Unlock()
+		entry := e.Value.(*policyCacheEntry)
+		if err := l.check(entry.shape); err != nil {
+			return nil, err
+		}
+		return entry.ast, nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// Parse outside the lock; two callers may both parse a new policy,
+	// and the second simply refreshes the entry.
+	ast, shape, err := parseLimits(src, l)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[digest]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*policyCacheEntry).ast, nil
+	}
+	c.entries[digest] = c.order.PushFront(&policyCacheEntry{digest: digest, ast: ast, shape: shape})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*policyCacheEntry).digest)
+		c.stats.Evictions++
+	}
+	return ast, nil
+}
+
+// Len returns the number of cached policies.
+func (c *PolicyCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns the cache's hit, miss and eviction counts.
+func (c *PolicyCache) Stats() PolicyCacheStats {
+	if c == nil {
+		return PolicyCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Purge empties the cache. Its statistics are kept.
+func (c *PolicyCache) Purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = map[[32]byte]*list.Element{}
+}
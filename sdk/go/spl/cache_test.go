@@ -0,0 +1,106 @@
+package spl
+
+import "testing"
+
+func TestPolicyCacheLRU(t *testing.T) {
+	c := NewPolicyCache(2)
+	policies := []string{`(= (get req "a") 1)`, `(= (get req "a") 2)`, `(= (get req "a") 3)`}
+	for _, p := range policies[:2] {
+		if _, err := c.Parse(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Touch the first so the second is the least recently used.
+	if _, err := c.Parse(policies[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Parse(policies[2]); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Stats(); got != (PolicyCacheStats{Hits: 1, Misses: 3, Evictions: 1}) {
+		t.Fatalf("expected 1 hit, 3 misses, 1 eviction, got %+v", got)
+	}
+	if _, err := c.Parse(policies[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Parse(policies[1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Stats(); got.Hits != 2 || got.Misses != 4 {
+		t.Fatalf("expected the second policy to have been evicted, got %+v", got)
+	}
+	if c.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", c.Len())
+	}
+	c.Purge()
+	if c.Len() != 0 {
+		t.Fatalf("expected an empty cache, got %d", c.Len())
+	}
+}
+
+func TestPolicyCacheErrorsNotCached(t *testing.T) {
+	c := NewPolicyCache(4)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Parse(`(and x`); err == nil {
+			t.Fatal("expected parse error")
+		}
+	}
+	if c.Len() != 0 || c.Stats().Misses != 2 {
+		t.Fatalf("expected errors to miss and not be cached, got %d entries, %+v", c.Len(), c.Stats())
+	}
+	var none *PolicyCache
+	if _, err := none.Parse(tokenTestPolicy); err != nil || none.Len() != 0 {
+		t.Fatalf("expected a nil cache to parse, got %v", err)
+	}
+	if _, err := NewPolicyCache(0).Parse(tokenTestPolicy); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyTokenUsesPolicyCache(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewPolicyCache(8)
+	opts := VerifyTokenOptions{PolicyCache: c}
+	for _, tc := range []struct {
+		amount float64
+		allow  bool
+	}{{50, true}, {150, false}, {100, true}} {
+		if r := VerifyTokenObj(tok, tokenTestReq(tc.amount), opts); r.Allow != tc.allow {
+			t.Fatalf("amount %v: expected allow=%v, got %v (%q)", tc.amount, tc.allow, r.Allow, r.ErrorMessage())
+		}
+	}
+	if got := c.Stats(); got.Hits != 2 || got.Misses != 1 {
+		t.Fatalf("expected one parse and two hits, got %+v", got)
+	}
+
+	tok.Policy = `(and (= (get req "action") "payments.create")`
+	if r := VerifyTokenObj(tok, tokenTestReq(50), opts); r.Allow || r.Err == nil {
+		t.Fatalf("expected tampered policy to be denied, got %+v", r)
+	}
+}
+
+func BenchmarkVerifyTokenCached(b *testing.B) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(benchPolicy, priv, MintOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	env := benchEnv()
+	for _, c := range []struct {
+		name  string
+		cache *PolicyCache
+	}{{"uncached", NewPolicyCache(0)}, {"cached", NewPolicyCache(DefaultPolicyCacheSize)}} {
+		opts := VerifyTokenOptions{Vars: env.Vars, PerDayCount: env.PerDayCount, Crypto: env.Crypto, PolicyCache: c.cache}
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if r := VerifyTokenObj(tok, env.Req, opts); !r.Allow {
+					b.Fatal(r.ErrorMessage())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package spl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// CBOR (RFC 8949) in its core deterministic encoding, over the values JSON
+// decodes to: arguments and floats in their shortest form, definite
+// lengths only, and map keys sorted by their encoded bytes. A value thus
+// has exactly one encoding, so encodings can be compared and signed.
+
+const (
+	cborUint   = 0 << 5
+	cborNegint = 1 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborSimple = 7 << 5
+)
+
+// cborFromJSON encodes the JSON document data.
+func cborFromJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return appendCBOR(nil, v)
+}
+
+func appendCBOR(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, cborSimple|22), nil
+	case bool:
+		if v {
+			return append(b, cborSimple|21), nil
+		}
+		return append(b, cborSimple|20), nil
+	case string:
+		return append(appendCBORHead(b, cborText, uint64(len(v))), v...), nil
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return appendCBORInt(b, n), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return appendCBOR(b, f)
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return appendCBORInt(b, int64(v)), nil
+		}
+		return appendCBORFloat(b, v), nil
+	case []any:
+		b = appendCBORHead(b, cborArray, uint64(len(v)))
+		for _, item := range v {
+			var err error
+			if b, err = appendCBOR(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// Encoded text keys sort by length, then bytewise.
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		b = appendCBORHead(b, cborMap, uint64(len(v)))
+		for _, k := range keys {
+			b = append(appendCBORHead(b, cborText, uint64(len(k))), k...)
+			var err error
+			if b, err = appendCBOR(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("cbor: cannot encode %T", v)
+}
+
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), n)
+}
+
+func appendCBORInt(b []byte, n int64) []byte {
+	if n < 0 {
+		return appendCBORHead(b, cborNegint, uint64(-1-n))
+	}
+	return appendCBORHead(b, cborUint, uint64(n))
+}
+
+// appendCBORFloat encodes f in the shortest of half, single and double
+// precision that holds it exactly.
+func appendCBORFloat(b []byte, f float64) []byte {
+	if f32 := float32(f); float64(f32) == f || math.IsNaN(f) {
+		if h, ok := float16Bits(f32); ok {
+			return binary.BigEndian.AppendUint16(append(b, cborSimple|25), h)
+		}
+		return binary.BigEndian.AppendUint32(append(b, cborSimple|26), math.Float32bits(f32))
+	}
+	return binary.BigEndian.AppendUint64(append(b, cborSimple|27), math.Float64bits(f))
+}
+
+// float16Bits returns f as an IEEE 754 half, if one holds it exactly.
+func float16Bits(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23&0xff) - 127
+	mant := bits & 0x7fffff
+	switch {
+	case f != f:
+		return 0x7e00, true
+	case f == 0:
+		return sign, true
+	case math.IsInf(float64(f), 0):
+		return sign | 0x7c00, true
+	case exp >= -14 && exp <= 15 && mant&0x1fff == 0:
+		return sign | uint16(exp+15)<<10 | uint16(mant>>13), true
+	case exp >= -24 && exp < -14:
+		// A subnormal half: the mantissa, with its implicit bit, shifted
+		// right by the exponent's distance below the normal range.
+		shift := uint(13 - 14 - exp)
+		full := mant | 0x800000
+		if full&(1<<shift-1) == 0 {
+			return sign | uint16(full>>shift), true
+		}
+	}
+	return 0, false
+}
+
+func float16Value(h uint16) float64 {
+	exp, mant := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		f = math.Inf(1)
+		if mant != 0 {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+// cborToJSON decodes the single CBOR item in data as a JSON document. It
+// accepts any definite-length encoding of the types appendCBOR writes,
+// nested at most MaxDepth deep.
+func cborToJSON(data []byte) ([]byte, error) {
+	d := cborDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.data) > 0 {
+		return nil, errors.New("cbor: trailing data")
+	}
+	return json.Marshal(v)
+}
+
+type cborDecoder struct {
+	data []byte
+}
+
+func (d *cborDecoder) head() (major byte, info byte, n uint64, err error) {
+	if len(d.data) == 0 {
+		return 0, 0, 0, errCBORTruncated
+	}
+	major, info = d.data[0]&0xe0, d.data[0]&0x1f
+	d.data = d.data[1:]
+	size := 0
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		size = 1 << (info - 24)
+	default:
+		return 0, 0, 0, fmt.Errorf("cbor: unsupported additional information %d", info)
+	}
+	if len(d.data) < size {
+		return 0, 0, 0, errCBORTruncated
+	}
+	for _, c := range d.data[:size] {
+		n = n<<8 | uint64(c)
+	}
+	d.data = d.data[size:]
+	return major, info, n, nil
+}
+
+func (d *cborDecoder) value(depth int) (any, error) {
+	if depth > MaxDepth {
+		return nil, errors.New("cbor: nested too deeply")
+	}
+	major, info, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		if n > math.MaxInt64 {
+			return float64(n), nil
+		}
+		return int64(n), nil
+	case cborNegint:
+		if n > math.MaxInt64 {
+			return -1 - float64(n), nil
+		}
+		return -1 - int64(n), nil
+	case cborText:
+		if n > uint64(len(d.data)) {
+			return nil, errCBORTruncated
+		}
+		s := string(d.data[:n])
+		d.data = d.data[n:]
+		if !utf8.ValidString(s) {
+			return nil, errors.New("cbor: text is not UTF-8")
+		}
+		return s, nil
+	case cborArray:
+		// Each item takes at least a byte, which bounds what a length
+		// can make us allocate.
+		if n > uint64(len(d.data)) {
+			return nil, errCBORTruncated
+		}
+		out := make([]any, n)
+		for i := range out {
+			if out[i], err = d.value(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case cborMap:
+		if n > uint64(len(d.data))/2 {
+			return nil, errCBORTruncated
+		}
+		out := make(map[string]any, n)
+		for i := uint64(0); i < n; i++ {
+			k, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("cbor: map key %T is not text", k)
+			}
+			if out[key], err = d.value(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case cborSimple:
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		case 25:
+			return float16Value(uint16(n)), nil
+		case 26:
+			return float64(math.Float32frombits(uint32(n))), nil
+		case 27:
+			return math.Float64frombits(n), nil
+		}
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+	}
+	return nil, fmt.Errorf("cbor: unsupported major type %d", major>>5)
+}
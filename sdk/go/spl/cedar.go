@@ -0,0 +1,568 @@
+package spl
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ToCedar translates a policy into a Cedar permit policy, for review in
+// tooling built around AWS Cedar. The request becomes Cedar's context, and
+// the principal, action and resource are left unconstrained:
+//
+//	(and (= (get req "action") "read") (<= (get req "amount") 50))
+//
+// becomes
+//
+//	permit (principal, action, resource)
+//	when {
+//	  (context.action == "read") && (context.amount <= 50)
+//	};
+//
+// Values SPL takes from its environment rather than the request are read
+// from context too, and each is noted in the Warnings: a variable such as
+// now from context.vars, dpop_ok? and the other zero-argument crypto
+// predicates from context.crypto, and (approval_ok? "parent") as
+// context.approvals.contains("parent"). before compares Cedar datetimes.
+// Cedar has no counterpart for per-day-count, merkle_ok?, merkle-member?,
+// vrf_ok? or range_ok?, or for fractional numbers, and a policy using them
+// is an error.
+func ToCedar(src string) (*Translation, error) {
+	root, err := readPolicy(src)
+	if err != nil {
+		return nil, err
+	}
+	t := &Translation{}
+	cond, err := cedarExpr(t, root)
+	if err != nil {
+		return nil, err
+	}
+	t.Policy = "permit (principal, action, resource)\nwhen {\n  " + cond + "\n};\n"
+	return t, nil
+}
+
+// cedarReserved are the Cedar words that cannot follow a dot.
+var cedarReserved = map[string]bool{
+	"true": true, "false": true, "if": true, "then": true, "else": true,
+	"in": true, "like": true, "has": true, "is": true,
+}
+
+func cedarAttr(base, name string) string {
+	if isIdent(name) && !cedarReserved[name] {
+		return base + "." + name
+	}
+	return base + "[" + quoteC(name, true) + "]"
+}
+
+// cedarOperand renders n for use as an operand, parenthesized unless it is
+// an atom, an attribute access, a set or a method call.
+func cedarOperand(t *Translation, n *syntaxNode) (string, error) {
+	s, err := cedarExpr(t, n)
+	if err != nil {
+		return "", err
+	}
+	switch op := n.op(); {
+	case !n.isList, op == "get", op == "tuple", cryptoPredicates[op] != "",
+		op == "member", op == "in", op == "subset?", op == "approval_ok?":
+		return s, nil
+	}
+	return "(" + s + ")", nil
+}
+
+func cedarExpr(t *Translation, n *syntaxNode) (string, error) {
+	switch {
+	case n.isString():
+		s, _ := strconv.Unquote(n.atom)
+		return quoteC(s, true), nil
+	case n.isBool():
+		return strconv.FormatBool(n.atom == "#t"), nil
+	case n.isNumber():
+		f, _ := strconv.ParseFloat(n.atom, 64)
+		if f != math.Trunc(f) || math.Abs(f) >= 1<<63 {
+			return "", fmt.Errorf("%s: Cedar has no counterpart for the number %s", n.pos, n.atom)
+		}
+		return strconv.FormatInt(int64(f), 10), nil
+	case n.isSymbol():
+		if n.atom == "req" {
+			return "context", nil
+		}
+		t.warn("the SPL variable %s is read from context.vars", n.atom)
+		return cedarAttr("context.vars", n.atom), nil
+	}
+	op := n.op()
+	if op == "" {
+		return "", fmt.Errorf("%s: list does not start with an operator", n.pos)
+	}
+	if err := checkArity(n); err != nil {
+		return "", err
+	}
+	args := n.list[1:]
+	operands := make([]string, len(args))
+	for i, a := range args {
+		s, err := cedarOperand(t, a)
+		if err != nil {
+			return "", err
+		}
+		operands[i] = s
+	}
+	switch op {
+	case "and", "or":
+		if len(args) == 0 {
+			return strconv.FormatBool(op == "and"), nil
+		}
+		sep := " && "
+		if op == "or" {
+			sep = " || "
+		}
+		return strings.Join(operands, sep), nil
+	case "not":
+		return "!" + operands[0], nil
+	case "=":
+		return operands[0] + " == " + operands[1], nil
+	case "<", "<=", ">", ">=":
+		return operands[0] + " " + op + " " + operands[1], nil
+	case "member", "in":
+		return operands[1] + ".contains(" + operands[0] + ")", nil
+	case "not-member":
+		return "!" + operands[1] + ".contains(" + operands[0] + ")", nil
+	case "subset?":
+		return operands[1] + ".containsAll(" + operands[0] + ")", nil
+	case "before":
+		for i, a := range args {
+			if a.isString() {
+				operands[i] = "datetime(" + operands[i] + ")"
+			} else {
+				t.warn("before compares %s as a Cedar datetime", operands[i])
+			}
+		}
+		return operands[0] + " < " + operands[1], nil
+	case "get":
+		if !args[1].isString() {
+			return "", fmt.Errorf("%s: Cedar can only get attributes named by a string literal", n.pos)
+		}
+		key, _ := strconv.Unquote(args[1].atom)
+		return cedarAttr(operands[0], key), nil
+	case "tuple":
+		return "[" + strings.Join(operands, ", ") + "]", nil
+	case "approval_ok?":
+		t.warn("approval_ok? reads the approvers who have approved from context.approvals")
+		return "context.approvals.contains(" + operands[0] + ")", nil
+	}
+	if name := cryptoPredicates[op]; name != "" {
+		t.warn("%s is read from context.crypto.%s", op, name)
+		return "context.crypto." + name, nil
+	}
+	return "", fmt.Errorf("%s: Cedar has no counterpart for %s", n.pos, op)
+}
+
+// FromCedar translates Cedar policies into one SPL policy, the reverse of
+// ToCedar. A request is allowed when some permit policy's conditions hold
+// and no forbid policy's do. Only the subset of Cedar ToCedar produces is
+// accepted: policies must not constrain the principal, action or resource,
+// and conditions may use context, records of it, sets, comparisons,
+// contains and containsAll, and datetime and decimal literals. Annotations
+// are ignored.
+func FromCedar(src string) (*Translation, error) {
+	toks, err := lexC(src, "//", false)
+	if err != nil {
+		return nil, err
+	}
+	p := &cedarParser{cParser{toks: toks}}
+	var permits, forbids []string
+	for p.peek().kind != 0 {
+		effect, cond, err := p.policy()
+		if err != nil {
+			return nil, err
+		}
+		if effect == "permit" {
+			permits = append(permits, cond)
+		} else {
+			forbids = append(forbids, "(not "+cond+")")
+		}
+	}
+	allow := splJoin("or", permits, "#f")
+	if len(forbids) > 0 {
+		allow = splJoin("and", append([]string{allow}, forbids...), "#t")
+	}
+	out, err := Format(allow)
+	if err != nil {
+		return nil, err
+	}
+	return &Translation{Policy: out}, nil
+}
+
+// splJoin combines SPL expressions with op, returning a lone expression as
+// is and empty for none.
+func splJoin(op string, exprs []string, empty string) string {
+	switch len(exprs) {
+	case 0:
+		return empty
+	case 1:
+		return exprs[0]
+	}
+	return "(" + op + " " + strings.Join(exprs, " ") + ")"
+}
+
+type cedarParser struct{ cParser }
+
+// A cedarTerm is a parsed Cedar expression: SPL source, or one of the parts
+// of context that ToCedar gives a meaning.
+type cedarTerm struct {
+	spl  string
+	kind int
+}
+
+const (
+	cedarValue = iota
+	cedarContext
+	cedarVars
+	cedarCrypto
+	cedarApprovals
+	cedarDatetime
+)
+
+func (p *cedarParser) policy() (effect, cond string, err error) {
+	for p.accept("@") {
+		if p.next().kind != 'i' {
+			return "", "", p.errorf("expected an annotation name")
+		}
+		if p.accept("(") {
+			if p.next().kind != 's' {
+				return "", "", p.errorf("expected an annotation value")
+			}
+			if err := p.expect(")"); err != nil {
+				return "", "", err
+			}
+		}
+	}
+	effect = p.peek().text
+	if effect != "permit" && effect != "forbid" {
+		return "", "", p.errorf("expected permit or forbid, found %s", p.peek())
+	}
+	p.next()
+	if err := p.expect("("); err != nil {
+		return "", "", err
+	}
+	for i, v := range []string{"principal", "action", "resource"} {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return "", "", err
+			}
+		}
+		if err := p.expect(v); err != nil {
+			return "", "", err
+		}
+		if !p.is(",") && !p.is(")") {
+			return "", "", p.errorf("SPL has no counterpart for a %s constraint", v)
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return "", "", err
+	}
+	var conds []string
+	for p.is("when") || p.is("unless") {
+		kw := p.next().text
+		if err := p.expect("{"); err != nil {
+			return "", "", err
+		}
+		c, err := p.value(p.or)
+		if err != nil {
+			return "", "", err
+		}
+		if kw == "unless" {
+			c = "(not " + c + ")"
+		}
+		conds = append(conds, c)
+		if err := p.expect("}"); err != nil {
+			return "", "", err
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return "", "", err
+	}
+	return effect, splJoin("and", conds, "#t"), nil
+}
+
+// value parses with parse and requires an SPL value.
+func (p *cedarParser) value(parse func() (cedarTerm, error)) (string, error) {
+	pos := p.peek().pos
+	t, err := parse()
+	if err != nil {
+		return "", err
+	}
+	return p.valueOf(t, pos)
+}
+
+func (p *cedarParser) or() (cedarTerm, error) { return p.binary("||", "or", p.and) }
+
+func (p *cedarParser) and() (cedarTerm, error) { return p.binary("&&", "and", p.relation) }
+
+func (p *cedarParser) binary(tok, op string, operand func() (cedarTerm, error)) (cedarTerm, error) {
+	first, err := p.value(operand)
+	if err != nil {
+		return cedarTerm{}, err
+	}
+	exprs := []string{first}
+	for p.accept(tok) {
+		s, err := p.value(operand)
+		if err != nil {
+			return cedarTerm{}, err
+		}
+		exprs = append(exprs, s)
+	}
+	return cedarTerm{spl: splJoin(op, exprs, "")}, nil
+}
+
+func (p *cedarParser) relation() (cedarTerm, error) {
+	a, err := p.unary()
+	if err != nil {
+		return cedarTerm{}, err
+	}
+	op := p.peek()
+	switch op.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	case "in", "has", "like", "is":
+		return cedarTerm{}, p.errorf("SPL has no counterpart for %s", op.text)
+	default:
+		return a, nil
+	}
+	p.next()
+	b, err := p.unary()
+	if err != nil {
+		return cedarTerm{}, err
+	}
+	as, err := p.valueOf(a, op.pos)
+	if err != nil {
+		return cedarTerm{}, err
+	}
+	bs, err := p.valueOf(b, op.pos)
+	if err != nil {
+		return cedarTerm{}, err
+	}
+	if a.kind == cedarDatetime || b.kind == cedarDatetime {
+		switch op.text {
+		case "<":
+			return cedarTerm{spl: "(before " + as + " " + bs + ")"}, nil
+		case ">":
+			return cedarTerm{spl: "(before " + bs + " " + as + ")"}, nil
+		case "<=":
+			return cedarTerm{spl: "(not (before " + bs + " " + as + "))"}, nil
+		case ">=":
+			return cedarTerm{spl: "(not (before " + as + " " + bs + "))"}, nil
+		}
+	}
+	switch op.text {
+	case "==":
+		return cedarTerm{spl: "(= " + as + " " + bs + ")"}, nil
+	case "!=":
+		return cedarTerm{spl: "(not (= " + as + " " + bs + "))"}, nil
+	}
+	return cedarTerm{spl: "(" + op.text + " " + as + " " + bs + ")"}, nil
+}
+
+func (p *cedarParser) valueOf(t cedarTerm, pos Position) (string, error) {
+	switch t.kind {
+	case cedarContext:
+		return "req", nil
+	case cedarValue, cedarDatetime:
+		return t.spl, nil
+	}
+	return "", fmt.Errorf("%s: expected a value", pos)
+}
+
+func (p *cedarParser) unary() (cedarTerm, error) {
+	switch {
+	case p.accept("!"):
+		s, err := p.value(p.unary)
+		if err != nil {
+			return cedarTerm{}, err
+		}
+		return cedarTerm{spl: "(not " + s + ")"}, nil
+	case p.is("-") && p.toks[p.i+1].kind == 'n':
+		p.next()
+		t, err := p.member()
+		if err != nil {
+			return cedarTerm{}, err
+		}
+		t.spl = "-" + t.spl
+		return t, nil
+	}
+	return p.member()
+}
+
+func (p *cedarParser) member() (cedarTerm, error) {
+	t, err := p.primary()
+	if err != nil {
+		return cedarTerm{}, err
+	}
+	for {
+		var name string
+		switch {
+		case p.accept("."):
+			tok := p.next()
+			if tok.kind != 'i' {
+				return cedarTerm{}, p.errorf("expected an attribute name")
+			}
+			name = tok.text
+			if p.is("(") {
+				t, err = p.method(t, name)
+				if err != nil {
+					return cedarTerm{}, err
+				}
+				continue
+			}
+		case p.accept("["):
+			tok := p.next()
+			if tok.kind != 's' {
+				return cedarTerm{}, p.errorf("expected a string attribute name")
+			}
+			name = tok.text
+			if err := p.expect("]"); err != nil {
+				return cedarTerm{}, err
+			}
+		case p.is("+"), p.is("-"), p.is("*"):
+			return cedarTerm{}, p.errorf("SPL has no arithmetic")
+		default:
+			return t, nil
+		}
+		if t, err = p.attr(t, name); err != nil {
+			return cedarTerm{}, err
+		}
+	}
+}
+
+func (p *cedarParser) attr(t cedarTerm, name string) (cedarTerm, error) {
+	switch t.kind {
+	case cedarContext:
+		switch name {
+		case "vars":
+			return cedarTerm{kind: cedarVars}, nil
+		case "crypto":
+			return cedarTerm{kind: cedarCrypto}, nil
+		case "approvals":
+			return cedarTerm{kind: cedarApprovals}, nil
+		}
+		t.spl = "req"
+	case cedarVars:
+		if !isIdent(name) {
+			return cedarTerm{}, p.errorf("%q is not an SPL variable name", name)
+		}
+		return cedarTerm{spl: name}, nil
+	case cedarCrypto:
+		for op, n := range cryptoPredicates {
+			if n == name {
+				return cedarTerm{spl: "(" + op + ")"}, nil
+			}
+		}
+		return cedarTerm{}, p.errorf("unknown crypto predicate context.crypto.%s", name)
+	case cedarApprovals:
+		return cedarTerm{}, p.errorf("expected context.approvals.contains")
+	}
+	key, err := splString(name)
+	if err != nil {
+		return cedarTerm{}, p.errorf("%v", err)
+	}
+	return cedarTerm{spl: "(get " + t.spl + " " + key + ")"}, nil
+}
+
+func (p *cedarParser) method(t cedarTerm, name string) (cedarTerm, error) {
+	pos := p.peek().pos
+	p.next()
+	arg, err := p.value(p.or)
+	if err != nil {
+		return cedarTerm{}, err
+	}
+	if err := p.expect(")"); err != nil {
+		return cedarTerm{}, err
+	}
+	if t.kind == cedarApprovals && name == "contains" {
+		return cedarTerm{spl: "(approval_ok? " + arg + ")"}, nil
+	}
+	set, err := p.valueOf(t, pos)
+	if err != nil {
+		return cedarTerm{}, err
+	}
+	switch name {
+	case "contains":
+		return cedarTerm{spl: "(member " + arg + " " + set + ")"}, nil
+	case "containsAll":
+		return cedarTerm{spl: "(subset? " + arg + " " + set + ")"}, nil
+	}
+	return cedarTerm{}, fmt.Errorf("%s: SPL has no counterpart for %s", pos, name)
+}
+
+func (p *cedarParser) primary() (cedarTerm, error) {
+	tok := p.next()
+	switch tok.kind {
+	case 'n':
+		if _, err := strconv.ParseFloat(tok.text, 64); err != nil {
+			return cedarTerm{}, fmt.Errorf("%s: invalid number %s", tok.pos, tok.text)
+		}
+		return cedarTerm{spl: tok.text}, nil
+	case 's':
+		s, err := splString(tok.text)
+		if err != nil {
+			return cedarTerm{}, fmt.Errorf("%s: %w", tok.pos, err)
+		}
+		return cedarTerm{spl: s}, nil
+	case 'i':
+		switch tok.text {
+		case "true":
+			return cedarTerm{spl: "#t"}, nil
+		case "false":
+			return cedarTerm{spl: "#f"}, nil
+		case "context":
+			return cedarTerm{kind: cedarContext}, nil
+		case "datetime", "decimal":
+			if err := p.expect("("); err != nil {
+				return cedarTerm{}, err
+			}
+			lit := p.next()
+			if lit.kind != 's' {
+				return cedarTerm{}, fmt.Errorf("%s: %s takes a string literal", tok.pos, tok.text)
+			}
+			if err := p.expect(")"); err != nil {
+				return cedarTerm{}, err
+			}
+			if tok.text == "decimal" {
+				f, err := strconv.ParseFloat(lit.text, 64)
+				if err != nil {
+					return cedarTerm{}, fmt.Errorf("%s: invalid decimal %q", lit.pos, lit.text)
+				}
+				return cedarTerm{spl: splNumber(f)}, nil
+			}
+			s, err := splString(lit.text)
+			if err != nil {
+				return cedarTerm{}, fmt.Errorf("%s: %w", lit.pos, err)
+			}
+			return cedarTerm{spl: s, kind: cedarDatetime}, nil
+		}
+		return cedarTerm{}, fmt.Errorf("%s: SPL has no counterpart for %s", tok.pos, tok.text)
+	case 'p':
+		switch tok.text {
+		case "(":
+			s, err := p.value(p.or)
+			if err != nil {
+				return cedarTerm{}, err
+			}
+			return cedarTerm{spl: s}, p.expect(")")
+		case "[":
+			var items []string
+			for !p.accept("]") {
+				if len(items) > 0 {
+					if err := p.expect(","); err != nil {
+						return cedarTerm{}, err
+					}
+				}
+				s, err := p.value(p.or)
+				if err != nil {
+					return cedarTerm{}, err
+				}
+				items = append(items, s)
+			}
+			return cedarTerm{spl: "(tuple" + strings.TrimRight(" "+strings.Join(items, " "), " ") + ")"}, nil
+		}
+	}
+	return cedarTerm{}, fmt.Errorf("%s: unexpected %s", tok.pos, tok)
+}
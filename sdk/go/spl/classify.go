@@ -0,0 +1,133 @@
+package spl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Sensitivity is how a classified request field is written to decision
+// logs, traces and receipts.
+type Sensitivity uint8
+
+const (
+	// Redact replaces the value with "[REDACTED]".
+	Redact Sensitivity = iota + 1
+	// Hash replaces the value with a digest, so records can still be
+	// matched on it without revealing it.
+	Hash
+)
+
+// Redacted is what a redacted value is replaced with.
+const Redacted = "[REDACTED]"
+
+// FieldClassifier marks request fields as sensitive, so that what is
+// recorded about a verification keeps them out: Explain traces, the
+// requests events report, and toolguard receipts. Policies still see every
+// field as it is. A nil classifier marks nothing.
+type FieldClassifier struct {
+	// Fields maps a request field, named by its dotted path such as
+	// "recipient" or "card.number", to its sensitivity. A path naming an
+	// object covers everything in it.
+	Fields map[string]Sensitivity
+	// HashKey keys the HMAC-SHA256 behind Hash. Without it values are
+	// hashed with SHA-256 alone, which a dictionary reverses for guessable
+	// values such as email addresses.
+	HashKey []byte
+}
+
+// Redact returns req with its sensitive fields redacted or hashed. Objects
+// on the way to a sensitive field are copied; req itself is not changed.
+func (c *FieldClassifier) Redact(req map[string]any) map[string]any {
+	if c == nil || len(c.Fields) == 0 || req == nil {
+		return req
+	}
+	out, _ := c.redact(nil, req).(map[string]any)
+	return out
+}
+
+// redact returns v, the value at path, with the sensitive fields at or
+// below path redacted.
+func (c *FieldClassifier) redact(path []string, v any) any {
+	if s, ok := c.sensitivity(path); ok {
+		return c.apply(s, v)
+	}
+	m, ok := v.(map[string]any)
+	if !ok || !c.below(path) {
+		return v
+	}
+	out := make(map[string]any, len(m))
+	for k, x := range m {
+		out[k] = c.redact(append(path[:len(path):len(path)], k), x)
+	}
+	return out
+}
+
+// sensitivity returns the sensitivity of the field at path, or of the
+// nearest object containing it that is classified.
+func (c *FieldClassifier) sensitivity(path []string) (Sensitivity, bool) {
+	for i := 1; i <= len(path); i++ {
+		if s, ok := c.Fields[strings.Join(path[:i], ".")]; ok {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// below reports whether a classified field lies strictly inside path.
+func (c *FieldClassifier) below(path []string) bool {
+	prefix := strings.Join(path, ".")
+	if prefix != "" {
+		prefix += "."
+	}
+	for f := range c.Fields {
+		if strings.HasPrefix(f, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply replaces v as s requires.
+func (c *FieldClassifier) apply(s Sensitivity, v any) any {
+	if s != Hash {
+		return Redacted
+	}
+	b, err := CanonicalJSON(v)
+	if err != nil {
+		return Redacted
+	}
+	if len(c.HashKey) == 0 {
+		h := sha256.Sum256(b)
+		return "sha256:" + hex.EncodeToString(h[:])
+	}
+	m := hmac.New(sha256.New, c.HashKey)
+	m.Write(b)
+	return "hmac-sha256:" + hex.EncodeToString(m.Sum(nil))
+}
+
+// requestPath returns the request field a get reads, such as
+// ["card", "number"] for (get (get req "card") "number"), or false if n
+// is not a get of req with string keys.
+func requestPath(n Node) ([]string, bool) {
+	l, ok := n.(List)
+	if !ok || len(l) < 3 {
+		return nil, false
+	}
+	if op, _, _ := l.Head(); op != OpGet {
+		return nil, false
+	}
+	key, ok := l[2].(Str)
+	if !ok {
+		return nil, false
+	}
+	if isSymbol(l[1], "req") {
+		return []string{string(key)}, true
+	}
+	parent, ok := requestPath(l[1])
+	if !ok {
+		return nil, false
+	}
+	return append(parent, string(key)), true
+}
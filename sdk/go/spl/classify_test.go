@@ -0,0 +1,58 @@
+package spl
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFieldClassifierRedact(t *testing.T) {
+	c := &FieldClassifier{Fields: map[string]Sensitivity{"email": Hash, "card.number": Redact, "address": Redact}}
+	req := map[string]any{
+		"email":   "ann@example.com",
+		"card":    map[string]any{"number": "4111", "brand": "visa"},
+		"address": map[string]any{"city": "Oslo"},
+		"amount":  12.0,
+	}
+	out := c.Redact(req)
+	card := out["card"].(map[string]any)
+	if card["number"] != Redacted || card["brand"] != "visa" || out["address"] != Redacted || out["amount"] != 12.0 {
+		t.Fatalf("unexpected redaction %+v", out)
+	}
+	if h := out["email"].(string); !strings.HasPrefix(h, "sha256:") || h != c.Redact(req)["email"] {
+		t.Fatalf("expected a stable SHA-256, got %q", h)
+	}
+	if req["card"].(map[string]any)["number"] != "4111" || req["email"] != "ann@example.com" {
+		t.Fatal("expected req to be left unchanged")
+	}
+
+	keyed := &FieldClassifier{Fields: c.Fields, HashKey: []byte("k")}
+	if h := keyed.Redact(req)["email"].(string); !strings.HasPrefix(h, "hmac-sha256:") {
+		t.Fatalf("expected an HMAC with HashKey, got %q", h)
+	}
+	var none *FieldClassifier
+	if none.Redact(req)["email"] != "ann@example.com" {
+		t.Fatal("expected a nil classifier to redact nothing")
+	}
+}
+
+func TestExplainRedacts(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(and (= (get (get req "card") "brand") "visa") (member (get req "email") (tuple (get req "email") "x")))`, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := map[string]any{"email": "ann@example.com", "card": map[string]any{"number": "4111", "brand": "visa"}}
+	c := &FieldClassifier{Fields: map[string]Sensitivity{"email": Redact, "card.number": Redact}}
+	res := VerifyTokenObj(tok, req, VerifyTokenOptions{Explain: true, Classifier: c})
+	if !res.Allow || res.Trace == nil {
+		t.Fatalf("expected an explained allow, got %+v", res)
+	}
+	b, err := json.Marshal(res.Trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); strings.Contains(s, "ann@example.com") || strings.Contains(s, "4111") || !strings.Contains(s, `"value":"visa"`) {
+		t.Fatalf("expected sensitive values redacted from the trace, got %s", s)
+	}
+}
@@ -0,0 +1,165 @@
+package spl
+
+import (
+	"math"
+	"time"
+)
+
+// counterKey names one counter: an action in a window, by the op that
+// counts it, per-day-count or window-count, or a budget: an action's spend
+// in a period and currency, by sum-amount.
+type counterKey struct{ op, action, window, currency string }
+
+const unlimited = int(^uint(0) >> 1)
+
+// counterUse collects, over one evaluation, the counters a policy read and
+// the tightest limit any comparison found each to be under, and the buckets
+// it found a token in, so that VerifyTokenOptions.Counters and Rates can
+// take a unit of every one of them, each checked against its limit, once
+// the policy allows.
+type counterUse struct {
+	limits  map[counterKey]int
+	order   []counterKey
+	last    counterKey // the counter read most recently
+	buckets []bucketKey
+	// spends are the limits of the budgets sum-amount read, which pending
+	// is recorded against in the ledger under the token's signature.
+	spends  map[counterKey]SpendLimit
+	pending Money
+	ledger  LedgerStore
+	token   string
+}
+
+func (u *counterUse) read(op, action, window string) {
+	u.readKey(counterKey{op: op, action: action, window: window})
+}
+
+func (u *counterUse) readSpend(action, period, currency string, pending Money) {
+	k := counterKey{op: "sum-amount", action: action, window: period, currency: currency}
+	if u.spends == nil {
+		u.spends = map[counterKey]SpendLimit{}
+	}
+	if _, ok := u.spends[k]; !ok {
+		u.spends[k] = SpendLimit{Max: math.Inf(1), Currency: currency}
+	}
+	u.pending = pending
+	u.readKey(k)
+}
+
+func (u *counterUse) readKey(k counterKey) {
+	if u.limits == nil {
+		u.limits = map[counterKey]int{}
+	}
+	if _, ok := u.limits[k]; !ok {
+		u.limits[k] = unlimited
+		u.order = append(u.order, k)
+	}
+	u.last = k
+}
+
+// flipCmp turns "count OP bound" into "bound OP' count".
+var flipCmp = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<="}
+
+// negateCmp is the comparison that holds when OP does not.
+var negateCmp = map[string]string{"<": ">=", "<=": ">", ">": "<=", ">=": "<"}
+
+// limit records that the evaluation found "count OP bound" to be res for
+// counter k. Counts only rise, so only an upper bound can stop holding
+// before the count is taken; it becomes k's limit. This is conservative:
+// a comparison in an or the policy did not need still limits k.
+func (u *counterUse) limit(k counterKey, op string, bound float64, res bool) {
+	if _, ok := u.limits[k]; !ok {
+		return
+	}
+	if !res {
+		op = negateCmp[op]
+	}
+	if k.op == "sum-amount" {
+		if op != "<" && op != "<=" {
+			return
+		}
+		l := SpendLimit{Max: bound, Strict: op == "<", Currency: k.currency}
+		if cur := u.spends[k]; l.Max < cur.Max || (l.Max == cur.Max && l.Strict) {
+			u.spends[k] = l
+		}
+		return
+	}
+	var n float64
+	switch op {
+	case "<":
+		n = math.Ceil(bound)
+	case "<=":
+		n = math.Floor(bound) + 1
+	default:
+		return
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n < float64(u.limits[k]) {
+		u.limits[k] = int(n)
+	}
+}
+
+func (u *counterUse) bucket(action string, b Bucket) {
+	for _, k := range u.buckets {
+		if k == (bucketKey{action, b}) {
+			return
+		}
+	}
+	u.buckets = append(u.buckets, bucketKey{action, b})
+}
+
+// take counts a use of every counter read, in the order read, each only if
+// it is still below its limit, records the request's spend against every
+// budget read, each only if it stays within its limit, then takes a token
+// from every bucket found to hold one. It reports false when a counter is
+// at its limit, a budget would go over, or a bucket is empty: a concurrent
+// request took the unit this evaluation relied on. Units taken before that
+// one stay taken.
+func (u *counterUse) take(counters CounterStore, rates RateStore, now time.Time) (bool, error) {
+	for _, k := range u.order {
+		var ok bool
+		var err error
+		switch {
+		case k.op == "sum-amount":
+			if u.pending.Amount == 0 {
+				continue
+			}
+			period, _ := LedgerPeriod(k.window, now) // checked when read
+			ok, err = u.ledger.Spend(LedgerKey{u.token, k.action, period}, u.pending, u.spends[k])
+		case k.op == "window-count":
+			window, _ := ParseWindow(k.window) // parsed when read
+			ok, err = rates.WindowTake(k.action, window, u.limits[k], now)
+		case counters == nil:
+			// Counts from PerDayCount are the host's to keep.
+			continue
+		default:
+			ok, err = counters.CheckAndIncrement(k.action, k.window, u.limits[k])
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	for _, k := range u.buckets {
+		if ok, err := rates.BucketTake(k.action, k.Bucket, now); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// isCounter reports whether n reads a counter.
+func isCounter(n Node) bool {
+	return isCall(n, OpPerDayCount) || isCall(n, OpWindowCount) || isCall(n, OpSumAmount)
+}
+
+// isCall reports whether n is a call of op.
+func isCall(n Node, op Op) bool {
+	l, ok := n.(List)
+	if !ok {
+		return false
+	}
+	code, _, ok := l.Head()
+	return ok && code == op
+}
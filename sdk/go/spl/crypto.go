@@ -4,18 +4,52 @@ import (
 	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 )
 
+// decodeHex strictly decodes a hex token field. It rejects empty input,
+// odd lengths, whitespace, 0x prefixes and any non-hex character, and when
+// size is positive, any value that does not decode to exactly size bytes.
+func decodeHex(s string, size int) ([]byte, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty hex value")
+	}
+	if len(s)%2 != 0 {
+		return nil, fmt.Errorf("odd-length hex value")
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return nil, fmt.Errorf("invalid hex character %q at offset %d", c, i)
+		}
+	}
+	if size > 0 && len(s) != 2*size {
+		return nil, fmt.Errorf("hex value must be %d bytes, got %d", size, len(s)/2)
+	}
+	return hex.DecodeString(s)
+}
+
+// equalHex reports in constant time whether got equals the strictly decoded
+// wantHex. Hex case is not significant.
+func equalHex(got []byte, wantHex string) bool {
+	want, err := decodeHex(wantHex, len(got))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
 // VerifyEd25519 checks an Ed25519 signature over a message.
 func VerifyEd25519(message []byte, signatureHex, publicKeyHex string) bool {
-	sig, err := hex.DecodeString(signatureHex)
-	if err != nil || len(sig) != ed25519.SignatureSize {
+	sig, err := decodeHex(signatureHex, ed25519.SignatureSize)
+	if err != nil {
 		return false
 	}
-	pub, err := hex.DecodeString(publicKeyHex)
-	if err != nil || len(pub) != ed25519.PublicKeySize {
+	pub, err := decodeHex(publicKeyHex, ed25519.PublicKeySize)
+	if err != nil {
 		return false
 	}
 	return ed25519.Verify(ed25519.PublicKey(pub), message, sig)
@@ -38,22 +72,25 @@ func VerifyMerkleProof(leafData string, proof []MerkleProofStep, rootHex string)
 	current := SHA256Hash([]byte(leafData))
 
 	for _, step := range proof {
-		sibling, err := hex.DecodeString(step.Hash)
+		sibling, err := decodeHex(step.Hash, sha256.Size)
 		if err != nil {
 			return false
 		}
 		h := sha256.New()
-		if step.Position == "right" {
+		switch step.Position {
+		case "right":
 			h.Write(current)
 			h.Write(sibling)
-		} else {
+		case "left":
 			h.Write(sibling)
 			h.Write(current)
+		default:
+			return false
 		}
 		current = h.Sum(nil)
 	}
 
-	return hex.EncodeToString(current) == rootHex
+	return equalHex(current, rootHex)
 }
 
 // HashTuple hashes a slice of values by JSON-serializing then SHA-256.
@@ -94,7 +131,7 @@ func hkdfSHA256(ikm, salt, info []byte, length int) []byte {
 // DeriveServiceKey derives a service-specific Ed25519 keypair using HKDF-SHA256.
 // Provides unlinkability: different services see different public keys.
 func DeriveServiceKey(masterKeyHex, serviceDomain string) (publicKeyHex, privateKeyHex string, err error) {
-	masterKey, err := hex.DecodeString(masterKeyHex)
+	masterKey, err := decodeHex(masterKeyHex, 0)
 	if err != nil {
 		return "", "", err
 	}
@@ -108,9 +145,13 @@ func DeriveServiceKey(masterKeyHex, serviceDomain string) (publicKeyHex, private
 }
 
 // VerifyHashChain checks that hashing preimageHex (chainLength - index) times
-// produces the commitment.
+// produces the commitment. An index outside [0, chainLength] is rejected
+// rather than treated as zero steps.
 func VerifyHashChain(commitment, preimageHex string, index, chainLength int) bool {
-	current, err := hex.DecodeString(preimageHex)
+	if index < 0 || index > chainLength {
+		return false
+	}
+	current, err := decodeHex(preimageHex, 0)
 	if err != nil {
 		return false
 	}
@@ -119,5 +160,5 @@ func VerifyHashChain(commitment, preimageHex string, index, chainLength int) boo
 		h := sha256.Sum256(current)
 		current = h[:]
 	}
-	return hex.EncodeToString(current) == commitment
+	return equalHex(current, commitment)
 }
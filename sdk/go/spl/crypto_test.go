@@ -97,3 +97,53 @@ func TestHashChainValid(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeHexStrict(t *testing.T) {
+	for _, bad := range []string{"", "abc", " ab", "ab\n", "0xab", "zz", "ab cd"} {
+		if _, err := decodeHex(bad, 0); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+	if _, err := decodeHex("abcd", 3); err == nil {
+		t.Fatal("expected wrong length to be rejected")
+	}
+	if b, err := decodeHex("ABcd", 2); err != nil || hex.EncodeToString(b) != "abcd" {
+		t.Fatalf("expected mixed-case hex to decode, got %x, %v", b, err)
+	}
+}
+
+func TestHashChainRejectsOutOfRangeIndex(t *testing.T) {
+	commitment := hex.EncodeToString(SHA256Hash([]byte("seed")))
+	// Past the end of the chain the commitment itself must not verify.
+	if VerifyHashChain(commitment, commitment, 6, 5) {
+		t.Fatal("expected index beyond chain length to be rejected")
+	}
+	if VerifyHashChain(commitment, commitment, -1, 5) {
+		t.Fatal("expected negative index to be rejected")
+	}
+	if VerifyHashChain(commitment, " "+commitment, 5, 5) {
+		t.Fatal("expected whitespace in preimage to be rejected")
+	}
+}
+
+func TestMerkleProofRejectsUnknownPosition(t *testing.T) {
+	leaf := "leaf"
+	sibling := sha256.Sum256([]byte("sibling"))
+	h := sha256.New()
+	h.Write(sibling[:])
+	h.Write(SHA256Hash([]byte(leaf)))
+	root := hex.EncodeToString(h.Sum(nil))
+
+	step := MerkleProofStep{Hash: hex.EncodeToString(sibling[:]), Position: "left"}
+	if !VerifyMerkleProof(leaf, []MerkleProofStep{step}, root) {
+		t.Fatal("expected valid proof")
+	}
+	step.Position = "up"
+	if VerifyMerkleProof(leaf, []MerkleProofStep{step}, root) {
+		t.Fatal("expected unknown position to be rejected")
+	}
+	step.Position = "left"
+	if VerifyMerkleProof(leaf, []MerkleProofStep{step}, root+"00") {
+		t.Fatal("expected oversized root to be rejected")
+	}
+}
@@ -0,0 +1,146 @@
+package spl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Outcome is how a verification ended.
+type Outcome string
+
+const (
+	OutcomeAllow Outcome = "allow"
+	OutcomeDeny  Outcome = "deny"  // the policy denied the request
+	OutcomeError Outcome = "error" // verification failed
+)
+
+// Decision is the machine-readable record of one verification: what was
+// decided, for which token, policy and request, and why. It identifies the
+// token and request by digest rather than carrying them, so it can be kept
+// in audit logs, attached to receipts and sent to webhooks as is.
+type Decision struct {
+	// TokenID is the hex SHA-256 identifying the token; see TokenID.
+	TokenID string `json:"token_id,omitempty"`
+	// PolicyDigest is the hex SHA-256 of the source of the token's policy
+	// for the request, as PolicyFor selects it; see PolicyDigest.
+	PolicyDigest string `json:"policy_digest,omitempty"`
+	// RequestDigest is the hex SHA-256 of the request's canonical JSON;
+	// see RequestDigest. It is empty if the request has no JSON encoding.
+	RequestDigest string  `json:"request_digest,omitempty"`
+	Outcome       Outcome `json:"outcome"`
+	// Reason is CodeNone when allowed, CodeDenied when the policy denied,
+	// and the error's code when verification failed.
+	Reason ErrorCode `json:"reason,omitempty"`
+	// FailingPath locates the clause that denied or failed: the index,
+	// within each enclosing and from the root down, of the conjunct that
+	// decided. It is empty when the root itself decided.
+	FailingPath []int `json:"failing_path,omitempty"`
+	// FailingClause is the source of that clause, when the policy was
+	// evaluated and did not allow.
+	FailingClause string    `json:"failing_clause,omitempty"`
+	EvaluatedAt   time.Time `json:"evaluated_at"`
+	GasUsed       int       `json:"gas_used"`
+
+	// Result is the verification result the decision records.
+	Result VerifyTokenResult `json:"-"`
+}
+
+// TokenID identifies a token by the hex SHA-256 of its TransparencyLeaf,
+// which covers the signed payload, the issuer key and the signature.
+func TokenID(t *Token) string {
+	return hex.EncodeToString(TransparencyLeaf(t))
+}
+
+// PolicyDigest returns the hex SHA-256 of policy source, the digest
+// PolicyCache keys parsed policies by.
+func PolicyDigest(src string) string {
+	h := sha256.Sum256([]byte(src))
+	return hex.EncodeToString(h[:])
+}
+
+// RequestDigest returns the hex SHA-256 of req's canonical JSON.
+func RequestDigest(req map[string]any) (string, error) {
+	b, err := CanonicalJSON(req)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// CanonicalJSON encodes v with object keys sorted, no insignificant space
+// and no HTML escaping, so equal values encode to equal bytes. For the
+// strings, numbers and nested objects of a request it matches RFC 8785.
+func CanonicalJSON(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// newDecision records res, the result of verifying t against req. d holds
+// what verification filled in: the evaluation time and failing clause.
+func newDecision(t *Token, req map[string]any, res VerifyTokenResult, d *Decision) Decision {
+	out := *d
+	out.Result, out.GasUsed = res, res.GasUsed
+	if t != nil {
+		out.TokenID, out.PolicyDigest = TokenID(t), PolicyDigest(t.PolicyFor(req))
+	}
+	out.RequestDigest, _ = RequestDigest(req)
+	switch {
+	case res.Allow:
+		out.Outcome = OutcomeAllow
+		out.FailingPath, out.FailingClause = nil, ""
+	case res.Err != nil:
+		out.Outcome, out.Reason = OutcomeError, res.Code
+	default:
+		out.Outcome, out.Reason = OutcomeDeny, CodeDenied
+	}
+	if out.EvaluatedAt.IsZero() {
+		out.EvaluatedAt = time.Now()
+	}
+	return out
+}
+
+// failPath collects Decision.FailingPath as a deny or error unwinds
+// through the ands above it, innermost first.
+type failPath struct {
+	path       []int
+	gas, depth int // the gas left and depth when path was last extended
+}
+
+// note records that conjunct i of an and at depth decided, with gas left.
+// The path so far is kept only if it was noted by that conjunct itself:
+// one level deeper, with no gas spent since. Otherwise it is stale, from a
+// clause whose result did not decide.
+func (f *failPath) note(i, gas, depth int) {
+	if f == nil {
+		return
+	}
+	if f.gas != gas || f.depth != depth+1 {
+		f.path = f.path[:0]
+	}
+	f.path, f.gas, f.depth = append(f.path, i), gas, depth
+}
+
+// clause sets d's failing path and clause from f, for ast.
+func (f *failPath) clause(ast Node, d *Decision) {
+	d.FailingPath = slices.Clone(f.path)
+	slices.Reverse(d.FailingPath)
+	n := ast
+	for _, i := range d.FailingPath {
+		l, ok := n.(List)
+		if !ok || i >= len(l) {
+			return
+		}
+		n = l[i]
+	}
+	d.FailingClause = render(n)
+}
@@ -0,0 +1,82 @@
+package spl
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVerifierDecide(t *testing.T) {
+	_, priv := GenerateKeypair()
+	policy := `(and (= (get req "action") "payments.create") (or (= (get req "to") "bob") (and (= (get req "to") "carol") (<= (get req "amount") 10))))`
+	tok, err := Mint(policy, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	js := mustJSON(t, tok)
+	var recorded []Decision
+	v := NewVerifier(VerifierConfig{
+		VerifyTokenOptions: VerifyTokenOptions{Now: "2026-01-01T00:00:00Z"},
+		OnRecord:           func(_ context.Context, d Decision) { recorded = append(recorded, d) },
+	})
+	ctx := context.Background()
+	req := map[string]any{"action": "payments.create", "to": "carol", "amount": 50.0}
+
+	d := v.Decide(ctx, js, req)
+	if d.Outcome != OutcomeDeny || d.Reason != CodeDenied || d.Result.Allow {
+		t.Fatalf("expected a deny, got %+v", d)
+	}
+	if d.TokenID != TokenID(tok) || d.PolicyDigest != PolicyDigest(policy) || d.GasUsed == 0 {
+		t.Fatalf("expected token, policy digests and gas, got %+v", d)
+	}
+	if want, _ := RequestDigest(req); d.RequestDigest != want || len(want) != 64 {
+		t.Fatalf("expected request digest %s, got %s", want, d.RequestDigest)
+	}
+	// The or in conjunct 2 decided; its own failing and is not reported.
+	if !reflect.DeepEqual(d.FailingPath, []int{2}) || d.FailingClause[:4] != "(or " {
+		t.Fatalf("expected the or clause at [2], got %v %q", d.FailingPath, d.FailingClause)
+	}
+	if !d.EvaluatedAt.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the verification time, got %v", d.EvaluatedAt)
+	}
+
+	req["action"] = "payments.refund"
+	if d := v.Decide(ctx, js, req); !reflect.DeepEqual(d.FailingPath, []int{1}) || d.FailingClause != `(= (get req "action") "payments.create")` {
+		t.Fatalf("expected conjunct 1 to fail, got %v %q", d.FailingPath, d.FailingClause)
+	}
+	req["action"], req["to"] = "payments.create", "bob"
+	if d := v.Decide(ctx, js, req); d.Outcome != OutcomeAllow || d.Reason != CodeNone || d.FailingPath != nil {
+		t.Fatalf("expected an allow, got %+v", d)
+	}
+	if d := v.Decide(ctx, "not a token", req); d.Outcome != OutcomeError || d.Reason != CodeMalformedToken || d.TokenID != "" {
+		t.Fatalf("expected a malformed token error, got %+v", d)
+	}
+	v.Verify(ctx, js, req)
+	if len(recorded) != 5 {
+		t.Fatalf("expected OnRecord for every verification, got %d", len(recorded))
+	}
+
+	b, err := json.Marshal(recorded[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["outcome"] != "deny" || m["reason"] != "denied" || m["failing_path"] == nil || m["Result"] != nil {
+		t.Fatalf("unexpected decision JSON %s", b)
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	b, err := CanonicalJSON(map[string]any{"b": 1.5, "a": "<&>", "c": map[string]any{"z": true, "y": nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"a":"<&>","b":1.5,"c":{"y":null,"z":true}}`; string(b) != want {
+		t.Fatalf("expected %s, got %s", want, b)
+	}
+}
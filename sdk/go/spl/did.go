@@ -0,0 +1,195 @@
+package spl
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Multicodec prefixes (unsigned varint) for did:key public keys.
+var (
+	multicodecEd25519 = []byte{0xed, 0x01}
+	multicodecP256    = []byte{0x80, 0x24}
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// PublicKeyToDIDKey expresses a hex public key as a did:key identifier.
+// P-256 keys are encoded in compressed form as the did:key method requires.
+func PublicKeyToDIDKey(alg, publicKeyHex string) (string, error) {
+	pub, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return "", fmt.Errorf("invalid public key hex: %w", err)
+	}
+	var body []byte
+	switch alg {
+	case "", AlgEd25519:
+		if len(pub) != 32 {
+			return "", fmt.Errorf("public key must be 32 bytes, got %d", len(pub))
+		}
+		body = append(append([]byte{}, multicodecEd25519...), pub...)
+	case AlgES256:
+		k, err := p256PublicKey(pub)
+		if err != nil {
+			return "", err
+		}
+		body = append(append([]byte{}, multicodecP256...), elliptic.MarshalCompressed(elliptic.P256(), k.X, k.Y)...)
+	default:
+		return "", fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+	return "did:key:z" + base58Encode(body), nil
+}
+
+// DIDKeyToPublicKey decodes a did:key identifier into the hex public key
+// format used by tokens.
+func DIDKeyToPublicKey(did string) (alg, publicKeyHex string, err error) {
+	mb, ok := strings.CutPrefix(did, "did:key:")
+	if !ok {
+		return "", "", fmt.Errorf("not a did:key identifier: %q", did)
+	}
+	// A DID URL fragment (did:key:z...#z...) names a key in the same document.
+	mb, _, _ = strings.Cut(mb, "#")
+	if !strings.HasPrefix(mb, "z") {
+		return "", "", fmt.Errorf("did:key must use base58btc multibase")
+	}
+	body, err := base58Decode(mb[1:])
+	if err != nil {
+		return "", "", err
+	}
+	switch {
+	case hasPrefix(body, multicodecEd25519) && len(body) == 2+32:
+		return AlgEd25519, hex.EncodeToString(body[2:]), nil
+	case hasPrefix(body, multicodecP256) && len(body) == 2+33:
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), body[2:])
+		if x == nil {
+			return "", "", fmt.Errorf("invalid P-256 point in did:key")
+		}
+		point := make([]byte, 1+2*p256ScalarSize)
+		point[0] = 4
+		x.FillBytes(point[1 : 1+p256ScalarSize])
+		y.FillBytes(point[1+p256ScalarSize:])
+		return AlgES256, hex.EncodeToString(point), nil
+	default:
+		return "", "", fmt.Errorf("unsupported did:key multicodec")
+	}
+}
+
+func hasPrefix(b, prefix []byte) bool {
+	return len(b) >= len(prefix) && string(b[:len(prefix)]) == string(prefix)
+}
+
+// DIDDocument is the subset of a DID document needed to find a key.
+type DIDDocument struct {
+	Context            []string             `json:"@context"`
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod"`
+	Authentication     []string             `json:"authentication,omitempty"`
+	AssertionMethod    []string             `json:"assertionMethod,omitempty"`
+}
+
+// VerificationMethod is a DID document key entry in Multikey form.
+type VerificationMethod struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller"`
+	PublicKeyMultibase string `json:"publicKeyMultibase"`
+}
+
+// DIDResolver resolves a DID to its document. Implementations may consult
+// networks or registries (did:web, did:ion, ...).
+type DIDResolver interface {
+	Resolve(did string) (*DIDDocument, error)
+}
+
+// DIDKeyResolver resolves did:key identifiers locally, without I/O.
+type DIDKeyResolver struct{}
+
+// Resolve builds the DID document implied by a did:key identifier.
+func (DIDKeyResolver) Resolve(did string) (*DIDDocument, error) {
+	did, _, _ = strings.Cut(did, "#")
+	if _, _, err := DIDKeyToPublicKey(did); err != nil {
+		return nil, err
+	}
+	mb := strings.TrimPrefix(did, "did:key:")
+	vmID := did + "#" + mb
+	return &DIDDocument{
+		Context: []string{"https://www.w3.org/ns/did/v1", "https://w3id.org/security/multikey/v1"},
+		ID:      did,
+		VerificationMethod: []VerificationMethod{{
+			ID:                 vmID,
+			Type:               "Multikey",
+			Controller:         did,
+			PublicKeyMultibase: mb,
+		}},
+		Authentication:  []string{vmID},
+		AssertionMethod: []string{vmID},
+	}, nil
+}
+
+// resolveKeyRef turns a token key field into (alg, hex public key). Plain hex
+// is returned unchanged; DIDs go through resolver, falling back to local
+// did:key resolution. A DID URL fragment selects a verification method,
+// otherwise the first one is used.
+func resolveKeyRef(ref string, resolver DIDResolver) (string, string, error) {
+	if !strings.HasPrefix(ref, "did:") {
+		return "", ref, nil
+	}
+	if resolver == nil {
+		resolver = DIDKeyResolver{}
+	}
+	doc, err := resolver.Resolve(ref)
+	if err != nil {
+		return "", "", fmt.Errorf("resolve %s: %w", ref, err)
+	}
+	for _, vm := range doc.VerificationMethod {
+		if strings.Contains(ref, "#") && vm.ID != ref {
+			continue
+		}
+		return DIDKeyToPublicKey("did:key:" + vm.PublicKeyMultibase)
+	}
+	return "", "", fmt.Errorf("resolve %s: no matching verification method", ref)
+}
+
+func base58Encode(b []byte) string {
+	x := new(big.Int).SetBytes(b)
+	base := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for x.Sign() > 0 {
+		x.DivMod(x, base, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func base58Decode(s string) ([]byte, error) {
+	x := new(big.Int)
+	base := big.NewInt(58)
+	for _, c := range s {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		x.Mul(x, base)
+		x.Add(x, big.NewInt(int64(i)))
+	}
+	out := x.Bytes()
+	for _, c := range s {
+		if c != rune(base58Alphabet[0]) {
+			break
+		}
+		out = append([]byte{0}, out...)
+	}
+	return out, nil
+}
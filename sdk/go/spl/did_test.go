@@ -0,0 +1,104 @@
+package spl
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDIDKeyEd25519Vector(t *testing.T) {
+	// did:key spec example (RFC 8037 A.1 public key)
+	pub := "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+	did, err := PublicKeyToDIDKey(AlgEd25519, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if did != "did:key:z6MktwupdmLXVVqTzCw4i46r4uGyosGXRnR3XjN4Zq7oMMsw" {
+		t.Fatalf("unexpected did %s", did)
+	}
+	alg, got, err := DIDKeyToPublicKey(did)
+	if err != nil || alg != AlgEd25519 || got != pub {
+		t.Fatalf("round trip failed: %v", err)
+	}
+}
+
+func TestDIDKeyP256RoundTrip(t *testing.T) {
+	pub, _, _ := GenerateKeypairAlg(AlgES256)
+	did, err := PublicKeyToDIDKey(AlgES256, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if did[:10] != "did:key:zD" {
+		t.Fatalf("expected P-256 did:key to start with zDn, got %s", did)
+	}
+	alg, got, err := DIDKeyToPublicKey(did)
+	if err != nil || alg != AlgES256 || got != pub {
+		t.Fatalf("round trip failed: %v", err)
+	}
+}
+
+func TestVerifyTokenWithDIDKeys(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	agentPub, agentPriv := GenerateKeypair()
+	agentDID, _ := PublicKeyToDIDKey(AlgEd25519, agentPub)
+
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{PoPKey: agentDID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok.PublicKey, _ = PublicKeyToDIDKey(AlgEd25519, pub)
+
+	sig, _ := CreatePresentationSignature(tok, agentPriv)
+	r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{PresentationSignature: sig})
+	if !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+
+	_, otherPriv := GenerateKeypair()
+	badSig, _ := CreatePresentationSignature(tok, otherPriv)
+	if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{PresentationSignature: badSig}); r.Allow {
+		t.Fatal("expected deny for presentation by wrong agent key")
+	}
+}
+
+type mapResolver map[string]*DIDDocument
+
+func (m mapResolver) Resolve(did string) (*DIDDocument, error) {
+	if doc, ok := m[did]; ok {
+		return doc, nil
+	}
+	return nil, fmt.Errorf("not found")
+}
+
+func TestVerifyTokenCustomDIDResolver(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	keyDID, _ := PublicKeyToDIDKey(AlgEd25519, pub)
+	doc, _ := DIDKeyResolver{}.Resolve(keyDID)
+	doc.ID = "did:web:issuer.example.com"
+
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{})
+	tok.PublicKey = "did:web:issuer.example.com"
+
+	r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{
+		DIDResolver: mapResolver{"did:web:issuer.example.com": doc},
+	})
+	if !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+	if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{}); r.Allow {
+		t.Fatal("expected did:web to fail without a resolver")
+	}
+}
+
+func TestDIDKeyAlgMismatch(t *testing.T) {
+	pub, priv, _ := GenerateKeypairAlg(AlgES256)
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{Alg: AlgES256})
+	edPub, _ := GenerateKeypair()
+	tok.PublicKey, _ = PublicKeyToDIDKey(AlgEd25519, edPub)
+	if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{}); r.Allow {
+		t.Fatal("expected mismatch to be rejected")
+	}
+	tok.PublicKey, _ = PublicKeyToDIDKey(AlgES256, pub)
+	if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{}); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+}
@@ -0,0 +1,204 @@
+package spl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PolicyChange is one semantic difference between two policies, at the
+// level of their top-level conjuncts.
+type PolicyChange struct {
+	// Kind is "added" or "tightened" (the new policy is stricter here),
+	// "removed" or "loosened" (it is more permissive), or "changed" when
+	// neither direction could be shown.
+	Kind   string `json:"kind"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+	Detail string `json:"detail,omitempty"` // e.g. list items added or removed
+}
+
+// PolicyDiff compares an old policy with a new one.
+type PolicyDiff struct {
+	Changes []PolicyChange `json:"changes"`
+	// Narrower is true when the new policy provably allows no request the
+	// old one denies (see Subsumes).
+	Narrower bool `json:"narrower"`
+	// Unproven lists the old policy's conjuncts the new policy could not be
+	// shown to imply; empty when Narrower.
+	Unproven []string `json:"unproven,omitempty"`
+}
+
+// DiffPolicies reports how newSrc differs from oldSrc. Top-level conjuncts
+// are paired by what they constrain, such as the same numeric limit, member
+// list or deadline, and each pair is classified with Subsumes; vars are
+// substituted as for Subsumes.
+func DiffPolicies(oldSrc, newSrc string, vars map[string]any) (*PolicyDiff, error) {
+	oldRoot, err := readPolicy(oldSrc)
+	if err != nil {
+		return nil, fmt.Errorf("old policy: %w", err)
+	}
+	newRoot, err := readPolicy(newSrc)
+	if err != nil {
+		return nil, fmt.Errorf("new policy: %w", err)
+	}
+	d := &PolicyDiff{Changes: []PolicyChange{}}
+	newAST := bind(newRoot.node(), vars)
+	for _, c := range conjuncts(oldRoot) {
+		if !implies(newAST, bind(c.node(), vars)) {
+			d.Unproven = append(d.Unproven, c.String())
+		}
+	}
+	d.Narrower = len(d.Unproven) == 0
+
+	olds, news := conjuncts(oldRoot), conjuncts(newRoot)
+	used := make([]bool, len(news))
+	for _, o := range olds {
+		j := -1
+		for i, n := range news {
+			if !used[i] && o.String() == n.String() {
+				j = i
+				break
+			}
+		}
+		if j < 0 {
+			for i, n := range news {
+				if !used[i] && conjunctKey(o) == conjunctKey(n) {
+					j = i
+					break
+				}
+			}
+		}
+		if j < 0 {
+			d.Changes = append(d.Changes, PolicyChange{Kind: "removed", Old: o.String()})
+			continue
+		}
+		used[j] = true
+		n := news[j]
+		if o.String() == n.String() {
+			continue
+		}
+		on, nn := bind(o.node(), vars), bind(n.node(), vars)
+		narrower, wider := implies(nn, on), implies(on, nn)
+		kind := "changed"
+		switch {
+		case narrower && wider:
+			continue // equivalent
+		case narrower:
+			kind = "tightened"
+		case wider:
+			kind = "loosened"
+		}
+		d.Changes = append(d.Changes, PolicyChange{Kind: kind, Old: o.String(), New: n.String(), Detail: listDetail(on, nn)})
+	}
+	for i, n := range news {
+		if !used[i] {
+			d.Changes = append(d.Changes, PolicyChange{Kind: "added", New: n.String()})
+		}
+	}
+	return d, nil
+}
+
+func readPolicy(src string) (*syntaxNode, error) {
+	if _, err := Parse(src); err != nil {
+		return nil, err
+	}
+	forms, err := readSyntax(src)
+	if err != nil {
+		return nil, err
+	}
+	return forms[0], nil
+}
+
+func conjuncts(n *syntaxNode) []*syntaxNode {
+	if n.op() == "and" {
+		return n.list[1:]
+	}
+	return []*syntaxNode{n}
+}
+
+// conjunctKey names what a conjunct constrains, so an old and a new
+// conjunct with the same key are compared as one change.
+func conjunctKey(n *syntaxNode) string {
+	op := n.op()
+	if len(n.list) < 3 {
+		return n.String()
+	}
+	a, b := n.list[1], n.list[2]
+	switch op {
+	case "<", "<=", ">", ">=", "=":
+		if a.isNumber() {
+			a, b, op = b, a, flipped[op]
+		}
+		switch {
+		case !b.isNumber():
+			return op + " " + a.String()
+		case strings.HasPrefix(op, "<"):
+			return "upper " + a.String()
+		case strings.HasPrefix(op, ">"):
+			return "lower " + a.String()
+		}
+		return "= " + a.String()
+	case "member", "in":
+		return "member " + a.String()
+	case "subset?":
+		return "subset " + a.String()
+	case "before":
+		if a.isString() {
+			return "after " + b.String()
+		}
+		return "before " + a.String()
+	}
+	return n.String()
+}
+
+// node converts a syntax node to the AST Parse would produce for it.
+func (n *syntaxNode) node() Node {
+	if n.isList {
+		out := make(List, len(n.list))
+		for i, c := range n.list {
+			out[i] = c.node()
+		}
+		return out
+	}
+	switch {
+	case n.atom == "#t":
+		return Bool(true)
+	case n.atom == "#f":
+		return Bool(false)
+	case n.isString():
+		s, _ := strconv.Unquote(n.atom)
+		return Str(s)
+	}
+	if f, err := strconv.ParseFloat(n.atom, 64); err == nil {
+		return Num(f)
+	}
+	return Sym(n.atom)
+}
+
+// listDetail describes the items added to or removed from a member or
+// subset? list between two constraints.
+func listDetail(o, n Node) string {
+	oop, oargs := nodeOp(o)
+	nop, nargs := nodeOp(n)
+	if len(oargs) < 2 || len(nargs) < 2 || !(isMember(oop) || oop == "subset?") || !(isMember(nop) || nop == "subset?") {
+		return ""
+	}
+	ol, ok1 := constList(oargs[1])
+	nl, ok2 := constList(nargs[1])
+	if !ok1 || !ok2 {
+		return ""
+	}
+	var parts []string
+	for _, e := range nl {
+		if !containsEq(ol, e) {
+			parts = append(parts, fmt.Sprintf("+%v", e))
+		}
+	}
+	for _, e := range ol {
+		if !containsEq(nl, e) {
+			parts = append(parts, fmt.Sprintf("-%v", e))
+		}
+	}
+	return strings.Join(parts, " ")
+}
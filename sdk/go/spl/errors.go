@@ -0,0 +1,150 @@
+package spl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrorCode classifies why a verification failed, so integrators can branch
+// on the kind of failure rather than on the text of its message.
+type ErrorCode uint8
+
+const (
+	CodeNone             ErrorCode = iota // no error
+	CodeUnknown                           // an error of no other kind
+	CodeMalformedToken                    // the token could not be decoded
+	CodeExpired                           // the token is past its expiry
+	CodeUnsupportedAlg                    // the token's algorithm or version is not accepted
+	CodeBadSignature                      // a signature did not verify
+	CodeUntrustedKey                      // the issuer key is not trusted
+	CodePoPRequired                       // the presenter did not prove possession of pop_key
+	CodePolicySyntax                      // the policy or a caveat did not parse
+	CodeGasExceeded                       // evaluation ran out of gas
+	CodeDepthExceeded                     // evaluation nested too deeply
+	CodeUnknownOp                         // the policy applies an unknown operator
+	CodeUnresolvedSymbol                  // a strict evaluation met an unbound name
+	CodeEval                              // the policy failed to evaluate otherwise
+	CodeSealed                            // a sealed token was attenuated
+	CodeStore                             // a counter, rate or ledger store failed
+	CodeCanceled                          // the context was done
+	CodeDenied                            // the policy denied the request; used only by Decision
+	CodeProfile                           // the policy falls short of the verifier's PolicyProfile
+	CodeReplayed                          // the presentation's nonce or receipt was already used
+	CodeResult                            // a call's result fell outside its ResultPolicy
+	numCodes
+)
+
+var codeNames = [numCodes]string{
+	CodeNone: "none", CodeUnknown: "error", CodeMalformedToken: "malformed_token",
+	CodeExpired: "expired", CodeUnsupportedAlg: "unsupported_alg", CodeBadSignature: "bad_signature",
+	CodeUntrustedKey: "untrusted_key", CodePoPRequired: "pop_required", CodePolicySyntax: "policy_syntax",
+	CodeGasExceeded: "gas_exceeded", CodeDepthExceeded: "depth_exceeded", CodeUnknownOp: "unknown_op",
+	CodeUnresolvedSymbol: "unresolved_symbol", CodeEval: "eval", CodeSealed: "sealed",
+	CodeStore: "store", CodeCanceled: "canceled", CodeDenied: "denied",
+	CodeProfile: "profile", CodeReplayed: "replayed", CodeResult: "result",
+}
+
+// String returns the code's snake_case name, as used on the wire.
+func (c ErrorCode) String() string {
+	if c >= numCodes {
+		return codeNames[CodeUnknown]
+	}
+	return codeNames[c]
+}
+
+// MarshalText encodes the code as its name.
+func (c ErrorCode) MarshalText() ([]byte, error) { return []byte(c.String()), nil }
+
+// UnmarshalText decodes a code name; an unrecognised name is CodeUnknown.
+func (c *ErrorCode) UnmarshalText(b []byte) error {
+	for i, name := range codeNames {
+		if name == string(b) {
+			*c = ErrorCode(i)
+			return nil
+		}
+	}
+	*c = CodeUnknown
+	return nil
+}
+
+// Error is a kind of verification error. The Err values are the kinds;
+// errors returned by verification wrap one of them, so errors.Is matches the
+// kind and errors.As to a *Error reads its Code, while the message keeps its
+// details.
+type Error struct {
+	Code ErrorCode
+	msg  string
+}
+
+func (e *Error) Error() string { return e.msg }
+
+// Wrap returns err marked as being of kind e, so that errors.Is(err, e)
+// holds and CodeOf reports e's code, while the message stays err's.
+// Packages that verify on top of this one use it to classify their own
+// failures.
+func (e *Error) Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{e, err}
+}
+
+// The kinds of verification error.
+var (
+	ErrMalformedToken   = &Error{CodeMalformedToken, "malformed token"}
+	ErrExpired          = &Error{CodeExpired, "token expired"}
+	ErrUnsupportedAlg   = &Error{CodeUnsupportedAlg, "unsupported algorithm"}
+	ErrBadSignature     = &Error{CodeBadSignature, "invalid signature"}
+	ErrUntrustedKey     = &Error{CodeUntrustedKey, "untrusted issuer key"}
+	ErrPoPRequired      = &Error{CodePoPRequired, "PoP binding requires presentation signature"}
+	ErrPolicySyntax     = &Error{CodePolicySyntax, "parse error"}
+	ErrGasExceeded      = &Error{CodeGasExceeded, "gas budget exceeded"}
+	ErrDepthExceeded    = &Error{CodeDepthExceeded, "max nesting depth exceeded"}
+	ErrUnknownOp        = &Error{CodeUnknownOp, "unknown op"}
+	ErrUnresolvedSymbol = &Error{CodeUnresolvedSymbol, "unresolved symbol"}
+	ErrEval             = &Error{CodeEval, "evaluation failed"}
+	ErrSealed           = &Error{CodeSealed, "token is sealed and cannot be attenuated"}
+	ErrStore            = &Error{CodeStore, "store failed"}
+	ErrProfile          = &Error{CodeProfile, "policy does not meet the required profile"}
+	ErrReplayed         = &Error{CodeReplayed, "presentation already used"}
+	ErrResult           = &Error{CodeResult, "result denied by result policy"}
+)
+
+// CodeOf returns the code of the kind err wraps: CodeNone for nil,
+// CodeCanceled for a context error, and CodeUnknown for an error of no kind.
+func CodeOf(err error) ErrorCode {
+	if err == nil {
+		return CodeNone
+	}
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		return e.Code
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return CodeCanceled
+	}
+	return CodeUnknown
+}
+
+// kindError is an error of a kind whose message is err's.
+type kindError struct {
+	kind *Error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// withKind returns err marked as being of kind, unless it already has one.
+func withKind(kind *Error, err error) error {
+	if err == nil || CodeOf(err) != CodeUnknown {
+		return err
+	}
+	return kind.Wrap(err)
+}
+
+// kindf formats an error of kind.
+func kindf(kind *Error, format string, args ...any) error {
+	return kind.Wrap(fmt.Errorf(format, args...))
+}
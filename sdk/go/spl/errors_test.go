@@ -0,0 +1,91 @@
+package spl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestVerifyErrorKinds(t *testing.T) {
+	_, priv := GenerateKeypair()
+	mint := func(policy string, opts MintOptions) *Token {
+		t.Helper()
+		tok, err := Mint(policy, priv, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	tampered := mint(tokenTestPolicy, MintOptions{})
+	tampered.Policy = "#t"
+	agentPub, _ := GenerateKeypair()
+	cases := []struct {
+		name string
+		tok  *Token
+		opts VerifyTokenOptions
+		kind *Error
+	}{
+		{"expired", mint("#t", MintOptions{Expires: "2020-01-01T00:00:00Z"}), VerifyTokenOptions{}, ErrExpired},
+		{"tampered", tampered, VerifyTokenOptions{}, ErrBadSignature},
+		{"pop", mint("#t", MintOptions{PoPKey: agentPub}), VerifyTokenOptions{}, ErrPoPRequired},
+		{"syntax", mint("(and", MintOptions{}), VerifyTokenOptions{}, ErrPolicySyntax},
+		{"unknown op", mint("(frobnicate 1)", MintOptions{}), VerifyTokenOptions{}, ErrUnknownOp},
+		{"gas", mint(tokenTestPolicy, MintOptions{}), VerifyTokenOptions{Limits: Limits{MaxGas: 2}}, ErrGasExceeded},
+		{"eval", mint("(get req)", MintOptions{}), VerifyTokenOptions{}, ErrEval},
+	}
+	for _, c := range cases {
+		r := VerifyTokenObj(c.tok, tokenTestReq(50), c.opts)
+		if !errors.Is(r.Err, c.kind) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.kind, r.Err)
+		}
+		if r.Code != c.kind.Code {
+			t.Fatalf("%s: expected code %s, got %s", c.name, c.kind.Code, r.Code)
+		}
+		var e *Error
+		if !errors.As(r.Err, &e) || e != c.kind {
+			t.Fatalf("%s: expected errors.As to find %v", c.name, c.kind)
+		}
+	}
+	if r := VerifyToken("{", nil, VerifyTokenOptions{}); r.Code != CodeMalformedToken {
+		t.Fatalf("expected malformed_token, got %s (%v)", r.Code, r.Err)
+	}
+	if r := VerifyTokenObj(mint(tokenTestPolicy, MintOptions{}), tokenTestReq(500), VerifyTokenOptions{}); r.Allow || r.Err != nil || r.Code != CodeNone {
+		t.Fatalf("expected a plain deny, got %+v", r)
+	}
+}
+
+func TestEvalErrorKinds(t *testing.T) {
+	env := makeEnv()
+	env.Strict = true
+	_, err := Verify(List{Sym("="), Sym("missing"), Num(1)}, env)
+	if !errors.Is(err, ErrUnresolvedSymbol) || err.Error() != "unresolved symbol: missing" {
+		t.Fatalf("expected unresolved symbol: missing, got %v", err)
+	}
+	env.Sealed = true
+	if _, err := Verify(Bool(true), env); !errors.Is(err, ErrSealed) {
+		t.Fatalf("expected ErrSealed, got %v", err)
+	}
+	if CodeOf(context.Canceled) != CodeCanceled || CodeOf(errors.New("x")) != CodeUnknown || CodeOf(nil) != CodeNone {
+		t.Fatal("expected CodeOf to classify context, plain and nil errors")
+	}
+}
+
+func TestErrorCodeJSON(t *testing.T) {
+	b, err := json.Marshal(map[string]ErrorCode{"code": CodeBadSignature})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"code":"bad_signature"}` {
+		t.Fatalf("expected bad_signature, got %s", b)
+	}
+	var m map[string]ErrorCode
+	if err := json.Unmarshal(b, &m); err != nil || m["code"] != CodeBadSignature {
+		t.Fatalf("expected round trip, got %v, %v", m, err)
+	}
+	for c := CodeNone; c < numCodes; c++ {
+		if c.String() == "" {
+			t.Fatalf("code %d has no name", c)
+		}
+	}
+}
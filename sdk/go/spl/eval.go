@@ -1,36 +1,95 @@
 package spl
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"slices"
+	"time"
 )
 
 type Env struct {
-	Req    map[string]any
-	Vars   map[string]any
-	Gas    int
-	MaxGas int
-	Depth  int
-	Sealed bool
-	Strict bool
+	Req      map[string]any
+	Vars     map[string]any
+	Gas      int
+	MaxGas   int // 0 means DefaultLimits.MaxGas
+	Depth    int
+	MaxDepth int // 0 means DefaultLimits.MaxDepth
+	Sealed   bool
+	// Strict makes a name bound neither in Vars nor by the language an
+	// error. Otherwise such a name evaluates to itself, except the names in
+	// RequiredVars, which are errors whether or not Strict is set.
+	Strict       bool
+	RequiredVars []string
+	// Clock, when set, binds now to its time, as an RFC 3339 string, when
+	// Vars does not. It is read at most once per evaluation.
+	Clock func() time.Time
+	// Memoize evaluates each pure get or tuple once per evaluation: a
+	// repeat of one already evaluated, which the parser makes the same
+	// node, reuses its value and costs one unit of gas.
+	Memoize bool
+	// Classifier redacts the sensitive request fields in the values
+	// Explain records.
+	Classifier *FieldClassifier
 
 	PerDayCount func(action, day string) int
-	Crypto      struct {
-		DPoPOk    func() bool
-		MerkleOk  func(tuple []any) bool
-		VRFOk     func(day string, amount float64) bool
-		ThreshOk  func() bool
-	}
+	// WindowCount backs window-count, and BucketOk bucket-ok?; see
+	// VerifyTokenOptions.Rates. Without WindowCount, window-count is an
+	// error; without BucketOk, bucket-ok? is false.
+	WindowCount func(action string, window time.Duration) int
+	BucketOk    func(action string, b Bucket) bool
+	// SumAmount backs sum-amount with the spend recorded for the action in
+	// the period, plus pending, in currency; see VerifyTokenOptions.Ledger.
+	// Without it, sum-amount is an error.
+	SumAmount func(action, period, currency string, pending Money) float64
+	Crypto    CryptoCallbacks
+
+	trace    *tracer       // set by Explain
+	counters *counterUse   // set for VerifyTokenOptions.Counters
+	memo     map[*Node]any // pure lists' values by node, with Memoize
+	failing  *failPath     // set for Decision
+	now      string        // Clock's time, once read
+}
+
+// CryptoCallbacks are the host-provided checks behind the crypto predicates.
+// A nil callback evaluates to false (fail-closed).
+type CryptoCallbacks struct {
+	DPoPOk   func() bool
+	MerkleOk func(tuple []any) bool
+	VRFOk    func(day string, amount float64) bool
+	ThreshOk func() bool
+	// AttestedOk backs attested_ok?; see VerifyWebAuthnAssertion.
+	AttestedOk func() bool
+	// ApprovalOk backs approval_ok?, reporting whether the named approver
+	// has approved this request; see the approval package.
+	ApprovalOk func(approver string) bool
+	// MerkleMember backs merkle-member?, reporting whether value is a leaf
+	// of the token's Merkle root. proof is the inclusion proof the policy
+	// passed as its second argument, or nil if it passed none; see
+	// VerifyTokenOptions.MerkleWitnesses.
+	MerkleMember func(value string, proof []MerkleProofStep) bool
 }
 
 const DefaultMaxGas = 10000
 const MaxDepth = 64
 
 func Verify(ast Node, env Env) (bool, error) {
+	allow, _, err := VerifyWithGas(ast, env)
+	return allow, err
+}
+
+// VerifyWithGas is Verify that also reports how much of the gas budget the
+// evaluation consumed. Its errors are of a kind such as ErrGasExceeded or
+// ErrUnknownOp, or else ErrEval; see Error.
+func VerifyWithGas(ast Node, env Env) (bool, int, error) {
 	if env.Sealed {
-		return false, fmt.Errorf("token is sealed and cannot be attenuated")
+		return false, 0, ErrSealed
 	}
 	if env.MaxGas == 0 {
-		env.MaxGas = DefaultMaxGas
+		env.MaxGas = DefaultLimits.MaxGas
+	}
+	if env.MaxDepth == 0 {
+		env.MaxDepth = DefaultLimits.MaxDepth
 	}
 	env.Gas = env.MaxGas
 	// Ensure crypto callbacks are never nil (fail-closed defaults)
@@ -46,51 +105,84 @@ func Verify(ast Node, env Env) (bool, error) {
 	if env.Crypto.ThreshOk == nil {
 		env.Crypto.ThreshOk = func() bool { return false }
 	}
+	if env.Crypto.AttestedOk == nil {
+		env.Crypto.AttestedOk = func() bool { return false }
+	}
+	if env.Crypto.ApprovalOk == nil {
+		env.Crypto.ApprovalOk = func(string) bool { return false }
+	}
+	if env.Crypto.MerkleMember == nil {
+		env.Crypto.MerkleMember = func(string, []MerkleProofStep) bool { return false }
+	}
+	if env.Memoize && env.memo == nil {
+		env.memo = map[*Node]any{}
+	}
 	val, err := eval(ast, &env)
+	used := env.MaxGas - env.Gas
+	if used > env.MaxGas {
+		used = env.MaxGas
+	}
 	if err != nil {
-		return false, err
+		return false, used, withKind(ErrEval, err)
 	}
 	b, ok := val.(bool)
 	if !ok {
-		return false, fmt.Errorf("policy did not return boolean")
+		return false, used, kindf(ErrEval, "policy did not return boolean")
 	}
-	return b, nil
+	return b, used, nil
 }
 
 func eval(n Node, env *Env) (any, error) {
+	if env.trace == nil {
+		return evalNode(n, env)
+	}
+	t := env.trace.enter(n)
+	v, err := evalNode(n, env)
+	env.trace.exit(t, v, err)
+	return v, err
+}
+
+func evalNode(n Node, env *Env) (any, error) {
 	env.Gas--
 	if env.Gas < 0 {
-		return nil, fmt.Errorf("gas budget exceeded")
+		return nil, ErrGasExceeded
 	}
 	env.Depth++
-	if env.Depth > MaxDepth {
+	if env.Depth > env.MaxDepth {
 		env.Depth--
-		return nil, fmt.Errorf("max nesting depth exceeded")
+		return nil, ErrDepthExceeded
 	}
-	defer func() { env.Depth-- }()
+	v, err := evalValue(n, env)
+	env.Depth--
+	return v, err
+}
 
+// evalValue evaluates n once evalNode has charged gas and depth for it.
+func evalValue(n Node, env *Env) (any, error) {
 	switch v := n.(type) {
-	case []Node:
+	case List:
 		if len(v) == 0 {
 			return nil, nil
 		}
-		op, ok := v[0].(string)
+		code, op, ok := v.Head()
 		if !ok {
 			return nil, fmt.Errorf("operator must be a symbol")
 		}
-		switch op {
-		case "and":
-			for _, a := range v[1:] {
+		switch code {
+		case OpAnd:
+			for i, a := range v[1:] {
 				res, err := eval(a, env)
 				if err != nil {
+					env.failing.note(i+1, env.Gas, env.Depth)
 					return nil, err
 				}
 				if !truthy(res) {
+					env.failing.note(i+1, env.Gas, env.Depth)
 					return false, nil
 				}
 			}
 			return true, nil
-		case "or":
+		case OpOr:
 			for _, a := range v[1:] {
 				res, err := eval(a, env)
 				if err != nil {
@@ -101,7 +193,7 @@ func eval(n Node, env *Env) (any, error) {
 				}
 			}
 			return false, nil
-		case "not":
+		case OpNot:
 			if len(v) < 2 {
 				return nil, fmt.Errorf("not requires 1 argument")
 			}
@@ -110,7 +202,7 @@ func eval(n Node, env *Env) (any, error) {
 				return nil, err
 			}
 			return !truthy(res), nil
-		case "=":
+		case OpEq:
 			if len(v) < 3 {
 				return nil, fmt.Errorf("= requires 2 arguments")
 			}
@@ -123,12 +215,12 @@ func eval(n Node, env *Env) (any, error) {
 				return nil, err
 			}
 			return eq(a, b), nil
-		case "<=", "<", ">=", ">":
+		case OpLe, OpLt, OpGe, OpGt:
 			if len(v) < 3 {
 				return nil, fmt.Errorf("%s requires 2 arguments", op)
 			}
-			return cmp(v[1:], env, op)
-		case "member", "in":
+			return cmp(v[1:], env, code)
+		case OpMember, OpIn:
 			if len(v) < 3 {
 				return nil, fmt.Errorf("%s requires 2 arguments", op)
 			}
@@ -140,15 +232,29 @@ func eval(n Node, env *Env) (any, error) {
 			if err != nil {
 				return nil, err
 			}
-			if arr, ok := lst.([]any); ok {
-				for _, e := range arr {
-					if eq(e, x) {
-						return true, nil
-					}
+			return listContains(lst, x), nil
+		case OpNotMember:
+			if len(v) < 3 {
+				return nil, fmt.Errorf("not-member requires 2 arguments")
+			}
+			x, err := eval(v[1], env)
+			if err != nil {
+				return nil, err
+			}
+			lst, err := eval(v[2], env)
+			if err != nil {
+				return nil, err
+			}
+			// A blocklist that is missing is an error, not an empty list, so
+			// a deny-list check never passes for want of its list.
+			if _, ok := asList(lst); !ok {
+				if s, ok := v[2].(*Symbol); ok && env.Vars[s.Name] == nil {
+					return nil, fmt.Errorf("not-member: %w: %s", ErrUnresolvedSymbol, s.Name)
 				}
+				return nil, fmt.Errorf("not-member: blocklist must be a list, got %T", lst)
 			}
-			return false, nil
-		case "subset?":
+			return !listContains(lst, x), nil
+		case OpSubset:
 			if len(v) < 3 {
 				return nil, fmt.Errorf("subset? requires 2 arguments")
 			}
@@ -160,61 +266,58 @@ func eval(n Node, env *Env) (any, error) {
 			if err != nil {
 				return nil, err
 			}
-			listA, okA := a.([]any)
-			listB, okB := b.([]any)
+			listA, okA := asList(a)
+			_, okB := asList(b)
 			if !okA || !okB {
 				return false, nil
 			}
 			for _, item := range listA {
-				found := false
-				for _, candidate := range listB {
-					if eq(item, candidate) {
-						found = true
-						break
-					}
-				}
-				if !found {
+				if !listContains(b, item) {
 					return false, nil
 				}
 			}
 			return true, nil
-		case "before":
+		case OpBloomMember:
 			if len(v) < 3 {
-				return nil, fmt.Errorf("before requires 2 arguments")
+				return nil, fmt.Errorf("bloom-member? requires 2 arguments")
 			}
-			a, err := eval(v[1], env)
+			x, err := eval(v[1], env)
 			if err != nil {
 				return nil, err
 			}
-			b, err := eval(v[2], env)
+			f, err := eval(v[2], env)
 			if err != nil {
 				return nil, err
 			}
-			sa, okA := a.(string)
-			sb, okB := b.(string)
-			if !okA || !okB {
-				return nil, fmt.Errorf("before requires string arguments")
+			filter, ok := f.(*Bloom)
+			if !ok {
+				return nil, fmt.Errorf("bloom-member?: filter must be a Bloom filter")
 			}
-			return sa < sb, nil
-		case "get":
+			key, ok := bloomKey(x)
+			return ok && filter.Contains(key), nil
+		case OpBefore:
 			if len(v) < 3 {
-				return nil, fmt.Errorf("get requires 2 arguments")
+				return nil, fmt.Errorf("before requires 2 arguments")
 			}
-			obj, err := eval(v[1], env)
+			a, err := eval(v[1], env)
 			if err != nil {
 				return nil, err
 			}
-			key, err := eval(v[2], env)
+			b, err := eval(v[2], env)
 			if err != nil {
 				return nil, err
 			}
-			if m, ok := obj.(map[string]any); ok {
-				if s, ok := key.(string); ok {
-					return m[s], nil
-				}
+			var lits *[2]timeLit
+			if h, ok := v[0].(*Symbol); ok {
+				lits = h.times
 			}
-			return nil, nil
-		case "per-day-count":
+			return before(a, b, lits)
+		case OpGet:
+			if len(v) < 3 {
+				return nil, fmt.Errorf("get requires 2 arguments")
+			}
+			return memoized(v, env, evalGet)
+		case OpPerDayCount:
 			if len(v) < 3 {
 				return nil, fmt.Errorf("per-day-count requires 2 arguments")
 			}
@@ -234,10 +337,83 @@ func eval(n Node, env *Env) (any, error) {
 			if !ok {
 				return nil, fmt.Errorf("per-day-count: day must be string")
 			}
+			if env.counters != nil {
+				env.counters.read("per-day-count", actionStr, dayStr)
+			}
 			return float64(env.PerDayCount(actionStr, dayStr)), nil
-		case "dpop_ok?":
+		case OpWindowCount:
+			if len(v) < 3 {
+				return nil, fmt.Errorf("window-count requires 2 arguments")
+			}
+			action, window, d, err := evalRate(op, v[1], v[2], env)
+			if err != nil {
+				return nil, err
+			}
+			if env.WindowCount == nil {
+				return nil, fmt.Errorf("window-count requires a rate store")
+			}
+			if env.counters != nil {
+				env.counters.read(op, action, window)
+			}
+			return float64(env.WindowCount(action, d)), nil
+		case OpBucketOk:
+			if len(v) < 4 {
+				return nil, fmt.Errorf("bucket-ok? requires 3 arguments")
+			}
+			capacity, err := eval(v[2], env)
+			if err != nil {
+				return nil, err
+			}
+			c, ok := capacity.(float64)
+			if !ok || c < 1 || c != float64(int(c)) {
+				return nil, fmt.Errorf("bucket-ok?: capacity must be a positive integer")
+			}
+			action, _, d, err := evalRate(op, v[1], v[3], env)
+			if err != nil {
+				return nil, err
+			}
+			b := Bucket{Capacity: int(c), Refill: d}
+			if env.BucketOk == nil || !env.BucketOk(action, b) {
+				return false, nil
+			}
+			if env.counters != nil {
+				env.counters.bucket(action, b)
+			}
+			return true, nil
+		case OpSumAmount:
+			if len(v) < 4 {
+				return nil, fmt.Errorf("sum-amount requires 3 arguments")
+			}
+			var args [3]string
+			for i, name := range []string{"action", "period", "currency"} {
+				a, err := eval(v[i+1], env)
+				if err != nil {
+					return nil, err
+				}
+				s, ok := a.(string)
+				if !ok {
+					return nil, fmt.Errorf("sum-amount: %s must be string", name)
+				}
+				args[i] = s
+			}
+			action, period, currency := args[0], args[1], args[2]
+			if _, err := LedgerPeriod(period, time.Time{}); err != nil {
+				return nil, fmt.Errorf("sum-amount: %w", err)
+			}
+			if env.SumAmount == nil {
+				return nil, fmt.Errorf("sum-amount requires a ledger")
+			}
+			pending, err := RequestSpend(env.Req, currency)
+			if err != nil {
+				return nil, fmt.Errorf("sum-amount: %w", err)
+			}
+			if env.counters != nil {
+				env.counters.readSpend(action, period, currency, pending)
+			}
+			return env.SumAmount(action, period, currency, pending), nil
+		case OpDPoPOk:
 			return env.Crypto.DPoPOk(), nil
-		case "merkle_ok?":
+		case OpMerkleOk:
 			if len(v) < 2 {
 				return nil, fmt.Errorf("merkle_ok? requires 1 argument")
 			}
@@ -250,7 +426,30 @@ func eval(n Node, env *Env) (any, error) {
 				return nil, fmt.Errorf("merkle_ok? argument must be a tuple")
 			}
 			return env.Crypto.MerkleOk(arr), nil
-		case "vrf_ok?":
+		case OpMerkleMember:
+			if len(v) < 2 {
+				return nil, fmt.Errorf("merkle-member? requires 1 argument")
+			}
+			x, err := eval(v[1], env)
+			if err != nil {
+				return nil, err
+			}
+			// A value with no leaf form, such as a missing field, is no
+			// member, nor is one with a malformed proof.
+			var proof []MerkleProofStep
+			if len(v) > 2 {
+				p, err := eval(v[2], env)
+				if err != nil {
+					return nil, err
+				}
+				var ok bool
+				if proof, ok = proofSteps(p); !ok {
+					return false, nil
+				}
+			}
+			leaf, ok := bloomKey(x)
+			return ok && env.Crypto.MerkleMember(leaf, proof), nil
+		case OpVRFOk:
 			if len(v) < 3 {
 				return nil, fmt.Errorf("vrf_ok? requires 2 arguments")
 			}
@@ -279,28 +478,153 @@ func eval(n Node, env *Env) (any, error) {
 		// signature against its corresponding public key and confirms count >= threshold.
 		// Not implemented in v0.1 — remains an interface stub. Provide your own
 		// implementation via env.Crypto.ThreshOk when integrating.
-		case "thresh_ok?":
+		case OpThreshOk:
 			return env.Crypto.ThreshOk(), nil
-		case "tuple":
-			var out []any
-			for _, a := range v[1:] {
-				val, err := eval(a, env)
-				if err != nil {
-					return nil, err
-				}
-				out = append(out, val)
+		case OpAttestedOk:
+			return env.Crypto.AttestedOk(), nil
+		case OpApprovalOk:
+			if len(v) < 2 {
+				return nil, fmt.Errorf("approval_ok? requires 1 argument")
 			}
-			return out, nil
+			who, err := eval(v[1], env)
+			if err != nil {
+				return nil, err
+			}
+			whoStr, ok := who.(string)
+			if !ok {
+				return nil, fmt.Errorf("approval_ok?: approver must be string")
+			}
+			return env.Crypto.ApprovalOk(whoStr), nil
+		// range_ok? — zero-knowledge check that a Pedersen-committed amount is
+		// at most limit; see VerifyAmountAtMost.
+		case OpRangeOk:
+			if len(v) < 4 {
+				return nil, fmt.Errorf("range_ok? requires 3 arguments")
+			}
+			commitment, err := eval(v[1], env)
+			if err != nil {
+				return nil, err
+			}
+			proofArg, err := eval(v[2], env)
+			if err != nil {
+				return nil, err
+			}
+			limit, err := eval(v[3], env)
+			if err != nil {
+				return nil, err
+			}
+			c, ok := commitment.(string)
+			if !ok {
+				return false, nil
+			}
+			proof, err := rangeProofArg(proofArg)
+			if err != nil {
+				return false, nil
+			}
+			l := toFloat(limit)
+			if l < 0 || l != float64(uint64(l)) {
+				return nil, fmt.Errorf("range_ok?: limit must be a non-negative integer")
+			}
+			return VerifyAmountAtMost(c, uint64(l), proof), nil
+		case OpTuple:
+			return memoized(v, env, evalTuple)
 		default:
-			return nil, fmt.Errorf("unknown op: %v", op)
+			return nil, fmt.Errorf("%w: %v", ErrUnknownOp, op)
 		}
-	case string:
-		return resolveSymbol(v, env)
+	case *Symbol:
+		return resolveSymbol(v.Name, env)
+	case Str:
+		return resolveSymbol(string(v), env)
+	case Num:
+		if v >= 0 && v < Num(len(smallValues)) && v == Num(int(v)) {
+			return smallValues[int(v)], nil
+		}
+		return float64(v), nil
+	case Bool:
+		return bool(v), nil
 	default:
 		return v, nil
 	}
 }
 
+// evalGet evaluates (get obj key).
+func evalGet(v List, env *Env) (any, error) {
+	obj, err := eval(v[1], env)
+	if err != nil {
+		return nil, err
+	}
+	key, err := eval(v[2], env)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := obj.(map[string]any); ok {
+		if s, ok := key.(string); ok {
+			return m[s], nil
+		}
+	}
+	return nil, nil
+}
+
+// evalTuple evaluates (tuple x ...).
+func evalTuple(v List, env *Env) (any, error) {
+	var out []any
+	if len(v) > 1 {
+		out = make([]any, 0, len(v)-1)
+	}
+	for _, a := range v[1:] {
+		val, err := eval(a, env)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, val)
+	}
+	return out, nil
+}
+
+// memoized evaluates v with f, reusing the value from an earlier evaluation
+// of the same node when memoizing and v is pure.
+func memoized(v List, env *Env, f func(List, *Env) (any, error)) (any, error) {
+	if env.memo == nil || !pure(v) {
+		return f(v, env)
+	}
+	if val, ok := env.memo[&v[0]]; ok {
+		return val, nil
+	}
+	val, err := f(v, env)
+	if err == nil {
+		env.memo[&v[0]] = val
+	}
+	return val, err
+}
+
+// pure reports whether n evaluates to the same value wherever it appears in
+// one evaluation, without side effects: an atom, or a get or tuple of pure
+// arguments.
+func pure(n Node) bool {
+	l, ok := n.(List)
+	if !ok || len(l) == 0 {
+		return true
+	}
+	if op, _, _ := l.Head(); op != OpGet && op != OpTuple {
+		return false
+	}
+	for _, a := range l[1:] {
+		if !pure(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// smallValues holds the integers 0 to 255 as float64 values, so literals
+// such as limits and counts evaluate without allocating.
+var smallValues = func() (vals [256]any) {
+	for i := range vals {
+		vals[i] = float64(i)
+	}
+	return vals
+}()
+
 func resolveSymbol(name string, env *Env) (any, error) {
 	switch name {
 	case "req":
@@ -309,8 +633,14 @@ func resolveSymbol(name string, env *Env) (any, error) {
 		if v, ok := env.Vars["now"]; ok {
 			return v, nil
 		}
-		if env.Strict {
-			return nil, fmt.Errorf("unresolved symbol: %s", name)
+		if env.Clock != nil {
+			if env.now == "" {
+				env.now = env.Clock().UTC().Format(time.RFC3339)
+			}
+			return env.now, nil
+		}
+		if env.Strict || slices.Contains(env.RequiredVars, name) {
+			return nil, fmt.Errorf("%w: %s", ErrUnresolvedSymbol, name)
 		}
 		return name, nil
 	case "#t":
@@ -323,8 +653,8 @@ func resolveSymbol(name string, env *Env) (any, error) {
 				return v, nil
 			}
 		}
-		if env.Strict {
-			return nil, fmt.Errorf("unresolved symbol: %s", name)
+		if env.Strict || slices.Contains(env.RequiredVars, name) {
+			return nil, fmt.Errorf("%w: %s", ErrUnresolvedSymbol, name)
 		}
 		return name, nil
 	}
@@ -341,68 +671,167 @@ func truthy(x any) bool {
 	}
 }
 
+// eq is the equality of =, member and in. Values are equal only when they
+// are the same kind: numbers of any Go numeric type by value, strings,
+// booleans, nil, lists element by element, and objects key by key. Other
+// values are equal when they have the same type and compare equal.
 func eq(a, b any) bool {
 	switch av := a.(type) {
 	case bool:
 		bv, ok := b.(bool)
 		return ok && av == bv
-	case float64:
-		switch bv := b.(type) {
-		case float64:
-			return av == bv
-		case int:
-			return av == float64(bv)
-		}
-		return false
-	case int:
-		switch bv := b.(type) {
-		case int:
-			return av == bv
-		case float64:
-			return float64(av) == bv
-		}
-		return false
 	case string:
 		bv, ok := b.(string)
 		return ok && av == bv
 	case nil:
 		return b == nil
-	default:
-		return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return ok && av.Equal(bv)
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !eq(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case *Set:
+		bv, ok := b.(*Set)
+		if !ok || av.Len() != bv.Len() {
+			return false
+		}
+		for _, x := range av.items {
+			if !bv.Contains(x) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, x := range av {
+			y, ok := bv[k]
+			if !ok || !eq(x, y) {
+				return false
+			}
+		}
+		return true
 	}
+	if af, ok := number(a); ok {
+		bf, ok := number(b)
+		return ok && af == bf
+	}
+	// A comparable type, such as a struct with an interface field, can
+	// still hold a value == panics on, so the values are checked.
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.ValueOf(a).Comparable() && reflect.ValueOf(b).Comparable() && a == b
 }
 
-func cmp(args []Node, env *Env, op string) (any, error) {
+// evalRate evaluates the action and window arguments of window-count or
+// bucket-ok?.
+func evalRate(op string, actionArg, windowArg Node, env *Env) (string, string, time.Duration, error) {
+	action, err := eval(actionArg, env)
+	if err != nil {
+		return "", "", 0, err
+	}
+	window, err := eval(windowArg, env)
+	if err != nil {
+		return "", "", 0, err
+	}
+	actionStr, ok := action.(string)
+	if !ok {
+		return "", "", 0, fmt.Errorf("%s: action must be string", op)
+	}
+	windowStr, ok := window.(string)
+	if !ok {
+		return "", "", 0, fmt.Errorf("%s: window must be string", op)
+	}
+	d, err := ParseWindow(windowStr)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return actionStr, windowStr, d, nil
+}
+
+func cmp(args []Node, env *Env, op Op) (any, error) {
 	a, err := eval(args[0], env)
 	if err != nil {
 		return nil, err
 	}
+	var counted counterKey
+	if env.counters != nil {
+		counted = env.counters.last
+	}
 	b, err := eval(args[1], env)
 	if err != nil {
 		return nil, err
 	}
 	af := toFloat(a)
 	bf := toFloat(b)
+	var res bool
 	switch op {
-	case "<=":
-		return af <= bf, nil
-	case "<":
-		return af < bf, nil
-	case ">=":
-		return af >= bf, nil
-	case ">":
-		return af > bf, nil
-	}
-	return false, nil
+	case OpLe:
+		res = af <= bf
+	case OpLt:
+		res = af < bf
+	case OpGe:
+		res = af >= bf
+	case OpGt:
+		res = af > bf
+	}
+	if env.counters != nil {
+		switch {
+		case isCounter(args[0]):
+			env.counters.limit(counted, op.String(), bf, res)
+		case isCounter(args[1]):
+			env.counters.limit(env.counters.last, flipCmp[op.String()], af, res)
+		}
+	}
+	return res, nil
 }
 
+// toFloat returns x as a number for the comparisons, 0 if it is not one.
 func toFloat(x any) float64 {
+	f, _ := number(x)
+	return f
+}
+
+// number returns x as a float64 if it is a number: any Go integer or float
+// type, or a json.Number from a decoder that uses them.
+func number(x any) (float64, bool) {
 	switch v := x.(type) {
 	case float64:
-		return v
+		return v, true
 	case int:
-		return float64(v)
-	default:
-		return 0
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
 	}
+	return 0, false
 }
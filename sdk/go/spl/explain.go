@@ -0,0 +1,183 @@
+package spl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Trace records the evaluation of one expression: an operator application,
+// or a variable it looked up. Literals and req are not recorded.
+type Trace struct {
+	Expr  string `json:"expr"`
+	Value any    `json:"value"`
+	Error string `json:"error,omitempty"`
+	// Failing marks the path from the root to the clause that decided a
+	// deny; the last marked trace is that clause.
+	Failing  bool     `json:"failing,omitempty"`
+	Children []*Trace `json:"children,omitempty"`
+
+	op        string
+	sensitive bool // the value derives from a classified request field
+	path      []string
+}
+
+// Explanation is the outcome of Explain.
+type Explanation struct {
+	Allow   bool
+	GasUsed int
+	Root    *Trace
+	// Failing is the clause that decided a deny: the false conjunct, or the
+	// expression whose evaluation failed. Nil when the policy allows.
+	Failing *Trace
+}
+
+// Explain evaluates ast like VerifyWithGas and also records the evaluation
+// tree, with the values each clause produced. The error is the one
+// VerifyWithGas would return; the explanation is valid either way.
+func Explain(ast Node, env Env) (*Explanation, error) {
+	tr := &tracer{vars: env.Vars, classifier: env.Classifier}
+	env.trace = tr
+	allow, gas, err := VerifyWithGas(ast, env)
+	ex := &Explanation{Allow: allow, GasUsed: gas, Root: tr.root}
+	if !allow && ex.Root != nil {
+		ex.Failing = markFailing(ex.Root)
+	}
+	return ex, err
+}
+
+// markFailing follows a deny down from t: into the conjunct that
+// short-circuited an and, or into the subexpression that errored.
+func markFailing(t *Trace) *Trace {
+	for {
+		t.Failing = true
+		if len(t.Children) == 0 {
+			return t
+		}
+		last := t.Children[len(t.Children)-1]
+		switch {
+		case t.Error != "" && last.Error != "":
+		case t.Error == "" && t.op == "and" && !truthy(last.Value):
+		default:
+			return t
+		}
+		t = last
+	}
+}
+
+// tracer builds the Trace tree as eval descends.
+type tracer struct {
+	vars       map[string]any
+	classifier *FieldClassifier
+	root       *Trace
+	stack      []*Trace
+}
+
+// enter starts a trace for n, or returns nil for nodes not recorded.
+func (tr *tracer) enter(n Node) *Trace {
+	t := &Trace{}
+	switch v := n.(type) {
+	case List:
+		_, t.op, _ = v.Head()
+		if tr.classifier != nil {
+			t.path, _ = requestPath(v)
+		}
+	case *Symbol, Str:
+		name, _ := text(v)
+		if _, ok := tr.vars[name]; !ok {
+			return nil
+		}
+	default:
+		return nil
+	}
+	t.Expr = render(n)
+	if len(tr.stack) > 0 {
+		parent := tr.stack[len(tr.stack)-1]
+		parent.Children = append(parent.Children, t)
+	} else if tr.root == nil {
+		tr.root = t
+	}
+	tr.stack = append(tr.stack, t)
+	return t
+}
+
+func (tr *tracer) exit(t *Trace, v any, err error) {
+	if t == nil {
+		return
+	}
+	t.Value = tr.redact(t, v)
+	if err != nil {
+		t.Error = err.Error()
+	}
+	tr.stack = tr.stack[:len(tr.stack)-1]
+}
+
+// redact returns the value to record for t. A get of a classified request
+// field records it redacted or hashed, and so does a get of an object
+// holding one. Any other value computed from such a field is redacted,
+// except a truth value: the outcome of a test is what explains a decision.
+func (tr *tracer) redact(t *Trace, v any) any {
+	c := tr.classifier
+	if c == nil {
+		return v
+	}
+	if t.path != nil {
+		if _, ok := c.sensitivity(t.path); ok || c.below(t.path) {
+			t.sensitive = true
+			return c.redact(t.path, v)
+		}
+		return v
+	}
+	for _, child := range t.Children {
+		if child.sensitive {
+			t.sensitive = true
+			if _, ok := v.(bool); !ok && v != nil {
+				return Redacted
+			}
+			break
+		}
+	}
+	return v
+}
+
+// Render prints n as SPL source on one line, which Parse reads back as n.
+func Render(n Node) string { return render(n) }
+
+// render prints n as SPL source.
+func render(n Node) string {
+	var b strings.Builder
+	var walk func(n Node)
+	walk = func(n Node) {
+		switch v := n.(type) {
+		case List:
+			b.WriteByte('(')
+			for i, c := range v {
+				if i > 0 {
+					b.WriteByte(' ')
+				}
+				walk(c)
+			}
+			b.WriteByte(')')
+		case *Symbol:
+			b.WriteString(v.Name)
+		case Str:
+			// SPL strings have no escapes; Parse never makes one holding
+			// a double quote.
+			b.WriteByte('"')
+			b.WriteString(string(v))
+			b.WriteByte('"')
+		case Bool:
+			if v {
+				b.WriteString("#t")
+			} else {
+				b.WriteString("#f")
+			}
+		case Num:
+			b.WriteString(strconv.FormatFloat(float64(v), 'f', -1, 64))
+		default:
+			fmt.Fprint(&b, v)
+		}
+	}
+	walk(n)
+	return b.String()
+}
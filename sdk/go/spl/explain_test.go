@@ -0,0 +1,63 @@
+package spl
+
+import (
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	ast := mustParse(t, `(and (member (get req "to") family) (<= (get req "amount") limit) (dpop_ok?))`)
+	env := Env{
+		Req:  map[string]any{"to": "mom@example.com", "amount": float64(80)},
+		Vars: map[string]any{"family": []any{"mom@example.com"}, "limit": float64(50)},
+	}
+	ex, err := Explain(ast, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Allow {
+		t.Fatal("expected deny")
+	}
+	allow, gas, _ := VerifyWithGas(ast, env)
+	if allow != ex.Allow || gas != ex.GasUsed {
+		t.Fatalf("Explain (%v, %d) disagrees with VerifyWithGas (%v, %d)", ex.Allow, ex.GasUsed, allow, gas)
+	}
+	if got, want := ex.Failing.Expr, `(<= (get req "amount") limit)`; got != want {
+		t.Fatalf("failing clause %q, want %q", got, want)
+	}
+	if len(ex.Root.Children) != 2 {
+		t.Fatalf("evaluation should stop at the failing conjunct; got %d children", len(ex.Root.Children))
+	}
+	if ex.Root.Children[0].Failing || !ex.Root.Children[1].Failing || !ex.Root.Failing {
+		t.Fatal("failing path not marked")
+	}
+	cmp := ex.Failing.Children
+	if len(cmp) != 2 || cmp[0].Value != float64(80) || cmp[1].Expr != "limit" || cmp[1].Value != float64(50) {
+		t.Fatalf("comparison operands not recorded: %+v", cmp)
+	}
+
+	env.Req["amount"] = float64(20)
+	if ex, _ = Explain(ast, env); ex.Allow {
+		t.Fatal("dpop_ok? should fail closed")
+	}
+	if ex.Failing.Expr != "(dpop_ok?)" {
+		t.Fatalf("failing clause %q", ex.Failing.Expr)
+	}
+}
+
+func TestExplainError(t *testing.T) {
+	ast := mustParse(t, `(and #t (or #f (before (get req "at") "2026-01-01")))`)
+	ex, err := Explain(ast, Env{Req: map[string]any{"at": float64(1)}})
+	if err == nil {
+		t.Fatal("expected an evaluation error")
+	}
+	if ex.Failing == nil || ex.Failing.Expr != `(before (get req "at") "2026-01-01")` || ex.Failing.Error == "" {
+		t.Fatalf("failing clause %+v", ex.Failing)
+	}
+}
+
+func TestExplainAllow(t *testing.T) {
+	ex, err := Explain(mustParse(t, `(or (= (get req "a") 1) (= (get req "a") 2))`), Env{Req: map[string]any{"a": float64(2)}})
+	if err != nil || !ex.Allow || ex.Failing != nil || ex.Root.Failing {
+		t.Fatalf("allow explained as %+v, %v", ex, err)
+	}
+}
@@ -0,0 +1,43 @@
+package spl
+
+import "strings"
+
+// FormatWidth is the line width Format fills before breaking a list.
+const FormatWidth = 80
+
+// Format returns src in canonical layout: an expression that fits within
+// FormatWidth columns stays on one line with single spaces; otherwise its
+// operator stays on the opening line and each argument goes on its own line,
+// indented two spaces, with closing parentheses on the last line. Atoms are
+// kept exactly as written, so formatting never changes what a policy means.
+func Format(src string) (string, error) {
+	forms, err := readSyntax(src)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i, n := range forms {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		formatNode(&b, n, 0)
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+func formatNode(b *strings.Builder, n *syntaxNode, indent int) {
+	flat := n.String()
+	if !n.isList || len(n.list) < 2 || indent+len(flat) <= FormatWidth {
+		b.WriteString(flat)
+		return
+	}
+	b.WriteByte('(')
+	formatNode(b, n.list[0], indent+1)
+	for _, c := range n.list[1:] {
+		b.WriteByte('\n')
+		b.WriteString(strings.Repeat(" ", indent+2))
+		formatNode(b, c, indent+2)
+	}
+	b.WriteByte(')')
+}
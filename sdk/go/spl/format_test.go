@@ -0,0 +1,37 @@
+package spl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	src := "(and   (= (get req \"action\")\n\"pay\") ( <= (get req \"amount\") 50.00 ) (member (get req \"recipient\") allowed_recipients) (dpop_ok?))"
+	want := `(and
+  (= (get req "action") "pay")
+  (<= (get req "amount") 50.00)
+  (member (get req "recipient") allowed_recipients)
+  (dpop_ok?))
+`
+	got, err := Format(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if again, _ := Format(got); again != got {
+		t.Fatalf("Format is not idempotent:\n%s", again)
+	}
+	a, _ := Parse(src)
+	b, _ := Parse(got)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("formatting changed the policy")
+	}
+	if short, _ := Format("  (not\n x)"); short != "(not x)\n" {
+		t.Fatalf("got %q", short)
+	}
+	if _, err := Format("(and x"); err == nil {
+		t.Fatal("expected a syntax error")
+	}
+}
@@ -0,0 +1,130 @@
+package spl
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// fuzzPolicies returns seed policies: the example policy, the shared
+// evaluation vectors and a few shapes the tokenizer finds awkward.
+func fuzzPolicies() []string {
+	seeds := []string{
+		"", "(", ")", "#t", "#f", `"unterminated`, `(= "a\"b" "a\"b")`, "(and)", "(or)",
+		"((((((((((((((((((((#t))))))))))))))))))))", "(get req)", "(tuple 1 2 3)",
+		`(member (get req "recipient") allowed_recipients)`, "(<= 1e308 -1e308)", "(= -0 0)",
+		"; comment\n#t", "(before now \"2025-01-01T00:00:00Z\")", "(per-day-count \"a\" 1)",
+	}
+	if b, err := os.ReadFile("../../../examples/policies/family_gifts.spl"); err == nil {
+		seeds = append(seeds, string(b))
+	}
+	var v struct {
+		Cases []struct{ Policy string } `json:"cases"`
+	}
+	if b, err := os.ReadFile("../../../examples/crypto/eval_vectors.json"); err == nil && json.Unmarshal(b, &v) == nil {
+		for _, c := range v.Cases {
+			seeds = append(seeds, c.Policy)
+		}
+	}
+	return seeds
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range fuzzPolicies() {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		ast, err := Parse(src)
+		// Parsing is deterministic, and a policy within the strict limits
+		// parses the same under them.
+		again, err2 := Parse(src)
+		if (err == nil) != (err2 == nil) || err == nil && render(ast) != render(again) {
+			t.Fatalf("%q parsed differently twice: %v, %v", src, err, err2)
+		}
+		if err != nil {
+			return
+		}
+		if strict, err := ParseLimits(src, Limits{}.Strict()); err == nil && render(strict) != render(ast) {
+			t.Fatalf("%q parsed differently within limits", src)
+		}
+	})
+}
+
+func FuzzEval(f *testing.F) {
+	reqs := []string{`{}`, `{"amount": 50, "recipient": "niece@example.com", "action": "payments.create"}`}
+	if b, err := os.ReadFile("../../../examples/requests/gift_50_niece.json"); err == nil {
+		reqs = append(reqs, string(b))
+	}
+	for _, s := range fuzzPolicies() {
+		for _, r := range reqs {
+			f.Add(s, r)
+		}
+	}
+	f.Fuzz(func(t *testing.T, src, reqJSON string) {
+		ast, err := Parse(src)
+		if err != nil {
+			return
+		}
+		var req map[string]any
+		if json.Unmarshal([]byte(reqJSON), &req) != nil {
+			return
+		}
+		eval := func(memoize bool) (bool, int, string) {
+			env := makeEnv()
+			env.Req, env.MaxGas, env.Memoize = req, 2000, memoize
+			allow, gas, err := VerifyWithGas(ast, env)
+			msg := ""
+			if err != nil {
+				msg = err.Error()
+			}
+			return allow, gas, msg
+		}
+		// Evaluation is deterministic, and memoizing changes no decision.
+		allow, gas, msg := eval(false)
+		if a, g, m := eval(false); a != allow || g != gas || m != msg {
+			t.Fatalf("%q evaluated to %v %d %q, then %v %d %q", src, allow, gas, msg, a, g, m)
+		}
+		if a, _, m := eval(true); a != allow || (m == "") != (msg == "") {
+			t.Fatalf("%q evaluated to %v %q, memoized to %v %q", src, allow, msg, a, m)
+		}
+		if allow && msg != "" {
+			t.Fatalf("%q allowed with an error: %s", src, msg)
+		}
+	})
+}
+
+func FuzzVerifyToken(f *testing.F) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{Expires: "2030-01-01T00:00:00Z"})
+	if err != nil {
+		f.Fatal(err)
+	}
+	js, _ := json.Marshal(tok)
+	compact, err := tok.Compact()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(string(js))
+	f.Add(compact)
+	for _, s := range []string{"", "{}", "null", "[]", `{"version": "0.3.0", "alg": "ES256"}`, `{"policy": 1}`, "a.b.c"} {
+		f.Add(s)
+	}
+	var v struct {
+		Cases []struct{ Token json.RawMessage } `json:"cases"`
+	}
+	if b, err := os.ReadFile("../../../examples/crypto/token_vectors.json"); err == nil && json.Unmarshal(b, &v) == nil {
+		for _, c := range v.Cases {
+			f.Add(string(c.Token))
+		}
+	}
+	f.Fuzz(func(t *testing.T, token string) {
+		opts := VerifyTokenOptions{Now: "2026-01-01T00:00:00Z", Limits: Limits{}.Strict()}
+		res := VerifyToken(token, tokenTestReq(50), opts)
+		if (res.Err == nil) != (res.Code == CodeNone) || res.Allow && res.Err != nil {
+			t.Fatalf("inconsistent result %+v", res)
+		}
+		if t2, err := ParseToken(token); err == nil {
+			VerifyTokenObj(t2, tokenTestReq(50), opts)
+		}
+	})
+}
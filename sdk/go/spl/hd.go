@@ -0,0 +1,186 @@
+package spl
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//go:embed bip39_english.txt
+var bip39English string
+
+var bip39Words = strings.Split(strings.TrimSpace(bip39English), "\n")
+
+var bip39Index = func() map[string]int {
+	m := make(map[string]int, len(bip39Words))
+	for i, w := range bip39Words {
+		m[w] = i
+	}
+	return m
+}()
+
+// NewMnemonic generates a BIP-39 English mnemonic from fresh entropy.
+// entropyBits must be a multiple of 32 between 128 and 256 (12-24 words).
+func NewMnemonic(entropyBits int) (string, error) {
+	if entropyBits%32 != 0 || entropyBits < 128 || entropyBits > 256 {
+		return "", fmt.Errorf("entropy must be 128-256 bits in steps of 32, got %d", entropyBits)
+	}
+	entropy := make([]byte, entropyBits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return MnemonicFromEntropy(entropy)
+}
+
+// MnemonicFromEntropy encodes entropy as a BIP-39 English mnemonic.
+func MnemonicFromEntropy(entropy []byte) (string, error) {
+	n := len(entropy) * 8
+	if n%32 != 0 || n < 128 || n > 256 {
+		return "", fmt.Errorf("entropy must be 128-256 bits in steps of 32, got %d", n)
+	}
+	checksum := sha256.Sum256(entropy)
+	bits := append(append([]byte{}, entropy...), checksum[0])
+	words := make([]string, (n+n/32)/11)
+	for i := range words {
+		words[i] = bip39Words[readBits(bits, i*11, 11)]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// ValidateMnemonic checks word membership, length, and the BIP-39 checksum.
+func ValidateMnemonic(mnemonic string) error {
+	_, err := mnemonicEntropy(mnemonic)
+	return err
+}
+
+// MnemonicToSeed validates a mnemonic and stretches it into the 64-byte
+// BIP-39 seed (PBKDF2-HMAC-SHA512, 2048 rounds). Non-ASCII passphrases must
+// already be NFKD-normalized.
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2SHA512([]byte(normalized), []byte("mnemonic"+passphrase), 2048, 64), nil
+}
+
+func mnemonicEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words)%3 != 0 || len(words) < 12 || len(words) > 24 {
+		return nil, fmt.Errorf("mnemonic must have 12-24 words in steps of 3, got %d", len(words))
+	}
+	bits := make([]byte, (len(words)*11+7)/8)
+	for i, w := range words {
+		idx, ok := bip39Index[w]
+		if !ok {
+			return nil, fmt.Errorf("unknown mnemonic word %q", w)
+		}
+		writeBits(bits, i*11, 11, idx)
+	}
+	checksumBits := len(words) * 11 / 33
+	entropy := bits[:checksumBits*4]
+	checksum := sha256.Sum256(entropy)
+	if readBits(bits, checksumBits*32, checksumBits) != int(checksum[0]>>(8-checksumBits)) {
+		return nil, fmt.Errorf("invalid mnemonic checksum")
+	}
+	return entropy, nil
+}
+
+func readBits(b []byte, off, n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := (b[(off+i)/8] >> (7 - uint((off+i)%8))) & 1
+		v = v<<1 | int(bit)
+	}
+	return v
+}
+
+func writeBits(b []byte, off, n, v int) {
+	for i := 0; i < n; i++ {
+		if v>>(n-1-i)&1 == 1 {
+			b[(off+i)/8] |= 1 << (7 - uint((off+i)%8))
+		}
+	}
+}
+
+// pbkdf2SHA512 implements PBKDF2 (RFC 8018) with HMAC-SHA512.
+func pbkdf2SHA512(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha512.New, password)
+	var out []byte
+	for block := uint32(1); len(out) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		var ctr [4]byte
+		binary.BigEndian.PutUint32(ctr[:], block)
+		prf.Write(ctr[:])
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iter; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
+
+// HDPathPrefix is the root of the agent-safe derivation hierarchy. The
+// trailing component is the scheme version.
+const HDPathPrefix = "m/agent-safe/1"
+
+// ServiceKeyPath returns the derivation path for a service key in a given
+// rotation epoch, e.g. m/agent-safe/1/payments.example.com/epoch-3.
+func ServiceKeyPath(serviceDomain string, epoch int) string {
+	return HDPathPrefix + "/" + serviceDomain + "/epoch-" + strconv.Itoa(epoch)
+}
+
+// DerivePath derives an Ed25519 keypair from a master seed along a
+// slash-separated path rooted at "m". Each component is mixed in with
+// HKDF-SHA256, so any prefix of a path is a parent of every longer path and
+// siblings are unlinkable.
+func DerivePath(masterSeedHex, path string) (publicKeyHex, privateKeyHex string, err error) {
+	key, err := hex.DecodeString(masterSeedHex)
+	if err != nil {
+		return "", "", err
+	}
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return "", "", fmt.Errorf("derivation path must start with m/")
+	}
+	salt := []byte("agent-safe-hd-v1")
+	key = hkdfSHA256(key, salt, []byte("m"), ed25519.SeedSize)
+	for _, p := range parts[1:] {
+		if p == "" {
+			return "", "", fmt.Errorf("empty component in derivation path %q", path)
+		}
+		key = hkdfSHA256(key, salt, []byte(p), ed25519.SeedSize)
+	}
+	priv := ed25519.NewKeyFromSeed(key)
+	pub := priv.Public().(ed25519.PublicKey)
+	return hex.EncodeToString(pub), hex.EncodeToString(key), nil
+}
+
+// DeriveServiceKeyEpoch derives the key for serviceDomain in a rotation
+// epoch. Rotating a service key means bumping the epoch; every past key stays
+// recoverable from the same master seed.
+func DeriveServiceKeyEpoch(masterSeedHex, serviceDomain string, epoch int) (publicKeyHex, privateKeyHex string, err error) {
+	if serviceDomain == "" || strings.Contains(serviceDomain, "/") {
+		return "", "", fmt.Errorf("invalid service domain %q", serviceDomain)
+	}
+	if epoch < 0 {
+		return "", "", fmt.Errorf("epoch must be non-negative")
+	}
+	return DerivePath(masterSeedHex, ServiceKeyPath(serviceDomain, epoch))
+}
@@ -0,0 +1,104 @@
+package spl
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Vectors from the BIP-39 reference test suite (passphrase "TREZOR").
+var bip39Vectors = []struct {
+	entropy, mnemonic, seed string
+}{
+	{
+		"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		"80808080808080808080808080808080",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+	},
+}
+
+func TestBIP39Vectors(t *testing.T) {
+	for _, v := range bip39Vectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+		m, err := MnemonicFromEntropy(entropy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m != v.mnemonic {
+			t.Fatalf("mnemonic mismatch for %s: %s", v.entropy, m)
+		}
+		seed, err := MnemonicToSeed(m, "TREZOR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(seed) != v.seed {
+			t.Fatalf("seed mismatch for %s", v.entropy)
+		}
+	}
+}
+
+func TestValidateMnemonic(t *testing.T) {
+	m, err := NewMnemonic(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateMnemonic(m); err != nil {
+		t.Fatalf("fresh mnemonic invalid: %v", err)
+	}
+	bad := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"
+	if err := ValidateMnemonic(bad); err == nil {
+		t.Fatal("expected checksum failure")
+	}
+	if err := ValidateMnemonic("abandon zzz"); err == nil {
+		t.Fatal("expected word count failure")
+	}
+}
+
+func TestDeriveServiceKeyEpoch(t *testing.T) {
+	seed, _ := MnemonicToSeed(bip39Vectors[0].mnemonic, "")
+	master := hex.EncodeToString(seed)
+
+	pub1, priv1, err := DeriveServiceKeyEpoch(master, "payments.example.com", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub2, _, _ := DerivePath(master, "m/agent-safe/1/payments.example.com/epoch-3")
+	if pub1 != pub2 {
+		t.Fatal("expected epoch helper to match explicit path")
+	}
+	pub3, _, _ := DeriveServiceKeyEpoch(master, "payments.example.com", 4)
+	pub4, _, _ := DeriveServiceKeyEpoch(master, "shop.example.com", 3)
+	if pub1 == pub3 || pub1 == pub4 {
+		t.Fatal("expected distinct keys per epoch and per service")
+	}
+
+	// Derived keys mint verifiable tokens.
+	tok, err := Mint(tokenTestPolicy, priv1, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.PublicKey != pub1 {
+		t.Fatal("expected minted token to use derived key")
+	}
+}
+
+func TestDerivePathRejectsMalformed(t *testing.T) {
+	master := hex.EncodeToString(make([]byte, 64))
+	for _, p := range []string{"agent-safe/1", "m//x", "m/a/"} {
+		if _, _, err := DerivePath(master, p); err == nil {
+			t.Fatalf("expected error for path %q", p)
+		}
+	}
+	if _, _, err := DeriveServiceKeyEpoch(master, "a/b", 0); err == nil {
+		t.Fatal("expected error for domain containing /")
+	}
+}
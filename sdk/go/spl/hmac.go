@@ -0,0 +1,89 @@
+package spl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// AlgHS256 marks a token authenticated with HMAC-SHA256 under a secret
+// shared by issuer and verifier. HMAC tokens skip public-key crypto entirely
+// and support macaroon-style caveats.
+const AlgHS256 = "HS256"
+
+// MinHMACSecretSize is the shortest secret MintHMAC accepts.
+const MinHMACSecretSize = 32
+
+// MintHMAC creates a token authenticated with HMAC-SHA256 instead of a
+// signature. Only holders of secret can mint or verify it, so it suits closed
+// systems where issuer and verifier are the same party. opts.Alg and
+// opts.PQPrivateKey are ignored.
+func MintHMAC(policy string, secret []byte, opts MintOptions) (*Token, error) {
+	if len(secret) < MinHMACSecretSize {
+		return nil, fmt.Errorf("HMAC secret must be at least %d bytes", MinHMACSecretSize)
+	}
+	if err := checkActions(opts.Actions); err != nil {
+		return nil, err
+	}
+	payload := appendActions(SigningPayload(policy, opts.MerkleRoot, opts.HashChainCommitment, opts.Sealed, opts.Expires), opts.Actions)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return &Token{
+		Version:             TokenVersionAlg,
+		Alg:                 AlgHS256,
+		Policy:              policy,
+		Actions:             opts.Actions,
+		MerkleRoot:          opts.MerkleRoot,
+		HashChainCommitment: opts.HashChainCommitment,
+		Sealed:              opts.Sealed,
+		Expires:             opts.Expires,
+		Signature:           hex.EncodeToString(mac.Sum(nil)),
+		PoPKey:              opts.PoPKey,
+	}, nil
+}
+
+// AddCaveat returns a copy of an HMAC token narrowed by an extra policy that
+// must also allow. Anyone holding the token can add caveats without the
+// secret: the new tag is HMAC(previous tag, caveat), so caveats cannot be
+// removed or reordered without invalidating the chain.
+func AddCaveat(t *Token, caveat string) (*Token, error) {
+	if t.Alg != AlgHS256 {
+		return nil, errors.New("caveats require an HMAC token")
+	}
+	if t.Sealed {
+		return nil, errors.New("token is sealed and cannot be attenuated")
+	}
+	if _, err := Parse(caveat); err != nil {
+		return nil, fmt.Errorf("caveat parse error: %w", err)
+	}
+	tag, err := hex.DecodeString(t.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token tag: %w", err)
+	}
+	out := *t
+	out.Caveats = append(append([]string{}, t.Caveats...), caveat)
+	out.Signature = hex.EncodeToString(chainTag(tag, caveat))
+	return &out, nil
+}
+
+func chainTag(tag []byte, caveat string) []byte {
+	mac := hmac.New(sha256.New, tag)
+	mac.Write([]byte(caveat))
+	return mac.Sum(nil)
+}
+
+func verifyHMACChain(t *Token, payload, secret []byte) bool {
+	got, err := decodeHex(t.Signature, sha256.Size)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	tag := mac.Sum(nil)
+	for _, c := range t.Caveats {
+		tag = chainTag(tag, c)
+	}
+	return hmac.Equal(tag, got)
+}
@@ -0,0 +1,76 @@
+package spl
+
+import (
+	"bytes"
+	"testing"
+)
+
+var testHMACSecret = bytes.Repeat([]byte{0x42}, 32)
+
+func TestMintVerifyHMAC(t *testing.T) {
+	tok, err := MintHMAC(tokenTestPolicy, testHMACSecret, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Alg != AlgHS256 || tok.PublicKey != "" {
+		t.Fatalf("unexpected HMAC token shape: %+v", tok)
+	}
+	opts := VerifyTokenOptions{HMACSecret: testHMACSecret}
+	if r := VerifyToken(mustJSON(t, tok), tokenTestReq(50), opts); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+	if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{HMACSecret: bytes.Repeat([]byte{1}, 32)}); r.Allow {
+		t.Fatal("expected deny under the wrong secret")
+	}
+	if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{}); r.Allow || r.ErrorMessage() != "HMAC token requires a shared secret" {
+		t.Fatalf("expected missing secret error, got %+v", r)
+	}
+}
+
+func TestMintHMACRejectsShortSecret(t *testing.T) {
+	if _, err := MintHMAC(tokenTestPolicy, []byte("short"), MintOptions{}); err == nil {
+		t.Fatal("expected error for short secret")
+	}
+}
+
+func TestHMACCaveatChaining(t *testing.T) {
+	tok, _ := MintHMAC(tokenTestPolicy, testHMACSecret, MintOptions{})
+	narrowed, err := AddCaveat(tok, `(<= (get req "amount") 25)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := VerifyTokenOptions{HMACSecret: testHMACSecret}
+	if r := VerifyTokenObj(narrowed, tokenTestReq(20), opts); !r.Allow {
+		t.Fatalf("expected allow under caveat, got %q", r.ErrorMessage())
+	}
+	if r := VerifyTokenObj(narrowed, tokenTestReq(50), opts); r.Allow {
+		t.Fatal("expected caveat to deny amount 50")
+	}
+	// The parent is unaffected.
+	if r := VerifyTokenObj(tok, tokenTestReq(50), opts); !r.Allow {
+		t.Fatal("expected parent token to still allow amount 50")
+	}
+
+	// Stripping the caveat invalidates the tag.
+	stripped := *narrowed
+	stripped.Caveats = nil
+	if r := VerifyTokenObj(&stripped, tokenTestReq(50), opts); r.Allow || r.ErrorMessage() != "invalid signature" {
+		t.Fatalf("expected invalid signature after stripping caveat, got %+v", r)
+	}
+}
+
+func TestAddCaveatRestrictions(t *testing.T) {
+	sealed, _ := MintHMAC(tokenTestPolicy, testHMACSecret, MintOptions{Sealed: true})
+	if _, err := AddCaveat(sealed, "#t"); err == nil {
+		t.Fatal("expected sealed token to reject caveats")
+	}
+	_, priv := GenerateKeypair()
+	signed, _ := Mint(tokenTestPolicy, priv, MintOptions{})
+	if _, err := AddCaveat(signed, "#t"); err == nil {
+		t.Fatal("expected Ed25519 token to reject caveats")
+	}
+	signed.Caveats = []string{"#f"}
+	if r := VerifyTokenObj(signed, tokenTestReq(50), VerifyTokenOptions{}); r.Allow {
+		t.Fatal("expected caveats on a signed token to be rejected")
+	}
+}
@@ -0,0 +1,192 @@
+package spl
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Token delivery encryption is HPKE (RFC 9180) base mode with
+// DHKEM(X25519, HKDF-SHA256), HKDF-SHA256 and AES-128-GCM. The envelope is
+// the KEM encapsulation followed by the ciphertext, the same layout as the
+// single-shot Seal of other HPKE libraries, base64url-encoded behind a
+// version prefix so it survives email, QR codes and chat.
+const (
+	hpkeKEMX25519 = 0x0020
+	hpkeKDFSHA256 = 0x0001
+	hpkeAESGCM128 = 0x0001
+
+	envelopePrefix = "ashpke1."
+)
+
+var hpkeInfo = []byte("agent-safe token delivery v1")
+
+// GenerateX25519Keypair creates a keypair for receiving encrypted tokens.
+func GenerateX25519Keypair() (publicKeyHex, privateKeyHex string, err error) {
+	k, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(k.PublicKey().Bytes()), hex.EncodeToString(k.Bytes()), nil
+}
+
+// EncryptForRecipient encrypts a token to a recipient's X25519 public key so
+// it can travel over untrusted channels. Only the holder of the matching
+// private key can recover it with DecryptToken.
+func EncryptForRecipient(t *Token, recipientPublicKeyHex string) (string, error) {
+	pkR, err := decodeHex(recipientPublicKeyHex, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid recipient key: %w", err)
+	}
+	pub, err := ecdh.X25519().NewPublicKey(pkR)
+	if err != nil {
+		return "", fmt.Errorf("invalid recipient key: %w", err)
+	}
+	plaintext, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := hpkeSeal(pub, ephemeral, hpkeInfo, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return envelopePrefix + b64url.EncodeToString(sealed), nil
+}
+
+// DecryptToken recovers a token produced by EncryptForRecipient. The token
+// still has to be verified; decryption only proves it was addressed to us.
+func DecryptToken(envelope, recipientPrivateKeyHex string) (*Token, error) {
+	enc, ok := strings.CutPrefix(envelope, envelopePrefix)
+	if !ok {
+		return nil, errors.New("not an encrypted token envelope")
+	}
+	sealed, err := b64url.DecodeString(enc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope encoding: %w", err)
+	}
+	skR, err := decodeHex(recipientPrivateKeyHex, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient key: %w", err)
+	}
+	priv, err := ecdh.X25519().NewPrivateKey(skR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient key: %w", err)
+	}
+	plaintext, err := hpkeOpen(priv, hpkeInfo, sealed)
+	if err != nil {
+		return nil, err
+	}
+	var t Token
+	if err := json.Unmarshal(plaintext, &t); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	return &t, nil
+}
+
+func hpkeSeal(pkR *ecdh.PublicKey, skE *ecdh.PrivateKey, info, plaintext []byte) ([]byte, error) {
+	dh, err := skE.ECDH(pkR)
+	if err != nil {
+		return nil, err
+	}
+	enc := skE.PublicKey().Bytes()
+	aead, nonce, err := hpkeKeySchedule(dh, enc, pkR.Bytes(), info)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(enc, nonce, plaintext, nil), nil
+}
+
+func hpkeOpen(skR *ecdh.PrivateKey, info, sealed []byte) ([]byte, error) {
+	if len(sealed) < 32 {
+		return nil, errors.New("envelope too short")
+	}
+	enc, ct := sealed[:32], sealed[32:]
+	pkE, err := ecdh.X25519().NewPublicKey(enc)
+	if err != nil {
+		return nil, err
+	}
+	dh, err := skR.ECDH(pkE)
+	if err != nil {
+		return nil, err
+	}
+	aead, nonce, err := hpkeKeySchedule(dh, enc, skR.PublicKey().Bytes(), info)
+	if err != nil {
+		return nil, err
+	}
+	pt, err := aead.Open(nil, nonce, ct, nil)
+	if err != nil {
+		return nil, errors.New("envelope decryption failed")
+	}
+	return pt, nil
+}
+
+// hpkeKeySchedule derives the base-mode AEAD key and nonce (RFC 9180 §4.1,
+// §5.1) from the DH output. Only the first message (sequence 0) is used.
+func hpkeKeySchedule(dh, enc, pkR, info []byte) (cipher.AEAD, []byte, error) {
+	kemSuite := binary.BigEndian.AppendUint16([]byte("KEM"), hpkeKEMX25519)
+	kemContext := append(append([]byte{}, enc...), pkR...)
+	eaePRK := labeledExtract(kemSuite, nil, "eae_prk", dh)
+	shared := labeledExpand(kemSuite, eaePRK, "shared_secret", kemContext, 32)
+
+	suite := []byte("HPKE")
+	for _, id := range []uint16{hpkeKEMX25519, hpkeKDFSHA256, hpkeAESGCM128} {
+		suite = binary.BigEndian.AppendUint16(suite, id)
+	}
+	ctx := []byte{0} // mode_base
+	ctx = append(ctx, labeledExtract(suite, nil, "psk_id_hash", nil)...)
+	ctx = append(ctx, labeledExtract(suite, nil, "info_hash", info)...)
+	secret := labeledExtract(suite, shared, "secret", nil)
+	key := labeledExpand(suite, secret, "key", ctx, 16)
+	nonce := labeledExpand(suite, secret, "base_nonce", ctx, 12)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, nonce, err
+}
+
+func labeledExtract(suite, salt []byte, label string, ikm []byte) []byte {
+	if len(salt) == 0 {
+		salt = make([]byte, sha256.Size)
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte("HPKE-v1"))
+	mac.Write(suite)
+	mac.Write([]byte(label))
+	mac.Write(ikm)
+	return mac.Sum(nil)
+}
+
+func labeledExpand(suite, prk []byte, label string, info []byte, length int) []byte {
+	labeled := binary.BigEndian.AppendUint16(nil, uint16(length))
+	labeled = append(labeled, "HPKE-v1"...)
+	labeled = append(labeled, suite...)
+	labeled = append(labeled, label...)
+	labeled = append(labeled, info...)
+
+	var out, prev []byte
+	for i := byte(1); len(out) < length; i++ {
+		mac := hmac.New(sha256.New, prk)
+		mac.Write(prev)
+		mac.Write(labeled)
+		mac.Write([]byte{i})
+		prev = mac.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:length]
+}
@@ -0,0 +1,40 @@
+//go:build go1.26
+
+package spl
+
+import (
+	"crypto/ecdh"
+	"crypto/hpke"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Envelopes interoperate with the standard library's HPKE in both directions.
+func TestEnvelopeMatchesStdlibHPKE(t *testing.T) {
+	pubHex, privHex, _ := GenerateX25519Keypair()
+	raw, _ := hex.DecodeString(privHex)
+	priv, _ := ecdh.X25519().NewPrivateKey(raw)
+	sk, err := hpke.NewDHKEMPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, _ := hpke.NewDHKEMPublicKey(priv.PublicKey())
+
+	_, signer := GenerateKeypair()
+	tok, _ := Mint(tokenTestPolicy, signer, MintOptions{})
+	env, _ := EncryptForRecipient(tok, pubHex)
+	sealed, _ := b64url.DecodeString(strings.TrimPrefix(env, envelopePrefix))
+	if _, err := hpke.Open(sk, hpke.HKDFSHA256(), hpke.AES128GCM(), hpkeInfo, sealed); err != nil {
+		t.Fatalf("stdlib could not open envelope: %v", err)
+	}
+
+	sealed, err = hpke.Seal(pk, hpke.HKDFSHA256(), hpke.AES128GCM(), hpkeInfo, []byte(mustJSON(t, tok)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecryptToken(envelopePrefix+b64url.EncodeToString(sealed), privHex)
+	if err != nil || got.Signature != tok.Signature {
+		t.Fatalf("could not open stdlib envelope: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package spl
+
+import (
+	"crypto/ecdh"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestEncryptForRecipientRoundTrip(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{})
+	recipientPub, recipientPriv, err := GenerateX25519Keypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := EncryptForRecipient(tok, recipientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(env, tok.Signature) || strings.Contains(env, "amount") {
+		t.Fatal("envelope leaks token contents")
+	}
+	got, err := DecryptToken(env, recipientPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := VerifyTokenObj(got, tokenTestReq(50), VerifyTokenOptions{}); !r.Allow {
+		t.Fatalf("expected decrypted token to verify, got %q", r.ErrorMessage())
+	}
+
+	_, otherPriv, _ := GenerateX25519Keypair()
+	if _, err := DecryptToken(env, otherPriv); err == nil {
+		t.Fatal("expected decryption with the wrong key to fail")
+	}
+	tampered := env[:len(env)-2] + "AA"
+	if tampered == env {
+		tampered = env[:len(env)-2] + "BB"
+	}
+	if _, err := DecryptToken(tampered, recipientPriv); err == nil {
+		t.Fatal("expected tampered envelope to fail")
+	}
+}
+
+// RFC 9180 Appendix A.1.1: DHKEM(X25519, HKDF-SHA256), HKDF-SHA256,
+// AES-128-GCM, base mode, sequence number 0.
+func TestHPKEVector(t *testing.T) {
+	mustHex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	skE, _ := ecdh.X25519().NewPrivateKey(mustHex("52c4a758a802cd8b936eceea314432798d5baf2d7e9235dc084ab1b9cfa2f736"))
+	skR, _ := ecdh.X25519().NewPrivateKey(mustHex("4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8"))
+	info := mustHex("4f6465206f6e2061204772656369616e2055726e")
+	pt := mustHex("4265617574792069732074727574682c20747275746820626561757479")
+	aad := mustHex("436f756e742d30")
+	want := "f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a"
+
+	dh, _ := skE.ECDH(skR.PublicKey())
+	aead, nonce, err := hpkeKeySchedule(dh, skE.PublicKey().Bytes(), skR.PublicKey().Bytes(), info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(aead.Seal(nil, nonce, pt, aad)); got != want {
+		t.Fatalf("ciphertext mismatch:\n got %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,158 @@
+package spl
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// KeyResolver decides which issuer key a token must verify against, making
+// it the verifier's trust anchor. It returns the hex public key to use, or an
+// error if the token's issuer is not trusted at time at.
+type KeyResolver interface {
+	ResolveKey(t *Token, at time.Time) (publicKeyHex string, err error)
+}
+
+// KeyRingEntry is one issuer key and its validity window. PrivateKey is
+// empty for verify-only entries.
+type KeyRingEntry struct {
+	ID         string // defaults to the RFC 7638 JWK thumbprint
+	Alg        string
+	PublicKey  string
+	PrivateKey string
+	NotBefore  time.Time // zero means no lower bound
+	NotAfter   time.Time // zero means no upper bound
+}
+
+func (e *KeyRingEntry) validAt(at time.Time) bool {
+	return (e.NotBefore.IsZero() || !at.Before(e.NotBefore)) &&
+		(e.NotAfter.IsZero() || at.Before(e.NotAfter))
+}
+
+// KeyRing manages the current issuer key plus previous keys that remain
+// valid for verification during a rotation overlap. It is safe for
+// concurrent use and implements KeyResolver.
+type KeyRing struct {
+	mu      sync.RWMutex
+	entries []KeyRingEntry
+}
+
+// NewKeyRing returns a key ring holding entries, oldest first.
+func NewKeyRing(entries ...KeyRingEntry) (*KeyRing, error) {
+	kr := &KeyRing{}
+	for _, e := range entries {
+		if err := kr.Add(e); err != nil {
+			return nil, err
+		}
+	}
+	return kr, nil
+}
+
+// Add appends an entry, deriving its public key and ID when omitted.
+func (kr *KeyRing) Add(e KeyRingEntry) error {
+	if e.PrivateKey != "" {
+		pub, _, err := publicFromPrivate(e.Alg, e.PrivateKey)
+		if err != nil {
+			return err
+		}
+		if e.PublicKey != "" && e.PublicKey != pub {
+			return errors.New("key ring entry: private key does not match public key")
+		}
+		e.PublicKey = pub
+	}
+	if e.PublicKey == "" {
+		return errors.New("key ring entry: public key required")
+	}
+	if e.ID == "" {
+		j, err := KeyToJWK(e.Alg, e.PublicKey, "")
+		if err != nil {
+			return err
+		}
+		e.ID = j.Kid
+	}
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for _, x := range kr.entries {
+		if x.ID == e.ID {
+			return fmt.Errorf("key ring entry %s already present", e.ID)
+		}
+	}
+	kr.entries = append(kr.entries, e)
+	return nil
+}
+
+// Rotate makes a new signing key current from now on. Every previously
+// valid key keeps verifying until now+overlap, which should be at least the
+// longest token lifetime so tokens minted before the rotation verify until
+// they expire.
+func (kr *KeyRing) Rotate(alg, privateKeyHex string, now time.Time, overlap time.Duration) (KeyRingEntry, error) {
+	next := KeyRingEntry{Alg: alg, PrivateKey: privateKeyHex, NotBefore: now}
+	if err := kr.Add(next); err != nil {
+		return KeyRingEntry{}, err
+	}
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	cutoff := now.Add(overlap)
+	last := len(kr.entries) - 1
+	for i := range kr.entries[:last] {
+		e := &kr.entries[i]
+		if e.NotAfter.IsZero() || e.NotAfter.After(cutoff) {
+			e.NotAfter = cutoff
+		}
+	}
+	return kr.entries[last], nil
+}
+
+// Current returns the newest entry with a private key that is valid at now.
+func (kr *KeyRing) Current(now time.Time) (KeyRingEntry, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	for i := len(kr.entries) - 1; i >= 0; i-- {
+		e := kr.entries[i]
+		if e.PrivateKey != "" && e.validAt(now) {
+			return e, nil
+		}
+	}
+	return KeyRingEntry{}, errors.New("key ring has no current signing key")
+}
+
+// Mint signs a token with the current key and stamps it with the key's ID.
+// opts.Alg is taken from the key.
+func (kr *KeyRing) Mint(policy string, opts MintOptions) (*Token, error) {
+	e, err := kr.Current(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	opts.Alg = e.Alg
+	t, err := Mint(policy, e.PrivateKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	t.KeyID = e.ID
+	return t, nil
+}
+
+// Entries returns a snapshot of the ring, oldest first.
+func (kr *KeyRing) Entries() []KeyRingEntry {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return append([]KeyRingEntry(nil), kr.entries...)
+}
+
+// ResolveKey finds the token's key by kid, or by public key when the token
+// has no kid, and checks the key's validity window.
+func (kr *KeyRing) ResolveKey(t *Token, at time.Time) (string, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	for _, e := range kr.entries {
+		if t.KeyID != "" && e.ID != t.KeyID || t.KeyID == "" && e.PublicKey != t.PublicKey {
+			continue
+		}
+		if !e.validAt(at) {
+			return "", fmt.Errorf("key %s not valid at %s", e.ID, at.Format(time.RFC3339))
+		}
+		return e.PublicKey, nil
+	}
+	return "", errors.New("key not in key ring")
+}
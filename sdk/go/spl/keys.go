@@ -0,0 +1,283 @@
+package spl
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+)
+
+// JWK is a JSON Web Key (RFC 7517) holding an Ed25519 (OKP, RFC 8037) or
+// P-256 (EC) key. D is present only for private keys.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	D   string `json:"d,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+var b64url = base64.RawURLEncoding
+
+// KeyToJWK converts a hex keypair for alg into a JWK. privateKeyHex may be
+// empty to export only the public key. The kid is the RFC 7638 thumbprint.
+func KeyToJWK(alg, publicKeyHex, privateKeyHex string) (*JWK, error) {
+	pub, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key hex: %w", err)
+	}
+	var j JWK
+	switch alg {
+	case "", AlgEd25519:
+		if len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(pub))
+		}
+		j = JWK{Kty: "OKP", Crv: "Ed25519", X: b64url.EncodeToString(pub), Alg: "EdDSA"}
+	case AlgES256:
+		if _, err := p256PublicKey(pub); err != nil {
+			return nil, err
+		}
+		j = JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   b64url.EncodeToString(pub[1 : 1+p256ScalarSize]),
+			Y:   b64url.EncodeToString(pub[1+p256ScalarSize:]),
+			Alg: AlgES256,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+	if privateKeyHex != "" {
+		derivedPub, priv, err := publicFromPrivate(alg, privateKeyHex)
+		if err != nil {
+			return nil, err
+		}
+		if derivedPub != publicKeyHex {
+			return nil, fmt.Errorf("private key does not match public key")
+		}
+		j.D = b64url.EncodeToString(priv)
+	}
+	j.Kid = j.Thumbprint()
+	return &j, nil
+}
+
+// KeyFromJWK converts a JWK back into the hex encoding used by Mint and
+// VerifyToken. privateKeyHex is empty when the JWK has no private part.
+func KeyFromJWK(j *JWK) (alg, publicKeyHex, privateKeyHex string, err error) {
+	x, err := b64url.DecodeString(j.X)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid JWK x: %w", err)
+	}
+	switch {
+	case j.Kty == "OKP" && j.Crv == "Ed25519":
+		if len(x) != ed25519.PublicKeySize {
+			return "", "", "", fmt.Errorf("invalid Ed25519 JWK x length %d", len(x))
+		}
+		alg, publicKeyHex = AlgEd25519, hex.EncodeToString(x)
+	case j.Kty == "EC" && j.Crv == "P-256":
+		y, err := b64url.DecodeString(j.Y)
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid JWK y: %w", err)
+		}
+		if len(x) != p256ScalarSize || len(y) != p256ScalarSize {
+			return "", "", "", fmt.Errorf("invalid P-256 JWK coordinate length")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := p256PublicKey(point); err != nil {
+			return "", "", "", err
+		}
+		alg, publicKeyHex = AlgES256, hex.EncodeToString(point)
+	default:
+		return "", "", "", fmt.Errorf("unsupported JWK kty/crv: %s/%s", j.Kty, j.Crv)
+	}
+	if j.D == "" {
+		return alg, publicKeyHex, "", nil
+	}
+	d, err := b64url.DecodeString(j.D)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid JWK d: %w", err)
+	}
+	privateKeyHex = hex.EncodeToString(d)
+	derivedPub, _, err := publicFromPrivate(alg, privateKeyHex)
+	if err != nil {
+		return "", "", "", err
+	}
+	if derivedPub != publicKeyHex {
+		return "", "", "", fmt.Errorf("JWK private key does not match public key")
+	}
+	return alg, publicKeyHex, privateKeyHex, nil
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of the public key,
+// base64url-encoded.
+func (j *JWK) Thumbprint() string {
+	// Required members only, in lexicographic order.
+	var members any
+	if j.Kty == "EC" {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{j.Crv, j.Kty, j.X, j.Y}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X}
+	}
+	b, _ := json.Marshal(members)
+	h := sha256.Sum256(b)
+	return b64url.EncodeToString(h[:])
+}
+
+// PublicKeyToPEM encodes a hex public key as a PKIX "PUBLIC KEY" PEM block.
+func PublicKeyToPEM(alg, publicKeyHex string) (string, error) {
+	pub, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return "", fmt.Errorf("invalid public key hex: %w", err)
+	}
+	var key any
+	switch alg {
+	case "", AlgEd25519:
+		if len(pub) != ed25519.PublicKeySize {
+			return "", fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(pub))
+		}
+		key = ed25519.PublicKey(pub)
+	case AlgES256:
+		if key, err = p256PublicKey(pub); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// PrivateKeyToPEM encodes a hex private key as a PKCS#8 "PRIVATE KEY" PEM block.
+func PrivateKeyToPEM(alg, privateKeyHex string) (string, error) {
+	var key any
+	switch alg {
+	case "", AlgEd25519:
+		seed, err := hex.DecodeString(privateKeyHex)
+		if err != nil {
+			return "", fmt.Errorf("invalid private key hex: %w", err)
+		}
+		if len(seed) != ed25519.SeedSize {
+			return "", fmt.Errorf("private key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+		}
+		key = ed25519.NewKeyFromSeed(seed)
+	case AlgES256:
+		priv, err := p256PrivateKey(privateKeyHex)
+		if err != nil {
+			return "", err
+		}
+		key = priv
+	default:
+		return "", fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// KeyFromPEM decodes a PKIX public key or PKCS#8 private key PEM block.
+// For private keys both halves of the pair are returned.
+func KeyFromPEM(data []byte) (alg, publicKeyHex, privateKeyHex string, err error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", "", "", fmt.Errorf("no PEM block found")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return "", "", "", err
+		}
+		alg, publicKeyHex, err = encodePublicKey(key)
+		return alg, publicKeyHex, "", err
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return "", "", "", err
+		}
+		switch k := key.(type) {
+		case ed25519.PrivateKey:
+			return AlgEd25519, hex.EncodeToString(k.Public().(ed25519.PublicKey)), hex.EncodeToString(k.Seed()), nil
+		case *ecdsa.PrivateKey:
+			alg, publicKeyHex, err = encodePublicKey(&k.PublicKey)
+			if err != nil {
+				return "", "", "", err
+			}
+			scalar := make([]byte, p256ScalarSize)
+			k.D.FillBytes(scalar)
+			return alg, publicKeyHex, hex.EncodeToString(scalar), nil
+		default:
+			return "", "", "", fmt.Errorf("unsupported private key type %T", key)
+		}
+	default:
+		return "", "", "", fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
+
+// encodePublicKey encodes a parsed public key in the token's hex format.
+func encodePublicKey(key any) (string, string, error) {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return AlgEd25519, hex.EncodeToString(k), nil
+	case *ecdsa.PublicKey:
+		ek, err := k.ECDH()
+		if err != nil || ek.Curve() != ecdh.P256() {
+			return "", "", fmt.Errorf("unsupported ECDSA curve")
+		}
+		return AlgES256, hex.EncodeToString(ek.Bytes()), nil
+	default:
+		return "", "", fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// publicFromPrivate derives the hex public key for a hex private key and
+// returns the raw private key bytes.
+func publicFromPrivate(alg, privateKeyHex string) (string, []byte, error) {
+	switch alg {
+	case "", AlgEd25519:
+		seed, err := hex.DecodeString(privateKeyHex)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid private key hex: %w", err)
+		}
+		if len(seed) != ed25519.SeedSize {
+			return "", nil, fmt.Errorf("private key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+		}
+		pub := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+		return hex.EncodeToString(pub), seed, nil
+	case AlgES256:
+		priv, err := p256PrivateKey(privateKeyHex)
+		if err != nil {
+			return "", nil, err
+		}
+		_, pub, err := encodePublicKey(&priv.PublicKey)
+		if err != nil {
+			return "", nil, err
+		}
+		scalar := make([]byte, p256ScalarSize)
+		priv.D.FillBytes(scalar)
+		return pub, scalar, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+}
@@ -0,0 +1,83 @@
+package spl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJWKThumbprintRFC8037(t *testing.T) {
+	// RFC 8037 Appendix A.3
+	j := &JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+	if got := j.Thumbprint(); got != "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k" {
+		t.Fatalf("unexpected thumbprint %s", got)
+	}
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgEd25519, AlgES256} {
+		pub, priv, err := GenerateKeypairAlg(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		j, err := KeyToJWK(alg, pub, priv)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		gotAlg, gotPub, gotPriv, err := KeyFromJWK(j)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if gotAlg != alg || gotPub != pub || gotPriv != priv {
+			t.Fatalf("%s: JWK round trip mismatch", alg)
+		}
+
+		pubOnly, err := KeyToJWK(alg, pub, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pubOnly.D != "" || pubOnly.Kid != j.Kid {
+			t.Fatalf("%s: expected public JWK with same kid", alg)
+		}
+	}
+}
+
+func TestJWKMismatchedPrivateKey(t *testing.T) {
+	pub, _ := GenerateKeypair()
+	_, otherPriv := GenerateKeypair()
+	if _, err := KeyToJWK(AlgEd25519, pub, otherPriv); err == nil {
+		t.Fatal("expected error for mismatched keypair")
+	}
+}
+
+func TestPEMRoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgEd25519, AlgES256} {
+		pub, priv, _ := GenerateKeypairAlg(alg)
+
+		pubPEM, err := PublicKeyToPEM(alg, pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(pubPEM, "-----BEGIN PUBLIC KEY-----") {
+			t.Fatalf("%s: unexpected PEM header", alg)
+		}
+		gotAlg, gotPub, gotPriv, err := KeyFromPEM([]byte(pubPEM))
+		if err != nil || gotAlg != alg || gotPub != pub || gotPriv != "" {
+			t.Fatalf("%s: public PEM round trip failed: %v", alg, err)
+		}
+
+		privPEM, err := PrivateKeyToPEM(alg, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotAlg, gotPub, gotPriv, err = KeyFromPEM([]byte(privPEM))
+		if err != nil || gotAlg != alg || gotPub != pub || gotPriv != priv {
+			t.Fatalf("%s: private PEM round trip failed: %v", alg, err)
+		}
+	}
+}
+
+func TestKeyFromPEMRejectsGarbage(t *testing.T) {
+	if _, _, _, err := KeyFromPEM([]byte("not pem")); err == nil {
+		t.Fatal("expected error for non-PEM input")
+	}
+}
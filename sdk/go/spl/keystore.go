@@ -0,0 +1,265 @@
+package spl
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// KeyStore is a passphrase-encrypted file of named issuer keys and HD master
+// seeds, so private keys need not live on disk, or in shell history, as
+// plain hex. The file records the KDF parameters next to an AES-256-GCM
+// ciphertext of every entry; the AES key is PBKDF2-HMAC-SHA512 of the
+// passphrase. A KeyStore is not safe for concurrent use.
+type KeyStore struct {
+	path       string
+	passphrase []byte
+	entries    []StoredKey
+}
+
+// Kinds of key store entry.
+const (
+	KeyKindKey  = "key"  // a signing key
+	KeyKindSeed = "seed" // an HD master seed; see DerivePath
+)
+
+// StoredKey is one key store entry. Seed entries hold the master seed in
+// PrivateKey and have no Alg or PublicKey.
+type StoredKey struct {
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"`
+	Alg        string    `json:"alg,omitempty"`
+	PublicKey  string    `json:"public_key,omitempty"`
+	PrivateKey string    `json:"private_key"`
+	Path       string    `json:"path,omitempty"` // derivation path of a derived key
+	Created    time.Time `json:"created"`
+}
+
+type keyStoreFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+const keyStoreKDF = "pbkdf2-hmac-sha512"
+
+// keyStoreIterations is the PBKDF2 work factor for newly saved stores.
+var keyStoreIterations = 210000
+
+// ErrKeyStorePassphrase is returned when a key store cannot be decrypted.
+var ErrKeyStorePassphrase = errors.New("key store: wrong passphrase or corrupted file")
+
+// OpenKeyStore decrypts the key store at path. A missing file yields an
+// empty store that Save creates.
+func OpenKeyStore(path string, passphrase []byte) (*KeyStore, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("key store: empty passphrase")
+	}
+	ks := &KeyStore{path: path, passphrase: append([]byte(nil), passphrase...)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plain, err := openSealedFile(data, passphrase, keyStoreFileKind)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(plain, &ks.entries); err != nil {
+		return nil, fmt.Errorf("key store: %w", err)
+	}
+	return ks, nil
+}
+
+// sealedKind is a kind of passphrase-encrypted file: the key store or the
+// token vault. Its label is bound into the ciphertext, so one kind of file
+// cannot be passed off as the other.
+type sealedKind struct {
+	label string
+	name  string // for errors
+	// wrongPassphrase is returned when the file does not decrypt.
+	wrongPassphrase error
+}
+
+var keyStoreFileKind = sealedKind{"keystore", "key store", ErrKeyStorePassphrase}
+
+// openSealedFile decrypts a file writeSealedFile wrote for the same kind.
+func openSealedFile(data, passphrase []byte, kind sealedKind) ([]byte, error) {
+	var f keyStoreFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", kind.name, err)
+	}
+	if f.Version != 1 || f.KDF != keyStoreKDF || f.Iterations < 1 {
+		return nil, fmt.Errorf("%s: unsupported format (version %d, kdf %q)", kind.name, f.Version, f.KDF)
+	}
+	salt, err1 := hex.DecodeString(f.Salt)
+	nonce, err2 := hex.DecodeString(f.Nonce)
+	ct, err3 := hex.DecodeString(f.Ciphertext)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, fmt.Errorf("%s: %w", kind.name, err)
+	}
+	aead, err := keyStoreAEAD(passphrase, salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, kind.wrongPassphrase
+	}
+	plain, err := aead.Open(nil, nonce, ct, keyStoreAAD(kind.label, &f))
+	if err != nil {
+		return nil, kind.wrongPassphrase
+	}
+	return plain, nil
+}
+
+func keyStoreAEAD(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2SHA512(passphrase, salt, iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyStoreAAD binds the kind of file and the KDF parameters to the
+// ciphertext.
+func keyStoreAAD(label string, f *keyStoreFile) []byte {
+	return []byte("agent-safe " + label + "/" + strconv.Itoa(f.Version) + "/" + f.KDF + "/" + strconv.Itoa(f.Iterations) + "/" + f.Salt)
+}
+
+// Save encrypts the store under a fresh salt and nonce and atomically
+// replaces the file, creating its directory if needed.
+func (ks *KeyStore) Save() error {
+	plain, err := json.Marshal(ks.entries)
+	if err != nil {
+		return err
+	}
+	return writeSealedFile(ks.path, ks.passphrase, keyStoreFileKind, plain)
+}
+
+// writeSealedFile encrypts plain under passphrase, with a fresh salt and
+// nonce, and atomically replaces the file at path with it.
+func writeSealedFile(path string, passphrase []byte, kind sealedKind, plain []byte) error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	f := keyStoreFile{Version: 1, KDF: keyStoreKDF, Iterations: keyStoreIterations, Salt: hex.EncodeToString(salt)}
+	aead, err := keyStoreAEAD(passphrase, salt, f.Iterations)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	f.Nonce = hex.EncodeToString(nonce)
+	f.Ciphertext = hex.EncodeToString(aead.Seal(nil, nonce, plain, keyStoreAAD(kind.label, &f)))
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+kind.label+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Keys returns the entries sorted by name.
+func (ks *KeyStore) Keys() []StoredKey {
+	out := append([]StoredKey(nil), ks.entries...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Get returns the entry called name.
+func (ks *KeyStore) Get(name string) (StoredKey, bool) {
+	for _, k := range ks.entries {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return StoredKey{}, false
+}
+
+// Add validates k and adds it. A key entry's public key is derived from its
+// private key; Created defaults to now. Call Save to persist the change.
+func (ks *KeyStore) Add(k StoredKey) error {
+	if k.Name == "" {
+		return errors.New("key store: name required")
+	}
+	if _, ok := ks.Get(k.Name); ok {
+		return fmt.Errorf("key store: %q already exists", k.Name)
+	}
+	switch k.Kind {
+	case KeyKindKey:
+		if k.Alg == "" {
+			k.Alg = AlgEd25519
+		}
+		pub, _, err := publicFromPrivate(k.Alg, k.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("key store: %q: %w", k.Name, err)
+		}
+		if k.PublicKey != "" && k.PublicKey != pub {
+			return fmt.Errorf("key store: %q: private key does not match public key", k.Name)
+		}
+		k.PublicKey = pub
+	case KeyKindSeed:
+		seed, err := hex.DecodeString(k.PrivateKey)
+		if err != nil || len(seed) < 16 {
+			return fmt.Errorf("key store: %q: seed must be at least 16 bytes of hex", k.Name)
+		}
+		k.Alg, k.PublicKey = "", ""
+	default:
+		return fmt.Errorf("key store: unknown kind %q", k.Kind)
+	}
+	if k.Created.IsZero() {
+		k.Created = time.Now().UTC().Truncate(time.Second)
+	}
+	ks.entries = append(ks.entries, k)
+	return nil
+}
+
+// Derive adds the Ed25519 key for serviceDomain in epoch, derived from the
+// master seed stored as seedName (see DeriveServiceKeyEpoch), under name.
+func (ks *KeyStore) Derive(seedName, name, serviceDomain string, epoch int) (StoredKey, error) {
+	seed, ok := ks.Get(seedName)
+	if !ok || seed.Kind != KeyKindSeed {
+		return StoredKey{}, fmt.Errorf("key store: no seed named %q", seedName)
+	}
+	pub, priv, err := DeriveServiceKeyEpoch(seed.PrivateKey, serviceDomain, epoch)
+	if err != nil {
+		return StoredKey{}, err
+	}
+	k := StoredKey{Name: name, Kind: KeyKindKey, Alg: AlgEd25519, PublicKey: pub, PrivateKey: priv, Path: ServiceKeyPath(serviceDomain, epoch)}
+	if err := ks.Add(k); err != nil {
+		return StoredKey{}, err
+	}
+	k, _ = ks.Get(name)
+	return k, nil
+}
@@ -0,0 +1,74 @@
+package spl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyStore(t *testing.T) {
+	defer func(n int) { keyStoreIterations = n }(keyStoreIterations)
+	keyStoreIterations = 1000
+	path := filepath.Join(t.TempDir(), "sub", "keystore.json")
+	pass := []byte("correct horse")
+
+	ks, err := OpenKeyStore(path, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, priv, _ := GenerateKeypairAlg(AlgES256)
+	if err := ks.Add(StoredKey{Name: "issuer", Kind: KeyKindKey, Alg: AlgES256, PrivateKey: priv}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Add(StoredKey{Name: "master", Kind: KeyKindSeed, PrivateKey: strings.Repeat("ab", 32)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Add(StoredKey{Name: "issuer", Kind: KeyKindSeed, PrivateKey: strings.Repeat("ab", 32)}); err == nil {
+		t.Fatal("duplicate name accepted")
+	}
+	if err := ks.Add(StoredKey{Name: "short", Kind: KeyKindSeed, PrivateKey: "abcd"}); err == nil {
+		t.Fatal("short seed accepted")
+	}
+	derived, err := ks.Derive("master", "payments", "payments.example.com", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPub, _, _ := DeriveServiceKeyEpoch(strings.Repeat("ab", 32), "payments.example.com", 2)
+	if derived.PublicKey != wantPub || derived.Path != ServiceKeyPath("payments.example.com", 2) {
+		t.Fatalf("derived %+v", derived)
+	}
+	if err := ks.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), priv) {
+		t.Fatal("private key stored in plaintext")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm()&0o077 != 0 {
+		t.Fatalf("key store mode %v", info.Mode())
+	}
+
+	ks, err = OpenKeyStore(path, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := ks.Keys()
+	if len(keys) != 3 || keys[0].Name != "issuer" || keys[1].Name != "master" || keys[2].Name != "payments" {
+		t.Fatalf("keys after reopen: %+v", keys)
+	}
+	if k, _ := ks.Get("issuer"); k.PublicKey != pub || k.PrivateKey != priv || k.Created.IsZero() {
+		t.Fatalf("issuer after reopen: %+v", k)
+	}
+
+	if _, err := OpenKeyStore(path, []byte("wrong")); !errors.Is(err, ErrKeyStorePassphrase) {
+		t.Fatalf("wrong passphrase: %v", err)
+	}
+	tampered := strings.Replace(string(data), `"iterations": 1000`, `"iterations": 1001`, 1)
+	os.WriteFile(path, []byte(tampered), 0o600)
+	if _, err := OpenKeyStore(path, pass); !errors.Is(err, ErrKeyStorePassphrase) {
+		t.Fatalf("tampered parameters: %v", err)
+	}
+}
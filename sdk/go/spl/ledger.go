@@ -0,0 +1,93 @@
+package spl
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Money is an amount in a currency as a policy sees it: a number and an
+// ISO 4217 code such as "USD".
+type Money struct {
+	Amount   float64
+	Currency string
+}
+
+// LedgerKey names one budget: what a token, by signature, has spent on an
+// action in a period, such as "2026-10" for a month; see LedgerPeriod.
+type LedgerKey struct {
+	Token, Action, Period string
+}
+
+// SpendLimit bounds a budget's total in Currency: at most Max, or below it
+// when Strict.
+type SpendLimit struct {
+	Max      float64
+	Strict   bool
+	Currency string
+}
+
+// Allows reports whether total is within l.
+func (l SpendLimit) Allows(total float64) bool {
+	if l.Strict {
+		return total < l.Max
+	}
+	return total <= l.Max
+}
+
+// LedgerStore keeps the spend behind sum-amount. It converts between
+// currencies, so a budget can be kept in one currency and spent in others.
+type LedgerStore interface {
+	// Spent returns the spend recorded against k plus pending, the
+	// request's own spend, in currency.
+	Spent(k LedgerKey, pending Money, currency string) (float64, error)
+	// Spend records pending against k and reports true if the total with
+	// it is within limit, or leaves k unchanged and reports false if not,
+	// as one atomic step.
+	Spend(k LedgerKey, pending Money, limit SpendLimit) (bool, error)
+}
+
+// LedgerPeriod returns the calendar period of sum-amount containing now, in
+// UTC: "day" is 2026-10-16, "week" the ISO week 2026-W42, "month" 2026-10
+// and "year" 2026.
+func LedgerPeriod(period string, now time.Time) (string, error) {
+	now = now.UTC()
+	switch period {
+	case "day":
+		return now.Format("2006-01-02"), nil
+	case "week":
+		y, w := now.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w), nil
+	case "month":
+		return now.Format("2006-01"), nil
+	case "year":
+		return now.Format("2006"), nil
+	}
+	return "", fmt.Errorf("invalid period %q: want day, week, month or year", period)
+}
+
+// RequestSpend returns what req spends: its "amount" in its "currency",
+// which defaults to currency. A request without an amount spends nothing.
+func RequestSpend(req map[string]any, currency string) (Money, error) {
+	m := Money{Currency: currency}
+	switch a := req["amount"].(type) {
+	case nil:
+	case float64:
+		m.Amount = a
+	case int:
+		m.Amount = float64(a)
+	default:
+		return Money{}, fmt.Errorf("request amount must be a number")
+	}
+	if m.Amount < 0 || math.IsNaN(m.Amount) || math.IsInf(m.Amount, 0) {
+		return Money{}, fmt.Errorf("request amount must be a non-negative number")
+	}
+	if c, ok := req["currency"]; ok {
+		s, ok := c.(string)
+		if !ok || s == "" {
+			return Money{}, fmt.Errorf("request currency must be a non-empty string")
+		}
+		m.Currency = s
+	}
+	return m, nil
+}
@@ -0,0 +1,79 @@
+package spl
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeLedger keeps spend in one currency and records the limits it is
+// asked to spend within.
+type fakeLedger struct {
+	spent  map[LedgerKey]float64
+	limits []SpendLimit
+}
+
+func (f *fakeLedger) Spent(k LedgerKey, pending Money, _ string) (float64, error) {
+	return f.spent[k] + pending.Amount, nil
+}
+
+func (f *fakeLedger) Spend(k LedgerKey, pending Money, limit SpendLimit) (bool, error) {
+	f.limits = append(f.limits, limit)
+	if !limit.Allows(f.spent[k] + pending.Amount) {
+		return false, nil
+	}
+	f.spent[k] += pending.Amount
+	return true, nil
+}
+
+func TestSumAmount(t *testing.T) {
+	_, priv := GenerateKeypair()
+	now := "2026-10-16T12:00:00Z"
+	for _, tc := range []struct {
+		policy string
+		spent  float64
+		amount any
+		allow  bool
+		limit  SpendLimit
+		err    string
+	}{
+		{`(<= (sum-amount "pay" "month" "USD") 500)`, 400, 100.0, true, SpendLimit{Max: 500, Currency: "USD"}, ""},
+		{`(<= (sum-amount "pay" "month" "USD") 500)`, 400, 100.5, false, SpendLimit{}, ""},
+		{`(< (sum-amount "pay" "month" "USD") 500)`, 0, 20.0, true, SpendLimit{Max: 500, Strict: true, Currency: "USD"}, ""},
+		{`(not (> (sum-amount "pay" "month" "USD") 50))`, 0, 20.0, true, SpendLimit{Max: 50, Currency: "USD"}, ""},
+		{`(>= 500 (sum-amount "pay" "month" "EUR"))`, 0, 20.0, true, SpendLimit{Max: 500, Currency: "EUR"}, ""},
+		{`(<= (sum-amount "pay" "fortnight" "USD") 500)`, 0, 20.0, false, SpendLimit{}, "sum-amount: invalid period"},
+		{`(<= (sum-amount "pay" "month" "USD") 500)`, 0, "lots", false, SpendLimit{}, "sum-amount: request amount must be a number"},
+		{`(<= (sum-amount "pay" "month" "USD") 500)`, 0, -5.0, false, SpendLimit{}, "sum-amount: request amount must be a non-negative"},
+	} {
+		tok, err := Mint(tc.policy, priv, MintOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := &fakeLedger{spent: map[LedgerKey]float64{{tok.Signature, "pay", "2026-10"}: tc.spent}}
+		res := VerifyTokenObj(tok, map[string]any{"amount": tc.amount}, VerifyTokenOptions{Ledger: l, Now: now})
+		if res.Allow != tc.allow || !strings.HasPrefix(res.ErrorMessage(), tc.err) {
+			t.Fatalf("%s with %v: expected %v %q, got %v %q", tc.policy, tc.amount, tc.allow, tc.err, res.Allow, res.ErrorMessage())
+		}
+		if tc.allow && (len(l.limits) != 1 || l.limits[0] != tc.limit) {
+			t.Fatalf("%s: expected limit %+v, got %+v", tc.policy, tc.limit, l.limits)
+		}
+	}
+
+	tok, _ := Mint(`(<= (sum-amount "pay" "month" "USD") 500)`, priv, MintOptions{})
+	if res := VerifyTokenObj(tok, map[string]any{"amount": 1.0}, VerifyTokenOptions{}); res.ErrorMessage() != "sum-amount requires a ledger" {
+		t.Fatalf("expected sum-amount to need a ledger, got %q", res.ErrorMessage())
+	}
+}
+
+func TestLedgerPeriod(t *testing.T) {
+	now := time.Date(2026, 10, 16, 23, 0, 0, 0, time.FixedZone("", -5*3600))
+	for period, want := range map[string]string{"day": "2026-10-17", "week": "2026-W42", "month": "2026-10", "year": "2026"} {
+		if got, err := LedgerPeriod(period, now); err != nil || got != want {
+			t.Fatalf("%s: expected %s, got %s %v", period, want, got, err)
+		}
+	}
+	if _, err := LedgerPeriod("hour", now); err == nil {
+		t.Fatal("expected an unknown period to be refused")
+	}
+}
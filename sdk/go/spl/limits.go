@@ -0,0 +1,86 @@
+package spl
+
+import "fmt"
+
+// Limits bounds the resources a policy may use while it is parsed and
+// evaluated. A zero field takes its value from DefaultLimits, so
+// Limits{MaxGas: 500} changes only the gas budget.
+type Limits struct {
+	MaxPolicyBytes int // source size
+	MaxDepth       int // nesting, counting atoms as one level below their list
+	MaxNodes       int // lists and atoms in the whole policy
+	MaxGas         int // expressions evaluated
+	MaxListLen     int // elements in one list, the operator included
+}
+
+// DefaultLimits are the limits applied when none are given. Any policy of
+// up to MaxPolicyBytes that evaluates within the gas budget fits them.
+var DefaultLimits = Limits{
+	MaxPolicyBytes: MaxPolicyBytes,
+	MaxDepth:       MaxDepth,
+	MaxNodes:       32768,
+	MaxGas:         DefaultMaxGas,
+	MaxListLen:     4096,
+}
+
+// strictLimits suit embedded verifiers and hosts evaluating policies from
+// untrusted issuers.
+var strictLimits = Limits{
+	MaxPolicyBytes: 16384,
+	MaxDepth:       32,
+	MaxNodes:       2048,
+	MaxGas:         2000,
+	MaxListLen:     256,
+}
+
+// Strict returns l tightened to the strict preset: each limit is the lower
+// of l's and the preset's. Limits{}.Strict() is the preset itself: 16 KB,
+// depth 32, 2048 nodes, 2000 gas and lists of 256.
+func (l Limits) Strict() Limits {
+	l = l.withDefaults()
+	return Limits{
+		MaxPolicyBytes: min(l.MaxPolicyBytes, strictLimits.MaxPolicyBytes),
+		MaxDepth:       min(l.MaxDepth, strictLimits.MaxDepth),
+		MaxNodes:       min(l.MaxNodes, strictLimits.MaxNodes),
+		MaxGas:         min(l.MaxGas, strictLimits.MaxGas),
+		MaxListLen:     min(l.MaxListLen, strictLimits.MaxListLen),
+	}
+}
+
+// withDefaults fills l's zero fields from DefaultLimits.
+func (l Limits) withDefaults() Limits {
+	def := func(v, d int) int {
+		if v == 0 {
+			return d
+		}
+		return v
+	}
+	return Limits{
+		MaxPolicyBytes: def(l.MaxPolicyBytes, DefaultLimits.MaxPolicyBytes),
+		MaxDepth:       def(l.MaxDepth, DefaultLimits.MaxDepth),
+		MaxNodes:       def(l.MaxNodes, DefaultLimits.MaxNodes),
+		MaxGas:         def(l.MaxGas, DefaultLimits.MaxGas),
+		MaxListLen:     def(l.MaxListLen, DefaultLimits.MaxListLen),
+	}
+}
+
+// policyShape measures a parsed policy against the parse limits.
+type policyShape struct {
+	bytes, depth, nodes, list int
+}
+
+// check reports the first parse limit shape exceeds; l must have its
+// defaults filled.
+func (l Limits) check(s policyShape) error {
+	switch {
+	case s.bytes > l.MaxPolicyBytes:
+		return fmt.Errorf("policy exceeds maximum size of %d bytes", l.MaxPolicyBytes)
+	case s.depth > l.MaxDepth:
+		return fmt.Errorf("policy exceeds maximum depth of %d", l.MaxDepth)
+	case s.nodes > l.MaxNodes:
+		return fmt.Errorf("policy exceeds maximum of %d expressions", l.MaxNodes)
+	case s.list > l.MaxListLen:
+		return fmt.Errorf("policy list exceeds maximum length of %d", l.MaxListLen)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package spl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLimitsDefaultsAndStrict(t *testing.T) {
+	if got := (Limits{}).withDefaults(); got != DefaultLimits {
+		t.Fatalf("expected zero limits to take the defaults, got %+v", got)
+	}
+	if got := (Limits{MaxGas: 500}).withDefaults(); got.MaxGas != 500 || got.MaxDepth != MaxDepth {
+		t.Fatalf("expected only gas to change, got %+v", got)
+	}
+	if got := (Limits{}).Strict(); got != strictLimits {
+		t.Fatalf("expected the strict preset, got %+v", got)
+	}
+	got := Limits{MaxGas: 100, MaxListLen: 1000}.Strict()
+	if got.MaxGas != 100 || got.MaxListLen != strictLimits.MaxListLen {
+		t.Fatalf("expected the lower of each limit, got %+v", got)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	nested := strings.Repeat("(not ", 5) + "x" + strings.Repeat(")", 5)
+	cases := []struct {
+		src    string
+		limits Limits
+		want   string
+	}{
+		{`(and #t #t)`, Limits{MaxPolicyBytes: 8}, "maximum size of 8 bytes"},
+		{nested, Limits{MaxDepth: 5}, "maximum depth of 5"},
+		{`(and #t #t #t)`, Limits{MaxNodes: 3}, "maximum of 3 expressions"},
+		{`(and #t #t #t)`, Limits{MaxListLen: 3}, "maximum length of 3"},
+	}
+	for _, c := range cases {
+		_, err := ParseLimits(c.src, c.limits)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: expected %q, got %v", c.src, c.want, err)
+		}
+	}
+	// Atoms count one level below their list, as in evaluation.
+	if _, err := ParseLimits(nested, Limits{MaxDepth: 6}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseLimits(`(and #t #t #t)`, Limits{MaxNodes: 5, MaxListLen: 4}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicyCacheLimits(t *testing.T) {
+	c := NewPolicyCache(4)
+	if _, err := c.Parse(`(and #t #t #t)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ParseLimits(`(and #t #t #t)`, Limits{MaxListLen: 3}); err == nil {
+		t.Fatal("expected a cached policy to be checked against stricter limits")
+	}
+	if c.Stats().Hits != 1 {
+		t.Fatalf("expected a hit, got %+v", c.Stats())
+	}
+}
+
+func TestVerifyTokenLimits(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	js := mustJSON(t, tok)
+	if r := VerifyToken(js, tokenTestReq(50), VerifyTokenOptions{Limits: Limits{}.Strict()}); !r.Allow {
+		t.Fatalf("expected allow under strict limits, got %q", r.ErrorMessage())
+	}
+	r := VerifyToken(js, tokenTestReq(50), VerifyTokenOptions{Limits: Limits{MaxGas: 5}})
+	if r.Allow || r.ErrorMessage() != "gas budget exceeded" {
+		t.Fatalf("expected the gas limit to deny, got %+v", r)
+	}
+	r = VerifyToken(js, tokenTestReq(50), VerifyTokenOptions{Limits: Limits{MaxPolicyBytes: 16}})
+	if r.Allow || !strings.Contains(r.ErrorMessage(), "maximum size of 16 bytes") {
+		t.Fatalf("expected the size limit to deny, got %+v", r)
+	}
+	r = VerifyToken(js, tokenTestReq(50), VerifyTokenOptions{Limits: Limits{MaxDepth: 2}})
+	if r.Allow || !strings.Contains(r.ErrorMessage(), "maximum depth of 2") {
+		t.Fatalf("expected the depth limit to deny, got %+v", r)
+	}
+}
@@ -0,0 +1,269 @@
+package spl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity ranks a lint diagnostic.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity parses "info", "warning" or "error".
+func ParseSeverity(s string) (Severity, error) {
+	for i, name := range severityNames {
+		if s == name {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q", s)
+}
+
+func (s Severity) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+func (s *Severity) UnmarshalText(b []byte) error {
+	v, err := ParseSeverity(string(b))
+	*s = v
+	return err
+}
+
+// Diagnostic is one finding reported by Lint.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"` // stable identifier, e.g. "unknown-op"
+	Message  string   `json:"message"`
+	Pos      Position `json:"pos"`
+	Expr     string   `json:"expr,omitempty"` // the offending expression, canonically spaced
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", d.Pos, d.Severity, d.Message, d.Code)
+}
+
+// opArity is the number of arguments each built-in accepts; max < 0 means
+// any number.
+var opArity = map[string]struct{ min, max int }{
+	"and": {0, -1}, "or": {0, -1}, "not": {1, 1},
+	"=": {2, 2}, "<": {2, 2}, "<=": {2, 2}, ">": {2, 2}, ">=": {2, 2},
+	"member": {2, 2}, "in": {2, 2}, "subset?": {2, 2}, "bloom-member?": {2, 2},
+	"before": {2, 2}, "get": {2, 2}, "tuple": {0, -1}, "per-day-count": {2, 2},
+	"dpop_ok?": {0, 0}, "merkle_ok?": {1, 1}, "vrf_ok?": {2, 2},
+	"thresh_ok?": {0, 0}, "attested_ok?": {0, 0}, "range_ok?": {3, 3},
+	"approval_ok?": {1, 1}, "window-count": {2, 2}, "bucket-ok?": {3, 3},
+	"sum-amount": {3, 3}, "not-member": {2, 2},
+	"merkle-member?": {1, 2},
+}
+
+// boolOps are the built-ins that always return a boolean.
+var boolOps = map[string]bool{
+	"and": true, "or": true, "not": true,
+	"=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"member": true, "in": true, "subset?": true, "bloom-member?": true, "before": true,
+	"dpop_ok?": true, "merkle_ok?": true, "vrf_ok?": true,
+	"thresh_ok?": true, "attested_ok?": true, "range_ok?": true,
+	"approval_ok?": true, "bucket-ok?": true, "not-member": true,
+	"merkle-member?": true,
+}
+
+// Lint statically checks policy source and returns its diagnostics in
+// source order. Errors mark policies that cannot evaluate as intended
+// (unknown operators, missing arguments, non-boolean results); warnings mark
+// constructs that are legal but almost certainly mistakes; info notes
+// simplifications. A policy that fails to parse yields a single error.
+func Lint(src string) []Diagnostic {
+	if len(src) > MaxPolicyBytes {
+		return []Diagnostic{{Severity: SeverityError, Code: "too-large", Pos: Position{1, 1},
+			Message: fmt.Sprintf("policy exceeds maximum size of %d bytes", MaxPolicyBytes)}}
+	}
+	forms, err := readSyntax(src)
+	if err == nil && len(forms) == 0 {
+		err = fmt.Errorf("1:1: empty policy")
+	}
+	if err != nil {
+		pos, msg := Position{1, 1}, err.Error()
+		if n, _ := fmt.Sscanf(msg, "%d:%d:", &pos.Line, &pos.Col); n == 2 {
+			msg = msg[strings.Index(msg, ": ")+2:]
+		}
+		return []Diagnostic{{Severity: SeverityError, Code: "syntax", Pos: pos, Message: msg}}
+	}
+	l := &linter{}
+	root := forms[0]
+	l.check(root, 1)
+	switch {
+	case root.atom == "#t":
+		l.report(SeverityWarning, "always-allow", root, "policy always allows")
+	case root.atom == "#f":
+		l.report(SeverityWarning, "always-deny", root, "policy always denies")
+	case isLiteral(root), root.op() == "tuple", root.op() == "per-day-count", root.op() == "window-count", root.op() == "sum-amount":
+		l.report(SeverityError, "non-boolean", root, "policy must evaluate to a boolean")
+	}
+	if l.nodes > DefaultMaxGas {
+		l.report(SeverityWarning, "gas", root, fmt.Sprintf("policy has %d expressions and may exceed the default gas budget of %d", l.nodes, DefaultMaxGas))
+	}
+	for _, extra := range forms[1:] {
+		l.report(SeverityError, "trailing", extra, "expression after the policy is ignored")
+	}
+	return l.diags
+}
+
+type linter struct {
+	diags []Diagnostic
+	nodes int
+}
+
+func (l *linter) report(sev Severity, code string, n *syntaxNode, msg string) {
+	expr := n.String()
+	if len(expr) > 80 {
+		expr = expr[:77] + "..."
+	}
+	l.diags = append(l.diags, Diagnostic{Severity: sev, Code: code, Message: msg, Pos: n.pos, Expr: expr})
+}
+
+func (l *linter) check(n *syntaxNode, depth int) {
+	l.nodes++
+	if depth == MaxDepth+1 {
+		l.report(SeverityError, "depth", n, fmt.Sprintf("nesting exceeds the maximum depth of %d", MaxDepth))
+	}
+	if !n.isList {
+		return
+	}
+	if len(n.list) == 0 {
+		l.report(SeverityError, "empty-list", n, "() evaluates to nil")
+		return
+	}
+	head := n.list[0]
+	args := n.list[1:]
+	op := n.op()
+	known := op != ""
+	if op == "" {
+		l.report(SeverityError, "operator", n, fmt.Sprintf("operator must be a symbol, got %s", head))
+	} else if a, ok := opArity[op]; !ok {
+		known = false
+		l.report(SeverityError, "unknown-op", n, fmt.Sprintf("unknown operator %s", op))
+	} else if len(args) < a.min {
+		known = false
+		l.report(SeverityError, "arity", n, fmt.Sprintf("%s requires %d argument%s, got %d", op, a.min, plural(a.min), len(args)))
+	} else if a.max >= 0 && len(args) > a.max {
+		l.report(SeverityWarning, "arity", n, fmt.Sprintf("%s takes %d argument%s; the rest are ignored", op, a.max, plural(a.max)))
+	}
+	for _, a := range args {
+		l.check(a, depth+1)
+	}
+	if known {
+		l.checkOp(n, op, args)
+	}
+}
+
+func (l *linter) checkOp(n *syntaxNode, op string, args []*syntaxNode) {
+	switch op {
+	case "and", "or":
+		switch len(args) {
+		case 0:
+			l.report(SeverityWarning, "constant", n, fmt.Sprintf("(%s) is always %v", op, op == "and"))
+		case 1:
+			l.report(SeverityInfo, "redundant", n, fmt.Sprintf("%s with one argument can be replaced by the argument", op))
+		}
+		seen := map[string]bool{}
+		for _, a := range args {
+			s := a.String()
+			if seen[s] {
+				l.report(SeverityWarning, "duplicate", a, fmt.Sprintf("duplicate %s operand", op))
+			}
+			seen[s] = true
+			if a.isNumber() || a.isString() || a.op() == "tuple" || a.op() == "per-day-count" || a.op() == "window-count" || a.op() == "sum-amount" {
+				l.report(SeverityWarning, "non-boolean", a, fmt.Sprintf("%s operand is not a boolean and is always truthy", op))
+			}
+		}
+	case "not":
+		if args[0].op() == "not" {
+			l.report(SeverityInfo, "redundant", n, "double negation")
+		}
+		if a := args[0]; (a.op() == "member" || a.op() == "in") && len(a.list) == 3 && a.list[2].isSymbol() {
+			l.report(SeverityWarning, "fail-open", n, fmt.Sprintf("allows when %s is missing; use not-member to deny instead", a.list[2].atom))
+		}
+	case "=", "<", "<=", ">", ">=":
+		if len(args) >= 2 && isLiteral(args[0]) && isLiteral(args[1]) {
+			l.report(SeverityWarning, "constant", n, "comparison of two literals is constant")
+		}
+		if op != "=" {
+			for _, a := range args[:2] {
+				if a.isString() || a.isBool() || a.op() == "tuple" || boolOps[a.op()] {
+					l.report(SeverityWarning, "type", a, fmt.Sprintf("%s compares numbers; this operand is treated as 0", op))
+				}
+			}
+		}
+	case "before":
+		for _, a := range args[:2] {
+			if a.isNumber() || a.isBool() || a.op() == "tuple" || boolOps[a.op()] {
+				l.report(SeverityError, "type", a, "before requires string arguments")
+			}
+		}
+	case "member", "in", "subset?":
+		if a := args[1]; a.isNumber() || a.isBool() || a.isString() || boolOps[a.op()] {
+			l.report(SeverityWarning, "type", a, fmt.Sprintf("%s needs a list; this is always false", op))
+		}
+	case "not-member":
+		if a := args[1]; a.isNumber() || a.isBool() || a.isString() || boolOps[a.op()] {
+			l.report(SeverityError, "type", a, "not-member needs a list; this always fails")
+		}
+	case "bloom-member?":
+		if a := args[1]; isLiteral(a) || a.op() == "tuple" || boolOps[a.op()] {
+			l.report(SeverityError, "type", a, "bloom-member? needs a Bloom filter bound in vars")
+		}
+	case "sum-amount":
+		if p := args[1]; p.isString() {
+			s, _ := strconv.Unquote(p.atom)
+			if _, err := LedgerPeriod(s, time.Time{}); err != nil {
+				l.report(SeverityError, "period", p, err.Error())
+			}
+		} else if p.isNumber() || p.isBool() {
+			l.report(SeverityError, "type", p, "sum-amount requires a period: day, week, month or year")
+		}
+	case "window-count", "bucket-ok?":
+		w := args[len(args)-1]
+		if w.isString() {
+			s, _ := strconv.Unquote(w.atom)
+			if _, err := ParseWindow(s); err != nil {
+				l.report(SeverityError, "window", w, err.Error())
+			}
+		} else if w.isNumber() || w.isBool() {
+			l.report(SeverityError, "type", w, fmt.Sprintf("%s requires a window string such as \"24h\"", op))
+		}
+		if op == "bucket-ok?" {
+			if c := args[1]; c.isNumber() {
+				if f, _ := strconv.ParseFloat(c.atom, 64); f < 1 || f != float64(int(f)) {
+					l.report(SeverityError, "type", c, "bucket-ok? capacity must be a positive integer")
+				}
+			} else if c.isString() || c.isBool() {
+				l.report(SeverityError, "type", c, "bucket-ok? capacity must be a positive integer")
+			}
+		}
+	}
+}
+
+func isLiteral(n *syntaxNode) bool {
+	return n.isNumber() || n.isBool() || n.isString()
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
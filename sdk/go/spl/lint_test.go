@@ -0,0 +1,91 @@
+package spl
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLintCleanPolicy(t *testing.T) {
+	src, err := os.ReadFile("../../../examples/policies/family_gifts.spl")
+	if err != nil {
+		t.Skipf("examples not available: %v", err)
+	}
+	if diags := Lint(string(src)); len(diags) != 0 {
+		t.Fatalf("expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestLintFindings(t *testing.T) {
+	cases := []struct {
+		src  string
+		sev  Severity
+		code string
+		pos  Position
+	}{
+		{`(and (frobnicate 1))`, SeverityError, "unknown-op", Position{1, 6}},
+		{"(and\n  (not))", SeverityError, "arity", Position{2, 3}},
+		{`(not #t #f)`, SeverityWarning, "arity", Position{1, 1}},
+		{`(and (1 2))`, SeverityError, "operator", Position{1, 6}},
+		{`(and ())`, SeverityError, "empty-list", Position{1, 6}},
+		{`(tuple 1 2)`, SeverityError, "non-boolean", Position{1, 1}},
+		{`42`, SeverityError, "non-boolean", Position{1, 1}},
+		{`#t`, SeverityWarning, "always-allow", Position{1, 1}},
+		{`(or)`, SeverityWarning, "constant", Position{1, 1}},
+		{`(and x (= 1 2))`, SeverityWarning, "constant", Position{1, 8}},
+		{`(and x x)`, SeverityWarning, "duplicate", Position{1, 8}},
+		{`(and (get req "a") 7)`, SeverityWarning, "non-boolean", Position{1, 20}},
+		{`(< (get req "a") "10")`, SeverityWarning, "type", Position{1, 18}},
+		{`(before now 5)`, SeverityError, "type", Position{1, 13}},
+		{`(member x 3)`, SeverityWarning, "type", Position{1, 11}},
+		{`(not-member x "blocklist")`, SeverityError, "type", Position{1, 15}},
+		{`(and (not (member (get req "to") blocked)))`, SeverityWarning, "fail-open", Position{1, 6}},
+		{`(bloom-member? x "blocklist")`, SeverityError, "type", Position{1, 18}},
+		{`(< (window-count "pay" "1 day") 3)`, SeverityError, "window", Position{1, 24}},
+		{`(bucket-ok? "search" 2.5 "1m")`, SeverityError, "type", Position{1, 22}},
+		{`(window-count "pay" "24h")`, SeverityError, "non-boolean", Position{1, 1}},
+		{`(<= (sum-amount "pay" "quarter" "USD") 500)`, SeverityError, "period", Position{1, 23}},
+		{`(merkle-member? x y z)`, SeverityWarning, "arity", Position{1, 1}},
+		{`(and x)`, SeverityInfo, "redundant", Position{1, 1}},
+		{`(not (not x))`, SeverityInfo, "redundant", Position{1, 1}},
+		{`(and x) (or y)`, SeverityError, "trailing", Position{1, 9}},
+		{`(and x`, SeverityError, "syntax", Position{1, 1}},
+		{"(and x\n  \"open)", SeverityError, "syntax", Position{2, 3}},
+		{`(and x))`, SeverityError, "syntax", Position{1, 8}},
+		{``, SeverityError, "syntax", Position{1, 1}},
+	}
+	for _, c := range cases {
+		diags := Lint(c.src)
+		found := false
+		for _, d := range diags {
+			if d.Code == c.code && d.Severity == c.sev && d.Pos == c.pos {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%q: expected %s %s at %s, got %v", c.src, c.sev, c.code, c.pos, diags)
+		}
+	}
+}
+
+func TestLintDepthAndSeverity(t *testing.T) {
+	src := strings.Repeat("(not ", MaxDepth+1) + "x" + strings.Repeat(")", MaxDepth+1)
+	var depth bool
+	for _, d := range Lint(src) {
+		depth = depth || d.Code == "depth"
+	}
+	if !depth {
+		t.Fatal("expected a depth error")
+	}
+
+	for _, s := range []Severity{SeverityInfo, SeverityWarning, SeverityError} {
+		b, _ := s.MarshalText()
+		var got Severity
+		if err := got.UnmarshalText(b); err != nil || got != s {
+			t.Fatalf("%s: round trip gave %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Fatal("expected unknown severity to fail")
+	}
+}
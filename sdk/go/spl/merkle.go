@@ -0,0 +1,105 @@
+package spl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// MerkleAllowlist commits to a set of values, such as the recipients a
+// token may pay, without revealing them. The issuer mints the token with
+// Root as MintOptions.MerkleRoot and a policy such as
+// (merkle-member? (get req "recipient")), and gives the agent Witnesses.
+// The agent presents the witness for the value it uses, which verifiers
+// take in VerifyTokenOptions.MerkleWitnesses, so the verifier never holds
+// the list and the list can be of any size.
+type MerkleAllowlist struct {
+	Root string `json:"root"`
+	// Witnesses holds each value's inclusion proof, by value.
+	Witnesses map[string][]MerkleProofStep `json:"witnesses"`
+}
+
+// NewMerkleAllowlist builds the Merkle tree over values in the SPEC's proof
+// format: each leaf is SHA-256 of a value's UTF-8 bytes, with the values
+// sorted and duplicates dropped, and each node SHA-256 of its children. A
+// node without a sibling is carried up a level unchanged. A number is a
+// member in its shortest decimal form, as policies compare it, so list it
+// that way.
+func NewMerkleAllowlist(values []string) (*MerkleAllowlist, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("spl: Merkle allowlist has no values")
+	}
+	leaves := append([]string(nil), values...)
+	sort.Strings(leaves)
+	n := 0
+	for i, v := range leaves {
+		if i == 0 || v != leaves[n-1] {
+			leaves[n] = v
+			n++
+		}
+	}
+	leaves = leaves[:n]
+
+	level := make([][]byte, len(leaves))
+	pos := make([]int, len(leaves)) // each leaf's ancestor in level
+	for i, v := range leaves {
+		level[i], pos[i] = SHA256Hash([]byte(v)), i
+	}
+	w := &MerkleAllowlist{Witnesses: make(map[string][]MerkleProofStep, len(leaves))}
+	for len(level) > 1 {
+		for i, v := range leaves {
+			p := pos[i]
+			if sib := p ^ 1; sib < len(level) {
+				side := "right"
+				if p%2 == 1 {
+					side = "left"
+				}
+				w.Witnesses[v] = append(w.Witnesses[v], MerkleProofStep{Hash: hex.EncodeToString(level[sib]), Position: side})
+			}
+			pos[i] = p / 2
+		}
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			h := sha256.New()
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	for _, v := range leaves {
+		if w.Witnesses[v] == nil {
+			w.Witnesses[v] = []MerkleProofStep{}
+		}
+	}
+	w.Root = hex.EncodeToString(level[0])
+	return w, nil
+}
+
+// proofSteps converts a proof as JSON decodes it, a list of objects with
+// hash and position strings, to its steps.
+func proofSteps(v any) ([]MerkleProofStep, bool) {
+	items, ok := v.([]any)
+	if !ok {
+		return nil, false
+	}
+	steps := make([]MerkleProofStep, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		h, ok1 := m["hash"].(string)
+		p, ok2 := m["position"].(string)
+		if !ok1 || !ok2 {
+			return nil, false
+		}
+		steps[i] = MerkleProofStep{Hash: h, Position: p}
+	}
+	return steps, true
+}
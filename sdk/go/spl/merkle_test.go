@@ -0,0 +1,106 @@
+package spl
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMerkleAllowlist(t *testing.T) {
+	values := []string{"mom@example.com", "niece@example.com", "bob@example.com", "alice@example.com", "carol@example.com", "bob@example.com"}
+	a, err := NewMerkleAllowlist(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Witnesses) != 5 {
+		t.Fatalf("expected 5 distinct values, got %d", len(a.Witnesses))
+	}
+	for v, proof := range a.Witnesses {
+		if !VerifyMerkleProof(v, proof, a.Root) {
+			t.Errorf("%s: witness does not verify", v)
+		}
+		if VerifyMerkleProof(v+".", proof, a.Root) {
+			t.Errorf("%s: witness verifies another value", v)
+		}
+	}
+	// The root commits to the set, not to the order it was listed in.
+	b, _ := NewMerkleAllowlist([]string{"carol@example.com", "alice@example.com", "niece@example.com", "mom@example.com", "bob@example.com"})
+	if b.Root != a.Root {
+		t.Fatal("expected the same root for the same set")
+	}
+
+	one, err := NewMerkleAllowlist([]string{"only"})
+	if err != nil || !VerifyMerkleProof("only", one.Witnesses["only"], one.Root) {
+		t.Fatalf("expected a one-value allowlist to verify, got %v", err)
+	}
+	if _, err := NewMerkleAllowlist(nil); err == nil {
+		t.Fatal("expected an empty allowlist to be refused")
+	}
+}
+
+func TestMerkleMemberToken(t *testing.T) {
+	a, err := NewMerkleAllowlist([]string{"alice", "bob", "carol", "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(merkle-member? (get req "to"))`, priv, MintOptions{MerkleRoot: a.Root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(to any, witnesses map[string][]MerkleProofStep) VerifyTokenResult {
+		return VerifyTokenObj(tok, map[string]any{"to": to}, VerifyTokenOptions{MerkleWitnesses: witnesses})
+	}
+	for _, to := range []any{"bob", 42.0} {
+		key, _ := bloomKey(to)
+		if r := verify(to, map[string][]MerkleProofStep{key: a.Witnesses[key]}); !r.Allow {
+			t.Fatalf("%v: expected allow, got %q", to, r.ErrorMessage())
+		}
+	}
+	// A value with no witness, with another value's witness, or without
+	// a request field is no member.
+	if r := verify("mallory", a.Witnesses); r.Allow || r.Err != nil {
+		t.Fatalf("expected a plain deny for a non-member, got %+v", r)
+	}
+	if r := verify("mallory", map[string][]MerkleProofStep{"mallory": a.Witnesses["bob"]}); r.Allow {
+		t.Fatal("expected a borrowed witness to deny")
+	}
+	if r := verify(nil, a.Witnesses); r.Allow {
+		t.Fatal("expected a missing field to deny")
+	}
+	// A token without a root has no members.
+	bare, _ := Mint(`(merkle-member? (get req "to"))`, priv, MintOptions{})
+	if r := VerifyTokenObj(bare, map[string]any{"to": "bob"}, VerifyTokenOptions{MerkleWitnesses: a.Witnesses}); r.Allow {
+		t.Fatal("expected a token without a Merkle root to deny")
+	}
+}
+
+func TestMerkleMemberProofInRequest(t *testing.T) {
+	a, _ := NewMerkleAllowlist([]string{"alice", "bob", "carol"})
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(merkle-member? (get req "to") (get req "to_proof"))`, priv, MintOptions{MerkleRoot: a.Root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, _ := json.Marshal(a.Witnesses["carol"])
+	decode := func(s string) map[string]any {
+		var req map[string]any
+		if err := json.NewDecoder(strings.NewReader(s)).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	if r := VerifyTokenObj(tok, decode(`{"to": "carol", "to_proof": `+string(proof)+`}`), VerifyTokenOptions{}); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+	for _, req := range []string{
+		`{"to": "alice", "to_proof": ` + string(proof) + `}`,
+		`{"to": "carol", "to_proof": "not a proof"}`,
+		`{"to": "carol", "to_proof": [{"hash": 1}]}`,
+		`{"to": "carol"}`,
+	} {
+		if r := VerifyTokenObj(tok, decode(req), VerifyTokenOptions{}); r.Allow || r.Err != nil {
+			t.Fatalf("%s: expected a plain deny, got %+v", req, r)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+//go:build go1.27
+
+package spl
+
+import (
+	"crypto/mldsa"
+	"encoding/hex"
+	"fmt"
+)
+
+// PQAvailable reports whether this build can mint and verify ML-DSA-65
+// signatures. ML-DSA is provided by the standard library from Go 1.27.
+const PQAvailable = true
+
+// GeneratePQKeypair creates a new ML-DSA-65 keypair.
+// Returns (publicKeyHex, privateKeyHex) where the private key is the 32-byte seed.
+func GeneratePQKeypair() (string, string, error) {
+	sk, err := mldsa.GenerateKey(mldsa.MLDSA65())
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(sk.PublicKey().Bytes()), hex.EncodeToString(sk.Bytes()), nil
+}
+
+// signMLDSA65 signs message with the ML-DSA-65 key derived from seedHex and
+// returns (publicKeyHex, signatureHex).
+func signMLDSA65(message []byte, seedHex string) (string, string, error) {
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid PQ private key hex: %w", err)
+	}
+	sk, err := mldsa.NewPrivateKey(mldsa.MLDSA65(), seed)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid PQ private key: %w", err)
+	}
+	sig, err := sk.Sign(nil, message, &mldsa.Options{})
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(sk.PublicKey().Bytes()), hex.EncodeToString(sig), nil
+}
+
+// VerifyMLDSA65 checks an ML-DSA-65 signature over a message.
+func VerifyMLDSA65(message []byte, signatureHex, publicKeyHex string) bool {
+	sig, err := hex.DecodeString(signatureHex)
+	if err != nil || len(sig) != mldsa.MLDSA65SignatureSize {
+		return false
+	}
+	pubBytes, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return false
+	}
+	pub, err := mldsa.NewPublicKey(mldsa.MLDSA65(), pubBytes)
+	if err != nil {
+		return false
+	}
+	return mldsa.Verify(pub, message, sig, &mldsa.Options{}) == nil
+}
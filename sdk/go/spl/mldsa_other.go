@@ -0,0 +1,27 @@
+//go:build !go1.27
+
+package spl
+
+import "errors"
+
+// PQAvailable reports whether this build can mint and verify ML-DSA-65
+// signatures. ML-DSA is provided by the standard library from Go 1.27.
+const PQAvailable = false
+
+var errPQUnavailable = errors.New("ML-DSA-65 requires building with Go 1.27 or later")
+
+// GeneratePQKeypair creates a new ML-DSA-65 keypair.
+// Returns (publicKeyHex, privateKeyHex) where the private key is the 32-byte seed.
+func GeneratePQKeypair() (string, string, error) {
+	return "", "", errPQUnavailable
+}
+
+func signMLDSA65(_ []byte, _ string) (string, string, error) {
+	return "", "", errPQUnavailable
+}
+
+// VerifyMLDSA65 checks an ML-DSA-65 signature over a message. Always false
+// in builds without ML-DSA support.
+func VerifyMLDSA65(_ []byte, _, _ string) bool {
+	return false
+}
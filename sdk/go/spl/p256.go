@@ -0,0 +1,132 @@
+package spl
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Signature algorithms understood by Mint and VerifyToken.
+const (
+	AlgEd25519 = "Ed25519"
+	AlgES256   = "ES256" // ECDSA over P-256 with SHA-256
+)
+
+// p256ScalarSize is the byte length of a P-256 private scalar and of each
+// half (r, s) of a raw ES256 signature.
+const p256ScalarSize = 32
+
+// GenerateKeypairAlg creates a new keypair for the given signature algorithm.
+// Ed25519 keys use the same encoding as GenerateKeypair. ES256 public keys are
+// hex-encoded uncompressed SEC1 points (65 bytes) and private keys are the
+// hex-encoded 32-byte scalar.
+func GenerateKeypairAlg(alg string) (string, string, error) {
+	switch alg {
+	case "", AlgEd25519:
+		pub, priv := GenerateKeypair()
+		return pub, priv, nil
+	case AlgES256:
+		k, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		return hex.EncodeToString(k.PublicKey().Bytes()), hex.EncodeToString(k.Bytes()), nil
+	default:
+		return "", "", fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+}
+
+// p256PrivateKey decodes a hex P-256 scalar into an ECDSA private key.
+func p256PrivateKey(privateKeyHex string) (*ecdsa.PrivateKey, error) {
+	scalar, err := hex.DecodeString(privateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key hex: %w", err)
+	}
+	if len(scalar) != p256ScalarSize {
+		return nil, fmt.Errorf("private key must be %d bytes, got %d", p256ScalarSize, len(scalar))
+	}
+	k, err := ecdh.P256().NewPrivateKey(scalar)
+	if err != nil {
+		return nil, fmt.Errorf("invalid P-256 private key: %w", err)
+	}
+	pub, err := p256PublicKey(k.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PrivateKey{PublicKey: *pub, D: new(big.Int).SetBytes(scalar)}, nil
+}
+
+// p256PublicKey decodes an uncompressed SEC1 point, rejecting points that are
+// not on the curve.
+func p256PublicKey(point []byte) (*ecdsa.PublicKey, error) {
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("invalid P-256 public key: %w", err)
+	}
+	if len(point) != 1+2*p256ScalarSize {
+		return nil, fmt.Errorf("P-256 public key must be uncompressed")
+	}
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(point[1 : 1+p256ScalarSize]),
+		Y:     new(big.Int).SetBytes(point[1+p256ScalarSize:]),
+	}, nil
+}
+
+// p256RawSignature normalises an ECDSA signature to raw r||s (the WebCrypto /
+// JOSE format). crypto.Signer implementations return ASN.1 DER.
+func p256RawSignature(sig []byte) ([]byte, error) {
+	if len(sig) == 2*p256ScalarSize {
+		return sig, nil
+	}
+	var rs struct{ R, S *big.Int }
+	if rest, err := asn1.Unmarshal(sig, &rs); err != nil || len(rest) > 0 {
+		return nil, errors.New("invalid ECDSA signature encoding")
+	}
+	if rs.R.Sign() <= 0 || rs.S.Sign() <= 0 || rs.R.BitLen() > 8*p256ScalarSize || rs.S.BitLen() > 8*p256ScalarSize {
+		return nil, errors.New("invalid ECDSA signature values")
+	}
+	raw := make([]byte, 2*p256ScalarSize)
+	rs.R.FillBytes(raw[:p256ScalarSize])
+	rs.S.FillBytes(raw[p256ScalarSize:])
+	return raw, nil
+}
+
+// VerifyP256 checks a raw r||s ES256 signature over a message.
+func VerifyP256(message []byte, signatureHex, publicKeyHex string) bool {
+	sig, err := decodeHex(signatureHex, 2*p256ScalarSize)
+	if err != nil {
+		return false
+	}
+	point, err := decodeHex(publicKeyHex, 0)
+	if err != nil {
+		return false
+	}
+	pub, err := p256PublicKey(point)
+	if err != nil {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:p256ScalarSize])
+	s := new(big.Int).SetBytes(sig[p256ScalarSize:])
+	h := sha256.Sum256(message)
+	return ecdsa.Verify(pub, h[:], r, s)
+}
+
+// VerifySignature checks a signature using the named algorithm. An empty
+// algorithm means Ed25519, the default for tokens that predate the alg field.
+func VerifySignature(alg string, message []byte, signatureHex, publicKeyHex string) bool {
+	switch alg {
+	case "", AlgEd25519:
+		return VerifyEd25519(message, signatureHex, publicKeyHex)
+	case AlgES256:
+		return VerifyP256(message, signatureHex, publicKeyHex)
+	default:
+		return false
+	}
+}
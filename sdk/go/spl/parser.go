@@ -1,110 +1,296 @@
 package spl
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
-type Node interface{}
-
 const MaxPolicyBytes = 65536 // 64 KB
 
+// Parse parses policy source within DefaultLimits.
 func Parse(src string) (Node, error) {
-	if len(src) > MaxPolicyBytes {
-		return nil, fmt.Errorf("policy exceeds maximum size of %d bytes", MaxPolicyBytes)
-	}
-	toks := tokenize(src)
-	i := 0
-	var parse func() (Node, error)
-	parse = func() (Node, error) {
-		if i >= len(toks) {
-			return nil, fmt.Errorf("unexpected EOF")
+	n, _, err := parseLimits(src, DefaultLimits)
+	return n, err
+}
+
+// ParseLimits parses policy source within l; zero fields of l take their
+// defaults.
+func ParseLimits(src string, l Limits) (Node, error) {
+	n, _, err := parseLimits(src, l.withDefaults())
+	return n, err
+}
+
+// parseLimits parses src within l, whose defaults must be filled, and
+// reports the policy's shape for PolicyCache to check later limits against.
+func parseLimits(src string, l Limits) (Node, policyShape, error) {
+	if len(src) > l.MaxPolicyBytes {
+		return nil, policyShape{}, fmt.Errorf("policy exceeds maximum size of %d bytes", l.MaxPolicyBytes)
+	}
+	p := parserPool.Get().(*parser)
+	p.src, p.pos, p.open = src, 0, false
+	p.limits, p.depth, p.shape = l, 0, policyShape{bytes: len(src)}
+	n, err := p.parse()
+	shape := p.shape
+	p.src = ""
+	clear(p.shared)
+	clear(p.stack)
+	p.stack = p.stack[:0]
+	parserPool.Put(p)
+	return n, shape, err
+}
+
+var (
+	errEOF          = errors.New("unexpected EOF")
+	errUnterminated = errors.New("unterminated (")
+	errUnexpected   = errors.New("unexpected )")
+)
+
+// parser reads src one token at a time. Tokens are substrings of src, list
+// elements collect on stack until their list is closed, closed lists share
+// larger chunks, and operators are interned, so few allocations remain
+// besides the other atoms.
+type parser struct {
+	src   string
+	pos   int
+	open  bool // an unterminated string was read; the rest splits at spaces
+	stack []Node
+	chunk []Node // backing for finished lists
+	// shared maps the source of each pure get or tuple read so far to its
+	// list, so that repeats of it are one node that Env.Memoize evaluates
+	// once.
+	shared map[string]List
+
+	limits Limits
+	depth  int
+	shape  policyShape
+}
+
+// chunkNodes is the number of list elements allocated at a time.
+const chunkNodes = 128
+
+var parserPool = sync.Pool{New: func() any { return &parser{stack: make([]Node, 0, 64), shared: map[string]List{}} }}
+
+func (p *parser) parse() (Node, error) {
+	tok, ok := p.next()
+	if !ok {
+		return nil, errEOF
+	}
+	p.shape.nodes++
+	if p.shape.nodes > p.limits.MaxNodes {
+		return nil, p.limits.check(p.shape)
+	}
+	if p.depth+1 > p.shape.depth {
+		p.shape.depth = p.depth + 1
+		if p.shape.depth > p.limits.MaxDepth {
+			return nil, p.limits.check(p.shape)
 		}
-		tok := toks[i]
-		i++
-		switch tok {
-		case "(":
-			var arr []Node
-			for {
-				if i >= len(toks) {
-					return nil, fmt.Errorf("unterminated (")
-				}
-				if toks[i] == ")" {
-					i++
-					break
-				}
-				n, err := parse()
-				if err != nil {
-					return nil, err
-				}
-				arr = append(arr, n)
+	}
+	switch tok {
+	case "(":
+		p.depth++
+		start := p.pos - 1
+		base := len(p.stack)
+		for {
+			tok, ok := p.peek()
+			if !ok {
+				return nil, errUnterminated
 			}
-			return arr, nil
-		case ")":
-			return nil, fmt.Errorf("unexpected )")
-		default:
-			if tok == "#t" {
-				return true, nil
+			if tok == ")" {
+				p.next()
+				break
 			}
-			if tok == "#f" {
-				return false, nil
+			n, err := p.parse()
+			if err != nil {
+				return nil, err
 			}
-			if strings.HasPrefix(tok, "\"") && strings.HasSuffix(tok, "\"") {
-				s, err := strconv.Unquote(tok)
-				if err != nil {
-					return nil, err
+			p.stack = append(p.stack, n)
+			if n := len(p.stack) - base; n > p.shape.list {
+				p.shape.list = n
+				if n > p.limits.MaxListLen {
+					return nil, p.limits.check(p.shape)
 				}
-				return s, nil
-			}
-			if n, err := strconv.ParseFloat(tok, 64); err == nil {
-				return n, nil
 			}
-			return tok, nil
 		}
+		var arr List
+		if n := len(p.stack) - base; n > 0 {
+			arr = p.alloc(n)
+			copy(arr, p.stack[base:])
+			clear(p.stack[base:])
+			p.stack = p.stack[:base]
+		}
+		p.depth--
+		return p.share(parseTimeLits(arr), p.src[start:p.pos]), nil
+	case ")":
+		return nil, errUnexpected
 	}
-	return parse()
+	return atom(tok)
 }
 
-func tokenize(src string) []string {
-	var toks []string
-	var buf strings.Builder
-	inStr := false
-	for _, ch := range src {
-		if inStr {
-			buf.WriteRune(ch)
-			if ch == '"' {
-				inStr = false
-				toks = append(toks, buf.String())
-				buf.Reset()
-			}
-			continue
+// share returns the list read earlier from the same source as l, if l is a
+// pure get or tuple, so repeated lookups such as (get req "amount") share
+// one node. Other lists are returned as they are.
+func (p *parser) share(l List, src string) List {
+	if op, _, _ := l.Head(); op != OpGet && op != OpTuple || !pure(l) {
+		return l
+	}
+	if prev, ok := p.shared[src]; ok {
+		return prev
+	}
+	p.shared[src] = l
+	return l
+}
+
+// alloc returns n elements carved from the parser's current chunk, capped
+// so that appending to one list cannot overwrite the next.
+func (p *parser) alloc(n int) []Node {
+	if len(p.chunk)+n > cap(p.chunk) {
+		p.chunk = make([]Node, 0, max(n, chunkNodes))
+	}
+	i := len(p.chunk)
+	p.chunk = p.chunk[:i+n]
+	return p.chunk[i : i+n : i+n]
+}
+
+// atom converts a token other than a parenthesis to its value.
+func atom(tok string) (Node, error) {
+	switch tok {
+	case "#t":
+		return Bool(true), nil
+	case "#f":
+		return Bool(false), nil
+	}
+	if strings.HasPrefix(tok, "\"") && strings.HasSuffix(tok, "\"") {
+		s, err := strconv.Unquote(tok)
+		if err != nil {
+			return nil, err
 		}
-		switch ch {
-		case '(', ')':
-			if buf.Len() > 0 {
-				toks = append(toks, strings.Fields(buf.String())...)
-				buf.Reset()
-			}
-			toks = append(toks, string(ch))
-		case ' ', '\n', '\t', '\r':
-			if buf.Len() > 0 {
-				toks = append(toks, strings.Fields(buf.String())...)
-				buf.Reset()
-			}
-		case '"':
-			if buf.Len() > 0 {
-				toks = append(toks, strings.Fields(buf.String())...)
-				buf.Reset()
+		return Str(s), nil
+	}
+	if numeric(tok) {
+		if n, err := strconv.ParseFloat(tok, 64); err == nil {
+			if n >= 0 && n < float64(len(smallNumbers)) && n == float64(int(n)) && !(n == 0 && strings.HasPrefix(tok, "-")) {
+				return smallNumbers[int(n)], nil
 			}
-			inStr = true
-			buf.WriteRune(ch)
-		default:
-			buf.WriteRune(ch)
+			return Num(n), nil
+		}
+	}
+	return Sym(tok), nil
+}
+
+// numeric reports whether tok could be a number, sparing symbols the
+// allocation of a failed strconv.ParseFloat.
+func numeric(tok string) bool {
+	s := strings.TrimLeft(tok, "+-")
+	if s == "" {
+		return false
+	}
+	if c := s[0]; c >= '0' && c <= '9' || c == '.' {
+		return true
+	}
+	return strings.EqualFold(s, "inf") || strings.EqualFold(s, "infinity") || strings.EqualFold(s, "nan")
+}
+
+// smallNumbers holds the integers 0 to 255 boxed as Nodes.
+var smallNumbers = func() (nums [256]Node) {
+	for i := range nums {
+		nums[i] = Num(i)
+	}
+	return nums
+}()
+
+// peek returns the next token without consuming it.
+func (p *parser) peek() (string, bool) {
+	pos, open := p.pos, p.open
+	tok, ok := p.next()
+	p.pos, p.open = pos, open
+	return tok, ok
+}
+
+// next returns the next token: a parenthesis, a string running to the next
+// double quote, or a run of other non-space characters. From a string left
+// open to the end of src, the text is split at spaces alone.
+func (p *parser) next() (string, bool) {
+	src := p.src
+	i := p.pos
+	for i < len(src) {
+		r, size := rune(src[i]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(src[i:])
+		}
+		if !unicode.IsSpace(r) {
+			break
 		}
+		i += size
+	}
+	if i == len(src) {
+		p.pos = i
+		return "", false
+	}
+	start := i
+	if p.open {
+		i = spaceIndex(src, i)
+		p.pos = i
+		return validUTF8(src[start:i]), true
+	}
+	switch src[i] {
+	case '(', ')':
+		p.pos = i + 1
+		return src[i : i+1], true
+	case '"':
+		if end := strings.IndexByte(src[i+1:], '"'); end >= 0 {
+			p.pos = i + end + 2
+			return validUTF8(src[start:p.pos]), true
+		}
+		p.open = true
+		i = spaceIndex(src, i)
+		p.pos = i
+		return validUTF8(src[start:i]), true
+	}
+	for i < len(src) {
+		c := src[i]
+		if c == '(' || c == ')' || c == '"' {
+			break
+		}
+		r, size := rune(c), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(src[i:])
+		}
+		if unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	p.pos = i
+	return validUTF8(src[start:i]), true
+}
+
+// spaceIndex returns the index of the first space in src at or after i, or
+// len(src).
+func spaceIndex(src string, i int) int {
+	for i < len(src) {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// validUTF8 replaces each invalid byte in tok with U+FFFD, as reading the
+// source rune by rune does.
+func validUTF8(tok string) string {
+	if utf8.ValidString(tok) {
+		return tok
 	}
-	if buf.Len() > 0 {
-		toks = append(toks, strings.Fields(buf.String())...)
+	var b strings.Builder
+	for _, r := range tok {
+		b.WriteRune(r)
 	}
-	return toks
+	return b.String()
 }
@@ -0,0 +1,338 @@
+package spl
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Pedersen commitments and zero-knowledge range proofs over P-256.
+//
+// An agent commits to a request amount v as C = v·G + r·H and proves
+// v <= limit without revealing v. The proof shows that both v and limit-v
+// lie in [0, 2^RangeBits) by committing to each bit and proving every bit
+// commitment opens to 0 or 1 with a Fiat-Shamir OR-proof. Proof size is
+// linear in RangeBits (a Bulletproof would be logarithmic) but needs nothing
+// beyond the standard library.
+
+// RangeBits is the bit width of values a range proof covers.
+const RangeBits = 32
+
+var (
+	pedCurve    = elliptic.P256()
+	pedOrder    = pedCurve.Params().N
+	pedH        = pedersenH()
+	rangeDomain = []byte("agent-safe-range-v1")
+)
+
+type ecPoint struct{ x, y *big.Int }
+
+func (p ecPoint) add(q ecPoint) ecPoint {
+	x, y := pedCurve.Add(p.x, p.y, q.x, q.y)
+	return ecPoint{x, y}
+}
+
+func (p ecPoint) neg() ecPoint {
+	if p.x.Sign() == 0 && p.y.Sign() == 0 {
+		return p
+	}
+	return ecPoint{p.x, new(big.Int).Sub(pedCurve.Params().P, p.y)}
+}
+
+func (p ecPoint) mul(k *big.Int) ecPoint {
+	x, y := pedCurve.ScalarMult(p.x, p.y, scalarBytes(k))
+	return ecPoint{x, y}
+}
+
+func baseMul(k *big.Int) ecPoint {
+	x, y := pedCurve.ScalarBaseMult(scalarBytes(k))
+	return ecPoint{x, y}
+}
+
+func scalarBytes(k *big.Int) []byte {
+	b := make([]byte, 32)
+	new(big.Int).Mod(k, pedOrder).FillBytes(b)
+	return b
+}
+
+func (p ecPoint) encode() string {
+	if p.x.Sign() == 0 && p.y.Sign() == 0 {
+		return "00"
+	}
+	return hex.EncodeToString(elliptic.MarshalCompressed(pedCurve, p.x, p.y))
+}
+
+func decodePoint(s string) (ecPoint, error) {
+	if s == "00" {
+		return ecPoint{new(big.Int), new(big.Int)}, nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return ecPoint{}, err
+	}
+	x, y := elliptic.UnmarshalCompressed(pedCurve, b)
+	if x == nil {
+		return ecPoint{}, errors.New("invalid pedCurve ecPoint")
+	}
+	return ecPoint{x, y}, nil
+}
+
+// pedersenH derives the second generator by hashing to the pedCurve
+// (try-and-increment), so nobody knows log_G(H).
+func pedersenH() ecPoint {
+	p := pedCurve.Params().P
+	three := big.NewInt(3)
+	for ctr := uint32(0); ; ctr++ {
+		var buf [4]byte
+		binary.BigEndian.PutUint32(buf[:], ctr)
+		h := sha256.Sum256(append([]byte("agent-safe-pedersen-H"), buf[:]...))
+		x := new(big.Int).SetBytes(h[:])
+		if x.Cmp(p) >= 0 {
+			continue
+		}
+		// y² = x³ - 3x + b
+		rhs := new(big.Int).Exp(x, three, p)
+		rhs.Sub(rhs, new(big.Int).Mul(three, x))
+		rhs.Add(rhs, pedCurve.Params().B)
+		rhs.Mod(rhs, p)
+		y := new(big.Int).ModSqrt(rhs, p)
+		if y == nil {
+			continue
+		}
+		if y.Bit(0) == 1 {
+			y.Sub(p, y)
+		}
+		return ecPoint{x, y}
+	}
+}
+
+func randScalar() (*big.Int, error) {
+	for {
+		k, err := rand.Int(rand.Reader, pedOrder)
+		if err != nil {
+			return nil, err
+		}
+		if k.Sign() > 0 {
+			return k, nil
+		}
+	}
+}
+
+func commit(v, r *big.Int) ecPoint {
+	return baseMul(v).add(pedH.mul(r))
+}
+
+// CommitAmount commits to amount and returns the commitment (shared with the
+// verifier) and the blinding factor (kept by the prover), both hex-encoded.
+func CommitAmount(amount uint64) (commitmentHex, blindingHex string, err error) {
+	r, err := randScalar()
+	if err != nil {
+		return "", "", err
+	}
+	return commit(new(big.Int).SetUint64(amount), r).encode(), hex.EncodeToString(scalarBytes(r)), nil
+}
+
+// BitProof is one bit commitment with its OR-proof that it opens to 0 or 1.
+type BitProof struct {
+	C  string `json:"c"`
+	E0 string `json:"e0"`
+	E1 string `json:"e1"`
+	S0 string `json:"s0"`
+	S1 string `json:"s1"`
+}
+
+// RangeProof proves a committed amount v satisfies 0 <= v <= Limit.
+type RangeProof struct {
+	Limit uint64     `json:"limit"`
+	Value []BitProof `json:"value"` // v in [0, 2^RangeBits)
+	Slack []BitProof `json:"slack"` // limit - v in [0, 2^RangeBits)
+}
+
+// ProveAmountAtMost proves that the amount committed with blindingHex is at
+// most limit. It fails if the statement is false.
+func ProveAmountAtMost(amount uint64, blindingHex string, limit uint64) (*RangeProof, error) {
+	if amount > limit {
+		return nil, errors.New("amount exceeds limit")
+	}
+	if limit >= 1<<RangeBits {
+		return nil, fmt.Errorf("limit must be below 2^%d", RangeBits)
+	}
+	rb, err := hex.DecodeString(blindingHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blinding hex: %w", err)
+	}
+	r := new(big.Int).SetBytes(rb)
+	c := commit(new(big.Int).SetUint64(amount), r)
+	value, err := proveBits(amount, r, c, limit, "value")
+	if err != nil {
+		return nil, err
+	}
+	// limit·G - C commits to limit-v with blinding -r.
+	slackC := baseMul(new(big.Int).SetUint64(limit)).add(c.neg())
+	slack, err := proveBits(limit-amount, new(big.Int).Neg(r), slackC, limit, "slack")
+	if err != nil {
+		return nil, err
+	}
+	return &RangeProof{Limit: limit, Value: value, Slack: slack}, nil
+}
+
+// VerifyAmountAtMost checks a range proof against a commitment and the
+// verifier's own limit. The proof's embedded limit must match.
+func VerifyAmountAtMost(commitmentHex string, limit uint64, proof *RangeProof) bool {
+	if proof == nil || proof.Limit != limit || limit >= 1<<RangeBits {
+		return false
+	}
+	c, err := decodePoint(commitmentHex)
+	if err != nil {
+		return false
+	}
+	slackC := baseMul(new(big.Int).SetUint64(limit)).add(c.neg())
+	return verifyBits(proof.Value, c, limit, "value") && verifyBits(proof.Slack, slackC, limit, "slack")
+}
+
+// proveBits proves the value v committed in c (with blinding r) lies in
+// [0, 2^RangeBits) by committing to each bit.
+func proveBits(v uint64, r *big.Int, c ecPoint, limit uint64, label string) ([]BitProof, error) {
+	proofs := make([]BitProof, RangeBits)
+	// Blindings satisfy sum(2^i · r_i) = r, so sum(2^i · C_i) = C.
+	rs := make([]*big.Int, RangeBits)
+	acc := new(big.Int)
+	for i := 0; i < RangeBits-1; i++ {
+		ri, err := randScalar()
+		if err != nil {
+			return nil, err
+		}
+		rs[i] = ri
+		acc.Add(acc, new(big.Int).Lsh(ri, uint(i)))
+	}
+	last := new(big.Int).Sub(r, acc)
+	inv := new(big.Int).ModInverse(new(big.Int).Lsh(big.NewInt(1), RangeBits-1), pedOrder)
+	rs[RangeBits-1] = last.Mul(last, inv).Mod(last, pedOrder)
+
+	g := baseMul(big.NewInt(1))
+	for i := 0; i < RangeBits; i++ {
+		bit := int(v>>uint(i)) & 1
+		ci := commit(big.NewInt(int64(bit)), rs[i])
+		// Statements: P0 = C_i = r_i·H (bit 0) or P1 = C_i - G = r_i·H (bit 1).
+		ps := [2]ecPoint{ci, ci.add(g.neg())}
+		var e, s [2]*big.Int
+		var rPts [2]ecPoint
+		other := 1 - bit
+		var err error
+		if e[other], err = randScalar(); err != nil {
+			return nil, err
+		}
+		if s[other], err = randScalar(); err != nil {
+			return nil, err
+		}
+		rPts[other] = pedH.mul(s[other]).add(ps[other].mul(e[other]).neg())
+		k, err := randScalar()
+		if err != nil {
+			return nil, err
+		}
+		rPts[bit] = pedH.mul(k)
+		chal := bitChallenge(c, limit, label, i, ci, rPts)
+		e[bit] = new(big.Int).Sub(chal, e[other])
+		e[bit].Mod(e[bit], pedOrder)
+		s[bit] = new(big.Int).Mul(e[bit], rs[i])
+		s[bit].Add(s[bit], k).Mod(s[bit], pedOrder)
+		proofs[i] = BitProof{
+			C:  ci.encode(),
+			E0: hex.EncodeToString(scalarBytes(e[0])),
+			E1: hex.EncodeToString(scalarBytes(e[1])),
+			S0: hex.EncodeToString(scalarBytes(s[0])),
+			S1: hex.EncodeToString(scalarBytes(s[1])),
+		}
+	}
+	return proofs, nil
+}
+
+func verifyBits(proofs []BitProof, c ecPoint, limit uint64, label string) bool {
+	if len(proofs) != RangeBits {
+		return false
+	}
+	g := baseMul(big.NewInt(1))
+	sum := ecPoint{new(big.Int), new(big.Int)}
+	for i, bp := range proofs {
+		ci, err := decodePoint(bp.C)
+		if err != nil {
+			return false
+		}
+		var e, s [2]*big.Int
+		var ok [4]bool
+		e[0], ok[0] = decodeScalar(bp.E0)
+		e[1], ok[1] = decodeScalar(bp.E1)
+		s[0], ok[2] = decodeScalar(bp.S0)
+		s[1], ok[3] = decodeScalar(bp.S1)
+		if !ok[0] || !ok[1] || !ok[2] || !ok[3] {
+			return false
+		}
+		ps := [2]ecPoint{ci, ci.add(g.neg())}
+		var rPts [2]ecPoint
+		for b := 0; b < 2; b++ {
+			rPts[b] = pedH.mul(s[b]).add(ps[b].mul(e[b]).neg())
+		}
+		chal := bitChallenge(c, limit, label, i, ci, rPts)
+		if new(big.Int).Add(e[0], e[1]).Mod(new(big.Int).Add(e[0], e[1]), pedOrder).Cmp(chal) != 0 {
+			return false
+		}
+		sum = sum.add(ci.mul(new(big.Int).Lsh(big.NewInt(1), uint(i))))
+	}
+	return sum.encode() == c.encode()
+}
+
+func decodeScalar(s string) (*big.Int, bool) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 32 {
+		return nil, false
+	}
+	k := new(big.Int).SetBytes(b)
+	return k, k.Cmp(pedOrder) < 0
+}
+
+func bitChallenge(c ecPoint, limit uint64, label string, i int, ci ecPoint, rPts [2]ecPoint) *big.Int {
+	h := sha256.New()
+	h.Write(rangeDomain)
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], limit)
+	h.Write(buf[:])
+	h.Write([]byte(label))
+	binary.BigEndian.PutUint64(buf[:], uint64(i))
+	h.Write(buf[:])
+	for _, p := range []ecPoint{pedH, c, ci, rPts[0], rPts[1]} {
+		h.Write([]byte(p.encode()))
+	}
+	return new(big.Int).Mod(new(big.Int).SetBytes(h.Sum(nil)), pedOrder)
+}
+
+// rangeProofArg accepts a proof as *RangeProof, decoded JSON, or a JSON string.
+func rangeProofArg(x any) (*RangeProof, error) {
+	switch v := x.(type) {
+	case *RangeProof:
+		return v, nil
+	case string:
+		var p RangeProof
+		if err := json.Unmarshal([]byte(v), &p); err != nil {
+			return nil, err
+		}
+		return &p, nil
+	case map[string]any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var p RangeProof
+		if err := json.Unmarshal(b, &p); err != nil {
+			return nil, err
+		}
+		return &p, nil
+	default:
+		return nil, fmt.Errorf("unsupported proof type %T", x)
+	}
+}
@@ -0,0 +1,81 @@
+package spl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRangeProofAtMost(t *testing.T) {
+	c, r, err := CommitAmount(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := ProveAmountAtMost(42, r, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyAmountAtMost(c, 50, proof) {
+		t.Fatal("expected valid proof for 42 <= 50")
+	}
+	if VerifyAmountAtMost(c, 40, proof) {
+		t.Fatal("expected proof to be bound to its limit")
+	}
+	other, _, _ := CommitAmount(42)
+	if VerifyAmountAtMost(other, 50, proof) {
+		t.Fatal("expected proof to be bound to its commitment")
+	}
+}
+
+func TestRangeProofBoundary(t *testing.T) {
+	c, r, _ := CommitAmount(50)
+	proof, err := ProveAmountAtMost(50, r, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyAmountAtMost(c, 50, proof) {
+		t.Fatal("expected valid proof at the limit")
+	}
+	if _, err := ProveAmountAtMost(51, r, 50); err == nil {
+		t.Fatal("expected prover to refuse a false statement")
+	}
+}
+
+func TestRangeProofTampered(t *testing.T) {
+	c, r, _ := CommitAmount(7)
+	proof, _ := ProveAmountAtMost(7, r, 10)
+	proof.Value[3].S0, proof.Value[3].S1 = proof.Value[3].S1, proof.Value[3].S0
+	if VerifyAmountAtMost(c, 10, proof) {
+		t.Fatal("expected tampered proof to fail")
+	}
+}
+
+func TestRangeOkOp(t *testing.T) {
+	c, r, _ := CommitAmount(30)
+	proof, _ := ProveAmountAtMost(30, r, 50)
+	// Round-trip through JSON as a request would arrive over the wire.
+	raw, _ := json.Marshal(map[string]any{"amount_commitment": c, "amount_proof": proof})
+	var req map[string]any
+	if err := json.Unmarshal(raw, &req); err != nil {
+		t.Fatal(err)
+	}
+	env := makeEnv()
+	env.Req = req
+	ok, err := evalExpr(t, `(range_ok? (get req "amount_commitment") (get req "amount_proof") 50)`, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected range_ok? to allow")
+	}
+	ok, err = evalExpr(t, `(range_ok? (get req "amount_commitment") (get req "amount_proof") 25)`, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("expected range_ok? to deny against a lower limit")
+	}
+	ok, _ = evalExpr(t, `(range_ok? (get req "amount_commitment") (get req "missing") 50)`, env)
+	if ok {
+		t.Fatal("expected missing proof to deny")
+	}
+}
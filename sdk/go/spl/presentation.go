@@ -0,0 +1,236 @@
+package spl
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Presentation is what an agent sends with a request: the token and
+// everything that proves it may use it for this request. VerifyPresentation
+// checks all of it, so verifiers need not thread each proof through its own
+// VerifyTokenOptions field.
+//
+// json.Marshal of a Presentation is its canonical JSON encoding: fields in
+// a fixed order, object keys sorted, and no insignificant whitespace.
+// MarshalCBOR is its canonical CBOR encoding, and ParsePresentation reads
+// either back.
+type Presentation struct {
+	Token *Token `json:"token"`
+	// Signature is the holder's presentation signature, from
+	// CreatePresentationSignature, or from Sign when Nonce is set.
+	Signature string `json:"presentation_signature,omitempty"`
+	// Nonce identifies this presentation. The presentation signature
+	// covers it, and a verifier with VerifyTokenOptions.Replay accepts it
+	// only once.
+	Nonce string `json:"nonce,omitempty"`
+	// Disclosed holds request fields the presenter supplies rather than
+	// the verifier, such as the recipient a Merkle proof is for. They are
+	// added to the request, which must not already hold other values for
+	// them.
+	Disclosed map[string]any `json:"disclosed,omitempty"`
+	// MerkleProofs back merkle-member? with inclusion proofs, by value; see
+	// VerifyTokenOptions.MerkleWitnesses.
+	MerkleProofs map[string][]MerkleProofStep `json:"merkle_proofs,omitempty"`
+	// Receipt proves a step of the token's hash chain; a token with a
+	// hash_chain_commitment is presented only with one.
+	Receipt *HashChainReceipt `json:"receipt,omitempty"`
+	// Discharges back approval_ok? with tokens from approvers; see
+	// VerifyTokenOptions.Approvers.
+	Discharges []*Token `json:"discharges,omitempty"`
+	// Session, when set, is the holder's grant to the session key that
+	// signed the presentation; see DeriveSessionToken.
+	Session *SessionGrant `json:"session,omitempty"`
+}
+
+// HashChainReceipt reveals step Index of a hash chain of Length steps, as
+// VerifyHashChain checks it. Only Length - Index, the number of hashes from
+// the preimage to the commitment, is fixed by the chain; the presenter picks
+// Index and Length, so verifiers identify the step by that distance.
+type HashChainReceipt struct {
+	Preimage string `json:"preimage"`
+	Index    int    `json:"index"`
+	Length   int    `json:"length"`
+}
+
+// MaxHashChainLength is the longest hash chain a presentation's receipt
+// may claim, bounding the hashing a presenter can make a verifier do.
+const MaxHashChainLength = 1 << 16
+
+// DefaultNonceTTL is how long VerifyTokenOptions.Replay holds the nonce of
+// a presentation whose token does not expire.
+const DefaultNonceTTL = 24 * time.Hour
+
+// Sign sets p.Signature to the holder's presentation signature over the
+// token and p.Nonce: SHA-256(signing_payload || nonce), signed with the
+// Ed25519 key whose public half is the token's pop_key. Without a nonce it
+// is CreatePresentationSignature's. With p.Session, the key is the
+// session's and the nonce is preceded by the session ID and a newline.
+func (p *Presentation) Sign(agentPrivateKeyHex string) error {
+	if p.Token == nil {
+		return fmt.Errorf("presentation has no token")
+	}
+	seed, err := hex.DecodeString(agentPrivateKeyHex)
+	if err != nil {
+		return fmt.Errorf("invalid agent private key hex: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return fmt.Errorf("agent private key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	t := p.Token
+	nonce := p.Nonce
+	if p.Session != nil {
+		nonce = sessionNonce(p.Session.Session, nonce)
+	}
+	h := presentationDigest(t.Payload(), nonce)
+	p.Signature = hex.EncodeToString(ed25519.Sign(ed25519.NewKeyFromSeed(seed), h[:]))
+	return nil
+}
+
+// presentationDigest is what a presentation signature signs.
+func presentationDigest(payload []byte, nonce string) [sha256.Size]byte {
+	if nonce == "" {
+		return sha256.Sum256(payload)
+	}
+	return sha256.Sum256(append(payload[:len(payload):len(payload)], nonce...))
+}
+
+// MarshalCBOR encodes p in deterministic CBOR (RFC 8949 section 4.2.1),
+// with the same structure as its JSON.
+func (p *Presentation) MarshalCBOR() ([]byte, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return cborFromJSON(data)
+}
+
+// ParsePresentation decodes a presentation from its JSON or CBOR encoding,
+// telling them apart by the first byte: a JSON object opens with a brace, a
+// CBOR map with a byte of 0xa0 or above.
+func ParsePresentation(data []byte) (*Presentation, error) {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] >= 0xa0 && trimmed[0] < 0xc0 {
+		var err error
+		if data, err = cborToJSON(trimmed); err != nil {
+			return nil, kindf(ErrMalformedToken, "invalid presentation: %w", err)
+		}
+	}
+	var p Presentation
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, kindf(ErrMalformedToken, "invalid presentation: %w", err)
+	}
+	return &p, nil
+}
+
+// VerifyPresentation verifies p's token for req, as VerifyTokenObj does,
+// taking its proofs from p: the presentation signature, nonce and Merkle
+// proofs in place of opts' PresentationSignature and MerkleWitnesses, the
+// disclosed fields as part of req, the receipt against the token's
+// hash-chain commitment, and the discharges for approval_ok?. With a
+// session grant, the session key's signature stands in for the holder's.
+// The nonce and receipt are claimed in opts.Replay, when set, once the
+// token and its holder are verified.
+func VerifyPresentation(p *Presentation, req map[string]any, opts VerifyTokenOptions) VerifyTokenResult {
+	if p == nil || p.Token == nil {
+		return failed(nil, kindf(ErrMalformedToken, "presentation has no token"))
+	}
+	if len(p.Disclosed) > 0 {
+		merged := make(map[string]any, len(req)+len(p.Disclosed))
+		for k, v := range req {
+			merged[k] = v
+		}
+		for k, v := range p.Disclosed {
+			if have, ok := merged[k]; ok && !reflect.DeepEqual(have, v) {
+				return failed(p.Token, kindf(ErrMalformedToken, "disclosed field %q conflicts with the request", k))
+			}
+			merged[k] = v
+		}
+		req = merged
+	}
+	if p.Signature != "" {
+		opts.PresentationSignature = p.Signature
+	}
+	if p.MerkleProofs != nil {
+		opts.MerkleWitnesses = p.MerkleProofs
+	}
+	if opts.Crypto.ApprovalOk == nil && len(p.Discharges) > 0 {
+		opts.Crypto.ApprovalOk = p.approvalOk(&opts)
+	}
+	opts.presentation = p
+	return VerifyTokenObj(p.Token, req, opts)
+}
+
+// verify checks the parts of p that VerifyTokenObj does not, once the token
+// and its holder are verified: the receipt, and the nonce and receipt are
+// not replayed.
+func (p *Presentation) verify(t *Token, opts *VerifyTokenOptions, now time.Time) error {
+	if p.Session != nil && t.PoPKey == "" {
+		return kindf(ErrMalformedToken, "session grant for a token without a pop_key")
+	}
+	switch r := p.Receipt; {
+	case t.HashChainCommitment == "" && r != nil:
+		return kindf(ErrMalformedToken, "receipt for a token without a hash chain")
+	case t.HashChainCommitment == "":
+	case r == nil:
+		return kindf(ErrPoPRequired, "hash-chain receipt required")
+	case r.Length > MaxHashChainLength:
+		return kindf(ErrMalformedToken, "receipt chain length exceeds %d", MaxHashChainLength)
+	case !VerifyHashChain(t.HashChainCommitment, r.Preimage, r.Index, r.Length):
+		return kindf(ErrBadSignature, "invalid hash-chain receipt")
+	}
+	if opts.Replay == nil {
+		return nil
+	}
+	if p.Nonce == "" {
+		return kindf(ErrPoPRequired, "presentation nonce required")
+	}
+	ttl := DefaultNonceTTL
+	if exp, err := time.Parse(time.RFC3339, t.Expires); err == nil {
+		ttl = exp.Sub(now) + time.Second
+	}
+	claims := [][2]string{{"nonce", "nonce " + t.Signature + " " + p.Nonce}}
+	if p.Receipt != nil {
+		steps := p.Receipt.Length - p.Receipt.Index
+		claims = append(claims, [2]string{"receipt", fmt.Sprintf("receipt %s %d", strings.ToLower(t.HashChainCommitment), steps)})
+	}
+	for _, c := range claims {
+		ok, err := opts.Replay.Claim(c[1], ttl)
+		if err != nil {
+			return withKind(ErrStore, err)
+		}
+		if !ok {
+			return kindf(ErrReplayed, "presentation %s already used", c[0])
+		}
+	}
+	return nil
+}
+
+// approvalOk backs approval_ok? with p's discharges: approver name has
+// approved if a discharge issued by opts.Approvers[name] allows
+// {"approver": name, "token": the presented token's signature}.
+func (p *Presentation) approvalOk(opts *VerifyTokenOptions) func(string) bool {
+	approvers, now, clock, token := opts.Approvers, opts.Now, opts.Clock, p.Token.Signature
+	return func(name string) bool {
+		pub, ok := approvers[name]
+		if !ok {
+			return false
+		}
+		ring, err := NewKeyRing(KeyRingEntry{ID: name, PublicKey: pub})
+		if err != nil {
+			return false
+		}
+		req := map[string]any{"approver": name, "token": token}
+		for _, d := range p.Discharges {
+			if d != nil && VerifyTokenObj(d, req, VerifyTokenOptions{KeyResolver: ring, Now: now, Clock: clock}).Allow {
+				return true
+			}
+		}
+		return false
+	}
+}
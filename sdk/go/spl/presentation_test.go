@@ -0,0 +1,225 @@
+package spl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type claimSet struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+func (s *claimSet) Claim(id string, _ time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ids[id] {
+		return false, nil
+	}
+	if s.ids == nil {
+		s.ids = map[string]bool{}
+	}
+	s.ids[id] = true
+	return true, nil
+}
+
+func TestCBOR(t *testing.T) {
+	for _, c := range []struct{ json, cbor string }{
+		{`0`, "00"},
+		{`23`, "17"},
+		{`24`, "1818"},
+		{`-1`, "20"},
+		{`1000000`, "1a000f4240"},
+		{`1.0`, "01"},
+		{`1.5`, "f93e00"},
+		{`100000.5`, "fa47c35040"},
+		{`1.1`, "fb3ff199999999999a"},
+		{`5.960464477539063e-8`, "f90001"},
+		{`"a"`, "6161"},
+		{`[true,false,null]`, "83f5f4f6"},
+		// Keys sort shortest first: "b" before "aa".
+		{`{"aa":1,"b":[]}`, "a261628062616101"},
+	} {
+		got, err := cborFromJSON([]byte(c.json))
+		if err != nil || hex.EncodeToString(got) != c.cbor {
+			t.Errorf("%s: got %x, %v; want %s", c.json, got, err, c.cbor)
+			continue
+		}
+		back, err := cborToJSON(got)
+		if err != nil {
+			t.Errorf("%s: %v", c.json, err)
+			continue
+		}
+		var want, have any
+		json.Unmarshal([]byte(c.json), &want)
+		json.Unmarshal(back, &have)
+		if !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: decoded as %s", c.json, back)
+		}
+	}
+	for _, bad := range []string{"", "61", "62ff", "a10101", "9f", "8201", "0000", "c0"} {
+		data, _ := hex.DecodeString(bad)
+		if _, err := cborToJSON(data); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestPresentationEncoding(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{})
+	p := &Presentation{
+		Token:        tok,
+		Nonce:        "n-1",
+		Disclosed:    map[string]any{"recipient": "bob", "amount": 12.5},
+		MerkleProofs: map[string][]MerkleProofStep{"bob": {{Hash: "ab", Position: "left"}}},
+		Receipt:      &HashChainReceipt{Preimage: "00", Index: 2, Length: 10},
+		Discharges:   []*Token{tok},
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := p.MarshalCBOR()
+	if !bytes.Equal(c, again) {
+		t.Fatal("expected CBOR encoding to be deterministic")
+	}
+	for _, enc := range [][]byte{data, c} {
+		got, err := ParsePresentation(enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, p) {
+			t.Fatalf("round trip: got %+v", got)
+		}
+	}
+	if _, err := ParsePresentation([]byte{0xa1, 0x01}); !errors.Is(err, ErrMalformedToken) {
+		t.Fatalf("expected a malformed presentation, got %v", err)
+	}
+}
+
+func TestVerifyPresentation(t *testing.T) {
+	_, priv := GenerateKeypair()
+	agentPub, agentPriv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{PoPKey: agentPub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Presentation{Token: tok, Nonce: "n-1", Disclosed: map[string]any{"amount": 50.0}}
+	if err := p.Sign(agentPriv); err != nil {
+		t.Fatal(err)
+	}
+	req := map[string]any{"action": "payments.create"}
+	opts := VerifyTokenOptions{Replay: &claimSet{}}
+	if r := VerifyPresentation(p, req, opts); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+	if _, ok := req["amount"]; ok {
+		t.Fatal("expected the caller's request to be left alone")
+	}
+	if r := VerifyPresentation(p, req, opts); r.Code != CodeReplayed {
+		t.Fatalf("expected the nonce to be refused a second time, got %+v", r)
+	}
+
+	// The signature covers the nonce.
+	moved := *p
+	moved.Nonce = "n-2"
+	if r := VerifyPresentation(&moved, req, opts); r.Code != CodeBadSignature {
+		t.Fatalf("expected a bad signature for another nonce, got %+v", r)
+	}
+	fresh := &Presentation{Token: tok, Nonce: "n-3", Disclosed: map[string]any{"amount": 50.0}}
+	fresh.Sign(agentPriv)
+	conflict := map[string]any{"action": "payments.create", "amount": 5.0}
+	if r := VerifyPresentation(fresh, conflict, opts); r.Code != CodeMalformedToken {
+		t.Fatalf("expected a conflicting disclosure to fail, got %+v", r)
+	}
+	noNonce := &Presentation{Token: tok}
+	noNonce.Sign(agentPriv)
+	if r := VerifyPresentation(noNonce, tokenTestReq(50), opts); r.Code != CodePoPRequired {
+		t.Fatalf("expected a nonce to be required, got %+v", r)
+	}
+	if r := VerifyPresentation(noNonce, tokenTestReq(50), VerifyTokenOptions{}); !r.Allow {
+		t.Fatalf("expected allow without a replay store, got %q", r.ErrorMessage())
+	}
+	if r := VerifyPresentation(&Presentation{}, req, opts); r.Code != CodeMalformedToken {
+		t.Fatalf("expected a presentation without a token to fail, got %+v", r)
+	}
+}
+
+func TestVerifyPresentationReceipt(t *testing.T) {
+	seed := make([]byte, 32)
+	chain := [][]byte{seed}
+	for i := 1; i <= 5; i++ {
+		chain = append(chain, SHA256Hash(chain[i-1]))
+	}
+	_, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{HashChainCommitment: hex.EncodeToString(chain[5])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := VerifyTokenOptions{Replay: &claimSet{}}
+	receipt := func(i int, nonce string) *Presentation {
+		return &Presentation{Token: tok, Nonce: nonce, Receipt: &HashChainReceipt{Preimage: hex.EncodeToString(chain[i]), Index: i, Length: 5}}
+	}
+	if r := VerifyPresentation(receipt(2, "a"), tokenTestReq(50), opts); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+	if r := VerifyPresentation(receipt(2, "b"), tokenTestReq(50), opts); r.Code != CodeReplayed {
+		t.Fatalf("expected a spent step to be refused, got %+v", r)
+	}
+	bad := receipt(3, "c")
+	bad.Receipt.Index = 4
+	if r := VerifyPresentation(bad, tokenTestReq(50), opts); r.Code != CodeBadSignature {
+		t.Fatalf("expected an invalid receipt to fail, got %+v", r)
+	}
+	if r := VerifyPresentation(&Presentation{Token: tok, Nonce: "d"}, tokenTestReq(50), opts); r.Code != CodePoPRequired {
+		t.Fatalf("expected a receipt to be required, got %+v", r)
+	}
+	// The spent step, renumbered, is the same step.
+	shifted := receipt(2, "e")
+	shifted.Receipt.Index, shifted.Receipt.Length = 12, 15
+	if r := VerifyPresentation(shifted, tokenTestReq(50), opts); r.Code != CodeReplayed {
+		t.Fatalf("expected a renumbered spent step to be refused, got %+v", r)
+	}
+	long := receipt(1, "f")
+	long.Receipt.Length = 1 << 40
+	if r := VerifyPresentation(long, tokenTestReq(50), opts); r.Code != CodeMalformedToken {
+		t.Fatalf("expected an oversized chain length to be refused, got %+v", r)
+	}
+}
+
+func TestVerifyPresentationDischarges(t *testing.T) {
+	_, priv := GenerateKeypair()
+	parentPub, parentPriv := GenerateKeypair()
+	tok, err := Mint(`(and (= (get req "action") "pay") (approval_ok? "parent"))`, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	discharge, _ := Mint(`(= (get req "token") "`+tok.Signature+`")`, parentPriv, MintOptions{})
+	forged, _ := Mint(`#t`, priv, MintOptions{})
+	opts := VerifyTokenOptions{Approvers: map[string]string{"parent": parentPub}}
+	req := map[string]any{"action": "pay"}
+	if r := VerifyPresentation(&Presentation{Token: tok, Discharges: []*Token{forged, discharge}}, req, opts); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+	for name, ds := range map[string][]*Token{"none": nil, "forged": {forged}} {
+		if r := VerifyPresentation(&Presentation{Token: tok, Discharges: ds}, req, opts); r.Allow {
+			t.Fatalf("%s: expected deny", name)
+		}
+	}
+	// A discharge is bound to the token it was issued for.
+	rebound, _ := Mint(`(and (= (get req "action") "pay") (approval_ok? "parent"))`, priv, MintOptions{Expires: "2099-01-01T00:00:00Z"})
+	if r := VerifyPresentation(&Presentation{Token: rebound, Discharges: []*Token{discharge}}, req, opts); r.Allow {
+		t.Fatal("expected a discharge for another token to deny")
+	}
+}
@@ -0,0 +1,160 @@
+package spl
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// PolicyProfile is the least a verifier accepts of a token's policy,
+// checked against its syntax tree before evaluation, so a token too weak to
+// be trusted is refused whatever request it is presented with:
+//
+//	profile := &spl.PolicyProfile{
+//		Name:      "payments",
+//		Constrain: []string{"action"},
+//		Require:   []string{"dpop_ok?"},
+//		Bounded:   true,
+//	}
+//
+// A profile counts only required clauses: the conjuncts of the policy's and
+// of its caveats, and of the ands within them, which every allowed request
+// passes. An or is required in a clause only if each of its branches is;
+// anything under a not does not count towards Require or Bounded.
+type PolicyProfile struct {
+	// Name identifies the profile in errors.
+	Name string
+	// Constrain lists request fields, such as "action", that a required
+	// clause must read with (get req "field").
+	Constrain []string
+	// Require lists operators, such as "dpop_ok?" or "merkle_ok?", that a
+	// required clause must apply.
+	Require []string
+	// Bounded requires the token to expire, or a required clause to compare
+	// now with before or to apply a rate limit: per-day-count, window-count,
+	// sum-amount or bucket-ok?.
+	Bounded bool
+}
+
+// Check reports whether t's policy and caveats satisfy the profile, as
+// VerifyTokenOptions.Profile does. A token with action policies must
+// satisfy it in each of them. Issuers use it to refuse to mint what
+// verifiers would reject. The error is ErrPolicySyntax if a policy does
+// not parse, and ErrProfile naming every unmet rule if one falls short.
+func (p *PolicyProfile) Check(t *Token) error {
+	return p.check(t, DefaultPolicyCache, Limits{})
+}
+
+func (p *PolicyProfile) check(t *Token, c *PolicyCache, l Limits) error {
+	actions := make([]string, 0, len(t.Actions)+1)
+	for name := range t.Actions {
+		actions = append(actions, name)
+	}
+	sort.Strings(actions)
+	var failures []string
+	for _, action := range append([]string{""}, actions...) {
+		ast, err := tokenPolicy(t, t.actionPolicy(action), c, l)
+		if err != nil {
+			return err
+		}
+		if unmet := p.unmet(t, ast); len(unmet) > 0 {
+			label := "policy"
+			if action != "" {
+				label = fmt.Sprintf("action %q policy", action)
+			}
+			failures = append(failures, label+" "+strings.Join(unmet, ", "))
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	name := "policy profile"
+	if p.Name != "" {
+		name += " " + p.Name
+	}
+	return kindf(ErrProfile, "%s: %s", name, strings.Join(failures, "; "))
+}
+
+// unmet lists the rules of the profile that ast, one of t's policies, does
+// not meet.
+func (p *PolicyProfile) unmet(t *Token, ast Node) []string {
+	var unmet []string
+	for _, field := range p.Constrain {
+		if !requires(ast, func(n Node) bool { return reads(n, field) }) {
+			unmet = append(unmet, fmt.Sprintf("does not constrain %q", field))
+		}
+	}
+	for _, op := range p.Require {
+		if !requires(ast, func(n Node) bool { return applies(n, op) }) {
+			unmet = append(unmet, "does not require "+op)
+		}
+	}
+	if p.Bounded && t.Expires == "" && !requires(ast, bounds) {
+		unmet = append(unmet, "has no expiry or rate limit")
+	}
+	return unmet
+}
+
+// requires reports whether clause holds of a required clause of n.
+func requires(n Node, clause func(Node) bool) bool {
+	if l, ok := n.(List); ok {
+		switch op, _, _ := l.Head(); op {
+		case OpAnd:
+			return slices.ContainsFunc(l[1:], func(c Node) bool { return requires(c, clause) })
+		case OpOr:
+			for _, c := range l[1:] {
+				if !requires(c, clause) {
+					return false
+				}
+			}
+			return len(l) > 1
+		}
+	}
+	return clause(n)
+}
+
+// reads reports whether n reads (get req field) anywhere within it.
+func reads(n Node, field string) bool {
+	l, ok := n.(List)
+	if !ok {
+		return false
+	}
+	if op, _, _ := l.Head(); op == OpGet && len(l) == 3 {
+		if s, ok := l[1].(*Symbol); ok && s.Name == "req" && l[2] == Str(field) {
+			return true
+		}
+	}
+	return slices.ContainsFunc(l, func(c Node) bool { return reads(c, field) })
+}
+
+// applies reports whether n is an application of the operator called name.
+func applies(n Node, name string) bool {
+	l, ok := n.(List)
+	if !ok {
+		return false
+	}
+	_, head, ok := l.Head()
+	return ok && head == name
+}
+
+// bounds reports whether n limits the token in time or rate: it compares
+// now with before, or applies a rate limit outside any not.
+func bounds(n Node) bool {
+	l, ok := n.(List)
+	if !ok {
+		return false
+	}
+	switch op, _, _ := l.Head(); op {
+	case OpNot:
+		return false
+	case OpBefore:
+		return slices.ContainsFunc(l[1:], func(c Node) bool {
+			s, ok := c.(*Symbol)
+			return ok && s.Name == "now"
+		})
+	case OpPerDayCount, OpWindowCount, OpSumAmount, OpBucketOk:
+		return true
+	}
+	return slices.ContainsFunc(l[1:], bounds)
+}
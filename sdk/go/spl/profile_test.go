@@ -0,0 +1,82 @@
+package spl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPolicyProfile(t *testing.T) {
+	p := &PolicyProfile{Name: "payments", Constrain: []string{"action"}, Require: []string{"dpop_ok?"}, Bounded: true}
+	cases := []struct {
+		policy  string
+		expires string
+		unmet   []string
+	}{
+		{`(and (= (get req "action") "pay") (dpop_ok?) (<= (per-day-count "pay" day) 3))`, "", nil},
+		{`(and (member (get req "action") allowed) (and (dpop_ok?) (before now "2027-01-01T00:00:00Z")))`, "", nil},
+		{`(and (= (get req "action") "pay") (dpop_ok?))`, "2027-01-01T00:00:00Z", nil},
+		{`(and (or (= (get req "action") "a") (= (get req "action") "b")) (or (dpop_ok?) (and (dpop_ok?) #t)) (bucket-ok? "pay" 5 "1m"))`, "", nil},
+		{`#t`, "", []string{`"action"`, "dpop_ok?", "expiry"}},
+		{`(or (= (get req "action") "pay") (dpop_ok?))`, "", []string{`"action"`, "dpop_ok?"}},
+		{`(and (= (get req "action") "pay") (not (dpop_ok?)) (not (<= (per-day-count "pay" day) 3)))`, "", []string{"dpop_ok?", "expiry"}},
+		{`(and (= (get req "amount") 1) (dpop_ok?) (or))`, "2027-01-01T00:00:00Z", []string{`"action"`}},
+	}
+	for _, c := range cases {
+		err := p.Check(&Token{Policy: c.policy, Expires: c.expires})
+		if len(c.unmet) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected %v", c.policy, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrProfile) || CodeOf(err) != CodeProfile || !strings.HasPrefix(err.Error(), "policy profile payments: ") {
+			t.Errorf("%s: expected a profile error, got %v", c.policy, err)
+			continue
+		}
+		for _, u := range c.unmet {
+			if !strings.Contains(err.Error(), u) {
+				t.Errorf("%s: expected %q in %v", c.policy, u, err)
+			}
+		}
+	}
+
+	// Each action policy must meet the profile too.
+	multi := &Token{Policy: `(and (= (get req "action") "read") (dpop_ok?))`, Expires: "2027-01-01T00:00:00Z", Actions: map[string]string{
+		"pay":  `(and (= (get req "action") "pay") (dpop_ok?))`,
+		"list": `(= (get req "action") "list")`,
+	}}
+	err := p.Check(multi)
+	if !errors.Is(err, ErrProfile) || err.Error() != `policy profile payments: action "list" policy does not require dpop_ok?` {
+		t.Fatalf("expected the list action to fall short, got %v", err)
+	}
+	if err := p.Check(&Token{Policy: "("}); !errors.Is(err, ErrPolicySyntax) {
+		t.Fatalf("expected a syntax error, got %v", err)
+	}
+}
+
+func TestVerifyTokenProfile(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	strong := &PolicyProfile{Constrain: []string{"action", "amount"}}
+	if r := VerifyToken(mustJSON(t, tok), tokenTestReq(50), VerifyTokenOptions{Profile: strong}); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+	weak := &PolicyProfile{Require: []string{"dpop_ok?"}}
+	r := VerifyToken(mustJSON(t, tok), tokenTestReq(50), VerifyTokenOptions{Profile: weak})
+	if r.Allow || r.Code != CodeProfile || r.GasUsed != 0 {
+		t.Fatalf("expected the profile to reject before evaluation, got %+v", r)
+	}
+
+	// Attenuation can supply the required clause.
+	narrowed, err := Attenuate(tok, "(dpop_ok?)", priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := weak.Check(narrowed); err != nil {
+		t.Fatalf("expected the attenuated token to pass, got %v", err)
+	}
+}
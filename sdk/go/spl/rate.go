@@ -0,0 +1,179 @@
+package spl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket: it holds up to Capacity tokens, starts full,
+// and gains one token every Refill.
+type Bucket struct {
+	Capacity int
+	Refill   time.Duration
+}
+
+// RateStore keeps the history of uses behind window-count and bucket-ok?,
+// the rolling counterparts of per-day-count. Each action has one history of
+// uses, counted over whatever window a policy asks for, and one bucket per
+// Bucket shape.
+type RateStore interface {
+	// WindowCount returns the uses of action in the window ending at now.
+	WindowCount(action string, window time.Duration, now time.Time) (int, error)
+	// WindowTake records a use of action at now and reports true if fewer
+	// than limit uses were in the window ending at now, or leaves the
+	// history unchanged and reports false if not, as one atomic step.
+	WindowTake(action string, window time.Duration, limit int, now time.Time) (bool, error)
+	// BucketTokens returns the tokens in action's bucket at now.
+	BucketTokens(action string, b Bucket, now time.Time) (float64, error)
+	// BucketTake takes a token from action's bucket and reports true if it
+	// held one at now, atomically.
+	BucketTake(action string, b Bucket, now time.Time) (bool, error)
+}
+
+// ParseWindow parses the window of a window-count or bucket-ok?: a Go
+// duration such as "90m" or "24h", or a number of days such as "7d".
+func ParseWindow(s string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid window %q", s)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("window %q must be positive", s)
+	}
+	return d, nil
+}
+
+// MemoryRates is a RateStore for a single process. Each action keeps the
+// times of its uses within the longest window asked of it. The zero value
+// is ready to use.
+type MemoryRates struct {
+	mu      sync.Mutex
+	uses    map[string]*useHistory
+	buckets map[bucketKey]*bucketState
+}
+
+type useHistory struct {
+	times   []time.Time // ascending
+	longest time.Duration
+}
+
+type bucketKey struct {
+	action string
+	Bucket
+}
+
+type bucketState struct {
+	tokens float64
+	at     time.Time
+}
+
+// history returns action's uses within window of now, dropping uses older
+// than any window asked for.
+func (m *MemoryRates) history(action string, window time.Duration, now time.Time) (*useHistory, int) {
+	if m.uses == nil {
+		m.uses = map[string]*useHistory{}
+	}
+	h := m.uses[action]
+	if h == nil {
+		h = &useHistory{}
+		m.uses[action] = h
+	}
+	if window > h.longest {
+		h.longest = window
+	}
+	keep := 0
+	for keep < len(h.times) && !h.times[keep].After(now.Add(-h.longest)) {
+		keep++
+	}
+	h.times = h.times[keep:]
+	n := 0
+	for _, t := range h.times {
+		if t.After(now.Add(-window)) && !t.After(now) {
+			n++
+		}
+	}
+	return h, n
+}
+
+func (m *MemoryRates) WindowCount(action string, window time.Duration, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, n := m.history(action, window, now)
+	return n, nil
+}
+
+func (m *MemoryRates) WindowTake(action string, window time.Duration, limit int, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, n := m.history(action, window, now)
+	if n >= limit {
+		return false, nil
+	}
+	i := len(h.times)
+	for i > 0 && h.times[i-1].After(now) {
+		i--
+	}
+	h.times = append(h.times, time.Time{})
+	copy(h.times[i+1:], h.times[i:])
+	h.times[i] = now
+	return true, nil
+}
+
+// bucket returns action's bucket refilled up to now.
+func (m *MemoryRates) bucket(action string, b Bucket, now time.Time) *bucketState {
+	if m.buckets == nil {
+		m.buckets = map[bucketKey]*bucketState{}
+	}
+	k := bucketKey{action, b}
+	s := m.buckets[k]
+	if s == nil {
+		s = &bucketState{tokens: float64(b.Capacity), at: now}
+		m.buckets[k] = s
+	}
+	s.tokens, s.at = refill(s.tokens, s.at, b, now)
+	return s
+}
+
+// refill returns the tokens in a bucket that held tokens at the time at,
+// once it has refilled until now.
+func refill(tokens float64, at time.Time, b Bucket, now time.Time) (float64, time.Time) {
+	if !now.After(at) {
+		return tokens, at
+	}
+	if b.Refill > 0 {
+		tokens += float64(now.Sub(at)) / float64(b.Refill)
+	}
+	if tokens > float64(b.Capacity) {
+		tokens = float64(b.Capacity)
+	}
+	return tokens, now
+}
+
+func (m *MemoryRates) BucketTokens(action string, b Bucket, now time.Time) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bucket(action, b, now).tokens, nil
+}
+
+func (m *MemoryRates) BucketTake(action string, b Bucket, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.bucket(action, b, now)
+	if s.tokens < 1 {
+		return false, nil
+	}
+	s.tokens--
+	return true, nil
+}
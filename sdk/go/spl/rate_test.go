@@ -0,0 +1,75 @@
+package spl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	for in, want := range map[string]time.Duration{"90m": 90 * time.Minute, "24h": 24 * time.Hour, "7d": 7 * 24 * time.Hour} {
+		if got, err := ParseWindow(in); err != nil || got != want {
+			t.Errorf("ParseWindow(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0s", "-1h", "1 day", "d"} {
+		if _, err := ParseWindow(in); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded", in)
+		}
+	}
+}
+
+func TestRollingWindow(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(and (= (get req "action") "purchase") (< (window-count "purchase" "24h") 3))`, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates := &MemoryRates{}
+	at := func(when string) VerifyTokenResult {
+		return VerifyTokenObj(tok, map[string]any{"action": "purchase"}, VerifyTokenOptions{Rates: rates, Now: when})
+	}
+	// Three purchases late one day leave none for early the next: the
+	// window rolls instead of resetting at midnight.
+	for _, when := range []string{"2026-01-31T22:00:00Z", "2026-01-31T23:00:00Z", "2026-01-31T23:30:00Z"} {
+		if res := at(when); !res.Allow {
+			t.Fatalf("%s: expected allow, got %+v", when, res)
+		}
+	}
+	if res := at("2026-02-01T01:00:00Z"); res.Allow || res.Err != nil {
+		t.Fatalf("expected a fourth purchase within 24h to be denied, got %+v", res)
+	}
+	if res := at("2026-02-01T22:00:01Z"); !res.Allow {
+		t.Fatalf("expected a purchase once the first left the window, got %+v", res)
+	}
+	if n, _ := rates.WindowCount("purchase", 24*time.Hour, time.Date(2026, 2, 1, 22, 0, 1, 0, time.UTC)); n != 3 {
+		t.Fatalf("expected 3 purchases in the window, got %d", n)
+	}
+
+	if res := VerifyTokenObj(tok, map[string]any{"action": "purchase"}, VerifyTokenOptions{}); res.Allow || res.ErrorMessage() != "window-count requires a rate store" {
+		t.Fatalf("expected an error without a rate store, got %+v", res)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(bucket-ok? "search" 2 "1m")`, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates := &MemoryRates{}
+	at := func(when string) bool {
+		return VerifyTokenObj(tok, map[string]any{}, VerifyTokenOptions{Rates: rates, Now: when}).Allow
+	}
+	if !at("2026-01-31T12:00:00Z") || !at("2026-01-31T12:00:00Z") || at("2026-01-31T12:00:30Z") {
+		t.Fatal("expected a burst of 2, then a deny before a token refills")
+	}
+	if !at("2026-01-31T12:01:00Z") || at("2026-01-31T12:01:00Z") {
+		t.Fatal("expected one token a minute later")
+	}
+	if !at("2026-01-31T13:00:00Z") || !at("2026-01-31T13:00:00Z") || at("2026-01-31T13:00:00Z") {
+		t.Fatal("expected the bucket to refill no further than its capacity")
+	}
+	if VerifyTokenObj(tok, map[string]any{}, VerifyTokenOptions{}).Allow {
+		t.Fatal("expected bucket-ok? to be false without a rate store")
+	}
+}
@@ -0,0 +1,665 @@
+package spl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToRego translates a policy into a Rego module, package agentsafe, whose
+// allow rule holds when the policy allows, for review in tooling built
+// around Open Policy Agent. The request becomes input:
+//
+//	(and (= (get req "action") "read")
+//	     (or (<= (get req "amount") 50) (dpop_ok?)))
+//
+// becomes
+//
+//	package agentsafe
+//
+//	import rego.v1
+//
+//	default allow := false
+//
+//	allow if {
+//		input.action == "read"
+//		any_1
+//	}
+//
+//	any_1 if {
+//		input.amount <= 50
+//	}
+//
+//	any_1 if {
+//		data.crypto.dpop_ok
+//	}
+//
+// Conjunctions become rule bodies and disjunctions helper rules defined
+// once per alternative. Values SPL takes from its environment rather than
+// the request are read from data, and each is noted in the Warnings: a
+// variable such as now from data.vars, (per-day-count a d) from
+// data.per_day_count[a][d], dpop_ok? and the other zero-argument crypto
+// predicates from data.crypto, and (approval_ok? "parent") as "parent" in
+// data.approvals. Rego has no counterpart for merkle_ok?, merkle-member?,
+// vrf_ok? or range_ok?, and a policy using them is an error.
+func ToRego(src string) (*Translation, error) {
+	root, err := readPolicy(src)
+	if err != nil {
+		return nil, err
+	}
+	w := &regoWriter{t: &Translation{}}
+	body, err := w.body(root)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	b.WriteString("package agentsafe\n\nimport rego.v1\n\ndefault allow := false\n")
+	writeRegoRule(&b, "allow", body)
+	for _, r := range w.rules {
+		writeRegoRule(&b, r.name, r.body)
+	}
+	w.t.Policy = b.String()
+	return w.t, nil
+}
+
+func writeRegoRule(b *strings.Builder, name string, body []string) {
+	b.WriteString("\n" + name + " if {\n")
+	for _, s := range body {
+		b.WriteString("\t" + s + "\n")
+	}
+	b.WriteString("}\n")
+}
+
+type regoRule struct {
+	name string
+	body []string
+}
+
+type regoWriter struct {
+	t     *Translation
+	rules []regoRule
+	n     int
+}
+
+func (w *regoWriter) helper(prefix string) string {
+	w.n++
+	return prefix + "_" + strconv.Itoa(w.n)
+}
+
+// regoKeywords are the Rego words that cannot follow a dot.
+var regoKeywords = map[string]bool{
+	"as": true, "contains": true, "default": true, "else": true, "every": true,
+	"false": true, "if": true, "import": true, "in": true, "not": true,
+	"null": true, "package": true, "some": true, "true": true, "with": true,
+}
+
+func regoRef(base, name string) string {
+	if isIdent(name) && !regoKeywords[name] {
+		return base + "." + name
+	}
+	return base + "[" + quoteC(name, false) + "]"
+}
+
+// body renders n as the statements of a rule body, all of which must hold.
+func (w *regoWriter) body(n *syntaxNode) ([]string, error) {
+	op := n.op()
+	if op != "" {
+		if err := checkArity(n); err != nil {
+			return nil, err
+		}
+	}
+	args := []*syntaxNode(nil)
+	if n.isList {
+		args = n.list[1:]
+	}
+	switch op {
+	case "and":
+		if len(args) == 0 {
+			return []string{"true"}, nil
+		}
+		var stmts []string
+		for _, a := range args {
+			s, err := w.body(a)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, s...)
+		}
+		return stmts, nil
+	case "or":
+		switch len(args) {
+		case 0:
+			return []string{"false"}, nil
+		case 1:
+			return w.body(args[0])
+		}
+		name := w.helper("any")
+		for _, a := range args {
+			s, err := w.body(a)
+			if err != nil {
+				return nil, err
+			}
+			w.rules = append(w.rules, regoRule{name, s})
+		}
+		return []string{name}, nil
+	case "not":
+		s, err := w.body(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if len(s) == 1 && !strings.HasPrefix(s[0], "not ") && !strings.HasPrefix(s[0], "every ") {
+			return []string{"not " + s[0]}, nil
+		}
+		name := w.helper("all")
+		w.rules = append(w.rules, regoRule{name, s})
+		return []string{"not " + name}, nil
+	case "=", "<", "<=", ">", ">=", "before", "member", "in", "subset?", "not-member":
+		v := make([]string, len(args))
+		for i, a := range args {
+			s, err := w.value(a)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = s
+		}
+		switch op {
+		case "=":
+			return []string{v[0] + " == " + v[1]}, nil
+		case "before":
+			if !args[0].isString() && !args[1].isString() && args[0].atom != "now" && args[1].atom != "now" {
+				w.t.warn("before compares %s and %s as strings; FromRego reads < between them as a number comparison", v[0], v[1])
+			}
+			return []string{v[0] + " < " + v[1]}, nil
+		case "member", "in":
+			return []string{v[0] + " in " + v[1]}, nil
+		case "not-member":
+			// is_array is undefined, failing the rule, when the list is.
+			return []string{"is_array(" + v[1] + ")", "not " + v[0] + " in " + v[1]}, nil
+		case "subset?":
+			x := w.helper("x")
+			return []string{"every " + x + " in " + v[0] + " { " + x + " in " + v[1] + " }"}, nil
+		}
+		return []string{v[0] + " " + op + " " + v[1]}, nil
+	case "approval_ok?":
+		v, err := w.value(args[0])
+		if err != nil {
+			return nil, err
+		}
+		w.t.warn("approval_ok? reads the approvers who have approved from data.approvals")
+		return []string{v + " in data.approvals"}, nil
+	}
+	s, err := w.value(n)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+// value renders n as a Rego term.
+func (w *regoWriter) value(n *syntaxNode) (string, error) {
+	switch {
+	case n.isString():
+		s, _ := strconv.Unquote(n.atom)
+		return quoteC(s, false), nil
+	case n.isBool():
+		return strconv.FormatBool(n.atom == "#t"), nil
+	case n.isNumber():
+		f, _ := strconv.ParseFloat(n.atom, 64)
+		return splNumber(f), nil
+	case n.isSymbol():
+		if n.atom == "req" {
+			return "input", nil
+		}
+		w.t.warn("the SPL variable %s is read from data.vars", n.atom)
+		return regoRef("data.vars", n.atom), nil
+	}
+	op := n.op()
+	if op == "" {
+		return "", fmt.Errorf("%s: list does not start with an operator", n.pos)
+	}
+	if err := checkArity(n); err != nil {
+		return "", err
+	}
+	if name := cryptoPredicates[op]; name != "" {
+		w.t.warn("%s is read from data.crypto.%s", op, name)
+		return "data.crypto." + name, nil
+	}
+	v := make([]string, len(n.list)-1)
+	for i, a := range n.list[1:] {
+		s, err := w.value(a)
+		if err != nil {
+			return "", err
+		}
+		v[i] = s
+	}
+	switch op {
+	case "get":
+		if key := n.list[2]; key.isString() {
+			k, _ := strconv.Unquote(key.atom)
+			return regoRef(v[0], k), nil
+		}
+		return v[0] + "[" + v[1] + "]", nil
+	case "tuple":
+		return "[" + strings.Join(v, ", ") + "]", nil
+	case "per-day-count":
+		w.t.warn("per-day-count reads data.per_day_count[action][day], default 0")
+		return "object.get(data.per_day_count, [" + v[0] + ", " + v[1] + "], 0)", nil
+	case "merkle_ok?", "vrf_ok?", "range_ok?", "window-count", "bucket-ok?", "sum-amount", "bloom-member?", "merkle-member?":
+		return "", fmt.Errorf("%s: Rego has no counterpart for %s", n.pos, op)
+	}
+	return "", fmt.Errorf("%s: Rego cannot use %s as a value", n.pos, op)
+}
+
+// Markers in SPL source built by FromRego, resolved once every rule is read:
+// a reference to a rule (regoRuleMark name regoRuleMark), a variable bound
+// by every, and data.approvals.
+const (
+	regoRuleMark      = "\x00"
+	regoLocalMark     = "\x01"
+	regoApprovalsMark = "\x02approvals"
+)
+
+// FromRego translates a Rego module into an SPL policy that allows when the
+// module's allow rule holds, the reverse of ToRego. Rules allow refers to
+// are inlined, each definition of a rule an alternative. Only the subset of
+// Rego ToRego produces is accepted: boolean rules whose bodies compare
+// terms built from input, the data paths ToRego reads, literals and arrays,
+// with not, in and every x in a { x in b }. Packages and imports are
+// ignored.
+func FromRego(src string) (*Translation, error) {
+	toks, err := lexC(src, "#", true)
+	if err != nil {
+		return nil, err
+	}
+	p := &regoParser{cParser: cParser{toks: toks}, rules: map[string][]string{}}
+	if err := p.module(); err != nil {
+		return nil, err
+	}
+	allow, err := p.resolve(regoRuleMark+"allow"+regoRuleMark, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(allow, regoLocalMark) || strings.Contains(allow, regoApprovalsMark) {
+		return nil, fmt.Errorf("module uses a variable or data.approvals outside the forms SPL can express")
+	}
+	out, err := Format(allow)
+	if err != nil {
+		return nil, err
+	}
+	return &Translation{Policy: out}, nil
+}
+
+type regoParser struct {
+	cParser
+	rules  map[string][]string
+	locals map[string]bool
+}
+
+// resolve replaces the rule references in s with the rules' definitions.
+func (p *regoParser) resolve(s string, active map[string]bool) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, regoRuleMark)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		j := strings.Index(s[i+1:], regoRuleMark) + i + 1
+		name := s[i+1 : j]
+		defs, ok := p.rules[name]
+		if !ok && name != "allow" {
+			return "", fmt.Errorf("undefined rule %s", name)
+		}
+		if active[name] {
+			return "", fmt.Errorf("rule %s is recursive", name)
+		}
+		active[name] = true
+		var alts []string
+		for _, d := range defs {
+			r, err := p.resolve(d, active)
+			if err != nil {
+				return "", err
+			}
+			alts = append(alts, r)
+		}
+		delete(active, name)
+		b.WriteString(s[:i])
+		b.WriteString(splJoin("or", alts, "#f"))
+		s = s[j+1:]
+	}
+}
+
+func (p *regoParser) module() error {
+	for {
+		p.skipNewlines()
+		tok := p.peek()
+		switch {
+		case tok.kind == 0:
+			return nil
+		case p.accept("package"):
+			for p.peek().kind != '\n' && p.peek().kind != 0 {
+				p.next()
+			}
+		case p.accept("import"):
+			var path []string
+			for p.peek().kind != '\n' && p.peek().kind != 0 {
+				path = append(path, p.next().text)
+			}
+			if ref := strings.Join(path, ""); ref != "rego.v1" && !strings.HasPrefix(ref, "future.keywords") {
+				return fmt.Errorf("%s: SPL has no counterpart for import %s", tok.pos, ref)
+			}
+		case p.accept("default"):
+			name := p.next()
+			if !p.accept(":=") && !p.accept("=") {
+				return p.errorf("expected := after default %s", name.text)
+			}
+			v, err := p.term()
+			if err != nil {
+				return err
+			}
+			if v != "#f" {
+				return fmt.Errorf("%s: only a default of false is supported", name.pos)
+			}
+		case tok.kind == 'i':
+			if err := p.rule(); err != nil {
+				return err
+			}
+		default:
+			return p.errorf("unexpected %s", tok)
+		}
+		if k := p.peek().kind; k != '\n' && k != 0 {
+			return p.errorf("unexpected %s", p.peek())
+		}
+	}
+}
+
+func (p *regoParser) rule() error {
+	name := p.next()
+	if p.accept(":=") || p.accept("=") {
+		if v, err := p.term(); err != nil {
+			return err
+		} else if v != "#t" {
+			return fmt.Errorf("%s: only boolean rules are supported", name.pos)
+		}
+	}
+	var body []string
+	var err error
+	switch {
+	case p.accept("if"):
+		if p.is("{") {
+			body, err = p.body()
+		} else {
+			var s string
+			s, err = p.statement()
+			body = []string{s}
+		}
+	case p.is("{"):
+		body, err = p.body()
+	case p.peek().kind != '\n' && p.peek().kind != 0:
+		return p.errorf("SPL has no counterpart for rule %s", name.text)
+	}
+	if err != nil {
+		return err
+	}
+	if p.is("else") {
+		return p.errorf("SPL has no counterpart for else")
+	}
+	p.rules[name.text] = append(p.rules[name.text], splJoin("and", body, "#t"))
+	return nil
+}
+
+func (p *regoParser) body() ([]string, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var stmts []string
+	for {
+		p.skipNewlines()
+		for p.accept(";") {
+			p.skipNewlines()
+		}
+		if p.accept("}") {
+			return stmts, nil
+		}
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+		if k := p.peek().kind; k != '\n' && !p.is(";") && !p.is("}") {
+			return nil, p.errorf("unexpected %s", p.peek())
+		}
+	}
+}
+
+func (p *regoParser) statement() (string, error) {
+	switch {
+	case p.accept("not"):
+		s, err := p.statement()
+		if err != nil {
+			return "", err
+		}
+		return "(not " + s + ")", nil
+	case p.is("every"):
+		return p.every()
+	case p.is("some"), p.is("with"):
+		return "", p.errorf("SPL has no counterpart for %s", p.peek().text)
+	}
+	a, err := p.term()
+	if err != nil {
+		return "", err
+	}
+	op := p.peek()
+	switch {
+	case p.is("=="), p.is("!="), p.is("<"), p.is("<="), p.is(">"), p.is(">="), p.is("in"):
+	case p.is(":="), p.is("="):
+		return "", p.errorf("SPL has no counterpart for assignment")
+	default:
+		return a, nil
+	}
+	p.next()
+	b, err := p.term()
+	if err != nil {
+		return "", err
+	}
+	if op.text == "in" {
+		if b == regoApprovalsMark {
+			return "(approval_ok? " + a + ")", nil
+		}
+		return "(member " + a + " " + b + ")", nil
+	}
+	// Rego compares strings by their bytes, as before does.
+	str := strings.HasPrefix(a, `"`) || strings.HasPrefix(b, `"`) || a == "now" || b == "now"
+	switch {
+	case op.text == "==":
+		return "(= " + a + " " + b + ")", nil
+	case op.text == "!=":
+		return "(not (= " + a + " " + b + "))", nil
+	case str && op.text == "<":
+		return "(before " + a + " " + b + ")", nil
+	case str && op.text == ">":
+		return "(before " + b + " " + a + ")", nil
+	case str && op.text == "<=":
+		return "(not (before " + b + " " + a + "))", nil
+	case str && op.text == ">=":
+		return "(not (before " + a + " " + b + "))", nil
+	}
+	return "(" + op.text + " " + a + " " + b + ")", nil
+}
+
+// every reads every x in a { x in b }, which is (subset? a b).
+func (p *regoParser) every() (string, error) {
+	pos := p.next().pos
+	x := p.next()
+	if x.kind != 'i' {
+		return "", p.errorf("expected a variable")
+	}
+	if err := p.expect("in"); err != nil {
+		return "", err
+	}
+	a, err := p.term()
+	if err != nil {
+		return "", err
+	}
+	if p.locals == nil {
+		p.locals = map[string]bool{}
+	}
+	p.locals[x.text] = true
+	body, err := p.body()
+	delete(p.locals, x.text)
+	if err != nil {
+		return "", err
+	}
+	prefix := "(member " + regoLocalMark + x.text + " "
+	if len(body) != 1 || !strings.HasPrefix(body[0], prefix) || strings.Contains(body[0][len(prefix):], regoLocalMark) {
+		return "", fmt.Errorf("%s: SPL can only express every %s in a { %s in b }", pos, x.text, x.text)
+	}
+	return "(subset? " + a + " " + strings.TrimSuffix(body[0][len(prefix):], ")") + ")", nil
+}
+
+func (p *regoParser) term() (string, error) {
+	tok := p.next()
+	switch tok.kind {
+	case 'n':
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return "", fmt.Errorf("%s: invalid number %s", tok.pos, tok.text)
+		}
+		return splNumber(f), nil
+	case 's':
+		s, err := splString(tok.text)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", tok.pos, err)
+		}
+		return s, nil
+	case 'p':
+		switch tok.text {
+		case "-":
+			if p.peek().kind == 'n' {
+				n, err := p.term()
+				return "-" + n, err
+			}
+		case "(":
+			s, err := p.statement()
+			if err != nil {
+				return "", err
+			}
+			return s, p.expect(")")
+		case "[":
+			var items []string
+			for !p.accept("]") {
+				if len(items) > 0 {
+					if err := p.expect(","); err != nil {
+						return "", err
+					}
+				}
+				s, err := p.term()
+				if err != nil {
+					return "", err
+				}
+				items = append(items, s)
+			}
+			return "(tuple" + strings.TrimRight(" "+strings.Join(items, " "), " ") + ")", nil
+		}
+		return "", fmt.Errorf("%s: unexpected %s", tok.pos, tok)
+	case 'i':
+		switch tok.text {
+		case "true":
+			return "#t", nil
+		case "false":
+			return "#f", nil
+		case "input":
+			return p.ref("req")
+		case "data":
+			return p.data()
+		case "object":
+			return p.objectGet(tok.pos)
+		}
+		if p.locals[tok.text] {
+			return regoLocalMark + tok.text, nil
+		}
+		if p.is(".") || p.is("[") || p.is("(") {
+			return "", p.errorf("SPL has no counterpart for %s", tok.text)
+		}
+		return regoRuleMark + tok.text + regoRuleMark, nil
+	}
+	return "", fmt.Errorf("%s: unexpected %s", tok.pos, tok)
+}
+
+// ref reads the attribute accesses following base.
+func (p *regoParser) ref(base string) (string, error) {
+	for {
+		var key string
+		switch {
+		case p.accept("."):
+			tok := p.next()
+			if tok.kind != 'i' {
+				return "", p.errorf("expected an attribute name")
+			}
+			s, err := splString(tok.text)
+			if err != nil {
+				return "", err
+			}
+			key = s
+		case p.accept("["):
+			s, err := p.term()
+			if err != nil {
+				return "", err
+			}
+			if err := p.expect("]"); err != nil {
+				return "", err
+			}
+			key = s
+		default:
+			return base, nil
+		}
+		base = "(get " + base + " " + key + ")"
+	}
+}
+
+// data reads the data paths ToRego uses.
+func (p *regoParser) data() (string, error) {
+	pos := p.peek().pos
+	path := []string{}
+	for len(path) < 2 && p.accept(".") {
+		path = append(path, p.next().text)
+	}
+	switch {
+	case len(path) == 2 && path[0] == "vars":
+		return p.ref(path[1])
+	case len(path) == 2 && path[0] == "crypto":
+		for op, name := range cryptoPredicates {
+			if name == path[1] {
+				return "(" + op + ")", nil
+			}
+		}
+	case len(path) == 1 && path[0] == "approvals":
+		return regoApprovalsMark, nil
+	}
+	return "", fmt.Errorf("%s: SPL has no counterpart for data.%s", pos, strings.Join(path, "."))
+}
+
+// objectGet reads object.get(data.per_day_count, [action, day], 0).
+func (p *regoParser) objectGet(pos Position) (string, error) {
+	bad := fmt.Errorf("%s: SPL can only express object.get(data.per_day_count, [action, day], 0)", pos)
+	for _, t := range []string{".", "get", "(", "data", ".", "per_day_count", ",", "["} {
+		if !p.accept(t) {
+			return "", bad
+		}
+	}
+	action, err := p.term()
+	if err != nil {
+		return "", err
+	}
+	if !p.accept(",") {
+		return "", bad
+	}
+	day, err := p.term()
+	if err != nil {
+		return "", err
+	}
+	if !p.accept("]") || !p.accept(",") || p.next().text != "0" || !p.accept(")") {
+		return "", bad
+	}
+	return "(per-day-count " + action + " " + day + ")", nil
+}
@@ -0,0 +1,355 @@
+package spl
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestFromStruct converts v, a struct or a pointer to one, to the
+// request map policies read, so services need not build map[string]any by
+// hand. Each exported field becomes a key named by its spl tag, else its
+// json tag, else the field's name; a tag of "-" leaves the field out, and
+// the omitempty option leaves it out when it is the zero value:
+//
+//	type Payment struct {
+//		Action    string    `spl:"action"`
+//		Amount    int64     `spl:"amount"`
+//		Recipient string    `spl:"recipient"`
+//		Deadline  time.Time `spl:"deadline,omitempty"`
+//		Memo      string    `spl:"-"`
+//	}
+//
+// Values take the shapes a JSON request decodes to: numbers become
+// float64, time.Time an RFC 3339 string, slices and arrays []any, and
+// structs and maps with string keys map[string]any. Embedded structs
+// without a tag have their fields promoted, as in encoding/json. A nil
+// pointer, slice or map becomes nil. Channels, functions and complex
+// numbers are errors.
+func RequestFromStruct(v any) (map[string]any, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("spl: RequestFromStruct of %T, not a struct", v)
+	}
+	req, err := encodeValue(rv)
+	if err != nil {
+		return nil, err
+	}
+	return req.(map[string]any), nil
+}
+
+// RequestToStruct decodes req into the struct v points to, the reverse of
+// RequestFromStruct under the same field names. Keys with no field are
+// ignored. A number decodes into an integer field only if it is whole and
+// in range, and a string into a time.Time only if it is RFC 3339.
+func RequestToStruct(req map[string]any, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("spl: RequestToStruct into %T, not a pointer to a struct", v)
+	}
+	return decodeValue(req, rv.Elem(), "")
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// structField is an encoded field of a struct: its key, and the index path
+// to it through embedded structs.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields caches the fields of each struct type, by reflect.Type.
+var structFields sync.Map
+
+func fieldsOf(t reflect.Type) []structField {
+	if f, ok := structFields.Load(t); ok {
+		return f.([]structField)
+	}
+	var fields []structField
+	seen := map[string]bool{}
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		var embedded [][]int
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag, ok := f.Tag.Lookup("spl")
+			if !ok {
+				tag, ok = f.Tag.Lookup("json")
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if name == "-" && opts == "" {
+				continue
+			}
+			at := append(index[:len(index):len(index)], i)
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if f.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != timeType {
+				embedded = append(embedded, at)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			fields = append(fields, structField{name: name, index: at, omitEmpty: strings.Contains(","+opts+",", ",omitempty,")})
+		}
+		// Promote the fields of untagged embedded structs after the outer
+		// struct's own, which win.
+		for _, at := range embedded {
+			ft := t.FieldByIndex(at[len(at)-1:]).Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			walk(ft, at)
+		}
+	}
+	walk(t, nil)
+	structFields.Store(t, fields)
+	return fields
+}
+
+// fieldByIndex returns the field at index, or false if it lies behind a
+// nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func encodeValue(v reflect.Value) (any, error) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return encodeValue(v.Elem())
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			x, err := encodeValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = x
+		}
+		return out, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("spl: map key type %s is not a string", v.Type().Key())
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		out := make(map[string]any, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			x, err := encodeValue(it.Value())
+			if err != nil {
+				return nil, err
+			}
+			out[it.Key().String()] = x
+		}
+		return out, nil
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+		}
+		out := map[string]any{}
+		for _, f := range fieldsOf(v.Type()) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			x, err := encodeValue(fv)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			out[f.name] = x
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("spl: cannot use %s in a request", v.Type())
+}
+
+func decodeValue(x any, v reflect.Value, path string) error {
+	fail := func(err error) error {
+		if path == "" {
+			return err
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	mismatch := func() error {
+		return fail(fmt.Errorf("cannot decode %T into %s", x, v.Type()))
+	}
+	if x == nil {
+		v.SetZero()
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeValue(x, v.Elem(), path)
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return mismatch()
+		}
+		v.Set(reflect.ValueOf(x))
+		return nil
+	case reflect.Bool:
+		b, ok := x.(bool)
+		if !ok {
+			return mismatch()
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := x.(string)
+		if !ok {
+			return mismatch()
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := number(x)
+		if !ok {
+			return mismatch()
+		}
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || v.OverflowInt(int64(f)) {
+			return fail(fmt.Errorf("%v is not a whole number in range of %s", f, v.Type()))
+		}
+		v.SetInt(int64(f))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f, ok := number(x)
+		if !ok {
+			return mismatch()
+		}
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 || v.OverflowUint(uint64(f)) {
+			return fail(fmt.Errorf("%v is not a whole number in range of %s", f, v.Type()))
+		}
+		v.SetUint(uint64(f))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, ok := number(x)
+		if !ok {
+			return mismatch()
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.Slice, reflect.Array:
+		items, ok := x.([]any)
+		if !ok {
+			return mismatch()
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(items), len(items)))
+		} else if len(items) > v.Len() {
+			return fail(fmt.Errorf("%d items do not fit in %s", len(items), v.Type()))
+		}
+		for i, item := range items {
+			if err := decodeValue(item, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		m, ok := x.(map[string]any)
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return mismatch()
+		}
+		out := reflect.MakeMapWithSize(v.Type(), len(m))
+		for k, item := range m {
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(item, e, join(path, k)); err != nil {
+				return err
+			}
+			out.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), e)
+		}
+		v.Set(out)
+		return nil
+	case reflect.Struct:
+		if v.Type() == timeType {
+			s, ok := x.(string)
+			if !ok {
+				return mismatch()
+			}
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return fail(err)
+			}
+			v.Set(reflect.ValueOf(t))
+			return nil
+		}
+		m, ok := x.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		for _, f := range fieldsOf(v.Type()) {
+			item, ok := m[f.name]
+			if !ok {
+				continue
+			}
+			fv := v
+			for i, idx := range f.index {
+				if i > 0 && fv.Kind() == reflect.Pointer {
+					if fv.IsNil() {
+						fv.Set(reflect.New(fv.Type().Elem()))
+					}
+					fv = fv.Elem()
+				}
+				fv = fv.Field(idx)
+			}
+			if err := decodeValue(item, fv, join(path, f.name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return mismatch()
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
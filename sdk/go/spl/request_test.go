@@ -0,0 +1,92 @@
+package spl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type reqBase struct {
+	Action string `spl:"action"`
+	Actor  string `json:"actor"`
+}
+
+type payment struct {
+	reqBase
+	Amount    int64             `spl:"amount"`
+	Recipient string            `spl:"recipient"`
+	Scopes    []string          `spl:"scopes,omitempty"`
+	Deadline  time.Time         `spl:"deadline,omitempty"`
+	Labels    map[string]string `spl:"labels,omitempty"`
+	User      *struct {
+		Role string `spl:"role"`
+	} `spl:"user,omitempty"`
+	Memo    string `spl:"-"`
+	Count   uint8
+	private string
+}
+
+func TestRequestFromStruct(t *testing.T) {
+	p := payment{
+		reqBase:   reqBase{Action: "pay", Actor: "agent"},
+		Amount:    50,
+		Recipient: "niece@example.com",
+		Deadline:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		User: &struct {
+			Role string `spl:"role"`
+		}{"admin"},
+		Memo:  "secret",
+		Count: 3,
+	}
+	req, err := RequestFromStruct(&p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"action": "pay", "actor": "agent", "amount": 50.0, "recipient": "niece@example.com",
+		"deadline": "2026-01-02T03:04:05Z", "user": map[string]any{"role": "admin"}, "Count": 3.0,
+	}
+	if !reflect.DeepEqual(req, want) {
+		t.Fatalf("got %#v", req)
+	}
+
+	ast, _ := Parse(`(and (= (get req "action") "pay") (<= (get req "amount") 50) (= (get (get req "user") "role") "admin"))`)
+	if allow, err := Verify(ast, Env{Req: req}); err != nil || !allow {
+		t.Fatalf("expected the struct request to be allowed, got %v, %v", allow, err)
+	}
+
+	var back payment
+	if err := RequestToStruct(req, &back); err != nil {
+		t.Fatal(err)
+	}
+	p.Memo = ""
+	if !reflect.DeepEqual(back, p) {
+		t.Fatalf("round trip gave %+v, want %+v", back, p)
+	}
+
+	for _, bad := range []any{nil, 5, map[string]any{}, struct{ C chan int }{}, struct{ M map[int]int }{map[int]int{}}} {
+		if _, err := RequestFromStruct(bad); err == nil {
+			t.Errorf("expected %T to be refused", bad)
+		}
+	}
+}
+
+func TestRequestToStructErrors(t *testing.T) {
+	var p payment
+	if err := RequestToStruct(map[string]any{}, p); err == nil {
+		t.Fatal("expected a non-pointer to be refused")
+	}
+	for want, req := range map[string]map[string]any{
+		"amount: 2.5 is not a whole number":   {"amount": 2.5},
+		"Count: 300 is not a whole number":    {"Count": 300.0},
+		"recipient: cannot decode float64":    {"recipient": 5.0},
+		"deadline: parsing time":              {"deadline": "tomorrow"},
+		"scopes[1]: cannot decode bool":       {"scopes": []any{"read", true}},
+		"labels.k: cannot decode []interface": {"labels": map[string]any{"k": []any{}}},
+	} {
+		if err := RequestToStruct(req, &p); err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("expected %q, got %v", want, err)
+		}
+	}
+}
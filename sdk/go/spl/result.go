@@ -0,0 +1,101 @@
+package spl
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ResultPolicy is checked against what a guarded call returned, before the
+// agent sees it. It states limits a request cannot, such as how many
+// records a query may return or what kinds of data may leave: toolguard
+// applies one to tool outputs and splhttp to response bodies.
+type ResultPolicy struct {
+	// Policy is evaluated with req holding the call's request and its
+	// result, as DescribeResult describes it:
+	//
+	//	{"request": {...},
+	//	 "result": {"value": [...], "count": 120, "bytes": 5120, "classes": ["pii"]}}
+	//
+	// so (<= (get (get req "result") "count") 100) allows at most 100
+	// records.
+	Policy string
+	// Vars binds the policy's variables.
+	Vars map[string]any
+	// Classify, when set, names the classes of data in a result, such as
+	// "pii" or "secret", for the policy to read as "classes".
+	Classify func(result any) []string
+	// ReportOnly lets results the policy denies through; the guards still
+	// report them.
+	ReportOnly bool
+	// Clock, when set, is the policy's now; see Env.Clock.
+	Clock func() time.Time
+}
+
+// DescribeResult describes a call's result, as decoded JSON or any value
+// that marshals to it, for a ResultPolicy:
+//
+//   - value: the result itself
+//   - count: how many records it holds: an array's length; for an object,
+//     the length of its longest array field, or 1 if it has none; 1 for
+//     any other value, and 0 for nil
+//   - bytes: the length of its JSON encoding
+//   - classes: what classify names, when set
+func DescribeResult(result any, classify func(any) []string) map[string]any {
+	b, _ := json.Marshal(result)
+	var v any
+	if json.Unmarshal(b, &v) != nil {
+		v = nil
+	}
+	d := map[string]any{"value": v, "count": float64(count(v)), "bytes": float64(len(b))}
+	if classify != nil {
+		classes := []any{}
+		for _, c := range classify(v) {
+			classes = append(classes, c)
+		}
+		d["classes"] = classes
+	}
+	return d
+}
+
+func count(v any) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case []any:
+		return len(v)
+	case map[string]any:
+		n := 1
+		for _, x := range v {
+			if l, ok := x.([]any); ok && len(l) > n {
+				n = len(l)
+			}
+		}
+		return n
+	}
+	return 1
+}
+
+// Check evaluates p against the result of the call req describes. It
+// returns nil when the policy allows the result, an error of kind ErrResult
+// when it denies it, and an error of the evaluation's kind when the policy
+// cannot be evaluated, which callers should treat as a denial. ReportOnly
+// does not change what Check returns.
+func (p *ResultPolicy) Check(req map[string]any, result any) error {
+	ast, err := DefaultPolicyCache.Parse(p.Policy)
+	if err != nil {
+		return err
+	}
+	env := Env{
+		Req:   map[string]any{"request": req, "result": DescribeResult(result, p.Classify)},
+		Vars:  p.Vars,
+		Clock: p.Clock,
+	}
+	allow, err := Verify(ast, env)
+	if err != nil {
+		return err
+	}
+	if !allow {
+		return kindf(ErrResult, "result policy denied the result")
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package spl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDescribeResult(t *testing.T) {
+	for _, c := range []struct {
+		result any
+		count  float64
+	}{
+		{nil, 0},
+		{"text", 1},
+		{[]int{1, 2, 3}, 3},
+		{map[string]any{"rows": []any{1, 2}, "errors": []any{1}}, 2},
+		{map[string]any{"id": 7}, 1},
+	} {
+		d := DescribeResult(c.result, nil)
+		if d["count"] != c.count {
+			t.Errorf("%v: count %v, want %v", c.result, d["count"], c.count)
+		}
+		if _, ok := d["classes"]; ok {
+			t.Errorf("%v: expected no classes without a classifier", c.result)
+		}
+	}
+	d := DescribeResult(map[string]string{"a": "b"}, func(any) []string { return []string{"pii"} })
+	if d["bytes"] != 9.0 || len(d["classes"].([]any)) != 1 {
+		t.Fatalf("unexpected description %v", d)
+	}
+}
+
+func TestResultPolicyCheck(t *testing.T) {
+	p := &ResultPolicy{Policy: `(and (= (get (get req "request") "tool") "query") (<= (get (get req "result") "count") limit))`, Vars: map[string]any{"limit": 2.0}}
+	req := map[string]any{"tool": "query"}
+	if err := p.Check(req, []any{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Check(req, []any{1, 2, 3}); !errors.Is(err, ErrResult) || CodeOf(err) != CodeResult {
+		t.Fatalf("expected ErrResult, got %v", err)
+	}
+	if err := (&ResultPolicy{Policy: `(<=`}).Check(req, nil); err == nil || errors.Is(err, ErrResult) {
+		t.Fatalf("expected a syntax error, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package spl
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// TokenSchema is the JSON Schema (draft 2020-12) of the token format, for
+// SDKs and tools outside Go to validate against. Its $id is TokenSchemaID;
+// a change that rejects tokens it used to accept gets a new ID.
+//
+//go:embed token.schema.json
+var TokenSchema string
+
+// TokenSchemaID identifies the version of TokenSchema.
+const TokenSchemaID = "urn:agent-safe:token:v1"
+
+// SchemaProblem is one way token JSON departs from TokenSchema.
+type SchemaProblem struct {
+	// Path locates the value, as a JSON Pointer: "/x5c/1". It is empty for
+	// the token itself.
+	Path string `json:"path"`
+	// Kind is "syntax", "unknown-field", "missing-field", "type" or
+	// "invalid" (a value of the right type the schema does not allow).
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+func (p SchemaProblem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *additionalProperties  `json:"additionalProperties"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []string               `json:"enum"`
+	Pattern              string                 `json:"pattern"`
+	MinLength            int                    `json:"minLength"`
+	Format               string                 `json:"format"`
+}
+
+// additionalProperties is a schema's additionalProperties: false to refuse
+// fields not listed, or a schema they must match.
+type additionalProperties struct {
+	allowed bool
+	schema  *jsonSchema
+}
+
+func (a *additionalProperties) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return json.Unmarshal(b, &a.schema)
+}
+
+var tokenSchema = sync.OnceValue(func() *jsonSchema {
+	var s jsonSchema
+	if err := json.Unmarshal([]byte(TokenSchema), &s); err != nil {
+		panic("spl: invalid embedded token schema: " + err.Error())
+	}
+	return &s
+})
+
+// ValidateTokenJSON checks token JSON against TokenSchema and returns every
+// unknown field, missing required field and value of the wrong type or
+// form, or nil if there are none. It checks only the shape of the token,
+// not its signature, so that a token another SDK produced can be debugged
+// before verification rejects it with a less specific error.
+func ValidateTokenJSON(data []byte) []SchemaProblem {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return []SchemaProblem{{Kind: "syntax", Message: "invalid JSON: " + err.Error()}}
+	}
+	if dec.More() {
+		return []SchemaProblem{{Kind: "syntax", Message: "invalid JSON: data after the token"}}
+	}
+	var problems []SchemaProblem
+	validateSchema(tokenSchema(), v, "", &problems)
+	return problems
+}
+
+func validateSchema(s *jsonSchema, v any, path string, problems *[]SchemaProblem) {
+	report := func(kind, format string, args ...any) {
+		*problems = append(*problems, SchemaProblem{Path: path, Kind: kind, Message: fmt.Sprintf(format, args...)})
+	}
+	if got := jsonType(v); got != s.Type {
+		report("type", "expected %s, got %s", s.Type, got)
+		return
+	}
+	switch t := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := t[name]; !ok {
+				report("missing-field", "missing required field %q", name)
+			}
+		}
+		names := make([]string, 0, len(t))
+		for name := range t {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+			if prop, ok := s.Properties[name]; ok {
+				validateSchema(prop, t[name], p, problems)
+			} else if extra := s.AdditionalProperties; extra != nil && extra.schema != nil {
+				validateSchema(extra.schema, t[name], p, problems)
+			} else if extra != nil && !extra.allowed {
+				*problems = append(*problems, SchemaProblem{Path: p, Kind: "unknown-field", Message: fmt.Sprintf("unknown field %q", name)})
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range t {
+				validateSchema(s.Items, item, fmt.Sprintf("%s/%d", path, i), problems)
+			}
+		}
+	case string:
+		switch {
+		case len(s.Enum) > 0 && !contains(s.Enum, t):
+			report("invalid", "%q is not one of %s", t, strings.Join(s.Enum, ", "))
+		case utf8.RuneCountInString(t) < s.MinLength:
+			report("invalid", "must not be empty")
+		case s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(t):
+			report("invalid", "%q does not match %s", t, s.Pattern)
+		case s.Format == "date-time" && !isDateTime(t):
+			report("invalid", "%q is not an RFC 3339 time", t)
+		}
+	}
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	}
+	return "null"
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isDateTime(s string) bool {
+	_, err := time.Parse(time.RFC3339, s)
+	return err == nil
+}
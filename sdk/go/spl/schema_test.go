@@ -0,0 +1,70 @@
+package spl
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenSchemaCoversToken(t *testing.T) {
+	props := tokenSchema().Properties
+	typ := reflect.TypeOf(Token{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if props[name] == nil {
+			t.Errorf("token field %s is missing from the schema", name)
+		}
+	}
+	if len(props) != typ.NumField() {
+		t.Errorf("schema has %d properties, Token has %d fields", len(props), typ.NumField())
+	}
+}
+
+func TestValidateTokenJSON(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(get req "ok")`, priv, MintOptions{Expires: "2030-01-01T00:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hm, _ := MintHMAC(`(get req "ok")`, []byte(strings.Repeat("k", MinHMACSecretSize)), MintOptions{})
+	hm, _ = AddCaveat(hm, `(get req "small")`)
+	for _, tk := range []*Token{tok, hm} {
+		data, _ := json.Marshal(tk)
+		if problems := ValidateTokenJSON(data); problems != nil {
+			t.Fatalf("expected a minted token to validate, got %v", problems)
+		}
+	}
+
+	var m map[string]any
+	data, _ := json.Marshal(tok)
+	json.Unmarshal(data, &m)
+	delete(m, "signature")
+	m["sealed"] = "false"
+	m["expires"] = "tomorrow"
+	m["public_key"] = "not hex"
+	m["audience"] = "svc"
+	m["caveats"] = []any{"(get req \"ok\")", 3}
+	bad, _ := json.Marshal(m)
+	var got []string
+	for _, p := range ValidateTokenJSON(bad) {
+		got = append(got, p.Kind+" "+p.String())
+	}
+	want := []string{
+		`missing-field missing required field "signature"`,
+		`unknown-field /audience: unknown field "audience"`,
+		`type /caveats/1: expected string, got number`,
+		`invalid /expires: "tomorrow" is not an RFC 3339 time`,
+		`invalid /public_key: "not hex" does not match ^([0-9a-fA-F]{2})*$`,
+		`type /sealed: expected boolean, got string`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if p := ValidateTokenJSON([]byte(`{"version": "0.2.0"`)); len(p) != 1 || p[0].Kind != "syntax" {
+		t.Fatalf("expected a syntax problem, got %v", p)
+	}
+	if p := ValidateTokenJSON([]byte(`[]`)); len(p) != 1 || p[0].String() != "expected object, got array" {
+		t.Fatalf("expected a type problem, got %v", p)
+	}
+}
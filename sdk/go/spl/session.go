@@ -0,0 +1,175 @@
+package spl
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultSessionTTL is how long a session grant lasts when
+// DeriveSessionToken is given no TTL.
+const DefaultSessionTTL = 15 * time.Minute
+
+// SessionOptions configures DeriveSessionToken.
+type SessionOptions struct {
+	// Key is the hex Ed25519 public key the session presents with. Empty
+	// means a fresh key, returned in SessionToken.PrivateKey.
+	Key string
+	// Now returns the current time. Nil means time.Now.
+	Now func() time.Time
+}
+
+// SessionGrant is a token holder's delegation of its token to a session
+// key: the holder of the token's pop_key signs, for one session and until
+// Expires, that presentations signed with Key stand for its own. The
+// issuer takes no part, so a grant needs only the holder's key.
+type SessionGrant struct {
+	Session string `json:"session"`
+	// Key is the session's hex Ed25519 public key.
+	Key string `json:"key"`
+	// Expires is when the grant lapses, RFC 3339.
+	Expires string `json:"expires"`
+	// Signature is the holder's hex Ed25519 signature over
+	// SessionGrantPayload.
+	Signature string `json:"signature"`
+}
+
+// SessionGrantPayload returns the bytes a grant of t's signature covers:
+// the token's signature, so the grant is for that token alone, then the
+// session, key and expiry, each on its own line.
+func SessionGrantPayload(t *Token, g *SessionGrant) []byte {
+	return []byte("agent-safe-session\n" + t.Signature + "\n" + g.Session + "\n" + g.Key + "\n" + g.Expires)
+}
+
+// sessionNonce is what a session presentation's signature covers in place
+// of its nonce, so the presentation names its session.
+func sessionNonce(session, nonce string) string {
+	return session + "\n" + nonce
+}
+
+// SessionToken is a token delegated to one session.
+type SessionToken struct {
+	// Token is the parent, unchanged: the grant, not a new token, does
+	// the delegating.
+	Token *Token
+	Grant *SessionGrant
+	// PrivateKey is the hex Ed25519 private key of Grant.Key, when
+	// DeriveSessionToken made it. The session signs presentations with it
+	// and drops it when it ends.
+	PrivateKey string
+}
+
+// Present returns a presentation of the session token with nonce, signed
+// with s.PrivateKey.
+func (s *SessionToken) Present(nonce string) (*Presentation, error) {
+	p := &Presentation{Token: s.Token, Session: s.Grant, Nonce: nonce}
+	if err := p.Sign(s.PrivateKey); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DeriveSessionToken delegates parent to a session key for a single
+// conversation or task, so the long-lived holder key never leaves the
+// vault that keeps it. holderKey is the hex Ed25519 private key of
+// parent's pop_key; it signs a SessionGrant for sessionID that lapses after
+// ttl, or with parent if that is sooner.
+//
+// The session presents parent with the grant, signing each presentation
+// with its own key over the nonce and session ID; VerifyPresentation
+// checks the grant against parent's pop_key, so a verifier learns the
+// session from the holder's signature, not from the request. A session
+// presentation needs a nonce.
+func DeriveSessionToken(parent *Token, holderKey, sessionID string, ttl time.Duration, opts SessionOptions) (*SessionToken, error) {
+	switch {
+	case parent.PoPKey == "" || strings.HasPrefix(parent.PoPKey, spiffeScheme):
+		return nil, errors.New("session tokens need a token bound to an Ed25519 PoP key")
+	case parent.Sealed:
+		return nil, errors.New("token is sealed and cannot be delegated")
+	case sessionID == "" || strings.ContainsAny(sessionID, "\r\n"):
+		return nil, fmt.Errorf("invalid session ID %q", sessionID)
+	case ttl < 0:
+		return nil, fmt.Errorf("negative session TTL %v", ttl)
+	}
+	seed, err := decodeHex(holderKey, ed25519.SeedSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid holder private key: %w", err)
+	}
+	holder := ed25519.NewKeyFromSeed(seed)
+	_, popKey, err := resolveKeyRef(parent.PoPKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !equalHex(holder.Public().(ed25519.PublicKey), popKey) {
+		return nil, kindf(ErrUntrustedKey, "holder key does not match the token's pop_key")
+	}
+	if ttl == 0 {
+		ttl = DefaultSessionTTL
+	}
+	now := time.Now()
+	if opts.Now != nil {
+		now = opts.Now()
+	}
+	expires := now.Add(ttl).UTC().Truncate(time.Second)
+	if exp, ok := expiry(parent); ok {
+		if !exp.After(now) {
+			return nil, kindf(ErrExpired, "parent token expired at %s", parent.Expires)
+		}
+		if exp.Before(expires) {
+			expires = exp.UTC()
+		}
+	}
+	s := &SessionToken{Token: parent}
+	key := opts.Key
+	if key == "" {
+		key, s.PrivateKey = GenerateKeypair()
+	} else if _, err := decodeHex(key, ed25519.PublicKeySize); err != nil {
+		return nil, fmt.Errorf("invalid session key: %w", err)
+	}
+	g := &SessionGrant{Session: sessionID, Key: strings.ToLower(key), Expires: expires.Format(time.RFC3339)}
+	g.Signature = hex.EncodeToString(ed25519.Sign(holder, SessionGrantPayload(parent, g)))
+	s.Grant = g
+	return s, nil
+}
+
+// verifySession checks a session presentation of t in place of the
+// holder's presentation signature: the grant is signed with t's pop_key,
+// has not lapsed, and the session key signed the presentation, nonce and
+// session ID included.
+func (p *Presentation) verifySession(t *Token, payload []byte, opts *VerifyTokenOptions, now time.Time) error {
+	g := p.Session
+	if strings.HasPrefix(t.PoPKey, spiffeScheme) {
+		return kindf(ErrMalformedToken, "session grant for a SPIFFE-bound token")
+	}
+	popAlg, popKey, err := resolveKeyRef(t.PoPKey, opts.DIDResolver)
+	if err != nil {
+		return withKind(ErrMalformedToken, err)
+	}
+	if popAlg != "" && popAlg != AlgEd25519 {
+		return kindf(ErrUnsupportedAlg, "PoP key must be Ed25519")
+	}
+	if !VerifyEd25519(SessionGrantPayload(t, g), g.Signature, popKey) {
+		return kindf(ErrBadSignature, "invalid session grant signature")
+	}
+	exp, err := time.Parse(time.RFC3339, g.Expires)
+	if err != nil {
+		return kindf(ErrMalformedToken, "invalid session grant expiry: %w", err)
+	}
+	if now.After(exp) {
+		return kindf(ErrExpired, "session grant expired at %s", g.Expires)
+	}
+	switch {
+	case p.Nonce == "":
+		return kindf(ErrPoPRequired, "session presentation nonce required")
+	case opts.PresentationSignature == "":
+		return ErrPoPRequired
+	}
+	h := presentationDigest(payload, sessionNonce(g.Session, p.Nonce))
+	if !VerifyEd25519(h[:], opts.PresentationSignature, g.Key) {
+		return kindf(ErrBadSignature, "invalid session presentation signature")
+	}
+	return nil
+}
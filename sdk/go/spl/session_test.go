@@ -0,0 +1,152 @@
+package spl
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeriveSessionToken(t *testing.T) {
+	_, priv := GenerateKeypair()
+	holderPub, holderPriv := GenerateKeypair()
+	parent, err := Mint(tokenTestPolicy, priv, MintOptions{PoPKey: holderPub, Expires: "2026-06-01T12:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 6, 1, 11, 50, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	s, err := DeriveSessionToken(parent, holderPriv, "conv-42", time.Hour, SessionOptions{Now: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Token != parent || s.PrivateKey == "" || s.Grant.Session != "conv-42" {
+		t.Fatalf("expected a grant of the parent to a fresh key, got %+v", s)
+	}
+	// The parent expires before the TTL runs out.
+	if s.Grant.Expires != parent.Expires {
+		t.Fatalf("expected the parent's expiry, got %s", s.Grant.Expires)
+	}
+
+	opts := VerifyTokenOptions{Clock: clock, Replay: &claimSet{}}
+	p, err := s.Present("n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := VerifyPresentation(p, tokenTestReq(50), opts); !r.Allow {
+		t.Fatalf("expected the session's presentation to allow, got %q", r.ErrorMessage())
+	}
+	if r := VerifyPresentation(p, tokenTestReq(50), opts); r.Code != CodeReplayed {
+		t.Fatalf("expected the nonce to be claimed, got %+v", r)
+	}
+
+	// The session key signs for its own session only.
+	other := *s.Grant
+	other.Session = "conv-7"
+	forged := &Presentation{Token: parent, Session: &other, Nonce: "n2"}
+	forged.Sign(s.PrivateKey)
+	if r := VerifyPresentation(forged, tokenTestReq(50), opts); r.Code != CodeBadSignature {
+		t.Fatalf("expected a renamed session to be refused, got %+v", r)
+	}
+	// A presentation of one session cannot be passed off as another's.
+	p2, _ := s.Present("n3")
+	p2.Session = &other
+	if r := VerifyPresentation(p2, tokenTestReq(50), opts); r.Code != CodeBadSignature {
+		t.Fatalf("expected the presentation bound to its session, got %+v", r)
+	}
+	// Without a nonce a session presentation is refused, replay store or not.
+	bare := &Presentation{Token: parent, Session: s.Grant}
+	bare.Sign(s.PrivateKey)
+	if r := VerifyPresentation(bare, tokenTestReq(50), VerifyTokenOptions{Clock: clock}); r.Code != CodePoPRequired {
+		t.Fatalf("expected a nonce to be required, got %+v", r)
+	}
+	// Nor does the grant outlive its expiry.
+	if r := VerifyPresentation(p, tokenTestReq(50), VerifyTokenOptions{Now: "2026-06-01T12:00:01Z"}); r.Allow {
+		t.Fatal("expected an expired grant to deny")
+	}
+
+	short, err := DeriveSessionToken(parent, holderPriv, "conv-42", 0, SessionOptions{Now: func() time.Time { return now.Add(-time.Hour) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short.Grant.Expires != "2026-06-01T11:05:00Z" {
+		t.Fatalf("expected DefaultSessionTTL, got %s", short.Grant.Expires)
+	}
+	p, _ = short.Present("n4")
+	if r := VerifyPresentation(p, tokenTestReq(50), VerifyTokenOptions{Clock: clock}); r.Code != CodeExpired {
+		t.Fatalf("expected a lapsed grant to be refused, got %+v", r)
+	}
+}
+
+func TestSessionGrantForged(t *testing.T) {
+	_, priv := GenerateKeypair()
+	holderPub, holderPriv := GenerateKeypair()
+	_, stranger := GenerateKeypair()
+	parent, _ := Mint(tokenTestPolicy, priv, MintOptions{PoPKey: holderPub})
+	other, _ := Mint(tokenTestPolicy, priv, MintOptions{PoPKey: holderPub, Expires: "2099-01-01T00:00:00Z"})
+
+	// Only the holder can grant: a grant signed by anyone else is refused.
+	sessionPub, sessionPriv := GenerateKeypair()
+	g := &SessionGrant{Session: "s", Key: sessionPub, Expires: "2099-01-01T00:00:00Z"}
+	fake, err := DeriveSessionToken(parent, stranger, "s", 0, SessionOptions{Key: sessionPub})
+	if fake != nil || !errors.Is(err, ErrUntrustedKey) {
+		t.Fatalf("expected a stranger's key to be refused, got %v", err)
+	}
+	seed, _ := hex.DecodeString(stranger)
+	g.Signature = hex.EncodeToString(ed25519.Sign(ed25519.NewKeyFromSeed(seed), SessionGrantPayload(parent, g)))
+	p := &Presentation{Token: parent, Session: g, Nonce: "n"}
+	p.Sign(sessionPriv)
+	if r := VerifyPresentation(p, tokenTestReq(50), VerifyTokenOptions{}); r.Code != CodeBadSignature {
+		t.Fatalf("expected a grant not signed by the holder to be refused, got %+v", r)
+	}
+
+	// A grant is for the token it was signed for.
+	s, _ := DeriveSessionToken(parent, holderPriv, "s", 0, SessionOptions{})
+	p = &Presentation{Token: other, Session: s.Grant, Nonce: "n"}
+	p.Sign(s.PrivateKey)
+	if r := VerifyPresentation(p, tokenTestReq(50), VerifyTokenOptions{}); r.Code != CodeBadSignature {
+		t.Fatalf("expected a grant moved to another token to be refused, got %+v", r)
+	}
+	// And a token without a pop_key takes no grant.
+	open, _ := Mint(tokenTestPolicy, priv, MintOptions{})
+	p = &Presentation{Token: open, Session: s.Grant, Nonce: "n"}
+	p.Sign(s.PrivateKey)
+	if r := VerifyPresentation(p, tokenTestReq(50), VerifyTokenOptions{}); r.Code != CodeMalformedToken {
+		t.Fatalf("expected a grant for an unbound token to be refused, got %+v", r)
+	}
+}
+
+func TestDeriveSessionTokenErrors(t *testing.T) {
+	_, priv := GenerateKeypair()
+	holderPub, holderPriv := GenerateKeypair()
+	tok, _ := Mint(`#t`, priv, MintOptions{PoPKey: holderPub, Expires: "2026-01-01T00:00:00Z"})
+	unbound, _ := Mint(`#t`, priv, MintOptions{})
+	sealed, _ := Mint(`#t`, priv, MintOptions{PoPKey: holderPub, Sealed: true})
+	now := func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) }
+	later := func() time.Time { return time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC) }
+	opts := SessionOptions{Now: now}
+	for name, c := range map[string]struct {
+		parent *Token
+		key    string
+		id     string
+		ttl    time.Duration
+		opts   SessionOptions
+	}{
+		"unbound":     {unbound, holderPriv, "s", 0, opts},
+		"sealed":      {sealed, holderPriv, "s", 0, opts},
+		"empty id":    {tok, holderPriv, "", 0, opts},
+		"newline id":  {tok, holderPriv, "a\nb", 0, opts},
+		"negative":    {tok, holderPriv, "s", -time.Minute, opts},
+		"bad key":     {tok, "zz", "s", 0, opts},
+		"session key": {tok, holderPriv, "s", 0, SessionOptions{Key: "abc", Now: now}},
+		"expired":     {tok, holderPriv, "s", 0, SessionOptions{Now: later}},
+	} {
+		if _, err := DeriveSessionToken(c.parent, c.key, c.id, c.ttl, c.opts); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := DeriveSessionToken(tok, holderPriv, "s", 0, SessionOptions{Now: later}); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+}
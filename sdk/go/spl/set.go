@@ -0,0 +1,123 @@
+package spl
+
+import "encoding/json"
+
+// Set is a list indexed for member and in. A Vars value that is a *Set is
+// checked in constant time, where a []any list is scanned, so use one for
+// large allowlists. Elements match as with =: strings and booleans by
+// value, and numbers by value whatever their Go type. A Set must not be
+// changed once verification has started.
+type Set struct {
+	items []any
+	strs  map[string]struct{}
+	nums  map[float64]struct{}
+	bools [2]bool
+	other []any // elements with no index, scanned
+}
+
+// NewSet returns a set of items.
+func NewSet(items []any) *Set {
+	s := &Set{}
+	for _, x := range items {
+		s.Add(x)
+	}
+	return s
+}
+
+// NewStringSet returns a set of strings, such as recipient addresses.
+func NewStringSet(items ...string) *Set {
+	s := &Set{items: make([]any, 0, len(items)), strs: make(map[string]struct{}, len(items))}
+	for _, x := range items {
+		s.Add(x)
+	}
+	return s
+}
+
+// Add adds x to s unless it is already a member.
+func (s *Set) Add(x any) {
+	if s.Contains(x) {
+		return
+	}
+	s.items = append(s.items, x)
+	if f, ok := number(x); ok {
+		if s.nums == nil {
+			s.nums = map[float64]struct{}{}
+		}
+		s.nums[f] = struct{}{}
+		return
+	}
+	switch v := x.(type) {
+	case string:
+		if s.strs == nil {
+			s.strs = map[string]struct{}{}
+		}
+		s.strs[v] = struct{}{}
+	case bool:
+		if v {
+			s.bools[1] = true
+		} else {
+			s.bools[0] = true
+		}
+	default:
+		s.other = append(s.other, x)
+	}
+}
+
+// Contains reports whether x is a member of s.
+func (s *Set) Contains(x any) bool {
+	if f, ok := number(x); ok {
+		_, ok := s.nums[f]
+		return ok
+	}
+	switch v := x.(type) {
+	case string:
+		_, ok := s.strs[v]
+		return ok
+	case bool:
+		if v {
+			return s.bools[1]
+		}
+		return s.bools[0]
+	}
+	for _, e := range s.other {
+		if eq(e, x) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of members of s.
+func (s *Set) Len() int { return len(s.items) }
+
+// Items returns the members of s in the order they were added.
+func (s *Set) Items() []any { return s.items }
+
+// MarshalJSON encodes s as a list.
+func (s *Set) MarshalJSON() ([]byte, error) { return json.Marshal(s.items) }
+
+// asList returns the elements of a list value: a []any or a *Set.
+func asList(v any) ([]any, bool) {
+	switch l := v.(type) {
+	case []any:
+		return l, true
+	case *Set:
+		return l.items, true
+	}
+	return nil, false
+}
+
+// listContains reports whether list value l has a member equal to x.
+func listContains(l any, x any) bool {
+	switch v := l.(type) {
+	case *Set:
+		return v.Contains(x)
+	case []any:
+		for _, e := range v {
+			if eq(e, x) {
+				return true
+			}
+		}
+	}
+	return false
+}
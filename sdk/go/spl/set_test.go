@@ -0,0 +1,103 @@
+package spl
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestSetMatchesListMembership(t *testing.T) {
+	items := []any{"a", 2.0, 3, true, nil, []any{"x"}}
+	set := NewSet(items)
+	if set.Len() != len(items) {
+		t.Fatalf("expected %d members, got %d", len(items), set.Len())
+	}
+	for _, x := range []any{"a", "b", 2, 2.0, 3.0, 4.0, true, false, nil, []any{"x"}, "2"} {
+		if got, want := set.Contains(x), listContains(items, x); got != want {
+			t.Errorf("%#v: expected %v, got %v", x, want, got)
+		}
+	}
+	set.Add(2)
+	set.Add("a")
+	if set.Len() != len(items) {
+		t.Fatalf("expected duplicates to be ignored, got %d members", set.Len())
+	}
+	b, err := json.Marshal(NewStringSet("b", "a", "b"))
+	if err != nil || string(b) != `["b","a"]` {
+		t.Fatalf("expected [\"b\",\"a\"], got %s, %v", b, err)
+	}
+}
+
+func TestSetInPolicy(t *testing.T) {
+	env := Env{
+		Req: map[string]any{"to": "r99999@example.com", "tags": []any{"gift", "card"}},
+		Vars: map[string]any{
+			"recipients": NewStringSet(recipients(100000)...),
+			"labels":     NewStringSet("gift", "card", "food"),
+		},
+	}
+	cases := []struct {
+		src  string
+		want bool
+	}{
+		{`(member (get req "to") recipients)`, true},
+		{`(in "nobody@example.com" recipients)`, false},
+		{`(subset? (get req "tags") labels)`, true},
+		{`(subset? labels (get req "tags"))`, false},
+		{`(subset? (tuple "gift") labels)`, true},
+	}
+	for _, c := range cases {
+		ast, err := Parse(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := Verify(ast, env); err != nil || got != c.want {
+			t.Errorf("%s: expected %v, got %v, %v", c.src, c.want, got, err)
+		}
+	}
+}
+
+func TestSetSubsumes(t *testing.T) {
+	broad, _ := Parse(`(member (get req "to") allowed)`)
+	narrow, _ := Parse(`(member (get req "to") few)`)
+	vars := map[string]any{"allowed": NewStringSet("a", "b", "c"), "few": []any{"a", "c"}}
+	if !Subsumes(broad, narrow, vars) {
+		t.Fatal("expected a sub-list to be subsumed by a set")
+	}
+	if Subsumes(narrow, broad, vars) {
+		t.Fatal("expected a set not to be subsumed by a sub-list")
+	}
+}
+
+func recipients(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("r%d@example.com", i)
+	}
+	return out
+}
+
+func BenchmarkMemberLarge(b *testing.B) {
+	ast, err := Parse(`(member (get req "to") recipients)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	names := recipients(100000)
+	list := make([]any, len(names))
+	for i, n := range names {
+		list[i] = n
+	}
+	for _, c := range []struct {
+		name  string
+		value any
+	}{{"list", list}, {"set", NewStringSet(names...)}} {
+		env := Env{Req: map[string]any{"to": names[len(names)-1]}, Vars: map[string]any{"recipients": c.value}}
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if ok, err := Verify(ast, env); !ok || err != nil {
+					b.Fatal(ok, err)
+				}
+			}
+		})
+	}
+}
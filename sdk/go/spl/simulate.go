@@ -0,0 +1,102 @@
+package spl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SimulationReport is the outcome of replaying requests against an old and
+// a new policy.
+type SimulationReport struct {
+	// Requests counts the requests replayed, and Invalid the lines that
+	// were not JSON objects and were skipped.
+	Requests int `json:"requests"`
+	Invalid  int `json:"invalid"`
+	OldAllow int `json:"old_allow"`
+	NewAllow int `json:"new_allow"`
+	// NewlyAllowed and NewlyDenied count the flips each way: requests the
+	// new policy allows that the old one denied, and the reverse.
+	NewlyAllowed int            `json:"newly_allowed"`
+	NewlyDenied  int            `json:"newly_denied"`
+	Flips        []DecisionFlip `json:"flips"`
+}
+
+// DecisionFlip is a request the two policies decide differently.
+type DecisionFlip struct {
+	// Line is the request's line in the archive, counting from 1.
+	Line     int            `json:"line"`
+	Request  map[string]any `json:"request"`
+	OldAllow bool           `json:"old_allow"`
+	NewAllow bool           `json:"new_allow"`
+	// OldError and NewError are the evaluation errors, if any, that denied
+	// the request.
+	OldError string `json:"old_error,omitempty"`
+	NewError string `json:"new_error,omitempty"`
+}
+
+// Simulate replays a JSONL archive of requests, one JSON object per line,
+// against oldSrc and newSrc and reports the decisions that flip, so the
+// effect of a policy change on real traffic is known before it ships. Each
+// request is evaluated in env with its Req set to the request; evaluation
+// errors deny, as in token verification. Blank lines are skipped, as are
+// lines that do not decode, which Invalid counts. Simulate fails only if a
+// policy does not parse or requests cannot be read.
+func Simulate(oldSrc, newSrc string, requests io.Reader, env Env) (*SimulationReport, error) {
+	oldAST, err := Parse(oldSrc)
+	if err != nil {
+		return nil, fmt.Errorf("old policy: %w", err)
+	}
+	newAST, err := Parse(newSrc)
+	if err != nil {
+		return nil, fmt.Errorf("new policy: %w", err)
+	}
+	decide := func(ast Node, req map[string]any) (bool, string) {
+		e := env
+		e.Req = req
+		allow, err := Verify(ast, e)
+		if err != nil {
+			return false, err.Error()
+		}
+		return allow, ""
+	}
+
+	r := &SimulationReport{Flips: []DecisionFlip{}}
+	br := bufio.NewReader(requests)
+	for line := 1; ; line++ {
+		text, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if text = bytes.TrimSpace(text); len(text) > 0 {
+			var req map[string]any
+			if json.Unmarshal(text, &req) != nil || req == nil {
+				r.Invalid++
+			} else {
+				r.Requests++
+				f := DecisionFlip{Line: line, Request: req}
+				f.OldAllow, f.OldError = decide(oldAST, req)
+				f.NewAllow, f.NewError = decide(newAST, req)
+				if f.OldAllow {
+					r.OldAllow++
+				}
+				if f.NewAllow {
+					r.NewAllow++
+				}
+				if f.OldAllow != f.NewAllow {
+					if f.NewAllow {
+						r.NewlyAllowed++
+					} else {
+						r.NewlyDenied++
+					}
+					r.Flips = append(r.Flips, f)
+				}
+			}
+		}
+		if err == io.EOF {
+			return r, nil
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package spl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSimulate(t *testing.T) {
+	archive := `{"action": "pay", "amount": 40}
+{"action": "pay", "amount": 75}
+
+{"action": "pay", "amount": 150}
+not json
+{"action": "refund", "amount": 10}
+{"action": "pay"}`
+	r, err := Simulate(
+		`(and (= (get req "action") "pay") (<= (get req "amount") limit))`,
+		`(and (member (get req "action") (tuple "pay" "refund")) (<= (get req "amount") 50))`,
+		strings.NewReader(archive),
+		Env{Vars: map[string]any{"limit": 100.0}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Requests != 5 || r.Invalid != 1 || r.OldAllow != 3 || r.NewAllow != 3 || r.NewlyAllowed != 1 || r.NewlyDenied != 1 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	if len(r.Flips) != 2 {
+		t.Fatalf("expected 2 flips, got %+v", r.Flips)
+	}
+	if f := r.Flips[0]; f.Line != 2 || !f.OldAllow || f.NewAllow || f.Request["amount"] != 75.0 {
+		t.Fatalf("unexpected flip %+v", f)
+	}
+	if f := r.Flips[1]; f.Line != 6 || f.OldAllow || !f.NewAllow {
+		t.Fatalf("unexpected flip %+v", f)
+	}
+}
+
+func TestSimulateErrors(t *testing.T) {
+	if _, err := Simulate("(", "#t", strings.NewReader(""), Env{}); err == nil || !strings.Contains(err.Error(), "old policy") {
+		t.Fatalf("expected an old policy error, got %v", err)
+	}
+	if _, err := Simulate("#t", "(", strings.NewReader(""), Env{}); err == nil || !strings.Contains(err.Error(), "new policy") {
+		t.Fatalf("expected a new policy error, got %v", err)
+	}
+	boom := errors.New("boom")
+	if _, err := Simulate("#t", "#f", iotest.ErrReader(boom), Env{}); !errors.Is(err, boom) {
+		t.Fatalf("expected the read error, got %v", err)
+	}
+	// Evaluation errors deny, and are reported with the flip.
+	r, err := Simulate("#t", "(frobnicate)", strings.NewReader("{}"), Env{})
+	if err != nil || r.NewlyDenied != 1 || r.Flips[0].NewError == "" {
+		t.Fatalf("unexpected report %+v, %v", r, err)
+	}
+}
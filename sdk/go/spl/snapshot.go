@@ -0,0 +1,204 @@
+package spl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+// Snapshot is a copy of a verifier's durable state: counts, claimed replay
+// identifiers and revocations. Stores that implement StateStore export and
+// import it, so state can be backed up, or moved from one store to another
+// with CopyState.
+type Snapshot struct {
+	// Taken is when the snapshot was exported.
+	Taken       time.Time
+	Counters    []CounterState
+	Nonces      []NonceState
+	Revocations []string
+}
+
+// CounterState is one count in a Snapshot.
+type CounterState struct {
+	Action, Window string
+	Count          int
+	// Expires is when the store would drop the count; zero if never.
+	Expires time.Time
+}
+
+// NonceState is one claimed identifier in a Snapshot.
+type NonceState struct {
+	ID      string
+	Expires time.Time
+}
+
+// StateStore is a store whose state can be exported and imported.
+type StateStore interface {
+	// Export returns the store's state, read consistently.
+	Export() (*Snapshot, error)
+	// Import merges a snapshot into the store: a count is raised to the
+	// snapshot's if that is higher, and identifiers and revocations are
+	// added. State the store does not keep is ignored, and so are
+	// identifiers and counts that have expired. Importing a snapshot twice
+	// changes nothing the second time.
+	Import(*Snapshot) error
+}
+
+// CopyState imports src's state into dst.
+func CopyState(dst, src StateStore) error {
+	s, err := src.Export()
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err := dst.Import(s); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	return nil
+}
+
+// SnapshotVersion is the version of the binary snapshot format that
+// MarshalBinary writes.
+const SnapshotVersion = 1
+
+var snapshotMagic = []byte("ASSNAP")
+
+// MarshalBinary encodes s: the magic "ASSNAP", the format version, the
+// time taken, then the counters, nonces and revocations, each preceded by
+// its length, and a CRC-32 of everything before it. Integers are varints,
+// strings are length-prefixed, and times are Unix milliseconds, 0 for
+// none.
+func (s *Snapshot) MarshalBinary() ([]byte, error) {
+	var b []byte
+	b = append(b, snapshotMagic...)
+	b = binary.AppendUvarint(b, SnapshotVersion)
+	b = appendTime(b, s.Taken)
+	b = binary.AppendUvarint(b, uint64(len(s.Counters)))
+	for _, c := range s.Counters {
+		if c.Count < 0 {
+			return nil, fmt.Errorf("snapshot: negative count for %s in %s", c.Action, c.Window)
+		}
+		b = appendString(b, c.Action)
+		b = appendString(b, c.Window)
+		b = binary.AppendUvarint(b, uint64(c.Count))
+		b = appendTime(b, c.Expires)
+	}
+	b = binary.AppendUvarint(b, uint64(len(s.Nonces)))
+	for _, n := range s.Nonces {
+		b = appendString(b, n.ID)
+		b = appendTime(b, n.Expires)
+	}
+	b = binary.AppendUvarint(b, uint64(len(s.Revocations)))
+	for _, id := range s.Revocations {
+		b = appendString(b, id)
+	}
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b)), nil
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(binary.AppendUvarint(b, uint64(len(s))), s...)
+}
+
+func appendTime(b []byte, t time.Time) []byte {
+	if t.IsZero() {
+		return binary.AppendVarint(b, 0)
+	}
+	return binary.AppendVarint(b, t.UnixMilli())
+}
+
+// UnmarshalBinary decodes a snapshot MarshalBinary encoded, refusing
+// versions it does not know and data that fails its checksum.
+func (s *Snapshot) UnmarshalBinary(data []byte) error {
+	if len(data) < len(snapshotMagic)+4 || !bytes.HasPrefix(data, snapshotMagic) {
+		return errors.New("snapshot: not a snapshot")
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return errors.New("snapshot: checksum mismatch")
+	}
+	r := &snapshotReader{b: body[len(snapshotMagic):]}
+	if v := r.uvarint(); r.err == nil && v != SnapshotVersion {
+		return fmt.Errorf("snapshot: unsupported version %d", v)
+	}
+	out := Snapshot{Taken: r.time()}
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		c := CounterState{Action: r.string(), Window: r.string()}
+		c.Count = int(r.uvarint())
+		c.Expires = r.time()
+		if c.Count < 0 {
+			r.err = errors.New("count out of range")
+		}
+		out.Counters = append(out.Counters, c)
+	}
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		out.Nonces = append(out.Nonces, NonceState{ID: r.string(), Expires: r.time()})
+	}
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		out.Revocations = append(out.Revocations, r.string())
+	}
+	if r.err == nil && len(r.b) != 0 {
+		r.err = errors.New("trailing data")
+	}
+	if r.err != nil {
+		return fmt.Errorf("snapshot: %w", r.err)
+	}
+	*s = out
+	return nil
+}
+
+// snapshotReader decodes a snapshot body, keeping the first error.
+type snapshotReader struct {
+	b   []byte
+	err error
+}
+
+func (r *snapshotReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errors.New("truncated")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+// count reads a length, which cannot exceed the bytes left.
+func (r *snapshotReader) count() uint64 {
+	n := r.uvarint()
+	if n > uint64(len(r.b)) {
+		r.err = errors.New("truncated")
+		return 0
+	}
+	return n
+}
+
+func (r *snapshotReader) string() string {
+	n := r.count()
+	if r.err != nil {
+		return ""
+	}
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+func (r *snapshotReader) time() time.Time {
+	if r.err != nil {
+		return time.Time{}
+	}
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = errors.New("truncated")
+		return time.Time{}
+	}
+	r.b = r.b[n:]
+	if v == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(v).UTC()
+}
@@ -0,0 +1,79 @@
+package spl
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotBinary(t *testing.T) {
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	s := &Snapshot{
+		Taken:       at,
+		Counters:    []CounterState{{Action: "pay", Window: "2026-10-16", Count: 3, Expires: at.Add(time.Hour)}, {Action: "read", Window: "2026-10-16", Count: 300}},
+		Nonces:      []NonceState{{ID: "sig", Expires: at.Add(time.Minute)}},
+		Revocations: []string{"abc", ""},
+	}
+	b, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Snapshot
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, s) {
+		t.Fatalf("expected %+v, got %+v", s, got)
+	}
+
+	corrupt := func(f func([]byte) []byte) []byte { return f(append([]byte(nil), b...)) }
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "not a snapshot"},
+		{"flipped bit", corrupt(func(b []byte) []byte { b[10] ^= 1; return b }), "checksum mismatch"},
+		{"truncated", b[:len(b)-5], "checksum mismatch"},
+		{"future version", resum(corrupt(func(b []byte) []byte { b[6] = 2; return b })), "unsupported version 2"},
+		{"short body", resum(append([]byte("ASSNAP\x01\x00\x05"), 0, 0, 0, 0)), "truncated"},
+	} {
+		if err := new(Snapshot).UnmarshalBinary(tc.data); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+// resum recomputes b's checksum after an edit.
+func resum(b []byte) []byte {
+	body := b[:len(b)-4]
+	return binary.BigEndian.AppendUint32(append([]byte(nil), body...), crc32.ChecksumIEEE(body))
+}
+
+func TestCopyState(t *testing.T) {
+	src, dst := &MemoryCounters{}, &MemoryCounters{}
+	for i := 0; i < 3; i++ {
+		src.CheckAndIncrement("pay", "d", 10)
+	}
+	for i := 0; i < 5; i++ {
+		dst.CheckAndIncrement("read", "d", 10)
+	}
+	dst.CheckAndIncrement("pay", "d", 10)
+	for i := 0; i < 2; i++ {
+		if err := CopyState(dst, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pay, _ := dst.Count("pay", "d")
+	read, _ := dst.Count("read", "d")
+	if pay != 3 || read != 5 {
+		t.Fatalf("expected counts raised to the snapshot's and kept, got pay %d read %d", pay, read)
+	}
+	dst.Import(&Snapshot{Counters: []CounterState{{Action: "old", Window: "d", Count: 9, Expires: time.Now().Add(-time.Second)}}})
+	if n, _ := dst.Count("old", "d"); n != 0 {
+		t.Fatalf("expected an expired count to be skipped, got %d", n)
+	}
+}
@@ -0,0 +1,209 @@
+package spl
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const spiffeScheme = "spiffe://"
+
+// SPIFFEOptions binds tokens to workload identity. A token whose pop_key is
+// a SPIFFE ID, such as spiffe://example.org/agents/billing, may only be
+// presented by the workload holding an SVID for that ID, in place of a
+// presentation signature.
+//
+// Trust is per trust domain, as in SPIFFE bundles: an SVID for
+// spiffe://example.org/... must chain to Roots["example.org"] or be signed
+// by one of JWTAuthorities["example.org"].
+type SPIFFEOptions struct {
+	// Roots are the X.509 authorities of each trusted trust domain.
+	Roots map[string]*x509.CertPool
+	// JWTAuthorities are the JWT-SVID signing keys of each trusted trust
+	// domain; a JWT-SVID's kid selects one.
+	JWTAuthorities map[string][]JWK
+	// Audience must appear in a JWT-SVID's aud claim. Required for
+	// JWT-SVIDs, which are bearer tokens.
+	Audience string
+
+	// X509SVID is the presenting workload's certificate chain, leaf first:
+	// the peer certificates of the mutual-TLS connection the token arrived
+	// on, whose handshake proved possession of the leaf key.
+	X509SVID []*x509.Certificate
+	// JWTSVID is the presenting workload's JWT-SVID, used when X509SVID is
+	// empty.
+	JWTSVID string
+}
+
+// ParseSPIFFEID checks that id is a SPIFFE ID and returns its trust domain.
+func ParseSPIFFEID(id string) (trustDomain string, err error) {
+	if !strings.HasPrefix(id, spiffeScheme) {
+		return "", fmt.Errorf("SPIFFE ID %q: scheme must be spiffe", id)
+	}
+	u, err := url.Parse(id)
+	if err != nil {
+		return "", fmt.Errorf("SPIFFE ID %q: %w", id, err)
+	}
+	switch {
+	case u.Host == "" || u.Host != strings.ToLower(u.Host) || u.Port() != "":
+		return "", fmt.Errorf("SPIFFE ID %q: invalid trust domain", id)
+	case u.User != nil || u.RawQuery != "" || u.Fragment != "" || strings.HasSuffix(id, "#"):
+		return "", fmt.Errorf("SPIFFE ID %q: must not have user info, query or fragment", id)
+	case u.Path != "" && (strings.HasSuffix(u.Path, "/") || strings.Contains(u.Path, "//")):
+		return "", fmt.Errorf("SPIFFE ID %q: invalid path", id)
+	}
+	return u.Host, nil
+}
+
+// verifyPresenter checks that the presenting workload's SVID is valid and
+// names id.
+func (o *SPIFFEOptions) verifyPresenter(id string, now time.Time) error {
+	if o == nil {
+		return errors.New("token is bound to a SPIFFE ID but no SPIFFE options are configured")
+	}
+	if _, err := ParseSPIFFEID(id); err != nil {
+		return err
+	}
+	var got string
+	var err error
+	switch {
+	case len(o.X509SVID) > 0:
+		got, err = VerifyX509SVID(o.X509SVID, o.Roots, now)
+	case o.JWTSVID != "":
+		got, err = VerifyJWTSVID(o.JWTSVID, o.JWTAuthorities, o.Audience, now)
+	default:
+		return errors.New("no SVID presented")
+	}
+	if err != nil {
+		return err
+	}
+	if got != id {
+		return fmt.Errorf("presenter is %s, token is bound to %s", got, id)
+	}
+	return nil
+}
+
+// VerifyX509SVID validates an X.509-SVID chain, leaf first, against the
+// roots of the trust domain named in the leaf and returns its SPIFFE ID.
+func VerifyX509SVID(chain []*x509.Certificate, roots map[string]*x509.CertPool, now time.Time) (string, error) {
+	if len(chain) == 0 {
+		return "", errors.New("X.509-SVID: empty chain")
+	}
+	leaf := chain[0]
+	if leaf.IsCA {
+		return "", errors.New("X.509-SVID: leaf is a CA certificate")
+	}
+	if len(leaf.URIs) != 1 {
+		return "", fmt.Errorf("X.509-SVID: leaf must have exactly one URI SAN, has %d", len(leaf.URIs))
+	}
+	id := leaf.URIs[0].String()
+	td, err := ParseSPIFFEID(id)
+	if err != nil {
+		return "", fmt.Errorf("X.509-SVID: %w", err)
+	}
+	pool := roots[td]
+	if pool == nil {
+		return "", fmt.Errorf("X.509-SVID: trust domain %s is not trusted", td)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return "", fmt.Errorf("X.509-SVID: %w", err)
+	}
+	return id, nil
+}
+
+// VerifyJWTSVID validates a JWT-SVID signed with ES256 or EdDSA by an
+// authority of the trust domain named in its sub claim, checks that
+// audience is among its aud claims and that it has not expired, and
+// returns its SPIFFE ID.
+func VerifyJWTSVID(token string, authorities map[string][]JWK, audience string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("JWT-SVID: malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	var claims struct {
+		Sub string          `json:"sub"`
+		Aud json.RawMessage `json:"aud"`
+		Exp *float64        `json:"exp"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return "", fmt.Errorf("JWT-SVID header: %w", err)
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("JWT-SVID claims: %w", err)
+	}
+	td, err := ParseSPIFFEID(claims.Sub)
+	if err != nil {
+		return "", fmt.Errorf("JWT-SVID: %w", err)
+	}
+
+	var key *JWK
+	for i, k := range authorities[td] {
+		if k.Kid == header.Kid {
+			key = &authorities[td][i]
+			break
+		}
+	}
+	if key == nil {
+		return "", fmt.Errorf("JWT-SVID: no authority %q for trust domain %s", header.Kid, td)
+	}
+	alg, pub, _, err := KeyFromJWK(key)
+	if err != nil {
+		return "", fmt.Errorf("JWT-SVID authority: %w", err)
+	}
+	if jwsAlg := map[string]string{AlgEd25519: "EdDSA", AlgES256: AlgES256}[alg]; header.Alg != jwsAlg {
+		return "", fmt.Errorf("JWT-SVID: alg %q does not match the authority's key", header.Alg)
+	}
+	sig, err := b64url.DecodeString(parts[2])
+	if err != nil || !VerifySignature(alg, []byte(parts[0]+"."+parts[1]), hex.EncodeToString(sig), pub) {
+		return "", errors.New("JWT-SVID: invalid signature")
+	}
+
+	if claims.Exp == nil {
+		return "", errors.New("JWT-SVID: missing exp")
+	}
+	if !now.Before(time.Unix(int64(*claims.Exp), 0)) {
+		return "", errors.New("JWT-SVID: expired")
+	}
+	var auds []string
+	if json.Unmarshal(claims.Aud, &auds) != nil {
+		var aud string
+		if json.Unmarshal(claims.Aud, &aud) == nil {
+			auds = []string{aud}
+		}
+	}
+	if audience == "" {
+		return "", errors.New("JWT-SVID: no audience configured")
+	}
+	for _, a := range auds {
+		if a == audience {
+			return claims.Sub, nil
+		}
+	}
+	return "", fmt.Errorf("JWT-SVID: audience %q not in aud", audience)
+}
+
+func decodeJWTPart(s string, v any) error {
+	data, err := b64url.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
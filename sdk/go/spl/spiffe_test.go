@@ -0,0 +1,164 @@
+package spl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const spiffeTestNow = "2026-01-01T00:00:00Z"
+
+// testSVID issues a trust domain CA and an X.509-SVID for id under it.
+func testSVID(t *testing.T, id string) (*x509.CertPool, []*x509.Certificate) {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Trust Domain"},
+		NotBefore:             time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, _ := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	ca, _ := x509.ParseCertificate(caDER)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	u, _ := url.Parse(id)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		URIs:         []*url.URL{u},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(leafDER)
+	return pool, []*x509.Certificate{leaf}
+}
+
+// testJWTSVID signs an ES256 JWT-SVID and returns it with its authority.
+func testJWTSVID(t *testing.T, claims map[string]any) (string, JWK) {
+	t.Helper()
+	pubHex, privHex, _ := GenerateKeypairAlg(AlgES256)
+	jwk, _ := KeyToJWK(AlgES256, pubHex, "")
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": jwk.Kid, "typ": "JWT"})
+	body, _ := json.Marshal(claims)
+	input := b64url.EncodeToString(header) + "." + b64url.EncodeToString(body)
+	priv, _ := p256PrivateKey(privHex)
+	h := sha256.Sum256([]byte(input))
+	r, s, _ := ecdsa.Sign(rand.Reader, priv, h[:])
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + b64url.EncodeToString(sig), *jwk
+}
+
+func TestParseSPIFFEID(t *testing.T) {
+	if td, err := ParseSPIFFEID("spiffe://example.org/agents/billing"); err != nil || td != "example.org" {
+		t.Fatalf("got %q, %v", td, err)
+	}
+	for _, id := range []string{
+		"https://example.org/agents",
+		"spiffe://Example.org/agents",
+		"spiffe://example.org:8443/agents",
+		"spiffe://example.org/agents/",
+		"spiffe://example.org/agents?x=1",
+		"spiffe:///agents",
+	} {
+		if _, err := ParseSPIFFEID(id); err == nil {
+			t.Errorf("%s: expected an error", id)
+		}
+	}
+}
+
+func TestSPIFFEX509Binding(t *testing.T) {
+	const id = "spiffe://example.org/agents/billing"
+	_, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{PoPKey: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, svid := testSVID(t, id)
+	_, other := testSVID(t, "spiffe://example.org/agents/marketing")
+	_, foreign := testSVID(t, id) // same ID, issued by an untrusted CA
+
+	opts := VerifyTokenOptions{Now: spiffeTestNow, SPIFFE: &SPIFFEOptions{
+		Roots:    map[string]*x509.CertPool{"example.org": pool},
+		X509SVID: svid,
+	}}
+	if r := VerifyTokenObj(tok, tokenTestReq(50), opts); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+	for name, o := range map[string]*SPIFFEOptions{
+		"other workload":    {Roots: opts.SPIFFE.Roots, X509SVID: other},
+		"untrusted CA":      {Roots: opts.SPIFFE.Roots, X509SVID: foreign},
+		"other domain root": {Roots: map[string]*x509.CertPool{"other.org": pool}, X509SVID: svid},
+		"no SVID":           {Roots: opts.SPIFFE.Roots},
+		"no options":        nil,
+	} {
+		r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{Now: spiffeTestNow, SPIFFE: o})
+		if r.Allow || !strings.HasPrefix(r.ErrorMessage(), "SPIFFE binding: ") {
+			t.Errorf("%s: expected a SPIFFE binding denial, got %+v", name, r)
+		}
+	}
+
+	if _, err := Mint(tokenTestPolicy, priv, MintOptions{PoPKey: "spiffe://example.org/"}); err == nil {
+		t.Fatal("expected an invalid SPIFFE ID to be refused at mint time")
+	}
+}
+
+func TestSPIFFEJWTBinding(t *testing.T) {
+	const id = "spiffe://example.org/agents/billing"
+	_, priv := GenerateKeypair()
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{PoPKey: id})
+	exp := time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC).Unix()
+	jwt, authority := testJWTSVID(t, map[string]any{"sub": id, "aud": []string{"payments"}, "exp": exp})
+
+	verify := func(svid string, aud string) VerifyTokenResult {
+		return VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{Now: spiffeTestNow, SPIFFE: &SPIFFEOptions{
+			JWTAuthorities: map[string][]JWK{"example.org": {authority}},
+			Audience:       aud,
+			JWTSVID:        svid,
+		}})
+	}
+	if r := verify(jwt, "payments"); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+	expired, expAuthority := testJWTSVID(t, map[string]any{"sub": id, "aud": "payments", "exp": exp - 7200})
+	unsigned, _ := testJWTSVID(t, map[string]any{"sub": id, "aud": "payments", "exp": exp})
+	tampered := jwt[:len(jwt)-4] + "AAAA"
+	for name, r := range map[string]VerifyTokenResult{
+		"wrong audience": verify(jwt, "billing"),
+		"no audience":    verify(jwt, ""),
+		"unknown kid":    verify(unsigned, "payments"),
+		"tampered":       verify(tampered, "payments"),
+		"malformed":      verify("not-a-jwt", "payments"),
+	} {
+		if r.Allow {
+			t.Errorf("%s: expected deny", name)
+		}
+	}
+	if _, err := VerifyJWTSVID(expired, map[string][]JWK{"example.org": {expAuthority}}, "payments", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("expected an expiry error, got %v", err)
+	}
+	if _, err := VerifyJWTSVID(jwt, map[string][]JWK{"example.org": {authority}}, "payments", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,9 +2,11 @@ package spl
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 // --- Parser tests ---
@@ -14,7 +16,7 @@ func TestParseNumber(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != 42.0 {
+	if n != Num(42) {
 		t.Fatalf("expected 42, got %v", n)
 	}
 }
@@ -24,7 +26,7 @@ func TestParseNegativeNumber(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != -3.14 {
+	if n != Num(-3.14) {
 		t.Fatalf("expected -3.14, got %v", n)
 	}
 }
@@ -34,7 +36,7 @@ func TestParseString(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != "hello" {
+	if n != Str("hello") {
 		t.Fatalf("expected hello, got %v", n)
 	}
 }
@@ -44,7 +46,7 @@ func TestParseBoolTrue(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != true {
+	if n != Bool(true) {
 		t.Fatalf("expected true, got %v", n)
 	}
 }
@@ -54,7 +56,7 @@ func TestParseBoolFalse(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != false {
+	if n != Bool(false) {
 		t.Fatalf("expected false, got %v", n)
 	}
 }
@@ -64,7 +66,7 @@ func TestParseSymbol(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != "foo" {
+	if s, ok := n.(*Symbol); !ok || s.Name != "foo" {
 		t.Fatalf("expected foo, got %v", n)
 	}
 }
@@ -74,14 +76,14 @@ func TestParseList(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	arr, ok := n.([]Node)
+	arr, ok := n.(List)
 	if !ok {
 		t.Fatalf("expected list, got %T", n)
 	}
 	if len(arr) != 3 {
 		t.Fatalf("expected 3 elements, got %d", len(arr))
 	}
-	if arr[0] != "and" {
+	if arr[0] != Sym("and") {
 		t.Fatalf("expected and, got %v", arr[0])
 	}
 }
@@ -91,12 +93,12 @@ func TestParseNested(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	arr := n.([]Node)
+	arr := n.(List)
 	if len(arr) != 3 {
 		t.Fatalf("expected 3 elements, got %d", len(arr))
 	}
-	inner := arr[1].([]Node)
-	if inner[0] != "=" {
+	inner := arr[1].(List)
+	if inner[0] != Sym("=") || inner[0].(*Symbol).Op != OpEq {
 		t.Fatalf("expected =, got %v", inner[0])
 	}
 }
@@ -127,12 +129,53 @@ func TestParseStringWithSpaces(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	arr := n.([]Node)
-	if arr[1] != "hello world" {
+	arr := n.(List)
+	if arr[1] != Str("hello world") {
 		t.Fatalf("expected 'hello world', got %v", arr[1])
 	}
 }
 
+func TestParseTokenBoundaries(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"(a\v\u00a0b)", `(a b)`},
+		{`(a"b c"d)`, `(a "b c" d)`},
+		{`(x -0 255 256 2.5)`, `(x -0 255 256 2.5)`},
+		{`(f inf nan)`, `(f +Inf NaN)`},
+		{"(= \"\xff\" y\xfe)", "(= \"\ufffd\" y\ufffd)"},
+		{`(and x) (or y)`, `(and x)`},
+		{`()`, `()`},
+	}
+	for _, c := range cases {
+		n, err := Parse(c.src)
+		if err != nil {
+			t.Fatalf("%q: %v", c.src, err)
+		}
+		if got := render(n); got != c.want {
+			t.Errorf("%q: expected %s, got %s", c.src, c.want, got)
+		}
+	}
+	// An unterminated string swallows the rest of the policy, split at spaces.
+	if _, err := Parse(`(and x "open (y))`); err == nil || err.Error() != "unterminated (" {
+		t.Fatalf("expected unterminated (, got %v", err)
+	}
+}
+
+func TestParseAllocations(t *testing.T) {
+	src := `(and (= (get req "action") "payments.create") (<= (get req "amount") 100))`
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := Parse(src); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// Six lists and three strings are boxed; list elements share a chunk.
+	if allocs > 10 {
+		t.Fatalf("expected at most 10 allocations, got %v", allocs)
+	}
+}
+
 // --- Eval tests ---
 
 func makeEnv() Env {
@@ -311,6 +354,40 @@ func TestEvalIn(t *testing.T) {
 	}
 }
 
+func TestEvalNotMember(t *testing.T) {
+	env := makeEnv()
+	env.Vars["blocklist"] = []any{"scam@example.com"}
+	for src, want := range map[string]bool{
+		`(not-member "niece@example.com" blocklist)`: true,
+		`(not-member "scam@example.com" blocklist)`:  false,
+		`(not-member "x" (tuple))`:                   true,
+	} {
+		ok, err := evalExpr(t, src, env)
+		if err != nil || ok != want {
+			t.Fatalf("%s: got %v, %v; want %v", src, ok, err, want)
+		}
+	}
+}
+
+func TestEvalNotMemberFailsClosed(t *testing.T) {
+	// (not (member ...)) allows when the list is missing; not-member denies.
+	if ok, err := evalExpr(t, `(not (member "scam@example.com" blocklist))`, makeEnv()); err != nil || !ok {
+		t.Fatalf("expected the negated member to allow, got %v, %v", ok, err)
+	}
+	env := makeEnv()
+	env.Vars["blocklist"] = nil
+	for _, e := range []Env{makeEnv(), env, {Strict: true}} {
+		_, err := evalExpr(t, `(not-member "scam@example.com" blocklist)`, e)
+		if !errors.Is(err, ErrUnresolvedSymbol) {
+			t.Fatalf("expected an unresolved blocklist, got %v", err)
+		}
+	}
+	env.Vars["blocklist"] = "scam@example.com"
+	if _, err := evalExpr(t, `(not-member "x" blocklist)`, env); err == nil || CodeOf(err) == CodeUnresolvedSymbol {
+		t.Fatalf("expected a non-list blocklist to fail, got %v", err)
+	}
+}
+
 func TestEvalSubsetTrue(t *testing.T) {
 	env := makeEnv()
 	env.Vars["small"] = []any{"a", "b"}
@@ -437,6 +514,75 @@ func TestGasBudgetSufficient(t *testing.T) {
 	}
 }
 
+func TestVerifyWithGasReportsUsage(t *testing.T) {
+	ast, err := Parse("(and #t #t)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, used, err := VerifyWithGas(ast, makeEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One unit for the and, one per argument.
+	if used != 3 {
+		t.Fatalf("expected 3 gas used, got %d", used)
+	}
+
+	env := makeEnv()
+	env.MaxGas = 5
+	ast, _ = Parse("(and #t #t #t #t #t #t #t #t #t #t)")
+	if _, used, _ := VerifyWithGas(ast, env); used != 5 {
+		t.Fatalf("expected exhausted budget to report 5, got %d", used)
+	}
+}
+
+func TestMemoizeRepeatedGet(t *testing.T) {
+	ast, err := Parse(`(and (> (get req "amount") 0) (<= (get req "amount") 50) (= (tuple (get req "amount") 1) (tuple (get req "amount") 1)))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clauses := ast.(List)
+	first, second := clauses[1].(List)[1].(List), clauses[2].(List)[1].(List)
+	if &first[0] != &second[0] {
+		t.Fatal("expected repeated gets to parse to one node")
+	}
+	env := makeEnv()
+	env.Req = map[string]any{"amount": 25.0}
+	allow, plain, err := VerifyWithGas(ast, env)
+	if err != nil || !allow {
+		t.Fatalf("expected allow, got %v, %v", allow, err)
+	}
+	env.Memoize = true
+	allow, memo, err := VerifyWithGas(ast, env)
+	if err != nil || !allow {
+		t.Fatalf("expected allow with memoization, got %v, %v", allow, err)
+	}
+	if memo >= plain {
+		t.Fatalf("expected memoization to use less than %d gas, got %d", plain, memo)
+	}
+}
+
+func TestMemoizeSkipsImpure(t *testing.T) {
+	ast, err := Parse(`(and (< (per-day-count "pay" (get req "day")) 5) (< (per-day-count "pay" (get req "day")) 5))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := makeEnv()
+	env.Req = map[string]any{"day": "2025-01-15"}
+	env.Memoize = true
+	calls := 0
+	env.PerDayCount = func(action, day string) int {
+		calls++
+		return calls
+	}
+	if allow, err := Verify(ast, env); err != nil || !allow {
+		t.Fatalf("expected allow, got %v, %v", allow, err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected per-day-count to be read twice, got %d", calls)
+	}
+}
+
 // --- Error propagation tests ---
 
 func TestErrorPropagationInAnd(t *testing.T) {
@@ -499,6 +645,46 @@ func TestStrictModeRejectsUnresolved(t *testing.T) {
 	}
 }
 
+func TestRequiredVars(t *testing.T) {
+	env := makeEnv()
+	env.RequiredVars = []string{"blocklist", "limit"}
+	_, err := evalExpr(t, `(not (member "x" blocklist))`, env)
+	if !errors.Is(err, ErrUnresolvedSymbol) || !strings.Contains(err.Error(), "blocklist") {
+		t.Fatalf("expected the required blocklist to be unresolved, got %v", err)
+	}
+	if _, err := evalExpr(t, `(or (<= 5 limit) #t)`, env); !errors.Is(err, ErrUnresolvedSymbol) {
+		t.Fatalf("expected the required limit to be unresolved, got %v", err)
+	}
+	// Optional names stay lenient.
+	ok, err := evalExpr(t, `(= optional "optional")`, env)
+	if err != nil || !ok {
+		t.Fatalf("expected optional vars to evaluate leniently, got %v, %v", ok, err)
+	}
+	env.Vars["blocklist"] = []any{"y"}
+	if ok, err := evalExpr(t, `(not (member "x" blocklist))`, env); err != nil || !ok {
+		t.Fatalf("expected the bound blocklist to allow, got %v, %v", ok, err)
+	}
+}
+
+func TestEnvClock(t *testing.T) {
+	env := makeEnv()
+	delete(env.Vars, "now")
+	reads := 0
+	env.Clock = func() time.Time {
+		reads++
+		return time.Date(2025, 10, 1, 12, 0, 0, 0, time.FixedZone("", 3600))
+	}
+	ok, err := evalExpr(t, `(and (= now "2025-10-01T11:00:00Z") (before now "2025-10-02T00:00:00Z"))`, env)
+	if err != nil || !ok || reads != 1 {
+		t.Fatalf("expected now from one clock read, got %v, %v after %d reads", ok, err, reads)
+	}
+	// Vars still take precedence.
+	env.Vars["now"] = "2025-10-03T00:00:00Z"
+	if ok, _ := evalExpr(t, `(before now "2025-10-02T00:00:00Z")`, env); ok {
+		t.Fatal("expected the bound now to win over Clock")
+	}
+}
+
 func TestNonStrictAllowsUnresolved(t *testing.T) {
 	env := makeEnv()
 	ok, err := evalExpr(t, `(= "foo" unbound_var)`, env)
@@ -525,6 +711,46 @@ func TestEqualityTypeAware(t *testing.T) {
 	}
 }
 
+func TestEqTyped(t *testing.T) {
+	type id struct{ n int }
+	cases := []struct {
+		a, b any
+		want bool
+	}{
+		{50.0, 50, true},
+		{int64(50), 50.0, true},
+		{uint8(7), float32(7), true},
+		{json.Number("2.5"), 2.5, true},
+		{int64(50), "50", false},
+		{json.Number("50"), "50", false},
+		{true, "true", false},
+		{nil, "<nil>", false},
+		{[]any{"a", 1.0}, []any{"a", 1}, true},
+		{[]any{"a"}, []any{"a", "b"}, false},
+		{[]any{1.0}, "[1]", false},
+		{map[string]any{"k": 1.0}, map[string]any{"k": 1}, true},
+		{map[string]any{"k": 1.0}, map[string]any{"j": 1.0}, false},
+		{map[string]any{"k": "v"}, "map[k:v]", false},
+		{NewStringSet("a", "b"), NewStringSet("b", "a"), true},
+		{NewStringSet("a"), []any{"a"}, false},
+		{id{1}, id{1}, true},
+		{id{1}, id{2}, false},
+		{[]string{"a"}, []string{"a"}, false},
+		// Comparable types whose values are not.
+		{struct{ X any }{[]int{1}}, struct{ X any }{[]int{1}}, false},
+		{struct{ X any }{1}, struct{ X any }{[]int{1}}, false},
+		{struct{ X any }{1}, struct{ X any }{1}, true},
+	}
+	for _, c := range cases {
+		if got := eq(c.a, c.b); got != c.want {
+			t.Errorf("eq(%#v, %#v): expected %v, got %v", c.a, c.b, c.want, got)
+		}
+		if got := eq(c.b, c.a); got != c.want {
+			t.Errorf("eq(%#v, %#v): expected %v, got %v", c.b, c.a, c.want, got)
+		}
+	}
+}
+
 // --- Integration test ---
 
 func TestFamilyGiftsPolicy(t *testing.T) {
@@ -0,0 +1,142 @@
+package spl
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// CounterStore keeps the per-action usage counts behind per-day-count,
+// shared by every verifier that uses it. A window names the period counted,
+// such as the day "2026-01-31"; counts in different windows are separate.
+type CounterStore interface {
+	// Count returns the number of times action was counted in window.
+	Count(action, window string) (int, error)
+	// CheckAndIncrement counts action in window and reports true if the
+	// count was below limit, or leaves it unchanged and reports false if
+	// not. The check and the increment are one atomic step, so concurrent
+	// requests cannot both take the last unit of a limit.
+	CheckAndIncrement(action, window string, limit int) (bool, error)
+}
+
+// MemoryCounters is a CounterStore for a single process. Counts are never
+// expired; the zero value is ready to use.
+type MemoryCounters struct {
+	mu     sync.Mutex
+	counts map[[2]string]int
+}
+
+func (m *MemoryCounters) Count(action, window string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[[2]string{action, window}], nil
+}
+
+func (m *MemoryCounters) CheckAndIncrement(action, window string, limit int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := [2]string{action, window}
+	if m.counts[k] >= limit {
+		return false, nil
+	}
+	if m.counts == nil {
+		m.counts = map[[2]string]int{}
+	}
+	m.counts[k]++
+	return true, nil
+}
+
+var _ StateStore = (*MemoryCounters)(nil)
+
+// Export returns every count.
+func (m *MemoryCounters) Export() (*Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := &Snapshot{Taken: time.Now().UTC()}
+	for k, n := range m.counts {
+		s.Counters = append(s.Counters, CounterState{Action: k[0], Window: k[1], Count: n})
+	}
+	return s, nil
+}
+
+// Import raises each count to the snapshot's. Nonces and revocations are
+// ignored.
+func (m *MemoryCounters) Import(s *Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, c := range s.Counters {
+		if !c.Expires.IsZero() && !c.Expires.After(now) {
+			continue
+		}
+		if m.counts == nil {
+			m.counts = map[[2]string]int{}
+		}
+		if k := [2]string{c.Action, c.Window}; c.Count > m.counts[k] {
+			m.counts[k] = c.Count
+		}
+	}
+	return nil
+}
+
+// ReplayStore remembers identifiers, such as presentation or request
+// signatures, that must be accepted only once.
+type ReplayStore interface {
+	// Claim records id for ttl and reports true if it was not already
+	// recorded, atomically, so of concurrent claims of one id exactly one
+	// succeeds.
+	Claim(id string, ttl time.Duration) (bool, error)
+}
+
+// PerDayCountFrom adapts a CounterStore for VerifyTokenOptions.PerDayCount,
+// for reading counts without taking them; VerifyTokenOptions.Counters both
+// reads and takes them. A store error counts as unlimited use, failing the
+// policy's limit closed.
+func PerDayCountFrom(s CounterStore) func(action, day string) int {
+	return func(action, day string) int {
+		n, err := s.Count(action, day)
+		if err != nil {
+			return int(^uint(0) >> 1)
+		}
+		return n
+	}
+}
+
+// RevocationStore lists revoked identifiers: token signatures, issuer
+// public keys and kids. Identifiers are compared case-insensitively.
+type RevocationStore interface {
+	Revoke(id string) error
+	IsRevoked(id string) (bool, error)
+}
+
+// RevokedFrom adapts a RevocationStore to a revocation check, such as
+// mcpguard's Guard.Revoked: a token is revoked if its signature, issuer key
+// or kid is listed. A store error counts as revoked.
+func RevokedFrom(s RevocationStore) func(*Token) bool {
+	return func(t *Token) bool {
+		for _, id := range []string{t.Signature, t.PublicKey, t.KeyID} {
+			if id == "" {
+				continue
+			}
+			if revoked, err := s.IsRevoked(strings.ToLower(id)); revoked || err != nil {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AuditRecord is one verification decision as an audit log keeps it.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// Token is the signature of the token verified.
+	Token   string         `json:"token,omitempty"`
+	Request map[string]any `json:"request,omitempty"`
+	Allow   bool           `json:"allow"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// AuditLog is an append-only record of decisions.
+type AuditLog interface {
+	Append(AuditRecord) error
+}
@@ -0,0 +1,116 @@
+package spl
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+type brokenCounter struct{}
+
+func (brokenCounter) Count(string, string) (int, error) { return 0, errors.New("down") }
+func (brokenCounter) CheckAndIncrement(string, string, int) (bool, error) {
+	return false, errors.New("down")
+}
+
+func TestPerDayCountFromFailsClosed(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(<= (per-day-count "search" "2026-01-31") 5)`, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifyTokenObj(tok, map[string]any{}, VerifyTokenOptions{PerDayCount: PerDayCountFrom(brokenCounter{})}).Allow {
+		t.Fatal("expected a store error to deny")
+	}
+}
+
+func TestCountersTakeAtomically(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(and (= (get req "action") "pay") (< (per-day-count "pay" "2026-01-31") 3))`, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &MemoryCounters{}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := VerifyTokenObj(tok, map[string]any{"action": "pay"}, VerifyTokenOptions{Counters: store})
+			if res.Err != nil {
+				t.Error(res.ErrorMessage())
+			}
+			if res.Allow {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _ := store.Count("pay", "2026-01-31"); allowed != 3 || n != 3 {
+		t.Fatalf("expected exactly 3 of 20 concurrent requests allowed and counted, got %d allowed, count %d", allowed, n)
+	}
+	if VerifyTokenObj(tok, map[string]any{"action": "refund"}, VerifyTokenOptions{Counters: &MemoryCounters{}}).Allow {
+		t.Fatal("expected a deny")
+	}
+}
+
+// racingCounters reports a count of 0 but has no units left, as when a
+// concurrent request takes the last one between the read and the take.
+type racingCounters struct{ taken []string }
+
+func (r *racingCounters) Count(string, string) (int, error) { return 0, nil }
+func (r *racingCounters) CheckAndIncrement(action, window string, limit int) (bool, error) {
+	r.taken = append(r.taken, action)
+	return limit == unlimited, nil
+}
+
+func TestCountersLimits(t *testing.T) {
+	_, priv := GenerateKeypair()
+	for _, tc := range []struct {
+		policy string
+		allow  bool
+	}{
+		{`(< (per-day-count "pay" "d") 3)`, false},
+		{`(>= 3 (per-day-count "pay" "d"))`, false},
+		{`(not (>= (per-day-count "pay" "d") max))`, false},
+		// A count that passes a lower bound still passes it after other
+		// requests are counted; equality sets no limit.
+		{`(> (per-day-count "pay" "d") -1)`, true},
+		{`(= (per-day-count "pay" "d") 0)`, true},
+	} {
+		tok, err := Mint(tc.policy, priv, MintOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := &racingCounters{}
+		res := VerifyTokenObj(tok, map[string]any{"action": "pay"}, VerifyTokenOptions{Counters: store, Vars: map[string]any{"max": 3.0}})
+		if res.Allow != tc.allow || res.Err != nil || len(store.taken) != 1 {
+			t.Errorf("%s: expected allow=%v after taking the counter once, got %+v, took %v", tc.policy, tc.allow, res, store.taken)
+		}
+	}
+
+	tok, _ := Mint(`(<= (per-day-count "search" "2026-01-31") 5)`, priv, MintOptions{})
+	if res := VerifyTokenObj(tok, map[string]any{}, VerifyTokenOptions{Counters: brokenCounter{}}); res.Allow {
+		t.Fatalf("expected a store error to deny, got %+v", res)
+	}
+}
+
+type brokenRevocations struct{}
+
+func (brokenRevocations) Revoke(string) error            { return errors.New("down") }
+func (brokenRevocations) IsRevoked(string) (bool, error) { return false, errors.New("down") }
+
+func TestRevokedFromFailsClosed(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint("#t", priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !RevokedFrom(brokenRevocations{})(tok) {
+		t.Fatal("expected a store error to count as revoked")
+	}
+}
@@ -0,0 +1,313 @@
+package spl
+
+import (
+	"reflect"
+	"regexp"
+)
+
+// Subsumes reports whether every request narrow allows is also allowed by
+// broad, i.e. narrow implies broad, for example because it attenuates broad
+// with an extra conjunct, lowers a numeric limit, shrinks a member list or
+// moves a before deadline earlier. Symbols found in vars are replaced by
+// their values first, so lists and limits held in variables can be
+// compared.
+//
+// The check is sound but incomplete: true is a proof, false means no proof
+// was found. With nil vars, strings that could name a variable (anything
+// matching the symbol grammar) are compared only by name, since a verifier
+// may bind them. With non-nil vars, vars is taken to be everything the
+// verifier binds, so other strings are literals; now is always opaque.
+func Subsumes(broad, narrow Node, vars map[string]any) bool {
+	return implies(bind(narrow, vars), bind(broad, vars))
+}
+
+// boundValue is a value substituted from vars, kept distinct from symbols.
+type boundValue struct{ v any }
+
+func (boundValue) node() {}
+
+// bind substitutes vars into n. Symbols and strings evaluate alike, so
+// those left unbound all become Str.
+func bind(n Node, vars map[string]any) Node {
+	switch v := n.(type) {
+	case List:
+		if len(v) == 0 {
+			return v
+		}
+		out := make(List, len(v))
+		out[0] = v[0]
+		for i, a := range v[1:] {
+			out[i+1] = bind(a, vars)
+		}
+		return out
+	case *Symbol, Str:
+		name, _ := text(v)
+		if name == "req" || name == "now" || vars == nil {
+			return Str(name)
+		}
+		if val, ok := vars[name]; ok {
+			return boundValue{val}
+		}
+		return boundValue{name}
+	}
+	return n
+}
+
+func nodeOp(n Node) (string, []Node) {
+	if l, ok := n.(List); ok {
+		if _, op, ok := l.Head(); ok {
+			return op, l[1:]
+		}
+	}
+	return "", nil
+}
+
+// implies reports whether n being truthy guarantees b is truthy.
+func implies(n, b Node) bool {
+	if reflect.DeepEqual(n, b) || b == Bool(true) || n == Bool(false) {
+		return true
+	}
+	nop, nargs := nodeOp(n)
+	bop, bargs := nodeOp(b)
+	if bop == "and" {
+		for _, a := range bargs {
+			if !implies(n, a) {
+				return false
+			}
+		}
+		return true
+	}
+	if nop == "or" {
+		for _, a := range nargs {
+			if !implies(a, b) {
+				return false
+			}
+		}
+		return true
+	}
+	if nop == "and" {
+		for _, a := range nargs {
+			if implies(a, b) {
+				return true
+			}
+		}
+	}
+	if bop == "or" {
+		for _, a := range bargs {
+			if implies(n, a) {
+				return true
+			}
+		}
+	}
+	if nop == "not" && bop == "not" && len(nargs) > 0 && len(bargs) > 0 {
+		return implies(bargs[0], nargs[0])
+	}
+	return atomImplies(n, b)
+}
+
+// bound is a comparison normalized to subject op constant.
+type bound struct {
+	subject Node
+	op      string // "<", "<=", ">", ">=" or "="
+	c       float64
+}
+
+var flipped = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<=", "=": "="}
+
+func asBound(n Node) (bound, bool) {
+	op, args := nodeOp(n)
+	if _, ok := flipped[op]; !ok || len(args) < 2 {
+		return bound{}, false
+	}
+	if c, ok := constNumber(args[1]); ok {
+		return bound{args[0], op, c}, true
+	}
+	if c, ok := constNumber(args[0]); ok {
+		return bound{args[1], flipped[op], c}, true
+	}
+	return bound{}, false
+}
+
+// boundImplies reports whether x n.op n.c implies x b.op b.c.
+func boundImplies(n, b bound) bool {
+	upper := func(op string) bool { return op == "<" || op == "<=" }
+	lower := func(op string) bool { return op == ">" || op == ">=" }
+	switch {
+	case n.op == "=" && b.op == "=":
+		return n.c == b.c
+	case n.op == "=":
+		return evalCompare(b.op, n.c, b.c)
+	case upper(n.op) && upper(b.op):
+		return n.c < b.c || n.c == b.c && (n.op == "<" || b.op == "<=")
+	case lower(n.op) && lower(b.op):
+		return n.c > b.c || n.c == b.c && (n.op == ">" || b.op == ">=")
+	}
+	return false
+}
+
+func evalCompare(op string, a, b float64) bool {
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return a == b
+}
+
+func atomImplies(n, b Node) bool {
+	if nb, ok := asBound(n); ok {
+		if bb, ok := asBound(b); ok && reflect.DeepEqual(nb.subject, bb.subject) {
+			return boundImplies(nb, bb)
+		}
+	}
+	nop, nargs := nodeOp(n)
+	bop, bargs := nodeOp(b)
+	if len(nargs) < 2 || len(bargs) < 2 || !reflect.DeepEqual(nargs[0], bargs[0]) {
+		// before compares the subject from either side.
+		if nop == "before" && bop == "before" && len(nargs) >= 2 && len(bargs) >= 2 && reflect.DeepEqual(nargs[1], bargs[1]) {
+			return timeNotAfter(bargs[0], nargs[0])
+		}
+		return false
+	}
+	switch {
+	case isMember(nop) && isMember(bop):
+		return listSubset(nargs[1], bargs[1])
+	case nop == "subset?" && bop == "subset?":
+		return listSubset(nargs[1], bargs[1])
+	case nop == "=" && isMember(bop):
+		c, ok := constScalar(nargs[1])
+		l, lok := constList(bargs[1])
+		return ok && lok && containsEq(l, c)
+	case isMember(nop) && bop == "=":
+		l, lok := constList(nargs[1])
+		c, ok := constScalar(bargs[1])
+		if !ok || !lok {
+			return false
+		}
+		for _, e := range l {
+			if !eq(e, c) {
+				return false
+			}
+		}
+		return true
+	case nop == "before" && bop == "before":
+		return timeNotAfter(nargs[1], bargs[1])
+	}
+	return false
+}
+
+// timeNotAfter reports whether constant timestamp a is at or before b.
+// before compares instants once now is a time.Time, so the deadlines are
+// parsed and compared as instants; ones that do not parse prove nothing.
+func timeNotAfter(a, b Node) bool {
+	as, ok1 := constString(a)
+	bs, ok2 := constString(b)
+	if !ok1 || !ok2 {
+		return false
+	}
+	ta, err1 := parseTime(as)
+	tb, err2 := parseTime(bs)
+	return err1 == nil && err2 == nil && !ta.After(tb)
+}
+
+func isMember(op string) bool { return op == "member" || op == "in" }
+
+// listSubset reports whether list a is a subset of list b, both constant.
+func listSubset(a, b Node) bool {
+	la, ok1 := constList(a)
+	lb, ok2 := constList(b)
+	if !ok1 || !ok2 {
+		return reflect.DeepEqual(a, b)
+	}
+	for _, e := range la {
+		if !containsEq(lb, e) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsEq(list []any, x any) bool {
+	for _, e := range list {
+		if eq(e, x) {
+			return true
+		}
+	}
+	return false
+}
+
+// symbolRE is the SPL symbol grammar; a string matching it may be resolved
+// as a variable at verification time.
+var symbolRE = regexp.MustCompile(`^[a-zA-Z_?!.][a-zA-Z0-9_?!.-]*$`)
+
+func constNumber(n Node) (float64, bool) {
+	if b, ok := n.(boundValue); ok {
+		switch v := b.v.(type) {
+		case float64:
+			return v, true
+		case int:
+			return float64(v), true
+		}
+		return 0, false
+	}
+	if v, ok := n.(Num); ok {
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func constString(n Node) (string, bool) {
+	switch v := n.(type) {
+	case boundValue:
+		s, ok := v.v.(string)
+		return s, ok
+	case *Symbol, Str:
+		s, _ := text(v)
+		return s, !symbolRE.MatchString(s)
+	}
+	return "", false
+}
+
+func constScalar(n Node) (any, bool) {
+	if f, ok := constNumber(n); ok {
+		return f, true
+	}
+	if s, ok := constString(n); ok {
+		return s, true
+	}
+	if b, ok := n.(Bool); ok {
+		return bool(b), true
+	}
+	if b, ok := n.(boundValue); ok {
+		if v, ok := b.v.(bool); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// constList returns the elements of a list bound from vars or built by
+// tuple from constants.
+func constList(n Node) ([]any, bool) {
+	if b, ok := n.(boundValue); ok {
+		return asList(b.v)
+	}
+	op, args := nodeOp(n)
+	if op != "tuple" {
+		return nil, false
+	}
+	out := make([]any, 0, len(args))
+	for _, a := range args {
+		c, ok := constScalar(a)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, c)
+	}
+	return out, true
+}
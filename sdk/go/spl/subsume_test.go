@@ -0,0 +1,107 @@
+package spl
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, src string) Node {
+	t.Helper()
+	n, err := Parse(src)
+	if err != nil {
+		t.Fatalf("%s: %v", src, err)
+	}
+	return n
+}
+
+func TestSubsumes(t *testing.T) {
+	vars := map[string]any{
+		"family": []any{"niece@example.com", "mom@example.com"},
+		"limit":  float64(50),
+	}
+	cases := []struct {
+		broad, narrow string
+		want          bool
+	}{
+		{`(<= (get req "amount") 100)`, `(and (<= (get req "amount") 100) (dpop_ok?))`, true},
+		{`(<= (get req "amount") 100)`, `(<= (get req "amount") 50)`, true},
+		{`(<= (get req "amount") 50)`, `(<= (get req "amount") 100)`, false},
+		{`(<= (get req "amount") 50)`, `(< (get req "amount") 50)`, true},
+		{`(< (get req "amount") 50)`, `(<= (get req "amount") 50)`, false},
+		{`(<= (get req "amount") 50)`, `(>= 25 (get req "amount"))`, true},
+		{`(>= (get req "amount") 1)`, `(> (get req "amount") 1)`, true},
+		{`(<= (get req "amount") 100)`, `(= (get req "amount") 20)`, true},
+		{`(<= (get req "amount") limit)`, `(<= (get req "amount") 40)`, true},
+		{`(member (get req "to") family)`, `(member (get req "to") (tuple "mom@example.com"))`, true},
+		{`(member (get req "to") (tuple "mom@example.com"))`, `(member (get req "to") family)`, false},
+		{`(member (get req "to") family)`, `(= (get req "to") "niece@example.com")`, true},
+		{`(member (get req "to") family)`, `(= (get req "to") "stranger@example.com")`, false},
+		{`(before now "2027-01-01T00:00:00Z")`, `(before now "2026-01-01T00:00:00Z")`, true},
+		{`(before now "2026-01-01T00:00:00Z")`, `(before now "2027-01-01T00:00:00Z")`, false},
+		// Deadlines are instants: a fraction of a second later is wider, and
+		// an offset can make a lexically later deadline earlier.
+		{`(before now "2026-01-01T06:00:00Z")`, `(before now "2026-01-01T06:00:00.5Z")`, false},
+		{`(before now "2026-01-01T06:00:00Z")`, `(before now "2026-01-01T07:00:00+02:00")`, true},
+		{`(before "2026-01-01T06:00:00Z" now)`, `(before "2026-01-01T05:59:59.5Z" now)`, false},
+		{`(before now "2027")`, `(before now "2026")`, false},
+		{`(or (dpop_ok?) (thresh_ok?))`, `(dpop_ok?)`, true},
+		{`(dpop_ok?)`, `(or (dpop_ok?) (thresh_ok?))`, false},
+		{`(not (member (get req "to") family))`, `(not (member (get req "to") (tuple "niece@example.com" "mom@example.com" "x@example.com")))`, true},
+		{`(and (<= (get req "amount") 100) (>= (get req "amount") 0))`, `(and (>= (get req "amount") 10) (<= (get req "amount") 20))`, true},
+		// Strings that could name variables are compared by name only.
+		{`(<= (get req "amount") cap)`, `(<= (get req "amount") 1)`, false},
+		{`#t`, `(dpop_ok?)`, true},
+		{`(dpop_ok?)`, `#f`, true},
+	}
+	for _, c := range cases {
+		if got := Subsumes(mustParse(t, c.broad), mustParse(t, c.narrow), vars); got != c.want {
+			t.Errorf("Subsumes(%s, %s) = %v, want %v", c.broad, c.narrow, got, c.want)
+		}
+	}
+
+	// Without vars, "mom" might be a variable, so the lists are incomparable.
+	broad, narrow := mustParse(t, `(member x (tuple "mom" "dad"))`), mustParse(t, `(member x (tuple "mom"))`)
+	if Subsumes(broad, narrow, nil) || !Subsumes(broad, narrow, map[string]any{}) {
+		t.Fatal("expected symbol-like strings to be literals only when vars are given")
+	}
+}
+
+func TestDiffPolicies(t *testing.T) {
+	old := `(and
+  (<= (get req "amount") 50)
+  (member (get req "recipient") (tuple "niece@example.com" "mom@example.com"))
+  (= (get req "purpose") "giftcard")
+  (dpop_ok?))`
+	narrower := `(and
+  (<= (get req "amount") 25)
+  (member (get req "recipient") (tuple "niece@example.com"))
+  (= (get req "purpose") "giftcard")
+  (dpop_ok?)
+  (vrf_ok? (get req "day") (get req "amount")))`
+	d, err := DiffPolicies(old, narrower, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Narrower || len(d.Unproven) != 0 {
+		t.Fatalf("expected a proof of narrowing, unproven %v", d.Unproven)
+	}
+	var kinds []string
+	for _, c := range d.Changes {
+		kinds = append(kinds, c.Kind+" "+c.Detail)
+	}
+	if got := strings.Join(kinds, ","); got != "tightened ,tightened -mom@example.com,added " {
+		t.Fatalf("unexpected changes %q: %+v", got, d.Changes)
+	}
+
+	wider := strings.Replace(strings.Replace(old, "50", "500", 1), "\n  (dpop_ok?)", "", 1)
+	d, err = DiffPolicies(old, wider, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Narrower || len(d.Unproven) != 2 || d.Changes[0].Kind != "loosened" || d.Changes[1].Kind != "removed" {
+		t.Fatalf("expected loosening to be reported: %+v", d)
+	}
+	if _, err := DiffPolicies("(and", old, nil); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
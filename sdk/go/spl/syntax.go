@@ -0,0 +1,179 @@
+package spl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Position is a 1-based line and column (in runes) in policy source.
+type Position struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+func (p Position) String() string { return fmt.Sprintf("%d:%d", p.Line, p.Col) }
+
+// syntaxNode is a policy expression as written: atoms keep their source
+// text, so string literals stay distinct from symbols, and every node
+// records where it starts. Tools such as Lint and Format work on it; Parse
+// and evaluation do not.
+type syntaxNode struct {
+	pos    Position
+	atom   string // raw token; empty for lists
+	list   []*syntaxNode
+	isList bool
+}
+
+func (n *syntaxNode) isString() bool { return strings.HasPrefix(n.atom, `"`) }
+
+func (n *syntaxNode) isBool() bool { return n.atom == "#t" || n.atom == "#f" }
+
+func (n *syntaxNode) isNumber() bool {
+	if n.isList || n.isString() {
+		return false
+	}
+	_, err := strconv.ParseFloat(n.atom, 64)
+	return err == nil
+}
+
+func (n *syntaxNode) isSymbol() bool {
+	return !n.isList && !n.isString() && !n.isBool() && !n.isNumber()
+}
+
+// op returns the operator symbol of a list, or "".
+func (n *syntaxNode) op() string {
+	if n.isList && len(n.list) > 0 && n.list[0].isSymbol() {
+		return n.list[0].atom
+	}
+	return ""
+}
+
+// String renders n on one line in canonical spacing.
+func (n *syntaxNode) String() string {
+	if !n.isList {
+		return n.atom
+	}
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, c := range n.list {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(c.String())
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+type syntaxToken struct {
+	text string
+	pos  Position
+}
+
+// scanSyntax splits src into tokens with positions, using the same rules as
+// tokenize: parentheses and double quotes delimit, and a string runs to the
+// next double quote.
+func scanSyntax(src string) ([]syntaxToken, error) {
+	var toks []syntaxToken
+	var buf strings.Builder
+	var start Position
+	line, col := 1, 0
+	flush := func() {
+		if buf.Len() > 0 {
+			toks = append(toks, syntaxToken{buf.String(), start})
+			buf.Reset()
+		}
+	}
+	inStr := false
+	for _, ch := range src {
+		col++
+		if inStr {
+			buf.WriteRune(ch)
+			if ch == '"' {
+				inStr = false
+				flush()
+			}
+			if ch == '\n' {
+				line, col = line+1, 0
+			}
+			continue
+		}
+		switch ch {
+		case '(', ')':
+			flush()
+			toks = append(toks, syntaxToken{string(ch), Position{line, col}})
+		case ' ', '\t', '\r':
+			flush()
+		case '\n':
+			flush()
+			line, col = line+1, 0
+		case '"':
+			flush()
+			inStr = true
+			start = Position{line, col}
+			buf.WriteRune(ch)
+		default:
+			if buf.Len() == 0 {
+				start = Position{line, col}
+			}
+			buf.WriteRune(ch)
+		}
+	}
+	if inStr {
+		return nil, fmt.Errorf("%s: unterminated string", start)
+	}
+	flush()
+	return toks, nil
+}
+
+// readSyntax reads every top-level expression in src. Parse only evaluates
+// the first; callers decide what to do with the rest.
+func readSyntax(src string) ([]*syntaxNode, error) {
+	toks, err := scanSyntax(src)
+	if err != nil {
+		return nil, err
+	}
+	i := 0
+	var read func() (*syntaxNode, error)
+	read = func() (*syntaxNode, error) {
+		tok := toks[i]
+		i++
+		switch tok.text {
+		case "(":
+			n := &syntaxNode{pos: tok.pos, isList: true}
+			for {
+				if i >= len(toks) {
+					return nil, fmt.Errorf("%s: unterminated (", tok.pos)
+				}
+				if toks[i].text == ")" {
+					i++
+					return n, nil
+				}
+				c, err := read()
+				if err != nil {
+					return nil, err
+				}
+				n.list = append(n.list, c)
+			}
+		case ")":
+			return nil, fmt.Errorf("%s: unexpected )", tok.pos)
+		default:
+			if strings.HasPrefix(tok.text, `"`) {
+				if _, err := strconv.Unquote(tok.text); err != nil {
+					return nil, fmt.Errorf("%s: invalid string %s", tok.pos, tok.text)
+				}
+			}
+			return &syntaxNode{pos: tok.pos, atom: tok.text}, nil
+		}
+	}
+	var forms []*syntaxNode
+	for i < len(toks) {
+		n, err := read()
+		if err != nil {
+			return nil, err
+		}
+		forms = append(forms, n)
+	}
+	return forms, nil
+}
@@ -0,0 +1,159 @@
+package spl
+
+import (
+	"math/bits"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPolicyStatsLimit is how many policies a Verifier keeps statistics
+// for when VerifierConfig.PolicyStatsLimit is zero.
+const DefaultPolicyStatsLimit = 1024
+
+// PolicyStats aggregates the evaluations of one policy by a Verifier, for
+// finding the policies that are slow or expensive to evaluate. Latency is
+// the time spent evaluating the policy, counters included, after the token
+// was authenticated.
+type PolicyStats struct {
+	// Digest identifies the policy; see PolicyDigest.
+	Digest      string `json:"digest"`
+	Evaluations uint64 `json:"evaluations"`
+	// Errors counts the evaluations that failed, as by running out of gas.
+	Errors      uint64        `json:"errors"`
+	GasMean     float64       `json:"gas_mean"`
+	GasMax      int           `json:"gas_max"`
+	LatencyMean time.Duration `json:"latency_mean_ns"`
+	// LatencyP99 is an upper bound on the 99th percentile latency, to
+	// within a factor of two.
+	LatencyP99 time.Duration `json:"latency_p99_ns"`
+	LatencyMax time.Duration `json:"latency_max_ns"`
+}
+
+// latencyBuckets is the size of the latency histogram: bucket i counts
+// latencies under 2^i microseconds, and the last bucket all the rest.
+const latencyBuckets = 24
+
+// policyTable holds a Verifier's PolicyStats, keyed by policy source.
+type policyTable struct {
+	limit    int
+	mu       sync.RWMutex
+	policies map[string]*policyCounters
+}
+
+// policyCounters accumulates the evaluations of one policy.
+type policyCounters struct {
+	digest              string
+	evaluations, errors atomic.Uint64
+	gas, gasMax         atomic.Uint64
+	latency, latencyMax atomic.Uint64 // nanoseconds
+	histogram           [latencyBuckets]atomic.Uint64
+}
+
+// newPolicyTable returns a table of at most limit policies, or nil when
+// limit is negative.
+func newPolicyTable(limit int) *policyTable {
+	if limit < 0 {
+		return nil
+	}
+	if limit == 0 {
+		limit = DefaultPolicyStatsLimit
+	}
+	return &policyTable{limit: limit, policies: map[string]*policyCounters{}}
+}
+
+// record adds one evaluation of policy. Once the table is full, policies
+// not already in it are not recorded.
+func (p *policyTable) record(policy string, res VerifyTokenResult, latency time.Duration) {
+	p.mu.RLock()
+	c := p.policies[policy]
+	p.mu.RUnlock()
+	if c == nil {
+		p.mu.Lock()
+		if c = p.policies[policy]; c == nil && len(p.policies) < p.limit {
+			c = &policyCounters{digest: PolicyDigest(policy)}
+			p.policies[policy] = c
+		}
+		p.mu.Unlock()
+		if c == nil {
+			return
+		}
+	}
+	c.evaluations.Add(1)
+	if res.Err != nil {
+		c.errors.Add(1)
+	}
+	gas, ns := uint64(res.GasUsed), uint64(max(latency, 0))
+	c.gas.Add(gas)
+	c.latency.Add(ns)
+	storeMax(&c.gasMax, gas)
+	storeMax(&c.latencyMax, ns)
+	c.histogram[min(bits.Len64(ns/1000), latencyBuckets-1)].Add(1)
+}
+
+// storeMax raises m to v if v is larger.
+func storeMax(m *atomic.Uint64, v uint64) {
+	for {
+		old := m.Load()
+		if v <= old || m.CompareAndSwap(old, v) {
+			return
+		}
+	}
+}
+
+// stats returns the statistics of every policy in the table, highest p99
+// latency first.
+func (p *policyTable) stats() []PolicyStats {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	out := make([]PolicyStats, 0, len(p.policies))
+	for _, c := range p.policies {
+		out = append(out, c.stats())
+	}
+	p.mu.RUnlock()
+	slices.SortFunc(out, func(a, b PolicyStats) int {
+		switch {
+		case a.LatencyP99 != b.LatencyP99:
+			return int(b.LatencyP99 - a.LatencyP99)
+		case a.Digest < b.Digest:
+			return -1
+		}
+		return 1
+	})
+	return out
+}
+
+func (c *policyCounters) stats() PolicyStats {
+	s := PolicyStats{
+		Digest:      c.digest,
+		Evaluations: c.evaluations.Load(),
+		Errors:      c.errors.Load(),
+		GasMax:      int(c.gasMax.Load()),
+		LatencyMax:  time.Duration(c.latencyMax.Load()),
+	}
+	if s.Evaluations == 0 {
+		return s
+	}
+	s.GasMean = float64(c.gas.Load()) / float64(s.Evaluations)
+	s.LatencyMean = time.Duration(c.latency.Load() / s.Evaluations)
+	// The bucket holding the 99th percentile evaluation, read while others
+	// may still be recorded, so counted against the histogram's own total.
+	var counts [latencyBuckets]uint64
+	var total uint64
+	for i := range counts {
+		counts[i] = c.histogram[i].Load()
+		total += counts[i]
+	}
+	rank := total - total/100
+	var seen uint64
+	for i, n := range counts {
+		if seen += n; seen >= rank {
+			s.LatencyP99 = min(time.Duration(1<<i)*time.Microsecond, s.LatencyMax)
+			break
+		}
+	}
+	return s
+}
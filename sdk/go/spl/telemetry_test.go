@@ -0,0 +1,56 @@
+package spl
+
+import (
+	"context"
+	"testing"
+)
+
+func TestVerifierPolicyStats(t *testing.T) {
+	_, priv := GenerateKeypair()
+	cheap, err := Mint("#t", priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	costly, err := Mint(tokenTestPolicy, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(VerifierConfig{PolicyStatsLimit: 2})
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		v.VerifyPresented(ctx, costly, tokenTestReq(50), "")
+	}
+	v.VerifyPresented(ctx, cheap, nil, "")
+	v.Verify(ctx, "not a token", nil)
+
+	stats := v.Stats().Policies
+	if len(stats) != 2 {
+		t.Fatalf("expected stats for 2 policies, got %+v", stats)
+	}
+	byDigest := map[string]PolicyStats{}
+	for _, s := range stats {
+		byDigest[s.Digest] = s
+	}
+	s := byDigest[PolicyDigest(tokenTestPolicy)]
+	if s.Evaluations != 3 || s.Errors != 0 || s.GasMax == 0 || s.GasMean != float64(s.GasMax) {
+		t.Fatalf("unexpected stats for the costly policy %+v", s)
+	}
+	if s.LatencyMax <= 0 || s.LatencyP99 <= 0 || s.LatencyP99 > s.LatencyMax || s.LatencyMean > s.LatencyMax {
+		t.Fatalf("unexpected latencies %+v", s)
+	}
+	if byDigest[PolicyDigest("#t")].Evaluations != 1 {
+		t.Fatalf("expected one evaluation of #t, got %+v", stats)
+	}
+
+	other, err := Mint("#f", priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.VerifyPresented(ctx, other, nil, "")
+	if len(v.Stats().Policies) != 2 {
+		t.Fatal("expected policies past the limit not to be counted")
+	}
+	if NewVerifier(VerifierConfig{PolicyStatsLimit: -1}).Stats().Policies != nil {
+		t.Fatal("expected a negative limit to keep no stats")
+	}
+}
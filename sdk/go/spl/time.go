@@ -0,0 +1,98 @@
+package spl
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeLit is a string literal argument of before, parsed as RFC 3339 when
+// the policy is, so evaluation compares it to a time.Time without parsing
+// it again. ok is false for a literal that is not a time.
+type timeLit struct {
+	s  string
+	t  time.Time
+	ok bool
+}
+
+// parseTimeLits gives a (before a b) list whose arguments include an RFC
+// 3339 string literal a head symbol of its own, carrying the parsed
+// literals. Values read from requests are parsed as they are compared and
+// never retained.
+func parseTimeLits(l List) List {
+	if len(l) != 3 {
+		return l
+	}
+	h, ok := l[0].(*Symbol)
+	if !ok || opOf(h) != OpBefore {
+		return l
+	}
+	var lits [2]timeLit
+	found := false
+	for i, a := range l[1:] {
+		if s, ok := a.(Str); ok {
+			if t, err := parseTime(string(s)); err == nil {
+				lits[i], found = timeLit{string(s), t, true}, true
+			}
+		}
+	}
+	if found {
+		l[0] = &Symbol{Name: h.Name, Op: OpBefore, times: &lits}
+	}
+	return l
+}
+
+// noTimeLits stands in for the literals of a before with none.
+var noTimeLits [2]timeLit
+
+// parseTime parses an RFC 3339 timestamp.
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("before: %q is not an RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+// before orders two timestamps. Two strings compare lexically, as the
+// specification requires of ISO 8601 values; once either side is a
+// time.Time (for example a "now" var set by the host) the other is parsed,
+// or taken from lits when it is the policy's literal, and the instants are
+// compared.
+func before(a, b any, lits *[2]timeLit) (bool, error) {
+	ta, okA := a.(time.Time)
+	tb, okB := b.(time.Time)
+	if !okA && !okB {
+		sa, okA := a.(string)
+		sb, okB := b.(string)
+		if !okA || !okB {
+			return false, fmt.Errorf("before requires string arguments")
+		}
+		return sa < sb, nil
+	}
+	if lits == nil {
+		lits = &noTimeLits
+	}
+	var err error
+	if !okA {
+		if ta, err = timeOperand(a, &lits[0]); err != nil {
+			return false, err
+		}
+	}
+	if !okB {
+		if tb, err = timeOperand(b, &lits[1]); err != nil {
+			return false, err
+		}
+	}
+	return ta.Before(tb), nil
+}
+
+func timeOperand(x any, lit *timeLit) (time.Time, error) {
+	s, ok := x.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("before requires string or time arguments")
+	}
+	if lit.ok && s == lit.s {
+		return lit.t, nil
+	}
+	return parseTime(s)
+}
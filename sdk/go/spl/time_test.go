@@ -0,0 +1,107 @@
+package spl
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBeforeTime(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		src  string
+		now  any
+		want bool
+	}{
+		{`(before now "2026-01-01T00:00:00Z")`, now, true},
+		{`(before now "2025-01-01T00:00:00Z")`, now, false},
+		{`(before "2025-06-01T11:59:59Z" now)`, now, true},
+		// Offsets are honoured once a side is a time.Time; lexically
+		// "2025-06-01T13:00:00+02:00" sorts after now.
+		{`(before "2025-06-01T13:00:00+02:00" now)`, now, true},
+		{`(before now (get req "expires"))`, now, true},
+		{`(before now now)`, now, false},
+	}
+	for _, c := range cases {
+		env := makeEnv()
+		env.Req["expires"] = "2025-06-01T12:00:00.5Z"
+		env.Vars["now"] = c.now
+		got, err := evalExpr(t, c.src, env)
+		if err != nil {
+			t.Fatalf("%s: %v", c.src, err)
+		}
+		if got != c.want {
+			t.Fatalf("%s: expected %v, got %v", c.src, c.want, got)
+		}
+	}
+}
+
+func TestBeforeTimeErrors(t *testing.T) {
+	env := makeEnv()
+	env.Vars["now"] = time.Now()
+	for _, src := range []string{`(before now "tomorrow")`, `(before now 5)`} {
+		if _, err := evalExpr(t, src, env); err == nil {
+			t.Fatalf("%s: expected an error", src)
+		}
+	}
+	env.Vars["now"] = "2025-06-01T00:00:00Z"
+	if _, err := evalExpr(t, `(before now 5)`, env); err == nil || err.Error() != "before requires string arguments" {
+		t.Fatalf("expected a string argument error, got %v", err)
+	}
+}
+
+func TestEqTime(t *testing.T) {
+	a := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	if !eq(a, a.In(time.FixedZone("x", 3600))) {
+		t.Fatal("expected the same instant in two zones to be equal")
+	}
+	if eq(a, a.Add(time.Second)) || eq(a, "2025-06-01T12:00:00Z") {
+		t.Fatal("expected different values to be unequal")
+	}
+}
+
+func TestBeforeTimeLiterals(t *testing.T) {
+	ast, err := Parse(`(and (before now "2026-01-01T00:00:00Z") (before now (get req "expires")))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lit := ast.(List)[1].(List)[0].(*Symbol)
+	if lit.times == nil || !lit.times[1].ok || lit.times[0].ok {
+		t.Fatalf("expected the deadline parsed with the policy, got %+v", lit.times)
+	}
+	if ast.(List)[2].(List)[0].(*Symbol).times != nil {
+		t.Fatal("expected no literals for a before of request values")
+	}
+	if src := Render(ast); lit.String() != "before" || !strings.Contains(src, `(before now "2026-01-01T00:00:00Z")`) {
+		t.Fatal("expected the symbol to read and format as before")
+	}
+}
+
+const timePolicy = `(and
+  (before (get req "issued") now)
+  (before now (get req "expires"))
+  (before now "2026-01-01T00:00:00Z")
+  (before "2025-01-01T00:00:00Z" now)
+)`
+
+func BenchmarkBeforeTime(b *testing.B) {
+	ast, err := Parse(timePolicy)
+	if err != nil {
+		b.Fatal(err)
+	}
+	run := func(b *testing.B, now any) {
+		env := benchEnv()
+		env.Req["issued"] = "2025-05-31T23:00:00Z"
+		env.Req["expires"] = "2025-06-01T01:30:00+01:00"
+		env.Vars["now"] = now
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if ok, err := Verify(ast, env); err != nil || !ok {
+				b.Fatalf("expected allow, got %v, %v", ok, err)
+			}
+		}
+	}
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	b.Run("string", func(b *testing.B) { run(b, "2025-06-01T00:00:00Z") })
+	b.Run("time", func(b *testing.B) { run(b, now) })
+}
@@ -1,27 +1,57 @@
 package spl
 
 import (
+	"crypto"
 	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 // Token represents a signed Agent-Safe capability token.
 type Token struct {
-	Version              string `json:"version"`
-	Policy               string `json:"policy"`
-	MerkleRoot           string `json:"merkle_root,omitempty"`
-	HashChainCommitment  string `json:"hash_chain_commitment,omitempty"`
-	Sealed               bool   `json:"sealed"`
-	Expires              string `json:"expires,omitempty"`
-	PublicKey            string `json:"public_key"`
-	Signature            string `json:"signature"`
-	PoPKey               string `json:"pop_key,omitempty"`
+	Version string `json:"version"`
+	Policy  string `json:"policy"`
+	// Actions holds the policies of named actions, applied to requests
+	// whose "action" names one in place of Policy; see PolicyFor.
+	Actions             map[string]string `json:"actions,omitempty"`
+	MerkleRoot          string            `json:"merkle_root,omitempty"`
+	HashChainCommitment string            `json:"hash_chain_commitment,omitempty"`
+	Sealed              bool              `json:"sealed"`
+	Expires             string            `json:"expires,omitempty"`
+	PublicKey           string            `json:"public_key"`
+	Signature           string            `json:"signature"`
+	PoPKey              string            `json:"pop_key,omitempty"`
+	Alg                 string            `json:"alg,omitempty"`
+	PQPublicKey         string            `json:"pq_public_key,omitempty"`
+	PQSignature         string            `json:"pq_signature,omitempty"`
+	X5C                 []string          `json:"x5c,omitempty"`
+	X5TS256             string            `json:"x5t#S256,omitempty"`
+	Caveats             []string          `json:"caveats,omitempty"`
+	KeyID               string            `json:"kid,omitempty"`
+	// Inclusion proves the token is in an issuance transparency log. It is
+	// not covered by the signature; it is authenticated by the log's.
+	Inclusion *InclusionProof `json:"inclusion,omitempty"`
 }
 
+// Token versions. Tokens signed with anything other than Ed25519 carry an
+// explicit alg field and are stamped with the newer version so that older
+// verifiers reject them instead of misreading the key material.
+const (
+	TokenVersion    = "0.2.0"
+	TokenVersionAlg = "0.3.0"
+)
+
 // GenerateKeypair creates a new Ed25519 keypair.
 // Returns (publicKeyHex, privateKeyHex).
 func GenerateKeypair() (string, string) {
@@ -35,7 +65,27 @@ type MintOptions struct {
 	HashChainCommitment string
 	Sealed              bool
 	Expires             string
-	PoPKey              string
+	// Actions gives named actions their own policies, such as a tight
+	// policy for "payments.create" beside a broad one for reads. The
+	// policy passed to Mint applies to the actions not named.
+	Actions map[string]string
+	// PoPKey binds the token to its holder: an Ed25519 public key (hex or
+	// a DID) that must sign each presentation, or a SPIFFE ID whose SVID
+	// the presenter must hold.
+	PoPKey string
+	Alg    string // AlgEd25519 (default) or AlgES256
+	// PQPrivateKey, when set, adds an ML-DSA-65 signature over the same
+	// payload alongside the classical one (hybrid mode).
+	PQPrivateKey string
+	// CertChain is a DER certificate chain, leaf first, whose leaf key is
+	// the minting key. With CertRef only the leaf's SHA-256 thumbprint is
+	// embedded and verifiers look the chain up themselves.
+	CertChain [][]byte
+	CertRef   bool
+	// Transparency, when set, receives the token's TransparencyLeaf, and
+	// the inclusion proof it returns is set on the token. MintHMAC ignores
+	// it.
+	Transparency TransparencyLog
 }
 
 // SigningPayload builds the canonical signing payload for a token.
@@ -49,33 +99,157 @@ func SigningPayload(policy, merkleRoot, hashChainCommitment string, sealed bool,
 	return []byte(policy + "\x00" + merkleRoot + "\x00" + hashChainCommitment + "\x00" + sealedStr + "\x00" + expires)
 }
 
+// Payload returns t's signing payload: SigningPayload of its envelope,
+// followed by its action policies if it has any.
+func (t *Token) Payload() []byte {
+	return appendActions(SigningPayload(t.Policy, t.MerkleRoot, t.HashChainCommitment, t.Sealed, t.Expires), t.Actions)
+}
+
+// appendActions appends actions to a signing payload: a NUL, then each
+// action's name and policy, in name order, as netstrings ("4:read,2:#t,").
+// Tokens without actions keep the payload SigningPayload builds.
+func appendActions(payload []byte, actions map[string]string) []byte {
+	if len(actions) == 0 {
+		return payload
+	}
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	payload = append(payload, 0)
+	for _, name := range names {
+		for _, s := range []string{name, actions[name]} {
+			payload = append(strconv.AppendInt(payload, int64(len(s)), 10), ':')
+			payload = append(append(payload, s...), ',')
+		}
+	}
+	return payload
+}
+
+// checkActions rejects action maps that name no action.
+func checkActions(actions map[string]string) error {
+	if _, ok := actions[""]; ok {
+		return errors.New("action name must not be empty")
+	}
+	return nil
+}
+
+// PolicyFor returns the policy t applies to req: the one Actions holds for
+// req["action"], else Policy.
+func (t *Token) PolicyFor(req map[string]any) string {
+	action, _ := req["action"].(string)
+	return t.actionPolicy(action)
+}
+
+func (t *Token) actionPolicy(action string) string {
+	if p, ok := t.Actions[action]; ok && action != "" {
+		return p
+	}
+	return t.Policy
+}
+
 // Mint creates a signed capability token.
 func Mint(policy string, privateKeyHex string, opts MintOptions) (*Token, error) {
-	seed, err := hex.DecodeString(privateKeyHex)
-	if err != nil {
-		return nil, fmt.Errorf("invalid private key hex: %w", err)
-	}
-	if len(seed) != ed25519.SeedSize {
-		return nil, fmt.Errorf("private key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	var signer crypto.Signer
+	switch opts.Alg {
+	case "", AlgEd25519:
+		seed, err := hex.DecodeString(privateKeyHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key hex: %w", err)
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("private key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+		}
+		signer = ed25519.NewKeyFromSeed(seed)
+	case AlgES256:
+		priv, err := p256PrivateKey(privateKeyHex)
+		if err != nil {
+			return nil, err
+		}
+		signer = priv
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", opts.Alg)
 	}
+	return MintWithSigner(policy, signer, opts)
+}
 
-	priv := ed25519.NewKeyFromSeed(seed)
-	pub := priv.Public().(ed25519.PublicKey)
+// MintWithSigner creates a signed token using any crypto.Signer, such as a
+// KMS- or HSM-backed key that never leaves its device. The algorithm follows
+// from the signer's public key: Ed25519 or ECDSA P-256 (ES256). ECDSA signers
+// may return ASN.1 DER or raw r||s signatures.
+func MintWithSigner(policy string, signer crypto.Signer, opts MintOptions) (*Token, error) {
+	if err := checkActions(opts.Actions); err != nil {
+		return nil, err
+	}
+	payload := appendActions(SigningPayload(policy, opts.MerkleRoot, opts.HashChainCommitment, opts.Sealed, opts.Expires), opts.Actions)
 
-	payload := SigningPayload(policy, opts.MerkleRoot, opts.HashChainCommitment, opts.Sealed, opts.Expires)
-	sig := ed25519.Sign(priv, payload)
+	alg, pubHex, err := encodePublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	if opts.Alg != "" && opts.Alg != alg {
+		return nil, fmt.Errorf("signer key is %s, not %s", alg, opts.Alg)
+	}
+	if strings.HasPrefix(opts.PoPKey, spiffeScheme) {
+		if _, err := ParseSPIFFEID(opts.PoPKey); err != nil {
+			return nil, err
+		}
+	}
+	var sig []byte
+	version := TokenVersion
+	switch alg {
+	case AlgEd25519:
+		if sig, err = signer.Sign(rand.Reader, payload, crypto.Hash(0)); err != nil {
+			return nil, fmt.Errorf("signing failed: %w", err)
+		}
+	case AlgES256:
+		h := sha256.Sum256(payload)
+		der, err := signer.Sign(rand.Reader, h[:], crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("signing failed: %w", err)
+		}
+		if sig, err = p256RawSignature(der); err != nil {
+			return nil, err
+		}
+		version = TokenVersionAlg
+	}
+	sigHex := hex.EncodeToString(sig)
 
-	return &Token{
-		Version:             "0.2.0",
+	t := &Token{
+		Version:             version,
 		Policy:              policy,
+		Actions:             opts.Actions,
 		MerkleRoot:          opts.MerkleRoot,
 		HashChainCommitment: opts.HashChainCommitment,
 		Sealed:              opts.Sealed,
 		Expires:             opts.Expires,
-		PublicKey:           hex.EncodeToString(pub),
-		Signature:           hex.EncodeToString(sig),
+		PublicKey:           pubHex,
+		Signature:           sigHex,
 		PoPKey:              opts.PoPKey,
-	}, nil
+	}
+	if version == TokenVersionAlg {
+		t.Alg = alg
+	}
+	if len(opts.CertChain) > 0 {
+		if err := bindCertChain(t, opts.CertChain, opts.CertRef); err != nil {
+			return nil, err
+		}
+	}
+	if opts.PQPrivateKey != "" {
+		pqPub, pqSig, err := signMLDSA65(payload, opts.PQPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		t.PQPublicKey = pqPub
+		t.PQSignature = pqSig
+	}
+	if opts.Transparency != nil {
+		if t.Inclusion, err = opts.Transparency.Append(TransparencyLeaf(t)); err != nil {
+			return nil, fmt.Errorf("transparency log: %w", err)
+		}
+	}
+	return t, nil
 }
 
 // CreatePresentationSignature creates a PoP presentation signature for a token.
@@ -89,38 +263,321 @@ func CreatePresentationSignature(t *Token, agentPrivateKeyHex string) (string, e
 		return "", fmt.Errorf("agent private key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
 	}
 	priv := ed25519.NewKeyFromSeed(seed)
-	payload := SigningPayload(t.Policy, t.MerkleRoot, t.HashChainCommitment, t.Sealed, t.Expires)
+	payload := t.Payload()
 	h := sha256.Sum256(payload)
 	sig := ed25519.Sign(priv, h[:])
 	return hex.EncodeToString(sig), nil
 }
 
+// SignatureVerifier checks a hex signature over message under a hex public
+// key for one signature algorithm.
+type SignatureVerifier func(message []byte, signatureHex, publicKeyHex string) bool
+
 // VerifyTokenOptions configures token verification.
 type VerifyTokenOptions struct {
-	Vars        map[string]any
+	Vars map[string]any
+	// RequiredVars names the variables a policy may not use unbound: a
+	// policy that reads one missing from Vars fails with
+	// ErrUnresolvedSymbol, so a deployment that forgets to supply it
+	// denies rather than evaluating the name as a string. Other variables
+	// still evaluate leniently.
+	RequiredVars []string
+	// PerDayCount backs per-day-count with read-only counts. It is for
+	// testing and for hosts that count uses themselves; use Counters to
+	// enforce limits.
 	PerDayCount func(action, day string) int
-	Crypto      struct {
-		DPoPOk   func() bool
-		MerkleOk func(tuple []any) bool
-		VRFOk    func(day string, amount float64) bool
-		ThreshOk func() bool
+	// Counters, when set, backs per-day-count in place of PerDayCount and
+	// counts the request: when the policy allows, every counter it read is
+	// incremented with CheckAndIncrement, limited by the comparisons made
+	// with it, such as 3 for (< (per-day-count "pay" day) 3). If
+	// a concurrent request has meanwhile taken the last unit, the request
+	// is denied, so no two requests can both see a count below a limit and
+	// both be allowed. A store error is a verification error.
+	Counters CounterStore
+	// Rates, when set, backs window-count and bucket-ok?, and like Counters
+	// counts the request when the policy allows: a use of every action
+	// whose window-count it read, limited by the comparisons made with it,
+	// and a token from every bucket bucket-ok? found one in.
+	Rates RateStore
+	// Ledger, when set, backs sum-amount with the token's spend, and like
+	// Counters records the request's spend when the policy allows: against
+	// every budget sum-amount read, limited by the comparisons made with
+	// it, such as 500 for (<= (sum-amount "pay" "month" "USD") 500).
+	Ledger LedgerStore
+	Crypto CryptoCallbacks
+	// Now fixes the verification time, as an RFC 3339 string; without it
+	// or Clock, verification uses the wall clock and leaves now unbound.
+	Now string
+	// Clock, when set, tells the verification time in place of Now: for
+	// expiry, key and certificate validity, rate windows and the now the
+	// policy reads. It is read once per verification, so a request sees
+	// one time. Tests and replays pass a fixed or simulated clock, and
+	// hosts can correct for a skewed system clock.
+	Clock func() time.Time
+	// PresentationSignature proves possession of a token's pop_key; see
+	// CreatePresentationSignature. VerifyPresentation takes it, and
+	// MerkleWitnesses, from the Presentation.
+	PresentationSignature string
+	// MerkleWitnesses backs merkle-member? with the inclusion proofs the
+	// presenter supplies, by value, checked against the token's signed
+	// merkle_root; see MerkleAllowlist. A proof the policy passes, as in
+	// (merkle-member? (get req "to") (get req "to_proof")), is used
+	// instead. A value without a valid proof is not a member.
+	// Crypto.MerkleMember, when set, replaces both.
+	MerkleWitnesses map[string][]MerkleProofStep
+	// Replay, when set, claims the nonce of each presentation given to
+	// VerifyPresentation, and the step of its hash-chain receipt, until the
+	// token expires or for DefaultNonceTTL, and refuses with ErrReplayed
+	// one already claimed. Presentations must then carry a nonce.
+	Replay ReplayStore
+	// Approvers maps approver names to their public keys, so that
+	// VerifyPresentation can back approval_ok? with the presentation's
+	// discharge tokens. (approval_ok? "name") holds if a discharge issued
+	// by name's key allows {"approver": "name", "token": <the presented
+	// token's signature>}.
+	Approvers map[string]string
+	// Signatures selects which of a hybrid token's signatures must verify.
+	// The zero value requires the classical signature only.
+	Signatures SignatureRequirement
+	// PQKeys is the trust anchor for ML-DSA-65 signatures: it maps each
+	// trusted issuer's hex public key, after DID and KeyResolver
+	// resolution, to the issuer's hex ML-DSA-65 public key. The token's
+	// pq_public_key is not signed, so RequirePQ and RequireBoth verify the
+	// PQ signature only under the key pinned here, and refuse every token
+	// when PQKeys is empty.
+	PQKeys map[string]string
+	// KeyResolver, when set, is the trust anchor: the token must verify
+	// under the key it resolves, e.g. a KeyRing of current and previous
+	// issuer keys.
+	KeyResolver KeyResolver
+	// HMACSecret verifies HS256 tokens minted with MintHMAC.
+	HMACSecret []byte
+	// WebAuthn, when set and Crypto.AttestedOk is nil, backs attested_ok?
+	// with a passkey assertion check. A nil Challenge defaults to
+	// SHA-256(signing payload), binding the assertion to this token.
+	WebAuthn *WebAuthnCheck
+	// X509, when set, requires the issuer key to be certified by a chain
+	// ending in one of its pinned roots.
+	X509 *X509Options
+	// Transparency, when set, requires the token's inclusion proof to
+	// verify against a tree head signed by the log.
+	Transparency *TransparencyOptions
+	// SPIFFE verifies the presenting workload's SVID for tokens whose
+	// pop_key is a SPIFFE ID.
+	SPIFFE *SPIFFEOptions
+	// DIDResolver resolves public_key and pop_key values that are DIDs.
+	// did:key is always resolved locally when this is nil.
+	DIDResolver DIDResolver
+	// Algorithms adds signature algorithms implemented outside this package,
+	// keyed by alg, such as BLS12-381 from the bls module. Tokens using them
+	// must be version 0.3.0.
+	Algorithms map[string]SignatureVerifier
+	// Explain records the policy evaluation in VerifyTokenResult.Trace.
+	Explain bool
+	// Tracer, when set, receives spans for the steps of verification.
+	Tracer Tracer
+	// PolicyCache holds parsed policies between verifications; nil uses
+	// DefaultPolicyCache.
+	PolicyCache *PolicyCache
+	// Limits bounds parsing and evaluating the policy; zero fields take
+	// their values from DefaultLimits.
+	Limits Limits
+	// Memoize evaluates each repeated lookup, such as (get req "amount")
+	// in several clauses, once per verification; see Env.Memoize.
+	Memoize bool
+	// Classifier marks request fields as sensitive, to be redacted in
+	// VerifyTokenResult.Trace and by the packages that record requests.
+	Classifier *FieldClassifier
+	// Profile, when set, rejects with ErrProfile a token whose policy and
+	// caveats fall short of it, before the policy is evaluated.
+	Profile *PolicyProfile
+
+	auth     *authMemo    // set by VerifyBatch
+	scratch  *sync.Pool   // of *verifyScratch, set by Verifier
+	decision *Decision    // filled in for Verifier.Decide
+	policies *policyTable // set by Verifier
+
+	presentation *Presentation // set by VerifyPresentation
+}
+
+// SignatureRequirement selects which token signatures a verifier insists on.
+type SignatureRequirement int
+
+const (
+	// RequireClassical requires the Ed25519/ES256 signature; any ML-DSA
+	// signature is ignored.
+	RequireClassical SignatureRequirement = iota
+	// RequirePQ requires the ML-DSA-65 signature, under the key
+	// VerifyTokenOptions.PQKeys pins for the issuer; the classical
+	// signature is ignored.
+	RequirePQ
+	// RequireBoth requires both signatures to verify, the ML-DSA-65 one
+	// as RequirePQ does.
+	RequireBoth
+)
+
+// verifyAuthenticity checks that the token was issued by who it claims:
+// suite negotiation, key resolution, certificate binding, and the classical,
+// PQ, or HMAC signatures.
+func verifyAuthenticity(t *Token, payload []byte, opts *VerifyTokenOptions, now time.Time) error {
+	// Negotiate the signature suite: pre-0.3.0 tokens are always Ed25519
+	switch t.Alg {
+	case "", AlgEd25519:
+	case AlgES256, AlgHS256:
+		if t.Version == TokenVersion {
+			return kindf(ErrUnsupportedAlg, "alg %s requires token version %s", t.Alg, TokenVersionAlg)
+		}
+	default:
+		if opts.Algorithms[t.Alg] == nil {
+			return fmt.Errorf("%w: %s", ErrUnsupportedAlg, t.Alg)
+		}
+		if t.Version == TokenVersion {
+			return kindf(ErrUnsupportedAlg, "alg %s requires token version %s", t.Alg, TokenVersionAlg)
+		}
+	}
+	if len(t.Caveats) > 0 && t.Alg != AlgHS256 {
+		return kindf(ErrMalformedToken, "caveats require an HMAC token")
+	}
+
+	if t.Alg == AlgHS256 {
+		if len(opts.HMACSecret) == 0 {
+			return kindf(ErrUntrustedKey, "HMAC token requires a shared secret")
+		}
+		if !verifyHMACChain(t, payload, opts.HMACSecret) {
+			return ErrBadSignature
+		}
+		return nil
+	}
+
+	// Key fields may be DIDs; resolve them to raw keys before verifying
+	keyAlg, issuerKey, err := resolveKeyRef(t.PublicKey, opts.DIDResolver)
+	if err != nil {
+		return withKind(ErrUntrustedKey, err)
+	}
+	tokenAlg := t.Alg
+	if tokenAlg == "" {
+		tokenAlg = AlgEd25519
+	}
+	if keyAlg != "" && keyAlg != tokenAlg {
+		return kindf(ErrMalformedToken, "public key type does not match alg")
+	}
+	if opts.KeyResolver != nil {
+		trusted, err := opts.KeyResolver.ResolveKey(t, now)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrUntrustedKey, err)
+		}
+		if issuerKey != "" && issuerKey != trusted {
+			return fmt.Errorf("%w: token key does not match resolved key", ErrUntrustedKey)
+		}
+		issuerKey = trusted
+	}
+
+	if opts.X509 != nil {
+		if err := opts.X509.verify(t, issuerKey, now); err != nil {
+			return kindf(ErrUntrustedKey, "certificate chain: %w", err)
+		}
+	}
+
+	verify := VerifySignature
+	if ext := opts.Algorithms[t.Alg]; ext != nil {
+		verify = func(_ string, msg []byte, sig, pub string) bool { return ext(msg, sig, pub) }
+	}
+	if opts.Signatures != RequireClassical && len(opts.PQKeys) == 0 {
+		return kindf(ErrUntrustedKey, "PQ signatures require pinned PQ keys")
+	}
+	if opts.Signatures != RequirePQ && !verify(t.Alg, payload, t.Signature, issuerKey) {
+		return ErrBadSignature
+	}
+	if opts.Signatures != RequireClassical {
+		if t.PQSignature == "" {
+			return kindf(ErrBadSignature, "PQ signature required")
+		}
+		pqKey, ok := opts.PQKeys[issuerKey]
+		if !ok || !strings.EqualFold(pqKey, t.PQPublicKey) {
+			return kindf(ErrUntrustedKey, "PQ key is not pinned for the issuer")
+		}
+		if !VerifyMLDSA65(payload, t.PQSignature, pqKey) {
+			return kindf(ErrBadSignature, "invalid PQ signature")
+		}
+	}
+	return nil
+}
+
+// now returns the verification time: opts.Clock's when set, else opts.Now
+// when set and valid, else the wall clock.
+func (opts *VerifyTokenOptions) now() time.Time {
+	if opts.Clock != nil {
+		return opts.Clock()
+	}
+	if opts.Now != "" {
+		if n, err := time.Parse(time.RFC3339, opts.Now); err == nil {
+			return n
+		}
 	}
-	Now                    string
-	PresentationSignature  string
+	return time.Now()
 }
 
 // VerifyTokenResult is the result of token verification.
 type VerifyTokenResult struct {
 	Allow  bool
 	Sealed bool
-	Error  string
+	// Err is why verification failed; it is nil when the token was
+	// allowed or its policy denied the request. It wraps one of the Err
+	// kinds, so test it with errors.Is, or branch on Code.
+	Err  error
+	Code ErrorCode
+	// GasUsed is the evaluation budget consumed by the policy; zero when
+	// verification failed before evaluation.
+	GasUsed int
+	// Trace is the evaluation tree when VerifyTokenOptions.Explain is set
+	// and verification reached the policy; see Explain.
+	Trace *Trace
+}
+
+// ErrorMessage returns the text of r.Err, or "" when there is none.
+func (r VerifyTokenResult) ErrorMessage() string {
+	if r.Err == nil {
+		return ""
+	}
+	return r.Err.Error()
+}
+
+// failed is the result of a verification that failed with err.
+func failed(t *Token, err error) VerifyTokenResult {
+	return VerifyTokenResult{Sealed: t != nil && t.Sealed, Err: err, Code: CodeOf(err)}
+}
+
+// Compact encodes t as the base64url (unpadded) encoding of its JSON: a
+// single line that fits in an HTTP header. ParseToken reads it back.
+func (t *Token) Compact() (string, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ParseToken decodes a token in JSON or compact form.
+func ParseToken(s string) (*Token, error) {
+	data := []byte(strings.TrimSpace(s))
+	if len(data) > 0 && data[0] != '{' {
+		var err error
+		if data, err = base64.RawURLEncoding.DecodeString(string(data)); err != nil {
+			return nil, kindf(ErrMalformedToken, "not a JSON or compact token")
+		}
+	}
+	var t Token
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, kindf(ErrMalformedToken, "invalid token JSON: %w", err)
+	}
+	return &t, nil
 }
 
 // VerifyToken verifies a token's signature and evaluates its policy.
 func VerifyToken(tokenJSON string, req map[string]any, opts VerifyTokenOptions) VerifyTokenResult {
 	var t Token
 	if err := json.Unmarshal([]byte(tokenJSON), &t); err != nil {
-		return VerifyTokenResult{Allow: false, Error: "invalid token JSON: " + err.Error()}
+		return failed(nil, kindf(ErrMalformedToken, "invalid token JSON: %w", err))
 	}
 
 	return VerifyTokenObj(&t, req, opts)
@@ -128,96 +585,290 @@ func VerifyToken(tokenJSON string, req map[string]any, opts VerifyTokenOptions)
 
 // VerifyTokenObj verifies a token object and evaluates its policy.
 func VerifyTokenObj(t *Token, req map[string]any, opts VerifyTokenOptions) VerifyTokenResult {
+	if opts.Tracer == nil {
+		return verifyTokenObj(t, req, &opts, noSpan{})
+	}
+	span := opts.Tracer.Start("agent-safe.verify")
+	res := verifyTokenObj(t, req, &opts, span)
+	span.SetAttribute(AttrAllow, res.Allow)
+	span.SetAttribute(AttrSealed, res.Sealed)
+	span.SetAttribute(AttrGasUsed, res.GasUsed)
+	span.End(res.Err)
+	return res
+}
+
+func verifyTokenObj(t *Token, req map[string]any, opts *VerifyTokenOptions, span Span) VerifyTokenResult {
+	now := opts.now()
+	if opts.Clock != nil {
+		// Pin the clock's time for the rest of the verification.
+		opts.Now, opts.Clock = now.UTC().Format(time.RFC3339), nil
+	}
+	if opts.decision != nil {
+		opts.decision.EvaluatedAt = now
+	}
+
 	// Check expiration
 	if t.Expires != "" {
 		exp, err := time.Parse(time.RFC3339, t.Expires)
 		if err == nil {
-			now := time.Now()
-			if opts.Now != "" {
-				if n, err := time.Parse(time.RFC3339, opts.Now); err == nil {
-					now = n
-				}
-			}
 			if now.After(exp) {
-				return VerifyTokenResult{Allow: false, Sealed: t.Sealed, Error: "token expired"}
+				return failed(t, ErrExpired)
 			}
 		}
 	}
 
 	// Verify signature over full token envelope
-	payload := SigningPayload(t.Policy, t.MerkleRoot, t.HashChainCommitment, t.Sealed, t.Expires)
-	if !VerifyEd25519(payload, t.Signature, t.PublicKey) {
-		return VerifyTokenResult{Allow: false, Sealed: t.Sealed, Error: "invalid signature"}
+	payload := t.Payload()
+	sigSpan := span.Start("agent-safe.signature")
+	err := opts.auth.verify(t, payload, opts, now)
+	sigSpan.End(err)
+	if err != nil {
+		return failed(t, err)
+	}
+	if opts.Transparency != nil {
+		if err := opts.Transparency.verify(t, t.Inclusion); err != nil {
+			return failed(t, kindf(ErrUntrustedKey, "transparency: %w", err))
+		}
 	}
 
-	// PoP binding: if token has pop_key, require and verify presentation signature
+	// PoP binding: a token with a pop_key must be presented by its holder
 	if t.PoPKey != "" {
-		if opts.PresentationSignature == "" {
-			return VerifyTokenResult{Allow: false, Sealed: t.Sealed, Error: "PoP binding requires presentation signature"}
+		popSpan := span.Start("agent-safe.pop")
+		err := verifyPoP(t, payload, opts, now)
+		popSpan.End(err)
+		if err != nil {
+			return failed(t, err)
 		}
-		h := sha256.Sum256(payload)
-		if !VerifyEd25519(h[:], opts.PresentationSignature, t.PoPKey) {
-			return VerifyTokenResult{Allow: false, Sealed: t.Sealed, Error: "invalid presentation signature"}
+	}
+	if opts.presentation != nil {
+		if err := opts.presentation.verify(t, opts, now); err != nil {
+			return failed(t, err)
 		}
 	}
 
-	// Parse policy
-	ast, err := Parse(t.Policy)
+	parseSpan := span.Start("agent-safe.parse")
+	cache := opts.PolicyCache
+	if cache == nil {
+		cache = DefaultPolicyCache
+	}
+	policy := t.PolicyFor(req)
+	ast, err := tokenPolicy(t, policy, cache, opts.Limits)
+	if err == nil && opts.Profile != nil {
+		err = opts.Profile.check(t, cache, opts.Limits)
+	}
+	parseSpan.End(err)
+	if err != nil {
+		return failed(t, err)
+	}
+
+	evalSpan := span.Start("agent-safe.eval")
+	var start time.Time
+	if opts.policies != nil {
+		start = time.Now()
+	}
+	res := evalTokenPolicy(t, ast, payload, req, opts, evalSpan)
+	if opts.policies != nil {
+		opts.policies.record(policy, res, time.Since(start))
+	}
+	evalSpan.End(res.Err)
+	return res
+}
+
+// verifyPoP checks the presenter's possession of the token's pop_key: a
+// presentation signature, a session grant or, for a SPIFFE ID, the
+// presenter's SVID.
+func verifyPoP(t *Token, payload []byte, opts *VerifyTokenOptions, now time.Time) error {
+	if p := opts.presentation; p != nil && p.Session != nil {
+		return p.verifySession(t, payload, opts, now)
+	}
+	if strings.HasPrefix(t.PoPKey, spiffeScheme) {
+		if err := opts.SPIFFE.verifyPresenter(t.PoPKey, now); err != nil {
+			return kindf(ErrPoPRequired, "SPIFFE binding: %w", err)
+		}
+		return nil
+	}
+	if opts.PresentationSignature == "" {
+		return ErrPoPRequired
+	}
+	popAlg, popKey, err := resolveKeyRef(t.PoPKey, opts.DIDResolver)
 	if err != nil {
-		return VerifyTokenResult{Allow: false, Sealed: t.Sealed, Error: "parse error: " + err.Error()}
+		return withKind(ErrMalformedToken, err)
+	}
+	if popAlg != "" && popAlg != AlgEd25519 {
+		return kindf(ErrUnsupportedAlg, "PoP key must be Ed25519")
+	}
+	var nonce string
+	if opts.presentation != nil {
+		nonce = opts.presentation.Nonce
 	}
+	h := presentationDigest(payload, nonce)
+	if !VerifyEd25519(h[:], opts.PresentationSignature, popKey) {
+		return kindf(ErrBadSignature, "invalid presentation signature")
+	}
+	return nil
+}
+
+// evalTokenPolicy evaluates a verified token's policy against req.
+func evalTokenPolicy(t *Token, ast Node, payload []byte, req map[string]any, opts *VerifyTokenOptions, span Span) VerifyTokenResult {
+	scratch := getScratch(opts.scratch)
+	defer putScratch(opts.scratch, scratch)
 
 	// Set up defaults
 	perDayCount := opts.PerDayCount
+	var counters *counterUse
+	if opts.Counters != nil {
+		perDayCount = PerDayCountFrom(opts.Counters)
+	}
+	if opts.Counters != nil || opts.Rates != nil || opts.Ledger != nil {
+		counters = &scratch.counters
+		counters.ledger, counters.token = opts.Ledger, t.Signature
+	}
 	if perDayCount == nil {
 		perDayCount = func(_, _ string) int { return 0 }
 	}
-	dpopOk := opts.Crypto.DPoPOk
-	if dpopOk == nil {
-		dpopOk = func() bool { return false }
-	}
-	merkleOk := opts.Crypto.MerkleOk
-	if merkleOk == nil {
-		merkleOk = func(_ []any) bool { return false }
+	if opts.Tracer != nil {
+		count := perDayCount
+		perDayCount = func(action, day string) int {
+			s := span.Start("agent-safe.counter")
+			n := count(action, day)
+			s.SetAttribute(AttrAction, action)
+			s.SetAttribute(AttrWindow, day)
+			s.SetAttribute(AttrCount, n)
+			s.End(nil)
+			return n
+		}
 	}
-	vrfOk := opts.Crypto.VRFOk
-	if vrfOk == nil {
-		vrfOk = func(_ string, _ float64) bool { return false }
+	crypto := opts.Crypto
+	if opts.WebAuthn != nil && crypto.AttestedOk == nil {
+		check := *opts.WebAuthn
+		if check.Challenge == nil {
+			h := sha256.Sum256(payload)
+			check.Challenge = h[:]
+		}
+		crypto.AttestedOk = func() bool {
+			return VerifyWebAuthnAssertion(&check.Assertion, check.WebAuthnOptions) == nil
+		}
 	}
-	threshOk := opts.Crypto.ThreshOk
-	if threshOk == nil {
-		threshOk = func() bool { return false }
+
+	if crypto.MerkleMember == nil && t.MerkleRoot != "" {
+		root, witnesses := t.MerkleRoot, opts.MerkleWitnesses
+		crypto.MerkleMember = func(value string, proof []MerkleProofStep) bool {
+			if proof == nil {
+				var ok bool
+				if proof, ok = witnesses[value]; !ok {
+					return false
+				}
+			}
+			return VerifyMerkleProof(value, proof, root)
+		}
 	}
 
-	vars := opts.Vars
-	if vars == nil {
-		vars = map[string]any{}
+	// Copy vars before setting now so that options can be shared by
+	// concurrent verifications.
+	vars := scratch.varsFor(opts)
+
+	env := Env{
+		Req:          req,
+		Vars:         vars,
+		RequiredVars: opts.RequiredVars,
+		MaxGas:       opts.Limits.MaxGas,
+		MaxDepth:     opts.Limits.MaxDepth,
+		PerDayCount:  perDayCount,
+		Crypto:       crypto,
+		Memoize:      opts.Memoize,
+		Classifier:   opts.Classifier,
+		counters:     counters,
 	}
-	if opts.Now != "" {
-		vars["now"] = opts.Now
+	if opts.Memoize {
+		env.memo = scratch.memoFor()
+	}
+	var failing *failPath
+	if opts.decision != nil {
+		failing = &failPath{}
+		env.failing = failing
+	}
+	now := opts.now()
+	if rates := opts.Rates; rates != nil {
+		// A store error counts as unlimited use and an empty bucket,
+		// failing closed.
+		env.WindowCount = func(action string, window time.Duration) int {
+			n, err := rates.WindowCount(action, window, now)
+			if err != nil {
+				return unlimited
+			}
+			return n
+		}
+		env.BucketOk = func(action string, b Bucket) bool {
+			n, err := rates.BucketTokens(action, b, now)
+			return err == nil && n >= 1
+		}
 	}
 
-	env := Env{
-		Req:         req,
-		Vars:        vars,
-		PerDayCount: perDayCount,
-		Crypto: struct {
-			DPoPOk   func() bool
-			MerkleOk func(tuple []any) bool
-			VRFOk    func(day string, amount float64) bool
-			ThreshOk func() bool
-		}{
-			DPoPOk:   dpopOk,
-			MerkleOk: merkleOk,
-			VRFOk:    vrfOk,
-			ThreshOk: threshOk,
-		},
-	}
-
-	allow, err := Verify(ast, env)
+	if ledger := opts.Ledger; ledger != nil {
+		// A store error counts as an unlimited spend, failing closed.
+		env.SumAmount = func(action, period, currency string, pending Money) float64 {
+			p, _ := LedgerPeriod(period, now)
+			total, err := ledger.Spent(LedgerKey{t.Signature, action, p}, pending, currency)
+			if err != nil {
+				return math.Inf(1)
+			}
+			return total
+		}
+	}
+
+	var trace *Trace
+	var allow bool
+	var gas int
+	var err error
+	if opts.Explain || opts.Tracer != nil {
+		// Tracing explains the evaluation to report the failing clause.
+		var ex *Explanation
+		ex, err = Explain(ast, env)
+		allow, gas = ex.Allow, ex.GasUsed
+		if opts.Explain {
+			trace = ex.Root
+		}
+		if ex.Failing != nil {
+			span.SetAttribute(AttrFailingClause, ex.Failing.Expr)
+		}
+	} else {
+		allow, gas, err = VerifyWithGas(ast, env)
+	}
+	if failing != nil && !allow {
+		failing.clause(ast, opts.decision)
+	}
+	if err == nil && allow && counters != nil {
+		if allow, err = counters.take(opts.Counters, opts.Rates, now); err != nil {
+			err = kindf(ErrStore, "counter store: %w", err)
+		}
+	}
 	if err != nil {
-		return VerifyTokenResult{Allow: false, Sealed: t.Sealed, Error: err.Error()}
+		res := failed(t, err)
+		res.GasUsed, res.Trace = gas, trace
+		return res
 	}
 
-	return VerifyTokenResult{Allow: allow, Sealed: t.Sealed}
+	return VerifyTokenResult{Allow: allow, Sealed: t.Sealed, GasUsed: gas, Trace: trace}
+}
+
+// tokenPolicy parses policy, one of a token's policies, plus any caveats
+// appended to an HMAC token, through c within l; a nil c parses without
+// caching.
+func tokenPolicy(t *Token, policy string, c *PolicyCache, l Limits) (Node, error) {
+	ast, err := c.ParseLimits(policy, l)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPolicySyntax, err)
+	}
+	if len(t.Caveats) > 0 {
+		all := List{Sym("and"), ast}
+		for _, cv := range t.Caveats {
+			cav, err := c.ParseLimits(cv, l)
+			if err != nil {
+				return nil, kindf(ErrPolicySyntax, "caveat parse error: %w", err)
+			}
+			all = append(all, cav)
+		}
+		ast = all
+	}
+	return ast, nil
 }
@@ -0,0 +1,336 @@
+package spl
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+const tokenTestPolicy = `(and (= (get req "action") "payments.create") (<= (get req "amount") 100))`
+
+func tokenTestReq(amount float64) map[string]any {
+	return map[string]any{"action": "payments.create", "amount": amount}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// --- Signature suites ---
+
+func TestMintVerifyES256(t *testing.T) {
+	pub, priv, err := GenerateKeypairAlg(AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{Alg: AlgES256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Alg != AlgES256 || tok.Version != TokenVersionAlg {
+		t.Fatalf("expected alg %s version %s, got %s %s", AlgES256, TokenVersionAlg, tok.Alg, tok.Version)
+	}
+	if tok.PublicKey != pub {
+		t.Fatal("expected token public key to match generated key")
+	}
+	r := VerifyToken(mustJSON(t, tok), tokenTestReq(50), VerifyTokenOptions{})
+	if !r.Allow {
+		t.Fatalf("expected allow, got error %q", r.ErrorMessage())
+	}
+	r = VerifyToken(mustJSON(t, tok), tokenTestReq(500), VerifyTokenOptions{})
+	if r.Allow {
+		t.Fatal("expected deny for amount over limit")
+	}
+}
+
+func TestES256TamperedPolicy(t *testing.T) {
+	_, priv, _ := GenerateKeypairAlg(AlgES256)
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{Alg: AlgES256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok.Policy = `(and (= (get req "action") "payments.create") (<= (get req "amount") 1000))`
+	r := VerifyTokenObj(tok, tokenTestReq(500), VerifyTokenOptions{})
+	if r.Allow || r.ErrorMessage() != "invalid signature" {
+		t.Fatalf("expected invalid signature, got %+v", r)
+	}
+}
+
+func TestAlgDowngradeRejected(t *testing.T) {
+	_, priv, _ := GenerateKeypairAlg(AlgES256)
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{Alg: AlgES256})
+	tok.Version = TokenVersion
+	if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{}); r.Allow {
+		t.Fatal("expected ES256 token with 0.2.0 version to be rejected")
+	}
+
+	_, edPriv := GenerateKeypair()
+	edTok, _ := Mint(tokenTestPolicy, edPriv, MintOptions{})
+	edTok.Alg = AlgES256
+	edTok.Version = TokenVersionAlg
+	if r := VerifyTokenObj(edTok, tokenTestReq(50), VerifyTokenOptions{}); r.Allow {
+		t.Fatal("expected Ed25519 key relabelled as ES256 to be rejected")
+	}
+}
+
+func TestUnsupportedAlg(t *testing.T) {
+	_, priv := GenerateKeypair()
+	if _, err := Mint(tokenTestPolicy, priv, MintOptions{Alg: "RS256"}); err == nil {
+		t.Fatal("expected error minting with unsupported alg")
+	}
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{})
+	tok.Alg = "none"
+	r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{})
+	if r.Allow || r.ErrorMessage() != "unsupported algorithm: none" {
+		t.Fatalf("expected unsupported algorithm, got %+v", r)
+	}
+}
+
+func TestEd25519TokenUnchanged(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Alg != "" || tok.Version != TokenVersion {
+		t.Fatalf("expected legacy Ed25519 shape, got alg %q version %q", tok.Alg, tok.Version)
+	}
+	if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{}); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+}
+
+func TestHybridPQSignatures(t *testing.T) {
+	if !PQAvailable {
+		t.Skip("ML-DSA not available in this build")
+	}
+	pub, priv := GenerateKeypair()
+	pqPub, pqPriv, err := GeneratePQKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{PQPrivateKey: pqPriv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.PQSignature == "" || tok.PQPublicKey == "" {
+		t.Fatal("expected hybrid token to carry PQ key and signature")
+	}
+	pinned := map[string]string{pub: pqPub}
+	for _, mode := range []SignatureRequirement{RequireClassical, RequirePQ, RequireBoth} {
+		if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{Signatures: mode, PQKeys: pinned}); !r.Allow {
+			t.Fatalf("mode %d: expected allow, got %q", mode, r.ErrorMessage())
+		}
+	}
+
+	// A broken classical signature only matters when the verifier requires it.
+	broken := *tok
+	broken.Signature = tok.PQSignature[:128]
+	if r := VerifyTokenObj(&broken, tokenTestReq(50), VerifyTokenOptions{Signatures: RequirePQ, PQKeys: pinned}); !r.Allow {
+		t.Fatalf("expected PQ-only verifier to allow, got %q", r.ErrorMessage())
+	}
+	if r := VerifyTokenObj(&broken, tokenTestReq(50), VerifyTokenOptions{Signatures: RequireBoth, PQKeys: pinned}); r.Allow {
+		t.Fatal("expected RequireBoth to reject a bad classical signature")
+	}
+
+	// Tampering with the policy breaks the PQ signature as well.
+	tampered := *tok
+	tampered.Policy = tokenTestPolicy + " "
+	if r := VerifyTokenObj(&tampered, tokenTestReq(50), VerifyTokenOptions{Signatures: RequirePQ, PQKeys: pinned}); r.Allow || r.ErrorMessage() != "invalid PQ signature" {
+		t.Fatalf("expected invalid PQ signature, got %+v", r)
+	}
+}
+
+func TestPQForgedKeyRejected(t *testing.T) {
+	if !PQAvailable {
+		t.Skip("ML-DSA not available in this build")
+	}
+	pub, _ := GenerateKeypair()
+	pqPub, _, _ := GeneratePQKeypair()
+	// The attacker names the issuer's key but signs with a PQ key of its own.
+	_, attacker := GenerateKeypair()
+	_, attackerPQ, _ := GeneratePQKeypair()
+	forged, err := Mint(`#t`, attacker, MintOptions{PQPrivateKey: attackerPQ})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.PublicKey = pub
+	for _, mode := range []SignatureRequirement{RequirePQ, RequireBoth} {
+		r := VerifyTokenObj(forged, tokenTestReq(50), VerifyTokenOptions{Signatures: mode})
+		if r.Allow || r.Code != CodeUntrustedKey {
+			t.Fatalf("mode %d without pinned keys: expected an untrusted key, got %+v", mode, r)
+		}
+	}
+	ring, err := NewKeyRing(KeyRingEntry{PublicKey: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := VerifyTokenOptions{Signatures: RequirePQ, PQKeys: map[string]string{pub: pqPub}, KeyResolver: ring}
+	if r := VerifyTokenObj(forged, tokenTestReq(50), opts); r.Allow || r.Code != CodeUntrustedKey {
+		t.Fatalf("expected the forged PQ key to be refused, got %+v", r)
+	}
+}
+
+func TestPQRequiredButMissing(t *testing.T) {
+	pub, priv := GenerateKeypair()
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{})
+	r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{Signatures: RequireBoth, PQKeys: map[string]string{pub: "00"}})
+	if r.Allow || r.ErrorMessage() != "PQ signature required" {
+		t.Fatalf("expected PQ signature required, got %+v", r)
+	}
+}
+
+func TestMintWithSigner(t *testing.T) {
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed, _ := ed25519.GenerateKey(rand.Reader)
+	for alg, signer := range map[string]crypto.Signer{AlgES256: ec, AlgEd25519: ed} {
+		tok, err := MintWithSigner(tokenTestPolicy, signer, MintOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{}); !r.Allow {
+			t.Fatalf("%s: expected allow, got %q", alg, r.ErrorMessage())
+		}
+	}
+	if _, err := MintWithSigner(tokenTestPolicy, ec, MintOptions{Alg: AlgEd25519}); err == nil {
+		t.Fatal("expected alg mismatch to be rejected")
+	}
+}
+
+func TestCompactToken(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{Expires: "2099-01-01T00:00:00Z"})
+	compact, err := tok.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{compact, mustJSON(t, tok), " " + compact + "\n"} {
+		got, err := ParseToken(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Signature != tok.Signature || got.Expires != tok.Expires {
+			t.Fatalf("round trip lost fields: %+v", got)
+		}
+	}
+	for _, bad := range []string{"", "not a token!", "e30x"} {
+		if _, err := ParseToken(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestVerifyTokenLeavesVarsAlone(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, _ := Mint(tokenTestPolicy, priv, MintOptions{})
+	vars := map[string]any{"limit": 100.0}
+	VerifyTokenObj(tok, tokenTestReq(5), VerifyTokenOptions{Vars: vars, Now: "2026-01-01T00:00:00Z"})
+	if _, ok := vars["now"]; ok {
+		t.Fatal("expected the caller's vars to be left unchanged")
+	}
+}
+
+func TestVerifyTokenRequiredVars(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(= (get req "role") admin_role)`, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := map[string]any{"role": "admin_role"}
+	if r := VerifyTokenObj(tok, req, VerifyTokenOptions{}); !r.Allow {
+		t.Fatalf("expected the lenient symbol to allow, got %q", r.ErrorMessage())
+	}
+	r := VerifyTokenObj(tok, req, VerifyTokenOptions{RequiredVars: []string{"admin_role"}})
+	if r.Allow || r.Code != CodeUnresolvedSymbol {
+		t.Fatalf("expected an unresolved symbol, got %+v", r)
+	}
+}
+
+func TestVerifyTokenClock(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(and (before now "2026-06-01T00:00:00Z") (<= (get req "amount") 100))`, priv, MintOptions{Expires: "2026-09-01T00:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads := 0
+	at := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { reads++; return at }
+	// Clock takes precedence over Now, and is read once.
+	opts := VerifyTokenOptions{Clock: clock, Now: "2026-07-01T00:00:00Z"}
+	if r := VerifyTokenObj(tok, tokenTestReq(50), opts); !r.Allow || reads != 1 {
+		t.Fatalf("expected allow from one clock read, got %q after %d reads", r.ErrorMessage(), reads)
+	}
+	at = at.AddDate(0, 2, 0)
+	if r := VerifyTokenObj(tok, tokenTestReq(50), opts); r.Allow || r.Err != nil {
+		t.Fatalf("expected the policy to deny after its deadline, got %+v", r)
+	}
+	at = at.AddDate(0, 3, 0)
+	if r := VerifyTokenObj(tok, tokenTestReq(50), opts); r.Code != CodeExpired {
+		t.Fatalf("expected the token to expire, got %+v", r)
+	}
+}
+
+func TestTokenActions(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(= (get req "action") "read")`, priv, MintOptions{Actions: map[string]string{
+		"payments.create": `(<= (get req "amount") 100)`,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		req   map[string]any
+		allow bool
+	}{
+		{map[string]any{"action": "read"}, true},
+		{map[string]any{"action": "delete"}, false},
+		{map[string]any{"action": "payments.create", "amount": 50.0}, true},
+		{map[string]any{"action": "payments.create", "amount": 500.0}, false},
+		{map[string]any{"amount": 5.0}, false},
+	} {
+		if r := VerifyTokenObj(tok, c.req, VerifyTokenOptions{}); r.Allow != c.allow || r.Err != nil {
+			t.Errorf("%v: expected allow %v, got %+v", c.req, c.allow, r)
+		}
+	}
+
+	// The action policies are signed.
+	forged := *tok
+	forged.Actions = map[string]string{"payments.create": "#t"}
+	if r := VerifyTokenObj(&forged, map[string]any{"action": "payments.create"}, VerifyTokenOptions{}); r.Code != CodeBadSignature {
+		t.Fatalf("expected a changed action policy to break the signature, got %+v", r)
+	}
+	forged.Actions = nil
+	if r := VerifyTokenObj(&forged, map[string]any{"action": "read"}, VerifyTokenOptions{}); r.Code != CodeBadSignature {
+		t.Fatalf("expected dropped action policies to break the signature, got %+v", r)
+	}
+	plain, _ := Mint(tokenTestPolicy, priv, MintOptions{})
+	if !bytes.Equal(plain.Payload(), SigningPayload(plain.Policy, "", "", false, "")) {
+		t.Fatal("expected a token without actions to keep its signing payload")
+	}
+
+	if _, err := Mint("#t", priv, MintOptions{Actions: map[string]string{"": "#t"}}); err == nil {
+		t.Fatal("expected an empty action name to be refused")
+	}
+	secret := bytes.Repeat([]byte{7}, MinHMACSecretSize)
+	mac, err := MintHMAC("#f", secret, MintOptions{Actions: map[string]string{"read": "#t"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := VerifyTokenObj(mac, map[string]any{"action": "read"}, VerifyTokenOptions{HMACSecret: secret}); !r.Allow {
+		t.Fatalf("expected the HMAC token's action policy to allow, got %q", r.ErrorMessage())
+	}
+}
@@ -0,0 +1,38 @@
+package spl
+
+// Tracer receives the spans of a verification, for distributed tracing. The
+// splotel module adapts an OpenTelemetry TracerProvider.
+//
+// VerifyTokenObj starts an agent-safe.verify span with the children
+// agent-safe.signature, agent-safe.pop, agent-safe.parse and
+// agent-safe.eval, the last of which has an agent-safe.counter child for
+// each per-day-count lookup.
+type Tracer interface {
+	// Start begins a span named name.
+	Start(name string) Span
+}
+
+// Span is a traced operation. Spans it starts are its children.
+type Span interface {
+	Tracer
+	SetAttribute(key string, value any)
+	// End finishes the span, marking it failed when err is not nil.
+	End(err error)
+}
+
+// Span attribute keys.
+const (
+	AttrAllow         = "agent_safe.allow"
+	AttrSealed        = "agent_safe.sealed"
+	AttrGasUsed       = "agent_safe.gas_used"
+	AttrFailingClause = "agent_safe.failing_clause"
+	AttrAction        = "agent_safe.action"
+	AttrWindow        = "agent_safe.window"
+	AttrCount         = "agent_safe.count"
+)
+
+type noSpan struct{}
+
+func (noSpan) Start(string) Span        { return noSpan{} }
+func (noSpan) SetAttribute(string, any) {}
+func (noSpan) End(error)                {}
@@ -0,0 +1,69 @@
+package spl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recorder is a Tracer that records each finished span as
+// "parent/name attr=value ... error".
+type recorder struct {
+	path  string
+	attrs []string
+	spans *[]string
+}
+
+func (r *recorder) Start(name string) Span {
+	return &recorder{path: strings.TrimPrefix(r.path+"/"+name, "/"), spans: r.spans}
+}
+
+func (r *recorder) SetAttribute(key string, value any) {
+	r.attrs = append(r.attrs, fmt.Sprintf("%s=%v", key, value))
+}
+
+func (r *recorder) End(err error) {
+	s := strings.Join(append([]string{r.path}, r.attrs...), " ")
+	if err != nil {
+		s += " error=" + err.Error()
+	}
+	*r.spans = append(*r.spans, s)
+}
+
+func TestTracer(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(and (<= (per-day-count "pay" "2026-01-31") 2) (= (get req "action") "pay"))`, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spans []string
+	opts := VerifyTokenOptions{
+		PerDayCount: func(action, day string) int { return 1 },
+		Tracer:      &recorder{spans: &spans},
+	}
+	if res := VerifyTokenObj(tok, map[string]any{"action": "refund"}, opts); res.Allow || res.Trace != nil {
+		t.Fatalf("expected a deny without a trace, got %+v", res)
+	}
+	want := []string{
+		"agent-safe.verify/agent-safe.signature",
+		"agent-safe.verify/agent-safe.parse",
+		"agent-safe.verify/agent-safe.eval/agent-safe.counter agent_safe.action=pay agent_safe.window=2026-01-31 agent_safe.count=1",
+		`agent-safe.verify/agent-safe.eval agent_safe.failing_clause=(= (get req "action") "pay")`,
+		"agent-safe.verify agent_safe.allow=false agent_safe.sealed=false agent_safe.gas_used=",
+	}
+	if len(spans) != len(want) {
+		t.Fatalf("expected %d spans, got %q", len(want), spans)
+	}
+	for i := range want {
+		if !strings.HasPrefix(spans[i], want[i]) {
+			t.Errorf("span %d: expected %q, got %q", i, want[i], spans[i])
+		}
+	}
+
+	spans = nil
+	bound, _ := Mint("#t", priv, MintOptions{PoPKey: strings.Repeat("ab", 32)})
+	VerifyTokenObj(bound, nil, opts)
+	if got := spans[1]; got != "agent-safe.verify/agent-safe.pop error=PoP binding requires presentation signature" {
+		t.Fatalf("unexpected PoP span %q", got)
+	}
+}
@@ -0,0 +1,311 @@
+package spl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Translation is a policy translated to or from another policy language.
+type Translation struct {
+	Policy string `json:"policy"`
+	// Warnings note where the translation depends on data the host must
+	// supply in the other language, such as the variables SPL reads from
+	// its environment, or only approximates the original.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func (t *Translation) warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	for _, w := range t.Warnings {
+		if w == msg {
+			return
+		}
+	}
+	t.Warnings = append(t.Warnings, msg)
+}
+
+// cryptoPredicates are the zero-argument crypto built-ins, which the
+// translations read as booleans the host supplies.
+var cryptoPredicates = map[string]string{
+	"dpop_ok?": "dpop_ok", "thresh_ok?": "thresh_ok", "attested_ok?": "attested_ok",
+}
+
+// checkArity reports a built-in called with the wrong number of arguments,
+// which Parse accepts but a translation cannot render faithfully.
+func checkArity(n *syntaxNode) error {
+	op := n.op()
+	a, ok := opArity[op]
+	if !ok {
+		return fmt.Errorf("%s: unknown operator %s", n.pos, op)
+	}
+	if got := len(n.list) - 1; got < a.min || (a.max >= 0 && got > a.max) {
+		return fmt.Errorf("%s: %s takes %d argument%s, got %d", n.pos, op, a.max, plural(a.max), got)
+	}
+	return nil
+}
+
+// splString renders s as an SPL string literal. SPL strings cannot contain
+// a double quote.
+func splString(s string) (string, error) {
+	if strings.ContainsRune(s, '"') {
+		return "", fmt.Errorf("string %q contains a double quote, which SPL strings cannot", s)
+	}
+	return strconv.Quote(s), nil
+}
+
+// splNumber renders f as an SPL number.
+func splNumber(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+
+// quoteC renders s as a double-quoted string in Cedar (cedar true) or JSON
+// and Rego syntax.
+func quoteC(s string, cedar bool) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			switch {
+			case unicode.IsPrint(r):
+				b.WriteRune(r)
+			case cedar:
+				fmt.Fprintf(&b, `\u{%x}`, r)
+			default:
+				fmt.Fprintf(&b, `\u%04x`, r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// isIdent reports whether s can be written as a bare attribute name in
+// Cedar and Rego.
+func isIdent(s string) bool {
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// A cToken is a token of Cedar or Rego source.
+type cToken struct {
+	kind byte   // 'i' identifier, 'n' number, 's' string, 'p' punctuation, '\n' newline, 0 end
+	text string // strings hold their decoded value
+	pos  Position
+}
+
+func (t cToken) String() string {
+	switch t.kind {
+	case 0:
+		return "end of input"
+	case '\n':
+		return "newline"
+	}
+	return strconv.Quote(t.text)
+}
+
+var cPunct = []string{"==", "!=", "<=", ">=", "&&", "||", ":=", "::",
+	"<", ">", "!", "(", ")", "[", "]", "{", "}", ",", ";", ".", "@", ":", "-", "+", "*", "="}
+
+// lexC splits Cedar or Rego source into tokens. comment starts a line
+// comment. With newlines, line breaks outside parentheses and brackets are
+// tokens, as Rego separates rule body statements with them.
+func lexC(src, comment string, newlines bool) ([]cToken, error) {
+	var toks []cToken
+	line, col := 1, 1
+	depth := 0
+	advance := func(n int) {
+		for _, r := range src[:n] {
+			if r == '\n' {
+				line, col = line+1, 1
+			} else {
+				col++
+			}
+		}
+		src = src[n:]
+	}
+	for src != "" {
+		pos := Position{line, col}
+		r, size := utf8.DecodeRuneInString(src)
+		switch {
+		case r == '\n':
+			if newlines && depth == 0 && (len(toks) == 0 || toks[len(toks)-1].kind != '\n') {
+				toks = append(toks, cToken{kind: '\n', pos: pos})
+			}
+			advance(size)
+		case unicode.IsSpace(r):
+			advance(size)
+		case strings.HasPrefix(src, comment):
+			end := strings.IndexByte(src, '\n')
+			if end < 0 {
+				end = len(src)
+			}
+			advance(end)
+		case r == '_' || unicode.IsLetter(r):
+			end := strings.IndexFunc(src, func(r rune) bool { return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+			if end < 0 {
+				end = len(src)
+			}
+			toks = append(toks, cToken{'i', src[:end], pos})
+			advance(end)
+		case r >= '0' && r <= '9':
+			end := strings.IndexFunc(src, func(r rune) bool { return r != '.' && r != 'e' && r != 'E' && (r < '0' || r > '9') })
+			if end < 0 {
+				end = len(src)
+			}
+			toks = append(toks, cToken{'n', src[:end], pos})
+			advance(end)
+		case r == '"':
+			s, n, err := unquoteC(src)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", pos, err)
+			}
+			toks = append(toks, cToken{'s', s, pos})
+			advance(n)
+		case r == '`' && newlines:
+			end := strings.IndexByte(src[1:], '`')
+			if end < 0 {
+				return nil, fmt.Errorf("%s: unterminated raw string", pos)
+			}
+			toks = append(toks, cToken{'s', src[1 : end+1], pos})
+			advance(end + 2)
+		default:
+			p := ""
+			for _, c := range cPunct {
+				if strings.HasPrefix(src, c) {
+					p = c
+					break
+				}
+			}
+			if p == "" {
+				return nil, fmt.Errorf("%s: unexpected %q", pos, r)
+			}
+			switch p {
+			case "(", "[":
+				depth++
+			case ")", "]":
+				depth--
+			}
+			toks = append(toks, cToken{'p', p, pos})
+			advance(len(p))
+		}
+	}
+	return append(toks, cToken{pos: Position{line, col}}), nil
+}
+
+// unquoteC decodes the double-quoted string at the start of src, accepting
+// the escapes of both Cedar (\u{...}) and JSON (\uXXXX), and returns its
+// value and source length.
+func unquoteC(src string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch r {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\n':
+			return "", 0, fmt.Errorf("unterminated string")
+		case '\\':
+			if i+1 >= len(src) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i += 2
+			switch e := src[i-1]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '0':
+				b.WriteByte(0)
+			case '"', '\\', '\'', '/', '*':
+				b.WriteByte(e)
+			case 'u':
+				var hex string
+				if strings.HasPrefix(src[i:], "{") {
+					end := strings.IndexByte(src[i:], '}')
+					if end < 0 {
+						return "", 0, fmt.Errorf("invalid \\u escape")
+					}
+					hex, i = src[i+1:i+end], i+end+1
+				} else if i+4 <= len(src) {
+					hex, i = src[i:i+4], i+4
+				}
+				v, err := strconv.ParseUint(hex, 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid \\u escape")
+				}
+				b.WriteRune(rune(v))
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c", e)
+			}
+		default:
+			b.WriteRune(r)
+			i += size
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// cParser walks the tokens of Cedar or Rego source.
+type cParser struct {
+	toks []cToken
+	i    int
+}
+
+func (p *cParser) peek() cToken { return p.toks[p.i] }
+
+func (p *cParser) next() cToken {
+	t := p.toks[p.i]
+	if t.kind != 0 {
+		p.i++
+	}
+	return t
+}
+
+// is reports whether the next token is punctuation or an identifier
+// spelled text.
+func (p *cParser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == 'p' || t.kind == 'i') && t.text == text
+}
+
+func (p *cParser) accept(text string) bool {
+	if p.is(text) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *cParser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %q, found %s", text, p.peek())
+	}
+	return nil
+}
+
+func (p *cParser) skipNewlines() {
+	for p.peek().kind == '\n' {
+		p.i++
+	}
+}
+
+func (p *cParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s: %s", p.peek().pos, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,189 @@
+package spl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToCedar(t *testing.T) {
+	got, err := ToCedar(`(and (= (get req "action") "read") (<= (get req "amount") 50))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "permit (principal, action, resource)\nwhen {\n  (context.action == \"read\") && (context.amount <= 50)\n};\n"
+	if got.Policy != want || len(got.Warnings) != 0 {
+		t.Fatalf("got:\n%s%v", got.Policy, got.Warnings)
+	}
+	got, _ = ToCedar(`(and (before now "2026-12-31T00:00:00Z") (dpop_ok?) (member (get req "to-whom") allowed))`)
+	for _, s := range []string{`context.vars.now < datetime("2026-12-31T00:00:00Z")`, "context.crypto.dpop_ok", `context.vars.allowed.contains(context["to-whom"])`} {
+		if !strings.Contains(got.Policy, s) {
+			t.Fatalf("expected %s in:\n%s", s, got.Policy)
+		}
+	}
+	if len(got.Warnings) != 4 {
+		t.Fatalf("expected warnings for now, allowed, before and dpop_ok?, got %q", got.Warnings)
+	}
+	got, _ = ToCedar(`(not-member (get req "to") blocked)`)
+	if !strings.Contains(got.Policy, "!context.vars.blocked.contains(context.to)") {
+		t.Fatalf("unexpected not-member translation:\n%s", got.Policy)
+	}
+	for _, src := range []string{`(<= (get req "amount") 49.5)`, `(<= (per-day-count "pay" "2026-01-31") 3)`, `(= (get req (get req "k")) 1)`, `(merkle_ok? (tuple 1))`} {
+		if _, err := ToCedar(src); err == nil {
+			t.Fatalf("expected %s to have no Cedar translation", src)
+		}
+	}
+}
+
+func TestToRego(t *testing.T) {
+	got, err := ToRego(`(and (= (get req "action") "read") (or (<= (get req "amount") 50) (dpop_ok?)))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package agentsafe\n\nimport rego.v1\n\ndefault allow := false\n\n" +
+		"allow if {\n\tinput.action == \"read\"\n\tany_1\n}\n\n" +
+		"any_1 if {\n\tinput.amount <= 50\n}\n\n" +
+		"any_1 if {\n\tdata.crypto.dpop_ok\n}\n"
+	if got.Policy != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got.Policy, want)
+	}
+	got, _ = ToRego(`(not-member (get req "to") blocked)`)
+	if !strings.Contains(got.Policy, "\tis_array(data.vars.blocked)\n\tnot input.to in data.vars.blocked\n") {
+		t.Fatalf("expected not-member to fail when the list is undefined, got:\n%s", got.Policy)
+	}
+	if _, err := ToRego(`(vrf_ok? "2026-01-31" 5)`); err == nil {
+		t.Fatal("expected vrf_ok? to have no Rego translation")
+	}
+}
+
+// TestTranslationRoundTrip translates policies to Cedar and Rego and back,
+// and checks that the result decides every request as the original does.
+func TestTranslationRoundTrip(t *testing.T) {
+	policies := []string{
+		`(and (= (get req "action") "read") (<= (get req "amount") 50))`,
+		`(or (not (= (get req "action") "delete")) (and (dpop_ok?) (> (get req "amount") -5)))`,
+		`(and (member (get req "recipient") (tuple "alice" "bob")) (subset? (get req "tags") (tuple "a" "b" "c")))`,
+		`(not (and (= (get req "action") "pay") (< (get req "amount") 10)))`,
+		`(and (before now "2026-06-01T00:00:00Z") (or (approval_ok? "parent") (thresh_ok?)))`,
+		`(= (get (get req "to") "country") "NZ")`,
+	}
+	rego := []string{`(<= (per-day-count (get req "action") "2026-01-31") 2)`}
+	reqs := []map[string]any{
+		{"action": "read", "amount": 20.0, "recipient": "alice", "tags": []any{"a"}, "to": map[string]any{"country": "NZ"}},
+		{"action": "pay", "amount": 5.0, "recipient": "carol", "tags": []any{"a", "d"}, "to": map[string]any{"country": "AU"}},
+		{"action": "delete", "amount": 80.0, "recipient": "bob", "tags": []any{}, "to": map[string]any{}},
+	}
+	envs := []Env{
+		{Vars: map[string]any{"now": "2026-01-31T00:00:00Z"}, PerDayCount: func(string, string) int { return 1 }},
+		{Vars: map[string]any{"now": "2026-07-01T00:00:00Z"}, PerDayCount: func(string, string) int { return 3 },
+			Crypto: CryptoCallbacks{DPoPOk: func() bool { return true }, ApprovalOk: func(a string) bool { return a == "parent" }}},
+	}
+	check := func(lang, src string, to func(string) (*Translation, error), from func(string) (*Translation, error)) {
+		t.Helper()
+		out, err := to(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		back, err := from(out.Policy)
+		if err != nil {
+			t.Fatalf("%s via %s:\n%s\n%v", src, lang, out.Policy, err)
+		}
+		a, _ := Parse(src)
+		b, err := Parse(back.Policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, req := range reqs {
+			for _, env := range envs {
+				env.Req = req
+				want, _ := Verify(a, env)
+				if got, _ := Verify(b, env); got != want {
+					t.Fatalf("%s via %s became %s: %v allowed %v, want %v", src, lang, back.Policy, req, got, want)
+				}
+			}
+		}
+	}
+	for _, src := range policies {
+		check("Cedar", src, ToCedar, FromCedar)
+		check("Rego", src, ToRego, FromRego)
+	}
+	for _, src := range rego {
+		check("Rego", src, ToRego, FromRego)
+	}
+}
+
+func TestFromCedar(t *testing.T) {
+	got, err := FromCedar(`
+		@id("reads")
+		permit (principal, action, resource) when { context.action == "read" };
+		// large payments are never allowed
+		forbid (principal, action, resource) when { context.amount > 100 } unless { context.crypto.thresh_ok };
+		permit (principal, action, resource) when { ["pay", "refund"].contains(context.action) && context.amount <= decimal("50.5") };`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `(and
+  (or
+    (= (get req "action") "read")
+    (and
+      (member (get req "action") (tuple "pay" "refund"))
+      (<= (get req "amount") 50.5)))
+  (not (and (> (get req "amount") 100) (not (thresh_ok?)))))
+`
+	if got.Policy != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got.Policy, want)
+	}
+	for _, src := range []string{
+		`permit (principal == User::"alice", action, resource);`,
+		`permit (principal, action, resource) when { context.amount + 1 > 2 };`,
+		`permit (principal, action, resource) when { context has amount };`,
+		`permit (principal, action, resource) when { context.crypto.unknown }`,
+	} {
+		if _, err := FromCedar(src); err == nil {
+			t.Fatalf("expected an error for %s", src)
+		}
+	}
+}
+
+func TestFromRego(t *testing.T) {
+	got, err := FromRego(`package example
+
+import rego.v1
+
+default allow := false
+
+# reads, and small payments to known recipients
+allow if input.action == "read"
+
+allow if {
+	input.action == "pay"; input.amount < 20
+	not blocked
+}
+
+blocked if input.recipient in data.vars.blocklist
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `(or
+  (= (get req "action") "read")
+  (and
+    (= (get req "action") "pay")
+    (< (get req "amount") 20)
+    (not (member (get req "recipient") blocklist))))
+`
+	if got.Policy != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got.Policy, want)
+	}
+	for _, src := range []string{
+		"allow if { x := input.amount; x < 5 }",
+		"allow if { every x in input.tags { x == \"a\" } }",
+		"allow if { missing }",
+		"allow if { loop }\nloop if { allow }",
+		"import data.other\nallow if { true }",
+		"default allow := true",
+	} {
+		if _, err := FromRego(src); err == nil {
+			t.Fatalf("expected an error for %s", src)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package spl
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"sync"
+)
+
+// Issuance transparency: a minter appends every token it mints to a
+// Merkle log (RFC 6962 hashing) whose operator signs each tree head, and
+// verifiers that set VerifyTokenOptions.Transparency accept only tokens
+// proven to be in that log. An issuer that watches the log then sees every
+// token minted under its key, so a compromised minter cannot issue tokens
+// silently.
+
+// TreeHead is a transparency log's signed statement of its size and root.
+type TreeHead struct {
+	Size uint64 `json:"size"`
+	Root string `json:"root"`
+	// Signature is the log's hex Ed25519 signature over TreeHeadPayload.
+	Signature string `json:"signature"`
+}
+
+// InclusionProof proves that a token is leaf Index of the tree TreeHead
+// signs. Hashes is the RFC 6962 audit path, leaf first.
+type InclusionProof struct {
+	Index    uint64   `json:"index"`
+	Hashes   []string `json:"hashes"`
+	TreeHead TreeHead `json:"tree_head"`
+}
+
+// TransparencyLog appends leaves to a Merkle log. MerkleLog is an
+// in-memory implementation; a hosted log can stand behind the same method.
+type TransparencyLog interface {
+	// Append adds leaf and returns its inclusion proof against a signed
+	// tree head that includes it.
+	Append(leaf []byte) (*InclusionProof, error)
+}
+
+// TransparencyOptions requires tokens to carry an inclusion proof. A token
+// that Attenuate or Seal re-signs is a new token and needs its own.
+type TransparencyOptions struct {
+	// LogKey is the hex Ed25519 public key that signs the log's tree heads.
+	LogKey string
+}
+
+// TransparencyLeaf returns a token's leaf in a transparency log: the
+// SHA-256 digest of its signing payload, issuer key and signature, each on
+// its own line. The log holds digests, not tokens, so reading it gives no
+// one a token to present.
+func TransparencyLeaf(t *Token) []byte {
+	payload := t.Payload()
+	h := sha256.Sum256(bytes.Join([][]byte{payload, []byte(t.PublicKey), []byte(t.Signature)}, []byte("\n")))
+	return h[:]
+}
+
+// TreeHeadPayload returns the bytes a tree head's signature covers.
+func TreeHeadPayload(size uint64, root string) []byte {
+	return []byte("agent-safe-tree-head\n" + strconv.FormatUint(size, 10) + "\n" + root)
+}
+
+// leafHash and nodeHash are RFC 6962's domain-separated hashes.
+func leafHash(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+func nodeHash(l, r []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(l)
+	h.Write(r)
+	return h.Sum(nil)
+}
+
+// verify checks that p proves t is in a tree signed with o.LogKey.
+func (o *TransparencyOptions) verify(t *Token, p *InclusionProof) error {
+	if p == nil {
+		return errors.New("no inclusion proof")
+	}
+	if !VerifyEd25519(TreeHeadPayload(p.TreeHead.Size, p.TreeHead.Root), p.TreeHead.Signature, o.LogKey) {
+		return errors.New("invalid tree head signature")
+	}
+	return VerifyInclusion(TransparencyLeaf(t), p.Index, p.TreeHead.Size, p.Hashes, p.TreeHead.Root)
+}
+
+// VerifyInclusion checks an RFC 6962 audit path: that leaf is leaf index of
+// the tree of size leaves whose root is rootHex.
+func VerifyInclusion(leaf []byte, index, size uint64, hashes []string, rootHex string) error {
+	if index >= size {
+		return fmt.Errorf("leaf %d is not in a tree of %d", index, size)
+	}
+	path, err := decodeHashes(hashes)
+	if err != nil {
+		return err
+	}
+	fn, sn := index, size-1
+	r := leafHash(leaf)
+	for _, p := range path {
+		if sn == 0 {
+			return errors.New("inclusion proof too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !equalHex(r, rootHex) {
+		return errors.New("inclusion proof does not match the tree head")
+	}
+	return nil
+}
+
+// VerifyConsistency checks an RFC 6962 consistency proof: that the tree of
+// size2 leaves with root root2 extends the tree of size1 leaves with root
+// root1, so the log only appended between the two tree heads.
+func VerifyConsistency(size1, size2 uint64, root1, root2 string, hashes []string) error {
+	path, err := decodeHashes(hashes)
+	if err != nil {
+		return err
+	}
+	switch {
+	case size1 > size2:
+		return fmt.Errorf("tree of %d cannot extend a tree of %d", size2, size1)
+	case size1 == size2:
+		r1, err := decodeHex(root1, sha256.Size)
+		if err != nil {
+			return fmt.Errorf("invalid root: %w", err)
+		}
+		if len(path) != 0 || !equalHex(r1, root2) {
+			return errors.New("consistency proof does not match the tree heads")
+		}
+		return nil
+	case size1 == 0:
+		return nil
+	case len(path) == 0:
+		return errors.New("empty consistency proof")
+	}
+	if size1&(size1-1) == 0 {
+		r1, err := decodeHex(root1, sha256.Size)
+		if err != nil {
+			return fmt.Errorf("invalid root: %w", err)
+		}
+		path = append([][]byte{r1}, path...)
+	}
+	fn, sn := size1-1, size2-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := path[0], path[0]
+	for _, c := range path[1:] {
+		if sn == 0 {
+			return errors.New("consistency proof too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !equalHex(fr, root1) || !equalHex(sr, root2) {
+		return errors.New("consistency proof does not match the tree heads")
+	}
+	return nil
+}
+
+func decodeHashes(hashes []string) ([][]byte, error) {
+	out := make([][]byte, len(hashes))
+	for i, h := range hashes {
+		b, err := decodeHex(h, sha256.Size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proof hash %q: %w", h, err)
+		}
+		out[i] = b
+	}
+	return out, nil
+}
+
+// MerkleLog is an in-memory TransparencyLog that signs its tree heads with
+// an Ed25519 key. It keeps every complete subtree's hash, so appends and
+// proofs take O(log² n) hashing. It is safe for concurrent use.
+type MerkleLog struct {
+	mu  sync.Mutex
+	key ed25519.PrivateKey
+	// levels[h][i] is the hash of the complete subtree of 2^h leaves
+	// starting at leaf i·2^h.
+	levels [][][]byte
+}
+
+var _ TransparencyLog = (*MerkleLog)(nil)
+
+// NewMerkleLog returns an empty log whose tree heads are signed with the
+// hex Ed25519 seed privateKeyHex.
+func NewMerkleLog(privateKeyHex string) (*MerkleLog, error) {
+	seed, err := decodeHex(privateKeyHex, ed25519.SeedSize)
+	if err != nil {
+		return nil, errors.New("transparency log key must be a hex Ed25519 seed")
+	}
+	return &MerkleLog{key: ed25519.NewKeyFromSeed(seed), levels: [][][]byte{nil}}, nil
+}
+
+// Append adds leaf and returns its inclusion proof in the new tree.
+func (l *MerkleLog) Append(leaf []byte) (*InclusionProof, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.levels[0] = append(l.levels[0], leafHash(leaf))
+	for h := 0; len(l.levels[h])%2 == 0; h++ {
+		if h+1 == len(l.levels) {
+			l.levels = append(l.levels, nil)
+		}
+		n := len(l.levels[h])
+		l.levels[h+1] = append(l.levels[h+1], nodeHash(l.levels[h][n-2], l.levels[h][n-1]))
+	}
+	size := uint64(len(l.levels[0]))
+	return &InclusionProof{Index: size - 1, Hashes: hexAll(l.path(size-1, 0, size)), TreeHead: l.treeHead(size)}, nil
+}
+
+// Size returns the number of leaves.
+func (l *MerkleLog) Size() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return uint64(len(l.levels[0]))
+}
+
+// TreeHead returns the signed head of the current tree.
+func (l *MerkleLog) TreeHead() TreeHead {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.treeHead(uint64(len(l.levels[0])))
+}
+
+// InclusionProof returns the proof that leaf index is in the tree of the
+// first size leaves, signed at that size.
+func (l *MerkleLog) InclusionProof(index, size uint64) (*InclusionProof, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if size > uint64(len(l.levels[0])) || index >= size {
+		return nil, fmt.Errorf("no leaf %d in a tree of %d", index, size)
+	}
+	return &InclusionProof{Index: index, Hashes: hexAll(l.path(index, 0, size)), TreeHead: l.treeHead(size)}, nil
+}
+
+// ConsistencyProof returns the proof that the tree of size2 leaves extends
+// the tree of size1 leaves, for VerifyConsistency.
+func (l *MerkleLog) ConsistencyProof(size1, size2 uint64) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if size1 > size2 || size2 > uint64(len(l.levels[0])) {
+		return nil, fmt.Errorf("no consistency proof from %d to %d leaves", size1, size2)
+	}
+	if size1 == 0 || size1 == size2 {
+		return []string{}, nil
+	}
+	return hexAll(l.subproof(size1, 0, size2, true)), nil
+}
+
+func (l *MerkleLog) treeHead(size uint64) TreeHead {
+	root := hex.EncodeToString(l.root(0, size))
+	return TreeHead{Size: size, Root: root, Signature: hex.EncodeToString(ed25519.Sign(l.key, TreeHeadPayload(size, root)))}
+}
+
+// split returns the largest power of two below n, for n > 1.
+func split(n uint64) uint64 {
+	return 1 << (bits.Len64(n-1) - 1)
+}
+
+// root returns the hash of leaves [lo, hi). lo is always a multiple of the
+// largest power of two not above hi-lo, so the left half of each split is
+// a complete subtree.
+func (l *MerkleLog) root(lo, hi uint64) []byte {
+	n := hi - lo
+	switch {
+	case n == 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case n&(n-1) == 0:
+		return l.levels[bits.TrailingZeros64(n)][lo/n]
+	}
+	k := split(n)
+	return nodeHash(l.root(lo, lo+k), l.root(lo+k, hi))
+}
+
+// path is RFC 6962's PATH(m, D[lo:hi]).
+func (l *MerkleLog) path(m, lo, hi uint64) [][]byte {
+	if hi-lo == 1 {
+		return nil
+	}
+	k := split(hi - lo)
+	if m < k {
+		return append(l.path(m, lo, lo+k), l.root(lo+k, hi))
+	}
+	return append(l.path(m-k, lo+k, hi), l.root(lo, lo+k))
+}
+
+// subproof is RFC 6962's SUBPROOF(m, D[lo:hi], b).
+func (l *MerkleLog) subproof(m, lo, hi uint64, b bool) [][]byte {
+	n := hi - lo
+	if m == n {
+		if b {
+			return nil
+		}
+		return [][]byte{l.root(lo, hi)}
+	}
+	k := split(n)
+	if m <= k {
+		return append(l.subproof(m, lo, lo+k, b), l.root(lo+k, hi))
+	}
+	return append(l.subproof(m-k, lo+k, hi, false), l.root(lo, lo+k))
+}
+
+func hexAll(hs [][]byte) []string {
+	out := make([]string, len(hs))
+	for i, h := range hs {
+		out[i] = hex.EncodeToString(h)
+	}
+	return out
+}
@@ -0,0 +1,163 @@
+package spl
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// naiveRoot is RFC 6962's MTH, computed from scratch.
+func naiveRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		return SHA256Hash(nil)
+	case 1:
+		return leafHash(leaves[0])
+	}
+	k := split(uint64(len(leaves)))
+	return nodeHash(naiveRoot(leaves[:k]), naiveRoot(leaves[k:]))
+}
+
+func TestMerkleLogProofs(t *testing.T) {
+	_, priv := GenerateKeypair()
+	l, err := NewMerkleLog(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var leaves [][]byte
+	var heads []TreeHead
+	for n := uint64(1); n <= 33; n++ {
+		leaf := []byte(fmt.Sprint("leaf ", n))
+		leaves = append(leaves, leaf)
+		p, err := l.Append(leaf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := hex.EncodeToString(naiveRoot(leaves)); p.TreeHead.Root != want || p.TreeHead.Size != n {
+			t.Fatalf("size %d: expected root %s, got %+v", n, want, p.TreeHead)
+		}
+		if err := VerifyInclusion(leaf, p.Index, n, p.Hashes, p.TreeHead.Root); err != nil {
+			t.Fatalf("size %d: %v", n, err)
+		}
+		heads = append(heads, p.TreeHead)
+	}
+	for size := uint64(1); size <= 33; size++ {
+		root := heads[size-1].Root
+		for i := uint64(0); i < size; i++ {
+			p, _ := l.InclusionProof(i, size)
+			if err := VerifyInclusion(leaves[i], i, size, p.Hashes, root); err != nil {
+				t.Fatalf("leaf %d of %d: %v", i, size, err)
+			}
+			if err := VerifyInclusion(leaves[(i+1)%size], i, size, p.Hashes, root); size > 1 && err == nil {
+				t.Fatalf("leaf %d of %d: expected another leaf's proof to fail", i, size)
+			}
+		}
+		for old := uint64(0); old <= size; old++ {
+			oldRoot := hex.EncodeToString(naiveRoot(leaves[:old]))
+			proof, err := l.ConsistencyProof(old, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyConsistency(old, size, oldRoot, root, proof); err != nil {
+				t.Fatalf("%d to %d: %v", old, size, err)
+			}
+			if old > 0 && old < size {
+				if err := VerifyConsistency(old, size, heads[size-1].Root, root, proof); err == nil {
+					t.Fatalf("%d to %d: expected a wrong old root to fail", old, size)
+				}
+			}
+		}
+	}
+	if _, err := l.InclusionProof(5, 5); err == nil {
+		t.Fatal("expected a leaf outside the tree to be refused")
+	}
+}
+
+func TestTransparencyHex(t *testing.T) {
+	_, priv := GenerateKeypair()
+	l, _ := NewMerkleLog(priv)
+	var heads []TreeHead
+	for n := 0; n < 4; n++ {
+		p, err := l.Append([]byte(fmt.Sprint("leaf ", n)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		heads = append(heads, p.TreeHead)
+	}
+	proof, _ := l.ConsistencyProof(2, 4)
+	upper := make([]string, len(proof))
+	for i, h := range proof {
+		upper[i] = strings.ToUpper(h)
+	}
+	// Hex case is not significant, wherever a hash appears.
+	if err := VerifyConsistency(2, 4, strings.ToUpper(heads[1].Root), heads[3].Root, upper); err != nil {
+		t.Fatalf("expected upper-case hex to verify, got %v", err)
+	}
+	if err := VerifyConsistency(4, 4, strings.ToUpper(heads[3].Root), heads[3].Root, nil); err != nil {
+		t.Fatalf("expected equal heads in different case to verify, got %v", err)
+	}
+	for name, c := range map[string]struct {
+		root1  string
+		hashes []string
+	}{
+		"space in proof":  {heads[1].Root, []string{" " + proof[0][1:]}},
+		"short proof":     {heads[1].Root, []string{proof[0][:62]}},
+		"space in root":   {heads[1].Root[:63] + " ", proof},
+		"same size, junk": {"zz", nil},
+	} {
+		size1 := uint64(2)
+		if c.hashes == nil {
+			size1 = 4
+		}
+		if err := VerifyConsistency(size1, 4, c.root1, heads[3].Root, c.hashes); err == nil {
+			t.Errorf("%s: expected malformed hex to be refused", name)
+		}
+	}
+}
+
+func TestMintTransparency(t *testing.T) {
+	_, issuer := GenerateKeypair()
+	logPub, logPriv := GenerateKeypair()
+	l, _ := NewMerkleLog(logPriv)
+	l.Append([]byte("earlier"))
+	tok, err := Mint(tokenTestPolicy, issuer, MintOptions{Transparency: l})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Inclusion == nil || tok.Inclusion.Index != 1 || l.Size() != 2 {
+		t.Fatalf("expected the token appended as leaf 1, got %+v", tok.Inclusion)
+	}
+	data, _ := json.Marshal(tok)
+	if problems := ValidateTokenJSON(data); problems != nil {
+		t.Fatalf("expected the token to match the schema, got %v", problems)
+	}
+	req := tokenTestReq(50)
+	other, _ := Mint(tokenTestPolicy, issuer, MintOptions{Expires: "2099-01-01T00:00:00Z"})
+	otherLog, _ := GenerateKeypair()
+
+	cases := []struct {
+		name string
+		tok  *Token
+		key  string
+		want string
+	}{
+		{"logged", tok, logPub, ""},
+		{"unlogged", other, logPub, "transparency: no inclusion proof"},
+		{"other log", tok, otherLog, "transparency: invalid tree head signature"},
+		{"borrowed proof", &Token{Version: other.Version, Policy: other.Policy, Expires: other.Expires, PublicKey: other.PublicKey, Signature: other.Signature, Inclusion: tok.Inclusion}, logPub, "transparency: inclusion proof does not match"},
+	}
+	for _, c := range cases {
+		res := VerifyTokenObj(c.tok, req, VerifyTokenOptions{Transparency: &TransparencyOptions{LogKey: c.key}})
+		if c.want == "" && !res.Allow {
+			t.Fatalf("%s: expected allow, got %q", c.name, res.ErrorMessage())
+		}
+		if c.want != "" && !strings.HasPrefix(res.ErrorMessage(), c.want) {
+			t.Fatalf("%s: expected %q, got %q", c.name, c.want, res.ErrorMessage())
+		}
+	}
+	if res := VerifyTokenObj(other, req, VerifyTokenOptions{}); !res.Allow {
+		t.Fatalf("expected transparency to be optional, got %q", res.ErrorMessage())
+	}
+}
@@ -0,0 +1,359 @@
+package spl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TokenVault keeps an agent's tokens and picks the one to present with
+// each request, so agent code asks for "a token for this request" rather
+// than tracking which token covers what. Select considers the tokens for
+// the request's audience that have not expired, keeps those whose policy
+// allows the request, and returns the narrowest, renewing and narrowing it
+// on the way as the hooks below say.
+//
+// A vault opened with OpenTokenVault is kept, like a KeyStore, in a file
+// encrypted under a passphrase; the zero TokenVault holds tokens in memory
+// only. A TokenVault is safe for concurrent use once its fields are set.
+type TokenVault struct {
+	// Vars binds the variables policies use, as far as the agent knows
+	// them, for Select's preflight evaluation.
+	Vars map[string]any
+	// Now returns the current time. Nil means time.Now.
+	Now func() time.Time
+	// Renew, when set, exchanges a token that expires within RenewBefore
+	// for a fresh one, such as by asking its issuer. Select renews the
+	// tokens it considers, replaces them in the vault and, for a vault
+	// with a file, saves it.
+	Renew func(ctx context.Context, t *Token) (*Token, error)
+	// RenewBefore is how long before expiry Renew is called. Zero means
+	// DefaultRenewBefore.
+	RenewBefore time.Duration
+	// Narrow, when set, returns a constraint Select adds to the token it
+	// picks, so what the agent presents allows little beyond req, such as
+	// (= (get req "recipient") "bob"). Only HMAC tokens are narrowed, with
+	// AddCaveat: narrowing any other token means re-signing it with the
+	// issuer's key, which the agent does not hold. An empty constraint
+	// leaves the token alone.
+	Narrow func(req map[string]any) string
+
+	mu         sync.Mutex
+	path       string
+	passphrase []byte
+	entries    []VaultEntry
+}
+
+// VaultEntry is a token in a TokenVault.
+type VaultEntry struct {
+	Name string `json:"name"`
+	// Audience is the service the token is for, such as
+	// "payments.example.com". A token without one is offered to every
+	// audience.
+	Audience string `json:"audience,omitempty"`
+	Token    *Token `json:"token"`
+	// HolderKey is the hex Ed25519 private key of the token's pop_key,
+	// which DeriveSession signs session grants with. It is kept encrypted
+	// with the token.
+	HolderKey string    `json:"holder_key,omitempty"`
+	Added     time.Time `json:"added"`
+}
+
+// DefaultRenewBefore is how long before a token expires a TokenVault
+// renews it, when TokenVault.RenewBefore is zero.
+const DefaultRenewBefore = 5 * time.Minute
+
+var (
+	// ErrTokenVaultPassphrase is returned when a token vault cannot be
+	// decrypted.
+	ErrTokenVaultPassphrase = errors.New("token vault: wrong passphrase or corrupted file")
+	// ErrNoToken is returned by Select when no token in the vault allows
+	// the request.
+	ErrNoToken = errors.New("token vault: no token allows the request")
+)
+
+var tokenVaultFileKind = sealedKind{"tokenvault", "token vault", ErrTokenVaultPassphrase}
+
+// OpenTokenVault decrypts the token vault at path. A missing file yields
+// an empty vault that Save creates.
+func OpenTokenVault(path string, passphrase []byte) (*TokenVault, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("token vault: empty passphrase")
+	}
+	v := &TokenVault{path: path, passphrase: append([]byte(nil), passphrase...)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plain, err := openSealedFile(data, passphrase, tokenVaultFileKind)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(plain, &v.entries); err != nil {
+		return nil, fmt.Errorf("token vault: %w", err)
+	}
+	return v, nil
+}
+
+// Save encrypts the vault under a fresh salt and nonce and atomically
+// replaces its file. It fails for a vault not opened with OpenTokenVault.
+func (v *TokenVault) Save() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.save()
+}
+
+func (v *TokenVault) save() error {
+	if v.path == "" {
+		return errors.New("token vault: no file to save to")
+	}
+	plain, err := json.Marshal(v.entries)
+	if err != nil {
+		return err
+	}
+	return writeSealedFile(v.path, v.passphrase, tokenVaultFileKind, plain)
+}
+
+// Add adds e, replacing any entry of the same name. Added defaults to now.
+// Call Save to persist the change.
+func (v *TokenVault) Add(e VaultEntry) error {
+	if e.Name == "" {
+		return errors.New("token vault: name required")
+	}
+	if e.Token == nil {
+		return fmt.Errorf("token vault: %q has no token", e.Name)
+	}
+	if e.Token.Expires != "" {
+		if _, err := time.Parse(time.RFC3339, e.Token.Expires); err != nil {
+			return fmt.Errorf("token vault: %q: invalid expiry: %w", e.Name, err)
+		}
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if e.Added.IsZero() {
+		e.Added = v.now().UTC().Truncate(time.Second)
+	}
+	for i := range v.entries {
+		if v.entries[i].Name == e.Name {
+			v.entries[i] = e
+			return nil
+		}
+	}
+	v.entries = append(v.entries, e)
+	return nil
+}
+
+// Remove removes the entry called name, reporting whether there was one.
+func (v *TokenVault) Remove(name string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i := range v.entries {
+		if v.entries[i].Name == name {
+			v.entries = append(v.entries[:i], v.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Entries returns the entries sorted by name.
+func (v *TokenVault) Entries() []VaultEntry {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	out := append([]VaultEntry(nil), v.entries...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Prune removes the tokens that have expired, returning how many.
+func (v *TokenVault) Prune() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	kept := v.entries[:0]
+	for _, e := range v.entries {
+		if exp, ok := expiry(e.Token); !ok || exp.After(now) {
+			kept = append(kept, e)
+		}
+	}
+	n := len(v.entries) - len(kept)
+	clear(v.entries[len(kept):])
+	v.entries = kept
+	return n
+}
+
+// Select returns the token to present to audience with req: of the
+// unexpired tokens for audience whose policies and caveats allow req, the
+// narrowest, narrowed further by Narrow. One token is narrower than another
+// when Subsumes proves its policy for req implies the other's; between
+// tokens neither proves narrower, the one expiring first wins. Tokens
+// within RenewBefore of expiry are renewed first when Renew is set.
+//
+// The evaluation is a preflight: it binds Vars, counts no prior use, and
+// takes the crypto predicates, which need proofs only the verifier checks,
+// to hold. The verifier still decides. Select returns ErrNoToken when no
+// token allows req.
+func (v *TokenVault) Select(ctx context.Context, audience string, req map[string]any) (*Token, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.renew(ctx, audience); err != nil {
+		return nil, err
+	}
+	now := v.now()
+	type candidate struct {
+		entry  VaultEntry
+		policy Node
+		exp    time.Time
+		expOK  bool
+	}
+	var cands []candidate
+	for _, e := range v.entries {
+		if e.Audience != "" && e.Audience != audience {
+			continue
+		}
+		exp, ok := expiry(e.Token)
+		if ok && !exp.After(now) {
+			continue
+		}
+		ast, err := tokenPolicy(e.Token, e.Token.PolicyFor(req), DefaultPolicyCache, DefaultLimits)
+		if err != nil || !v.preflight(ast, req, now) {
+			continue
+		}
+		cands = append(cands, candidate{e, ast, exp, ok})
+	}
+	if len(cands) == 0 {
+		return nil, ErrNoToken
+	}
+	sort.SliceStable(cands, func(i, j int) bool {
+		a, b := cands[i], cands[j]
+		if a.expOK != b.expOK {
+			return a.expOK
+		}
+		return a.exp.Before(b.exp)
+	})
+	best := cands[0]
+	for _, c := range cands[1:] {
+		if Subsumes(best.policy, c.policy, v.Vars) && !Subsumes(c.policy, best.policy, v.Vars) {
+			best = c
+		}
+	}
+	t := best.entry.Token
+	if v.Narrow == nil || t.Sealed || t.Alg != AlgHS256 {
+		return t, nil
+	}
+	constraint := v.Narrow(req)
+	if constraint == "" {
+		return t, nil
+	}
+	narrowed, err := AddCaveat(t, constraint)
+	if err != nil {
+		return nil, fmt.Errorf("token vault: narrowing %q: %w", best.entry.Name, err)
+	}
+	return narrowed, nil
+}
+
+// DeriveSession delegates the entry called name to a session, as
+// DeriveSessionToken does, with the entry's HolderKey, so the holder key
+// stays in the vault. opts.Now defaults to the vault's.
+func (v *TokenVault) DeriveSession(name, sessionID string, ttl time.Duration, opts SessionOptions) (*SessionToken, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, e := range v.entries {
+		if e.Name != name {
+			continue
+		}
+		if e.HolderKey == "" {
+			return nil, fmt.Errorf("token vault: %q has no holder key", name)
+		}
+		if opts.Now == nil {
+			opts.Now = v.now
+		}
+		s, err := DeriveSessionToken(e.Token, e.HolderKey, sessionID, ttl, opts)
+		if err != nil {
+			return nil, fmt.Errorf("token vault: %q: %w", name, err)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("token vault: no entry %q", name)
+}
+
+// renew renews the tokens for audience that expire within RenewBefore,
+// saving the vault if any changed and it has a file.
+func (v *TokenVault) renew(ctx context.Context, audience string) error {
+	if v.Renew == nil {
+		return nil
+	}
+	before := v.RenewBefore
+	if before == 0 {
+		before = DefaultRenewBefore
+	}
+	deadline := v.now().Add(before)
+	renewed := false
+	for i, e := range v.entries {
+		if e.Audience != "" && e.Audience != audience {
+			continue
+		}
+		if exp, ok := expiry(e.Token); !ok || exp.After(deadline) {
+			continue
+		}
+		t, err := v.Renew(ctx, e.Token)
+		if err != nil {
+			return fmt.Errorf("token vault: renewing %q: %w", e.Name, err)
+		}
+		v.entries[i].Token = t
+		renewed = true
+	}
+	if renewed && v.path != "" {
+		return v.save()
+	}
+	return nil
+}
+
+// preflight evaluates a token's policy for req as Select describes.
+func (v *TokenVault) preflight(ast Node, req map[string]any, now time.Time) bool {
+	yes := func() bool { return true }
+	env := Env{
+		Req:         req,
+		Vars:        v.Vars,
+		Clock:       func() time.Time { return now },
+		PerDayCount: func(string, string) int { return 0 },
+		WindowCount: func(string, time.Duration) int { return 0 },
+		BucketOk:    func(string, Bucket) bool { return true },
+		SumAmount:   func(_, _, _ string, pending Money) float64 { return pending.Amount },
+		Crypto: CryptoCallbacks{
+			DPoPOk:       yes,
+			MerkleOk:     func([]any) bool { return true },
+			VRFOk:        func(string, float64) bool { return true },
+			ThreshOk:     yes,
+			AttestedOk:   yes,
+			ApprovalOk:   func(string) bool { return true },
+			MerkleMember: func(string, []MerkleProofStep) bool { return true },
+		},
+	}
+	allow, err := Verify(ast, env)
+	return err == nil && allow
+}
+
+func (v *TokenVault) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// expiry returns when t expires, or false if it does not.
+func expiry(t *Token) (time.Time, bool) {
+	if t.Expires == "" {
+		return time.Time{}, false
+	}
+	exp, err := time.Parse(time.RFC3339, t.Expires)
+	return exp, err == nil
+}
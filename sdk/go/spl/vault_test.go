@@ -0,0 +1,173 @@
+package spl
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenVaultSelect(t *testing.T) {
+	_, priv := GenerateKeypair()
+	mint := func(policy, expires string) *Token {
+		t.Helper()
+		tok, err := Mint(policy, priv, MintOptions{Expires: expires})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	broad := mint(`(<= (get req "amount") 500)`, "2027-06-01T00:00:00Z")
+	narrow := mint(`(and (<= (get req "amount") 500) (= (get req "recipient") "bob"))`, "2027-12-01T00:00:00Z")
+	other := mint(`#t`, "2027-06-01T00:00:00Z")
+	expired := mint(`#t`, "2026-01-01T00:00:00Z")
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	v := &TokenVault{Now: func() time.Time { return now }}
+	for _, e := range []VaultEntry{
+		{Name: "broad", Audience: "pay", Token: broad},
+		{Name: "narrow", Audience: "pay", Token: narrow},
+		{Name: "other", Audience: "mail", Token: other},
+		{Name: "expired", Token: expired},
+	} {
+		if err := v.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	for _, c := range []struct {
+		audience string
+		req      map[string]any
+		want     *Token
+	}{
+		// The narrower token wins even though it expires later.
+		{"pay", map[string]any{"amount": 50.0, "recipient": "bob"}, narrow},
+		{"pay", map[string]any{"amount": 50.0, "recipient": "carol"}, broad},
+		{"mail", map[string]any{"amount": 50.0}, other},
+		{"pay", map[string]any{"amount": 900.0}, nil},
+	} {
+		got, err := v.Select(ctx, c.audience, c.req)
+		if c.want == nil {
+			if !errors.Is(err, ErrNoToken) {
+				t.Errorf("%s %v: expected ErrNoToken, got %v", c.audience, c.req, err)
+			}
+			continue
+		}
+		if err != nil || got.Signature != c.want.Signature {
+			t.Errorf("%s %v: got %v, %v", c.audience, c.req, got, err)
+		}
+	}
+	if n := v.Prune(); n != 1 || len(v.Entries()) != 3 {
+		t.Fatalf("expected the expired token pruned, got %d, %d left", n, len(v.Entries()))
+	}
+	if !v.Remove("narrow") || v.Remove("narrow") {
+		t.Fatal("expected Remove to report the entry once")
+	}
+}
+
+func TestTokenVaultRenewAndNarrow(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	old, err := MintHMAC(`(<= (get req "amount") 100)`, secret, MintOptions{Expires: now.Add(time.Minute).Format(time.RFC3339)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewals := 0
+	v := &TokenVault{
+		Now: func() time.Time { return now },
+		Renew: func(_ context.Context, t *Token) (*Token, error) {
+			renewals++
+			return MintHMAC(t.Policy, secret, MintOptions{Expires: now.Add(time.Hour).Format(time.RFC3339)})
+		},
+		Narrow: func(req map[string]any) string { return `(= (get req "amount") 40)` },
+	}
+	v.Add(VaultEntry{Name: "pay", Token: old})
+	tok, err := v.Select(context.Background(), "pay", map[string]any{"amount": 40.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewals != 1 || v.Entries()[0].Token.Expires != now.Add(time.Hour).Format(time.RFC3339) {
+		t.Fatalf("expected the token renewed in the vault, got %d renewals", renewals)
+	}
+	if len(tok.Caveats) != 1 {
+		t.Fatalf("expected the presented token narrowed, got caveats %v", tok.Caveats)
+	}
+	opts := VerifyTokenOptions{HMACSecret: secret, Clock: func() time.Time { return now }}
+	if r := VerifyTokenObj(tok, map[string]any{"amount": 40.0}, opts); !r.Allow {
+		t.Fatalf("expected the narrowed token to verify, got %q", r.ErrorMessage())
+	}
+	if r := VerifyTokenObj(tok, map[string]any{"amount": 90.0}, opts); r.Allow {
+		t.Fatal("expected the narrowed token to deny other amounts")
+	}
+	if _, err := v.Select(context.Background(), "pay", map[string]any{"amount": 40.0}); err != nil || renewals != 1 {
+		t.Fatalf("expected a fresh token not to be renewed again, got %d renewals, %v", renewals, err)
+	}
+
+	// Narrowing a signed token needs the issuer's key, so it is presented
+	// as issued.
+	_, priv := GenerateKeypair()
+	signed, _ := Mint(`(<= (get req "amount") 100)`, priv, MintOptions{})
+	v = &TokenVault{Narrow: v.Narrow}
+	v.Add(VaultEntry{Name: "signed", Token: signed})
+	if tok, err := v.Select(context.Background(), "pay", map[string]any{"amount": 40.0}); err != nil || tok != signed {
+		t.Fatalf("expected the signed token as issued, got %+v, %v", tok, err)
+	}
+}
+
+func TestTokenVaultFile(t *testing.T) {
+	defer func(n int) { keyStoreIterations = n }(keyStoreIterations)
+	keyStoreIterations = 1000
+	path := filepath.Join(t.TempDir(), "vault.json")
+	v, err := OpenTokenVault(path, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, priv := GenerateKeypair()
+	tok, _ := Mint(`#t`, priv, MintOptions{})
+	if err := v.Add(VaultEntry{Name: "a", Audience: "svc", Token: tok}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := OpenTokenVault(path, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if es := again.Entries(); len(es) != 1 || es[0].Token.Signature != tok.Signature || es[0].Audience != "svc" {
+		t.Fatalf("unexpected entries %+v", es)
+	}
+	if _, err := OpenTokenVault(path, []byte("wrong")); !errors.Is(err, ErrTokenVaultPassphrase) {
+		t.Fatalf("expected ErrTokenVaultPassphrase, got %v", err)
+	}
+	// A token vault is not a key store.
+	if _, err := OpenKeyStore(path, []byte("pass")); !errors.Is(err, ErrKeyStorePassphrase) {
+		t.Fatalf("expected the key store to refuse a vault file, got %v", err)
+	}
+	if err := (&TokenVault{}).Save(); err == nil {
+		t.Fatal("expected an in-memory vault to refuse to save")
+	}
+}
+
+func TestTokenVaultDeriveSession(t *testing.T) {
+	_, priv := GenerateKeypair()
+	holderPub, holderPriv := GenerateKeypair()
+	tok, _ := Mint(`#t`, priv, MintOptions{PoPKey: holderPub})
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	v := &TokenVault{Now: func() time.Time { return now }}
+	v.Add(VaultEntry{Name: "main", Token: tok, HolderKey: holderPriv})
+	v.Add(VaultEntry{Name: "keyless", Token: tok})
+	s, err := v.DeriveSession("main", "task-1", time.Minute, SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Grant.Expires != "2026-06-01T00:01:00Z" || s.Token != tok {
+		t.Fatalf("expected a grant of the vault's token, got %+v", s.Grant)
+	}
+	if _, err := v.DeriveSession("keyless", "task-1", 0, SessionOptions{}); err == nil {
+		t.Fatal("expected an entry without a holder key to fail")
+	}
+	if _, err := v.DeriveSession("missing", "task-1", 0, SessionOptions{}); err == nil {
+		t.Fatal("expected a missing entry to fail")
+	}
+}
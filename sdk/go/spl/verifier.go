@@ -0,0 +1,231 @@
+package spl
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Verifier is a long-lived token verifier. It is built once from a
+// VerifierConfig holding the trust anchors, caches, stores, limits and
+// crypto callbacks, rather than assembling VerifyTokenOptions on every
+// call, and it keeps decision counts for metrics. It reuses the
+// per-request state of one verification in the next: the vars map handed
+// to the policy and the record of counters it read, so at high request
+// rates it allocates little beyond what the policy itself needs. It is
+// safe for concurrent use.
+type Verifier struct {
+	opts       VerifyTokenOptions
+	onDecision func(ctx context.Context, res VerifyTokenResult)
+	onRecord   func(ctx context.Context, d Decision)
+	scratch    sync.Pool // *verifyScratch
+
+	requests, allowed, denied, errors atomic.Uint64
+}
+
+// VerifierConfig configures a Verifier. The embedded options apply to
+// every token; Now is best left empty so that each request is verified at
+// the time it arrives, by Clock or, when that is nil too, time.Now. A now
+// in Vars is left to the host. PresentationSignature is given per request
+// to VerifyPresented. A nil PolicyCache uses DefaultPolicyCache.
+type VerifierConfig struct {
+	VerifyTokenOptions
+	// OnDecision, when set, is called with every result, for logging and
+	// metrics.
+	OnDecision func(ctx context.Context, res VerifyTokenResult)
+	// OnRecord, when set, is called with the Decision of every
+	// verification, for audit logs, receipts and webhooks.
+	OnRecord func(ctx context.Context, d Decision)
+	// PolicyStatsLimit is how many policies VerifierStats.Policies covers;
+	// 0 means DefaultPolicyStatsLimit and a negative limit keeps none.
+	// Policies first evaluated after the limit is reached are not counted.
+	PolicyStatsLimit int
+}
+
+// VerifierStats counts a Verifier's decisions. Errors counts denials with
+// an error; Denied counts the others.
+type VerifierStats struct {
+	Requests, Allowed, Denied, Errors uint64
+	PolicyCache                       PolicyCacheStats
+	// Policies holds the gas and latency of each policy evaluated, highest
+	// p99 latency first.
+	Policies []PolicyStats
+}
+
+// NewVerifier returns a verifier for config. Vars and the stores in config
+// are shared by all verifications and must not be modified while the
+// verifier is in use.
+func NewVerifier(config VerifierConfig) *Verifier {
+	v := &Verifier{opts: config.VerifyTokenOptions, onDecision: config.OnDecision, onRecord: config.OnRecord}
+	if v.opts.PolicyCache == nil {
+		v.opts.PolicyCache = DefaultPolicyCache
+	}
+	if _, ok := v.opts.Vars["now"]; !ok && v.opts.Now == "" && v.opts.Clock == nil {
+		v.opts.Clock = time.Now
+	}
+	n := len(v.opts.Vars) + 1
+	v.scratch.New = func() any { return &verifyScratch{vars: make(map[string]any, n)} }
+	v.opts.scratch = &v.scratch
+	v.opts.policies = newPolicyTable(config.PolicyStatsLimit)
+	return v
+}
+
+// Verify verifies a token, JSON or compact, and evaluates its policy
+// against req. A token bound to a pop_key needs VerifyPresented. When ctx is
+// already done the token is denied with its error.
+func (v *Verifier) Verify(ctx context.Context, token string, req map[string]any) VerifyTokenResult {
+	t, err := ParseToken(token)
+	res, _ := v.run(ctx, t, err, req, "", false)
+	return res
+}
+
+// VerifyPresented verifies a decoded token presented with the signature
+// proving possession of its pop_key for this request, if it has one.
+func (v *Verifier) VerifyPresented(ctx context.Context, t *Token, req map[string]any, presentationSignature string) VerifyTokenResult {
+	res, _ := v.run(ctx, t, nil, req, presentationSignature, false)
+	return res
+}
+
+// Decide is Verify returning the decision record of the verification.
+func (v *Verifier) Decide(ctx context.Context, token string, req map[string]any) Decision {
+	t, err := ParseToken(token)
+	_, d := v.run(ctx, t, err, req, "", true)
+	return *d
+}
+
+// DecidePresented is VerifyPresented returning the decision record of the
+// verification.
+func (v *Verifier) DecidePresented(ctx context.Context, t *Token, req map[string]any, presentationSignature string) Decision {
+	_, d := v.run(ctx, t, nil, req, presentationSignature, true)
+	return *d
+}
+
+// VerifyToken is VerifyToken under the verifier's options.
+func (v *Verifier) VerifyToken(tokenJSON string, req map[string]any) VerifyTokenResult {
+	var t Token
+	if err := json.Unmarshal([]byte(tokenJSON), &t); err != nil {
+		res, _ := v.run(context.Background(), nil, kindf(ErrMalformedToken, "invalid token JSON: %w", err), req, "", false)
+		return res
+	}
+	return v.VerifyTokenObj(&t, req)
+}
+
+// VerifyTokenObj is VerifyTokenObj under the verifier's options.
+func (v *Verifier) VerifyTokenObj(t *Token, req map[string]any) VerifyTokenResult {
+	res, _ := v.run(context.Background(), t, nil, req, v.opts.PresentationSignature, false)
+	return res
+}
+
+// run verifies t, or fails with decodeErr when the token did not decode,
+// and records the result. With decide, or OnRecord set, it also returns
+// the decision record.
+func (v *Verifier) run(ctx context.Context, t *Token, decodeErr error, req map[string]any, presentationSignature string, decide bool) (VerifyTokenResult, *Decision) {
+	var d *Decision
+	if decide || v.onRecord != nil {
+		d = &Decision{}
+	}
+	var res VerifyTokenResult
+	if decodeErr == nil {
+		decodeErr = ctx.Err()
+	}
+	if decodeErr != nil {
+		res = failed(t, decodeErr)
+	} else {
+		opts := v.opts
+		opts.PresentationSignature = presentationSignature
+		opts.decision = d
+		res = VerifyTokenObj(t, req, opts)
+	}
+	v.requests.Add(1)
+	switch {
+	case res.Allow:
+		v.allowed.Add(1)
+	case res.Err != nil:
+		v.errors.Add(1)
+	default:
+		v.denied.Add(1)
+	}
+	if v.onDecision != nil {
+		v.onDecision(ctx, res)
+	}
+	if d != nil {
+		*d = newDecision(t, req, res, d)
+		if v.onRecord != nil {
+			v.onRecord(ctx, *d)
+		}
+	}
+	return res, d
+}
+
+// Stats returns the verifier's decision counts, the statistics of its
+// policy cache and the gas and latency of the policies it evaluated.
+func (v *Verifier) Stats() VerifierStats {
+	return VerifierStats{
+		Requests:    v.requests.Load(),
+		Allowed:     v.allowed.Load(),
+		Denied:      v.denied.Load(),
+		Errors:      v.errors.Load(),
+		PolicyCache: v.opts.PolicyCache.Stats(),
+		Policies:    v.opts.policies.stats(),
+	}
+}
+
+// verifyScratch is the state of one verification that a Verifier reuses.
+type verifyScratch struct {
+	vars     map[string]any
+	counters counterUse
+	memo     map[*Node]any
+	now      string
+	nowValue any // now boxed once rather than per request
+}
+
+// getScratch returns scratch state from p, or fresh state when p is nil.
+func getScratch(p *sync.Pool) *verifyScratch {
+	if p == nil {
+		return &verifyScratch{}
+	}
+	return p.Get().(*verifyScratch)
+}
+
+// putScratch empties s and returns it to p.
+func putScratch(p *sync.Pool, s *verifyScratch) {
+	if p == nil {
+		return
+	}
+	clear(s.vars)
+	clear(s.memo)
+	u := &s.counters
+	clear(u.limits)
+	clear(u.spends)
+	clear(u.order)
+	clear(u.buckets)
+	*u = counterUse{limits: u.limits, spends: u.spends, order: u.order[:0], buckets: u.buckets[:0]}
+	p.Put(s)
+}
+
+// varsFor fills s's vars from opts, with now set from opts.Now.
+func (s *verifyScratch) varsFor(opts *VerifyTokenOptions) map[string]any {
+	if s.vars == nil {
+		s.vars = make(map[string]any, len(opts.Vars)+1)
+	}
+	for k, v := range opts.Vars {
+		s.vars[k] = v
+	}
+	if opts.Now != "" {
+		if s.nowValue == nil || s.now != opts.Now {
+			s.now, s.nowValue = opts.Now, opts.Now
+		}
+		s.vars["now"] = s.nowValue
+	}
+	return s.vars
+}
+
+// memoFor returns s's empty memo table for VerifyTokenOptions.Memoize.
+func (s *verifyScratch) memoFor() map[*Node]any {
+	if s.memo == nil {
+		s.memo = map[*Node]any{}
+	}
+	return s.memo
+}
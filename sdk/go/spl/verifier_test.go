@@ -0,0 +1,151 @@
+package spl
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVerifierMatchesVerifyToken(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	js := mustJSON(t, tok)
+	v := NewVerifier(VerifierConfig{VerifyTokenOptions: VerifyTokenOptions{Now: "2026-01-01T00:00:00Z"}})
+	for _, amount := range []float64{50, 500, 50} {
+		want := VerifyToken(js, tokenTestReq(amount), VerifyTokenOptions{Now: "2026-01-01T00:00:00Z"})
+		if got := v.VerifyToken(js, tokenTestReq(amount)); got != want {
+			t.Fatalf("amount %v: expected %+v, got %+v", amount, want, got)
+		}
+		if got := v.VerifyTokenObj(tok, tokenTestReq(amount)); got != want {
+			t.Fatalf("amount %v: expected %+v, got %+v", amount, want, got)
+		}
+	}
+}
+
+func TestVerifierVerify(t *testing.T) {
+	_, priv := GenerateKeypair()
+	popPub, popPriv := GenerateKeypair()
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bound, err := Mint(tokenTestPolicy, priv, MintOptions{PoPKey: popPub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := tok.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decisions []VerifyTokenResult
+	v := NewVerifier(VerifierConfig{
+		VerifyTokenOptions: VerifyTokenOptions{PolicyCache: NewPolicyCache(8)},
+		OnDecision:         func(_ context.Context, res VerifyTokenResult) { decisions = append(decisions, res) },
+	})
+	ctx := context.Background()
+	if r := v.Verify(ctx, compact, tokenTestReq(50)); !r.Allow {
+		t.Fatalf("expected a compact token to allow, got %q", r.ErrorMessage())
+	}
+	if r := v.Verify(ctx, mustJSON(t, tok), tokenTestReq(500)); r.Allow || r.Err != nil {
+		t.Fatalf("expected a plain deny, got %+v", r)
+	}
+	if r := v.Verify(ctx, mustJSON(t, bound), tokenTestReq(50)); r.ErrorMessage() != "PoP binding requires presentation signature" {
+		t.Fatalf("expected a PoP error, got %+v", r)
+	}
+	sig, err := CreatePresentationSignature(bound, popPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := v.VerifyPresented(ctx, bound, tokenTestReq(50), sig); !r.Allow {
+		t.Fatalf("expected a presented token to allow, got %q", r.ErrorMessage())
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if r := v.Verify(canceled, compact, tokenTestReq(50)); r.Allow || r.ErrorMessage() != context.Canceled.Error() {
+		t.Fatalf("expected a canceled context to deny, got %+v", r)
+	}
+	got := v.Stats()
+	if got.Requests != 5 || got.Allowed != 2 || got.Denied != 1 || got.Errors != 2 || len(decisions) != 5 {
+		t.Fatalf("expected 5 requests, 2 allowed, 1 denied, 2 errors, got %+v and %d decisions", got, len(decisions))
+	}
+	if got.PolicyCache.Hits == 0 {
+		t.Fatalf("expected the verifier's policy cache to be used, got %+v", got.PolicyCache)
+	}
+}
+
+func TestVerifierClock(t *testing.T) {
+	_, priv := GenerateKeypair()
+	popPub, popPriv := GenerateKeypair()
+	tok, _ := Mint(`(before now "2099-01-01T00:00:00Z")`, priv, MintOptions{PoPKey: popPub})
+	sig, _ := CreatePresentationSignature(tok, popPriv)
+	ctx := context.Background()
+	// Without Now or Clock, each request is verified at the time it arrives.
+	if r := NewVerifier(VerifierConfig{}).VerifyPresented(ctx, tok, map[string]any{}, sig); !r.Allow {
+		t.Fatalf("expected now bound to the current time, got %q", r.ErrorMessage())
+	}
+	late := VerifierConfig{VerifyTokenOptions: VerifyTokenOptions{Clock: func() time.Time { return time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC) }}}
+	if r := NewVerifier(late).VerifyPresented(ctx, tok, map[string]any{}, sig); r.Allow {
+		t.Fatal("expected the configured clock to be used")
+	}
+}
+
+func TestVerifierResetsCounters(t *testing.T) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(`(or
+  (and (= (get req "action") "a") (< (per-day-count "a" "2026-01-31") 5))
+  (and (= (get req "action") "b") (< (per-day-count "b" "2026-01-31") 5)))`, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &MemoryCounters{}
+	v := NewVerifier(VerifierConfig{VerifyTokenOptions: VerifyTokenOptions{Counters: store}})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(action string) {
+			defer wg.Done()
+			if r := v.VerifyTokenObj(tok, map[string]any{"action": action}); !r.Allow {
+				t.Errorf("%s: expected allow, got %q", action, r.ErrorMessage())
+			}
+		}([]string{"a", "b"}[i%2])
+	}
+	wg.Wait()
+	// A counter read by one request must not be taken again by the next
+	// request that reuses its state.
+	for _, action := range []string{"a", "b"} {
+		if n, _ := store.Count(action, "2026-01-31"); n != 2 {
+			t.Fatalf("%s: expected a count of 2, got %d", action, n)
+		}
+	}
+}
+
+func BenchmarkVerifier(b *testing.B) {
+	_, priv := GenerateKeypair()
+	tok, err := Mint(benchPolicy, priv, MintOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	env := benchEnv()
+	opts := VerifyTokenOptions{Vars: env.Vars, PerDayCount: env.PerDayCount, Crypto: env.Crypto, Counters: &MemoryCounters{}}
+	b.Run("VerifyTokenObj", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if r := VerifyTokenObj(tok, env.Req, opts); r.Err != nil {
+				b.Fatal(r.ErrorMessage())
+			}
+		}
+	})
+	v := NewVerifier(VerifierConfig{VerifyTokenOptions: opts})
+	b.Run("Verifier", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if r := v.VerifyTokenObj(tok, env.Req); r.Err != nil {
+				b.Fatal(r.ErrorMessage())
+			}
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package spl
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// WebAuthnAssertion is a passkey assertion as returned by
+// navigator.credentials.get(), with binary fields base64url-encoded.
+type WebAuthnAssertion struct {
+	AuthenticatorData string `json:"authenticatorData"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	Signature         string `json:"signature"`
+}
+
+// WebAuthnOptions are the relying-party expectations for an assertion.
+type WebAuthnOptions struct {
+	Challenge []byte
+	Origin    string
+	RPID      string
+	// PublicKey is the credential key registered for the device, hex-encoded
+	// as for tokens: a 65-byte SEC1 point for ES256 or 32 bytes for Ed25519.
+	PublicKey               string
+	RequireUserVerification bool
+}
+
+// WebAuthnCheck pairs an assertion with the expectations it must meet.
+type WebAuthnCheck struct {
+	Assertion WebAuthnAssertion
+	WebAuthnOptions
+}
+
+// Authenticator data flags (WebAuthn §6.1).
+const (
+	webauthnFlagUP = 0x01
+	webauthnFlagUV = 0x04
+)
+
+// VerifyWebAuthnAssertion checks that a passkey assertion answers the
+// expected challenge for the expected origin and relying party and is signed
+// by the registered credential key over authenticatorData || SHA-256(clientDataJSON).
+func VerifyWebAuthnAssertion(a *WebAuthnAssertion, o WebAuthnOptions) error {
+	authData, err := b64url.DecodeString(a.AuthenticatorData)
+	if err != nil {
+		return fmt.Errorf("invalid authenticatorData: %w", err)
+	}
+	clientDataJSON, err := b64url.DecodeString(a.ClientDataJSON)
+	if err != nil {
+		return fmt.Errorf("invalid clientDataJSON: %w", err)
+	}
+	sig, err := b64url.DecodeString(a.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	var cd struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil {
+		return fmt.Errorf("invalid clientDataJSON: %w", err)
+	}
+	if cd.Type != "webauthn.get" {
+		return fmt.Errorf("unexpected client data type %q", cd.Type)
+	}
+	challenge, err := b64url.DecodeString(cd.Challenge)
+	if err != nil || len(o.Challenge) == 0 || subtle.ConstantTimeCompare(challenge, o.Challenge) != 1 {
+		return errors.New("challenge mismatch")
+	}
+	if cd.Origin != o.Origin {
+		return fmt.Errorf("origin mismatch: %q", cd.Origin)
+	}
+
+	if len(authData) < 37 {
+		return errors.New("authenticatorData too short")
+	}
+	rpIDHash := sha256.Sum256([]byte(o.RPID))
+	if subtle.ConstantTimeCompare(authData[:32], rpIDHash[:]) != 1 {
+		return errors.New("rpId mismatch")
+	}
+	flags := authData[32]
+	if flags&webauthnFlagUP == 0 {
+		return errors.New("user presence not asserted")
+	}
+	if o.RequireUserVerification && flags&webauthnFlagUV == 0 {
+		return errors.New("user verification required")
+	}
+
+	clientHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientHash[:]...)
+	pub, err := hex.DecodeString(o.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid credential public key: %w", err)
+	}
+	switch len(pub) {
+	case ed25519.PublicKeySize:
+		if !ed25519.Verify(ed25519.PublicKey(pub), signed, sig) {
+			return errors.New("invalid assertion signature")
+		}
+	default:
+		k, err := p256PublicKey(pub)
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(signed)
+		// WebAuthn ES256 signatures are ASN.1 DER, unlike token signatures.
+		if !ecdsa.VerifyASN1(k, h[:], sig) {
+			return errors.New("invalid assertion signature")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package spl
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+// fakeAuthenticator signs WebAuthn assertions with a P-256 credential key.
+type fakeAuthenticator struct {
+	priv   *ecdsa.PrivateKey
+	pubHex string
+}
+
+func newFakeAuthenticator(t *testing.T) *fakeAuthenticator {
+	t.Helper()
+	_, privHex, _ := GenerateKeypairAlg(AlgES256)
+	priv, err := p256PrivateKey(privHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := priv.PublicKey.ECDH()
+	return &fakeAuthenticator{priv: priv, pubHex: hex.EncodeToString(pub.Bytes())}
+}
+
+func (f *fakeAuthenticator) assert(t *testing.T, challenge []byte, origin, rpID string, flags byte) WebAuthnAssertion {
+	t.Helper()
+	cd, _ := json.Marshal(map[string]any{
+		"type":      "webauthn.get",
+		"challenge": b64url.EncodeToString(challenge),
+		"origin":    origin,
+	})
+	rpHash := sha256.Sum256([]byte(rpID))
+	authData := append(rpHash[:], flags, 0, 0, 0, 1)
+	cdHash := sha256.Sum256(cd)
+	h := sha256.Sum256(append(append([]byte{}, authData...), cdHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, f.priv, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return WebAuthnAssertion{
+		AuthenticatorData: b64url.EncodeToString(authData),
+		ClientDataJSON:    b64url.EncodeToString(cd),
+		Signature:         b64url.EncodeToString(sig),
+	}
+}
+
+func TestVerifyWebAuthnAssertion(t *testing.T) {
+	dev := newFakeAuthenticator(t)
+	challenge := []byte("server-challenge-123")
+	opts := WebAuthnOptions{Challenge: challenge, Origin: "https://app.example.com", RPID: "example.com", PublicKey: dev.pubHex}
+
+	a := dev.assert(t, challenge, "https://app.example.com", "example.com", webauthnFlagUP|webauthnFlagUV)
+	if err := VerifyWebAuthnAssertion(&a, opts); err != nil {
+		t.Fatalf("expected valid assertion: %v", err)
+	}
+
+	cases := map[string]WebAuthnAssertion{
+		"wrong challenge": dev.assert(t, []byte("other"), "https://app.example.com", "example.com", webauthnFlagUP),
+		"wrong origin":    dev.assert(t, challenge, "https://evil.example.net", "example.com", webauthnFlagUP),
+		"wrong rp":        dev.assert(t, challenge, "https://app.example.com", "evil.net", webauthnFlagUP),
+		"no presence":     dev.assert(t, challenge, "https://app.example.com", "example.com", 0),
+	}
+	for name, bad := range cases {
+		if err := VerifyWebAuthnAssertion(&bad, opts); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+
+	other := newFakeAuthenticator(t)
+	forged := other.assert(t, challenge, "https://app.example.com", "example.com", webauthnFlagUP)
+	if err := VerifyWebAuthnAssertion(&forged, opts); err == nil {
+		t.Fatal("expected error for assertion from unregistered credential")
+	}
+
+	uvOpts := opts
+	uvOpts.RequireUserVerification = true
+	upOnly := dev.assert(t, challenge, "https://app.example.com", "example.com", webauthnFlagUP)
+	if err := VerifyWebAuthnAssertion(&upOnly, uvOpts); err == nil {
+		t.Fatal("expected error when user verification is required")
+	}
+}
+
+func TestAttestedOkBoundToToken(t *testing.T) {
+	_, priv := GenerateKeypair()
+	policy := `(and (= (get req "action") "payments.create") (attested_ok?))`
+	tok, err := Mint(policy, priv, MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := newFakeAuthenticator(t)
+	challenge := sha256.Sum256(SigningPayload(tok.Policy, tok.MerkleRoot, tok.HashChainCommitment, tok.Sealed, tok.Expires))
+	check := &WebAuthnCheck{
+		Assertion:       dev.assert(t, challenge[:], "https://app.example.com", "example.com", webauthnFlagUP),
+		WebAuthnOptions: WebAuthnOptions{Origin: "https://app.example.com", RPID: "example.com", PublicKey: dev.pubHex},
+	}
+	if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{WebAuthn: check}); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+
+	// The request field alone no longer counts as attestation.
+	req := tokenTestReq(50)
+	req["device_attested"] = true
+	if r := VerifyTokenObj(tok, req, VerifyTokenOptions{}); r.Allow {
+		t.Fatal("expected deny without a WebAuthn assertion")
+	}
+
+	// An assertion for a different token's challenge is rejected.
+	check.Assertion = dev.assert(t, []byte("replayed"), "https://app.example.com", "example.com", webauthnFlagUP)
+	if r := VerifyTokenObj(tok, tokenTestReq(50), VerifyTokenOptions{WebAuthn: check}); r.Allow {
+		t.Fatal("expected deny for assertion over a different challenge")
+	}
+}
@@ -0,0 +1,121 @@
+package spl
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// X509Options anchors issuer trust in an existing PKI. A token either
+// carries its chain in x5c or references its leaf by x5t#S256, in which case
+// Lookup must supply the chain. The leaf's public key must be the token's
+// issuer key. The chain itself is not covered by the token signature; it is
+// authenticated by the CA signatures instead.
+type X509Options struct {
+	// Roots are the pinned root CAs. Required.
+	Roots *x509.CertPool
+	// Intermediates supplements intermediates carried in the token.
+	Intermediates *x509.CertPool
+	// Lookup resolves a base64url SHA-256 leaf thumbprint to a chain, leaf
+	// first. Needed only for tokens that reference rather than carry a chain.
+	Lookup func(thumbprint string) ([]*x509.Certificate, error)
+	// KeyUsages restricts the leaf's extended key usages. Empty means any.
+	KeyUsages []x509.ExtKeyUsage
+}
+
+// CertThumbprint returns the base64url SHA-256 thumbprint of a DER
+// certificate, as used in x5t#S256.
+func CertThumbprint(der []byte) string {
+	h := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+func bindCertChain(t *Token, chain [][]byte, ref bool) error {
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return fmt.Errorf("invalid leaf certificate: %w", err)
+	}
+	_, leafKey, err := encodePublicKey(leaf.PublicKey)
+	if err != nil {
+		return fmt.Errorf("leaf certificate: %w", err)
+	}
+	if leafKey != t.PublicKey {
+		return errors.New("leaf certificate key does not match minting key")
+	}
+	if ref {
+		t.X5TS256 = CertThumbprint(chain[0])
+		return nil
+	}
+	for _, der := range chain {
+		t.X5C = append(t.X5C, base64.StdEncoding.EncodeToString(der))
+	}
+	return nil
+}
+
+func (o *X509Options) verify(t *Token, issuerKey string, now time.Time) error {
+	if o.Roots == nil {
+		return errors.New("no pinned roots configured")
+	}
+	var chain []*x509.Certificate
+	switch {
+	case len(t.X5C) > 0:
+		for _, enc := range t.X5C {
+			der, err := base64.StdEncoding.DecodeString(enc)
+			if err != nil {
+				return fmt.Errorf("invalid x5c entry: %w", err)
+			}
+			c, err := x509.ParseCertificate(der)
+			if err != nil {
+				return err
+			}
+			chain = append(chain, c)
+		}
+		if t.X5TS256 != "" && t.X5TS256 != CertThumbprint(chain[0].Raw) {
+			return errors.New("x5t#S256 does not match x5c leaf")
+		}
+	case t.X5TS256 != "":
+		if o.Lookup == nil {
+			return errors.New("token references a certificate but no lookup is configured")
+		}
+		var err error
+		if chain, err = o.Lookup(t.X5TS256); err != nil {
+			return err
+		}
+		if len(chain) == 0 || CertThumbprint(chain[0].Raw) != t.X5TS256 {
+			return errors.New("looked-up certificate does not match x5t#S256")
+		}
+	default:
+		return errors.New("token carries no certificate chain")
+	}
+
+	leaf := chain[0]
+	_, leafKey, err := encodePublicKey(leaf.PublicKey)
+	if err != nil {
+		return err
+	}
+	if leafKey != issuerKey {
+		return errors.New("leaf certificate key does not match token key")
+	}
+
+	intermediates := x509.NewCertPool()
+	if o.Intermediates != nil {
+		intermediates = o.Intermediates.Clone()
+	}
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	usages := o.KeyUsages
+	if len(usages) == 0 {
+		usages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         o.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     usages,
+	})
+	return err
+}
@@ -0,0 +1,126 @@
+package spl
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testPKI issues a root CA and a leaf certificate for an Ed25519 minting key.
+func testPKI(t *testing.T) (root *x509.Certificate, leafDER []byte, mintPriv string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		NotBefore:             time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ = x509.ParseCertificate(caDER)
+
+	pubHex, privHex := GenerateKeypair()
+	pub, _ := hex.DecodeString(pubHex)
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "issuer.example.com"},
+		NotBefore:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leafDER, err = x509.CreateCertificate(rand.Reader, leafTmpl, root, ed25519.PublicKey(pub), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, leafDER, privHex
+}
+
+func TestX509ChainBinding(t *testing.T) {
+	root, leafDER, priv := testPKI(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{CertChain: [][]byte{leafDER}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tok.X5C) != 1 {
+		t.Fatalf("expected x5c with 1 cert, got %d", len(tok.X5C))
+	}
+	opts := VerifyTokenOptions{Now: "2026-01-01T00:00:00Z", X509: &X509Options{Roots: roots}}
+	if r := VerifyTokenObj(tok, tokenTestReq(50), opts); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+
+	// Outside the leaf's validity window
+	opts.Now = "2028-01-01T00:00:00Z"
+	if r := VerifyTokenObj(tok, tokenTestReq(50), opts); r.Allow {
+		t.Fatal("expected deny for expired leaf certificate")
+	}
+
+	// Untrusted root
+	opts = VerifyTokenOptions{Now: "2026-01-01T00:00:00Z", X509: &X509Options{Roots: x509.NewCertPool()}}
+	if r := VerifyTokenObj(tok, tokenTestReq(50), opts); r.Allow {
+		t.Fatal("expected deny for unpinned root")
+	}
+}
+
+func TestX509ChainKeyMismatch(t *testing.T) {
+	root, leafDER, _ := testPKI(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	_, otherPriv := GenerateKeypair()
+	if _, err := Mint(tokenTestPolicy, otherPriv, MintOptions{CertChain: [][]byte{leafDER}}); err == nil {
+		t.Fatal("expected mint to reject a certificate for a different key")
+	}
+
+	// A token re-labelled with someone else's chain must not verify.
+	tok, _ := Mint(tokenTestPolicy, otherPriv, MintOptions{})
+	tok.X5C = []string{base64.StdEncoding.EncodeToString(leafDER)}
+	opts := VerifyTokenOptions{Now: "2026-01-01T00:00:00Z", X509: &X509Options{Roots: roots}}
+	if r := VerifyTokenObj(tok, tokenTestReq(50), opts); r.Allow {
+		t.Fatal("expected deny for foreign certificate chain")
+	}
+}
+
+func TestX509ChainByReference(t *testing.T) {
+	root, leafDER, priv := testPKI(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	leaf, _ := x509.ParseCertificate(leafDER)
+
+	tok, err := Mint(tokenTestPolicy, priv, MintOptions{CertChain: [][]byte{leafDER}, CertRef: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tok.X5C) != 0 || tok.X5TS256 != CertThumbprint(leafDER) {
+		t.Fatal("expected reference-only token")
+	}
+	opts := VerifyTokenOptions{Now: "2026-01-01T00:00:00Z", X509: &X509Options{
+		Roots: roots,
+		Lookup: func(thumb string) ([]*x509.Certificate, error) {
+			return []*x509.Certificate{leaf}, nil
+		},
+	}}
+	if r := VerifyTokenObj(tok, tokenTestReq(50), opts); !r.Allow {
+		t.Fatalf("expected allow, got %q", r.ErrorMessage())
+	}
+}
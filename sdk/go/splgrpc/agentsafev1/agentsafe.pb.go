@@ -0,0 +1,952 @@
+// The Agent-Safe verification service: verify, inspect, mint and check
+// delegation chains of Agent-Safe tokens over gRPC, for fleets whose
+// services are not written in a language with an SDK.
+//
+// Tokens are passed as strings in their JSON or compact (base64url JSON)
+// form, exactly as the CLI and HTTP service accept them. Requests are JSON
+// objects, carried as google.protobuf.Struct.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: agentsafe/v1/agentsafe.proto
+
+package agentsafev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SignatureStatus int32
+
+const (
+	SignatureStatus_SIGNATURE_STATUS_UNSPECIFIED SignatureStatus = 0
+	SignatureStatus_SIGNATURE_STATUS_VALID       SignatureStatus = 1
+	SignatureStatus_SIGNATURE_STATUS_INVALID     SignatureStatus = 2
+	// HS256 tokens cannot be checked without the shared secret.
+	SignatureStatus_SIGNATURE_STATUS_UNCHECKED SignatureStatus = 3
+)
+
+// Enum value maps for SignatureStatus.
+var (
+	SignatureStatus_name = map[int32]string{
+		0: "SIGNATURE_STATUS_UNSPECIFIED",
+		1: "SIGNATURE_STATUS_VALID",
+		2: "SIGNATURE_STATUS_INVALID",
+		3: "SIGNATURE_STATUS_UNCHECKED",
+	}
+	SignatureStatus_value = map[string]int32{
+		"SIGNATURE_STATUS_UNSPECIFIED": 0,
+		"SIGNATURE_STATUS_VALID":       1,
+		"SIGNATURE_STATUS_INVALID":     2,
+		"SIGNATURE_STATUS_UNCHECKED":   3,
+	}
+)
+
+func (x SignatureStatus) Enum() *SignatureStatus {
+	p := new(SignatureStatus)
+	*p = x
+	return p
+}
+
+func (x SignatureStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SignatureStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_agentsafe_v1_agentsafe_proto_enumTypes[0].Descriptor()
+}
+
+func (SignatureStatus) Type() protoreflect.EnumType {
+	return &file_agentsafe_v1_agentsafe_proto_enumTypes[0]
+}
+
+func (x SignatureStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SignatureStatus.Descriptor instead.
+func (SignatureStatus) EnumDescriptor() ([]byte, []int) {
+	return file_agentsafe_v1_agentsafe_proto_rawDescGZIP(), []int{0}
+}
+
+type VerifyTokenRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The token, as JSON or in compact form.
+	Token string `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	// The request the policy sees as req.
+	Request *structpb.Struct `protobuf:"bytes,2,opt,name=request,proto3" json:"request,omitempty"`
+	// Hex signature over the signing payload by the token's pop_key, for
+	// tokens bound to a proof-of-possession key.
+	PresentationSignature string `protobuf:"bytes,3,opt,name=presentation_signature,json=presentationSignature,proto3" json:"presentation_signature,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *VerifyTokenRequest) Reset() {
+	*x = VerifyTokenRequest{}
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyTokenRequest) ProtoMessage() {}
+
+func (x *VerifyTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyTokenRequest.ProtoReflect.Descriptor instead.
+func (*VerifyTokenRequest) Descriptor() ([]byte, []int) {
+	return file_agentsafe_v1_agentsafe_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *VerifyTokenRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *VerifyTokenRequest) GetRequest() *structpb.Struct {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *VerifyTokenRequest) GetPresentationSignature() string {
+	if x != nil {
+		return x.PresentationSignature
+	}
+	return ""
+}
+
+type VerifyTokenResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Allow bool                   `protobuf:"varint,1,opt,name=allow,proto3" json:"allow,omitempty"`
+	// Why verification failed before or during evaluation; empty for a plain
+	// allow or deny.
+	Error         string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Sealed        bool   `protobuf:"varint,3,opt,name=sealed,proto3" json:"sealed,omitempty"`
+	GasUsed       int64  `protobuf:"varint,4,opt,name=gas_used,json=gasUsed,proto3" json:"gas_used,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyTokenResponse) Reset() {
+	*x = VerifyTokenResponse{}
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyTokenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyTokenResponse) ProtoMessage() {}
+
+func (x *VerifyTokenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyTokenResponse.ProtoReflect.Descriptor instead.
+func (*VerifyTokenResponse) Descriptor() ([]byte, []int) {
+	return file_agentsafe_v1_agentsafe_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *VerifyTokenResponse) GetAllow() bool {
+	if x != nil {
+		return x.Allow
+	}
+	return false
+}
+
+func (x *VerifyTokenResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *VerifyTokenResponse) GetSealed() bool {
+	if x != nil {
+		return x.Sealed
+	}
+	return false
+}
+
+func (x *VerifyTokenResponse) GetGasUsed() int64 {
+	if x != nil {
+		return x.GasUsed
+	}
+	return 0
+}
+
+type InspectTokenRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The token, as JSON or in compact form.
+	Token         string `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InspectTokenRequest) Reset() {
+	*x = InspectTokenRequest{}
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InspectTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InspectTokenRequest) ProtoMessage() {}
+
+func (x *InspectTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InspectTokenRequest.ProtoReflect.Descriptor instead.
+func (*InspectTokenRequest) Descriptor() ([]byte, []int) {
+	return file_agentsafe_v1_agentsafe_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *InspectTokenRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+// A way the token's JSON departs from the token schema.
+type SchemaProblem struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// JSON Pointer to the value; empty for the token itself.
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// syntax, unknown-field, missing-field, type or invalid.
+	Kind          string `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Message       string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SchemaProblem) Reset() {
+	*x = SchemaProblem{}
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SchemaProblem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SchemaProblem) ProtoMessage() {}
+
+func (x *SchemaProblem) ProtoReflect() protoreflect.Message {
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SchemaProblem.ProtoReflect.Descriptor instead.
+func (*SchemaProblem) Descriptor() ([]byte, []int) {
+	return file_agentsafe_v1_agentsafe_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SchemaProblem) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *SchemaProblem) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *SchemaProblem) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type InspectTokenResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Version string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	// The signature algorithm; Ed25519 when the token names none.
+	Alg                 string `protobuf:"bytes,2,opt,name=alg,proto3" json:"alg,omitempty"`
+	Kid                 string `protobuf:"bytes,3,opt,name=kid,proto3" json:"kid,omitempty"`
+	PublicKey           string `protobuf:"bytes,4,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Sealed              bool   `protobuf:"varint,5,opt,name=sealed,proto3" json:"sealed,omitempty"`
+	Expires             string `protobuf:"bytes,6,opt,name=expires,proto3" json:"expires,omitempty"`
+	PopKey              string `protobuf:"bytes,7,opt,name=pop_key,json=popKey,proto3" json:"pop_key,omitempty"`
+	MerkleRoot          string `protobuf:"bytes,8,opt,name=merkle_root,json=merkleRoot,proto3" json:"merkle_root,omitempty"`
+	HashChainCommitment string `protobuf:"bytes,9,opt,name=hash_chain_commitment,json=hashChainCommitment,proto3" json:"hash_chain_commitment,omitempty"`
+	// Whether the token also carries an ML-DSA-65 signature.
+	Hybrid         bool             `protobuf:"varint,10,opt,name=hybrid,proto3" json:"hybrid,omitempty"`
+	Caveats        int32            `protobuf:"varint,11,opt,name=caveats,proto3" json:"caveats,omitempty"`
+	Policy         string           `protobuf:"bytes,12,opt,name=policy,proto3" json:"policy,omitempty"`
+	Signature      SignatureStatus  `protobuf:"varint,13,opt,name=signature,proto3,enum=agentsafe.v1.SignatureStatus" json:"signature,omitempty"`
+	SchemaProblems []*SchemaProblem `protobuf:"bytes,14,rep,name=schema_problems,json=schemaProblems,proto3" json:"schema_problems,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *InspectTokenResponse) Reset() {
+	*x = InspectTokenResponse{}
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InspectTokenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InspectTokenResponse) ProtoMessage() {}
+
+func (x *InspectTokenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InspectTokenResponse.ProtoReflect.Descriptor instead.
+func (*InspectTokenResponse) Descriptor() ([]byte, []int) {
+	return file_agentsafe_v1_agentsafe_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *InspectTokenResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *InspectTokenResponse) GetAlg() string {
+	if x != nil {
+		return x.Alg
+	}
+	return ""
+}
+
+func (x *InspectTokenResponse) GetKid() string {
+	if x != nil {
+		return x.Kid
+	}
+	return ""
+}
+
+func (x *InspectTokenResponse) GetPublicKey() string {
+	if x != nil {
+		return x.PublicKey
+	}
+	return ""
+}
+
+func (x *InspectTokenResponse) GetSealed() bool {
+	if x != nil {
+		return x.Sealed
+	}
+	return false
+}
+
+func (x *InspectTokenResponse) GetExpires() string {
+	if x != nil {
+		return x.Expires
+	}
+	return ""
+}
+
+func (x *InspectTokenResponse) GetPopKey() string {
+	if x != nil {
+		return x.PopKey
+	}
+	return ""
+}
+
+func (x *InspectTokenResponse) GetMerkleRoot() string {
+	if x != nil {
+		return x.MerkleRoot
+	}
+	return ""
+}
+
+func (x *InspectTokenResponse) GetHashChainCommitment() string {
+	if x != nil {
+		return x.HashChainCommitment
+	}
+	return ""
+}
+
+func (x *InspectTokenResponse) GetHybrid() bool {
+	if x != nil {
+		return x.Hybrid
+	}
+	return false
+}
+
+func (x *InspectTokenResponse) GetCaveats() int32 {
+	if x != nil {
+		return x.Caveats
+	}
+	return 0
+}
+
+func (x *InspectTokenResponse) GetPolicy() string {
+	if x != nil {
+		return x.Policy
+	}
+	return ""
+}
+
+func (x *InspectTokenResponse) GetSignature() SignatureStatus {
+	if x != nil {
+		return x.Signature
+	}
+	return SignatureStatus_SIGNATURE_STATUS_UNSPECIFIED
+}
+
+func (x *InspectTokenResponse) GetSchemaProblems() []*SchemaProblem {
+	if x != nil {
+		return x.SchemaProblems
+	}
+	return nil
+}
+
+type MintTokenRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The SPL policy. Policies with lint errors are refused.
+	Policy string `protobuf:"bytes,1,opt,name=policy,proto3" json:"policy,omitempty"`
+	// Names the server issuer key to sign with; may be empty when the server
+	// has only one.
+	Issuer string `protobuf:"bytes,2,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	// RFC 3339 expiry; empty for none.
+	Expires string `protobuf:"bytes,3,opt,name=expires,proto3" json:"expires,omitempty"`
+	Sealed  bool   `protobuf:"varint,4,opt,name=sealed,proto3" json:"sealed,omitempty"`
+	// Binds the token to a holder key, did:key or SPIFFE ID.
+	PopKey              string `protobuf:"bytes,5,opt,name=pop_key,json=popKey,proto3" json:"pop_key,omitempty"`
+	MerkleRoot          string `protobuf:"bytes,6,opt,name=merkle_root,json=merkleRoot,proto3" json:"merkle_root,omitempty"`
+	HashChainCommitment string `protobuf:"bytes,7,opt,name=hash_chain_commitment,json=hashChainCommitment,proto3" json:"hash_chain_commitment,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *MintTokenRequest) Reset() {
+	*x = MintTokenRequest{}
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MintTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MintTokenRequest) ProtoMessage() {}
+
+func (x *MintTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MintTokenRequest.ProtoReflect.Descriptor instead.
+func (*MintTokenRequest) Descriptor() ([]byte, []int) {
+	return file_agentsafe_v1_agentsafe_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MintTokenRequest) GetPolicy() string {
+	if x != nil {
+		return x.Policy
+	}
+	return ""
+}
+
+func (x *MintTokenRequest) GetIssuer() string {
+	if x != nil {
+		return x.Issuer
+	}
+	return ""
+}
+
+func (x *MintTokenRequest) GetExpires() string {
+	if x != nil {
+		return x.Expires
+	}
+	return ""
+}
+
+func (x *MintTokenRequest) GetSealed() bool {
+	if x != nil {
+		return x.Sealed
+	}
+	return false
+}
+
+func (x *MintTokenRequest) GetPopKey() string {
+	if x != nil {
+		return x.PopKey
+	}
+	return ""
+}
+
+func (x *MintTokenRequest) GetMerkleRoot() string {
+	if x != nil {
+		return x.MerkleRoot
+	}
+	return ""
+}
+
+func (x *MintTokenRequest) GetHashChainCommitment() string {
+	if x != nil {
+		return x.HashChainCommitment
+	}
+	return ""
+}
+
+type MintTokenResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The token as JSON.
+	Token string `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	// The same token in compact form.
+	Compact       string `protobuf:"bytes,2,opt,name=compact,proto3" json:"compact,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MintTokenResponse) Reset() {
+	*x = MintTokenResponse{}
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MintTokenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MintTokenResponse) ProtoMessage() {}
+
+func (x *MintTokenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MintTokenResponse.ProtoReflect.Descriptor instead.
+func (*MintTokenResponse) Descriptor() ([]byte, []int) {
+	return file_agentsafe_v1_agentsafe_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *MintTokenResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *MintTokenResponse) GetCompact() string {
+	if x != nil {
+		return x.Compact
+	}
+	return ""
+}
+
+type VerifyChainRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The chain, root first, each as JSON or in compact form.
+	Tokens []string `protobuf:"bytes,1,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	// When set, the last token is verified against this request.
+	Request               *structpb.Struct `protobuf:"bytes,2,opt,name=request,proto3" json:"request,omitempty"`
+	PresentationSignature string           `protobuf:"bytes,3,opt,name=presentation_signature,json=presentationSignature,proto3" json:"presentation_signature,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *VerifyChainRequest) Reset() {
+	*x = VerifyChainRequest{}
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyChainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyChainRequest) ProtoMessage() {}
+
+func (x *VerifyChainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyChainRequest.ProtoReflect.Descriptor instead.
+func (*VerifyChainRequest) Descriptor() ([]byte, []int) {
+	return file_agentsafe_v1_agentsafe_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *VerifyChainRequest) GetTokens() []string {
+	if x != nil {
+		return x.Tokens
+	}
+	return nil
+}
+
+func (x *VerifyChainRequest) GetRequest() *structpb.Struct {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *VerifyChainRequest) GetPresentationSignature() string {
+	if x != nil {
+		return x.PresentationSignature
+	}
+	return ""
+}
+
+type ChainLink struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Signature SignatureStatus        `protobuf:"varint,1,opt,name=signature,proto3,enum=agentsafe.v1.SignatureStatus" json:"signature,omitempty"`
+	// Why this link is invalid; empty when it is valid.
+	Error         string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChainLink) Reset() {
+	*x = ChainLink{}
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChainLink) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChainLink) ProtoMessage() {}
+
+func (x *ChainLink) ProtoReflect() protoreflect.Message {
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChainLink.ProtoReflect.Descriptor instead.
+func (*ChainLink) Descriptor() ([]byte, []int) {
+	return file_agentsafe_v1_agentsafe_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ChainLink) GetSignature() SignatureStatus {
+	if x != nil {
+		return x.Signature
+	}
+	return SignatureStatus_SIGNATURE_STATUS_UNSPECIFIED
+}
+
+func (x *ChainLink) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type VerifyChainResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Valid bool                   `protobuf:"varint,1,opt,name=valid,proto3" json:"valid,omitempty"`
+	Links []*ChainLink           `protobuf:"bytes,2,rep,name=links,proto3" json:"links,omitempty"`
+	// The last token's verification, when a request was given and the chain
+	// is valid.
+	Result        *VerifyTokenResponse `protobuf:"bytes,3,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyChainResponse) Reset() {
+	*x = VerifyChainResponse{}
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyChainResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyChainResponse) ProtoMessage() {}
+
+func (x *VerifyChainResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_agentsafe_v1_agentsafe_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyChainResponse.ProtoReflect.Descriptor instead.
+func (*VerifyChainResponse) Descriptor() ([]byte, []int) {
+	return file_agentsafe_v1_agentsafe_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *VerifyChainResponse) GetValid() bool {
+	if x != nil {
+		return x.Valid
+	}
+	return false
+}
+
+func (x *VerifyChainResponse) GetLinks() []*ChainLink {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *VerifyChainResponse) GetResult() *VerifyTokenResponse {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+var File_agentsafe_v1_agentsafe_proto protoreflect.FileDescriptor
+
+const file_agentsafe_v1_agentsafe_proto_rawDesc = "" +
+	"\n" +
+	"\x1cagentsafe/v1/agentsafe.proto\x12\fagentsafe.v1\x1a\x1cgoogle/protobuf/struct.proto\"\x94\x01\n" +
+	"\x12VerifyTokenRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x121\n" +
+	"\arequest\x18\x02 \x01(\v2\x17.google.protobuf.StructR\arequest\x125\n" +
+	"\x16presentation_signature\x18\x03 \x01(\tR\x15presentationSignature\"t\n" +
+	"\x13VerifyTokenResponse\x12\x14\n" +
+	"\x05allow\x18\x01 \x01(\bR\x05allow\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x16\n" +
+	"\x06sealed\x18\x03 \x01(\bR\x06sealed\x12\x19\n" +
+	"\bgas_used\x18\x04 \x01(\x03R\agasUsed\"+\n" +
+	"\x13InspectTokenRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\"Q\n" +
+	"\rSchemaProblem\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"\xe0\x03\n" +
+	"\x14InspectTokenResponse\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x12\x10\n" +
+	"\x03alg\x18\x02 \x01(\tR\x03alg\x12\x10\n" +
+	"\x03kid\x18\x03 \x01(\tR\x03kid\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x04 \x01(\tR\tpublicKey\x12\x16\n" +
+	"\x06sealed\x18\x05 \x01(\bR\x06sealed\x12\x18\n" +
+	"\aexpires\x18\x06 \x01(\tR\aexpires\x12\x17\n" +
+	"\apop_key\x18\a \x01(\tR\x06popKey\x12\x1f\n" +
+	"\vmerkle_root\x18\b \x01(\tR\n" +
+	"merkleRoot\x122\n" +
+	"\x15hash_chain_commitment\x18\t \x01(\tR\x13hashChainCommitment\x12\x16\n" +
+	"\x06hybrid\x18\n" +
+	" \x01(\bR\x06hybrid\x12\x18\n" +
+	"\acaveats\x18\v \x01(\x05R\acaveats\x12\x16\n" +
+	"\x06policy\x18\f \x01(\tR\x06policy\x12;\n" +
+	"\tsignature\x18\r \x01(\x0e2\x1d.agentsafe.v1.SignatureStatusR\tsignature\x12D\n" +
+	"\x0fschema_problems\x18\x0e \x03(\v2\x1b.agentsafe.v1.SchemaProblemR\x0eschemaProblems\"\xe2\x01\n" +
+	"\x10MintTokenRequest\x12\x16\n" +
+	"\x06policy\x18\x01 \x01(\tR\x06policy\x12\x16\n" +
+	"\x06issuer\x18\x02 \x01(\tR\x06issuer\x12\x18\n" +
+	"\aexpires\x18\x03 \x01(\tR\aexpires\x12\x16\n" +
+	"\x06sealed\x18\x04 \x01(\bR\x06sealed\x12\x17\n" +
+	"\apop_key\x18\x05 \x01(\tR\x06popKey\x12\x1f\n" +
+	"\vmerkle_root\x18\x06 \x01(\tR\n" +
+	"merkleRoot\x122\n" +
+	"\x15hash_chain_commitment\x18\a \x01(\tR\x13hashChainCommitment\"C\n" +
+	"\x11MintTokenResponse\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x18\n" +
+	"\acompact\x18\x02 \x01(\tR\acompact\"\x96\x01\n" +
+	"\x12VerifyChainRequest\x12\x16\n" +
+	"\x06tokens\x18\x01 \x03(\tR\x06tokens\x121\n" +
+	"\arequest\x18\x02 \x01(\v2\x17.google.protobuf.StructR\arequest\x125\n" +
+	"\x16presentation_signature\x18\x03 \x01(\tR\x15presentationSignature\"^\n" +
+	"\tChainLink\x12;\n" +
+	"\tsignature\x18\x01 \x01(\x0e2\x1d.agentsafe.v1.SignatureStatusR\tsignature\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"\x95\x01\n" +
+	"\x13VerifyChainResponse\x12\x14\n" +
+	"\x05valid\x18\x01 \x01(\bR\x05valid\x12-\n" +
+	"\x05links\x18\x02 \x03(\v2\x17.agentsafe.v1.ChainLinkR\x05links\x129\n" +
+	"\x06result\x18\x03 \x01(\v2!.agentsafe.v1.VerifyTokenResponseR\x06result*\x8d\x01\n" +
+	"\x0fSignatureStatus\x12 \n" +
+	"\x1cSIGNATURE_STATUS_UNSPECIFIED\x10\x00\x12\x1a\n" +
+	"\x16SIGNATURE_STATUS_VALID\x10\x01\x12\x1c\n" +
+	"\x18SIGNATURE_STATUS_INVALID\x10\x02\x12\x1e\n" +
+	"\x1aSIGNATURE_STATUS_UNCHECKED\x10\x032\xdf\x02\n" +
+	"\x10AgentSafeService\x12R\n" +
+	"\vVerifyToken\x12 .agentsafe.v1.VerifyTokenRequest\x1a!.agentsafe.v1.VerifyTokenResponse\x12U\n" +
+	"\fInspectToken\x12!.agentsafe.v1.InspectTokenRequest\x1a\".agentsafe.v1.InspectTokenResponse\x12L\n" +
+	"\tMintToken\x12\x1e.agentsafe.v1.MintTokenRequest\x1a\x1f.agentsafe.v1.MintTokenResponse\x12R\n" +
+	"\vVerifyChain\x12 .agentsafe.v1.VerifyChainRequest\x1a!.agentsafe.v1.VerifyChainResponseBHZFgithub.com/jmcentire/agent-safe/sdk/go/splgrpc/agentsafev1;agentsafev1b\x06proto3"
+
+var (
+	file_agentsafe_v1_agentsafe_proto_rawDescOnce sync.Once
+	file_agentsafe_v1_agentsafe_proto_rawDescData []byte
+)
+
+func file_agentsafe_v1_agentsafe_proto_rawDescGZIP() []byte {
+	file_agentsafe_v1_agentsafe_proto_rawDescOnce.Do(func() {
+		file_agentsafe_v1_agentsafe_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_agentsafe_v1_agentsafe_proto_rawDesc), len(file_agentsafe_v1_agentsafe_proto_rawDesc)))
+	})
+	return file_agentsafe_v1_agentsafe_proto_rawDescData
+}
+
+var file_agentsafe_v1_agentsafe_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_agentsafe_v1_agentsafe_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_agentsafe_v1_agentsafe_proto_goTypes = []any{
+	(SignatureStatus)(0),         // 0: agentsafe.v1.SignatureStatus
+	(*VerifyTokenRequest)(nil),   // 1: agentsafe.v1.VerifyTokenRequest
+	(*VerifyTokenResponse)(nil),  // 2: agentsafe.v1.VerifyTokenResponse
+	(*InspectTokenRequest)(nil),  // 3: agentsafe.v1.InspectTokenRequest
+	(*SchemaProblem)(nil),        // 4: agentsafe.v1.SchemaProblem
+	(*InspectTokenResponse)(nil), // 5: agentsafe.v1.InspectTokenResponse
+	(*MintTokenRequest)(nil),     // 6: agentsafe.v1.MintTokenRequest
+	(*MintTokenResponse)(nil),    // 7: agentsafe.v1.MintTokenResponse
+	(*VerifyChainRequest)(nil),   // 8: agentsafe.v1.VerifyChainRequest
+	(*ChainLink)(nil),            // 9: agentsafe.v1.ChainLink
+	(*VerifyChainResponse)(nil),  // 10: agentsafe.v1.VerifyChainResponse
+	(*structpb.Struct)(nil),      // 11: google.protobuf.Struct
+}
+var file_agentsafe_v1_agentsafe_proto_depIdxs = []int32{
+	11, // 0: agentsafe.v1.VerifyTokenRequest.request:type_name -> google.protobuf.Struct
+	0,  // 1: agentsafe.v1.InspectTokenResponse.signature:type_name -> agentsafe.v1.SignatureStatus
+	4,  // 2: agentsafe.v1.InspectTokenResponse.schema_problems:type_name -> agentsafe.v1.SchemaProblem
+	11, // 3: agentsafe.v1.VerifyChainRequest.request:type_name -> google.protobuf.Struct
+	0,  // 4: agentsafe.v1.ChainLink.signature:type_name -> agentsafe.v1.SignatureStatus
+	9,  // 5: agentsafe.v1.VerifyChainResponse.links:type_name -> agentsafe.v1.ChainLink
+	2,  // 6: agentsafe.v1.VerifyChainResponse.result:type_name -> agentsafe.v1.VerifyTokenResponse
+	1,  // 7: agentsafe.v1.AgentSafeService.VerifyToken:input_type -> agentsafe.v1.VerifyTokenRequest
+	3,  // 8: agentsafe.v1.AgentSafeService.InspectToken:input_type -> agentsafe.v1.InspectTokenRequest
+	6,  // 9: agentsafe.v1.AgentSafeService.MintToken:input_type -> agentsafe.v1.MintTokenRequest
+	8,  // 10: agentsafe.v1.AgentSafeService.VerifyChain:input_type -> agentsafe.v1.VerifyChainRequest
+	2,  // 11: agentsafe.v1.AgentSafeService.VerifyToken:output_type -> agentsafe.v1.VerifyTokenResponse
+	5,  // 12: agentsafe.v1.AgentSafeService.InspectToken:output_type -> agentsafe.v1.InspectTokenResponse
+	7,  // 13: agentsafe.v1.AgentSafeService.MintToken:output_type -> agentsafe.v1.MintTokenResponse
+	10, // 14: agentsafe.v1.AgentSafeService.VerifyChain:output_type -> agentsafe.v1.VerifyChainResponse
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_agentsafe_v1_agentsafe_proto_init() }
+func file_agentsafe_v1_agentsafe_proto_init() {
+	if File_agentsafe_v1_agentsafe_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_agentsafe_v1_agentsafe_proto_rawDesc), len(file_agentsafe_v1_agentsafe_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_agentsafe_v1_agentsafe_proto_goTypes,
+		DependencyIndexes: file_agentsafe_v1_agentsafe_proto_depIdxs,
+		EnumInfos:         file_agentsafe_v1_agentsafe_proto_enumTypes,
+		MessageInfos:      file_agentsafe_v1_agentsafe_proto_msgTypes,
+	}.Build()
+	File_agentsafe_v1_agentsafe_proto = out.File
+	file_agentsafe_v1_agentsafe_proto_goTypes = nil
+	file_agentsafe_v1_agentsafe_proto_depIdxs = nil
+}
@@ -0,0 +1,265 @@
+// The Agent-Safe verification service: verify, inspect, mint and check
+// delegation chains of Agent-Safe tokens over gRPC, for fleets whose
+// services are not written in a language with an SDK.
+//
+// Tokens are passed as strings in their JSON or compact (base64url JSON)
+// form, exactly as the CLI and HTTP service accept them. Requests are JSON
+// objects, carried as google.protobuf.Struct.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: agentsafe/v1/agentsafe.proto
+
+package agentsafev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AgentSafeService_VerifyToken_FullMethodName  = "/agentsafe.v1.AgentSafeService/VerifyToken"
+	AgentSafeService_InspectToken_FullMethodName = "/agentsafe.v1.AgentSafeService/InspectToken"
+	AgentSafeService_MintToken_FullMethodName    = "/agentsafe.v1.AgentSafeService/MintToken"
+	AgentSafeService_VerifyChain_FullMethodName  = "/agentsafe.v1.AgentSafeService/VerifyChain"
+)
+
+// AgentSafeServiceClient is the client API for AgentSafeService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AgentSafeServiceClient interface {
+	// VerifyToken checks a token's signature, expiry and bindings against
+	// the server's trust anchors and evaluates its policy against a request.
+	// A deny is a response with allow false, not an error.
+	VerifyToken(ctx context.Context, in *VerifyTokenRequest, opts ...grpc.CallOption) (*VerifyTokenResponse, error)
+	// InspectToken describes a token, checks its signature without trust
+	// anchors, and checks its JSON against the token schema.
+	InspectToken(ctx context.Context, in *InspectTokenRequest, opts ...grpc.CallOption) (*InspectTokenResponse, error)
+	// MintToken signs a policy with one of the server's issuer keys. Servers
+	// that hold no issuer keys answer FAILED_PRECONDITION; servers that do
+	// should gate the method, for example with the splgrpc interceptors.
+	MintToken(ctx context.Context, in *MintTokenRequest, opts ...grpc.CallOption) (*MintTokenResponse, error)
+	// VerifyChain checks a delegation chain, root first: every signature,
+	// and that every token is a valid delegation of the one before it.
+	// With a request it also verifies the last token against it.
+	VerifyChain(ctx context.Context, in *VerifyChainRequest, opts ...grpc.CallOption) (*VerifyChainResponse, error)
+}
+
+type agentSafeServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAgentSafeServiceClient(cc grpc.ClientConnInterface) AgentSafeServiceClient {
+	return &agentSafeServiceClient{cc}
+}
+
+func (c *agentSafeServiceClient) VerifyToken(ctx context.Context, in *VerifyTokenRequest, opts ...grpc.CallOption) (*VerifyTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VerifyTokenResponse)
+	err := c.cc.Invoke(ctx, AgentSafeService_VerifyToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentSafeServiceClient) InspectToken(ctx context.Context, in *InspectTokenRequest, opts ...grpc.CallOption) (*InspectTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InspectTokenResponse)
+	err := c.cc.Invoke(ctx, AgentSafeService_InspectToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentSafeServiceClient) MintToken(ctx context.Context, in *MintTokenRequest, opts ...grpc.CallOption) (*MintTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MintTokenResponse)
+	err := c.cc.Invoke(ctx, AgentSafeService_MintToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentSafeServiceClient) VerifyChain(ctx context.Context, in *VerifyChainRequest, opts ...grpc.CallOption) (*VerifyChainResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VerifyChainResponse)
+	err := c.cc.Invoke(ctx, AgentSafeService_VerifyChain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AgentSafeServiceServer is the server API for AgentSafeService service.
+// All implementations must embed UnimplementedAgentSafeServiceServer
+// for forward compatibility.
+type AgentSafeServiceServer interface {
+	// VerifyToken checks a token's signature, expiry and bindings against
+	// the server's trust anchors and evaluates its policy against a request.
+	// A deny is a response with allow false, not an error.
+	VerifyToken(context.Context, *VerifyTokenRequest) (*VerifyTokenResponse, error)
+	// InspectToken describes a token, checks its signature without trust
+	// anchors, and checks its JSON against the token schema.
+	InspectToken(context.Context, *InspectTokenRequest) (*InspectTokenResponse, error)
+	// MintToken signs a policy with one of the server's issuer keys. Servers
+	// that hold no issuer keys answer FAILED_PRECONDITION; servers that do
+	// should gate the method, for example with the splgrpc interceptors.
+	MintToken(context.Context, *MintTokenRequest) (*MintTokenResponse, error)
+	// VerifyChain checks a delegation chain, root first: every signature,
+	// and that every token is a valid delegation of the one before it.
+	// With a request it also verifies the last token against it.
+	VerifyChain(context.Context, *VerifyChainRequest) (*VerifyChainResponse, error)
+	mustEmbedUnimplementedAgentSafeServiceServer()
+}
+
+// UnimplementedAgentSafeServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAgentSafeServiceServer struct{}
+
+func (UnimplementedAgentSafeServiceServer) VerifyToken(context.Context, *VerifyTokenRequest) (*VerifyTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyToken not implemented")
+}
+func (UnimplementedAgentSafeServiceServer) InspectToken(context.Context, *InspectTokenRequest) (*InspectTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InspectToken not implemented")
+}
+func (UnimplementedAgentSafeServiceServer) MintToken(context.Context, *MintTokenRequest) (*MintTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MintToken not implemented")
+}
+func (UnimplementedAgentSafeServiceServer) VerifyChain(context.Context, *VerifyChainRequest) (*VerifyChainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyChain not implemented")
+}
+func (UnimplementedAgentSafeServiceServer) mustEmbedUnimplementedAgentSafeServiceServer() {}
+func (UnimplementedAgentSafeServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeAgentSafeServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AgentSafeServiceServer will
+// result in compilation errors.
+type UnsafeAgentSafeServiceServer interface {
+	mustEmbedUnimplementedAgentSafeServiceServer()
+}
+
+func RegisterAgentSafeServiceServer(s grpc.ServiceRegistrar, srv AgentSafeServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAgentSafeServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AgentSafeService_ServiceDesc, srv)
+}
+
+func _AgentSafeService_VerifyToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentSafeServiceServer).VerifyToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentSafeService_VerifyToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentSafeServiceServer).VerifyToken(ctx, req.(*VerifyTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentSafeService_InspectToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentSafeServiceServer).InspectToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentSafeService_InspectToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentSafeServiceServer).InspectToken(ctx, req.(*InspectTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentSafeService_MintToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MintTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentSafeServiceServer).MintToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentSafeService_MintToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentSafeServiceServer).MintToken(ctx, req.(*MintTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentSafeService_VerifyChain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyChainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentSafeServiceServer).VerifyChain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentSafeService_VerifyChain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentSafeServiceServer).VerifyChain(ctx, req.(*VerifyChainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AgentSafeService_ServiceDesc is the grpc.ServiceDesc for AgentSafeService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AgentSafeService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "agentsafe.v1.AgentSafeService",
+	HandlerType: (*AgentSafeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "VerifyToken",
+			Handler:    _AgentSafeService_VerifyToken_Handler,
+		},
+		{
+			MethodName: "InspectToken",
+			Handler:    _AgentSafeService_InspectToken_Handler,
+		},
+		{
+			MethodName: "MintToken",
+			Handler:    _AgentSafeService_MintToken_Handler,
+		},
+		{
+			MethodName: "VerifyChain",
+			Handler:    _AgentSafeService_VerifyChain_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "agentsafe/v1/agentsafe.proto",
+}
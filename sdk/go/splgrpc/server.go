@@ -0,0 +1,216 @@
+package splgrpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+	pb "github.com/jmcentire/agent-safe/sdk/go/splgrpc/agentsafev1"
+)
+
+// ServerOptions configures the verification service.
+type ServerOptions struct {
+	// Verify configures VerifyToken and VerifyChain: trust anchors, vars,
+	// counters and crypto callbacks. PresentationSignature is taken from
+	// each request instead. Vars are also substituted when VerifyChain
+	// checks that each token narrows the one before it.
+	Verify spl.VerifyTokenOptions
+	// Issuers maps names to the keys MintToken signs with. Without issuers
+	// MintToken is refused.
+	Issuers map[string]Issuer
+}
+
+// Issuer is a key MintToken signs with.
+type Issuer struct {
+	// Alg is spl.AlgEd25519 (the default) or spl.AlgES256.
+	Alg string
+	// PrivateKey is the hex private key, as spl.Mint takes it.
+	PrivateKey string
+}
+
+// NewServer returns the agentsafe.v1.AgentSafeService, defined in
+// proto/agentsafe/v1, for services in any language to verify tokens
+// through:
+//
+//	srv := grpc.NewServer()
+//	agentsafev1.RegisterAgentSafeServiceServer(srv, splgrpc.NewServer(opts))
+//
+// A server with issuers can mint tokens, so gate it with the interceptors
+// or serve it only to trusted callers.
+func NewServer(opts ServerOptions) pb.AgentSafeServiceServer {
+	return &server{opts: opts}
+}
+
+type server struct {
+	pb.UnimplementedAgentSafeServiceServer
+	opts ServerOptions
+}
+
+func parseToken(s string) (*spl.Token, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, status.Error(codes.InvalidArgument, "token required")
+	}
+	tok, err := spl.ParseToken(s)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return tok, nil
+}
+
+func (s *server) verify(tok *spl.Token, req map[string]any, presentation string) *pb.VerifyTokenResponse {
+	if req == nil {
+		req = map[string]any{}
+	}
+	vopts := s.opts.Verify
+	vopts.PresentationSignature = presentation
+	res := spl.VerifyTokenObj(tok, req, vopts)
+	return &pb.VerifyTokenResponse{Allow: res.Allow, Error: res.ErrorMessage(), Sealed: res.Sealed, GasUsed: int64(res.GasUsed)}
+}
+
+func (s *server) VerifyToken(_ context.Context, r *pb.VerifyTokenRequest) (*pb.VerifyTokenResponse, error) {
+	tok, err := parseToken(r.GetToken())
+	if err != nil {
+		return nil, err
+	}
+	return s.verify(tok, r.GetRequest().AsMap(), r.GetPresentationSignature()), nil
+}
+
+func (s *server) InspectToken(_ context.Context, r *pb.InspectTokenRequest) (*pb.InspectTokenResponse, error) {
+	tok, err := parseToken(r.GetToken())
+	if err != nil {
+		return nil, err
+	}
+	// Check the JSON as sent: the parsed token has lost unknown fields.
+	data := []byte(strings.TrimSpace(r.GetToken()))
+	if data[0] != '{' {
+		data, _ = base64.RawURLEncoding.DecodeString(string(data))
+	}
+	alg := tok.Alg
+	if alg == "" {
+		alg = spl.AlgEd25519
+	}
+	resp := &pb.InspectTokenResponse{
+		Version:             tok.Version,
+		Alg:                 alg,
+		Kid:                 tok.KeyID,
+		PublicKey:           tok.PublicKey,
+		Sealed:              tok.Sealed,
+		Expires:             tok.Expires,
+		PopKey:              tok.PoPKey,
+		MerkleRoot:          tok.MerkleRoot,
+		HashChainCommitment: tok.HashChainCommitment,
+		Hybrid:              tok.PQSignature != "",
+		Caveats:             int32(len(tok.Caveats)),
+		Policy:              tok.Policy,
+		Signature:           signatureStatus(tok),
+	}
+	for _, p := range spl.ValidateTokenJSON(data) {
+		resp.SchemaProblems = append(resp.SchemaProblems, &pb.SchemaProblem{Path: p.Path, Kind: p.Kind, Message: p.Message})
+	}
+	return resp, nil
+}
+
+// signatureStatus checks the classical signature without trust anchors or
+// evaluating the policy.
+func signatureStatus(t *spl.Token) pb.SignatureStatus {
+	if t.Alg == spl.AlgHS256 {
+		return pb.SignatureStatus_SIGNATURE_STATUS_UNCHECKED
+	}
+	key := t.PublicKey
+	if strings.HasPrefix(key, "did:key:") {
+		if _, k, err := spl.DIDKeyToPublicKey(key); err == nil {
+			key = k
+		}
+	}
+	payload := t.Payload()
+	if spl.VerifySignature(t.Alg, payload, t.Signature, key) {
+		return pb.SignatureStatus_SIGNATURE_STATUS_VALID
+	}
+	return pb.SignatureStatus_SIGNATURE_STATUS_INVALID
+}
+
+func (s *server) MintToken(_ context.Context, r *pb.MintTokenRequest) (*pb.MintTokenResponse, error) {
+	if len(s.opts.Issuers) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "this server has no issuer keys")
+	}
+	name := r.GetIssuer()
+	if name == "" && len(s.opts.Issuers) == 1 {
+		for n := range s.opts.Issuers {
+			name = n
+		}
+	}
+	issuer, ok := s.opts.Issuers[name]
+	if !ok {
+		names := make([]string, 0, len(s.opts.Issuers))
+		for n := range s.opts.Issuers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, status.Errorf(codes.NotFound, "unknown issuer %q (have %s)", name, strings.Join(names, ", "))
+	}
+	// Lint before signing, as the CLI does: a token cannot be fixed once
+	// issued.
+	for _, d := range spl.Lint(r.GetPolicy()) {
+		if d.Severity >= spl.SeverityError {
+			return nil, status.Error(codes.InvalidArgument, "policy: "+d.String())
+		}
+	}
+	tok, err := spl.Mint(r.GetPolicy(), issuer.PrivateKey, spl.MintOptions{
+		Alg:                 issuer.Alg,
+		Expires:             r.GetExpires(),
+		Sealed:              r.GetSealed(),
+		PoPKey:              r.GetPopKey(),
+		MerkleRoot:          r.GetMerkleRoot(),
+		HashChainCommitment: r.GetHashChainCommitment(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	compact, err := tok.Compact()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.MintTokenResponse{Token: string(data), Compact: compact}, nil
+}
+
+func (s *server) VerifyChain(_ context.Context, r *pb.VerifyChainRequest) (*pb.VerifyChainResponse, error) {
+	if len(r.GetTokens()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "tokens required")
+	}
+	toks := make([]*spl.Token, len(r.GetTokens()))
+	for i, raw := range r.GetTokens() {
+		tok, err := parseToken(raw)
+		if err != nil {
+			return nil, err
+		}
+		toks[i] = tok
+	}
+	resp := &pb.VerifyChainResponse{Valid: true}
+	for i, tok := range toks {
+		l := &pb.ChainLink{Signature: signatureStatus(tok)}
+		switch {
+		case l.Signature == pb.SignatureStatus_SIGNATURE_STATUS_INVALID:
+			l.Error = "invalid signature"
+		case i > 0:
+			if err := spl.VerifyDelegation(toks[i-1], tok, s.opts.Verify.Vars); err != nil {
+				l.Error = err.Error()
+			}
+		}
+		resp.Valid = resp.Valid && l.Error == ""
+		resp.Links = append(resp.Links, l)
+	}
+	if resp.Valid && r.GetRequest() != nil {
+		resp.Result = s.verify(toks[len(toks)-1], r.GetRequest().AsMap(), r.GetPresentationSignature())
+	}
+	return resp, nil
+}
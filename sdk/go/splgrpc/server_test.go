@@ -0,0 +1,122 @@
+package splgrpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+	pb "github.com/jmcentire/agent-safe/sdk/go/splgrpc/agentsafev1"
+)
+
+func dialServer(t *testing.T, opts ServerOptions) pb.AgentSafeServiceClient {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterAgentSafeServiceServer(srv, NewServer(opts))
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewAgentSafeServiceClient(conn)
+}
+
+func TestServerMintVerifyInspect(t *testing.T) {
+	pub, priv := spl.GenerateKeypair()
+	ring, _ := spl.NewKeyRing()
+	ring.Add(spl.KeyRingEntry{ID: "issuer", PublicKey: pub})
+	client := dialServer(t, ServerOptions{
+		Verify:  spl.VerifyTokenOptions{KeyResolver: ring},
+		Issuers: map[string]Issuer{"issuer": {PrivateKey: priv}},
+	})
+	ctx := context.Background()
+
+	minted, err := client.MintToken(ctx, &pb.MintTokenRequest{Policy: `(<= (get req "amount") 50)`, Expires: "2030-01-01T00:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, _ := structpb.NewStruct(map[string]any{"amount": 20})
+	big, _ := structpb.NewStruct(map[string]any{"amount": 200})
+	if res, err := client.VerifyToken(ctx, &pb.VerifyTokenRequest{Token: minted.Compact, Request: small}); err != nil || !res.Allow {
+		t.Fatalf("expected allow, got %v %v", res, err)
+	}
+	if res, err := client.VerifyToken(ctx, &pb.VerifyTokenRequest{Token: minted.Token, Request: big}); err != nil || res.Allow || res.Error != "" {
+		t.Fatalf("expected a plain deny, got %v %v", res, err)
+	}
+	if _, err := client.VerifyToken(ctx, &pb.VerifyTokenRequest{Token: "{not json"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a malformed token, got %v", err)
+	}
+
+	_, stranger := spl.GenerateKeypair()
+	other, _ := spl.Mint(`(<= (get req "amount") 50)`, stranger, spl.MintOptions{})
+	compact, _ := other.Compact()
+	if res, _ := client.VerifyToken(ctx, &pb.VerifyTokenRequest{Token: compact, Request: small}); res.Allow {
+		t.Fatal("expected a token from an untrusted issuer to be refused")
+	}
+
+	info, err := client.InspectToken(ctx, &pb.InspectTokenRequest{Token: strings.Replace(minted.Token, `{`, `{"audience":"x",`, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Alg != spl.AlgEd25519 || info.PublicKey != pub || info.Expires != "2030-01-01T00:00:00Z" ||
+		info.Signature != pb.SignatureStatus_SIGNATURE_STATUS_VALID ||
+		len(info.SchemaProblems) != 1 || info.SchemaProblems[0].Kind != "unknown-field" {
+		t.Fatalf("unexpected inspection %v", info)
+	}
+
+	if _, err := client.MintToken(ctx, &pb.MintTokenRequest{Policy: `(frobnicate)`}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected a lint error to refuse minting, got %v", err)
+	}
+	if _, err := client.MintToken(ctx, &pb.MintTokenRequest{Policy: `#t`, Issuer: "other"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for an unknown issuer, got %v", err)
+	}
+	bare := dialServer(t, ServerOptions{})
+	if _, err := bare.MintToken(ctx, &pb.MintTokenRequest{Policy: `#t`}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition without issuers, got %v", err)
+	}
+}
+
+func TestServerVerifyChain(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	client := dialServer(t, ServerOptions{})
+	ctx := context.Background()
+
+	root, _ := spl.Mint(`(<= (get req "amount") 50)`, priv, spl.MintOptions{})
+	child, err := spl.Attenuate(root, `(<= (get req "amount") 10)`, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootC, _ := root.Compact()
+	childC, _ := child.Compact()
+	req, _ := structpb.NewStruct(map[string]any{"amount": 20})
+	res, err := client.VerifyChain(ctx, &pb.VerifyChainRequest{Tokens: []string{rootC, childC}, Request: req})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid || len(res.Links) != 2 || res.Result == nil || res.Result.Allow {
+		t.Fatalf("expected a valid chain whose last token denies, got %v", res)
+	}
+
+	wider, _ := spl.Mint(`(<= (get req "amount") 500)`, priv, spl.MintOptions{})
+	widerC, _ := wider.Compact()
+	res, err = client.VerifyChain(ctx, &pb.VerifyChainRequest{Tokens: []string{childC, widerC}, Request: req})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Valid || res.Links[1].Error == "" || res.Result != nil {
+		t.Fatalf("expected a widening link to fail, got %v", res)
+	}
+}
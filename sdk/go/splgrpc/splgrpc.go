@@ -0,0 +1,199 @@
+// Package splgrpc gates gRPC services with Agent-Safe capability tokens.
+//
+// The interceptors read the caller's token from request metadata, describe
+// the call to the token's policy, and reject it with PermissionDenied unless
+// the policy allows it:
+//
+//	srv := grpc.NewServer(
+//		grpc.UnaryInterceptor(splgrpc.UnaryServerInterceptor(opts)),
+//		grpc.StreamInterceptor(splgrpc.StreamServerInterceptor(opts)),
+//	)
+//
+// The policy sees req as
+//
+//	{"method": "/payments.v1.Payments/Create",
+//	 "service": "payments.v1.Payments", "rpc": "Create",
+//	 "message": {"amount": 50, ...}}
+//
+// with the request message in its protobuf JSON form, using the field names
+// from the .proto file. It lives in its own module so that the SDK itself
+// stays free of the gRPC dependency.
+package splgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Metadata keys. A token may be sent as "authorization: AgentSafe <token>"
+// (or Bearer) or in TokenKey, in JSON or compact form; a token bound to a
+// proof-of-possession key also needs PresentationKey.
+const (
+	TokenKey        = "agent-safe-token"
+	PresentationKey = "agent-safe-presentation"
+)
+
+// Options configures the interceptors.
+type Options struct {
+	// Verify configures token verification: trust anchors, vars, counters
+	// and crypto callbacks. PresentationSignature is taken from each call's
+	// PresentationKey metadata instead.
+	Verify spl.VerifyTokenOptions
+	// Request, when set, adjusts the request map built for a call before
+	// the policy sees it, e.g. to add the peer's identity.
+	Request func(ctx context.Context, req map[string]any)
+	// OnDecision, when set, is called with every verification result, for
+	// logging and metrics.
+	OnDecision func(ctx context.Context, method string, res spl.VerifyTokenResult)
+}
+
+type contextKey struct{}
+
+// TokenFromContext returns the token that authorized the call, in a
+// handler behind the interceptors.
+func TokenFromContext(ctx context.Context) (*spl.Token, bool) {
+	t, ok := ctx.Value(contextKey{}).(*spl.Token)
+	return t, ok
+}
+
+// UnaryServerInterceptor verifies each unary call's token against the
+// method and request message.
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, msg any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		tok, err := opts.verify(ctx, info.FullMethod, msg, true)
+		if err != nil {
+			return nil, err
+		}
+		return handler(context.WithValue(ctx, contextKey{}, tok), msg)
+	}
+}
+
+// StreamServerInterceptor verifies a stream's token when it opens, against
+// the method alone, and again against every message the client sends.
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		tok, err := opts.verify(ss.Context(), info.FullMethod, nil, false)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &guardedStream{
+			ServerStream: ss,
+			ctx:          context.WithValue(ss.Context(), contextKey{}, tok),
+			method:       info.FullMethod,
+			opts:         &opts,
+		})
+	}
+}
+
+// guardedStream checks each received message before the handler sees it.
+type guardedStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	method string
+	opts   *Options
+}
+
+func (s *guardedStream) Context() context.Context { return s.ctx }
+
+func (s *guardedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	_, err := s.opts.verify(s.ctx, s.method, m, true)
+	return err
+}
+
+// verify checks the call's token against its method and, when withMsg is
+// set, its message.
+func (opts *Options) verify(ctx context.Context, method string, msg any, withMsg bool) (*spl.Token, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	raw := TokenFromMetadata(md)
+	if raw == "" {
+		return nil, status.Error(codes.Unauthenticated, "no Agent-Safe token")
+	}
+	tok, err := spl.ParseToken(raw)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	req, err := RequestMap(method, msg, withMsg)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if opts.Request != nil {
+		opts.Request(ctx, req)
+	}
+	vopts := opts.Verify
+	vopts.PresentationSignature = first(md, PresentationKey)
+	res := spl.VerifyTokenObj(tok, req, vopts)
+	if opts.OnDecision != nil {
+		opts.OnDecision(ctx, method, res)
+	}
+	if !res.Allow {
+		reason := res.ErrorMessage()
+		if reason == "" {
+			reason = "policy denied the request"
+		}
+		return nil, status.Error(codes.PermissionDenied, reason)
+	}
+	return tok, nil
+}
+
+// TokenFromMetadata returns the raw token from the authorization entry
+// (scheme AgentSafe or Bearer) or TokenKey, or "" if there is none.
+func TokenFromMetadata(md metadata.MD) string {
+	if auth := first(md, "authorization"); auth != "" {
+		scheme, tok, ok := strings.Cut(auth, " ")
+		if ok && (strings.EqualFold(scheme, "AgentSafe") || strings.EqualFold(scheme, "Bearer")) {
+			return strings.TrimSpace(tok)
+		}
+	}
+	return strings.TrimSpace(first(md, TokenKey))
+}
+
+func first(md metadata.MD, key string) string {
+	if vs := md.Get(key); len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+// RequestMap describes a call as an SPL request: the full method name, its
+// service and rpc parts and, when withMsg is set, the message. Protobuf
+// messages are converted through their JSON form; other values through
+// encoding/json.
+func RequestMap(method string, msg any, withMsg bool) (map[string]any, error) {
+	service, rpc := "", ""
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		service, rpc = strings.TrimPrefix(method[:i], "/"), method[i+1:]
+	}
+	req := map[string]any{"method": method, "service": service, "rpc": rpc}
+	if !withMsg {
+		return req, nil
+	}
+	var data []byte
+	var err error
+	if m, ok := msg.(proto.Message); ok {
+		data, err = protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+	} else {
+		data, err = json.Marshal(msg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	req["message"] = v
+	return req, nil
+}
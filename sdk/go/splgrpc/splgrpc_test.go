@@ -0,0 +1,126 @@
+package splgrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+const testPolicy = `(and (= (get req "service") "grpc.health.v1.Health")
+  (or (not (get req "message")) (= (get (get req "message") "service") "payments")))`
+
+func dial(t *testing.T, opts Options) healthpb.HealthClient {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(opts)),
+		grpc.StreamInterceptor(StreamServerInterceptor(opts)),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("payments", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("admin", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func withToken(key, value string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), key, value)
+}
+
+func TestUnaryInterceptor(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	tok, _ := spl.Mint(testPolicy, priv, spl.MintOptions{})
+	compact, _ := tok.Compact()
+	var decisions int
+	client := dial(t, Options{OnDecision: func(context.Context, string, spl.VerifyTokenResult) { decisions++ }})
+
+	ctx := withToken("authorization", "AgentSafe "+compact)
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "payments"}); err != nil {
+		t.Fatalf("expected allow, got %v", err)
+	}
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "admin"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "payments"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated without a token, got %v", err)
+	}
+	if decisions != 2 {
+		t.Fatalf("expected OnDecision for each verified call, got %d", decisions)
+	}
+
+	// A PoP-bound token needs the presentation signature.
+	agentPub, agentPriv := spl.GenerateKeypair()
+	bound, _ := spl.Mint(testPolicy, priv, spl.MintOptions{PoPKey: agentPub})
+	sig, _ := spl.CreatePresentationSignature(bound, agentPriv)
+	ctx = withToken(TokenKey, mustCompact(t, bound))
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "payments"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected a PoP denial, got %v", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, PresentationKey, sig)
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "payments"}); err != nil {
+		t.Fatalf("expected allow with a presentation signature, got %v", err)
+	}
+}
+
+func TestStreamInterceptor(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	tok, _ := spl.Mint(testPolicy, priv, spl.MintOptions{})
+	client := dial(t, Options{})
+	ctx := withToken("authorization", "Bearer "+mustCompact(t, tok))
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "payments"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := stream.Recv(); err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected a status update, got %v, %v", resp, err)
+	}
+
+	stream, err = client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected the message to be denied, got %v", err)
+	}
+}
+
+func TestRequestMap(t *testing.T) {
+	req, err := RequestMap("/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{Service: "payments"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req["service"] != "grpc.health.v1.Health" || req["rpc"] != "Check" || req["message"].(map[string]any)["service"] != "payments" {
+		t.Fatalf("unexpected request map %v", req)
+	}
+}
+
+func mustCompact(t *testing.T, tok *spl.Token) string {
+	t.Helper()
+	s, err := tok.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,282 @@
+package splhttp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// SignatureLabel labels the agent's signature in the RFC 9421
+// Signature-Input and Signature headers.
+const SignatureLabel = "agent-safe"
+
+// DefaultSignatureMaxAge bounds how old a request signature may be.
+const DefaultSignatureMaxAge = 5 * time.Minute
+
+// SignRequest prepares an outgoing request for a token bound to the agent's
+// Ed25519 key: it sets the token and presentation headers, a Content-Digest
+// (RFC 9530) of the body, and an RFC 9421 signature over the method, target
+// URI, digest and token. A verifier with RequireHTTPSignature then refuses
+// the token on any other endpoint or payload.
+func SignRequest(r *http.Request, tok *spl.Token, agentPrivateKeyHex string) error {
+	seed, err := hex.DecodeString(agentPrivateKeyHex)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return errors.New("agent private key must be a hex Ed25519 seed")
+	}
+	raw, err := tok.Compact()
+	if err != nil {
+		return err
+	}
+	pop, err := spl.CreatePresentationSignature(tok, agentPrivateKeyHex)
+	if err != nil {
+		return err
+	}
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		r.ContentLength = int64(len(body))
+	}
+	r.Header.Set(TokenHeader, raw)
+	r.Header.Set(PresentationHeader, pop)
+	r.Header.Set("Content-Digest", contentDigest(body))
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	target := r.URL.Scheme + "://" + host + r.URL.RequestURI()
+	components := []string{"@method", "@target-uri", "content-digest", strings.ToLower(TokenHeader)}
+	params := fmt.Sprintf("(%s);created=%d;keyid=%q;alg=\"ed25519\"",
+		quoteAll(components), time.Now().Unix(), tok.PoPKey)
+	base := signatureBase(components, func(c string) string {
+		switch c {
+		case "@method":
+			return r.Method
+		case "@target-uri":
+			return target
+		}
+		return r.Header.Get(c)
+	}, params)
+	sig := ed25519.Sign(ed25519.NewKeyFromSeed(seed), []byte(base))
+	r.Header.Set("Signature-Input", SignatureLabel+"="+params)
+	r.Header.Set("Signature", SignatureLabel+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+func contentDigest(body []byte) string {
+	h := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(h[:]) + ":"
+}
+
+func quoteAll(ss []string) string {
+	q := make([]string, len(ss))
+	for i, s := range ss {
+		q[i] = strconv.Quote(s)
+	}
+	return strings.Join(q, " ")
+}
+
+// signatureBase builds the RFC 9421 signature base for the covered
+// components, whose values value supplies, and the serialized signature
+// parameters.
+func signatureBase(components []string, value func(string) string, params string) string {
+	var b strings.Builder
+	for _, c := range components {
+		fmt.Fprintf(&b, "%q: %s\n", c, strings.TrimSpace(value(c)))
+	}
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", params)
+	return b.String()
+}
+
+// verifyHTTPSignature checks the request's agent-safe signature against the
+// token's PoP key. body is the request body already read.
+func verifyHTTPSignature(r *http.Request, tok *spl.Token, body []byte, opts *Options, now time.Time) error {
+	if tok.PoPKey == "" {
+		return errors.New("token has no pop_key to verify a signature with")
+	}
+	params, ok := dictMember(r.Header.Get("Signature-Input"), SignatureLabel)
+	if !ok {
+		return errors.New("no agent-safe Signature-Input")
+	}
+	sigItem, ok := dictMember(r.Header.Get("Signature"), SignatureLabel)
+	if !ok || len(sigItem) < 2 || sigItem[0] != ':' || sigItem[len(sigItem)-1] != ':' {
+		return errors.New("no agent-safe Signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigItem[1 : len(sigItem)-1])
+	if err != nil {
+		return errors.New("malformed Signature")
+	}
+
+	components, p, err := parseSignatureParams(params)
+	if err != nil {
+		return err
+	}
+	// The signature must cover the header the token was taken from.
+	tokenComponent := strings.ToLower(TokenHeader)
+	if _, ok := tokenFromAuthorization(r); ok {
+		tokenComponent = "authorization"
+	}
+	for _, need := range []string{"@method", "@target-uri", "content-digest", tokenComponent} {
+		if !contains(components, need) {
+			return fmt.Errorf("signature does not cover %s", need)
+		}
+	}
+	if alg, ok := p["alg"]; ok && alg != "ed25519" {
+		return fmt.Errorf("unsupported signature alg %q", alg)
+	}
+	if keyid, ok := p["keyid"]; ok && keyid != tok.PoPKey {
+		return errors.New("signature keyid is not the token's pop_key")
+	}
+	created, err := strconv.ParseInt(p["created"], 10, 64)
+	if err != nil {
+		return errors.New("signature has no created time")
+	}
+	maxAge := opts.SignatureMaxAge
+	if maxAge == 0 {
+		maxAge = DefaultSignatureMaxAge
+	}
+	if at := time.Unix(created, 0); now.Sub(at) > maxAge || at.Sub(now) > time.Minute {
+		return errors.New("signature is too old or from the future")
+	}
+	if exp, ok := p["expires"]; ok {
+		if e, err := strconv.ParseInt(exp, 10, 64); err != nil || !now.Before(time.Unix(e, 0)) {
+			return errors.New("signature expired")
+		}
+	}
+	if r.Header.Get("Content-Digest") != contentDigest(body) {
+		// Only sha-256 is produced by SignRequest; anything else is refused.
+		return errors.New("Content-Digest does not match the body")
+	}
+
+	var pub []byte
+	if strings.HasPrefix(tok.PoPKey, "did:key:") {
+		alg, pubHex, err := spl.DIDKeyToPublicKey(tok.PoPKey)
+		if err != nil || alg != spl.AlgEd25519 {
+			return errors.New("pop_key must be an Ed25519 key")
+		}
+		pub, _ = hex.DecodeString(pubHex)
+	} else {
+		pub, _ = hex.DecodeString(tok.PoPKey)
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return errors.New("pop_key must be an Ed25519 key")
+	}
+	target := targetURI(r)
+	if opts.TargetURI != nil {
+		target = opts.TargetURI(r)
+	}
+	base := signatureBase(components, func(c string) string {
+		switch c {
+		case "@method":
+			return r.Method
+		case "@target-uri":
+			return target
+		}
+		return strings.Join(r.Header.Values(c), ", ")
+	}, params)
+	if !ed25519.Verify(pub, []byte(base), sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// targetURI reconstructs the URI the client addressed.
+func targetURI(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// dictMember returns the raw value of the label member of a structured
+// field dictionary, such as Signature-Input.
+func dictMember(field, label string) (string, bool) {
+	for _, m := range splitTopLevel(field) {
+		name, value, ok := strings.Cut(strings.TrimSpace(m), "=")
+		if ok && name == label {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// splitTopLevel splits a header on commas outside quotes and parentheses.
+func splitTopLevel(s string) []string {
+	var out []string
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == '(' && !quoted:
+			depth++
+		case c == ')' && !quoted:
+			depth--
+		case c == ',' && !quoted && depth == 0:
+			out = append(out, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, s[start:])
+}
+
+// parseSignatureParams parses an inner list of quoted component names and
+// its parameters, e.g. ("@method" "content-digest");created=1;keyid="k".
+func parseSignatureParams(s string) ([]string, map[string]string, error) {
+	bad := errors.New("malformed Signature-Input")
+	if !strings.HasPrefix(s, "(") {
+		return nil, nil, bad
+	}
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, nil, bad
+	}
+	var components []string
+	for _, f := range strings.Fields(s[1:end]) {
+		c, err := strconv.Unquote(f)
+		if err != nil {
+			return nil, nil, bad
+		}
+		components = append(components, c)
+	}
+	params := map[string]string{}
+	for _, p := range strings.Split(s[end+1:], ";")[1:] {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			continue
+		}
+		if u, err := strconv.Unquote(v); err == nil {
+			v = u
+		}
+		params[k] = v
+	}
+	return components, params, nil
+}
@@ -0,0 +1,111 @@
+package splhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func signed(t *testing.T, srv *httptest.Server, tok *spl.Token, agentPriv, path, body string) *http.Request {
+	t.Helper()
+	req, _ := http.NewRequest("POST", srv.URL+path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if err := SignRequest(req, tok, agentPriv); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func send(t *testing.T, req *http.Request) int {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestHTTPSignatureBinding(t *testing.T) {
+	_, issuer := spl.GenerateKeypair()
+	agentPub, agentPriv := spl.GenerateKeypair()
+	tok, _ := spl.Mint(`(= (get req "method") "POST")`, issuer, spl.MintOptions{PoPKey: agentPub})
+	srv := newServer(t, Options{RequireHTTPSignature: true})
+
+	if code := send(t, signed(t, srv, tok, agentPriv, "/pay", `{"amount": 20}`)); code != http.StatusOK {
+		t.Fatalf("expected a signed request to be allowed, got %d", code)
+	}
+
+	// The same headers replayed against another endpoint or payload.
+	orig := signed(t, srv, tok, agentPriv, "/pay", `{"amount": 20}`)
+	for name, replay := range map[string]*http.Request{
+		"other endpoint": mustRequest("POST", srv.URL+"/refund", `{"amount": 20}`),
+		"other payload":  mustRequest("POST", srv.URL+"/pay", `{"amount": 2000}`),
+		"other method":   mustRequest("PUT", srv.URL+"/pay", `{"amount": 20}`),
+	} {
+		replay.Header = orig.Header.Clone()
+		if code := send(t, replay); code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", name, code)
+		}
+	}
+
+	unsigned := mustRequest("POST", srv.URL+"/pay", `{}`)
+	compact, _ := tok.Compact()
+	pop, _ := spl.CreatePresentationSignature(tok, agentPriv)
+	unsigned.Header.Set(TokenHeader, compact)
+	unsigned.Header.Set(PresentationHeader, pop)
+	if code := send(t, unsigned); code != http.StatusForbidden {
+		t.Fatalf("expected an unsigned request to be refused, got %d", code)
+	}
+
+	bearer, _ := spl.Mint(`(= 1 1)`, issuer, spl.MintOptions{})
+	_, otherPriv := spl.GenerateKeypair()
+	if code := send(t, signed(t, srv, bearer, otherPriv, "/pay", `{}`)); code != http.StatusForbidden {
+		t.Fatalf("expected a token without pop_key to be refused, got %d", code)
+	}
+	if code := send(t, signed(t, srv, tok, otherPriv, "/pay", `{}`)); code != http.StatusForbidden {
+		t.Fatalf("expected another key's signature to be refused, got %d", code)
+	}
+
+	// Without RequireHTTPSignature, a present signature is still checked.
+	lax := newServer(t, Options{})
+	req := signed(t, lax, tok, agentPriv, "/pay", `{}`)
+	req.Header.Set("Content-Digest", contentDigest([]byte("other")))
+	if code := send(t, req); code != http.StatusForbidden {
+		t.Fatalf("expected a bad digest to be refused, got %d", code)
+	}
+}
+
+func TestHTTPSignatureAge(t *testing.T) {
+	_, issuer := spl.GenerateKeypair()
+	agentPub, agentPriv := spl.GenerateKeypair()
+	tok, _ := spl.Mint(`(= 1 1)`, issuer, spl.MintOptions{PoPKey: agentPub})
+	req := mustRequest("GET", "http://api.example/items?x=1", "")
+	if err := SignRequest(req, tok, agentPriv); err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "api.example"
+	opts := &Options{}
+	if err := verifyHTTPSignature(req, tok, nil, opts, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyHTTPSignature(req, tok, nil, opts, time.Now().Add(10*time.Minute)); err == nil {
+		t.Fatal("expected an old signature to be refused")
+	}
+	opts.TargetURI = func(*http.Request) string { return "https://api.example/items?x=1" }
+	if err := verifyHTTPSignature(req, tok, nil, opts, time.Now()); err == nil {
+		t.Fatal("expected a different target URI to be refused")
+	}
+}
+
+func mustRequest(method, url, body string) *http.Request {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	return req
+}
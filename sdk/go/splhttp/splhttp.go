@@ -0,0 +1,375 @@
+// Package splhttp gates net/http handlers with Agent-Safe capability tokens.
+//
+// Middleware reads the caller's token from the request headers, describes
+// the HTTP request to the token's policy, and lets the request through only
+// when the policy allows it:
+//
+//	gate := splhttp.Middleware(splhttp.Options{
+//		Verify: spl.VerifyTokenOptions{KeyResolver: issuers},
+//	})
+//	http.ListenAndServe(":8080", gate(mux))
+//
+// The policy sees req as RequestToMap describes it,
+//
+//	{"method": "POST", "host": "api.example.com", "path": "/v1/payments",
+//	 "query": {"dry_run": "1"}, "content_digest": "sha-256=:...:",
+//	 "body": {"amount": 50, ...}}
+//
+// so it can say, for example,
+//
+//	(and (= (get req "method") "POST")
+//	     (<= (get (get req "body") "amount") 50))
+package splhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Request headers. A token may be sent as "Authorization: AgentSafe <token>"
+// (or Bearer) or in TokenHeader, in JSON or compact form; a token bound to
+// a proof-of-possession key also needs PresentationHeader.
+const (
+	TokenHeader        = "Agent-Safe-Token"
+	PresentationHeader = "Agent-Safe-Presentation"
+)
+
+// DefaultMaxBodyBytes bounds the request body read for the policy.
+const DefaultMaxBodyBytes = 1 << 20
+
+// ErrUnsupportedBody is returned by RequestToMap for a body whose
+// Content-Type is not JSON. A limit on a body field would otherwise see no
+// body and hold, while a backend that parses the body regardless acts on
+// it. Middleware answers it with 415.
+var ErrUnsupportedBody = errors.New("request body is not JSON")
+
+// Options configures Middleware.
+type Options struct {
+	// Verify configures token verification: trust anchors, vars, counters
+	// and crypto callbacks. PresentationSignature is taken from each
+	// request's PresentationHeader instead.
+	Verify spl.VerifyTokenOptions
+	// MaxBodyBytes bounds the JSON body read into req; larger requests get
+	// 413. Zero means DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+	// OpaqueBodies admits requests whose body is not JSON; see MapOptions.
+	OpaqueBodies bool
+	// Request, when set, adjusts the request map built from r before the
+	// policy sees it, e.g. to add the authenticated user or a path
+	// parameter.
+	Request func(r *http.Request, req map[string]any)
+	// OnDecision, when set, is called with every verification result, for
+	// logging and metrics.
+	OnDecision func(r *http.Request, res spl.VerifyTokenResult)
+
+	// RequireHTTPSignature refuses tokens unless the request carries an
+	// RFC 9421 signature by the token's Ed25519 pop_key over its method,
+	// target URI, Content-Digest and token, as SignRequest makes, so that
+	// a captured token cannot be replayed against another endpoint or
+	// payload. Without it, such a signature is checked only when present.
+	RequireHTTPSignature bool
+	// SignatureMaxAge bounds the age of a request signature. Zero means
+	// DefaultSignatureMaxAge.
+	SignatureMaxAge time.Duration
+	// TargetURI, when set, returns the URI the client addressed, for
+	// servers behind a proxy that rewrites the scheme or host.
+	TargetURI func(r *http.Request) string
+
+	// Result, when set, is checked against each response body before it
+	// is sent: the request it sees is the request map, and the result is
+	// the body, parsed when it is JSON. A response it denies is replaced
+	// with a 403, unless Result.ReportOnly is set. The next handler's
+	// response is buffered in full to be checked.
+	Result *spl.ResultPolicy
+	// OnResult, when set, is called with the outcome of each Result check,
+	// nil when the response passed, for logging violations.
+	OnResult func(r *http.Request, err error)
+}
+
+// Denial is the JSON body of a 401 or 403 response.
+type Denial struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+type contextKey struct{}
+
+// TokenFromContext returns the token that authorized the request, in a
+// handler behind Middleware.
+func TokenFromContext(ctx context.Context) (*spl.Token, bool) {
+	t, ok := ctx.Value(contextKey{}).(*spl.Token)
+	return t, ok
+}
+
+// Middleware returns a wrapper that verifies each request's token against
+// the request before calling the next handler. Requests without a token get
+// 401, malformed ones 400, those with a body that is not JSON 415, and
+// denied ones 403, each with a Denial body.
+// The body is read for the policy and restored for the next handler.
+func Middleware(opts Options) func(http.Handler) http.Handler {
+	verifier := spl.NewVerifier(spl.VerifierConfig{VerifyTokenOptions: opts.Verify})
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := TokenFromRequest(r)
+			if raw == "" {
+				w.Header().Set("WWW-Authenticate", "AgentSafe")
+				deny(w, http.StatusUnauthorized, "unauthorized", "no Agent-Safe token")
+				return
+			}
+			tok, err := spl.ParseToken(raw)
+			if err != nil {
+				deny(w, http.StatusBadRequest, "bad_request", err.Error())
+				return
+			}
+			req, err := RequestToMap(r, MapOptions{MaxBodyBytes: opts.MaxBodyBytes, OpaqueBodies: opts.OpaqueBodies})
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					deny(w, http.StatusRequestEntityTooLarge, "bad_request", "request body too large")
+				} else if errors.Is(err, ErrUnsupportedBody) {
+					deny(w, http.StatusUnsupportedMediaType, "unsupported_media_type", err.Error())
+				} else {
+					deny(w, http.StatusBadRequest, "bad_request", err.Error())
+				}
+				return
+			}
+			if opts.Request != nil {
+				opts.Request(r, req)
+			}
+			var res spl.VerifyTokenResult
+			if opts.RequireHTTPSignature || r.Header.Get("Signature-Input") != "" {
+				res = checkHTTPSignature(r, tok, &opts)
+			}
+			if res.Err == nil {
+				res = verifier.VerifyPresented(r.Context(), tok, req, r.Header.Get(PresentationHeader))
+			}
+			if opts.OnDecision != nil {
+				opts.OnDecision(r, res)
+			}
+			if !res.Allow {
+				reason := res.ErrorMessage()
+				if reason == "" {
+					reason = "policy denied the request"
+				}
+				deny(w, http.StatusForbidden, "forbidden", reason)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), contextKey{}, tok))
+			if opts.Result == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			resp := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+			next.ServeHTTP(resp, r)
+			err = opts.Result.Check(req, resp.result())
+			if opts.OnResult != nil {
+				opts.OnResult(r, err)
+			}
+			if err != nil && !opts.Result.ReportOnly {
+				deny(w, http.StatusForbidden, "forbidden", err.Error())
+				return
+			}
+			resp.writeTo(w)
+		})
+	}
+}
+
+// bufferedResponse holds a handler's response until Options.Result has
+// checked it.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+
+// result is the body as a ResultPolicy sees it: parsed when it is JSON,
+// nil when it is empty, and a string otherwise.
+func (b *bufferedResponse) result() any {
+	data := b.body.Bytes()
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	var v any
+	if isJSON(b.header.Get("Content-Type")) && json.Unmarshal(data, &v) == nil {
+		return v
+	}
+	return string(data)
+}
+
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	for k, vs := range b.header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+// checkHTTPSignature verifies the request signature, returning a denying
+// result on failure and the zero result on success.
+func checkHTTPSignature(r *http.Request, tok *spl.Token, opts *Options) spl.VerifyTokenResult {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		body, _ = io.ReadAll(r.Body) // already buffered by RequestMap
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if err := verifyHTTPSignature(r, tok, body, opts, time.Now()); err != nil {
+		err = spl.ErrBadSignature.Wrap(fmt.Errorf("HTTP signature: %w", err))
+		return spl.VerifyTokenResult{Sealed: tok.Sealed, Err: err, Code: spl.CodeBadSignature}
+	}
+	return spl.VerifyTokenResult{}
+}
+
+// TokenFromRequest returns the raw token from the Authorization header
+// (scheme AgentSafe or Bearer) or TokenHeader, or "" if there is none.
+func TokenFromRequest(r *http.Request) string {
+	if tok, ok := tokenFromAuthorization(r); ok {
+		return tok
+	}
+	return strings.TrimSpace(r.Header.Get(TokenHeader))
+}
+
+func tokenFromAuthorization(r *http.Request) (string, bool) {
+	scheme, tok, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && (strings.EqualFold(scheme, "AgentSafe") || strings.EqualFold(scheme, "Bearer")) {
+		return strings.TrimSpace(tok), true
+	}
+	return "", false
+}
+
+// MapOptions configures RequestToMap.
+type MapOptions struct {
+	// MaxBodyBytes bounds the body read. Zero means DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+	// OpaqueBodies describes a body that is not JSON by its content_digest
+	// alone, rather than failing with ErrUnsupportedBody. Only endpoints
+	// whose policies read no body field should set it.
+	OpaqueBodies bool
+}
+
+// RequestToMap describes r as an SPL request, the same way wherever an
+// HTTP request meets a policy: in Middleware, in the CLI's enforcing
+// proxy, and in agent-safe verify-token --http-request. The keys are
+//
+//   - method: the method, in upper case
+//   - host: the host the client addressed, in lower case, without the
+//     scheme's default port
+//   - path: the decoded path, "/" when empty
+//   - query: each decoded parameter's value, a string, or a list in the
+//     order given when the parameter repeats
+//   - content_digest: the body's RFC 9530 Content-Digest,
+//     "sha-256=:<base64>:", when there is a body
+//   - body: the parsed body
+//
+// so a policy reads a body field as (get (get req "body") "amount"). A
+// body must be JSON, by its Content-Type or in the absence of one:
+// RequestToMap fails with ErrUnsupportedBody for any other, unless
+// opts.OpaqueBodies is set, and with an error for JSON that does not
+// parse. It reads at most opts.MaxBodyBytes of body and leaves r.Body
+// readable again.
+func RequestToMap(r *http.Request, opts MapOptions) (map[string]any, error) {
+	limit := opts.MaxBodyBytes
+	if limit == 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	query := map[string]any{}
+	for k, vs := range r.URL.Query() {
+		if len(vs) == 1 {
+			query[k] = vs[0]
+			continue
+		}
+		list := make([]any, len(vs))
+		for i, v := range vs {
+			list[i] = v
+		}
+		query[k] = list
+	}
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	req := map[string]any{
+		"method": strings.ToUpper(r.Method),
+		"host":   canonicalHost(r),
+		"path":   path,
+		"query":  query,
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return req, nil
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, limit))
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if len(data) > 0 {
+		req["content_digest"] = contentDigest(data)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return req, nil
+	}
+	if ct := r.Header.Get("Content-Type"); !isJSON(ct) {
+		if opts.OpaqueBodies {
+			return req, nil
+		}
+		return nil, fmt.Errorf("%w: Content-Type %s", ErrUnsupportedBody, ct)
+	}
+	var body any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, errors.New("invalid JSON body: " + err.Error())
+	}
+	req["body"] = body
+	return req, nil
+}
+
+// RequestMap is RequestToMap with a body limit of limit bytes.
+func RequestMap(r *http.Request, limit int64) (map[string]any, error) {
+	return RequestToMap(r, MapOptions{MaxBodyBytes: limit})
+}
+
+// canonicalHost returns the host r addressed, in lower case and without
+// the default port of its scheme.
+func canonicalHost(r *http.Request) string {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	host = strings.ToLower(host)
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if (r.TLS == nil && port == "80") || (r.TLS != nil && port == "443") {
+			return h
+		}
+	}
+	return host
+}
+
+// isJSON reports whether a Content-Type is JSON; an absent one is taken
+// to be.
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+func deny(w http.ResponseWriter, status int, code, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Denial{Error: code, Reason: reason})
+}
@@ -0,0 +1,196 @@
+package splhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+const testPolicy = `(and (= (get req "method") "POST")
+  (= (get req "path") "/pay")
+  (<= (get (get req "body") "amount") 50))`
+
+func newServer(t *testing.T, opts Options) *httptest.Server {
+	t.Helper()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := TokenFromContext(r.Context()); !ok {
+			t.Error("expected the token in the context")
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+	srv := httptest.NewServer(Middleware(opts)(handler))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func call(t *testing.T, srv *httptest.Server, method, path, body string, header http.Header) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestMiddleware(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	tok, _ := spl.Mint(testPolicy, priv, spl.MintOptions{})
+	compact, _ := tok.Compact()
+	var decisions int
+	srv := newServer(t, Options{OnDecision: func(*http.Request, spl.VerifyTokenResult) { decisions++ }})
+	auth := http.Header{"Authorization": {"AgentSafe " + compact}}
+
+	if code, body := call(t, srv, "POST", "/pay", `{"amount": 20}`, auth); code != http.StatusOK || body != `{"amount": 20}` {
+		t.Fatalf("expected allow with the body passed through, got %d %q", code, body)
+	}
+	code, body := call(t, srv, "POST", "/pay", `{"amount": 80}`, auth)
+	var d Denial
+	if err := json.Unmarshal([]byte(body), &d); err != nil || code != http.StatusForbidden || d.Error != "forbidden" {
+		t.Fatalf("expected 403, got %d %q", code, body)
+	}
+	if code, _ := call(t, srv, "GET", "/pay", "", auth); code != http.StatusForbidden {
+		t.Fatalf("expected GET to be denied, got %d", code)
+	}
+	if code, _ := call(t, srv, "POST", "/pay", `{"amount": 20}`, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %d", code)
+	}
+	if code, _ := call(t, srv, "POST", "/pay", `{"amount":`, auth); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a malformed body, got %d", code)
+	}
+	// A body the policy cannot read is refused, not passed on unchecked.
+	form := http.Header{"Authorization": auth["Authorization"], "Content-Type": {"text/plain"}}
+	if code, _ := call(t, srv, "POST", "/pay", `{"amount": 80}`, form); code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for a body that is not JSON, got %d", code)
+	}
+	if decisions != 3 {
+		t.Fatalf("expected OnDecision for each verified request, got %d", decisions)
+	}
+
+	// The JSON form in Agent-Safe-Token works too.
+	data, _ := json.Marshal(tok)
+	if code, _ := call(t, srv, "POST", "/pay", `{"amount": 50}`, http.Header{TokenHeader: {string(data)}}); code != http.StatusOK {
+		t.Fatalf("expected allow via %s, got %d", TokenHeader, code)
+	}
+}
+
+func TestMiddlewarePoPAndLimits(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	agentPub, agentPriv := spl.GenerateKeypair()
+	tok, _ := spl.Mint(testPolicy, priv, spl.MintOptions{PoPKey: agentPub})
+	compact, _ := tok.Compact()
+	sig, _ := spl.CreatePresentationSignature(tok, agentPriv)
+	srv := newServer(t, Options{MaxBodyBytes: 64})
+
+	header := http.Header{"Authorization": {"Bearer " + compact}}
+	if code, body := call(t, srv, "POST", "/pay", `{"amount": 20}`, header); code != http.StatusForbidden || !strings.Contains(body, "presentation signature") {
+		t.Fatalf("expected a PoP denial, got %d %q", code, body)
+	}
+	header.Set(PresentationHeader, sig)
+	if code, _ := call(t, srv, "POST", "/pay", `{"amount": 20}`, header); code != http.StatusOK {
+		t.Fatalf("expected allow with a presentation signature, got %d", code)
+	}
+	big := `{"amount": 20, "memo": "` + strings.Repeat("x", 100) + `"}`
+	if code, _ := call(t, srv, "POST", "/pay", big, header); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", code)
+	}
+}
+
+func TestResultPolicy(t *testing.T) {
+	_, priv := spl.GenerateKeypair()
+	tok, _ := spl.Mint(testPolicy, priv, spl.MintOptions{})
+	compact, _ := tok.Compact()
+	header := http.Header{"Authorization": {"AgentSafe " + compact}, "Content-Type": {"application/json"}}
+	var violations []error
+	opts := Options{
+		Result:   &spl.ResultPolicy{Policy: `(and (= (get (get req "request") "path") "/pay") (<= (get (get req "result") "count") 2))`},
+		OnResult: func(_ *http.Request, err error) { violations = append(violations, err) },
+	}
+	srv := newServer(t, opts)
+	// The handler echoes the request body as its response.
+	if code, body := call(t, srv, "POST", "/pay", `{"amount": 5, "rows": [1, 2]}`, header); code != http.StatusOK || !strings.Contains(body, "rows") {
+		t.Fatalf("expected the response through, got %d %s", code, body)
+	}
+	code, body := call(t, srv, "POST", "/pay", `{"amount": 5, "rows": [1, 2, 3]}`, header)
+	if code != http.StatusForbidden || strings.Contains(body, "rows") {
+		t.Fatalf("expected the response withheld, got %d %s", code, body)
+	}
+	if len(violations) != 2 || violations[0] != nil || !errors.Is(violations[1], spl.ErrResult) {
+		t.Fatalf("unexpected OnResult calls: %v", violations)
+	}
+
+	opts.Result.ReportOnly = true
+	srv = newServer(t, opts)
+	if code, _ := call(t, srv, "POST", "/pay", `{"amount": 5, "rows": [1, 2, 3]}`, header); code != http.StatusOK {
+		t.Fatalf("expected a reported response through, got %d", code)
+	}
+	if !errors.Is(violations[2], spl.ErrResult) {
+		t.Fatalf("expected the violation reported, got %v", violations[2])
+	}
+}
+
+func TestRequestMap(t *testing.T) {
+	r := httptest.NewRequest("PUT", "/items/7?tag=a&tag=b&dry_run=1", strings.NewReader(`{"n": 1}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req, err := RequestMap(r, DefaultMaxBodyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := req["query"].(map[string]any)
+	if req["method"] != "PUT" || req["path"] != "/items/7" || q["dry_run"] != "1" || len(q["tag"].([]any)) != 2 {
+		t.Fatalf("unexpected request map %v", req)
+	}
+	if req["body"].(map[string]any)["n"] != 1.0 {
+		t.Fatalf("expected the JSON body, got %v", req["body"])
+	}
+
+	r = httptest.NewRequest("POST", "/upload", strings.NewReader("not json"))
+	r.Header.Set("Content-Type", "text/plain")
+	if _, err := RequestMap(r, DefaultMaxBodyBytes); !errors.Is(err, ErrUnsupportedBody) {
+		t.Fatalf("expected a non-JSON body to be refused, got %v", err)
+	}
+	r = httptest.NewRequest("POST", "/upload", strings.NewReader("not json"))
+	r.Header.Set("Content-Type", "text/plain")
+	if req, err := RequestToMap(r, MapOptions{OpaqueBodies: true}); err != nil || req["body"] != nil || req["content_digest"] == nil {
+		t.Fatalf("expected an opaque body to be described by its digest, got %v, %v", req, err)
+	}
+}
+
+func TestRequestToMap(t *testing.T) {
+	r := httptest.NewRequest("post", "http://API.Example.com:80/pay?to=a%20b", strings.NewReader(`{"amount": 5}`))
+	req, err := RequestToMap(r, MapOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req["method"] != "POST" || req["host"] != "api.example.com" || req["query"].(map[string]any)["to"] != "a b" {
+		t.Fatalf("unexpected request map %v", req)
+	}
+	if req["content_digest"] != contentDigest([]byte(`{"amount": 5}`)) {
+		t.Fatalf("unexpected content digest %v", req["content_digest"])
+	}
+	if body, _ := io.ReadAll(r.Body); string(body) != `{"amount": 5}` {
+		t.Fatalf("expected the body to be readable again, got %q", body)
+	}
+
+	r = httptest.NewRequest("GET", "https://api.example.com:8443/", nil)
+	if req, _ := RequestToMap(r, MapOptions{}); req["host"] != "api.example.com:8443" || req["content_digest"] != nil {
+		t.Fatalf("unexpected request map %v", req)
+	}
+	r = httptest.NewRequest("PUT", "/blob", strings.NewReader("0123456789"))
+	var tooLarge *http.MaxBytesError
+	if _, err := RequestToMap(r, MapOptions{MaxBodyBytes: 4}); !errors.As(err, &tooLarge) {
+		t.Fatalf("expected the body limit to apply, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+// Package splotel reports token verification to OpenTelemetry, so its
+// latency shows up inside existing distributed traces:
+//
+//	opts.Tracer = splotel.Tracer(r.Context(), otel.GetTracerProvider())
+//	res := spl.VerifyTokenObj(tok, req, opts)
+//
+// The agent-safe.verify span is a child of the span in the context, with
+// children for the signature check, PoP check, policy parse and policy
+// evaluation; a denying evaluation carries the failing clause as its
+// agent_safe.failing_clause attribute. It lives in its own module so that
+// the SDK itself stays free of the OpenTelemetry dependency.
+package splotel
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// InstrumentationName names the tracer spans are created with.
+const InstrumentationName = "github.com/jmcentire/agent-safe/sdk/go"
+
+// Tracer returns an spl.Tracer whose spans are children of the span in ctx,
+// created by tp. A Tracer is for one verification, since it carries ctx.
+func Tracer(ctx context.Context, tp trace.TracerProvider) spl.Tracer {
+	return &span{ctx: ctx, tracer: tp.Tracer(InstrumentationName)}
+}
+
+// span is an OpenTelemetry span; the zero span, holding only a context,
+// is the root the first span is started from.
+type span struct {
+	ctx    context.Context
+	tracer trace.Tracer
+	span   trace.Span
+}
+
+func (s *span) Start(name string) spl.Span {
+	ctx, child := s.tracer.Start(s.ctx, name, trace.WithSpanKind(trace.SpanKindInternal))
+	return &span{ctx: ctx, tracer: s.tracer, span: child}
+}
+
+func (s *span) SetAttribute(key string, value any) {
+	if s.span == nil {
+		return
+	}
+	var kv attribute.KeyValue
+	switch v := value.(type) {
+	case bool:
+		kv = attribute.Bool(key, v)
+	case int:
+		kv = attribute.Int(key, v)
+	case int64:
+		kv = attribute.Int64(key, v)
+	case float64:
+		kv = attribute.Float64(key, v)
+	case string:
+		kv = attribute.String(key, v)
+	default:
+		kv = attribute.String(key, fmt.Sprint(v))
+	}
+	s.span.SetAttributes(kv)
+}
+
+func (s *span) End(err error) {
+	if s.span == nil {
+		return
+	}
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
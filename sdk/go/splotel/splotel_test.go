@@ -0,0 +1,55 @@
+package splotel
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func TestTracer(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "handler")
+
+	_, priv := spl.GenerateKeypair()
+	tok, err := spl.Mint(`(and (<= (per-day-count "pay" "2026-01-31") 2) (= (get req "action") "pay"))`, priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := spl.VerifyTokenObj(tok, map[string]any{"action": "refund"}, spl.VerifyTokenOptions{
+		PerDayCount: func(string, string) int { return 1 },
+		Tracer:      Tracer(ctx, tp),
+	})
+	parent.End()
+	if res.Allow {
+		t.Fatal("expected a deny")
+	}
+
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range rec.Ended() {
+		byName[s.Name()] = s
+	}
+	verify := byName["agent-safe.verify"]
+	if verify == nil || verify.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("expected agent-safe.verify under the handler span, got %v", byName)
+	}
+	for _, name := range []string{"agent-safe.signature", "agent-safe.parse", "agent-safe.eval"} {
+		if s := byName[name]; s == nil || s.Parent().SpanID() != verify.SpanContext().SpanID() {
+			t.Fatalf("expected %s under agent-safe.verify", name)
+		}
+	}
+	if s := byName["agent-safe.counter"]; s == nil || s.Parent().SpanID() != byName["agent-safe.eval"].SpanContext().SpanID() {
+		t.Fatal("expected agent-safe.counter under agent-safe.eval")
+	}
+	attrs := map[string]string{}
+	for _, kv := range append(verify.Attributes(), byName["agent-safe.eval"].Attributes()...) {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs[spl.AttrAllow] != "false" || attrs[spl.AttrFailingClause] != `(= (get req "action") "pay")` {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+}
@@ -0,0 +1,431 @@
+package spltest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// GeneratedRequest is a request derived from a policy's constraints.
+type GeneratedRequest struct {
+	Name    string         `json:"name"`
+	Request map[string]any `json:"request"`
+}
+
+// candidate is a value to try for a request field.
+type candidate struct {
+	value  any
+	absent bool // leave the field out of the request
+	note   string
+}
+
+// field is a request field the policy reads, as a path of keys under req,
+// with the values worth trying for it. Values that satisfy the constraint
+// they came from come first.
+type field struct {
+	path       []string
+	candidates []candidate
+}
+
+// GenerateRequests derives up to count requests from the constraints policy
+// places on req: numbers at, just inside and just past each limit, members
+// and non-members of each list, both sides of each deadline, and so on. The
+// first request gives every field a value that satisfies its first
+// constraint; each of the next varies one field; any beyond that combine
+// values at random, deterministically for a given seed. Symbols found in
+// vars are resolved, so limits and lists may be held in variables.
+//
+// The requests are meant to seed test suites and fuzzers: nothing checks
+// that the first request is allowed, since constraints may conflict or
+// involve counters and crypto predicates.
+func GenerateRequests(policy spl.Node, vars map[string]any, count int, seed int64) []GeneratedRequest {
+	g := &generator{vars: vars, byKey: map[string]*field{}}
+	g.walk(policy, true)
+	if count <= 0 {
+		return nil
+	}
+	base := map[string]candidate{}
+	for _, f := range g.fields {
+		base[pathKey(f.path)] = f.candidates[0]
+	}
+	seen := map[string]bool{}
+	var out []GeneratedRequest
+	add := func(name string, values map[string]candidate) {
+		req := g.build(values)
+		key, _ := json.Marshal(req)
+		if len(out) < count && !seen[string(key)] {
+			seen[string(key)] = true
+			out = append(out, GeneratedRequest{Name: name, Request: req})
+		}
+	}
+	add("baseline", base)
+	for _, f := range g.fields {
+		k := pathKey(f.path)
+		for _, c := range f.candidates {
+			values := make(map[string]candidate, len(base))
+			for k, v := range base {
+				values[k] = v
+			}
+			values[k] = c
+			add(describe(f.path, c), values)
+		}
+	}
+	// Random combinations, bounded so that a policy with few distinct
+	// requests cannot loop forever.
+	rng := rand.New(rand.NewSource(seed))
+	for tries := 0; len(out) < count && len(g.fields) > 0 && tries < 100*count; tries++ {
+		values := map[string]candidate{}
+		var parts []string
+		for _, f := range g.fields {
+			c := f.candidates[rng.Intn(len(f.candidates))]
+			values[pathKey(f.path)] = c
+			parts = append(parts, describe(f.path, c))
+		}
+		add(strings.Join(parts, ", "), values)
+	}
+	return out
+}
+
+func pathKey(path []string) string { return strings.Join(path, ".") }
+
+func describe(path []string, c candidate) string {
+	s := pathKey(path)
+	if c.absent {
+		s += " absent"
+	} else {
+		v, _ := json.Marshal(c.value)
+		s += " = " + string(v)
+	}
+	if c.note != "" {
+		s += " (" + c.note + ")"
+	}
+	return s
+}
+
+const notePlaceholder = "placeholder"
+
+type generator struct {
+	vars   map[string]any
+	fields []*field
+	byKey  map[string]*field
+}
+
+// build assembles a request from a value per field path.
+func (g *generator) build(values map[string]candidate) map[string]any {
+	req := map[string]any{}
+	for _, f := range g.fields {
+		c := values[pathKey(f.path)]
+		if c.absent {
+			continue
+		}
+		m := req
+		for _, k := range f.path[:len(f.path)-1] {
+			next, ok := m[k].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				m[k] = next
+			}
+			m = next
+		}
+		m[f.path[len(f.path)-1]] = c.value
+	}
+	return req
+}
+
+// add records candidates for the field at path. A placeholder stands in
+// only until the field meets a constraint.
+func (g *generator) add(path []string, cs ...candidate) {
+	k := pathKey(path)
+	f := g.byKey[k]
+	if f == nil {
+		f = &field{path: path}
+		g.byKey[k] = f
+		g.fields = append(g.fields, f)
+	}
+	if len(f.candidates) == 1 && f.candidates[0].note == notePlaceholder {
+		f.candidates = nil
+	}
+	for _, c := range cs {
+		if c.note == notePlaceholder && len(f.candidates) > 0 {
+			continue
+		}
+		dup := false
+		for _, x := range f.candidates {
+			if x.absent == c.absent && fmt.Sprint(x.value) == fmt.Sprint(c.value) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			f.candidates = append(f.candidates, c)
+		}
+	}
+}
+
+// walk collects candidates from n. clause is true when n is evaluated for
+// its truth value, so a bare field there must be truthy.
+func (g *generator) walk(n spl.Node, clause bool) {
+	if p, ok := fieldPath(n); ok && len(p) > 0 {
+		if clause {
+			g.add(p, candidate{value: true}, candidate{value: false, note: "falsy"}, candidate{absent: true})
+		} else {
+			g.add(p, candidate{value: "x", note: notePlaceholder})
+		}
+		return
+	}
+	l, ok := n.(spl.List)
+	if !ok || len(l) == 0 {
+		return
+	}
+	_, op, _ := l.Head()
+	args := l[1:]
+	switch op {
+	case "and", "or", "not":
+		for _, a := range args {
+			g.walk(a, true)
+		}
+		return
+	case "<", "<=", ">", ">=", "=":
+		if len(args) == 2 {
+			g.compare(op, args[0], args[1])
+		}
+	case "member", "in", "subset?", "not-member":
+		if len(args) == 2 {
+			g.membership(op, args[0], args[1])
+		}
+	case "before":
+		if len(args) == 2 {
+			g.before(args[0], args[1])
+		}
+	}
+	for _, a := range args {
+		g.walk(a, false)
+	}
+}
+
+// fieldPath returns the keys of a (get (get req "a") "b") chain.
+func fieldPath(n spl.Node) ([]string, bool) {
+	if name, _ := text(n); name == "req" {
+		return []string{}, true
+	}
+	l, ok := n.(spl.List)
+	if !ok || len(l) != 3 {
+		return nil, false
+	}
+	if op, _, _ := l.Head(); op != spl.OpGet {
+		return nil, false
+	}
+	parent, ok := fieldPath(l[1])
+	key, isStr := text(l[2])
+	if !ok || !isStr {
+		return nil, false
+	}
+	return append(append([]string{}, parent...), key), true
+}
+
+// constant resolves a literal, a variable or a tuple of constants.
+func (g *generator) constant(n spl.Node) (any, bool) {
+	switch v := n.(type) {
+	case spl.Num:
+		return float64(v), true
+	case spl.Bool:
+		return bool(v), true
+	case *spl.Symbol, spl.Str:
+		name, _ := text(v)
+		if val, ok := g.vars[name]; ok {
+			if set, ok := val.(*spl.Set); ok {
+				return set.Items(), true
+			}
+			return val, true
+		}
+		if name == "req" || name == "now" {
+			return nil, false
+		}
+		return name, true
+	case spl.List:
+		if op, _, _ := v.Head(); op != spl.OpTuple {
+			return nil, false
+		}
+		out := []any{}
+		for _, a := range v[1:] {
+			c, ok := g.constant(a)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, c)
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// text returns the name of a symbol or the value of a string, which
+// evaluate alike.
+func text(n spl.Node) (string, bool) {
+	switch v := n.(type) {
+	case *spl.Symbol:
+		return v.Name, true
+	case spl.Str:
+		return string(v), true
+	}
+	return "", false
+}
+
+var flippedOp = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<=", "=": "="}
+
+func (g *generator) compare(op string, a, b spl.Node) {
+	p, ok := fieldPath(a)
+	c, cok := g.constant(b)
+	if !ok || !cok {
+		if p, ok = fieldPath(b); !ok {
+			return
+		}
+		if c, cok = g.constant(a); !cok {
+			return
+		}
+		op = flippedOp[op]
+	}
+	if len(p) == 0 {
+		return
+	}
+	num, isNum := toNumber(c)
+	if !isNum {
+		if op == "=" {
+			g.add(p, candidate{value: c, note: "equal"}, other(c), candidate{absent: true})
+		}
+		return
+	}
+	step := 1.0
+	if num != math.Trunc(num) {
+		step = 0.01
+	}
+	in := func(v float64, note string) candidate { return candidate{value: v, note: note} }
+	switch op {
+	case "<=":
+		g.add(p, in(num, "at limit"), in(num-step, "under limit"), in(num+step, "over limit"), in(-1, "negative"))
+	case "<":
+		g.add(p, in(num-step, "under limit"), in(num, "at limit"), in(-1, "negative"))
+	case ">=":
+		g.add(p, in(num, "at limit"), in(num+step, "over limit"), in(num-step, "under limit"))
+	case ">":
+		g.add(p, in(num+step, "over limit"), in(num, "at limit"))
+	case "=":
+		g.add(p, in(num, "equal"), in(num+step, "not equal"))
+	}
+	g.add(p, candidate{absent: true})
+}
+
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// other returns a value of c's type that differs from c.
+func other(c any) candidate {
+	switch v := c.(type) {
+	case bool:
+		return candidate{value: !v, note: "not equal"}
+	case string:
+		return candidate{value: "not-" + v, note: "not equal"}
+	}
+	return candidate{value: fmt.Sprint(c) + "-other", note: "not equal"}
+}
+
+func (g *generator) membership(op string, a, b spl.Node) {
+	if p, ok := fieldPath(a); ok && len(p) > 0 {
+		c, ok := g.constant(b)
+		list, isList := c.([]any)
+		if !ok || !isList {
+			return
+		}
+		if op == "subset?" {
+			g.add(p, candidate{value: list, note: "whole list"}, candidate{value: []any{}, note: "empty"},
+				candidate{value: append(append([]any{}, list...), outsider(list)), note: "extra item"}, candidate{absent: true})
+			return
+		}
+		for i, e := range list {
+			if i == 3 {
+				break
+			}
+			g.add(p, candidate{value: e, note: "in list"})
+		}
+		g.add(p, candidate{value: outsider(list), note: "not in list"}, candidate{absent: true})
+		return
+	}
+	// (member "x" (get req "tags")): the field is the list.
+	if p, ok := fieldPath(b); ok && len(p) > 0 && op != "subset?" {
+		if c, ok := g.constant(a); ok {
+			g.add(p, candidate{value: []any{c}, note: "contains"}, candidate{value: []any{}, note: "empty"}, candidate{absent: true})
+		}
+	}
+}
+
+// outsider returns a value that is not in list.
+func outsider(list []any) any {
+	if len(list) == 0 {
+		return "x"
+	}
+	if _, ok := toNumber(list[0]); ok {
+		max := math.Inf(-1)
+		for _, e := range list {
+			if n, ok := toNumber(e); ok && n > max {
+				max = n
+			}
+		}
+		return max + 1
+	}
+	names := make([]string, len(list))
+	for i, e := range list {
+		names[i] = fmt.Sprint(e)
+	}
+	sort.Strings(names)
+	return "not-" + names[0]
+}
+
+// before handles (before field deadline) and (before start field).
+func (g *generator) before(a, b spl.Node) {
+	if p, ok := fieldPath(a); ok && len(p) > 0 {
+		if c, ok := g.constant(b); ok {
+			if s, ok := c.(string); ok {
+				g.add(p, candidate{value: shiftTime(s, -time.Second), note: "just before"}, candidate{value: s, note: "at deadline"}, candidate{absent: true})
+			}
+		}
+		return
+	}
+	if p, ok := fieldPath(b); ok && len(p) > 0 {
+		if c, ok := g.constant(a); ok {
+			if s, ok := c.(string); ok {
+				g.add(p, candidate{value: shiftTime(s, time.Second), note: "just after"}, candidate{value: s, note: "at start"}, candidate{absent: true})
+			}
+		}
+	}
+}
+
+// shiftTime moves an RFC 3339 time by d; other strings are compared
+// lexically by before, so they are adjusted by their last byte.
+func shiftTime(s string, d time.Duration) string {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Add(d).Format(time.RFC3339)
+	}
+	if s == "" {
+		return s
+	}
+	b := []byte(s)
+	if d < 0 {
+		b[len(b)-1]--
+	} else {
+		b[len(b)-1]++
+	}
+	return string(b)
+}
@@ -0,0 +1,60 @@
+package spltest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func TestGenerateRequests(t *testing.T) {
+	ast, err := spl.Parse(`(and
+  (<= (get req "amount") limit)
+  (member (get req "recipient") (tuple "mom" "niece"))
+  (before (get (get req "meta") "at") "2026-01-01T00:00:00Z")
+  (get req "attested"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := GenerateRequests(ast, map[string]any{"limit": 50.0}, 100, 1)
+	if len(reqs) == 0 || reqs[0].Name != "baseline" {
+		t.Fatalf("expected a baseline first, got %+v", reqs)
+	}
+	want := map[string]any{
+		"amount":    50.0,
+		"recipient": "mom",
+		"meta":      map[string]any{"at": "2025-12-31T23:59:59Z"},
+		"attested":  true,
+	}
+	if !reflect.DeepEqual(reqs[0].Request, want) {
+		t.Fatalf("baseline = %v, want %v", reqs[0].Request, want)
+	}
+	names := map[string]bool{}
+	for _, r := range reqs {
+		names[r.Name] = true
+		ok, _ := spl.Verify(ast, spl.Env{Req: r.Request, Vars: map[string]any{"limit": 50.0}})
+		if r.Name == "baseline" && !ok || r.Name == "amount = 51 (over limit)" && ok {
+			t.Errorf("%s: unexpected decision %v", r.Name, ok)
+		}
+	}
+	for _, n := range []string{
+		"amount = 51 (over limit)",
+		`recipient = "not-mom" (not in list)`,
+		`meta.at = "2026-01-01T00:00:00Z" (at deadline)`,
+		"attested = false (falsy)",
+		"amount absent",
+	} {
+		if !names[n] {
+			t.Errorf("missing case %q", n)
+		}
+	}
+
+	if got := GenerateRequests(ast, map[string]any{"limit": 50.0}, 3, 1); len(got) != 3 {
+		t.Fatalf("expected count to cap the output, got %d", len(got))
+	}
+	a := GenerateRequests(ast, map[string]any{"limit": 50.0}, 100, 7)
+	b := GenerateRequests(ast, map[string]any{"limit": 50.0}, 100, 7)
+	if len(a) != 100 || !reflect.DeepEqual(a, b) {
+		t.Fatal("expected random fill to be deterministic for a seed")
+	}
+}
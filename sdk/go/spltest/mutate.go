@@ -0,0 +1,231 @@
+package spltest
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Mutation kinds.
+const (
+	// MutateFlip moves a comparison's boundary: < and <= swap, as do > and
+	// >=.
+	MutateFlip = "flip_comparison"
+	// MutateWiden loosens a numeric limit: an upper bound doubles, a lower
+	// bound halves, and a zero bound moves by one.
+	MutateWiden = "widen_limit"
+	// MutateDrop removes one clause of an and or an or.
+	MutateDrop = "drop_clause"
+	// MutateSwap turns an and into an or, and an or into an and.
+	MutateSwap = "swap_connective"
+	// MutateNot removes a not, keeping the expression it negated.
+	MutateNot = "drop_not"
+	// MutateMember removes one item of a literal tuple that member, in,
+	// subset? or not-member tests against.
+	MutateMember = "drop_member"
+)
+
+// Mutant is a policy that differs from the original in one expression.
+type Mutant struct {
+	Kind string `json:"kind"`
+	// Original is the expression changed, and Mutated what replaced it;
+	// Mutated is empty for a clause or item dropped.
+	Original string `json:"original"`
+	Mutated  string `json:"mutated,omitempty"`
+	Policy   string `json:"policy"`
+	// KilledBy is the first case that fails against the mutant, or empty
+	// if every case passes and the mutant survived.
+	KilledBy string `json:"killed_by,omitempty"`
+}
+
+// Killed reports whether a case caught the mutant.
+func (m Mutant) Killed() bool { return m.KilledBy != "" }
+
+func (m Mutant) String() string {
+	if m.Mutated == "" {
+		return fmt.Sprintf("%s: dropped %s", m.Kind, m.Original)
+	}
+	return fmt.Sprintf("%s: %s -> %s", m.Kind, m.Original, m.Mutated)
+}
+
+// Mutants returns every mutant of policy, in the order their changes appear
+// in it. A mutant may be equivalent to the policy, such as one dropping a
+// clause another implies; no suite can kill those.
+func Mutants(policy spl.Node) []Mutant {
+	var out []Mutant
+	walk(policy, nil, func(kind string, path []int, from, to spl.Node) {
+		m := Mutant{Kind: kind, Original: spl.Render(from), Policy: spl.Render(replace(policy, path, to))}
+		if to != nil {
+			m.Mutated = spl.Render(to)
+		}
+		out = append(out, m)
+	})
+	return out
+}
+
+// MutationReport is the outcome of running a suite against the mutants of
+// its policy.
+type MutationReport struct {
+	Suite    string   `json:"suite,omitempty"`
+	Mutants  []Mutant `json:"mutants"`
+	Killed   int      `json:"killed"`
+	Survived int      `json:"survived"`
+}
+
+// Score is the fraction of mutants killed, or 1 if there are none.
+func (r *MutationReport) Score() float64 {
+	if len(r.Mutants) == 0 {
+		return 1
+	}
+	return float64(r.Killed) / float64(len(r.Mutants))
+}
+
+// Mutate runs the suite against each mutant of its policy. A mutant that
+// passes every case survived: the suite does not test the expression it
+// changed. Mutate returns an error if the policy or a request file cannot
+// be loaded, or if the suite fails against the policy itself.
+func (s *Suite) Mutate() (*MutationReport, error) {
+	ast, err := s.policy()
+	if err != nil {
+		return nil, err
+	}
+	// Read request files once, not once per mutant.
+	m := *s
+	m.Cases = append([]Case(nil), s.Cases...)
+	for i, c := range m.Cases {
+		req, err := s.request(c)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		m.Cases[i].Request, m.Cases[i].RequestFile = req, ""
+	}
+	results, err := m.run(ast)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if !r.Pass {
+			return nil, fmt.Errorf("suite fails before mutation: %s", r)
+		}
+	}
+
+	report := &MutationReport{Suite: s.Path, Mutants: Mutants(ast)}
+	for i, mu := range report.Mutants {
+		mutant, err := spl.Parse(mu.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("mutant %s: %w", mu, err)
+		}
+		results, err := m.run(mutant)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if !r.Pass {
+				report.Mutants[i].KilledBy = r.Case
+				break
+			}
+		}
+		if report.Mutants[i].Killed() {
+			report.Killed++
+		} else {
+			report.Survived++
+		}
+	}
+	return report, nil
+}
+
+// flipped maps each comparison to the one with its boundary moved.
+var flipped = map[spl.Op]string{spl.OpLt: "<=", spl.OpLe: "<", spl.OpGt: ">=", spl.OpGe: ">"}
+
+// walk calls emit with each mutation of n and its descendants: the kind,
+// the path of child indexes to the expression changed, the expression and
+// its replacement, nil to remove it.
+func walk(n spl.Node, path []int, emit func(kind string, path []int, from, to spl.Node)) {
+	l, ok := n.(spl.List)
+	if !ok {
+		return
+	}
+	op, _, _ := l.Head()
+	at := func(i int) []int { return append(path[:len(path):len(path)], i) }
+	switch op {
+	case spl.OpAnd, spl.OpOr:
+		swap := "or"
+		if op == spl.OpOr {
+			swap = "and"
+		}
+		emit(MutateSwap, path, l, with(l, 0, spl.Sym(swap)))
+		if len(l) > 2 {
+			for i := 1; i < len(l); i++ {
+				emit(MutateDrop, at(i), l[i], nil)
+			}
+		}
+	case spl.OpNot:
+		if len(l) == 2 {
+			emit(MutateNot, path, l, l[1])
+		}
+	case spl.OpLt, spl.OpLe, spl.OpGt, spl.OpGe:
+		if len(l) != 3 {
+			break
+		}
+		emit(MutateFlip, path, l, with(l, 0, spl.Sym(flipped[op])))
+		// A limit on the right of < or <=, or the left of > or >=, is an
+		// upper bound.
+		upper := op == spl.OpLt || op == spl.OpLe
+		for i := 1; i <= 2; i++ {
+			if v, ok := l[i].(spl.Num); ok {
+				emit(MutateWiden, path, l, with(l, i, widen(v, (i == 2) == upper)))
+			}
+		}
+	case spl.OpMember, spl.OpIn, spl.OpSubset, spl.OpNotMember:
+		if len(l) != 3 {
+			break
+		}
+		if t, ok := l[2].(spl.List); ok && len(t) > 2 {
+			if top, _, _ := t.Head(); top == spl.OpTuple {
+				for i := 1; i < len(t); i++ {
+					emit(MutateMember, append(at(2), i), t[i], nil)
+				}
+			}
+		}
+	}
+	for i, c := range l[1:] {
+		walk(c, at(i+1), emit)
+	}
+}
+
+// widen loosens the limit v: upward for an upper bound, else downward.
+func widen(v spl.Num, up bool) spl.Num {
+	d := math.Max(math.Abs(float64(v)), 1)
+	if !up {
+		d = -math.Max(math.Abs(float64(v))/2, 1)
+	}
+	return v + spl.Num(d)
+}
+
+// with returns a copy of l with its i'th item replaced by n.
+func with(l spl.List, i int, n spl.Node) spl.List {
+	out := append(spl.List(nil), l...)
+	out[i] = n
+	return out
+}
+
+// replace returns a copy of root with the node at path replaced by n, or
+// removed from its list if n is nil. root itself is not modified.
+func replace(root spl.Node, path []int, n spl.Node) spl.Node {
+	if len(path) == 0 {
+		return n
+	}
+	l := root.(spl.List)
+	out := make(spl.List, 0, len(l))
+	for i, c := range l {
+		if i == path[0] {
+			if len(path) == 1 && n == nil {
+				continue
+			}
+			c = replace(c, path[1:], n)
+		}
+		out = append(out, c)
+	}
+	return out
+}
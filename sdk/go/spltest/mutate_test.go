@@ -0,0 +1,78 @@
+package spltest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func TestMutants(t *testing.T) {
+	ast, err := spl.Parse(`(or (not (> 10 (get req "n"))) (member (get req "to") (tuple "a" "b")))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range Mutants(ast) {
+		got = append(got, m.String())
+		if _, err := spl.Parse(m.Policy); err != nil {
+			t.Fatalf("%s: mutant does not parse: %v", m, err)
+		}
+	}
+	want := []string{
+		`swap_connective: (or (not (> 10 (get req "n"))) (member (get req "to") (tuple "a" "b"))) -> (and (not (> 10 (get req "n"))) (member (get req "to") (tuple "a" "b")))`,
+		`drop_clause: dropped (not (> 10 (get req "n")))`,
+		`drop_clause: dropped (member (get req "to") (tuple "a" "b"))`,
+		`drop_not: (not (> 10 (get req "n"))) -> (> 10 (get req "n"))`,
+		`flip_comparison: (> 10 (get req "n")) -> (>= 10 (get req "n"))`,
+		`widen_limit: (> 10 (get req "n")) -> (> 20 (get req "n"))`,
+		`drop_member: dropped "a"`,
+		`drop_member: dropped "b"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got mutants\n%s", strings.Join(got, "\n"))
+	}
+	if p := Mutants(ast)[7].Policy; p != `(or (not (> 10 (get req "n"))) (member (get req "to") (tuple "a")))` {
+		t.Fatalf("unexpected mutant policy %s", p)
+	}
+}
+
+func TestMutate(t *testing.T) {
+	s, err := Parse([]byte(`
+policy: (and (= (get req "action") "pay") (<= (get req "amount") 100))
+cases:
+  - name: at the limit
+    request: {action: pay, amount: 100}
+    expect: allow
+  - name: over the limit
+    request: {action: pay, amount: 250}
+    expect: deny
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Mutate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	survived := map[string]bool{}
+	for _, m := range r.Mutants {
+		if !m.Killed() {
+			survived[m.String()] = true
+		}
+	}
+	// No case has another action, or an amount between the limit and
+	// twice it.
+	if len(survived) != 2 || !survived[`drop_clause: dropped (= (get req "action") "pay")`] ||
+		!survived[`widen_limit: (<= (get req "amount") 100) -> (<= (get req "amount") 200)`] {
+		t.Fatalf("unexpected survivors %v", survived)
+	}
+	if r.Killed != 3 || r.Survived != 2 || r.Score() != 0.6 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+
+	s.Cases[0].Expect = "deny"
+	if _, err := s.Mutate(); err == nil || !strings.Contains(err.Error(), "before mutation") {
+		t.Fatalf("expected a failing suite to be an error, got %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+// Package quick is property-based testing for SPL: it generates random
+// well-typed policies over a small request schema, with requests that the
+// policies allow and deny, and checks the invariants verification relies
+// on. It runs in this repository's tests and is meant for policy authors
+// too, who can check that a narrowed policy never allows what the original
+// denies:
+//
+//	func TestNarrowing(t *testing.T) {
+//		quick.CheckNarrower(t, parentSrc, childSrc, quick.Config{})
+//	}
+//
+// Generation is deterministic for a given seed, and a failure reports the
+// seed, policies and request needed to reproduce it.
+package quick
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+	"github.com/jmcentire/agent-safe/sdk/go/spltest"
+)
+
+// The request schema generated policies and requests share.
+var (
+	numberFields = []string{"amount", "count"}
+	stringFields = map[string][]string{
+		"recipient": {"alice", "bob", "carol", "dave"},
+		"action":    {"pay", "refund", "read"},
+	}
+	boolFields = []string{"attested"}
+	limits     = []float64{0, 1, 10, 50, 100, 500}
+)
+
+// Generator produces random policies and requests. It is not safe for
+// concurrent use.
+type Generator struct {
+	// MaxDepth bounds how deeply and, or and not nest; 0 means 3.
+	MaxDepth int
+
+	rng *rand.Rand
+}
+
+// New returns a generator seeded with seed.
+func New(seed int64) *Generator {
+	return &Generator{rng: rand.New(rand.NewSource(seed))}
+}
+
+// Policy returns the source of a random policy: and, or and not over
+// comparisons, equalities and memberships of request fields.
+func (g *Generator) Policy() string {
+	depth := g.MaxDepth
+	if depth <= 0 {
+		depth = 3
+	}
+	var b strings.Builder
+	g.expr(&b, g.rng.Intn(depth+1))
+	return b.String()
+}
+
+func (g *Generator) expr(b *strings.Builder, depth int) {
+	if depth == 0 || g.rng.Intn(3) == 0 {
+		g.clause(b)
+		return
+	}
+	switch g.rng.Intn(3) {
+	case 0:
+		b.WriteString("(not ")
+		g.expr(b, depth-1)
+	default:
+		b.WriteString([]string{"(and", "(or"}[g.rng.Intn(2)])
+		for i := 2 + g.rng.Intn(2); i > 0; i-- {
+			b.WriteByte(' ')
+			g.expr(b, depth-1)
+		}
+	}
+	b.WriteByte(')')
+}
+
+// clause writes a test of one request field.
+func (g *Generator) clause(b *strings.Builder) {
+	switch g.rng.Intn(4) {
+	case 0, 1:
+		op := []string{"<", "<=", ">", ">=", "="}[g.rng.Intn(5)]
+		fmt.Fprintf(b, "(%s (get req %q) %s)", op, pick(g.rng, numberFields), num(pick(g.rng, limits)))
+	case 2:
+		f := pick(g.rng, keys(stringFields))
+		values := stringFields[f]
+		if g.rng.Intn(2) == 0 {
+			fmt.Fprintf(b, "(= (get req %q) %q)", f, pick(g.rng, values))
+			return
+		}
+		fmt.Fprintf(b, "(member (get req %q) (tuple", f)
+		for _, v := range values {
+			if g.rng.Intn(2) == 0 {
+				fmt.Fprintf(b, " %q", v)
+			}
+		}
+		b.WriteString("))")
+	default:
+		fmt.Fprintf(b, "(= (get req %q) %s)", pick(g.rng, boolFields), []string{"#t", "#f"}[g.rng.Intn(2)])
+	}
+}
+
+// Request returns a random request over the schema. Now and then a field
+// is left out, which a policy reading it treats as an error, so a deny.
+func (g *Generator) Request() map[string]any {
+	req := map[string]any{}
+	for _, f := range numberFields {
+		v := pick(g.rng, limits)
+		switch g.rng.Intn(3) {
+		case 0:
+			v += 0.5
+		case 1:
+			v -= 0.5
+		}
+		req[f] = v
+	}
+	for _, f := range keys(stringFields) {
+		req[f] = pick(g.rng, stringFields[f])
+	}
+	for _, f := range boolFields {
+		req[f] = g.rng.Intn(2) == 0
+	}
+	fields := make([]string, 0, len(req))
+	for k := range req {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for _, k := range fields {
+		if g.rng.Intn(10) == 0 {
+			delete(req, k)
+		}
+	}
+	return req
+}
+
+// Requests returns n requests for policy: those spltest.GenerateRequests
+// derives from its limits, lists and equalities first, then random ones.
+// Each field the policy does not constrain gets a random value.
+func (g *Generator) Requests(policy string, n int) []map[string]any {
+	var out []map[string]any
+	if ast, err := spl.Parse(policy); err == nil {
+		for _, r := range spltest.GenerateRequests(ast, nil, n, g.rng.Int63()) {
+			req := g.Request()
+			for k, v := range r.Request {
+				req[k] = v
+			}
+			out = append(out, req)
+		}
+	}
+	for len(out) < n {
+		out = append(out, g.Request())
+	}
+	return out
+}
+
+// Matching returns a request policy allows, from up to tries candidates,
+// or false if none was found.
+func (g *Generator) Matching(policy string, tries int) (map[string]any, bool) {
+	return g.find(policy, tries, true)
+}
+
+// Mismatching returns a request policy denies, from up to tries
+// candidates, or false if none was found.
+func (g *Generator) Mismatching(policy string, tries int) (map[string]any, bool) {
+	return g.find(policy, tries, false)
+}
+
+func (g *Generator) find(policy string, tries int, allow bool) (map[string]any, bool) {
+	for _, req := range g.Requests(policy, tries) {
+		if Allows(policy, req) == allow {
+			return req, true
+		}
+	}
+	return nil, false
+}
+
+// Allows reports whether policy allows req. As in token verification, a
+// policy that does not parse or fails to evaluate denies.
+func Allows(policy string, req map[string]any) bool {
+	ast, err := spl.Parse(policy)
+	if err != nil {
+		return false
+	}
+	allow, err := spl.Verify(ast, spl.Env{Req: req})
+	return err == nil && allow
+}
+
+// Config configures the property checks. Zero fields take the defaults
+// given.
+type Config struct {
+	// Seed seeds generation; 1.
+	Seed int64
+	// Policies is how many random policies, or pairs, a check tries; 100.
+	Policies int
+	// Requests is how many requests each policy is checked against; 50.
+	Requests int
+}
+
+func (c Config) withDefaults() Config {
+	if c.Seed == 0 {
+		c.Seed = 1
+	}
+	if c.Policies <= 0 {
+		c.Policies = 100
+	}
+	if c.Requests <= 0 {
+		c.Requests = 50
+	}
+	return c
+}
+
+// CheckDeterministic checks that evaluation is deterministic: a random
+// policy reaches the same decision, gas and error every time it evaluates
+// a request, memoized or not.
+func CheckDeterministic(t testing.TB, c Config) {
+	t.Helper()
+	c = c.withDefaults()
+	g := New(c.Seed)
+	for range c.Policies {
+		policy := g.Policy()
+		ast, err := spl.Parse(policy)
+		if err != nil {
+			t.Fatalf("seed %d: generated policy %s does not parse: %v", c.Seed, policy, err)
+		}
+		for _, req := range g.Requests(policy, c.Requests) {
+			allow, gas, err := spl.VerifyWithGas(ast, spl.Env{Req: req})
+			for _, memoize := range []bool{false, true} {
+				a, used, e := spl.VerifyWithGas(ast, spl.Env{Req: req, Memoize: memoize})
+				if a != allow || !memoize && used != gas || (e == nil) != (err == nil) {
+					t.Fatalf("seed %d: %s on %s decided %v (gas %d, %v), then %v (gas %d, %v) with memoize %v",
+						c.Seed, policy, encode(req), allow, gas, err, a, used, e, memoize)
+				}
+			}
+		}
+	}
+}
+
+// CheckAttenuation checks that an attenuated token never allows what its
+// parent denies: for random policies and constraints, a token narrowed
+// with spl.Attenuate is verified against requests alongside its parent.
+func CheckAttenuation(t testing.TB, c Config) {
+	t.Helper()
+	c = c.withDefaults()
+	g := New(c.Seed)
+	_, priv := spl.GenerateKeypair()
+	for range c.Policies {
+		policy, constraint := g.Policy(), g.Policy()
+		parent, err := spl.Mint(policy, priv, spl.MintOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		child, err := spl.Attenuate(parent, constraint, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, req := range g.Requests(child.Policy, c.Requests) {
+			p := spl.VerifyTokenObj(parent, req, spl.VerifyTokenOptions{})
+			if ch := spl.VerifyTokenObj(child, req, spl.VerifyTokenOptions{}); ch.Allow && !p.Allow {
+				t.Fatalf("seed %d: %s attenuated with %s allows %s, which the parent denies", c.Seed, policy, constraint, encode(req))
+			}
+		}
+	}
+}
+
+// CheckNarrower checks that child never allows a request parent denies,
+// against the requests derived from both policies and random ones.
+func CheckNarrower(t testing.TB, parent, child string, c Config) {
+	t.Helper()
+	c = c.withDefaults()
+	if err := narrower(New(c.Seed), parent, child, c.Requests); err != nil {
+		t.Fatalf("seed %d: %v", c.Seed, err)
+	}
+}
+
+func narrower(g *Generator, parent, child string, n int) error {
+	reqs := append(g.Requests(child, n), g.Requests(parent, n)...)
+	for _, req := range reqs {
+		if Allows(child, req) && !Allows(parent, req) {
+			return fmt.Errorf("%s allows %s, which %s denies", child, encode(req), parent)
+		}
+	}
+	return nil
+}
+
+// CheckSubsumes checks that spl.Subsumes is sound: whenever it proves a
+// random policy narrower than another, no request tells them apart the
+// other way. Half the pairs are a policy and a conjunction extending it,
+// which Subsumes proves; the others are unrelated.
+func CheckSubsumes(t testing.TB, c Config) {
+	t.Helper()
+	c = c.withDefaults()
+	g := New(c.Seed)
+	for range c.Policies {
+		broad, narrow := g.Policy(), g.Policy()
+		if g.rng.Intn(2) == 0 {
+			narrow = "(and " + broad + " " + narrow + ")"
+		}
+		b, err1 := spl.Parse(broad)
+		n, err2 := spl.Parse(narrow)
+		if err1 != nil || err2 != nil || !spl.Subsumes(b, n, map[string]any{}) {
+			continue
+		}
+		if err := narrower(g, broad, narrow, c.Requests); err != nil {
+			t.Fatalf("seed %d: Subsumes proved %s narrower than %s, but %v", c.Seed, narrow, broad, err)
+		}
+	}
+}
+
+func pick[T any](rng *rand.Rand, xs []T) T { return xs[rng.Intn(len(xs))] }
+
+// keys returns m's keys in a fixed order, so generation is deterministic.
+func keys(m map[string][]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func num(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+
+func encode(req map[string]any) string {
+	b, _ := json.Marshal(req)
+	return string(b)
+}
@@ -0,0 +1,44 @@
+package quick
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProperties(t *testing.T) {
+	c := Config{Policies: 60, Requests: 30}
+	t.Run("deterministic", func(t *testing.T) { CheckDeterministic(t, c) })
+	t.Run("attenuation", func(t *testing.T) { CheckAttenuation(t, c) })
+	t.Run("subsumes", func(t *testing.T) { CheckSubsumes(t, c) })
+	t.Run("narrower", func(t *testing.T) {
+		CheckNarrower(t, `(<= (get req "amount") 100)`, `(and (<= (get req "amount") 50) (= (get req "action") "pay"))`, c)
+	})
+}
+
+func TestCheckNarrowerFindsWidening(t *testing.T) {
+	err := narrower(New(1), `(<= (get req "amount") 50)`, `(<= (get req "amount") 100)`, 50)
+	if err == nil {
+		t.Fatal("expected a wider child to be caught")
+	}
+}
+
+func TestGeneration(t *testing.T) {
+	a, b := New(7), New(7)
+	for range 20 {
+		p := a.Policy()
+		if q := b.Policy(); p != q {
+			t.Fatalf("expected the same policy for the same seed, got %s and %s", p, q)
+		}
+		if r, s := a.Request(), b.Request(); !reflect.DeepEqual(r, s) {
+			t.Fatalf("expected the same request for the same seed, got %v and %v", r, s)
+		}
+	}
+	g := New(3)
+	policy := `(and (<= (get req "amount") 10) (= (get req "recipient") "bob"))`
+	if req, ok := g.Matching(policy, 50); !ok || !Allows(policy, req) {
+		t.Fatalf("expected a matching request, got %v %v", req, ok)
+	}
+	if req, ok := g.Mismatching(policy, 50); !ok || Allows(policy, req) {
+		t.Fatalf("expected a mismatching request, got %v %v", req, ok)
+	}
+}
@@ -0,0 +1,348 @@
+// Package spltest runs policy test suites: files that pair requests with the
+// decision a policy must reach, so policy changes are covered by executable
+// tests.
+//
+// A suite is a YAML (or JSON) file, conventionally named *_test.yaml:
+//
+//	policy: family_gifts.spl       # path relative to the suite, or inline source
+//	now: 2025-10-01T00:00:00Z      # defaults shared by every case
+//	vars:
+//	  allowed_recipients: [niece@example.com]
+//	counters:                      # action → day → count for per-day-count
+//	  payments.create: {"2025-09-29": 1}
+//	crypto: {dpop: true}           # crypto predicates stubbed to true
+//	cases:
+//	  - name: gift to niece
+//	    request: {recipient: niece@example.com, amount: 50}
+//	    expect: allow
+//	  - name: stranger
+//	    request_file: ../requests/stranger.json
+//	    vars: {allowed_recipients: []}   # merged over the suite's vars
+//	    expect: deny
+//
+// A case may override now, counters and crypto as well as vars. Evaluation
+// errors count as deny, matching token verification. Run a suite from a Go
+// test with Run, or from the command line with "agent-safe test".
+package spltest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/internal/yaml"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Suite is a policy and the cases it must satisfy.
+type Suite struct {
+	// Path is the file the suite was loaded from, if any.
+	Path string `json:"-"`
+	// Policy is a path relative to the suite file, or policy source when it
+	// starts with "(".
+	Policy   string                    `json:"policy"`
+	Now      string                    `json:"now,omitempty"`
+	Vars     map[string]any            `json:"vars,omitempty"`
+	Counters map[string]map[string]int `json:"counters,omitempty"`
+	Crypto   map[string]bool           `json:"crypto,omitempty"`
+	Cases    []Case                    `json:"cases"`
+}
+
+// Case is one request and its expected decision.
+type Case struct {
+	Name    string         `json:"name"`
+	Request map[string]any `json:"request,omitempty"`
+	// RequestFile names a JSON request relative to the suite file, instead
+	// of an inline Request.
+	RequestFile string                    `json:"request_file,omitempty"`
+	Now         string                    `json:"now,omitempty"`
+	Vars        map[string]any            `json:"vars,omitempty"`
+	Counters    map[string]map[string]int `json:"counters,omitempty"`
+	Crypto      map[string]bool           `json:"crypto,omitempty"`
+	Expect      string                    `json:"expect"` // "allow" or "deny"
+}
+
+// Result is the outcome of one case.
+type Result struct {
+	Suite    string `json:"suite,omitempty"`
+	Case     string `json:"case"`
+	Pass     bool   `json:"pass"`
+	Expect   string `json:"expect"`
+	Got      string `json:"got"`
+	Reason   string `json:"reason,omitempty"` // evaluation error, if any
+	GasUsed  int    `json:"gas_used"`
+	Duration int64  `json:"duration_us"`
+}
+
+func (r Result) String() string {
+	s := fmt.Sprintf("%s: expected %s, got %s", r.Case, r.Expect, r.Got)
+	if r.Reason != "" {
+		s += " (" + r.Reason + ")"
+	}
+	return s
+}
+
+// Load reads a suite file.
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s.Path = path
+	return s, nil
+}
+
+// Parse decodes a YAML or JSON suite.
+func Parse(data []byte) (*Suite, error) {
+	var s Suite
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Policy == "" {
+		return nil, fmt.Errorf("suite has no policy")
+	}
+	for i, c := range s.Cases {
+		if c.Name == "" {
+			s.Cases[i].Name = fmt.Sprintf("case %d", i+1)
+		}
+		if c.Expect != "allow" && c.Expect != "deny" {
+			return nil, fmt.Errorf("%s: expect must be allow or deny, got %q", s.Cases[i].Name, c.Expect)
+		}
+		if (c.Request == nil) == (c.RequestFile == "") {
+			return nil, fmt.Errorf("%s: exactly one of request and request_file is required", s.Cases[i].Name)
+		}
+	}
+	return &s, nil
+}
+
+// resolve makes a path in the suite relative to the suite file.
+func (s *Suite) resolve(p string) string {
+	if s.Path == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(filepath.Dir(s.Path), p)
+}
+
+// Run evaluates every case. It returns an error only if the policy or a
+// request file cannot be loaded; failing cases are reported in the results.
+func (s *Suite) Run() ([]Result, error) {
+	ast, err := s.policy()
+	if err != nil {
+		return nil, err
+	}
+	return s.run(ast)
+}
+
+// policy loads and parses the suite's policy.
+func (s *Suite) policy() (spl.Node, error) {
+	src := s.Policy
+	if !strings.HasPrefix(strings.TrimSpace(src), "(") {
+		b, err := os.ReadFile(filepath.Clean(s.resolve(src)))
+		if err != nil {
+			return nil, err
+		}
+		src = string(b)
+	}
+	ast, err := spl.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	return ast, nil
+}
+
+// run evaluates every case against ast.
+func (s *Suite) run(ast spl.Node) ([]Result, error) {
+	results := make([]Result, 0, len(s.Cases))
+	for _, c := range s.Cases {
+		r, err := s.runCase(ast, c)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// request returns c's request, reading it from its file if need be.
+func (s *Suite) request(c Case) (map[string]any, error) {
+	if c.RequestFile == "" {
+		return c.Request, nil
+	}
+	b, err := os.ReadFile(filepath.Clean(s.resolve(c.RequestFile)))
+	if err != nil {
+		return nil, err
+	}
+	var req map[string]any
+	if err := json.Unmarshal(b, &req); err != nil {
+		return nil, fmt.Errorf("%s: %w", c.RequestFile, err)
+	}
+	return req, nil
+}
+
+func (s *Suite) runCase(ast spl.Node, c Case) (Result, error) {
+	req, err := s.request(c)
+	if err != nil {
+		return Result{}, err
+	}
+	vars := make(map[string]any, len(s.Vars)+len(c.Vars)+1)
+	for k, v := range s.Vars {
+		vars[k] = v
+	}
+	for k, v := range c.Vars {
+		vars[k] = v
+	}
+	now := s.Now
+	if c.Now != "" {
+		now = c.Now
+	}
+	if now != "" {
+		if _, err := time.Parse(time.RFC3339, now); err != nil {
+			return Result{}, fmt.Errorf("now: %w", err)
+		}
+		vars["now"] = now
+	}
+	enabled := make(map[string]bool, len(s.Crypto)+len(c.Crypto))
+	for k, v := range s.Crypto {
+		enabled[k] = v
+	}
+	for k, v := range c.Crypto {
+		enabled[k] = v
+	}
+	crypto, err := StubCrypto(enabled)
+	if err != nil {
+		return Result{}, err
+	}
+	counts := s.Counters
+	if c.Counters != nil {
+		counts = c.Counters
+	}
+
+	start := time.Now()
+	allow, gas, err := spl.VerifyWithGas(ast, spl.Env{
+		Req:         req,
+		Vars:        vars,
+		PerDayCount: func(action, day string) int { return counts[action][day] },
+		Crypto:      crypto,
+	})
+	r := Result{
+		Suite:    s.Path,
+		Case:     c.Name,
+		Expect:   c.Expect,
+		Got:      "deny",
+		GasUsed:  gas,
+		Duration: time.Since(start).Microseconds(),
+	}
+	if err != nil {
+		r.Reason = err.Error()
+	} else if allow {
+		r.Got = "allow"
+	}
+	r.Pass = r.Got == r.Expect
+	return r, nil
+}
+
+// Run loads the suite at path and runs each case as a subtest of t.
+func Run(t *testing.T, path string) {
+	t.Helper()
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := s.Run()
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	for _, r := range results {
+		r := r
+		t.Run(r.Case, func(t *testing.T) {
+			if !r.Pass {
+				t.Error(r)
+			}
+		})
+	}
+}
+
+// Find returns the suite files under each path, sorted: files named
+// *_test.yaml, *_test.yml or *_test.json in directories (recursively), and
+// any file named explicitly.
+func Find(paths ...string) ([]string, error) {
+	var found []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			found = append(found, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && IsSuiteFile(d.Name()) {
+				found = append(found, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+// IsSuiteFile reports whether name follows the suite naming convention.
+func IsSuiteFile(name string) bool {
+	for _, ext := range []string{"_test.yaml", "_test.yml", "_test.json"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// cryptoStubs maps predicate names to the stubs that satisfy them.
+var cryptoStubs = map[string]func(cb *spl.CryptoCallbacks){
+	"dpop": func(cb *spl.CryptoCallbacks) { cb.DPoPOk = func() bool { return true } },
+	"merkle": func(cb *spl.CryptoCallbacks) {
+		cb.MerkleOk = func([]any) bool { return true }
+		cb.MerkleMember = func(string, []spl.MerkleProofStep) bool { return true }
+	},
+	"vrf":      func(cb *spl.CryptoCallbacks) { cb.VRFOk = func(string, float64) bool { return true } },
+	"thresh":   func(cb *spl.CryptoCallbacks) { cb.ThreshOk = func() bool { return true } },
+	"attested": func(cb *spl.CryptoCallbacks) { cb.AttestedOk = func() bool { return true } },
+	"approval": func(cb *spl.CryptoCallbacks) { cb.ApprovalOk = func(string) bool { return true } },
+}
+
+// StubCrypto returns callbacks that satisfy the enabled crypto predicates,
+// named dpop, merkle, vrf, thresh, attested and approval. Predicates that
+// are not enabled keep the fail-closed default. Stubs are for testing only.
+func StubCrypto(enabled map[string]bool) (spl.CryptoCallbacks, error) {
+	var cb spl.CryptoCallbacks
+	for name, on := range enabled {
+		set, ok := cryptoStubs[name]
+		if !ok {
+			known := make([]string, 0, len(cryptoStubs))
+			for k := range cryptoStubs {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return cb, fmt.Errorf("unknown crypto predicate %q (known: %s)", name, strings.Join(known, ", "))
+		}
+		if on {
+			set(&cb)
+		}
+	}
+	return cb, nil
+}
@@ -0,0 +1,71 @@
+package spltest
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExampleSuite(t *testing.T) {
+	path := "../../../examples/policies/family_gifts_test.yaml"
+	if _, err := os.Stat(path); err != nil {
+		t.Skipf("examples not available: %v", err)
+	}
+	Run(t, path)
+}
+
+func TestSuiteReportsFailures(t *testing.T) {
+	s, err := Parse([]byte(`
+policy: (and (<= (get req "amount") limit) (dpop_ok?))
+vars: {limit: 100}
+crypto: {dpop: true}
+cases:
+  - request: {amount: 50}
+    expect: allow
+  - name: raised limit
+    request: {amount: 150}
+    vars: {limit: 200}
+    expect: allow
+  - name: wrong expectation
+    request: {amount: 150}
+    expect: allow
+  - name: no proof
+    request: {amount: 50}
+    crypto: {dpop: false}
+    expect: deny
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := s.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, true, false, true} {
+		if results[i].Pass != want {
+			t.Fatalf("%s: pass = %v, want %v", results[i].Case, results[i].Pass, want)
+		}
+	}
+	if results[0].Case != "case 1" || results[0].GasUsed == 0 {
+		t.Fatalf("unexpected first result %+v", results[0])
+	}
+	if got := results[2].String(); got != "wrong expectation: expected allow, got deny" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		`cases: []`: "no policy",
+		"policy: (= 1 1)\ncases:\n  - {request: {}, expect: maybe}":                       "expect must be allow or deny",
+		"policy: (= 1 1)\ncases:\n  - {expect: allow}":                                    "exactly one of request and request_file",
+		"policy: (= 1 1)\ncases:\n  - {request: {}, request_file: r.json, expect: allow}": "exactly one of request and request_file",
+	} {
+		if _, err := Parse([]byte(src)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected %q, got %v", src, want, err)
+		}
+	}
+	if _, err := StubCrypto(map[string]bool{"telepathy": true}); err == nil {
+		t.Error("expected unknown crypto predicate to fail")
+	}
+}
@@ -0,0 +1,473 @@
+// Package sql keeps counters, replay identifiers, revocations and the audit
+// log in a SQL database, so a single-binary verifier has durable state
+// without other infrastructure. SQLite and PostgreSQL are supported:
+//
+//	import sqlstore "github.com/jmcentire/agent-safe/sdk/go/store/sql"
+//
+//	db, err := sql.Open("sqlite", "agent-safe.db")
+//	s, err := sqlstore.New(db, sqlstore.Options{Dialect: sqlstore.SQLite})
+//	err = s.Migrate(ctx)
+//
+// Store implements spl.CounterStore, spl.ReplayStore, spl.RevocationStore,
+// spl.AuditLog and spl.StateStore. The caller registers the database driver; this package
+// depends on none.
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+var (
+	_ spl.CounterStore    = (*Store)(nil)
+	_ spl.ReplayStore     = (*Store)(nil)
+	_ spl.RevocationStore = (*Store)(nil)
+	_ spl.AuditLog        = (*Store)(nil)
+	_ spl.StateStore      = (*Store)(nil)
+)
+
+// Dialect selects the SQL variant.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// Options configures a Store.
+type Options struct {
+	// Dialect is the database's SQL variant. Default SQLite.
+	Dialect Dialect
+	// CounterTTL is how long a window's count is kept after it is first
+	// incremented, until Prune removes it. Default 48 hours.
+	CounterTTL time.Duration
+	// Timeout bounds each statement. Default 5 seconds.
+	Timeout time.Duration
+}
+
+// Store is a SQL-backed store. It is safe for concurrent use.
+type Store struct {
+	db   *dbsql.DB
+	opts Options
+	now  func() time.Time
+}
+
+// New returns a Store on db. Call Migrate before first use.
+func New(db *dbsql.DB, opts Options) (*Store, error) {
+	switch opts.Dialect {
+	case "":
+		opts.Dialect = SQLite
+	case SQLite, Postgres:
+	default:
+		return nil, fmt.Errorf("sql: unsupported dialect %q", opts.Dialect)
+	}
+	if opts.CounterTTL <= 0 {
+		opts.CounterTTL = 48 * time.Hour
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	return &Store{db: db, opts: opts, now: time.Now}, nil
+}
+
+// migrations create the schema, one version per entry. Entries are never
+// edited once released; changes are new entries.
+var migrations = []map[Dialect]string{
+	{
+		SQLite: `
+CREATE TABLE agent_safe_counters (
+	action TEXT NOT NULL,
+	period TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	expires_at BIGINT NOT NULL,
+	PRIMARY KEY (action, period)
+);
+CREATE TABLE agent_safe_replay (
+	id TEXT PRIMARY KEY,
+	expires_at BIGINT NOT NULL
+);
+CREATE TABLE agent_safe_revocations (
+	id TEXT PRIMARY KEY,
+	revoked_at BIGINT NOT NULL
+);
+CREATE TABLE agent_safe_audit (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	at BIGINT NOT NULL,
+	token TEXT NOT NULL,
+	request TEXT NOT NULL,
+	allow INTEGER NOT NULL,
+	error TEXT NOT NULL
+);`,
+		Postgres: `
+CREATE TABLE agent_safe_counters (
+	action TEXT NOT NULL,
+	period TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	expires_at BIGINT NOT NULL,
+	PRIMARY KEY (action, period)
+);
+CREATE TABLE agent_safe_replay (
+	id TEXT PRIMARY KEY,
+	expires_at BIGINT NOT NULL
+);
+CREATE TABLE agent_safe_revocations (
+	id TEXT PRIMARY KEY,
+	revoked_at BIGINT NOT NULL
+);
+CREATE TABLE agent_safe_audit (
+	seq BIGSERIAL PRIMARY KEY,
+	at BIGINT NOT NULL,
+	token TEXT NOT NULL,
+	request TEXT NOT NULL,
+	allow INTEGER NOT NULL,
+	error TEXT NOT NULL
+);`,
+	},
+}
+
+// Migrate brings the schema up to date, recording the applied version in
+// agent_safe_schema. It is safe to call on every start.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS agent_safe_schema (version INTEGER NOT NULL)`); err != nil {
+		return fmt.Errorf("sql: migrate: %w", err)
+	}
+	var version int
+	err := s.db.QueryRowContext(ctx, `SELECT version FROM agent_safe_schema`).Scan(&version)
+	if errors.Is(err, dbsql.ErrNoRows) {
+		_, err = s.db.ExecContext(ctx, `INSERT INTO agent_safe_schema (version) VALUES (0)`)
+	}
+	if err != nil {
+		return fmt.Errorf("sql: migrate: %w", err)
+	}
+	for v := version; v < len(migrations); v++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("sql: migrate: %w", err)
+		}
+		for _, stmt := range strings.Split(migrations[v][s.opts.Dialect], ";") {
+			if strings.TrimSpace(stmt) == "" {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("sql: migration %d: %w", v+1, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, s.q(`UPDATE agent_safe_schema SET version = ?`), v+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("sql: migration %d: %w", v+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("sql: migration %d: %w", v+1, err)
+		}
+	}
+	return nil
+}
+
+// q rewrites ? placeholders as $1, $2, ... for PostgreSQL.
+func (s *Store) q(query string) string {
+	if s.opts.Dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (s *Store) ctx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.opts.Timeout)
+}
+
+// Count returns the number of times action was counted in window.
+func (s *Store) Count(action, window string) (int, error) {
+	ctx, cancel := s.ctx()
+	defer cancel()
+	var n int
+	err := s.db.QueryRowContext(ctx, s.q(`SELECT count FROM agent_safe_counters WHERE action = ? AND period = ?`),
+		action, window).Scan(&n)
+	if errors.Is(err, dbsql.ErrNoRows) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// CheckAndIncrement counts action in window if its count is below limit and
+// reports whether it did, in a single upsert.
+func (s *Store) CheckAndIncrement(action, window string, limit int) (bool, error) {
+	if limit <= 0 {
+		return false, nil
+	}
+	ctx, cancel := s.ctx()
+	defer cancel()
+	var n int
+	err := s.db.QueryRowContext(ctx, s.q(`
+INSERT INTO agent_safe_counters (action, period, count, expires_at) VALUES (?, ?, 1, ?)
+ON CONFLICT (action, period) DO UPDATE SET count = agent_safe_counters.count + 1
+WHERE agent_safe_counters.count < ?
+RETURNING count`), action, window, s.now().Add(s.opts.CounterTTL).Unix(), limit).Scan(&n)
+	if errors.Is(err, dbsql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Claim records id for ttl and reports true if it was not already recorded
+// or its earlier record has expired.
+func (s *Store) Claim(id string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, errors.New("sql: replay TTL must be positive")
+	}
+	ctx, cancel := s.ctx()
+	defer cancel()
+	now := s.now()
+	res, err := s.db.ExecContext(ctx, s.q(`
+INSERT INTO agent_safe_replay (id, expires_at) VALUES (?, ?)
+ON CONFLICT (id) DO UPDATE SET expires_at = excluded.expires_at
+WHERE agent_safe_replay.expires_at <= ?`), id, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Revoke lists id as revoked.
+func (s *Store) Revoke(id string) error {
+	ctx, cancel := s.ctx()
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, s.q(`
+INSERT INTO agent_safe_revocations (id, revoked_at) VALUES (?, ?)
+ON CONFLICT (id) DO NOTHING`), strings.ToLower(id), s.now().Unix())
+	return err
+}
+
+// IsRevoked reports whether id is listed as revoked.
+func (s *Store) IsRevoked(id string) (bool, error) {
+	ctx, cancel := s.ctx()
+	defer cancel()
+	var one int
+	err := s.db.QueryRowContext(ctx, s.q(`SELECT 1 FROM agent_safe_revocations WHERE id = ?`),
+		strings.ToLower(id)).Scan(&one)
+	if errors.Is(err, dbsql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Append adds a decision to the audit log.
+func (s *Store) Append(r spl.AuditRecord) error {
+	req, err := json.Marshal(r.Request)
+	if err != nil {
+		return fmt.Errorf("sql: audit request: %w", err)
+	}
+	if r.Time.IsZero() {
+		r.Time = s.now()
+	}
+	allow := 0
+	if r.Allow {
+		allow = 1
+	}
+	ctx, cancel := s.ctx()
+	defer cancel()
+	_, err = s.db.ExecContext(ctx, s.q(`INSERT INTO agent_safe_audit (at, token, request, allow, error) VALUES (?, ?, ?, ?, ?)`),
+		r.Time.UnixNano(), r.Token, string(req), allow, r.Error)
+	return err
+}
+
+// AuditEntry is an audit record with its position in the log.
+type AuditEntry struct {
+	Seq int64
+	spl.AuditRecord
+}
+
+// Audit returns up to limit audit entries in the order they were appended,
+// starting after sequence number after. Pass the last Seq returned to read
+// on.
+func (s *Store) Audit(ctx context.Context, after int64, limit int) ([]AuditEntry, error) {
+	rows, err := s.db.QueryContext(ctx, s.q(`
+SELECT seq, at, token, request, allow, error FROM agent_safe_audit
+WHERE seq > ? ORDER BY seq LIMIT ?`), after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var at int64
+		var req string
+		var allow int
+		if err := rows.Scan(&e.Seq, &at, &e.Token, &req, &allow, &e.Error); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(req), &e.Request); err != nil {
+			return nil, fmt.Errorf("sql: audit entry %d: %w", e.Seq, err)
+		}
+		e.Time, e.Allow = time.Unix(0, at), allow == 1
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Prune deletes expired counters and replay identifiers and returns how
+// many rows it removed. Run it periodically; expired rows are otherwise
+// harmless.
+func (s *Store) Prune(ctx context.Context) (int64, error) {
+	now := s.now()
+	var total int64
+	for _, d := range []struct {
+		query string
+		at    int64
+	}{
+		{`DELETE FROM agent_safe_counters WHERE expires_at <= ?`, now.Unix()},
+		{`DELETE FROM agent_safe_replay WHERE expires_at <= ?`, now.UnixMilli()},
+	} {
+		res, err := s.db.ExecContext(ctx, s.q(d.query), d.at)
+		if err != nil {
+			return total, err
+		}
+		n, _ := res.RowsAffected()
+		total += n
+	}
+	return total, nil
+}
+
+// Export returns the counters, replay identifiers and revocations that have
+// not expired, read in one transaction. The audit log is not included; read
+// it with Audit.
+func (s *Store) Export() (*spl.Snapshot, error) {
+	ctx, cancel := s.ctx()
+	defer cancel()
+	var txOpts *dbsql.TxOptions
+	if s.opts.Dialect == Postgres {
+		// PostgreSQL's default isolation gives each statement its own view.
+		txOpts = &dbsql.TxOptions{Isolation: dbsql.LevelRepeatableRead, ReadOnly: true}
+	}
+	tx, err := s.db.BeginTx(ctx, txOpts)
+	if err != nil {
+		return nil, fmt.Errorf("sql: export: %w", err)
+	}
+	defer tx.Rollback()
+	now := s.now()
+	snap := &spl.Snapshot{Taken: now.UTC()}
+
+	rows, err := tx.QueryContext(ctx, s.q(`
+SELECT action, period, count, expires_at FROM agent_safe_counters
+WHERE expires_at > ? ORDER BY action, period`), now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("sql: export: %w", err)
+	}
+	for rows.Next() {
+		var c spl.CounterState
+		var exp int64
+		if err := rows.Scan(&c.Action, &c.Window, &c.Count, &exp); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("sql: export: %w", err)
+		}
+		c.Expires = time.Unix(exp, 0).UTC()
+		snap.Counters = append(snap.Counters, c)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("sql: export: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, s.q(`SELECT id, expires_at FROM agent_safe_replay WHERE expires_at > ? ORDER BY id`), now.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("sql: export: %w", err)
+	}
+	for rows.Next() {
+		var n spl.NonceState
+		var exp int64
+		if err := rows.Scan(&n.ID, &exp); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("sql: export: %w", err)
+		}
+		n.Expires = time.UnixMilli(exp).UTC()
+		snap.Nonces = append(snap.Nonces, n)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("sql: export: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT id FROM agent_safe_revocations ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("sql: export: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("sql: export: %w", err)
+		}
+		snap.Revocations = append(snap.Revocations, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sql: export: %w", err)
+	}
+	return snap, nil
+}
+
+// Import merges a snapshot in one transaction. Counts without an expiry
+// are kept for CounterTTL.
+func (s *Store) Import(snap *spl.Snapshot) error {
+	ctx, cancel := s.ctx()
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sql: import: %w", err)
+	}
+	defer tx.Rollback()
+	now := s.now()
+	for _, c := range snap.Counters {
+		exp := c.Expires
+		if exp.IsZero() {
+			exp = now.Add(s.opts.CounterTTL)
+		}
+		if !exp.After(now) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, s.q(`
+INSERT INTO agent_safe_counters (action, period, count, expires_at) VALUES (?, ?, ?, ?)
+ON CONFLICT (action, period) DO UPDATE SET count = excluded.count, expires_at = excluded.expires_at
+WHERE agent_safe_counters.count < excluded.count`), c.Action, c.Window, c.Count, exp.Unix()); err != nil {
+			return fmt.Errorf("sql: import: %w", err)
+		}
+	}
+	for _, n := range snap.Nonces {
+		if !n.Expires.After(now) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, s.q(`
+INSERT INTO agent_safe_replay (id, expires_at) VALUES (?, ?)
+ON CONFLICT (id) DO UPDATE SET expires_at = excluded.expires_at
+WHERE agent_safe_replay.expires_at < excluded.expires_at`), n.ID, n.Expires.UnixMilli()); err != nil {
+			return fmt.Errorf("sql: import: %w", err)
+		}
+	}
+	for _, id := range snap.Revocations {
+		if _, err := tx.ExecContext(ctx, s.q(`
+INSERT INTO agent_safe_revocations (id, revoked_at) VALUES (?, ?)
+ON CONFLICT (id) DO NOTHING`), strings.ToLower(id), now.Unix()); err != nil {
+			return fmt.Errorf("sql: import: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sql: import: %w", err)
+	}
+	return nil
+}
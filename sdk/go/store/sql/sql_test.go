@@ -0,0 +1,179 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func newStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := dbsql.Open("sqlite", filepath.Join(t.TempDir(), "state.db")+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := New(db, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Migrate(context.Background()); err != nil {
+			t.Fatalf("migrate %d: %v", i, err)
+		}
+	}
+	return s
+}
+
+func TestCounters(t *testing.T) {
+	s := newStore(t)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := s.CheckAndIncrement("payments.create", "2026-01-31", 3)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 3 {
+		t.Fatalf("expected 3 of 20 concurrent requests allowed, got %d", allowed)
+	}
+	if n, err := s.Count("payments.create", "2026-01-31"); err != nil || n != 3 {
+		t.Fatalf("expected count 3, got %d, %v", n, err)
+	}
+	if n, _ := s.Count("payments.create", "2026-02-01"); n != 0 {
+		t.Fatalf("expected a new window to start at 0, got %d", n)
+	}
+	if ok, _ := s.CheckAndIncrement("search", "2026-01-31", 0); ok {
+		t.Fatal("expected a zero limit to allow nothing")
+	}
+
+	s.now = func() time.Time { return time.Now().Add(49 * time.Hour) }
+	if n, err := s.Prune(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected one expired counter pruned, got %d, %v", n, err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	s := newStore(t)
+	if ok, err := s.Claim("sig-1", time.Minute); err != nil || !ok {
+		t.Fatalf("expected the first claim to succeed, got %v, %v", ok, err)
+	}
+	if ok, err := s.Claim("sig-1", time.Minute); err != nil || ok {
+		t.Fatalf("expected a replay to be refused, got %v, %v", ok, err)
+	}
+	s.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if ok, _ := s.Claim("sig-1", time.Minute); !ok {
+		t.Fatal("expected the claim to expire")
+	}
+	if _, err := s.Claim("sig-2", 0); err == nil {
+		t.Fatal("expected a zero TTL to be refused")
+	}
+}
+
+func TestRevocations(t *testing.T) {
+	s := newStore(t)
+	_, priv := spl.GenerateKeypair()
+	tok, err := spl.Mint("#t", priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := spl.RevokedFrom(s)
+	if revoked(tok) {
+		t.Fatal("expected the token not to be revoked yet")
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Revoke(tok.PublicKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !revoked(tok) {
+		t.Fatal("expected a revoked issuer key to revoke the token")
+	}
+}
+
+func TestAudit(t *testing.T) {
+	s := newStore(t)
+	at := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	for _, r := range []spl.AuditRecord{
+		{Time: at, Token: "sig-1", Request: map[string]any{"action": "read"}, Allow: true},
+		{Time: at, Token: "sig-1", Request: map[string]any{"action": "write"}, Error: "policy denied"},
+		{Time: at, Token: "sig-2"},
+	} {
+		if err := s.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, err := s.Audit(context.Background(), 0, 2)
+	if err != nil || len(first) != 2 {
+		t.Fatalf("expected 2 entries, got %v, %v", first, err)
+	}
+	if e := first[1]; !e.Time.Equal(at) || e.Allow || e.Error != "policy denied" || e.Request["action"] != "write" {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	rest, err := s.Audit(context.Background(), first[1].Seq, 10)
+	if err != nil || len(rest) != 1 || rest[0].Token != "sig-2" {
+		t.Fatalf("expected the third entry, got %v, %v", rest, err)
+	}
+}
+
+func TestPostgresPlaceholders(t *testing.T) {
+	s, err := New(nil, Options{Dialect: Postgres})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.q(`SELECT 1 WHERE a = ? AND b = ?`); got != `SELECT 1 WHERE a = $1 AND b = $2` {
+		t.Fatalf("got %s", got)
+	}
+	if _, err := New(nil, Options{Dialect: "oracle"}); err == nil {
+		t.Fatal("expected an unsupported dialect to be refused")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	src, dst := newStore(t), newStore(t)
+	src.CheckAndIncrement("pay", "2026-01-31", 5)
+	src.CheckAndIncrement("pay", "2026-01-31", 5)
+	src.Claim("sig", time.Hour)
+	src.Revoke("ABC")
+	dst.CheckAndIncrement("read", "2026-01-31", 5)
+	for i := 0; i < 2; i++ {
+		if err := spl.CopyState(dst, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := dst.Count("pay", "2026-01-31"); n != 2 {
+		t.Fatalf("expected count 2, got %d", n)
+	}
+	if n, _ := dst.Count("read", "2026-01-31"); n != 1 {
+		t.Fatalf("expected the existing count kept, got %d", n)
+	}
+	if ok, _ := dst.Claim("sig", time.Hour); ok {
+		t.Fatal("expected the imported claim to be refused")
+	}
+	if revoked, _ := dst.IsRevoked("abc"); !revoked {
+		t.Fatal("expected the revocation imported")
+	}
+	snap, err := dst.Export()
+	if err != nil || len(snap.Counters) != 2 || len(snap.Nonces) != 1 || len(snap.Revocations) != 1 {
+		t.Fatalf("unexpected snapshot %+v %v", snap, err)
+	}
+}
@@ -0,0 +1,92 @@
+package toolguard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jmcentire/agent-safe/sdk/go/mcpguard"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// FunctionCall is a function call a model proposes: an OpenAI tool_calls
+// entry or an Anthropic tool_use block. Arguments may be a JSON object, or
+// a string holding one as OpenAI sends them.
+type FunctionCall struct {
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// Decision is the outcome of Gate.
+type Decision struct {
+	Allow bool
+	// Reason is why the call was denied, for logs.
+	Reason string
+	// Message, set when the call is denied, explains the denial to the
+	// model; send it back as the call's result (an OpenAI tool message or
+	// an Anthropic tool_result with is_error) in place of running it.
+	Message string
+	// Arguments are the call's arguments as the policy saw them.
+	Arguments map[string]any
+}
+
+// Gate checks a proposed function call against the session's token before
+// the orchestrator runs it. The call is described to the policy as
+// GuardTool describes a tool call; arguments that are not valid JSON are
+// denied. Gate does not run anything, so it leaves no receipt.
+func (v *Verifier) Gate(ctx context.Context, token *spl.Token, call FunctionCall) Decision {
+	args, err := ParseArguments(call.Arguments)
+	if err != nil {
+		return denial(call.Name, err.Error(), nil)
+	}
+	caller, ok := ctx.Value(callerKey{}).(string)
+	if !ok {
+		caller = v.Caller
+	}
+	g := mcpguard.Guard{Token: token, Verify: v.Verify, Request: v.Request}
+	if err := g.Check(mcpguard.Call{Tool: call.Name, Arguments: args, Caller: caller}); err != nil {
+		reason := err.Error()
+		var denied *mcpguard.DeniedError
+		if errors.As(err, &denied) {
+			reason = denied.Reason
+		}
+		return denial(call.Name, reason, args)
+	}
+	return Decision{Allow: true, Arguments: args}
+}
+
+func denial(name, reason string, args map[string]any) Decision {
+	return Decision{
+		Reason:    reason,
+		Arguments: args,
+		Message: fmt.Sprintf("The call to %s was not made: it is outside this session's permissions (%s). "+
+			"Do not retry it unchanged; continue with actions you are permitted, or ask the user.", name, reason),
+	}
+}
+
+// ParseArguments normalizes function-call arguments to an object: a JSON
+// string is decoded first, empty arguments become {}, and a value that is
+// not an object is passed as {"input": value}.
+func ParseArguments(raw json.RawMessage) (map[string]any, error) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		raw = json.RawMessage(s)
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return map[string]any{}, nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("arguments are not valid JSON: %v", err)
+	}
+	if m, ok := v.(map[string]any); ok {
+		return m, nil
+	}
+	if v == nil {
+		return map[string]any{}, nil
+	}
+	return map[string]any{"input": v}, nil
+}
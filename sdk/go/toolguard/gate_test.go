@@ -0,0 +1,61 @@
+package toolguard
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGate(t *testing.T) {
+	tok := mint(t)
+	v := &Verifier{Caller: "planner"}
+	ctx := context.Background()
+	for name, tc := range map[string]struct {
+		call  FunctionCall
+		allow bool
+	}{
+		"openai string arguments":   {FunctionCall{Name: "transfer", Arguments: json.RawMessage(`"{\"amount\": 40}"`)}, true},
+		"anthropic object input":    {FunctionCall{Name: "transfer", Arguments: json.RawMessage(`{"amount": 40}`)}, true},
+		"no arguments":              {FunctionCall{Name: "search"}, true},
+		"over the limit":            {FunctionCall{Name: "transfer", Arguments: json.RawMessage(`{"amount": 400}`)}, false},
+		"unknown function":          {FunctionCall{Name: "delete_repo", Arguments: json.RawMessage(`{}`)}, false},
+		"malformed arguments":       {FunctionCall{Name: "transfer", Arguments: json.RawMessage(`"{\"amount\": "`)}, false},
+		"non-object argument value": {FunctionCall{Name: "search", Arguments: json.RawMessage(`"\"weather\""`)}, true},
+	} {
+		d := v.Gate(ctx, tok, tc.call)
+		if d.Allow != tc.allow {
+			t.Errorf("%s: expected allow=%v, got %+v", name, tc.allow, d)
+		}
+		if !d.Allow && (d.Reason == "" || !strings.Contains(d.Message, tc.call.Name)) {
+			t.Errorf("%s: expected a reason and a message naming the function, got %+v", name, d)
+		}
+	}
+	if d := v.Gate(WithCaller(ctx, "intruder"), tok, FunctionCall{Name: "search"}); d.Allow {
+		t.Fatal("expected the context's caller to be checked")
+	}
+}
+
+func TestParseArguments(t *testing.T) {
+	for raw, want := range map[string]string{
+		``:                 `{}`,
+		`null`:             `{}`,
+		`""`:               `{}`,
+		`{"a":1}`:          `{"a":1}`,
+		`"{\"a\":1}"`:      `{"a":1}`,
+		`[1,2]`:            `{"input":[1,2]}`,
+		`"\"plain text\""`: `{"input":"plain text"}`,
+	} {
+		args, err := ParseArguments(json.RawMessage(raw))
+		if err != nil {
+			t.Errorf("%s: %v", raw, err)
+			continue
+		}
+		if got, _ := json.Marshal(args); string(got) != want {
+			t.Errorf("%s: got %s, want %s", raw, got, want)
+		}
+	}
+	if _, err := ParseArguments(json.RawMessage(`{`)); err == nil {
+		t.Fatal("expected malformed JSON to be rejected")
+	}
+}
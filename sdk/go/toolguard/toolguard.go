@@ -0,0 +1,243 @@
+// Package toolguard wraps the tools of a Go agent framework so that each
+// call is checked against the agent's capability token before it runs and
+// leaves a receipt after.
+//
+// A call is described to the policy exactly as mcpguard describes an MCP
+// tool call, so one policy covers both:
+//
+//	{"tool": "search", "arguments": {"query": "..."}, "caller": "planner"}
+//
+// GuardTool wraps anything with the shape of LangChainGo's tools.Tool:
+//
+//	v := &toolguard.Verifier{
+//		Verify:  spl.VerifyTokenOptions{KeyResolver: issuers},
+//		Receipt: toolguard.JSONLines(auditLog),
+//	}
+//	agentTools := []tools.Tool{toolguard.GuardTool(tools.Calculator{}, tok, v)}
+//
+// and GuardFunc wraps a plain Go function.
+package toolguard
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/mcpguard"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// Tool is the interface agent frameworks such as LangChainGo give tools.
+// The input is free text, commonly a JSON object of arguments.
+type Tool interface {
+	Name() string
+	Description() string
+	Call(ctx context.Context, input string) (string, error)
+}
+
+// Verifier holds what guarded tools share: how to verify tokens and where
+// receipts go.
+type Verifier struct {
+	// Verify configures token verification: trust anchors, vars, counters
+	// and crypto callbacks.
+	Verify spl.VerifyTokenOptions
+	// Caller identifies the agent to policies when the context carries no
+	// caller of its own (see WithCaller).
+	Caller string
+	// Request, when set, adjusts the request map built for a call before
+	// the policy sees it.
+	Request func(call mcpguard.Call, req map[string]any)
+	// Receipt, when set, is called once per call, allowed or not, after
+	// the tool returns. Arguments that Verify.Classifier marks as
+	// sensitive, by request path such as "arguments.card_number", are
+	// redacted from it.
+	Receipt func(Receipt)
+	// DenialOutput makes a denied Tool call return the denial as its
+	// output instead of an error, so the model reads it and can choose
+	// another route; LangChainGo, for one, ends the run on a tool error.
+	// Functions wrapped with GuardFunc always return an error.
+	DenialOutput bool
+	// Result, when set, is checked against each output before it is
+	// returned. The request it sees is the call's, and the result is the
+	// output as JSON if it parses and as a string if not. An output it
+	// denies is withheld and the call fails as denied, unless
+	// Result.ReportOnly is set; either way the receipt records why.
+	Result *spl.ResultPolicy
+}
+
+// Receipt records one tool call. The output itself is not kept, only its
+// SHA-256, so receipts can be logged where results may not.
+type Receipt struct {
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments"`
+	Caller    string         `json:"caller,omitempty"`
+	// Token is the signature of the token the call was checked against,
+	// the identifier revocation lists use.
+	Token        string `json:"token,omitempty"`
+	Allowed      bool   `json:"allowed"`
+	Reason       string `json:"reason,omitempty"`
+	Error        string `json:"error,omitempty"`
+	OutputSHA256 string `json:"output_sha256,omitempty"`
+	// ResultViolation is why Verifier.Result denied the output.
+	ResultViolation string        `json:"result_violation,omitempty"`
+	Start           time.Time     `json:"start"`
+	Duration        time.Duration `json:"duration_ns"`
+}
+
+// JSONLines returns a Receipt func that writes each receipt to w as a line
+// of JSON. It is safe for concurrent use.
+func JSONLines(w io.Writer) func(Receipt) {
+	var mu sync.Mutex
+	return func(r Receipt) {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(append(b, '\n'))
+	}
+}
+
+type callerKey struct{}
+
+// WithCaller returns a context that names the caller of the tools called
+// with it, overriding Verifier.Caller.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// GuardTool returns tool with every call checked against token. A denied
+// call does not reach tool and fails with a *mcpguard.DeniedError, unless
+// v.DenialOutput is set. An input that is a JSON object becomes the call's
+// arguments; any other input is passed as {"input": "..."}.
+func GuardTool(tool Tool, token *spl.Token, v *Verifier) Tool {
+	return &guardedTool{Tool: tool, token: token, v: v}
+}
+
+type guardedTool struct {
+	Tool
+	token *spl.Token
+	v     *Verifier
+}
+
+func (t *guardedTool) Call(ctx context.Context, input string) (string, error) {
+	var args map[string]any
+	if json.Unmarshal([]byte(input), &args) != nil || args == nil {
+		args = map[string]any{"input": input}
+	}
+	out, err := run(ctx, t.v, t.token, t.Name(), args, func() (string, []byte, error) {
+		out, err := t.Tool.Call(ctx, input)
+		return out, []byte(out), err
+	})
+	var denied *mcpguard.DeniedError
+	if t.v.DenialOutput && errors.As(err, &denied) {
+		return denied.Error(), nil
+	}
+	return out, err
+}
+
+// redactArgs redacts the arguments c marks as sensitive, at their paths in
+// the call's request map.
+func redactArgs(c *spl.FieldClassifier, args map[string]any) map[string]any {
+	if c == nil {
+		return args
+	}
+	redacted, _ := c.Redact(map[string]any{"arguments": args})["arguments"].(map[string]any)
+	return redacted
+}
+
+// GuardFunc returns fn with every call checked against token as a call of
+// the tool name. The arguments are in as JSON, which should be an object;
+// any other value is passed as {"input": in}. A denied call does not reach
+// fn and fails with a *mcpguard.DeniedError.
+func GuardFunc[In, Out any](name string, fn func(context.Context, In) (Out, error), token *spl.Token, v *Verifier) func(context.Context, In) (Out, error) {
+	return func(ctx context.Context, in In) (Out, error) {
+		return run(ctx, v, token, name, arguments(in), func() (Out, []byte, error) {
+			out, err := fn(ctx, in)
+			b, _ := json.Marshal(out)
+			return out, b, err
+		})
+	}
+}
+
+func arguments(in any) map[string]any {
+	var args map[string]any
+	if b, err := json.Marshal(in); err == nil && json.Unmarshal(b, &args) == nil && args != nil {
+		return args
+	}
+	return map[string]any{"input": in}
+}
+
+// run checks the call, runs it if allowed and records its receipt. exec
+// returns the result with the bytes its receipt hashes.
+func run[Out any](ctx context.Context, v *Verifier, token *spl.Token, name string, args map[string]any, exec func() (Out, []byte, error)) (Out, error) {
+	caller, ok := ctx.Value(callerKey{}).(string)
+	if !ok {
+		caller = v.Caller
+	}
+	call := mcpguard.Call{Tool: name, Arguments: args, Caller: caller}
+	r := Receipt{Tool: name, Arguments: redactArgs(v.Verify.Classifier, args), Caller: caller, Start: time.Now()}
+	if token != nil {
+		r.Token = token.Signature
+	}
+	g := mcpguard.Guard{Token: token, Verify: v.Verify, Request: v.Request}
+	var out Out
+	err := g.Check(call)
+	if err != nil {
+		var denied *mcpguard.DeniedError
+		if errors.As(err, &denied) {
+			r.Reason = denied.Reason
+		}
+	} else {
+		r.Allowed = true
+		var b []byte
+		out, b, err = exec()
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			sum := sha256.Sum256(b)
+			r.OutputSHA256 = hex.EncodeToString(sum[:])
+			if v.Result != nil {
+				err = checkResult(v, call, b, &r)
+				if err != nil {
+					var zero Out
+					out = zero
+				}
+			}
+		}
+	}
+	r.Duration = time.Since(r.Start)
+	if v.Receipt != nil {
+		v.Receipt(r)
+	}
+	return out, err
+}
+
+// checkResult applies v.Result to a call's output, recording a violation
+// in r. It returns the error the call fails with, or nil to let the output
+// through.
+func checkResult(v *Verifier, call mcpguard.Call, output []byte, r *Receipt) error {
+	req := mcpguard.RequestMap(call)
+	if v.Request != nil {
+		v.Request(call, req)
+	}
+	var result any
+	if json.Unmarshal(output, &result) != nil {
+		result = string(output)
+	}
+	err := v.Result.Check(req, result)
+	if err == nil {
+		return nil
+	}
+	r.ResultViolation = err.Error()
+	if v.Result.ReportOnly {
+		return nil
+	}
+	return &mcpguard.DeniedError{Tool: call.Tool, Reason: err.Error()}
+}
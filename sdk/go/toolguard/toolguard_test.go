@@ -0,0 +1,198 @@
+package toolguard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/mcpguard"
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+type searchTool struct{ calls int }
+
+func (s *searchTool) Name() string        { return "search" }
+func (s *searchTool) Description() string { return "search the web" }
+func (s *searchTool) Call(_ context.Context, input string) (string, error) {
+	s.calls++
+	return "results for " + input, nil
+}
+
+func mint(t *testing.T) *spl.Token {
+	t.Helper()
+	_, priv := spl.GenerateKeypair()
+	tok, err := spl.Mint(`(and (or (= (get req "tool") "search") (= (get req "tool") "transfer"))
+  (<= (get (get req "arguments") "amount") 100)
+  (not (= (get (get req "arguments") "input") "secrets"))
+  (= (get req "caller") "planner"))`, priv, spl.MintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func receipts(buf *bytes.Buffer) []Receipt {
+	var out []Receipt
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var r Receipt
+		json.Unmarshal([]byte(line), &r)
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestGuardTool(t *testing.T) {
+	tok := mint(t)
+	var log bytes.Buffer
+	v := &Verifier{Caller: "planner", Receipt: JSONLines(&log)}
+	inner := &searchTool{}
+	tool := GuardTool(inner, tok, v)
+	if tool.Name() != "search" || tool.Description() != "search the web" {
+		t.Fatal("expected the wrapper to keep the tool's name and description")
+	}
+
+	ctx := context.Background()
+	if out, err := tool.Call(ctx, "weather"); err != nil || out != "results for weather" {
+		t.Fatalf("got %q, %v", out, err)
+	}
+	_, err := tool.Call(ctx, "secrets")
+	var denied *mcpguard.DeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("expected a DeniedError, got %v", err)
+	}
+	if _, err := tool.Call(WithCaller(ctx, "intruder"), "weather"); err == nil {
+		t.Fatal("expected the context's caller to be checked")
+	}
+	if inner.calls != 1 {
+		t.Fatalf("expected denied calls not to reach the tool, got %d calls", inner.calls)
+	}
+
+	v.DenialOutput = true
+	out, err := tool.Call(ctx, "secrets")
+	if err != nil || !strings.Contains(out, "denied tool") {
+		t.Fatalf("expected the denial as output, got %q, %v", out, err)
+	}
+
+	rs := receipts(&log)
+	if len(rs) != 4 {
+		t.Fatalf("expected a receipt per call, got %d", len(rs))
+	}
+	if !rs[0].Allowed || rs[0].OutputSHA256 == "" || rs[0].Token != tok.Signature || rs[0].Arguments["input"] != "weather" {
+		t.Fatalf("unexpected receipt for the allowed call: %+v", rs[0])
+	}
+	if rs[1].Allowed || rs[1].Reason == "" || rs[1].OutputSHA256 != "" {
+		t.Fatalf("unexpected receipt for the denied call: %+v", rs[1])
+	}
+	if rs[2].Caller != "intruder" {
+		t.Fatalf("expected the receipt to record the context's caller, got %q", rs[2].Caller)
+	}
+}
+
+type transfer struct {
+	To     string  `json:"to"`
+	Amount float64 `json:"amount"`
+}
+
+func TestGuardFunc(t *testing.T) {
+	var got []Receipt
+	v := &Verifier{Caller: "planner", Receipt: func(r Receipt) { got = append(got, r) }}
+	failure := errors.New("bank offline")
+	fn := GuardFunc("transfer", func(_ context.Context, in transfer) (string, error) {
+		if in.To == "offline" {
+			return "", failure
+		}
+		return "sent", nil
+	}, mint(t), v)
+
+	ctx := context.Background()
+	if out, err := fn(ctx, transfer{To: "alice", Amount: 50}); err != nil || out != "sent" {
+		t.Fatalf("got %q, %v", out, err)
+	}
+	if _, err := fn(ctx, transfer{To: "alice", Amount: 500}); err == nil {
+		t.Fatal("expected a transfer over the limit to be denied")
+	}
+	if _, err := fn(ctx, transfer{To: "offline", Amount: 5}); !errors.Is(err, failure) {
+		t.Fatalf("expected the function's error, got %v", err)
+	}
+	if len(got) != 3 || got[0].Arguments["amount"] != 50.0 || got[1].Allowed || got[2].Error != "bank offline" {
+		t.Fatalf("unexpected receipts: %+v", got)
+	}
+
+	if _, err := GuardFunc("transfer", func(context.Context, int) (int, error) { return 0, nil }, nil, v)(ctx, 1); err == nil {
+		t.Fatal("expected a nil token to deny")
+	}
+
+	got = nil
+	v.Verify.Classifier = &spl.FieldClassifier{Fields: map[string]spl.Sensitivity{"arguments.to": spl.Hash}}
+	if _, err := fn(ctx, transfer{To: "alice", Amount: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if to, _ := got[0].Arguments["to"].(string); !strings.HasPrefix(to, "sha256:") || got[0].Arguments["amount"] != 50.0 {
+		t.Fatalf("expected the recipient hashed in the receipt, got %+v", got[0].Arguments)
+	}
+}
+
+func TestResultPolicy(t *testing.T) {
+	type row struct {
+		ID  int    `json:"id"`
+		SSN string `json:"ssn,omitempty"`
+	}
+	var got []Receipt
+	v := &Verifier{Caller: "planner", Receipt: func(r Receipt) { got = append(got, r) }}
+	v.Result = &spl.ResultPolicy{
+		Policy: `(and (<= (get (get req "result") "count") 2) (not (member "pii" (get (get req "result") "classes"))))`,
+		Classify: func(result any) []string {
+			if b, _ := json.Marshal(result); strings.Contains(string(b), `"ssn"`) {
+				return []string{"pii"}
+			}
+			return nil
+		},
+	}
+	query := GuardFunc("transfer", func(_ context.Context, in transfer) ([]row, error) {
+		rows := make([]row, int(in.Amount))
+		for i := range rows {
+			rows[i].ID = i
+		}
+		if in.To == "ssn" {
+			rows[0].SSN = "000-00-0000"
+		}
+		return rows, nil
+	}, mint(t), v)
+
+	ctx := context.Background()
+	if rows, err := query(ctx, transfer{To: "a", Amount: 2}); err != nil || len(rows) != 2 {
+		t.Fatalf("got %v, %v", rows, err)
+	}
+	for _, in := range []transfer{{To: "a", Amount: 3}, {To: "ssn", Amount: 1}} {
+		rows, err := query(ctx, in)
+		var denied *mcpguard.DeniedError
+		if !errors.As(err, &denied) || rows != nil {
+			t.Fatalf("%+v: expected the result withheld, got %v, %v", in, rows, err)
+		}
+	}
+	if len(got) != 3 || got[0].ResultViolation != "" || got[1].ResultViolation == "" || !got[1].Allowed || got[1].OutputSHA256 == "" {
+		t.Fatalf("unexpected receipts: %+v", got)
+	}
+
+	v.Result.ReportOnly = true
+	got = nil
+	if rows, err := query(ctx, transfer{To: "a", Amount: 3}); err != nil || len(rows) != 3 {
+		t.Fatalf("expected a reported result through, got %v, %v", rows, err)
+	}
+	if got[0].ResultViolation == "" {
+		t.Fatal("expected the receipt to record the violation")
+	}
+
+	// Tool output that is not JSON is checked as a string.
+	v.Result = &spl.ResultPolicy{Policy: `(<= (get (get req "result") "bytes") 24)`}
+	tool := GuardTool(&searchTool{}, mint(t), v)
+	if _, err := tool.Call(ctx, "weather"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tool.Call(ctx, "the weather in a very long place name"); err == nil {
+		t.Fatal("expected a long output to be withheld")
+	}
+}
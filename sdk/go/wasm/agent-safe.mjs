@@ -0,0 +1,80 @@
+// Loader for agent-safe.wasm, the Go verifier built for js/wasm. It gives
+// browsers and Node the parse, verifyToken and mint functions of the JS SDK,
+// evaluated by the same code as Go services.
+//
+//   import './wasm_exec.js';            // from $(go env GOROOT)/lib/wasm
+//   import { load } from './agent-safe.mjs';
+//   const spl = await load(fetch('/agent-safe.wasm'));
+//   const { allow, error } = spl.verifyToken(token, req, { trustedKeys });
+
+const EXPORTS = '__agentSafeGo';
+
+/**
+ * Instantiate the module and return its functions. wasm is the module's
+ * bytes, a Response or promise of one, or a compiled WebAssembly.Module.
+ * wasm_exec.js must have been loaded first, defining globalThis.Go.
+ */
+export async function load(wasm) {
+  if (typeof globalThis.Go !== 'function') {
+    throw new Error('agent-safe: load wasm_exec.js before agent-safe.mjs');
+  }
+  const go = new globalThis.Go();
+  wasm = await wasm;
+  let instance;
+  if (wasm instanceof WebAssembly.Module) {
+    instance = await WebAssembly.instantiate(wasm, go.importObject);
+  } else if (typeof Response !== 'undefined' && wasm instanceof Response) {
+    ({ instance } = await WebAssembly.instantiateStreaming(wasm, go.importObject));
+  } else {
+    ({ instance } = await WebAssembly.instantiate(wasm, go.importObject));
+  }
+  // run resolves only when the Go program exits, which it does not.
+  go.run(instance);
+  const fns = globalThis[EXPORTS];
+  delete globalThis[EXPORTS];
+  if (!fns) {
+    throw new Error('agent-safe: module did not register its exports');
+  }
+  return bind(fns);
+}
+
+function result(json) {
+  const r = JSON.parse(json);
+  if (r.error !== undefined && Object.keys(r).length === 1) {
+    throw new Error(r.error);
+  }
+  return r;
+}
+
+function bind(fns) {
+  return {
+    /** Parse a policy and return its AST. Throws on a syntax error. */
+    parse(policy) {
+      return result(fns.parse(policy)).ast;
+    },
+
+    /**
+     * Verify a token, an object or a JSON or compact string, and evaluate
+     * its policy against req. Options are the JS SDK's VerifyTokenOptions
+     * plus hmacSecret (hex) and trustedKeys, a list of
+     * { kid, alg, publicKey, notBefore, notAfter } the issuer must be in.
+     * Returns { allow, sealed, error, gasUsed }; it does not throw.
+     */
+    verifyToken(token, req, options = {}) {
+      const { per_day_count, crypto, ...data } = options;
+      const t = typeof token === 'string' ? token : JSON.stringify(token);
+      return JSON.parse(fns.verifyToken(t, JSON.stringify(req ?? {}), JSON.stringify(data), { per_day_count, crypto }));
+    },
+
+    /** Mint a token signed with the issuer's hex private key. */
+    mint(policy, privateKeyHex, options = {}) {
+      return result(fns.mint(policy, privateKeyHex, JSON.stringify(options))).token;
+    },
+
+    /** Sign a presentation of a PoP-bound token with the agent's key. */
+    createPresentationSignature(token, agentPrivateKeyHex) {
+      const t = typeof token === 'string' ? token : JSON.stringify(token);
+      return result(fns.createPresentationSignature(t, agentPrivateKeyHex)).signature;
+    },
+  };
+}
@@ -0,0 +1,175 @@
+// Command wasm exports the Go verifier to JavaScript. Built with
+//
+//	GOOS=js GOARCH=wasm go build -o agent-safe.wasm ./wasm
+//
+// it is loaded through agent-safe.mjs, which gives browsers and Node the
+// parse, verifyToken and mint functions of the JS SDK backed by this
+// package's spl, so dashboards verify tokens exactly as Go services do.
+//
+// The functions here take and return JSON, leaving the conversion of
+// JavaScript values to main.go and the facade.
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// verifyOptions are the data fields of the JS SDK's VerifyTokenOptions plus
+// the Go verifier's trust anchors. Callbacks are passed separately.
+type verifyOptions struct {
+	Vars                  map[string]any `json:"vars"`
+	Now                   string         `json:"now"`
+	PresentationSignature string         `json:"presentationSignature"`
+	// HMACSecret is hex.
+	HMACSecret  string       `json:"hmacSecret"`
+	TrustedKeys []trustedKey `json:"trustedKeys"`
+}
+
+type trustedKey struct {
+	Kid       string `json:"kid"`
+	Alg       string `json:"alg"`
+	PublicKey string `json:"publicKey"`
+	NotBefore string `json:"notBefore"`
+	NotAfter  string `json:"notAfter"`
+}
+
+// verifyResult is the JS SDK's verifyToken result.
+type verifyResult struct {
+	Allow   bool   `json:"allow"`
+	Sealed  bool   `json:"sealed"`
+	Error   string `json:"error,omitempty"`
+	GasUsed int    `json:"gasUsed"`
+}
+
+// mintOptions are the JS SDK's MintOptions.
+type mintOptions struct {
+	MerkleRoot          string `json:"merkleRoot"`
+	HashChainCommitment string `json:"hashChainCommitment"`
+	Sealed              bool   `json:"sealed"`
+	Expires             string `json:"expires"`
+	PopKey              string `json:"popKey"`
+	Alg                 string `json:"alg"`
+}
+
+// failure is returned by parse and mint when they fail; the facade throws.
+type failure struct {
+	Error string `json:"error"`
+}
+
+func encode(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(failure{err.Error()})
+	}
+	return string(b)
+}
+
+func parse(policy string) string {
+	ast, err := spl.Parse(policy)
+	if err != nil {
+		return encode(failure{err.Error()})
+	}
+	return encode(map[string]any{"ast": ast})
+}
+
+// verifyToken verifies a token, JSON or compact, against the request JSON.
+// cb supplies the per-day counter and crypto predicates; it may be zero.
+func verifyToken(token, request, options string, cb spl.VerifyTokenOptions) string {
+	tok, err := spl.ParseToken(token)
+	if err != nil {
+		return encode(verifyResult{Error: err.Error()})
+	}
+	var req map[string]any
+	if err := json.Unmarshal([]byte(request), &req); err != nil {
+		return encode(verifyResult{Sealed: tok.Sealed, Error: "invalid request JSON: " + err.Error()})
+	}
+	var o verifyOptions
+	if options != "" {
+		if err := json.Unmarshal([]byte(options), &o); err != nil {
+			return encode(verifyResult{Sealed: tok.Sealed, Error: "invalid options: " + err.Error()})
+		}
+	}
+	opts := spl.VerifyTokenOptions{
+		Vars:                  o.Vars,
+		Now:                   o.Now,
+		PresentationSignature: o.PresentationSignature,
+		PerDayCount:           cb.PerDayCount,
+		Crypto:                cb.Crypto,
+	}
+	if o.HMACSecret != "" {
+		if opts.HMACSecret, err = hex.DecodeString(o.HMACSecret); err != nil {
+			return encode(verifyResult{Sealed: tok.Sealed, Error: "invalid hmacSecret: " + err.Error()})
+		}
+	}
+	if len(o.TrustedKeys) > 0 {
+		ring, err := keyRing(o.TrustedKeys)
+		if err != nil {
+			return encode(verifyResult{Sealed: tok.Sealed, Error: err.Error()})
+		}
+		opts.KeyResolver = ring
+	}
+	r := spl.VerifyTokenObj(tok, req, opts)
+	return encode(verifyResult{Allow: r.Allow, Sealed: r.Sealed, Error: r.ErrorMessage(), GasUsed: r.GasUsed})
+}
+
+func keyRing(keys []trustedKey) (*spl.KeyRing, error) {
+	ring, _ := spl.NewKeyRing()
+	for i, k := range keys {
+		e := spl.KeyRingEntry{ID: k.Kid, Alg: k.Alg, PublicKey: k.PublicKey}
+		for _, t := range []struct {
+			s   string
+			dst *time.Time
+		}{{k.NotBefore, &e.NotBefore}, {k.NotAfter, &e.NotAfter}} {
+			if t.s == "" {
+				continue
+			}
+			v, err := time.Parse(time.RFC3339, t.s)
+			if err != nil {
+				return nil, fmt.Errorf("trustedKeys[%d]: %w", i, err)
+			}
+			*t.dst = v
+		}
+		if err := ring.Add(e); err != nil {
+			return nil, fmt.Errorf("trustedKeys[%d]: %w", i, err)
+		}
+	}
+	return ring, nil
+}
+
+func mint(policy, privateKey, options string) string {
+	var o mintOptions
+	if options != "" {
+		if err := json.Unmarshal([]byte(options), &o); err != nil {
+			return encode(failure{"invalid options: " + err.Error()})
+		}
+	}
+	tok, err := spl.Mint(policy, privateKey, spl.MintOptions{
+		MerkleRoot:          o.MerkleRoot,
+		HashChainCommitment: o.HashChainCommitment,
+		Sealed:              o.Sealed,
+		Expires:             o.Expires,
+		PoPKey:              o.PopKey,
+		Alg:                 o.Alg,
+	})
+	if err != nil {
+		return encode(failure{err.Error()})
+	}
+	return encode(map[string]any{"token": tok})
+}
+
+func createPresentationSignature(token, agentPrivateKey string) string {
+	tok, err := spl.ParseToken(token)
+	if err != nil {
+		return encode(failure{err.Error()})
+	}
+	sig, err := spl.CreatePresentationSignature(tok, agentPrivateKey)
+	if err != nil {
+		return encode(failure{err.Error()})
+	}
+	return encode(map[string]any{"signature": sig})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+func TestAPI(t *testing.T) {
+	if got := parse(`(= (get req "action") "read")`); got != `{"ast":["=",["get","req","action"],"read"]}` {
+		t.Fatalf("parse: %s", got)
+	}
+	if got := parse("(and"); !strings.Contains(got, `"error"`) {
+		t.Fatalf("expected a parse error, got %s", got)
+	}
+
+	pub, priv := spl.GenerateKeypair()
+	var minted struct{ Token *spl.Token }
+	if err := json.Unmarshal([]byte(mint(`(and (= (get req "action") "read") (dpop_ok?))`, priv, `{"expires":"2030-01-01T00:00:00Z"}`)), &minted); err != nil || minted.Token == nil {
+		t.Fatalf("mint: %v", err)
+	}
+	tok, _ := json.Marshal(minted.Token)
+	compact, _ := minted.Token.Compact()
+	dpop := spl.VerifyTokenOptions{Crypto: spl.CryptoCallbacks{DPoPOk: func() bool { return true }}}
+	other, _ := spl.GenerateKeypair()
+
+	for name, tc := range map[string]struct {
+		token, req, options string
+		cb                  spl.VerifyTokenOptions
+		allow               bool
+	}{
+		"allowed":          {string(tok), `{"action":"read"}`, "", dpop, true},
+		"compact":          {compact, `{"action":"read"}`, `{"now":"2029-01-01T00:00:00Z"}`, dpop, true},
+		"denied":           {string(tok), `{"action":"write"}`, "", dpop, false},
+		"no callbacks":     {string(tok), `{"action":"read"}`, "", spl.VerifyTokenOptions{}, false},
+		"expired":          {string(tok), `{"action":"read"}`, `{"now":"2031-01-01T00:00:00Z"}`, dpop, false},
+		"trusted issuer":   {string(tok), `{"action":"read"}`, `{"trustedKeys":[{"kid":"k","publicKey":"` + pub + `"}]}`, dpop, true},
+		"untrusted issuer": {string(tok), `{"action":"read"}`, `{"trustedKeys":[{"kid":"k","publicKey":"` + other + `"}]}`, dpop, false},
+		"bad request JSON": {string(tok), `{`, "", dpop, false},
+		"bad options":      {string(tok), `{"action":"read"}`, `[]`, dpop, false},
+		"malformed token":  {"{", `{"action":"read"}`, "", dpop, false},
+	} {
+		var r verifyResult
+		if err := json.Unmarshal([]byte(verifyToken(tc.token, tc.req, tc.options, tc.cb)), &r); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if r.Allow != tc.allow {
+			t.Errorf("%s: expected allow=%v, got %+v", name, tc.allow, r)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/jmcentire/agent-safe/sdk/go/spl"
+)
+
+// exportName is the global the facade collects the exports from.
+const exportName = "__agentSafeGo"
+
+func main() {
+	js.Global().Set(exportName, js.ValueOf(map[string]any{
+		"parse": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			return parse(arg(args, 0))
+		}),
+		"verifyToken": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			var cb js.Value
+			if len(args) > 3 {
+				cb = args[3]
+			}
+			return verifyToken(arg(args, 0), arg(args, 1), arg(args, 2), callbacks(cb))
+		}),
+		"mint": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			return mint(arg(args, 0), arg(args, 1), arg(args, 2))
+		}),
+		"createPresentationSignature": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			return createPresentationSignature(arg(args, 0), arg(args, 1))
+		}),
+	}))
+	select {}
+}
+
+func arg(args []js.Value, i int) string {
+	if i >= len(args) || args[i].Type() != js.TypeString {
+		return ""
+	}
+	return args[i].String()
+}
+
+// callbacks wraps the JS SDK's per_day_count and crypto option functions.
+// A predicate that is missing, or does not return true, fails closed.
+func callbacks(o js.Value) spl.VerifyTokenOptions {
+	var opts spl.VerifyTokenOptions
+	if o.Type() != js.TypeObject {
+		return opts
+	}
+	if f := o.Get("per_day_count"); f.Type() == js.TypeFunction {
+		opts.PerDayCount = func(action, day string) int {
+			n := f.Invoke(action, day)
+			if n.Type() != js.TypeNumber {
+				return 0
+			}
+			return n.Int()
+		}
+	}
+	c := o.Get("crypto")
+	if c.Type() != js.TypeObject {
+		return opts
+	}
+	fn := func(name string) (js.Value, bool) {
+		f := c.Get(name)
+		return f, f.Type() == js.TypeFunction
+	}
+	if f, ok := fn("dpop_ok"); ok {
+		opts.Crypto.DPoPOk = func() bool { return f.Invoke().Equal(js.ValueOf(true)) }
+	}
+	if f, ok := fn("thresh_ok"); ok {
+		opts.Crypto.ThreshOk = func() bool { return f.Invoke().Equal(js.ValueOf(true)) }
+	}
+	if f, ok := fn("attested_ok"); ok {
+		opts.Crypto.AttestedOk = func() bool { return f.Invoke().Equal(js.ValueOf(true)) }
+	}
+	if f, ok := fn("merkle_ok"); ok {
+		opts.Crypto.MerkleOk = func(tuple []any) bool { return f.Invoke(js.ValueOf(tuple)).Equal(js.ValueOf(true)) }
+	}
+	if f, ok := fn("vrf_ok"); ok {
+		opts.Crypto.VRFOk = func(day string, amount float64) bool { return f.Invoke(day, amount).Equal(js.ValueOf(true)) }
+	}
+	return opts
+}
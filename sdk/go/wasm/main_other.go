@@ -0,0 +1,13 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Fprintln(os.Stderr, "build with GOOS=js GOARCH=wasm; see agent-safe.mjs")
+	os.Exit(2)
+}
@@ -0,0 +1,50 @@
+// Smoke test of the js/wasm build under Node:
+//
+//   GOOS=js GOARCH=wasm go build -o wasm/agent-safe.wasm ./wasm
+//   node wasm/test.mjs "$(go env GOROOT)/lib/wasm/wasm_exec.js" wasm/agent-safe.wasm
+
+import assert from 'node:assert/strict';
+import { generateKeyPairSync } from 'node:crypto';
+import { readFile } from 'node:fs/promises';
+import { pathToFileURL } from 'node:url';
+
+const [wasmExec, wasmPath] = process.argv.slice(2);
+await import(pathToFileURL(wasmExec).href);
+const { load } = await import('./agent-safe.mjs');
+const spl = await load(readFile(wasmPath));
+
+function keypair() {
+  const { publicKey, privateKey } = generateKeyPairSync('ed25519');
+  return {
+    publicKey: publicKey.export({ type: 'spki', format: 'der' }).subarray(12).toString('hex'),
+    privateKey: privateKey.export({ type: 'pkcs8', format: 'der' }).subarray(16).toString('hex'),
+  };
+}
+
+assert.deepEqual(spl.parse('(= (get req "action") "read")'), ['=', ['get', 'req', 'action'], 'read']);
+assert.throws(() => spl.parse('(and'));
+
+const issuer = keypair();
+const policy = '(and (= (get req "action") "read") (<= (per-day-count "read" "2026-01-01") 2) (dpop_ok?))';
+const token = spl.mint(policy, issuer.privateKey);
+assert.equal(token.public_key, issuer.publicKey);
+
+const opts = { per_day_count: () => 1, crypto: { dpop_ok: () => true } };
+assert.equal(spl.verifyToken(token, { action: 'read' }, opts).allow, true);
+assert.equal(spl.verifyToken(JSON.stringify(token), { action: 'write' }, opts).allow, false);
+assert.equal(spl.verifyToken(token, { action: 'read' }, { ...opts, per_day_count: () => 3 }).allow, false);
+assert.equal(spl.verifyToken(token, { action: 'read' }, { per_day_count: () => 1 }).allow, false);
+
+const other = keypair();
+const r = spl.verifyToken(token, { action: 'read' }, { ...opts, trustedKeys: [{ kid: 'k', publicKey: other.publicKey }] });
+assert.equal(r.allow, false);
+assert.ok(r.error);
+
+const agent = keypair();
+const bound = spl.mint('#t', issuer.privateKey, { popKey: agent.publicKey });
+assert.equal(spl.verifyToken(bound, {}).allow, false);
+const presentationSignature = spl.createPresentationSignature(bound, agent.privateKey);
+assert.equal(spl.verifyToken(bound, {}, { presentationSignature }).allow, true);
+
+console.log('ok');
+process.exit(0);